@@ -87,6 +87,7 @@ func main() {
 	startNotificationWorkers(appCtx, notificationService, taskMonitor, &backgroundTasks)
 	startSubscriptionLifecycleSweep(appCtx, service.NewSubscriptionService(db), taskMonitor, &backgroundTasks)
 	startScheduledBackupWorker(appCtx, service.NewAdminService(db), taskMonitor, &backgroundTasks)
+	startAuditRetentionSweep(appCtx, service.NewAuditService(db), taskMonitor, &backgroundTasks)
 
 	setupUploads(e, filepath.Join(pkg.GetDataPath(), "assets"))
 
@@ -639,6 +640,77 @@ func startScheduledBackupWorker(
 	}()
 }
 
+// startAuditRetentionSweep deletes audit events older than the configured
+// retention window once an hour.
+func startAuditRetentionSweep(
+	ctx context.Context,
+	audit *service.AuditService,
+	monitor *service.BackgroundTaskMonitor,
+	wg *sync.WaitGroup,
+) {
+	const (
+		taskKey       = "audit_retention_sweep"
+		sweepInterval = time.Hour
+	)
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if monitor != nil {
+		monitor.Register(
+			taskKey,
+			"Audit retention sweep",
+			"Deletes audit events older than the configured retention period.",
+			sweepInterval,
+		)
+	}
+
+	runSweep := func() {
+		run := func() error {
+			_, err := audit.PurgeExpired()
+			return err
+		}
+		if monitor != nil {
+			if err := monitor.Run(taskKey, run); err != nil {
+				logging.Error("audit retention sweep failed", slog.Any("error", err))
+			}
+			return
+		}
+		if err := run(); err != nil {
+			logging.Error("audit retention sweep failed", slog.Any("error", err))
+		}
+	}
+
+	if wg != nil {
+		wg.Add(1)
+	}
+
+	go func() {
+		if wg != nil {
+			defer wg.Done()
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		runSweep()
+
+		ticker := time.NewTicker(sweepInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				runSweep()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
 // assetsPathPrefix is Vite's output directory for content-hashed build assets.
 const assetsPathPrefix = "assets/"
 
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"mime/multipart"
 	"net/http"
 	"os"
@@ -15,9 +16,12 @@ import (
 	"time"
 
 	"github.com/labstack/echo/v4"
+	"github.com/shiroha/subdux/internal/model"
 	"github.com/shiroha/subdux/internal/pkg"
+	"github.com/shiroha/subdux/internal/pkg/logging"
 	"github.com/shiroha/subdux/internal/service"
 	"github.com/yeka/zip"
+	"gorm.io/gorm"
 )
 
 type AdminHandler struct {
@@ -84,7 +88,7 @@ func isRestorableAssetPath(relativePath string) bool {
 		return false
 	}
 	parts := strings.Split(relativePath, "/")
-	if len(parts) != 2 || parts[0] != "icons" {
+	if len(parts) != 2 {
 		return false
 	}
 	filename := parts[1]
@@ -92,7 +96,14 @@ func isRestorableAssetPath(relativePath string) bool {
 		return false
 	}
 	ext := strings.ToLower(path.Ext(filename))
-	return ext == ".png" || ext == ".jpg" || ext == ".jpeg" || ext == ".ico"
+	switch parts[0] {
+	case "icons":
+		return ext == ".png" || ext == ".jpg" || ext == ".jpeg" || ext == ".ico"
+	case "attachments":
+		return ext == ".pdf" || ext == ".png" || ext == ".jpg"
+	default:
+		return false
+	}
 }
 
 func NewAdminHandler(s *service.AdminService, taskMonitor *service.BackgroundTaskMonitor, reauth *service.ReauthService) *AdminHandler {
@@ -196,6 +207,21 @@ func (h *AdminHandler) ChangeUserStatus(c echo.Context) error {
 	return c.JSON(http.StatusOK, echo.Map{"message": "status updated"})
 }
 
+func (h *AdminHandler) UnlockUser(c echo.Context) error {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid user id"})
+	}
+
+	if err := h.Service.WithContext(c.Request().Context()).UnlockUser(uint(userID)); err != nil {
+		if errors.Is(err, service.ErrUserNotFound) {
+			return c.JSON(http.StatusNotFound, echo.Map{"error": err.Error()})
+		}
+		return writeInternalServerError(c, err)
+	}
+	return c.JSON(http.StatusOK, echo.Map{"message": "user unlocked"})
+}
+
 func (h *AdminHandler) DeleteUser(c echo.Context) error {
 	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
@@ -232,7 +258,10 @@ func (h *AdminHandler) UpdateSettings(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid request body"})
 	}
 
-	if err := h.Service.WithContext(c.Request().Context()).UpdateSettings(input); err != nil {
+	adminService := h.Service.WithContext(c.Request().Context())
+	before, _ := adminService.GetSettings()
+
+	if err := adminService.UpdateSettings(input); err != nil {
 		if errors.Is(err, service.ErrInvalidEmailDomainWhitelist) ||
 			errors.Is(err, service.ErrEmailDomainWhitelistTooLong) ||
 			errors.Is(err, service.ErrInvalidIconProxyDomainWhitelist) ||
@@ -248,12 +277,28 @@ func (h *AdminHandler) UpdateSettings(c echo.Context) error {
 			errors.Is(err, service.ErrInvalidBackupTimeOfDay) ||
 			errors.Is(err, service.ErrInvalidBackupRetentionCount) ||
 			errors.Is(err, service.ErrInvalidBackupLocalDir) ||
-			errors.Is(err, service.ErrBackupEncryptionPasswordRequired) {
+			errors.Is(err, service.ErrBackupEncryptionPasswordRequired) ||
+			errors.Is(err, service.ErrInvalidNotificationRetryMaxAttempts) ||
+			errors.Is(err, service.ErrInvalidNotificationRetryBackoff) ||
+			errors.Is(err, service.ErrInvalidAuditRetentionDays) ||
+			errors.Is(err, service.ErrInvalidAuditSinkType) ||
+			errors.Is(err, service.ErrInvalidAuditSyslogNetwork) ||
+			errors.Is(err, service.ErrInvalidAuditSyslogAddress) ||
+			errors.Is(err, service.ErrInvalidAuditFilePath) ||
+			errors.Is(err, service.ErrInvalidOIDCMapping) ||
+			errors.Is(err, service.ErrInvalidLDAPSettings) ||
+			errors.Is(err, service.ErrInvalidProxyAuthSettings) ||
+			errors.Is(err, service.ErrInvalidAccountLockoutSettings) ||
+			errors.Is(err, service.ErrInvalidAttachmentLimit) {
 			return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
 		}
 		return writeInternalServerError(c, err)
 	}
 
+	after, _ := adminService.GetSettings()
+	changedBefore, changedAfter := changedAuditFields(before, after)
+	setAuditSnapshots(c, changedBefore, changedAfter)
+
 	return c.JSON(http.StatusOK, echo.Map{"message": "settings updated"})
 }
 
@@ -294,6 +339,23 @@ func (h *AdminHandler) TestSMTP(c echo.Context) error {
 	return c.JSON(http.StatusOK, echo.Map{"message": "test email sent"})
 }
 
+func (h *AdminHandler) TestLDAP(c echo.Context) error {
+	var input struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if err := c.Bind(&input); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid request body"})
+	}
+
+	result, err := h.Service.WithContext(c.Request().Context()).TestLDAPConnection(input.Username, input.Password)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
+
 func (h *AdminHandler) BackupDB(c echo.Context) error {
 	var input struct {
 		IncludeAssets bool   `json:"include_assets"`
@@ -415,6 +477,9 @@ func (h *AdminHandler) RestoreDB(c echo.Context) error {
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "failed to access database"})
 	}
+	// The live database is closed from here on, so the audit middleware cannot
+	// write; a successful restore records its own event in the restored file.
+	skipRESTAudit(c)
 	if err := sqlDB.Close(); err != nil {
 		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "failed to close database before restore"})
 	}
@@ -428,9 +493,50 @@ func (h *AdminHandler) RestoreDB(c echo.Context) error {
 		}
 	}
 
+	recordRestoreAuditEvent(c, dbPath, map[string]interface{}{
+		"filename":        file.Filename,
+		"size":            file.Size,
+		"encrypted":       password != "",
+		"assets_restored": restorePayload.replaceAssetsDir,
+	})
+
 	return c.JSON(http.StatusOK, echo.Map{"message": "backup restored - please restart server"})
 }
 
+// recordRestoreAuditEvent writes the restore into the restored database so the
+// event survives the file swap. Backups taken before audit logging existed
+// have no audit table; the restore is then only logged.
+func recordRestoreAuditEvent(c echo.Context, dbPath string, restored map[string]interface{}) {
+	req := c.Request()
+	err := pkg.WithSQLiteDatabase(dbPath, func(db *gorm.DB) error {
+		if !db.Migrator().HasTable(&model.AuditEvent{}) {
+			return errors.New("restored database has no audit table")
+		}
+		audit := service.NewAuditService(db)
+		if enabled, err := audit.IsEnabled(); err != nil || !enabled {
+			return err
+		}
+		_, err := audit.Create(service.CreateAuditEventInput{
+			UserID:        getUserID(c),
+			KeyKind:       service.AuditKeyKindSession,
+			ScopeUsed:     service.APIKeyScopeWrite,
+			Transport:     service.AuditTransportREST,
+			ToolName:      req.Method + " " + c.Path(),
+			ResourceType:  service.AuditResourceBackup,
+			Action:        "restore",
+			Status:        service.AuditStatusSuccess,
+			ClientName:    req.UserAgent(),
+			RequestID:     logging.RequestIDFromContext(req.Context()),
+			AfterSnapshot: restored,
+		})
+		return err
+	})
+	if err != nil {
+		logging.FromContext(req.Context()).Warn("failed to record restore audit event",
+			slog.Int("user_id", int(getUserID(c))), slog.Any("error", err))
+	}
+}
+
 func saveUploadedBackupFile(fileHeader *multipart.FileHeader) (string, error) {
 	src, err := fileHeader.Open()
 	if err != nil {
@@ -623,7 +729,7 @@ func extractAssetsFromZip(entries []*zip.File, limits backupRestoreLimits) (bool
 			continue
 		}
 		if entry.FileInfo().IsDir() {
-			if relativePath == "icons" {
+			if relativePath == "icons" || relativePath == "attachments" {
 				continue
 			}
 			return false, "", invalidBackupError("zip backup contains unsupported assets entry")
@@ -650,7 +756,7 @@ func extractAssetsFromZip(entries []*zip.File, limits backupRestoreLimits) (bool
 			return false, "", err
 		}
 
-		sanitized, sourceSize, err := sanitizeRestoreAsset(entry, path.Base(relativePath), remainingSize)
+		sanitized, sourceSize, err := sanitizeRestoreAsset(entry, relativePath, remainingSize)
 		if err != nil {
 			return false, "", err
 		}
@@ -671,7 +777,9 @@ func extractAssetsFromZip(entries []*zip.File, limits backupRestoreLimits) (bool
 	return true, tempAssetsDir, nil
 }
 
-func sanitizeRestoreAsset(entry *zip.File, filename string, maxBytes int64) ([]byte, int64, error) {
+// sanitizeRestoreAsset re-encodes restored icons and checks that restored
+// attachments are the documents their extension claims.
+func sanitizeRestoreAsset(entry *zip.File, relativePath string, maxBytes int64) ([]byte, int64, error) {
 	source, err := entry.Open()
 	if err != nil {
 		if isZipPasswordError(err) {
@@ -682,7 +790,13 @@ func sanitizeRestoreAsset(entry *zip.File, filename string, maxBytes int64) ([]b
 	defer source.Close()
 
 	countingSource := &countingReader{reader: source}
-	sanitized, _, err := service.SanitizeIconFile(countingSource, filename, maxBytes)
+	filename := path.Base(relativePath)
+	var sanitized []byte
+	if strings.HasPrefix(relativePath, "attachments/") {
+		sanitized, _, _, err = service.ValidateAttachmentFile(countingSource, filename, maxBytes)
+	} else {
+		sanitized, _, err = service.SanitizeIconFile(countingSource, filename, maxBytes)
+	}
 	if err != nil {
 		if isZipPasswordError(err) {
 			return nil, 0, errBackupInvalidPassword
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/shiroha/subdux/internal/service"
+)
+
+func (h *AdminHandler) ListOIDCProviders(c echo.Context) error {
+	providers, err := h.Service.WithContext(c.Request().Context()).ListOIDCProviders()
+	if err != nil {
+		return writeInternalServerError(c, err)
+	}
+	return c.JSON(http.StatusOK, providers)
+}
+
+func (h *AdminHandler) CreateOIDCProvider(c echo.Context) error {
+	var input service.OIDCProviderInput
+	if err := c.Bind(&input); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid request body"})
+	}
+
+	provider, err := h.Service.WithContext(c.Request().Context()).CreateOIDCProvider(input)
+	if err != nil {
+		return writeOIDCProviderError(c, err)
+	}
+	return c.JSON(http.StatusCreated, provider)
+}
+
+func (h *AdminHandler) UpdateOIDCProvider(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid oidc provider id"})
+	}
+
+	var input service.OIDCProviderInput
+	if err := c.Bind(&input); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid request body"})
+	}
+
+	provider, err := h.Service.WithContext(c.Request().Context()).UpdateOIDCProvider(uint(id), input)
+	if err != nil {
+		return writeOIDCProviderError(c, err)
+	}
+	return c.JSON(http.StatusOK, provider)
+}
+
+func (h *AdminHandler) DeleteOIDCProvider(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid oidc provider id"})
+	}
+
+	if err := h.Service.WithContext(c.Request().Context()).DeleteOIDCProvider(uint(id)); err != nil {
+		return writeOIDCProviderError(c, err)
+	}
+	return c.JSON(http.StatusOK, echo.Map{"message": "oidc provider deleted"})
+}
+
+func writeOIDCProviderError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, service.ErrOIDCProviderNotFound):
+		return c.JSON(http.StatusNotFound, echo.Map{"error": err.Error()})
+	case errors.Is(err, service.ErrOIDCProviderKeyTaken):
+		return c.JSON(http.StatusConflict, echo.Map{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidOIDCProvider), errors.Is(err, service.ErrInvalidOIDCMapping):
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	default:
+		return writeInternalServerError(c, err)
+	}
+}
@@ -226,6 +226,50 @@ func TestPrepareRestorePayloadFromZipRejectsNonImageAsset(t *testing.T) {
 	assertNoRestoreAssetsTempDirs(t)
 }
 
+func TestPrepareRestorePayloadFromZipRestoresAttachments(t *testing.T) {
+	t.Setenv("DATA_PATH", t.TempDir())
+	pdfData := []byte("%PDF-1.7\n%%EOF\n")
+	zipPath := writeRestoreZip(t, map[string][]byte{
+		"subdux.db":                       sqliteFileHeader,
+		"assets/attachments/1_2_abc.pdf":  pdfData,
+		"assets/attachments/1_2_fake.pdf": []byte("<script>evil()</script>"),
+	})
+
+	_, err := prepareRestorePayloadFromZipWithLimits(zipPath, "", backupRestoreLimits{
+		maxDatabaseExtractedSize: 128,
+		maxAssetsExtractedSize:   256,
+		maxAssetEntries:          4,
+	})
+	if !errors.Is(err, errInvalidBackup) {
+		t.Fatalf("prepareRestorePayloadFromZipWithLimits() error = %v, want invalid backup for fake pdf", err)
+	}
+	assertNoRestoreAssetsTempDirs(t)
+
+	zipPath = writeRestoreZip(t, map[string][]byte{
+		"subdux.db":                      sqliteFileHeader,
+		"assets/attachments/1_2_abc.pdf": pdfData,
+	})
+	payload, err := prepareRestorePayloadFromZipWithLimits(zipPath, "", backupRestoreLimits{
+		maxDatabaseExtractedSize: 128,
+		maxAssetsExtractedSize:   256,
+		maxAssetEntries:          4,
+	})
+	if err != nil {
+		t.Fatalf("prepareRestorePayloadFromZipWithLimits() error = %v, want nil", err)
+	}
+	t.Cleanup(func() {
+		_ = os.Remove(payload.dbFilePath)
+		_ = os.RemoveAll(payload.assetsDirPath)
+	})
+	contents, err := os.ReadFile(filepath.Join(payload.assetsDirPath, "attachments", "1_2_abc.pdf"))
+	if err != nil {
+		t.Fatalf("restored attachment read error = %v, want nil", err)
+	}
+	if !bytes.Equal(contents, pdfData) {
+		t.Fatalf("restored attachment = %q, want original bytes", contents)
+	}
+}
+
 func TestPrepareRestorePayloadFromZipRejectsExecutableAssetPath(t *testing.T) {
 	t.Setenv("DATA_PATH", t.TempDir())
 	zipPath := writeRestoreZip(t, map[string][]byte{
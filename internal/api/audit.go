@@ -22,6 +22,9 @@ func NewAuditHandler(s *service.AuditService) *AuditHandler {
 
 type auditEventResponse struct {
 	EventID             string          `json:"event_id"`
+	Sequence            int64           `json:"sequence"`
+	PrevHash            string          `json:"prev_hash"`
+	Hash                string          `json:"hash"`
 	OccurredAt          time.Time       `json:"occurred_at"`
 	UserID              uint            `json:"user_id"`
 	KeyID               uint            `json:"key_id"`
@@ -60,6 +63,18 @@ func (h *AuditHandler) ListAdminEvents(c echo.Context) error {
 	return c.JSON(http.StatusOK, mapAuditEventResponses(events))
 }
 
+func (h *AuditHandler) VerifyAdminChain(c echo.Context) error {
+	userID, ok := parseOptionalUintQuery(c, "user_id")
+	if !ok {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid user_id"})
+	}
+	report, err := h.Service.WithContext(c.Request().Context()).VerifyChain(userID)
+	if err != nil {
+		return writeInternalServerError(c, err)
+	}
+	return c.JSON(http.StatusOK, report)
+}
+
 func parseAuditEventFilter(c echo.Context, userID *uint) service.AuditEventFilter {
 	limit, _ := strconv.Atoi(strings.TrimSpace(c.QueryParam("limit")))
 	var before *time.Time
@@ -88,6 +103,9 @@ func mapAuditEventResponses(events []model.AuditEvent) []auditEventResponse {
 func mapAuditEventResponse(event model.AuditEvent) auditEventResponse {
 	return auditEventResponse{
 		EventID:             event.EventID,
+		Sequence:            event.Sequence,
+		PrevHash:            event.PrevHash,
+		Hash:                event.Hash,
 		OccurredAt:          event.OccurredAt,
 		UserID:              event.UserID,
 		KeyID:               event.KeyID,
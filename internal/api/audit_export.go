@@ -0,0 +1,169 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/shiroha/subdux/internal/model"
+	"github.com/shiroha/subdux/internal/pkg"
+	"github.com/shiroha/subdux/internal/service"
+)
+
+const (
+	auditExportFormatJSONL = "jsonl"
+	auditExportFormatCSV   = "csv"
+)
+
+var auditExportCSVHeader = []string{
+	"event_id", "sequence", "occurred_at", "user_id", "key_id", "key_kind", "scope_used",
+	"transport", "tool_name", "resource_type", "resource_id", "action", "status", "error",
+	"latency_ms", "client_name", "client_version", "request_id", "request_args_redacted",
+	"before_snapshot", "after_snapshot", "prev_hash", "hash",
+}
+
+func (h *AuditHandler) ExportUserEvents(c echo.Context) error {
+	userID := getUserID(c)
+	return h.exportEvents(c, &userID)
+}
+
+func (h *AuditHandler) ExportAdminEvents(c echo.Context) error {
+	userID, ok := parseOptionalUintQuery(c, "user_id")
+	if !ok {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid user_id"})
+	}
+	return h.exportEvents(c, userID)
+}
+
+func (h *AuditHandler) exportEvents(c echo.Context, userID *uint) error {
+	format := strings.ToLower(strings.TrimSpace(c.QueryParam("format")))
+	if format == "" {
+		format = auditExportFormatJSONL
+	}
+	if format != auditExportFormatJSONL && format != auditExportFormatCSV {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "format must be jsonl or csv"})
+	}
+	from, ok := parseAuditExportBound(c.QueryParam("from"), false)
+	if !ok {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid from"})
+	}
+	to, ok := parseAuditExportBound(c.QueryParam("to"), true)
+	if !ok {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid to"})
+	}
+	if from != nil && to != nil && !from.Before(*to) {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "from must be before to"})
+	}
+
+	res := c.Response()
+	filename := fmt.Sprintf("subdux-audit-%s.%s", pkg.NowUTC().Format("2006-01-02"), format)
+	res.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	if format == auditExportFormatCSV {
+		res.Header().Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+	} else {
+		res.Header().Set(echo.HeaderContentType, "application/x-ndjson")
+	}
+	res.WriteHeader(http.StatusOK)
+
+	filter := service.AuditExportFilter{UserID: userID, From: from, To: to}
+	svc := h.Service.WithContext(c.Request().Context())
+
+	// Headers are already sent, so a failure part-way can only end the stream;
+	// the truncated body is the client's signal.
+	if format == auditExportFormatCSV {
+		writer := csv.NewWriter(res)
+		if err := writer.Write(auditExportCSVHeader); err != nil {
+			return nil
+		}
+		_ = svc.Export(filter, func(event model.AuditEvent) error {
+			if err := writer.Write(auditEventCSVRecord(event)); err != nil {
+				return err
+			}
+			writer.Flush()
+			res.Flush()
+			return writer.Error()
+		})
+		writer.Flush()
+		return nil
+	}
+
+	encoder := json.NewEncoder(res)
+	_ = svc.Export(filter, func(event model.AuditEvent) error {
+		if err := encoder.Encode(mapAuditEventResponse(event)); err != nil {
+			return err
+		}
+		res.Flush()
+		return nil
+	})
+	return nil
+}
+
+// parseAuditExportBound accepts RFC 3339 timestamps or YYYY-MM-DD dates. A
+// date used as the upper bound covers the whole day.
+func parseAuditExportBound(raw string, upper bool) (*time.Time, bool) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, true
+	}
+	if parsed, err := time.Parse(time.RFC3339, raw); err == nil {
+		return &parsed, true
+	}
+	parsed, err := time.Parse("2006-01-02", raw)
+	if err != nil {
+		return nil, false
+	}
+	if upper {
+		parsed = parsed.AddDate(0, 0, 1)
+	}
+	return &parsed, true
+}
+
+func auditEventCSVRecord(event model.AuditEvent) []string {
+	record := []string{
+		event.EventID,
+		strconv.FormatInt(event.Sequence, 10),
+		event.OccurredAt.UTC().Format(time.RFC3339Nano),
+		strconv.FormatUint(uint64(event.UserID), 10),
+		strconv.FormatUint(uint64(event.KeyID), 10),
+		event.KeyKind,
+		event.ScopeUsed,
+		event.Transport,
+		event.ToolName,
+		event.ResourceType,
+		event.ResourceID,
+		event.Action,
+		event.Status,
+		event.Error,
+		strconv.FormatInt(event.LatencyMS, 10),
+		event.ClientName,
+		event.ClientVersion,
+		event.RequestID,
+		event.RequestArgsRedacted,
+		event.BeforeSnapshot,
+		event.AfterSnapshot,
+		event.PrevHash,
+		event.Hash,
+	}
+	for i, value := range record {
+		record[i] = escapeCSVFormula(value)
+	}
+	return record
+}
+
+// escapeCSVFormula stops spreadsheet applications from evaluating
+// attacker-controlled text such as client names as formulas.
+func escapeCSVFormula(value string) string {
+	if value == "" {
+		return value
+	}
+	switch value[0] {
+	case '=', '+', '-', '@', '\t', '\r':
+		return "'" + value
+	}
+	return value
+}
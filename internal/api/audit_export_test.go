@@ -0,0 +1,126 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shiroha/subdux/internal/model"
+	"github.com/shiroha/subdux/internal/pkg"
+	"github.com/shiroha/subdux/internal/service"
+	"gorm.io/gorm"
+)
+
+func createAuditExportTestEvents(t *testing.T, db *gorm.DB, userID uint) {
+	t.Helper()
+
+	audit := service.NewAuditService(db)
+	for _, day := range []int{1, 2, 3} {
+		restore := pkg.SetNowForTest(time.Date(2026, 10, day, 12, 0, 0, 0, time.UTC))
+		_, err := audit.Create(service.CreateAuditEventInput{
+			UserID:       userID,
+			Transport:    service.AuditTransportREST,
+			ResourceType: service.AuditResourceCategory,
+			Action:       "update",
+			ClientName:   "=HYPERLINK(\"http://example.com\")",
+		})
+		restore()
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+}
+
+func TestAuditExportStreamsJSONLWithinDateRange(t *testing.T) {
+	db := newHumanOnlyRouteTestDB(t)
+	user := createHumanOnlyRouteTestUser(t, db)
+	createAuditExportTestEvents(t, db, user.ID)
+	createAuditExportTestEvents(t, db, user.ID+100)
+
+	rec := serveRESTAuditRequest(t, db, &user, http.MethodGet, "/api/audit-events/export?from=2026-10-02&to=2026-10-03", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("export status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/x-ndjson" {
+		t.Fatalf("Content-Type = %q, want application/x-ndjson", got)
+	}
+
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("export lines = %d, want 2: %s", len(lines), rec.Body.String())
+	}
+	for i, line := range lines {
+		var event auditEventResponse
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("failed to decode line %d: %v", i, err)
+		}
+		if event.UserID != user.ID || event.Sequence != int64(i+2) || event.Hash == "" {
+			t.Fatalf("line %d = %+v, want user %d sequence %d", i, event, user.ID, i+2)
+		}
+	}
+
+	if rec := serveRESTAuditRequest(t, db, &user, http.MethodGet, "/api/audit-events/export?from=2026-10-03&to=2026-10-01", ""); rec.Code != http.StatusBadRequest {
+		t.Fatalf("inverted range status = %d, want 400", rec.Code)
+	}
+	if rec := serveRESTAuditRequest(t, db, &user, http.MethodGet, "/api/audit-events/export?format=xml", ""); rec.Code != http.StatusBadRequest {
+		t.Fatalf("unknown format status = %d, want 400", rec.Code)
+	}
+}
+
+func TestAuditExportCSVEscapesFormulas(t *testing.T) {
+	db := newHumanOnlyRouteTestDB(t)
+	user := createHumanOnlyRouteTestUser(t, db)
+	createAuditExportTestEvents(t, db, user.ID)
+
+	rec := serveRESTAuditRequest(t, db, &user, http.MethodGet, "/api/audit-events/export?format=csv", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("export status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+
+	records, err := csv.NewReader(strings.NewReader(rec.Body.String())).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to parse CSV: %v", err)
+	}
+	if len(records) != 4 || records[0][0] != "event_id" {
+		t.Fatalf("CSV records = %d, want header plus 3 rows", len(records))
+	}
+	for _, record := range records[1:] {
+		if record[15] != `'=HYPERLINK("http://example.com")` {
+			t.Fatalf("client_name cell = %q, want formula escaped", record[15])
+		}
+	}
+}
+
+func TestAdminAuditVerifyReportsChainState(t *testing.T) {
+	db := newHumanOnlyRouteTestDB(t)
+	user := createHumanOnlyRouteTestUser(t, db)
+	admin := model.User{Username: "audit-verify-admin", Email: "audit-verify-admin@example.com", Password: "hashed-password", Role: "admin", Status: "active"}
+	if err := db.Create(&admin).Error; err != nil {
+		t.Fatalf("failed to create admin: %v", err)
+	}
+	createAuditExportTestEvents(t, db, user.ID)
+
+	if rec := serveRESTAuditRequest(t, db, &user, http.MethodGet, "/api/admin/audit-events/verify", ""); rec.Code != http.StatusForbidden {
+		t.Fatalf("non-admin verify status = %d, want 403", rec.Code)
+	}
+
+	if err := db.Model(&model.AuditEvent{}).Where("user_id = ? AND sequence = 2", user.ID).Update("action", "delete").Error; err != nil {
+		t.Fatalf("failed to tamper with event: %v", err)
+	}
+
+	rec := serveRESTAuditRequest(t, db, &admin, http.MethodGet, "/api/admin/audit-events/verify?user_id="+strconv.Itoa(int(user.ID)), "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("verify status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	var report service.AuditChainReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatalf("failed to decode report: %v", err)
+	}
+	if report.Verified || len(report.Issues) != 1 || report.Issues[0].Kind != service.AuditChainIssueHashMismatch {
+		t.Fatalf("report = %+v, want one hash mismatch", report)
+	}
+}
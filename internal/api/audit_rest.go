@@ -0,0 +1,449 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/shiroha/subdux/internal/pkg"
+	"github.com/shiroha/subdux/internal/pkg/logging"
+	"github.com/shiroha/subdux/internal/service"
+)
+
+const (
+	restAuditSnapshotsContextKey = "rest_audit_snapshots"
+	restAuditSkipContextKey      = "rest_audit_skip"
+
+	// maxRESTAuditResponseBytes bounds how much of a response body is kept for
+	// the after snapshot; anything larger is stored as truncated.
+	maxRESTAuditResponseBytes = 16 << 10
+)
+
+// restAuditRoute maps a route prefix to the audit resource it writes. Routes
+// with an empty resource are not audited. Credential routes only keep the
+// request fields listed in args and never store response bodies, since both
+// carry passwords, codes and tokens. Account routes snapshot the caller's user
+// row. omitArgs drops request fields whose values hold secrets key-based
+// redaction cannot recognise.
+type restAuditRoute struct {
+	prefix      string
+	resource    string
+	credentials bool
+	account     bool
+	args        []string
+	omitArgs    []string
+}
+
+// restAuditRoutes is matched in order, so narrower prefixes come first.
+var restAuditRoutes = []restAuditRoute{
+	{prefix: "/api/auth/register/send-code"},
+	{prefix: "/api/auth/email/change/send-code"},
+	{prefix: "/api/auth/password/forgot"},
+	{prefix: "/api/auth/passkeys/login/start"},
+	{prefix: "/api/auth/passkeys/register/start"},
+	{prefix: "/api/auth/login", resource: service.AuditResourceSession, credentials: true, args: []string{"identifier"}},
+	{prefix: "/api/auth/proxy/login", resource: service.AuditResourceSession, credentials: true},
+	{prefix: "/api/auth/totp/verify-login", resource: service.AuditResourceSession, credentials: true},
+	{prefix: "/api/auth/passkeys/login/finish", resource: service.AuditResourceSession, credentials: true},
+	{prefix: "/api/auth/register", resource: service.AuditResourceUser, credentials: true, account: true, args: []string{"username", "email"}},
+	{prefix: "/api/auth/password", resource: service.AuditResourcePassword, credentials: true, account: true},
+	{prefix: "/api/auth/email", resource: service.AuditResourceEmail, credentials: true, account: true},
+	{prefix: "/api/auth/totp", resource: service.AuditResourceTOTP, credentials: true, account: true},
+	{prefix: "/api/auth/passkeys", resource: service.AuditResourcePasskey, credentials: true},
+	{prefix: "/api/auth/sessions", resource: service.AuditResourceSession},
+	{prefix: "/api/auth/oidc/connections", resource: service.AuditResourceOIDCConnection},
+	{prefix: "/api/admin/users", resource: service.AuditResourceUser},
+	{prefix: "/api/admin/oidc/providers", resource: service.AuditResourceOIDCProvider, omitArgs: []string{"client_secret"}},
+	{prefix: "/api/admin/scim", resource: service.AuditResourceSCIMToken, credentials: true},
+	{prefix: "/api/admin/settings", resource: service.AuditResourceSettings, omitArgs: []string{"system_proxy_url"}},
+	{prefix: "/api/admin/backup", resource: service.AuditResourceBackup},
+	{prefix: "/api/admin/restore", resource: service.AuditResourceBackup},
+	{prefix: "/api/admin/notifications/outbox", resource: service.AuditResourceNotificationOutbox},
+	{prefix: "/api/admin/exchange-rates", resource: service.AuditResourceExchangeRate},
+	{prefix: "/api/subscriptions", resource: service.AuditResourceSubscription, omitArgs: []string{"custom_fields"}},
+	{prefix: "/api/actions", resource: service.AuditResourceSubscription},
+	{prefix: "/api/currencies", resource: service.AuditResourceCurrency},
+	{prefix: "/api/categories", resource: service.AuditResourceCategory},
+	{prefix: "/api/custom-fields", resource: service.AuditResourceCustomField},
+	{prefix: "/api/payment-methods", resource: service.AuditResourcePaymentMethod},
+	{prefix: "/api/notifications/channels", resource: service.AuditResourceNotificationChannel, omitArgs: []string{"config"}},
+	{prefix: "/api/notifications/policy", resource: service.AuditResourceNotificationPolicy},
+	{prefix: "/api/notifications/outbox", resource: service.AuditResourceNotificationOutbox},
+	{prefix: "/api/notifications/templates", resource: service.AuditResourceTemplate},
+	{prefix: "/api/preferences", resource: service.AuditResourcePreference},
+	{prefix: "/api/api-keys", resource: service.AuditResourceAPIKey},
+	{prefix: "/api/calendar/tokens", resource: service.AuditResourceCalendarToken},
+	{prefix: "/api/import", resource: service.AuditResourceImport},
+}
+
+// restAuditActions overrides the action derived from a route where the path
+// alone does not say what happened.
+var restAuditActions = map[string]string{
+	"POST /api/auth/login":                      "login",
+	"POST /api/auth/proxy/login":                "login_proxy",
+	"POST /api/auth/totp/verify-login":          "login_totp",
+	"POST /api/auth/passkeys/login/finish":      "login_passkey",
+	"POST /api/auth/register":                   "register",
+	"PUT /api/auth/password":                    "change",
+	"POST /api/auth/password/reset":             "reset",
+	"POST /api/auth/email/change/confirm":       "change",
+	"POST /api/auth/totp/confirm":               "enable",
+	"POST /api/auth/totp/disable":               "disable",
+	"POST /api/auth/passkeys/register/finish":   "create",
+	"PUT /api/admin/users/:id/role":             "change_role",
+	"PUT /api/admin/users/:id/status":           "change_status",
+	"POST /api/admin/users/:id/sessions/revoke": "revoke_sessions",
+	"POST /api/auth/sessions/revoke-others":     "revoke_others",
+	"DELETE /api/auth/sessions/:id":             "revoke",
+	"POST /api/admin/backup":                    "download",
+	"POST /api/admin/restore":                   "restore",
+	"DELETE /api/admin/notifications/outbox":    "purge",
+	"DELETE /api/notifications/outbox":          "purge",
+	"PUT /api/preferences/currency":             "update",
+	"PUT /api/preferences/locale":               "update",
+}
+
+type restAuditSnapshots struct {
+	before interface{}
+	after  interface{}
+}
+
+// setAuditSnapshots supplies explicit before/after snapshots for the current
+// request, replacing the ones the audit middleware would load itself.
+func setAuditSnapshots(c echo.Context, before, after interface{}) {
+	c.Set(restAuditSnapshotsContextKey, &restAuditSnapshots{
+		before: service.RedactAuditSnapshot(before),
+		after:  service.RedactAuditSnapshot(after),
+	})
+}
+
+// changedAuditFields reduces two snapshots of the same object to the top-level
+// fields that differ, so large objects such as system settings stay readable.
+func changedAuditFields(before, after interface{}) (interface{}, interface{}) {
+	beforeMap, beforeOK := service.RedactAuditSnapshot(before).(map[string]interface{})
+	afterMap, afterOK := service.RedactAuditSnapshot(after).(map[string]interface{})
+	if !beforeOK || !afterOK {
+		return before, after
+	}
+	changedBefore := make(map[string]interface{})
+	changedAfter := make(map[string]interface{})
+	for key, value := range afterMap {
+		if previous, ok := beforeMap[key]; !ok || !reflect.DeepEqual(previous, value) {
+			changedBefore[key] = previous
+			changedAfter[key] = value
+		}
+	}
+	return changedBefore, changedAfter
+}
+
+// skipRESTAudit tells the audit middleware not to record the current request,
+// for handlers that record their own event.
+func skipRESTAudit(c echo.Context) {
+	c.Set(restAuditSkipContextKey, true)
+}
+
+// RESTAuditMiddleware records an audit event for every REST write on an
+// audited route. It must run after authentication so the caller is known.
+func RESTAuditMiddleware(audit *service.AuditService) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			if isReadOnlyMethod(req.Method) {
+				return next(c)
+			}
+			route, ok := matchRESTAuditRoute(c.Path())
+			if !ok {
+				return next(c)
+			}
+			auditSvc := audit.WithContext(req.Context())
+			if enabled, err := auditSvc.IsEnabled(); err != nil || !enabled {
+				return next(c)
+			}
+
+			args := readRESTAuditArgs(c, route)
+			actor := restAuditActorFromContext(c)
+			resourceID := parseRESTAuditResourceID(c.Param("id"))
+			scope := restAuditScope(c.Path(), actor)
+
+			snapshot := func() interface{} {
+				if route.account {
+					return auditSvc.ResourceSnapshot(service.AuditResourceUser, scope, actor.userID)
+				}
+				return auditSvc.ResourceSnapshot(route.resource, scope, resourceID)
+			}
+
+			var before interface{}
+			if actor.authenticated {
+				before = snapshot()
+			}
+
+			recorder := &auditResponseRecorder{ResponseWriter: c.Response().Writer}
+			c.Response().Writer = recorder
+			start := time.Now()
+			handlerErr := next(c)
+			latency := time.Since(start)
+			c.Response().Writer = recorder.ResponseWriter
+
+			if skip, _ := c.Get(restAuditSkipContextKey).(bool); skip {
+				return handlerErr
+			}
+
+			status := c.Response().Status
+			if handlerErr != nil {
+				if httpErr, ok := handlerErr.(*echo.HTTPError); ok {
+					status = httpErr.Code
+				} else if !c.Response().Committed {
+					status = http.StatusInternalServerError
+				}
+			}
+			body := recorder.jsonBody(c.Response().Header().Get(echo.HeaderContentType))
+
+			if !actor.authenticated {
+				actor.userID = restAuditUserIDFromBody(body)
+			}
+			if resourceID == 0 {
+				resourceID = restAuditResourceIDFromBody(route, body, actor.userID)
+			}
+
+			var after interface{}
+			if status < http.StatusBadRequest && req.Method != http.MethodDelete {
+				after = snapshot()
+				if after == nil && !route.credentials {
+					after = service.RedactAuditSnapshot(body)
+				}
+			}
+			if explicit, ok := c.Get(restAuditSnapshotsContextKey).(*restAuditSnapshots); ok {
+				before, after = explicit.before, explicit.after
+			}
+
+			input := service.CreateAuditEventInput{
+				UserID:              actor.userID,
+				KeyID:               actor.keyID,
+				KeyKind:             actor.keyKind,
+				ScopeUsed:           requiredAPIKeyScope(c),
+				Transport:           service.AuditTransportREST,
+				ToolName:            req.Method + " " + c.Path(),
+				ResourceType:        route.resource,
+				Action:              restAuditAction(req.Method, c.Path(), route),
+				Status:              service.AuditStatusSuccess,
+				LatencyMS:           latency.Milliseconds(),
+				ClientName:          req.UserAgent(),
+				RequestID:           logging.RequestIDFromContext(req.Context()),
+				RequestArgsRedacted: args,
+				BeforeSnapshot:      before,
+				AfterSnapshot:       after,
+			}
+			if resourceID != 0 {
+				input.ResourceID = strconv.FormatUint(uint64(resourceID), 10)
+			}
+			if status >= http.StatusBadRequest {
+				input.Status = service.AuditStatusError
+				input.Error = restAuditErrorMessage(status, body, handlerErr)
+			}
+
+			if _, err := auditSvc.Create(input); err != nil {
+				logging.FromContext(req.Context()).Error("failed to record audit event",
+					slog.String("route", input.ToolName), slog.Any("error", err))
+			}
+			return handlerErr
+		}
+	}
+}
+
+func matchRESTAuditRoute(path string) (restAuditRoute, bool) {
+	for _, route := range restAuditRoutes {
+		if path == route.prefix || strings.HasPrefix(path, route.prefix+"/") {
+			return route, route.resource != ""
+		}
+	}
+	return restAuditRoute{}, false
+}
+
+// restAuditAction names the write: an explicit override, else the literal path
+// segments after the resource prefix (such as "mark-renewed" or "retry"), else
+// the CRUD verb implied by the method.
+func restAuditAction(method, path string, route restAuditRoute) string {
+	if action, ok := restAuditActions[method+" "+path]; ok {
+		return action
+	}
+	var parts []string
+	for _, segment := range strings.Split(strings.TrimPrefix(path, route.prefix), "/") {
+		if segment == "" || strings.HasPrefix(segment, ":") {
+			continue
+		}
+		parts = append(parts, strings.ReplaceAll(segment, "-", "_"))
+	}
+	if len(parts) > 0 {
+		return strings.Join(parts, "_")
+	}
+	switch method {
+	case http.MethodPost:
+		return "create"
+	case http.MethodDelete:
+		return "delete"
+	default:
+		return "update"
+	}
+}
+
+type restAuditActor struct {
+	authenticated bool
+	userID        uint
+	keyID         uint
+	keyKind       string
+}
+
+func restAuditActorFromContext(c echo.Context) restAuditActor {
+	token, ok := c.Get("user").(*jwt.Token)
+	if !ok || token == nil {
+		return restAuditActor{keyKind: service.AuditKeyKindAnonymous}
+	}
+	claims, ok := token.Claims.(*pkg.JWTClaims)
+	if !ok {
+		return restAuditActor{keyKind: service.AuditKeyKindAnonymous}
+	}
+	actor := restAuditActor{authenticated: true, userID: claims.UserID, keyKind: service.AuditKeyKindSession}
+	if getAuthType(c) == pkg.AuthTypeAPIKey {
+		actor.keyID = claims.KeyID
+		actor.keyKind = getAPIKeyKind(c)
+	}
+	return actor
+}
+
+// restAuditScope limits snapshot lookups to the caller's rows, except on admin
+// routes which act on other users' data.
+func restAuditScope(path string, actor restAuditActor) *uint {
+	if strings.HasPrefix(path, "/api/admin/") || !actor.authenticated {
+		return nil
+	}
+	userID := actor.userID
+	return &userID
+}
+
+func readRESTAuditArgs(c echo.Context, route restAuditRoute) interface{} {
+	if route.credentials && len(route.args) == 0 {
+		return nil
+	}
+	contentType := c.Request().Header.Get(echo.HeaderContentType)
+	if !strings.HasPrefix(contentType, echo.MIMEApplicationJSON) {
+		return nil
+	}
+	body, err := readRequestBodyAndRestore(c, maxRESTAuditResponseBytes)
+	if err != nil || len(body) == 0 {
+		return nil
+	}
+	var args map[string]interface{}
+	if err := json.Unmarshal(body, &args); err != nil {
+		return nil
+	}
+	if route.credentials {
+		kept := make(map[string]interface{}, len(route.args))
+		for _, field := range route.args {
+			if value, ok := args[field]; ok {
+				kept[field] = value
+			}
+		}
+		return kept
+	}
+	for _, field := range route.omitArgs {
+		if _, ok := args[field]; ok {
+			args[field] = "[redacted]"
+		}
+	}
+	return args
+}
+
+func parseRESTAuditResourceID(raw string) uint {
+	id, err := strconv.ParseUint(strings.TrimSpace(raw), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return uint(id)
+}
+
+// restAuditUserIDFromBody identifies the account behind an unauthenticated
+// request, such as a successful login, from the user object in its response.
+func restAuditUserIDFromBody(body interface{}) uint {
+	object, ok := body.(map[string]interface{})
+	if !ok {
+		return 0
+	}
+	user, ok := object["user"].(map[string]interface{})
+	if !ok {
+		return 0
+	}
+	id, _ := user["id"].(float64)
+	return uint(id)
+}
+
+func restAuditResourceIDFromBody(route restAuditRoute, body interface{}, userID uint) uint {
+	if route.account || route.resource == service.AuditResourceSession {
+		return userID
+	}
+	object, ok := body.(map[string]interface{})
+	if !ok {
+		return 0
+	}
+	id, _ := object["id"].(float64)
+	return uint(id)
+}
+
+func restAuditErrorMessage(status int, body interface{}, handlerErr error) string {
+	if object, ok := body.(map[string]interface{}); ok {
+		if message, ok := object["error"].(string); ok && message != "" {
+			return message
+		}
+	}
+	if httpErr, ok := handlerErr.(*echo.HTTPError); ok {
+		if message, ok := httpErr.Message.(string); ok && message != "" {
+			return message
+		}
+	}
+	if text := http.StatusText(status); text != "" {
+		return text
+	}
+	return "request failed"
+}
+
+// auditResponseRecorder keeps the first bytes of a response so the audit
+// middleware can read the written resource and any error message.
+type auditResponseRecorder struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *auditResponseRecorder) Write(p []byte) (int, error) {
+	if remaining := maxRESTAuditResponseBytes + 1 - r.body.Len(); remaining > 0 {
+		if len(p) > remaining {
+			r.body.Write(p[:remaining])
+		} else {
+			r.body.Write(p)
+		}
+	}
+	return r.ResponseWriter.Write(p)
+}
+
+func (r *auditResponseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+func (r *auditResponseRecorder) jsonBody(contentType string) interface{} {
+	if !strings.HasPrefix(contentType, echo.MIMEApplicationJSON) || r.body.Len() == 0 {
+		return nil
+	}
+	if r.body.Len() > maxRESTAuditResponseBytes {
+		return map[string]interface{}{"truncated": true}
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(r.body.Bytes(), &decoded); err != nil {
+		return nil
+	}
+	return decoded
+}
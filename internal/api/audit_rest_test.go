@@ -0,0 +1,199 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/shiroha/subdux/internal/model"
+	"github.com/shiroha/subdux/internal/pkg"
+	"github.com/shiroha/subdux/internal/service"
+	"gorm.io/gorm"
+)
+
+func serveRESTAuditRequest(t *testing.T, db *gorm.DB, user *model.User, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	e := newHumanOnlyRouteTestServer(t, db)
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if user != nil {
+		token, err := pkg.GenerateAccessToken(user.ID, user.Username, user.Email, user.Role)
+		if err != nil {
+			t.Fatalf("GenerateAccessToken() error = %v", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func listRESTAuditEvents(t *testing.T, db *gorm.DB) []model.AuditEvent {
+	t.Helper()
+
+	var events []model.AuditEvent
+	if err := db.Where("transport = ?", service.AuditTransportREST).Order("occurred_at ASC").Find(&events).Error; err != nil {
+		t.Fatalf("failed to list audit events: %v", err)
+	}
+	return events
+}
+
+func decodeAuditSnapshot(t *testing.T, raw string) map[string]interface{} {
+	t.Helper()
+
+	var snapshot map[string]interface{}
+	if err := json.Unmarshal([]byte(raw), &snapshot); err != nil {
+		t.Fatalf("failed to decode snapshot %q: %v", raw, err)
+	}
+	return snapshot
+}
+
+func TestRESTAuditRecordsUpdateWithBeforeAndAfterSnapshots(t *testing.T) {
+	db := newHumanOnlyRouteTestDB(t)
+	user := createHumanOnlyRouteTestUser(t, db)
+	category := model.Category{UserID: user.ID, Name: "Streaming"}
+	if err := db.Create(&category).Error; err != nil {
+		t.Fatalf("failed to create category: %v", err)
+	}
+
+	rec := serveRESTAuditRequest(t, db, &user, http.MethodPut, "/api/categories/"+strconv.Itoa(int(category.ID)), `{"name":"Video"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PUT category status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+
+	events := listRESTAuditEvents(t, db)
+	if len(events) != 1 {
+		t.Fatalf("audit events = %d, want 1", len(events))
+	}
+	event := events[0]
+	if event.UserID != user.ID || event.KeyKind != service.AuditKeyKindSession || event.ResourceType != service.AuditResourceCategory {
+		t.Fatalf("event = %+v, want session category event for user %d", event, user.ID)
+	}
+	if event.Action != "update" || event.Status != service.AuditStatusSuccess || event.ResourceID != strconv.Itoa(int(category.ID)) {
+		t.Fatalf("event action/status/resource = %q/%q/%q", event.Action, event.Status, event.ResourceID)
+	}
+	if event.ToolName != "PUT /api/categories/:id" {
+		t.Fatalf("event tool name = %q", event.ToolName)
+	}
+	if got := decodeAuditSnapshot(t, event.BeforeSnapshot)["name"]; got != "Streaming" {
+		t.Fatalf("before name = %v, want Streaming", got)
+	}
+	if got := decodeAuditSnapshot(t, event.AfterSnapshot)["name"]; got != "Video" {
+		t.Fatalf("after name = %v, want Video", got)
+	}
+}
+
+func TestRESTAuditSkipsReadsAndHonoursDisabledSetting(t *testing.T) {
+	db := newHumanOnlyRouteTestDB(t)
+	user := createHumanOnlyRouteTestUser(t, db)
+
+	if rec := serveRESTAuditRequest(t, db, &user, http.MethodGet, "/api/categories", ""); rec.Code != http.StatusOK {
+		t.Fatalf("GET categories status = %d", rec.Code)
+	}
+	if events := listRESTAuditEvents(t, db); len(events) != 0 {
+		t.Fatalf("audit events after read = %d, want 0", len(events))
+	}
+
+	if err := db.Model(&model.SystemSetting{}).Where("key = ?", "audit_enabled").Update("value", "false").Error; err != nil {
+		t.Fatalf("failed to disable audit: %v", err)
+	}
+	if rec := serveRESTAuditRequest(t, db, &user, http.MethodPost, "/api/categories", `{"name":"Music"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("POST category status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	if events := listRESTAuditEvents(t, db); len(events) != 0 {
+		t.Fatalf("audit events with audit disabled = %d, want 0", len(events))
+	}
+}
+
+func TestRESTAuditRecordsFailedLoginWithoutPassword(t *testing.T) {
+	db := newHumanOnlyRouteTestDB(t)
+	createHumanOnlyRouteTestUser(t, db)
+
+	rec := serveRESTAuditRequest(t, db, nil, http.MethodPost, "/api/auth/login", `{"identifier":"human-route-user","password":"wrong-password"}`)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("login status = %d, want 401", rec.Code)
+	}
+
+	events := listRESTAuditEvents(t, db)
+	if len(events) != 1 {
+		t.Fatalf("audit events = %d, want 1", len(events))
+	}
+	event := events[0]
+	if event.ResourceType != service.AuditResourceSession || event.Action != "login" || event.Status != service.AuditStatusError {
+		t.Fatalf("event = %+v, want failed session login", event)
+	}
+	if event.KeyKind != service.AuditKeyKindAnonymous || event.UserID != 0 {
+		t.Fatalf("event key kind/user = %q/%d, want anonymous/0", event.KeyKind, event.UserID)
+	}
+	if strings.Contains(event.RequestArgsRedacted, "wrong-password") || !strings.Contains(event.RequestArgsRedacted, "human-route-user") {
+		t.Fatalf("request args = %s, want identifier without password", event.RequestArgsRedacted)
+	}
+	if event.Error == "" {
+		t.Fatal("event error is empty, want login failure message")
+	}
+}
+
+func TestRESTAuditRecordsAdminRoleChange(t *testing.T) {
+	db := newHumanOnlyRouteTestDB(t)
+	target := createHumanOnlyRouteTestUser(t, db)
+	admin := model.User{Username: "audit-admin", Email: "audit-admin@example.com", Password: "hashed-password", Role: "admin", Status: "active"}
+	if err := db.Create(&admin).Error; err != nil {
+		t.Fatalf("failed to create admin: %v", err)
+	}
+
+	path := "/api/admin/users/" + strconv.Itoa(int(target.ID)) + "/role"
+	if rec := serveRESTAuditRequest(t, db, &admin, http.MethodPut, path, `{"role":"admin"}`); rec.Code != http.StatusOK {
+		t.Fatalf("change role status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+
+	events := listRESTAuditEvents(t, db)
+	if len(events) != 1 {
+		t.Fatalf("audit events = %d, want 1", len(events))
+	}
+	event := events[0]
+	if event.UserID != admin.ID || event.ResourceType != service.AuditResourceUser || event.Action != "change_role" {
+		t.Fatalf("event = %+v, want admin change_role on user", event)
+	}
+	if got := decodeAuditSnapshot(t, event.BeforeSnapshot)["role"]; got != "user" {
+		t.Fatalf("before role = %v, want user", got)
+	}
+	if got := decodeAuditSnapshot(t, event.AfterSnapshot)["role"]; got != "admin" {
+		t.Fatalf("after role = %v, want admin", got)
+	}
+}
+
+func TestRESTAuditAction(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		want   string
+	}{
+		{http.MethodPost, "/api/subscriptions", "create"},
+		{http.MethodPut, "/api/subscriptions/:id", "update"},
+		{http.MethodDelete, "/api/subscriptions/:id", "delete"},
+		{http.MethodPost, "/api/subscriptions/:id/mark-renewed", "mark_renewed"},
+		{http.MethodPost, "/api/notifications/outbox/:id/retry", "retry"},
+		{http.MethodDelete, "/api/notifications/outbox", "purge"},
+		{http.MethodPost, "/api/auth/totp/disable", "disable"},
+	}
+
+	for _, tt := range tests {
+		route, ok := matchRESTAuditRoute(tt.path)
+		if !ok {
+			t.Fatalf("matchRESTAuditRoute(%q) found no route", tt.path)
+		}
+		if got := restAuditAction(tt.method, tt.path, route); got != tt.want {
+			t.Fatalf("restAuditAction(%s %s) = %q, want %q", tt.method, tt.path, got, tt.want)
+		}
+	}
+
+	if _, ok := matchRESTAuditRoute("/api/auth/passkeys/login/start"); ok {
+		t.Fatal("passkey login start should not be audited")
+	}
+}
@@ -45,8 +45,19 @@ func (h *AuthHandler) GetOIDCConfig(c echo.Context) error {
 	return c.JSON(http.StatusOK, h.Service.WithContext(c.Request().Context()).GetOIDCPublicConfig())
 }
 
+// oidcStartInput selects the provider to start with. The body is optional;
+// without it the provider configured in system settings is used.
+type oidcStartInput struct {
+	Provider string `json:"provider"`
+}
+
 func (h *AuthHandler) BeginOIDCLogin(c echo.Context) error {
-	result, err := h.Service.WithContext(c.Request().Context()).BeginOIDCLogin()
+	var input oidcStartInput
+	if err := c.Bind(&input); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid request body"})
+	}
+
+	result, err := h.Service.WithContext(c.Request().Context()).BeginOIDCLogin(input.Provider)
 	if err != nil {
 		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
 	}
@@ -55,8 +66,13 @@ func (h *AuthHandler) BeginOIDCLogin(c echo.Context) error {
 }
 
 func (h *AuthHandler) BeginOIDCConnect(c echo.Context) error {
+	var input oidcStartInput
+	if err := c.Bind(&input); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid request body"})
+	}
+
 	userID := getUserID(c)
-	result, err := h.Service.WithContext(c.Request().Context()).BeginOIDCConnect(userID)
+	result, err := h.Service.WithContext(c.Request().Context()).BeginOIDCConnect(userID, input.Provider)
 	if err != nil {
 		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
 	}
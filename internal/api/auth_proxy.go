@@ -0,0 +1,30 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/shiroha/subdux/internal/service"
+)
+
+// ProxyLogin exchanges the identity headers set by a trusted forward-auth
+// proxy for a regular session, so users signed in at the proxy skip the
+// Subdux login form.
+func (h *AuthHandler) ProxyLogin(c echo.Context) error {
+	req := c.Request()
+	resp, err := h.Service.WithContext(req.Context()).LoginWithProxyHeaders(req.RemoteAddr, req.Header)
+	if err != nil {
+		clearRefreshTokenCookie(c)
+		switch {
+		case errors.Is(err, service.ErrProxyAuthDisabled):
+			return c.JSON(http.StatusNotFound, echo.Map{"error": err.Error()})
+		case errors.Is(err, service.ErrProxyAuthUntrustedSource):
+			return c.JSON(http.StatusForbidden, echo.Map{"error": err.Error()})
+		default:
+			return c.JSON(http.StatusUnauthorized, echo.Map{"error": err.Error()})
+		}
+	}
+
+	return writeLoginSuccess(c, http.StatusOK, resp)
+}
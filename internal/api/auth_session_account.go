@@ -85,6 +85,9 @@ func (h *AuthHandler) Login(c echo.Context) error {
 	resp, err := h.Service.WithContext(c.Request().Context()).Login(input)
 	if err != nil {
 		clearRefreshTokenCookie(c)
+		if isAccountLockoutError(err) {
+			return c.JSON(http.StatusTooManyRequests, echo.Map{"error": err.Error()})
+		}
 		return c.JSON(http.StatusUnauthorized, echo.Map{"error": err.Error()})
 	}
 
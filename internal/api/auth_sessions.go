@@ -0,0 +1,86 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/shiroha/subdux/internal/pkg"
+	"github.com/shiroha/subdux/internal/service"
+)
+
+// sessionClientMiddleware records the caller's user agent and address on the
+// request context so sessions issued while handling it can be annotated.
+func sessionClientMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := c.Request()
+		ctx := service.WithSessionClient(req.Context(), service.SessionClient{
+			UserAgent: req.UserAgent(),
+			IPAddress: c.RealIP(),
+		})
+		c.SetRequest(req.WithContext(ctx))
+		return next(c)
+	}
+}
+
+// getSessionID returns the session the caller's access token was issued for,
+// or "" for tokens that predate session tracking.
+func getSessionID(c echo.Context) string {
+	token, ok := c.Get("user").(*jwt.Token)
+	if !ok || token == nil {
+		return ""
+	}
+	claims, ok := token.Claims.(*pkg.JWTClaims)
+	if !ok || claims == nil {
+		return ""
+	}
+	return claims.SessionID
+}
+
+func (h *AuthHandler) ListSessions(c echo.Context) error {
+	sessions, err := h.Service.WithContext(c.Request().Context()).ListSessions(getUserID(c), getSessionID(c))
+	if err != nil {
+		return writeInternalServerError(c, err)
+	}
+	return c.JSON(http.StatusOK, sessions)
+}
+
+func (h *AuthHandler) RevokeSession(c echo.Context) error {
+	if err := h.Service.WithContext(c.Request().Context()).RevokeSession(getUserID(c), c.Param("id")); err != nil {
+		if errors.Is(err, service.ErrSessionNotFound) {
+			return c.JSON(http.StatusNotFound, echo.Map{"error": err.Error()})
+		}
+		return writeInternalServerError(c, err)
+	}
+	return c.JSON(http.StatusOK, echo.Map{"message": "session revoked"})
+}
+
+func (h *AuthHandler) RevokeOtherSessions(c echo.Context) error {
+	sessionID := getSessionID(c)
+	if sessionID == "" {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "current session could not be identified, sign in again first"})
+	}
+
+	revoked, err := h.Service.WithContext(c.Request().Context()).RevokeOtherSessions(getUserID(c), sessionID)
+	if err != nil {
+		return writeInternalServerError(c, err)
+	}
+	return c.JSON(http.StatusOK, echo.Map{"message": "other sessions revoked", "revoked": revoked})
+}
+
+func (h *AdminHandler) RevokeUserSessions(c echo.Context) error {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid user id"})
+	}
+
+	if err := h.Service.WithContext(c.Request().Context()).RevokeUserSessions(uint(userID)); err != nil {
+		if errors.Is(err, service.ErrUserNotFound) {
+			return c.JSON(http.StatusNotFound, echo.Map{"error": err.Error()})
+		}
+		return writeInternalServerError(c, err)
+	}
+	return c.JSON(http.StatusOK, echo.Map{"message": "sessions revoked"})
+}
@@ -57,6 +57,12 @@ func (h *AuthHandler) DisableTOTP(c echo.Context) error {
 	return c.JSON(http.StatusOK, echo.Map{"message": "2FA disabled successfully"})
 }
 
+// isAccountLockoutError reports whether a sign-in was refused because the
+// account is locked or throttled after earlier failures.
+func isAccountLockoutError(err error) bool {
+	return errors.Is(err, service.ErrAccountLocked) || errors.Is(err, service.ErrLoginThrottled)
+}
+
 type verifyTOTPLoginInput struct {
 	TotpToken string `json:"totp_token"`
 	Code      string `json:"code"`
@@ -79,8 +85,11 @@ func (h *AuthHandler) VerifyTOTPLogin(c echo.Context) error {
 
 	ctx := c.Request().Context()
 	totpSvc := h.TOTPService.WithContext(ctx)
-	if !totpSvc.VerifyLogin(userID, input.Code) && !totpSvc.VerifyBackupCode(userID, input.Code) {
+	if err := totpSvc.VerifyLoginCode(userID, input.Code); err != nil {
 		clearRefreshTokenCookie(c)
+		if isAccountLockoutError(err) {
+			return c.JSON(http.StatusTooManyRequests, echo.Map{"error": err.Error()})
+		}
 		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "Invalid code"})
 	}
 
@@ -0,0 +1,562 @@
+package api
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/shiroha/subdux/internal/service"
+)
+
+const (
+	calDAVBasePath = "/caldav"
+	calDAVHomePath = calDAVBasePath + "/calendars/"
+
+	davNamespace            = "DAV:"
+	calDAVNamespace         = "urn:ietf:params:xml:ns:caldav"
+	calendarServerNamespace = "http://calendarserver.org/ns/"
+
+	maxCalDAVRequestBodyBytes = 1 << 20
+	calDAVTimeLayout          = "20060102T150405Z"
+)
+
+// calDAVReadMethods are served; calDAVWriteMethods are rejected because the
+// collection is read-only.
+var (
+	calDAVReadMethods  = []string{http.MethodOptions, http.MethodGet, http.MethodHead, echo.PROPFIND, echo.REPORT}
+	calDAVWriteMethods = []string{http.MethodPut, http.MethodPost, http.MethodDelete, "PROPPATCH", "MKCOL", "MKCALENDAR", "COPY", "MOVE", "LOCK", "UNLOCK"}
+	davPrefixes        = map[string]string{
+		davNamespace:            "D",
+		calDAVNamespace:         "C",
+		calendarServerNamespace: "CS",
+	}
+)
+
+type CalDAVHandler struct {
+	Calendar *service.CalendarService
+	APIKeys  *service.APIKeyService
+}
+
+func NewCalDAVHandler(calendar *service.CalendarService, apiKeys *service.APIKeyService) *CalDAVHandler {
+	return &CalDAVHandler{Calendar: calendar, APIKeys: apiKeys}
+}
+
+type calDAVPrincipal struct {
+	userID uint
+	filter service.CalendarFeedFilter
+}
+
+// calDAVTarget is the resource a request path names. Empty fields narrow it:
+// no collection means the principal or home, no object means the collection.
+type calDAVTarget struct {
+	home       bool
+	collection string
+	object     string
+}
+
+func (h *CalDAVHandler) RedirectWellKnown(c echo.Context) error {
+	return c.Redirect(http.StatusMovedPermanently, calDAVBasePath+"/")
+}
+
+func (h *CalDAVHandler) Handle(c echo.Context) error {
+	setCalDAVHeaders(c)
+	if c.Request().Method == http.MethodOptions {
+		return c.NoContent(http.StatusOK)
+	}
+	for _, method := range calDAVWriteMethods {
+		if c.Request().Method == method {
+			return c.String(http.StatusForbidden, "calendar is read-only")
+		}
+	}
+
+	principal, err := h.authenticate(c)
+	if err != nil {
+		c.Response().Header().Set("WWW-Authenticate", `Basic realm="Subdux CalDAV", charset="UTF-8"`)
+		return c.String(http.StatusUnauthorized, "authorization required")
+	}
+
+	target, ok := parseCalDAVTarget(c.Request().URL.Path)
+	if !ok {
+		return c.String(http.StatusNotFound, "not found")
+	}
+
+	switch c.Request().Method {
+	case http.MethodGet, http.MethodHead:
+		return h.get(c, principal, target)
+	case echo.PROPFIND:
+		return h.propfind(c, principal, target)
+	case echo.REPORT:
+		return h.report(c, principal, target)
+	default:
+		return c.String(http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// authenticate accepts a calendar token or an integration API key as the
+// password of HTTP Basic auth, as a bearer token, or in X-API-Key. Calendar
+// tokens carry their own feed filter; API keys see every subscription.
+func (h *CalDAVHandler) authenticate(c echo.Context) (*calDAVPrincipal, error) {
+	secret := strings.TrimSpace(c.Request().Header.Get("X-API-Key"))
+	if secret == "" {
+		if username, password, ok := c.Request().BasicAuth(); ok {
+			secret = password
+			if secret == "" {
+				secret = username
+			}
+		} else if auth := c.Request().Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+			secret = strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+		}
+	}
+	if secret == "" {
+		return nil, errors.New("missing credentials")
+	}
+
+	ctx := c.Request().Context()
+	if token, err := h.Calendar.WithContext(ctx).ResolveToken(secret); err == nil {
+		return &calDAVPrincipal{userID: token.UserID, filter: service.CalendarTokenFilter(*token)}, nil
+	}
+
+	key, err := h.APIKeys.WithContext(ctx).ValidateKey(secret)
+	if err != nil {
+		return nil, err
+	}
+	if key.KeyKind != service.APIKeyKindAPIIntegration || !containsString(key.Scopes, service.APIKeyScopeRead) {
+		return nil, errors.New("api key cannot access calendars")
+	}
+	return &calDAVPrincipal{userID: key.UserID}, nil
+}
+
+func containsString(values []string, want string) bool {
+	for _, value := range values {
+		if value == want {
+			return true
+		}
+	}
+	return false
+}
+
+func setCalDAVHeaders(c echo.Context) {
+	header := c.Response().Header()
+	header.Set("DAV", "1, 3, calendar-access")
+	header.Set("Allow", strings.Join(calDAVReadMethods, ", "))
+}
+
+func parseCalDAVTarget(requestPath string) (calDAVTarget, bool) {
+	rest := strings.Trim(strings.TrimPrefix(requestPath, calDAVBasePath), "/")
+	if rest == "" {
+		return calDAVTarget{}, true
+	}
+	segments := strings.Split(rest, "/")
+	if segments[0] != "calendars" || len(segments) > 3 {
+		return calDAVTarget{}, false
+	}
+	target := calDAVTarget{home: true}
+	if len(segments) >= 2 {
+		target.collection = segments[1]
+	}
+	if len(segments) == 3 {
+		target.object = segments[2]
+	}
+	return target, true
+}
+
+func (h *CalDAVHandler) get(c echo.Context, principal *calDAVPrincipal, target calDAVTarget) error {
+	if target.object == "" {
+		return c.String(http.StatusMethodNotAllowed, "only calendar objects can be fetched")
+	}
+	snapshot, err := h.Calendar.WithContext(c.Request().Context()).CalDAVCollection(principal.userID, principal.filter, target.collection)
+	if err != nil {
+		return writeCalDAVError(c, err)
+	}
+	object := findCalendarObject(snapshot, target.object)
+	if object == nil {
+		return c.String(http.StatusNotFound, "not found")
+	}
+
+	etag := `"` + object.ETag + `"`
+	header := c.Response().Header()
+	header.Set("ETag", etag)
+	header.Set("Last-Modified", object.LastModified.Format(http.TimeFormat))
+	if calendarFeedNotModified(c.Request(), etag, object.LastModified) {
+		return c.NoContent(http.StatusNotModified)
+	}
+	return c.Blob(http.StatusOK, "text/calendar; charset=utf-8", []byte(object.Data))
+}
+
+func (h *CalDAVHandler) propfind(c echo.Context, principal *calDAVPrincipal, target calDAVTarget) error {
+	req, err := parseDAVRequest(c.Request().Body)
+	if err != nil {
+		return c.String(http.StatusBadRequest, "invalid request body")
+	}
+	depthOne := strings.TrimSpace(c.Request().Header.Get("Depth")) != "0"
+	svc := h.Calendar.WithContext(c.Request().Context())
+
+	var responses []davResponse
+	switch {
+	case !target.home:
+		responses = append(responses, req.respond(calDAVBasePath+"/", calDAVPrincipalProps()))
+		if depthOne {
+			responses = append(responses, req.respond(calDAVHomePath, calDAVHomeProps()))
+		}
+	case target.collection == "":
+		responses = append(responses, req.respond(calDAVHomePath, calDAVHomeProps()))
+		if depthOne {
+			snapshots, err := svc.CalDAVSnapshots(principal.userID, principal.filter)
+			if err != nil {
+				return writeCalDAVError(c, err)
+			}
+			for i := range snapshots {
+				responses = append(responses, req.respond(calDAVCollectionHref(snapshots[i].Collection), calDAVCollectionProps(&snapshots[i])))
+			}
+		}
+	default:
+		snapshot, err := svc.CalDAVCollection(principal.userID, principal.filter, target.collection)
+		if err != nil {
+			return writeCalDAVError(c, err)
+		}
+		if target.object != "" {
+			object := findCalendarObject(snapshot, target.object)
+			if object == nil {
+				return c.String(http.StatusNotFound, "not found")
+			}
+			responses = append(responses, req.respond(calDAVObjectHref(snapshot.Collection, *object), calDAVObjectProps(*object)))
+			break
+		}
+		responses = append(responses, req.respond(calDAVCollectionHref(snapshot.Collection), calDAVCollectionProps(snapshot)))
+		if depthOne {
+			for _, object := range snapshot.Objects {
+				responses = append(responses, req.respond(calDAVObjectHref(snapshot.Collection, object), calDAVObjectProps(object)))
+			}
+		}
+	}
+	return writeDAVMultistatus(c, responses)
+}
+
+func (h *CalDAVHandler) report(c echo.Context, principal *calDAVPrincipal, target calDAVTarget) error {
+	if target.collection == "" {
+		return c.String(http.StatusForbidden, "reports are only supported on calendars")
+	}
+	req, err := parseDAVRequest(c.Request().Body)
+	if err != nil {
+		return c.String(http.StatusBadRequest, "invalid request body")
+	}
+	snapshot, err := h.Calendar.WithContext(c.Request().Context()).CalDAVCollection(principal.userID, principal.filter, target.collection)
+	if err != nil {
+		return writeCalDAVError(c, err)
+	}
+
+	var responses []davResponse
+	switch req.root {
+	case xml.Name{Space: calDAVNamespace, Local: "calendar-multiget"}:
+		for _, href := range req.hrefs {
+			object := findCalendarObjectByHref(snapshot, href)
+			if object == nil {
+				responses = append(responses, davResponse{href: href, status: http.StatusNotFound})
+				continue
+			}
+			responses = append(responses, req.respond(calDAVObjectHref(snapshot.Collection, *object), calDAVObjectProps(*object)))
+		}
+	case xml.Name{Space: calDAVNamespace, Local: "calendar-query"}:
+		if !req.matchesEvents() {
+			break
+		}
+		for _, object := range snapshot.Objects {
+			if req.hasTimeRange && !object.OccursBetween(req.timeStart, req.timeEnd) {
+				continue
+			}
+			responses = append(responses, req.respond(calDAVObjectHref(snapshot.Collection, object), calDAVObjectProps(object)))
+		}
+	default:
+		return c.String(http.StatusForbidden, "unsupported report")
+	}
+	return writeDAVMultistatus(c, responses)
+}
+
+func writeCalDAVError(c echo.Context, err error) error {
+	if errors.Is(err, service.ErrCalendarCollectionNotFound) {
+		return c.String(http.StatusNotFound, "not found")
+	}
+	return writeInternalServerError(c, err)
+}
+
+func findCalendarObject(snapshot *service.CalendarCollectionSnapshot, name string) *service.CalendarObject {
+	for i := range snapshot.Objects {
+		if snapshot.Objects[i].Name == name {
+			return &snapshot.Objects[i]
+		}
+	}
+	return nil
+}
+
+func findCalendarObjectByHref(snapshot *service.CalendarCollectionSnapshot, href string) *service.CalendarObject {
+	parsed, err := url.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return nil
+	}
+	collectionHref := calDAVCollectionHref(snapshot.Collection)
+	if !strings.HasPrefix(parsed.Path, collectionHref) {
+		return nil
+	}
+	return findCalendarObject(snapshot, strings.TrimPrefix(parsed.Path, collectionHref))
+}
+
+func calDAVCollectionHref(collection service.CalendarCollection) string {
+	return calDAVHomePath + url.PathEscape(collection.Slug) + "/"
+}
+
+func calDAVObjectHref(collection service.CalendarCollection, object service.CalendarObject) string {
+	return calDAVCollectionHref(collection) + url.PathEscape(object.Name)
+}
+
+// davProp is one WebDAV property with its value already encoded as XML.
+// Explicit properties are only returned when requested by name.
+type davProp struct {
+	name     xml.Name
+	value    string
+	explicit bool
+}
+
+type davResponse struct {
+	href    string
+	status  int
+	found   []davProp
+	missing []xml.Name
+}
+
+func davName(local string) xml.Name {
+	return xml.Name{Space: davNamespace, Local: local}
+}
+
+func calDAVName(local string) xml.Name {
+	return xml.Name{Space: calDAVNamespace, Local: local}
+}
+
+func davHref(href string) string {
+	return "<D:href>" + davEscape(href) + "</D:href>"
+}
+
+func davEscape(value string) string {
+	var sb strings.Builder
+	_ = xml.EscapeText(&sb, []byte(value))
+	return sb.String()
+}
+
+func calDAVCommonProps() []davProp {
+	return []davProp{
+		{name: davName("current-user-principal"), value: davHref(calDAVBasePath + "/")},
+		{name: davName("current-user-privilege-set"), value: "<D:privilege><D:read/></D:privilege>"},
+	}
+}
+
+func calDAVPrincipalProps() []davProp {
+	return append([]davProp{
+		{name: davName("resourcetype"), value: "<D:collection/><D:principal/>"},
+		{name: davName("displayname"), value: "Subdux"},
+		{name: davName("principal-URL"), value: davHref(calDAVBasePath + "/")},
+		{name: calDAVName("calendar-home-set"), value: davHref(calDAVHomePath)},
+	}, calDAVCommonProps()...)
+}
+
+func calDAVHomeProps() []davProp {
+	return append([]davProp{
+		{name: davName("resourcetype"), value: "<D:collection/>"},
+		{name: davName("displayname"), value: "Calendars"},
+	}, calDAVCommonProps()...)
+}
+
+func calDAVCollectionProps(snapshot *service.CalendarCollectionSnapshot) []davProp {
+	return append([]davProp{
+		{name: davName("resourcetype"), value: "<D:collection/><C:calendar/>"},
+		{name: davName("displayname"), value: davEscape(snapshot.Collection.DisplayName)},
+		{name: davName("getetag"), value: davEscape(`"` + snapshot.CTag + `"`)},
+		{name: xml.Name{Space: calendarServerNamespace, Local: "getctag"}, value: davEscape(snapshot.CTag)},
+		{name: calDAVName("supported-calendar-component-set"), value: `<C:comp name="VEVENT"/>`},
+		{name: calDAVName("supported-calendar-data"), value: `<C:calendar-data content-type="text/calendar" version="2.0"/>`},
+		{name: davName("supported-report-set"), value: "<D:supported-report><D:report><C:calendar-query/></D:report></D:supported-report>" +
+			"<D:supported-report><D:report><C:calendar-multiget/></D:report></D:supported-report>"},
+	}, calDAVCommonProps()...)
+}
+
+func calDAVObjectProps(object service.CalendarObject) []davProp {
+	return []davProp{
+		{name: davName("resourcetype")},
+		{name: davName("getetag"), value: davEscape(`"` + object.ETag + `"`)},
+		{name: davName("getcontenttype"), value: "text/calendar; charset=utf-8; component=VEVENT"},
+		{name: davName("getcontentlength"), value: strconv.Itoa(len(object.Data))},
+		{name: davName("getlastmodified"), value: object.LastModified.Format(http.TimeFormat)},
+		{name: calDAVName("calendar-data"), value: davEscape(object.Data), explicit: true},
+	}
+}
+
+// davRequest is the subset of a PROPFIND or REPORT body this server acts on.
+type davRequest struct {
+	root         xml.Name
+	props        []xml.Name
+	propName     bool
+	hrefs        []string
+	compNames    []string
+	hasTimeRange bool
+	timeStart    time.Time
+	timeEnd      time.Time
+}
+
+// parseDAVRequest reads a WebDAV request body. An empty body is an allprop
+// PROPFIND.
+func parseDAVRequest(body io.Reader) (*davRequest, error) {
+	req := &davRequest{}
+	decoder := xml.NewDecoder(io.LimitReader(body, maxCalDAVRequestBodyBytes))
+	var stack []xml.Name
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return req, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch element := token.(type) {
+		case xml.StartElement:
+			switch {
+			case len(stack) == 0:
+				req.root = element.Name
+			case len(stack) == 2 && stack[1] == davName("prop"):
+				req.props = append(req.props, element.Name)
+			case element.Name == davName("propname"):
+				req.propName = true
+			case element.Name == davName("href"):
+				var href string
+				if err := decoder.DecodeElement(&href, &element); err != nil {
+					return nil, err
+				}
+				req.hrefs = append(req.hrefs, strings.TrimSpace(href))
+				continue
+			case element.Name == calDAVName("comp-filter"):
+				for _, attr := range element.Attr {
+					if attr.Name.Local == "name" {
+						req.compNames = append(req.compNames, strings.ToUpper(attr.Value))
+					}
+				}
+			case element.Name == calDAVName("time-range"):
+				req.hasTimeRange = true
+				for _, attr := range element.Attr {
+					parsed, err := time.Parse(calDAVTimeLayout, attr.Value)
+					if err != nil {
+						return nil, err
+					}
+					switch attr.Name.Local {
+					case "start":
+						req.timeStart = parsed
+					case "end":
+						req.timeEnd = parsed
+					}
+				}
+			}
+			stack = append(stack, element.Name)
+		case xml.EndElement:
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+}
+
+// matchesEvents reports whether a calendar-query's component filters can match
+// VEVENTs, the only component this server publishes.
+func (r *davRequest) matchesEvents() bool {
+	for _, name := range r.compNames {
+		if name != "VCALENDAR" && name != "VEVENT" {
+			return false
+		}
+	}
+	return true
+}
+
+// respond selects the requested properties of a resource: every
+// non-explicit property for allprop, names only for propname, or exactly the
+// requested names with unknown ones reported missing.
+func (r *davRequest) respond(href string, available []davProp) davResponse {
+	resp := davResponse{href: href}
+	if len(r.props) == 0 {
+		for _, prop := range available {
+			if prop.explicit {
+				continue
+			}
+			if r.propName {
+				prop.value = ""
+			}
+			resp.found = append(resp.found, prop)
+		}
+		return resp
+	}
+
+	for _, name := range r.props {
+		matched := false
+		for _, prop := range available {
+			if prop.name == name {
+				resp.found = append(resp.found, prop)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			resp.missing = append(resp.missing, name)
+		}
+	}
+	return resp
+}
+
+func writeDAVMultistatus(c echo.Context, responses []davResponse) error {
+	var sb strings.Builder
+	sb.WriteString(xml.Header)
+	sb.WriteString(`<D:multistatus xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav" xmlns:CS="http://calendarserver.org/ns/">`)
+	for _, resp := range responses {
+		sb.WriteString("<D:response>")
+		sb.WriteString(davHref(resp.href))
+		if resp.status != 0 {
+			sb.WriteString("<D:status>" + davStatusLine(resp.status) + "</D:status>")
+		}
+		if len(resp.found) > 0 {
+			sb.WriteString("<D:propstat><D:prop>")
+			for _, prop := range resp.found {
+				sb.WriteString(davElement(prop.name, prop.value))
+			}
+			sb.WriteString("</D:prop><D:status>" + davStatusLine(http.StatusOK) + "</D:status></D:propstat>")
+		}
+		if len(resp.missing) > 0 {
+			sb.WriteString("<D:propstat><D:prop>")
+			for _, name := range resp.missing {
+				sb.WriteString(davElement(name, ""))
+			}
+			sb.WriteString("</D:prop><D:status>" + davStatusLine(http.StatusNotFound) + "</D:status></D:propstat>")
+		}
+		sb.WriteString("</D:response>")
+	}
+	sb.WriteString("</D:multistatus>")
+	return c.Blob(http.StatusMultiStatus, "application/xml; charset=utf-8", []byte(sb.String()))
+}
+
+func davStatusLine(status int) string {
+	return "HTTP/1.1 " + strconv.Itoa(status) + " " + http.StatusText(status)
+}
+
+func davElement(name xml.Name, value string) string {
+	tag := name.Local
+	open := tag
+	if prefix, ok := davPrefixes[name.Space]; ok {
+		tag = prefix + ":" + name.Local
+		open = tag
+	} else if name.Space != "" {
+		open = tag + ` xmlns="` + davEscape(name.Space) + `"`
+	}
+	if value == "" {
+		return "<" + open + "/>"
+	}
+	return "<" + open + ">" + value + "</" + tag + ">"
+}
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/shiroha/subdux/internal/model"
+	"github.com/shiroha/subdux/internal/pkg"
+	"github.com/shiroha/subdux/internal/service"
+)
+
+func serveCalDAV(e *echo.Echo, method, target, secret, depth, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if secret != "" {
+		req.SetBasicAuth("subdux", secret)
+	}
+	if depth != "" {
+		req.Header.Set("Depth", depth)
+	}
+	req.Header.Set("Content-Type", "application/xml")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCalDAVServesSubscriptionsReadOnly(t *testing.T) {
+	restoreClock := pkg.SetNowForTest(time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC))
+	t.Cleanup(restoreClock)
+
+	db := newHumanOnlyRouteTestDB(t)
+	user := createHumanOnlyRouteTestUser(t, db)
+	streaming := model.Category{UserID: user.ID, Name: "Streaming"}
+	if err := db.Create(&streaming).Error; err != nil {
+		t.Fatalf("failed to create category: %v", err)
+	}
+	intervalCount := 1
+	for _, input := range []service.CreateSubscriptionInput{
+		{Name: "Video", Amount: 9.99, CategoryID: &streaming.ID, NextBillingDate: "2026-03-10"},
+		{Name: "Domain", Amount: 12, NextBillingDate: "2026-06-01"},
+	} {
+		input.BillingType = "recurring"
+		input.RecurrenceType = "interval"
+		input.IntervalCount = &intervalCount
+		input.IntervalUnit = "year"
+		if _, err := service.NewSubscriptionService(db).Create(user.ID, input); err != nil {
+			t.Fatalf("failed to create subscription: %v", err)
+		}
+	}
+	token, err := service.NewCalendarService(db).GenerateTokenWithFilter(user.ID, "CalDAV", service.CalendarFeedFilter{SplitByCategory: true})
+	if err != nil {
+		t.Fatalf("failed to create calendar token: %v", err)
+	}
+	e := newHumanOnlyRouteTestServer(t, db)
+
+	if rec := serveCalDAV(e, echo.PROPFIND, "/caldav/", "", "0", ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("unauthenticated PROPFIND status = %d, want 401", rec.Code)
+	}
+
+	rec := serveCalDAV(e, echo.PROPFIND, "/caldav/", token.Token, "0",
+		`<d:propfind xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav"><d:prop><c:calendar-home-set/><d:quota-used-bytes/></d:prop></d:propfind>`)
+	if rec.Code != http.StatusMultiStatus {
+		t.Fatalf("principal PROPFIND status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), "<C:calendar-home-set><D:href>/caldav/calendars/</D:href></C:calendar-home-set>") ||
+		!strings.Contains(rec.Body.String(), "<D:quota-used-bytes/></D:prop><D:status>HTTP/1.1 404 Not Found") {
+		t.Fatalf("principal PROPFIND body = %s", rec.Body.String())
+	}
+
+	rec = serveCalDAV(e, echo.PROPFIND, "/caldav/calendars/", token.Token, "1", "")
+	if !strings.Contains(rec.Body.String(), "/caldav/calendars/category-") ||
+		!strings.Contains(rec.Body.String(), "<D:displayname>Streaming</D:displayname>") ||
+		!strings.Contains(rec.Body.String(), "/caldav/calendars/uncategorized/") {
+		t.Fatalf("home PROPFIND does not list one calendar per category: %s", rec.Body.String())
+	}
+
+	categoryPath := fmt.Sprintf("/caldav/calendars/category-%d/", streaming.ID)
+	rec = serveCalDAV(e, echo.REPORT, categoryPath, token.Token, "1",
+		`<c:calendar-query xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav"><d:prop><d:getetag/><c:calendar-data/></d:prop>`+
+			`<c:filter><c:comp-filter name="VCALENDAR"><c:comp-filter name="VEVENT"><c:time-range start="20260301T000000Z" end="20260401T000000Z"/></c:comp-filter></c:comp-filter></c:filter></c:calendar-query>`)
+	if rec.Code != http.StatusMultiStatus || !strings.Contains(rec.Body.String(), "SUMMARY:Video") || strings.Contains(rec.Body.String(), "Domain") {
+		t.Fatalf("calendar-query status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+
+	rec = serveCalDAV(e, echo.REPORT, "/caldav/calendars/uncategorized/", token.Token, "1",
+		`<c:calendar-multiget xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav"><d:prop><c:calendar-data/></d:prop>`+
+			`<d:href>/caldav/calendars/uncategorized/subdux-sub-2.ics</d:href><d:href>/caldav/calendars/uncategorized/missing.ics</d:href></c:calendar-multiget>`)
+	if !strings.Contains(rec.Body.String(), "SUMMARY:Domain") || !strings.Contains(rec.Body.String(), "<D:href>/caldav/calendars/uncategorized/missing.ics</D:href><D:status>HTTP/1.1 404 Not Found") {
+		t.Fatalf("calendar-multiget body = %s", rec.Body.String())
+	}
+
+	rec = serveCalDAV(e, http.MethodGet, "/caldav/calendars/uncategorized/subdux-sub-2.ics", token.Token, "", "")
+	if rec.Code != http.StatusOK || rec.Header().Get("ETag") == "" || !strings.Contains(rec.Body.String(), "BEGIN:VEVENT") {
+		t.Fatalf("GET object status = %d, etag = %q", rec.Code, rec.Header().Get("ETag"))
+	}
+
+	if rec := serveCalDAV(e, http.MethodPut, "/caldav/calendars/uncategorized/new.ics", token.Token, "", "BEGIN:VCALENDAR"); rec.Code != http.StatusForbidden {
+		t.Fatalf("PUT status = %d, want 403", rec.Code)
+	}
+}
+
+func TestCalDAVAcceptsIntegrationAPIKey(t *testing.T) {
+	db := newHumanOnlyRouteTestDB(t)
+	user := createHumanOnlyRouteTestUser(t, db)
+	e := newHumanOnlyRouteTestServer(t, db)
+
+	apiKeys := service.NewAPIKeyService(db)
+	integration, err := apiKeys.Create(user.ID, user.Role, service.CreateAPIKeyInput{
+		Name:    "Calendar",
+		KeyKind: service.APIKeyKindAPIIntegration,
+		Scopes:  []string{service.APIKeyScopeRead},
+	})
+	if err != nil {
+		t.Fatalf("failed to create api key: %v", err)
+	}
+	mcp, err := apiKeys.Create(user.ID, user.Role, service.CreateAPIKeyInput{
+		Name:    "MCP",
+		KeyKind: service.APIKeyKindMCPClient,
+		Scopes:  []string{service.APIKeyScopeRead},
+	})
+	if err != nil {
+		t.Fatalf("failed to create api key: %v", err)
+	}
+
+	rec := serveCalDAV(e, echo.PROPFIND, "/caldav/calendars/", integration.Key, "1", "")
+	if rec.Code != http.StatusMultiStatus || !strings.Contains(rec.Body.String(), "/caldav/calendars/subscriptions/") {
+		t.Fatalf("integration key PROPFIND status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	if rec := serveCalDAV(e, echo.PROPFIND, "/caldav/calendars/", mcp.Key, "1", ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("mcp key PROPFIND status = %d, want 401", rec.Code)
+	}
+}
@@ -1,14 +1,71 @@
 package api
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
+	"github.com/shiroha/subdux/internal/model"
 	"github.com/shiroha/subdux/internal/service"
 )
 
+type calendarTokenResponse struct {
+	ID               uint      `json:"id"`
+	Token            string    `json:"token,omitempty"`
+	Name             string    `json:"name"`
+	CategoryIDs      []uint    `json:"category_ids"`
+	PaymentMethodIDs []uint    `json:"payment_method_ids"`
+	MinAmount        *float64  `json:"min_amount"`
+	SplitByCategory  bool      `json:"split_by_category"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+type calendarTokenFilterInput struct {
+	CategoryIDs      []uint   `json:"category_ids"`
+	PaymentMethodIDs []uint   `json:"payment_method_ids"`
+	MinAmount        *float64 `json:"min_amount"`
+	SplitByCategory  bool     `json:"split_by_category"`
+}
+
+func (in calendarTokenFilterInput) toServiceFilter() service.CalendarFeedFilter {
+	return service.CalendarFeedFilter{
+		CategoryIDs:      in.CategoryIDs,
+		PaymentMethodIDs: in.PaymentMethodIDs,
+		MinAmount:        in.MinAmount,
+		SplitByCategory:  in.SplitByCategory,
+	}
+}
+
+func mapCalendarTokenResponse(token model.CalendarToken) calendarTokenResponse {
+	filter := service.CalendarTokenFilter(token)
+	return calendarTokenResponse{
+		ID:               token.ID,
+		Token:            token.Token,
+		Name:             token.Name,
+		CategoryIDs:      filter.CategoryIDs,
+		PaymentMethodIDs: filter.PaymentMethodIDs,
+		MinAmount:        filter.MinAmount,
+		SplitByCategory:  filter.SplitByCategory,
+		CreatedAt:        token.CreatedAt,
+	}
+}
+
+func writeCalendarTokenError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, service.ErrCalendarTokenNotFound):
+		return c.JSON(http.StatusNotFound, echo.Map{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidCalendarFilterCategory),
+		errors.Is(err, service.ErrInvalidCalendarFilterPaymentMethod),
+		errors.Is(err, service.ErrInvalidCalendarFilterMinAmount):
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	default:
+		return writeInternalServerError(c, err)
+	}
+}
+
 type CalendarHandler struct {
 	Service *service.CalendarService
 }
@@ -23,16 +80,19 @@ func (h *CalendarHandler) ListTokens(c echo.Context) error {
 	if err != nil {
 		return writeInternalServerError(c, err)
 	}
+	responses := make([]calendarTokenResponse, len(tokens))
 	for i := range tokens {
 		tokens[i].MaskToken()
+		responses[i] = mapCalendarTokenResponse(tokens[i])
 	}
-	return c.JSON(http.StatusOK, tokens)
+	return c.JSON(http.StatusOK, responses)
 }
 
 func (h *CalendarHandler) CreateToken(c echo.Context) error {
 	userID := getUserID(c)
 	var input struct {
 		Name string `json:"name"`
+		calendarTokenFilterInput
 	}
 	if err := c.Bind(&input); err != nil {
 		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid request body"})
@@ -54,11 +114,29 @@ func (h *CalendarHandler) CreateToken(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Maximum of 5 calendar links reached"})
 	}
 
-	token, err := svc.GenerateToken(userID, input.Name)
+	token, err := svc.GenerateTokenWithFilter(userID, input.Name, input.toServiceFilter())
 	if err != nil {
-		return writeInternalServerError(c, err)
+		return writeCalendarTokenError(c, err)
 	}
-	return c.JSON(http.StatusCreated, token)
+	return c.JSON(http.StatusCreated, mapCalendarTokenResponse(*token))
+}
+
+func (h *CalendarHandler) UpdateTokenFilter(c echo.Context) error {
+	userID := getUserID(c)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid ID"})
+	}
+	var input calendarTokenFilterInput
+	if err := c.Bind(&input); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid request body"})
+	}
+
+	token, err := h.Service.WithContext(c.Request().Context()).UpdateTokenFilter(userID, uint(id), input.toServiceFilter())
+	if err != nil {
+		return writeCalendarTokenError(c, err)
+	}
+	return c.JSON(http.StatusOK, mapCalendarTokenResponse(*token))
 }
 
 func (h *CalendarHandler) DeleteToken(c echo.Context) error {
@@ -69,7 +147,7 @@ func (h *CalendarHandler) DeleteToken(c echo.Context) error {
 	}
 
 	if err := h.Service.WithContext(c.Request().Context()).DeleteToken(userID, uint(id)); err != nil {
-		return writeInternalServerError(c, err)
+		return writeCalendarTokenError(c, err)
 	}
 	return c.NoContent(http.StatusNoContent)
 }
@@ -81,17 +159,48 @@ func (h *CalendarHandler) GetCalendarFeed(c echo.Context) error {
 	}
 
 	svc := h.Service.WithContext(c.Request().Context())
-	userID, err := svc.ValidateToken(tokenStr)
+	token, err := svc.ResolveToken(tokenStr)
 	if err != nil {
 		return c.JSON(http.StatusUnauthorized, echo.Map{"error": "invalid or expired token"})
 	}
 
-	ics, err := svc.GenerateICalFeed(userID)
+	feed, err := svc.GenerateFeedForToken(token)
 	if err != nil {
 		return writeInternalServerError(c, err)
 	}
 
-	c.Response().Header().Set("Content-Type", "text/calendar; charset=utf-8")
-	c.Response().Header().Set("Content-Disposition", `attachment; filename="subdux.ics"`)
-	return c.String(http.StatusOK, ics)
+	etag := `"` + feed.ETag + `"`
+	header := c.Response().Header()
+	header.Set("ETag", etag)
+	header.Set("Last-Modified", feed.LastModified.Format(http.TimeFormat))
+	header.Set("Cache-Control", "private, no-cache")
+	if calendarFeedNotModified(c.Request(), etag, feed.LastModified) {
+		return c.NoContent(http.StatusNotModified)
+	}
+
+	header.Set("Content-Type", "text/calendar; charset=utf-8")
+	header.Set("Content-Disposition", `attachment; filename="subdux.ics"`)
+	return c.String(http.StatusOK, feed.Body)
+}
+
+// calendarFeedNotModified applies RFC 9110 conditional GET rules: If-None-Match
+// wins when present, otherwise If-Modified-Since is compared at second
+// precision.
+func calendarFeedNotModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if ifNoneMatch := strings.TrimSpace(r.Header.Get("If-None-Match")); ifNoneMatch != "" {
+		for _, candidate := range strings.Split(ifNoneMatch, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == "*" || candidate == etag {
+				return true
+			}
+		}
+		return false
+	}
+	if ifModifiedSince := r.Header.Get("If-Modified-Since"); ifModifiedSince != "" {
+		since, err := http.ParseTime(ifModifiedSince)
+		if err == nil && !lastModified.After(since) {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCalendarFeedNotModified(t *testing.T) {
+	lastModified := time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
+	etag := `"abc123"`
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    bool
+	}{
+		{name: "no validators", want: false},
+		{name: "matching etag", headers: map[string]string{"If-None-Match": `"other", W/"abc123"`}, want: true},
+		{name: "stale etag ignores date", headers: map[string]string{
+			"If-None-Match":     `"other"`,
+			"If-Modified-Since": lastModified.Add(time.Hour).Format(http.TimeFormat),
+		}, want: false},
+		{name: "not modified since", headers: map[string]string{"If-Modified-Since": lastModified.Format(http.TimeFormat)}, want: true},
+		{name: "modified since", headers: map[string]string{"If-Modified-Since": lastModified.Add(-time.Second).Format(http.TimeFormat)}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/calendar/feed", nil)
+			for key, value := range tt.headers {
+				req.Header.Set(key, value)
+			}
+			if got := calendarFeedNotModified(req, etag, lastModified); got != tt.want {
+				t.Fatalf("calendarFeedNotModified() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
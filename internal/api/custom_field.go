@@ -0,0 +1,131 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/shiroha/subdux/internal/model"
+	"github.com/shiroha/subdux/internal/service"
+)
+
+type CustomFieldHandler struct {
+	Service *service.CustomFieldService
+}
+
+type customFieldResponse struct {
+	ID           uint     `json:"id"`
+	Key          string   `json:"key"`
+	Name         string   `json:"name"`
+	Type         string   `json:"type"`
+	Options      []string `json:"options"`
+	DisplayOrder int      `json:"display_order"`
+}
+
+func mapCustomFieldResponse(field model.CustomFieldDefinition) customFieldResponse {
+	options := service.DecodeCustomFieldOptions(field)
+	if options == nil {
+		options = []string{}
+	}
+	return customFieldResponse{
+		ID:           field.ID,
+		Key:          field.Key,
+		Name:         field.Name,
+		Type:         field.Type,
+		Options:      options,
+		DisplayOrder: field.DisplayOrder,
+	}
+}
+
+func mapCustomFieldResponses(fields []model.CustomFieldDefinition) []customFieldResponse {
+	responses := make([]customFieldResponse, len(fields))
+	for i, field := range fields {
+		responses[i] = mapCustomFieldResponse(field)
+	}
+	return responses
+}
+
+func NewCustomFieldHandler(s *service.CustomFieldService) *CustomFieldHandler {
+	return &CustomFieldHandler{Service: s}
+}
+
+func (h *CustomFieldHandler) List(c echo.Context) error {
+	userID := getUserID(c)
+	fields, err := h.Service.WithContext(c.Request().Context()).List(userID)
+	if err != nil {
+		return writeInternalServerError(c, err)
+	}
+	return c.JSON(http.StatusOK, mapCustomFieldResponses(fields))
+}
+
+func (h *CustomFieldHandler) Create(c echo.Context) error {
+	userID := getUserID(c)
+	var input service.CreateCustomFieldInput
+	if err := c.Bind(&input); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid request body"})
+	}
+	field, err := h.Service.WithContext(c.Request().Context()).Create(userID, input)
+	if err != nil {
+		return writeCustomFieldError(c, err)
+	}
+	return c.JSON(http.StatusCreated, mapCustomFieldResponse(*field))
+}
+
+func (h *CustomFieldHandler) Update(c echo.Context) error {
+	userID := getUserID(c)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid id"})
+	}
+	var input service.UpdateCustomFieldInput
+	if err := c.Bind(&input); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid request body"})
+	}
+	field, err := h.Service.WithContext(c.Request().Context()).Update(userID, uint(id), input)
+	if err != nil {
+		return writeCustomFieldError(c, err)
+	}
+	return c.JSON(http.StatusOK, mapCustomFieldResponse(*field))
+}
+
+func (h *CustomFieldHandler) Delete(c echo.Context) error {
+	userID := getUserID(c)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid id"})
+	}
+	if err := h.Service.WithContext(c.Request().Context()).Delete(userID, uint(id)); err != nil {
+		return writeCustomFieldError(c, err)
+	}
+	return c.JSON(http.StatusNoContent, nil)
+}
+
+func (h *CustomFieldHandler) Reorder(c echo.Context) error {
+	userID := getUserID(c)
+	var items []service.ReorderItem
+	if err := c.Bind(&items); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid request body"})
+	}
+	if err := h.Service.WithContext(c.Request().Context()).Reorder(userID, items); err != nil {
+		return writeInternalServerError(c, err)
+	}
+	return c.JSON(http.StatusOK, echo.Map{"message": "reordered"})
+}
+
+func writeCustomFieldError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, service.ErrCustomFieldNotFound):
+		return c.JSON(http.StatusNotFound, echo.Map{"error": err.Error()})
+	case errors.Is(err, service.ErrCustomFieldKeyExists):
+		return c.JSON(http.StatusConflict, echo.Map{"error": err.Error()})
+	case errors.Is(err, service.ErrCustomFieldInvalidKey),
+		errors.Is(err, service.ErrCustomFieldInvalidName),
+		errors.Is(err, service.ErrCustomFieldInvalidType),
+		errors.Is(err, service.ErrCustomFieldInvalidOption),
+		errors.Is(err, service.ErrCustomFieldLimitReached):
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	default:
+		return writeInternalServerError(c, err)
+	}
+}
@@ -29,6 +29,11 @@ func newBootstrapTestDB(t *testing.T) *gorm.DB {
 		&model.User{},
 		&model.Subscription{},
 		&model.SubscriptionEvent{},
+		&model.SubscriptionPriceChange{},
+		&model.SubscriptionUsage{},
+		&model.CustomFieldDefinition{},
+		&model.SubscriptionCustomFieldValue{},
+		&model.SubscriptionAttachment{},
 		&model.Category{},
 		&model.PaymentMethod{},
 		&model.UserCurrency{},
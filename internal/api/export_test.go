@@ -36,6 +36,9 @@ func newExportAPITestDB(t *testing.T) *gorm.DB {
 		&model.Category{},
 		&model.PaymentMethod{},
 		&model.Subscription{},
+		&model.CustomFieldDefinition{},
+		&model.SubscriptionCustomFieldValue{},
+		&model.SubscriptionAttachment{},
 		&model.NotificationChannel{},
 		&model.NotificationPolicy{},
 		&model.NotificationTemplate{},
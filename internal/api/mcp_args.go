@@ -3,6 +3,7 @@ package api
 import (
 	"errors"
 	"fmt"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -405,3 +406,100 @@ func readBoolArg(args map[string]interface{}, key string) (bool, bool) {
 		return false, false
 	}
 }
+
+func bulkSubscriptionInputFromMCPArgs(args map[string]interface{}) (service.BulkSubscriptionInput, error) {
+	var input service.BulkSubscriptionInput
+	if err := validateMCPArgTypes(args, []mcpArgSpec{{Key: "action", Type: "string"}}); err != nil {
+		return input, err
+	}
+	input.Action, _ = readStringArg(args, "action")
+
+	if raw, ok := args["ids"]; ok && raw != nil {
+		items, ok := raw.([]interface{})
+		if !ok {
+			return input, errors.New("ids must be an array of integers")
+		}
+		for i := range items {
+			id, ok := readUintArg(map[string]interface{}{"id": items[i]}, "id")
+			if !ok {
+				return input, errors.New("ids must be an array of integers")
+			}
+			input.IDs = append(input.IDs, id)
+		}
+	}
+
+	filter, err := readMCPObjectArg(args, "filter", []mcpArgSpec{
+		{Key: "query", Type: "string"},
+		{Key: "status", Type: "string"},
+		{Key: "renewal_mode", Type: "string"},
+		{Key: "currency", Type: "string"},
+		{Key: "category_id", Type: "integer"},
+		{Key: "payment_method_id", Type: "integer"},
+	})
+	if err != nil {
+		return input, err
+	}
+	if filter != nil {
+		input.Filter = &service.BulkSubscriptionFilter{}
+		input.Filter.Query, _ = readStringArg(filter, "query")
+		input.Filter.Status, _ = readStringArg(filter, "status")
+		input.Filter.RenewalMode, _ = readStringArg(filter, "renewal_mode")
+		input.Filter.Currency, _ = readStringArg(filter, "currency")
+		input.Filter.CategoryID, _ = readUintPointerArg(filter, "category_id")
+		input.Filter.PaymentMethodID, _ = readUintPointerArg(filter, "payment_method_id")
+	}
+
+	update, err := readMCPObjectArg(args, "update", []mcpArgSpec{
+		{Key: "category_id", Type: "integer", Nullable: true},
+		{Key: "payment_method_id", Type: "integer", Nullable: true},
+		{Key: "notify_enabled", Type: "boolean", Nullable: true},
+		{Key: "notify_days_before", Type: "integer", Nullable: true},
+		{Key: "renewal_mode", Type: "string"},
+	})
+	if err != nil {
+		return input, err
+	}
+	if value, ok := readNullableUintArg(update, "category_id"); ok {
+		input.Update.CategoryIDSet = true
+		input.Update.CategoryID = value
+	}
+	if value, ok := readNullableUintArg(update, "payment_method_id"); ok {
+		input.Update.PaymentMethodIDSet = true
+		input.Update.PaymentMethodID = value
+	}
+	if value, ok := readNullableBoolArg(update, "notify_enabled"); ok {
+		input.Update.NotifyEnabledSet = true
+		input.Update.NotifyEnabled = value
+	}
+	if value, ok := readNullableIntArg(update, "notify_days_before"); ok {
+		input.Update.NotifyDaysBeforeSet = true
+		input.Update.NotifyDaysBefore = value
+	}
+	if value, ok := readStringArg(update, "renewal_mode"); ok {
+		input.Update.RenewalMode = &value
+	}
+	return input, nil
+}
+
+// readMCPObjectArg returns a nested object argument after checking that it
+// only holds the keys in specs and that each has the expected type. A missing
+// or null argument yields nil.
+func readMCPObjectArg(args map[string]interface{}, key string, specs []mcpArgSpec) (map[string]interface{}, error) {
+	raw, ok := args[key]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	object, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s must be object", key)
+	}
+	for name := range object {
+		if !slices.ContainsFunc(specs, func(spec mcpArgSpec) bool { return spec.Key == name }) {
+			return nil, fmt.Errorf("unexpected argument: %s.%s", key, name)
+		}
+	}
+	if err := validateMCPArgTypes(object, specs); err != nil {
+		return nil, fmt.Errorf("%s.%w", key, err)
+	}
+	return object, nil
+}
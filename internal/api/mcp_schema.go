@@ -126,6 +126,43 @@ func mcpToolDefinitions() []mcpToolDefinition {
 				return h.callMarkSubscriptionRenewed(ctx, principal, args)
 			},
 		},
+		{
+			Name:        "bulk_update_subscriptions",
+			Title:       "Bulk Update Subscriptions",
+			Description: "Update, delete, or mark renewed several subscriptions at once, selected by ids or by filter. All-or-nothing: if any item fails, nothing is changed and per-item errors are returned.",
+			InputSchema: func() map[string]interface{} {
+				return objectSchema(map[string]interface{}{
+					"idempotency_key": idempotencyKeySchema(),
+					"action":          enumSchema("Action to apply to each selected subscription.", []string{"update", "delete", "mark_renewed"}),
+					"ids": map[string]interface{}{
+						"type":        "array",
+						"description": "Subscription IDs. Provide either ids or filter.",
+						"items":       idSchema("Subscription ID."),
+						"minItems":    1,
+						"maxItems":    500,
+					},
+					"filter": objectSchema(map[string]interface{}{
+						"query":             stringSchema("Case-insensitive text matched against name, category, URL, notes, and custom fields."),
+						"status":            enumSchema("Subscription status.", []string{"active", "ended"}),
+						"renewal_mode":      enumSchema("Renewal mode.", []string{"auto_renew", "manual_renew", "cancel_at_period_end"}),
+						"currency":          stringSchema("Currency code, such as USD or CNY."),
+						"category_id":       idSchema("Category ID."),
+						"payment_method_id": idSchema("Payment method ID."),
+					}, nil),
+					"update": objectSchema(map[string]interface{}{
+						"category_id":        nullableIntegerSchema("Category ID. Use null to clear."),
+						"payment_method_id":  nullableIntegerSchema("Payment method ID. Use null to clear."),
+						"notify_enabled":     nullableBoolSchema("Notification override. Use null for default policy."),
+						"notify_days_before": nullableIntegerRangeSchema("Notification lead time, 0-10 days.", 0, 10),
+						"renewal_mode":       enumSchema("Renewal mode.", []string{"auto_renew", "manual_renew", "cancel_at_period_end"}),
+					}, nil),
+				}, []string{"idempotency_key", "action"})
+			},
+			Write: true,
+			Handler: func(ctx context.Context, h *MCPHandler, principal *mcpPrincipal, args map[string]interface{}) (*mcpToolResult, *mcpError) {
+				return h.callBulkUpdateSubscriptions(ctx, principal, args)
+			},
+		},
 		{
 			Name:        "get_dashboard_summary",
 			Title:       "Get Dashboard Summary",
@@ -167,7 +204,7 @@ func mcpToolDefinitions() []mcpToolDefinition {
 func (d mcpToolDefinition) sdkTool() *mcp.Tool {
 	annotations := readOnlySDKToolAnnotation()
 	if d.Write {
-		annotations = writeSDKToolAnnotation(d.Name == "delete_subscription")
+		annotations = writeSDKToolAnnotation(d.Name == "delete_subscription" || d.Name == "bulk_update_subscriptions")
 	}
 	return &mcp.Tool{
 		Name:        d.Name,
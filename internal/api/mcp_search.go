@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/shiroha/subdux/internal/model"
+	"github.com/shiroha/subdux/internal/service"
 )
 
 type mcpSubscriptionSearchFilters struct {
@@ -182,6 +183,7 @@ func mcpSubscriptionSearchText(sub model.Subscription, categoryLabels map[uint]s
 		sub.RecurrenceType,
 		sub.URL,
 		sub.Notes,
+		service.SubscriptionCustomFieldSearchText(sub),
 	}, " "))
 }
 
@@ -34,6 +34,11 @@ func newMCPTestDB(t *testing.T) *gorm.DB {
 		&model.APIKey{},
 		&model.Subscription{},
 		&model.SubscriptionEvent{},
+		&model.SubscriptionPriceChange{},
+		&model.SubscriptionUsage{},
+		&model.CustomFieldDefinition{},
+		&model.SubscriptionCustomFieldValue{},
+		&model.SubscriptionAttachment{},
 		&model.SubscriptionActionSnooze{},
 		&model.Category{},
 		&model.PaymentMethod{},
@@ -41,6 +46,7 @@ func newMCPTestDB(t *testing.T) *gorm.DB {
 		&model.UserPreference{},
 		&model.ExchangeRate{},
 		&model.AuditEvent{},
+		&model.AuditChainCheckpoint{},
 		&model.MCPIdempotencyKey{},
 	); err != nil {
 		t.Fatalf("failed to migrate test database: %v", err)
@@ -421,6 +427,7 @@ func TestMCPToolDefinitionsBuildDispatchableTools(t *testing.T) {
 		"update_subscription",
 		"delete_subscription",
 		"mark_subscription_renewed",
+		"bulk_update_subscriptions",
 		"get_dashboard_summary",
 		"list_categories",
 		"list_payment_methods",
@@ -466,7 +473,7 @@ func TestMCPToolDefinitionsBuildDispatchableTools(t *testing.T) {
 		if tool.InputSchema == nil {
 			t.Fatalf("%s built tool has nil input schema", tool.Name)
 		}
-		wantDestructive := definition.Name == "delete_subscription"
+		wantDestructive := definition.Name == "delete_subscription" || definition.Name == "bulk_update_subscriptions"
 		if got := tool.Annotations.DestructiveHint; got == nil || *got != wantDestructive {
 			t.Fatalf("%s destructiveHint = %v, want %v", tool.Name, got, wantDestructive)
 		}
@@ -1957,3 +1964,75 @@ func decodeStructuredID(t *testing.T, result *mcpToolResult) uint {
 		return 0
 	}
 }
+
+func TestMCPBulkUpdateSubscriptionsRollsBackWithItemErrors(t *testing.T) {
+	db := newMCPTestDB(t)
+	user := createMCPTestUser(t, db)
+	handler := newMCPTestHandler(db)
+	principal := &mcpPrincipal{
+		UserID:  user.ID,
+		KeyID:   7,
+		KeyKind: service.APIKeyKindMCPClient,
+		Scopes:  []string{service.APIKeyScopeRead, service.APIKeyScopeWrite},
+	}
+
+	intervalCount := 1
+	sub, err := service.NewSubscriptionService(db).Create(user.ID, service.CreateSubscriptionInput{
+		Name:            "Bulk",
+		Amount:          10,
+		Currency:        "USD",
+		RecurrenceType:  "interval",
+		IntervalCount:   &intervalCount,
+		IntervalUnit:    "month",
+		NextBillingDate: "2026-06-15",
+	})
+	if err != nil {
+		t.Fatalf("failed to create subscription: %v", err)
+	}
+
+	failed, rpcErr := handler.callBulkUpdateSubscriptions(context.Background(), principal, map[string]interface{}{
+		"idempotency_key": "bulk-rollback-1",
+		"action":          "update",
+		"ids":             []interface{}{float64(sub.ID), float64(9999)},
+		"update":          map[string]interface{}{"notify_enabled": false},
+	})
+	if rpcErr != nil {
+		t.Fatalf("callBulkUpdateSubscriptions() rpcErr = %v", rpcErr)
+	}
+	if failed == nil || !failed.IsError || !strings.Contains(failed.Content[0].Text, "rolled_back") {
+		t.Fatalf("result = %#v, want rolled back item results", failed)
+	}
+	reloaded, err := service.NewSubscriptionService(db).GetByID(user.ID, sub.ID)
+	if err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+	if reloaded.NotifyEnabled != nil {
+		t.Fatalf("notify_enabled = %v, want unchanged after rollback", *reloaded.NotifyEnabled)
+	}
+
+	result, rpcErr := handler.callBulkUpdateSubscriptions(context.Background(), principal, map[string]interface{}{
+		"idempotency_key": "bulk-update-1",
+		"action":          "update",
+		"filter":          map[string]interface{}{"query": "bulk"},
+		"update":          map[string]interface{}{"notify_enabled": false},
+	})
+	if rpcErr != nil || result == nil || result.IsError {
+		t.Fatalf("callBulkUpdateSubscriptions() = %#v, %v, want success", result, rpcErr)
+	}
+	reloaded, err = service.NewSubscriptionService(db).GetByID(user.ID, sub.ID)
+	if err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+	if reloaded.NotifyEnabled == nil || *reloaded.NotifyEnabled {
+		t.Fatalf("notify_enabled = %v, want false", reloaded.NotifyEnabled)
+	}
+
+	if _, rpcErr := handler.callBulkUpdateSubscriptions(context.Background(), principal, map[string]interface{}{
+		"idempotency_key": "bulk-update-2",
+		"action":          "update",
+		"ids":             []interface{}{float64(sub.ID)},
+		"update":          map[string]interface{}{"name": "renamed"},
+	}); rpcErr == nil {
+		t.Fatal("callBulkUpdateSubscriptions() with unknown update field rpcErr = nil")
+	}
+}
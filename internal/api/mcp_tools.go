@@ -235,6 +235,60 @@ func (h *MCPHandler) callMarkSubscriptionRenewed(ctx context.Context, principal
 	})
 }
 
+func (h *MCPHandler) callBulkUpdateSubscriptions(ctx context.Context, principal *mcpPrincipal, args map[string]interface{}) (*mcpToolResult, *mcpError) {
+	userID := principal.UserID
+	input, err := bulkSubscriptionInputFromMCPArgs(args)
+	if err != nil {
+		return nil, invalidMCPParams(err)
+	}
+
+	// A rolled-back batch still carries per-item results, which the generic
+	// write error mapping would drop.
+	var rolledBack *service.BulkSubscriptionResult
+	result, mcpErr := h.runIdempotentWrite(ctx, principal, args, mcpWriteSpec{
+		ToolName:     "bulk_update_subscriptions",
+		ResourceType: service.AuditResourceSubscription,
+		mutate: func(tx *gorm.DB) (*mcpWriteOutcome, error) {
+			bulk, err := service.NewSubscriptionService(tx).ApplyBulkRecords(userID, input)
+			if err != nil {
+				if errors.Is(err, service.ErrBulkSubscriptionRolledBack) {
+					rolledBack = bulk
+				}
+				return nil, err
+			}
+
+			// A batch has no single resource ID, so the affected IDs go in the
+			// audit snapshot instead.
+			ids := make([]uint, 0, len(bulk.Items))
+			for _, item := range bulk.Items {
+				ids = append(ids, item.ID)
+			}
+			outcome := &mcpWriteOutcome{
+				Result:        mcpStructuredResult(mapBulkSubscriptionResponse(bulk)),
+				Action:        "bulk_" + bulk.Action,
+				AfterSnapshot: map[string]interface{}{"action": bulk.Action, "ids": ids},
+			}
+			if len(bulk.Deleted) > 0 {
+				removed := bulk.Deleted
+				outcome.PostCommit = func() {
+					for _, sub := range removed {
+						h.subscriptions.CleanupDeletedSubscriptionResources(sub)
+					}
+				}
+			}
+			return outcome, nil
+		},
+	})
+	if rolledBack != nil {
+		resp := mapBulkSubscriptionResponse(rolledBack)
+		resp.Error = service.ErrBulkSubscriptionRolledBack.Error()
+		failed := mcpStructuredResult(resp)
+		failed.IsError = true
+		return failed, nil
+	}
+	return result, mcpErr
+}
+
 func auditSubscriptionSnapshot(sub model.Subscription, changedFields []string) map[string]interface{} {
 	snapshot := map[string]interface{}{
 		"id":                sub.ID,
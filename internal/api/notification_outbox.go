@@ -0,0 +1,265 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/shiroha/subdux/internal/model"
+	"github.com/shiroha/subdux/internal/service"
+)
+
+type notificationOutboxResponse struct {
+	ID             uint       `json:"id"`
+	UserID         uint       `json:"user_id"`
+	SubscriptionID *uint      `json:"subscription_id"`
+	ChannelID      *uint      `json:"channel_id"`
+	ChannelType    string     `json:"channel_type"`
+	TriggerType    string     `json:"trigger_type"`
+	NotifyDate     time.Time  `json:"notify_date"`
+	ScheduledFor   time.Time  `json:"scheduled_for"`
+	ExpiresAt      *time.Time `json:"expires_at"`
+	Status         string     `json:"status"`
+	AttemptCount   int        `json:"attempt_count"`
+	MaxAttempts    int        `json:"max_attempts"`
+	NextAttemptAt  time.Time  `json:"next_attempt_at"`
+	LastAttemptAt  *time.Time `json:"last_attempt_at"`
+	SentAt         *time.Time `json:"sent_at"`
+	LastError      string     `json:"last_error"`
+	Message        string     `json:"message"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+type notificationOutboxAttemptResponse struct {
+	ID        uint      `json:"id"`
+	Status    string    `json:"status"`
+	Error     string    `json:"error"`
+	Attempted time.Time `json:"attempted_at"`
+}
+
+type notificationOutboxDetailResponse struct {
+	notificationOutboxResponse
+	Attempts []notificationOutboxAttemptResponse `json:"attempts"`
+}
+
+func mapNotificationOutboxResponse(entry model.NotificationOutbox) notificationOutboxResponse {
+	return notificationOutboxResponse{
+		ID:             entry.ID,
+		UserID:         entry.UserID,
+		SubscriptionID: entry.SubscriptionID,
+		ChannelID:      entry.ChannelID,
+		ChannelType:    entry.ChannelType,
+		TriggerType:    entry.TriggerType,
+		NotifyDate:     entry.NotifyDate,
+		ScheduledFor:   entry.ScheduledFor,
+		ExpiresAt:      entry.ExpiresAt,
+		Status:         entry.Status,
+		AttemptCount:   entry.AttemptCount,
+		MaxAttempts:    entry.MaxAttempts,
+		NextAttemptAt:  entry.NextAttemptAt,
+		LastAttemptAt:  entry.LastAttemptAt,
+		SentAt:         entry.SentAt,
+		LastError:      entry.LastError,
+		Message:        entry.Message,
+		CreatedAt:      entry.CreatedAt,
+		UpdatedAt:      entry.UpdatedAt,
+	}
+}
+
+func mapNotificationOutboxResponses(entries []model.NotificationOutbox) []notificationOutboxResponse {
+	responses := make([]notificationOutboxResponse, len(entries))
+	for i, entry := range entries {
+		responses[i] = mapNotificationOutboxResponse(entry)
+	}
+	return responses
+}
+
+func mapNotificationOutboxDetailResponse(detail service.NotificationOutboxDetail) notificationOutboxDetailResponse {
+	attempts := make([]notificationOutboxAttemptResponse, len(detail.Attempts))
+	for i, attempt := range detail.Attempts {
+		attempts[i] = notificationOutboxAttemptResponse{
+			ID:        attempt.ID,
+			Status:    attempt.Status,
+			Error:     attempt.Error,
+			Attempted: attempt.SentAt,
+		}
+	}
+	return notificationOutboxDetailResponse{
+		notificationOutboxResponse: mapNotificationOutboxResponse(detail.Entry),
+		Attempts:                   attempts,
+	}
+}
+
+func (h *NotificationHandler) ListOutbox(c echo.Context) error {
+	userID := getUserID(c)
+	return h.listOutbox(c, &userID)
+}
+
+func (h *NotificationHandler) GetOutboxEntry(c echo.Context) error {
+	userID := getUserID(c)
+	return h.getOutboxEntry(c, &userID)
+}
+
+func (h *NotificationHandler) RetryOutboxEntry(c echo.Context) error {
+	userID := getUserID(c)
+	return h.retryOutboxEntry(c, &userID)
+}
+
+func (h *NotificationHandler) CancelOutboxEntry(c echo.Context) error {
+	userID := getUserID(c)
+	return h.cancelOutboxEntry(c, &userID, "cancelled by user")
+}
+
+func (h *NotificationHandler) PurgeOutbox(c echo.Context) error {
+	userID := getUserID(c)
+	return h.purgeOutbox(c, &userID)
+}
+
+func (h *NotificationHandler) AdminListOutbox(c echo.Context) error {
+	userID, ok := parseOptionalUintQuery(c, "user_id")
+	if !ok {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid user_id"})
+	}
+	return h.listOutbox(c, userID)
+}
+
+func (h *NotificationHandler) AdminGetOutboxEntry(c echo.Context) error {
+	return h.getOutboxEntry(c, nil)
+}
+
+func (h *NotificationHandler) AdminRetryOutboxEntry(c echo.Context) error {
+	return h.retryOutboxEntry(c, nil)
+}
+
+func (h *NotificationHandler) AdminCancelOutboxEntry(c echo.Context) error {
+	return h.cancelOutboxEntry(c, nil, "cancelled by administrator")
+}
+
+func (h *NotificationHandler) AdminPurgeOutbox(c echo.Context) error {
+	userID, ok := parseOptionalUintQuery(c, "user_id")
+	if !ok {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid user_id"})
+	}
+	return h.purgeOutbox(c, userID)
+}
+
+func (h *NotificationHandler) listOutbox(c echo.Context, userID *uint) error {
+	subscriptionID, ok := parseOptionalUintQuery(c, "subscription_id")
+	if !ok {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid subscription_id"})
+	}
+	before, ok := parseOptionalTimeQuery(c, "before")
+	if !ok {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "before must be an RFC 3339 timestamp"})
+	}
+	limit, _ := strconv.Atoi(strings.TrimSpace(c.QueryParam("limit")))
+
+	entries, err := h.Service.WithContext(c.Request().Context()).ListOutbox(service.NotificationOutboxFilter{
+		UserID:         userID,
+		Status:         c.QueryParam("status"),
+		ChannelType:    c.QueryParam("channel_type"),
+		TriggerType:    c.QueryParam("trigger_type"),
+		SubscriptionID: subscriptionID,
+		Before:         before,
+		Limit:          limit,
+	})
+	if err != nil {
+		return writeNotificationOutboxError(c, err)
+	}
+	return c.JSON(http.StatusOK, mapNotificationOutboxResponses(entries))
+}
+
+func (h *NotificationHandler) getOutboxEntry(c echo.Context, userID *uint) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid id"})
+	}
+	detail, err := h.Service.WithContext(c.Request().Context()).GetOutboxEntry(userID, uint(id))
+	if err != nil {
+		return writeNotificationOutboxError(c, err)
+	}
+	return c.JSON(http.StatusOK, mapNotificationOutboxDetailResponse(*detail))
+}
+
+func (h *NotificationHandler) retryOutboxEntry(c echo.Context, userID *uint) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid id"})
+	}
+	entry, err := h.Service.WithContext(c.Request().Context()).RetryOutboxEntry(userID, uint(id))
+	if err != nil {
+		return writeNotificationOutboxError(c, err)
+	}
+	return c.JSON(http.StatusOK, mapNotificationOutboxResponse(*entry))
+}
+
+func (h *NotificationHandler) cancelOutboxEntry(c echo.Context, userID *uint, reason string) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid id"})
+	}
+	entry, err := h.Service.WithContext(c.Request().Context()).CancelOutboxEntry(userID, uint(id), reason)
+	if err != nil {
+		return writeNotificationOutboxError(c, err)
+	}
+	return c.JSON(http.StatusOK, mapNotificationOutboxResponse(*entry))
+}
+
+func (h *NotificationHandler) purgeOutbox(c echo.Context, userID *uint) error {
+	before, ok := parseOptionalTimeQuery(c, "before")
+	if !ok {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "before must be an RFC 3339 timestamp"})
+	}
+	purged, err := h.Service.WithContext(c.Request().Context()).PurgeOutbox(service.NotificationOutboxPurgeFilter{
+		UserID: userID,
+		Status: c.QueryParam("status"),
+		Before: before,
+	})
+	if err != nil {
+		return writeNotificationOutboxError(c, err)
+	}
+	return c.JSON(http.StatusOK, echo.Map{"purged": purged})
+}
+
+func writeNotificationOutboxError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, service.ErrNotificationOutboxNotFound):
+		return c.JSON(http.StatusNotFound, echo.Map{"error": err.Error()})
+	case errors.Is(err, service.ErrNotificationOutboxNotRetryable),
+		errors.Is(err, service.ErrNotificationOutboxNotCancellable):
+		return c.JSON(http.StatusConflict, echo.Map{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidNotificationOutboxStatus):
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	default:
+		return writeInternalServerError(c, err)
+	}
+}
+
+func parseOptionalUintQuery(c echo.Context, name string) (*uint, bool) {
+	raw := strings.TrimSpace(c.QueryParam(name))
+	if raw == "" {
+		return nil, true
+	}
+	value, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil || value == 0 {
+		return nil, false
+	}
+	parsed := uint(value)
+	return &parsed, true
+}
+
+func parseOptionalTimeQuery(c echo.Context, name string) (*time.Time, bool) {
+	raw := strings.TrimSpace(c.QueryParam(name))
+	if raw == "" {
+		return nil, true
+	}
+	parsed, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return nil, false
+	}
+	return &parsed, true
+}
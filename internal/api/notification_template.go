@@ -1,6 +1,7 @@
 package api
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -51,7 +52,7 @@ func (h *NotificationTemplateHandler) CreateTemplate(c echo.Context) error {
 
 	template, err := h.Service.WithContext(c.Request().Context()).CreateTemplate(userID, input)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+		return c.JSON(http.StatusBadRequest, templateErrorResponse(err))
 	}
 	return c.JSON(http.StatusCreated, template)
 }
@@ -73,7 +74,7 @@ func (h *NotificationTemplateHandler) UpdateTemplate(c echo.Context) error {
 		if err.Error() == "template not found" {
 			return c.JSON(http.StatusNotFound, echo.Map{"error": err.Error()})
 		}
-		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+		return c.JSON(http.StatusBadRequest, templateErrorResponse(err))
 	}
 	return c.JSON(http.StatusOK, template)
 }
@@ -103,7 +104,18 @@ func (h *NotificationTemplateHandler) PreviewTemplate(c echo.Context) error {
 
 	preview, err := h.Service.WithContext(c.Request().Context()).PreviewTemplate(userID, input)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+		return c.JSON(http.StatusBadRequest, templateErrorResponse(err))
 	}
 	return c.JSON(http.StatusOK, echo.Map{"preview": preview})
 }
+
+// templateErrorResponse adds the line and column of template syntax errors so
+// the editor can highlight the offending action.
+func templateErrorResponse(err error) echo.Map {
+	response := echo.Map{"error": err.Error()}
+	var syntaxErr *service.TemplateSyntaxError
+	if errors.As(err, &syntaxErr) {
+		response["position"] = syntaxErr.Position
+	}
+	return response
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/labstack/echo/v4"
 	"github.com/shiroha/subdux/internal/model"
@@ -21,6 +22,10 @@ type paymentMethodResponse struct {
 	NameCustomized bool    `json:"name_customized"`
 	Icon           string  `json:"icon"`
 	SortOrder      int     `json:"sort_order"`
+	ExpiryMonth    *int    `json:"expiry_month"`
+	ExpiryYear     *int    `json:"expiry_year"`
+	LastFour       string  `json:"last_four"`
+	Issuer         string  `json:"issuer"`
 }
 
 func mapPaymentMethodResponse(method model.PaymentMethod) paymentMethodResponse {
@@ -31,6 +36,10 @@ func mapPaymentMethodResponse(method model.PaymentMethod) paymentMethodResponse
 		NameCustomized: method.NameCustomized,
 		Icon:           method.Icon,
 		SortOrder:      method.SortOrder,
+		ExpiryMonth:    method.ExpiryMonth,
+		ExpiryYear:     method.ExpiryYear,
+		LastFour:       method.LastFour,
+		Issuer:         method.Issuer,
 	}
 }
 
@@ -74,7 +83,7 @@ func (h *PaymentMethodHandler) Create(c echo.Context) error {
 		if err.Error() == "payment method name already exists" {
 			return c.JSON(http.StatusConflict, echo.Map{"error": err.Error()})
 		}
-		if err.Error() == "name must be 1-50 characters" {
+		if isPaymentMethodBadRequestError(err) {
 			return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
 		}
 		return writeInternalServerError(c, err)
@@ -106,7 +115,7 @@ func (h *PaymentMethodHandler) Update(c echo.Context) error {
 		if err.Error() == "payment method name already exists" {
 			return c.JSON(http.StatusConflict, echo.Map{"error": err.Error()})
 		}
-		if err.Error() == "name must be 1-50 characters" {
+		if isPaymentMethodBadRequestError(err) {
 			return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
 		}
 		return writeInternalServerError(c, err)
@@ -134,6 +143,39 @@ func (h *PaymentMethodHandler) Delete(c echo.Context) error {
 	return c.NoContent(http.StatusNoContent)
 }
 
+// Replace moves every subscription on the payment method to replacement_id,
+// for example when a card is reissued, and optionally deletes the old one.
+func (h *PaymentMethodHandler) Replace(c echo.Context) error {
+	userID := getUserID(c)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid id"})
+	}
+
+	var input service.ReplacePaymentMethodInput
+	if err := c.Bind(&input); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid request body"})
+	}
+
+	result, err := h.Service.WithContext(c.Request().Context()).Replace(userID, uint(id), input)
+	if err != nil {
+		if err.Error() == "payment method not found" {
+			return c.JSON(http.StatusNotFound, echo.Map{"error": err.Error()})
+		}
+		if err.Error() == "replacement payment method not found" || isPaymentMethodBadRequestError(err) {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+		}
+		return writeInternalServerError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"from":                   mapPaymentMethodResponse(result.From),
+		"to":                     mapPaymentMethodResponse(result.To),
+		"moved_subscription_ids": result.MovedSubscriptionIDs,
+		"deleted_old":            result.DeletedOld,
+	})
+}
+
 func (h *PaymentMethodHandler) Reorder(c echo.Context) error {
 	userID := getUserID(c)
 	var items []service.ReorderItem
@@ -180,3 +222,8 @@ func (h *PaymentMethodHandler) UploadIcon(c echo.Context) error {
 
 	return c.JSON(http.StatusOK, echo.Map{"icon": iconPath})
 }
+
+func isPaymentMethodBadRequestError(err error) bool {
+	message := err.Error()
+	return strings.Contains(message, "must be") || strings.Contains(message, "required")
+}
@@ -38,7 +38,7 @@ func writeReauthError(c echo.Context, err error) error {
 // validateReauthOperation extracts and validates the operation identifier.
 func validateReauthOperation(operation string) (string, error) {
 	switch operation {
-	case service.ReauthOperationBackup, service.ReauthOperationRestore:
+	case service.ReauthOperationBackup, service.ReauthOperationRestore, service.ReauthOperationSCIMToken:
 		return operation, nil
 	default:
 		return "", service.ErrInvalidReauthOperation
@@ -138,6 +138,7 @@ func (h *ReauthHandler) FinishPasskey(c echo.Context) error {
 
 type reauthOIDCStartInput struct {
 	Operation string `json:"operation"`
+	Provider  string `json:"provider"`
 }
 
 // BeginOIDC starts an OIDC step-up for the operation and returns the provider
@@ -153,7 +154,7 @@ func (h *ReauthHandler) BeginOIDC(c echo.Context) error {
 		return writeReauthError(c, err)
 	}
 
-	result, err := h.Service.WithContext(c.Request().Context()).BeginOIDC(getUserID(c), operation)
+	result, err := h.Service.WithContext(c.Request().Context()).BeginOIDC(getUserID(c), operation, input.Provider)
 	if err != nil {
 		return writeReauthError(c, err)
 	}
@@ -130,14 +130,19 @@ func SetupRoutes(
 	erService := service.NewExchangeRateService(db)
 	currencyService := service.NewCurrencyService(db)
 	categoryService := service.NewCategoryService(db)
+	customFieldService := service.NewCustomFieldService(db)
 	paymentMethodService := service.NewPaymentMethodService(db)
+	userPreferenceService := service.NewUserPreferenceService(db)
 	validator := service.NewTemplateValidator()
 	renderer := service.NewTemplateRenderer(validator)
 	templateService := service.NewNotificationTemplateService(db, validator)
 	notificationService := service.NewNotificationService(db, templateService, renderer)
+	notificationService.SetCurrencyConverter(erService)
 	apiKeyService := service.NewAPIKeyService(db)
 	auditService := service.NewAuditService(db)
+	scimService := service.NewSCIMService(db)
 	calendarService := service.NewCalendarService(db)
+	calendarService.SetCurrencyConverter(erService)
 	exportService := service.NewExportService(db)
 	importService := service.NewImportService(db)
 	if err := systemSettingsService.SeedDefaults(); err != nil {
@@ -151,8 +156,10 @@ func SetupRoutes(
 	siteInfoHandler := NewSiteInfoHandler(systemSettingsService)
 	iconProxyHandler := NewIconProxyHandler(iconProxyService)
 	erHandler := NewExchangeRateHandler(erService)
+	userPreferenceHandler := NewUserPreferenceHandler(userPreferenceService)
 	currencyHandler := NewCurrencyHandler(currencyService, erService)
 	categoryHandler := NewCategoryHandler(categoryService)
+	customFieldHandler := NewCustomFieldHandler(customFieldService)
 	paymentMethodHandler := NewPaymentMethodHandler(paymentMethodService)
 	dashboardBootstrapHandler := NewDashboardBootstrapHandler(subService, erService, currencyService, categoryService, paymentMethodService)
 	notificationHandler := NewNotificationHandler(notificationService)
@@ -171,14 +178,41 @@ func SetupRoutes(
 	e.PATCH("/mcp", mcpHandler.MethodNotAllowed, requireMCPEnabled)
 	e.DELETE("/mcp", mcpHandler.MethodNotAllowed, requireMCPEnabled)
 
+	scimHandler := NewSCIMHandler(scimService, auditService, reauthService)
+	scim := e.Group(scimBasePath)
+	scim.Use(requestBodyLimitMiddleware(1<<20, nil))
+	scim.Use(SCIMAuthMiddleware(scimService))
+	scim.GET("/ServiceProviderConfig", scimHandler.ServiceProviderConfig)
+	scim.GET("/ResourceTypes", scimHandler.ResourceTypes)
+	scim.GET("/Users", scimHandler.ListUsers)
+	scim.POST("/Users", scimHandler.CreateUser)
+	scim.GET("/Users/:id", scimHandler.GetUser)
+	scim.PUT("/Users/:id", scimHandler.ReplaceUser)
+	scim.PATCH("/Users/:id", scimHandler.PatchUser)
+	scim.DELETE("/Users/:id", scimHandler.DeleteUser)
+	scim.GET("/Groups", scimHandler.ListGroups)
+	scim.POST("/Groups", scimHandler.GroupNotSupported)
+	scim.GET("/Groups/:id", scimHandler.GetGroup)
+	scim.PUT("/Groups/:id", scimHandler.ReplaceGroup)
+	scim.PATCH("/Groups/:id", scimHandler.PatchGroup)
+	scim.DELETE("/Groups/:id", scimHandler.GroupNotSupported)
+
+	calDAVHandler := NewCalDAVHandler(calendarService, apiKeyService)
+	calDAVMethods := append(append([]string{}, calDAVReadMethods...), calDAVWriteMethods...)
+	e.Match(calDAVMethods, calDAVBasePath, calDAVHandler.Handle)
+	e.Match(calDAVMethods, calDAVBasePath+"/*", calDAVHandler.Handle)
+	e.Match([]string{http.MethodGet, http.MethodHead, echo.PROPFIND}, "/.well-known/caldav", calDAVHandler.RedirectWellKnown)
+
 	api := e.Group("/api")
 	api.Use(requestBodyLimitMiddleware(1<<20, func(c echo.Context) bool {
 		path := c.Path()
 		if path == "" {
 			path = c.Request().URL.Path
 		}
-		return path == "/api/admin/restore" || path == "/api/import/wallos" || path == "/api/import/subdux"
+		return path == "/api/admin/restore" || path == "/api/import/wallos" || path == "/api/import/subdux" ||
+			(path == "/api/subscriptions/:id/attachments" && c.Request().Method == http.MethodPost)
 	}))
+	api.Use(sessionClientMiddleware)
 
 	authIPLimiter := authIPRateLimit(30, time.Minute)
 	loginAccountLimiter := authAccountRateLimit(10, time.Minute, loginAccountKey)
@@ -225,12 +259,16 @@ func SetupRoutes(
 
 	api.GET("/icon-proxy/:provider", iconProxyHandler.Get, iconProxyLimiter)
 
+	auditREST := RESTAuditMiddleware(auditService)
+
 	auth := api.Group("/auth")
 	auth.Use(requestBodyLimitMiddleware(maxAuthRequestBodyBytes, nil))
+	auth.Use(auditREST)
 	auth.GET("/register/config", authHandler.GetRegistrationConfig)
 	auth.POST("/register/send-code", authHandler.SendRegisterVerificationCode, authIPLimiter, registerAccountLimiter)
 	auth.POST("/register", authHandler.Register, authIPLimiter, registerAccountLimiter)
 	auth.POST("/login", authHandler.Login, authIPLimiter, loginAccountLimiter)
+	auth.POST("/proxy/login", authHandler.ProxyLogin, authIPLimiter)
 	auth.POST("/password/forgot", authHandler.ForgotPassword, authIPLimiter, passwordAccountLimiter)
 	auth.POST("/password/reset", authHandler.ResetPassword, authIPLimiter, passwordAccountLimiter)
 	auth.POST("/totp/verify-login", authHandler.VerifyTOTPLogin, authIPLimiter, totpAccountLimiter)
@@ -253,29 +291,48 @@ func SetupRoutes(
 	protected := api.Group("")
 	protected.Use(JWTOrAPIKeyMiddleware(jwtConfig, apiKeyService))
 	protected.Use(APIKeyScopeMiddleware)
+	protected.Use(auditREST)
 
 	humanProtected := api.Group("")
 	humanProtected.Use(JWTOrAPIKeyMiddleware(jwtConfig, apiKeyService))
 	humanProtected.Use(HumanSessionOnlyMiddleware)
 	humanProtected.Use(APIKeyScopeMiddleware)
+	humanProtected.Use(auditREST)
 
 	protected.GET("/subscriptions", subHandler.List)
 	protected.POST("/subscriptions", subHandler.Create)
+	protected.POST("/subscriptions/bulk", subHandler.Bulk)
 	protected.GET("/subscriptions/:id/detail", subHandler.GetDetail)
 	protected.GET("/subscriptions/:id", subHandler.GetByID)
 	protected.PUT("/subscriptions/:id", subHandler.Update)
 	protected.DELETE("/subscriptions/:id", subHandler.Delete)
 	protected.POST("/subscriptions/:id/mark-renewed", subHandler.MarkRenewed)
+	protected.POST("/subscriptions/:id/check-in", subHandler.CheckIn)
+	protected.GET("/subscriptions/:id/usage", subHandler.UsageHistory)
 	protected.POST("/subscriptions/reconcile", subHandler.Reconcile)
 	protected.POST("/subscriptions/:id/icon", subHandler.UploadIcon)
+	protected.GET("/subscriptions/:id/price-changes", subHandler.ListPriceChanges)
+	protected.POST("/subscriptions/:id/price-changes", subHandler.SchedulePriceChange)
+	protected.DELETE("/subscriptions/:id/price-changes/:changeId", subHandler.CancelPriceChange)
+	protected.GET("/subscriptions/:id/attachments", subHandler.ListAttachments)
+	protected.POST("/subscriptions/:id/attachments", subHandler.UploadAttachment, requestBodyLimitMiddleware(maxAttachmentRequestBodyBytes, nil))
+	protected.PUT("/subscriptions/:id/attachments/:attachmentId", subHandler.UpdateAttachment)
+	protected.GET("/subscriptions/:id/attachments/:attachmentId/file", subHandler.DownloadAttachment)
+	protected.DELETE("/subscriptions/:id/attachments/:attachmentId", subHandler.DeleteAttachment)
 	protected.GET("/dashboard/summary", subHandler.Dashboard)
 	protected.GET("/dashboard/bootstrap", dashboardBootstrapHandler.Get)
 	protected.GET("/actions", subHandler.ActionCenter)
 	protected.POST("/actions/snooze", subHandler.SnoozeAction)
 	protected.GET("/reports/analytics", subHandler.AnalyticsReport)
+	protected.GET("/reports/tax-summary", subHandler.TaxSummary)
+	protected.GET("/reports/savings", subHandler.SavingsReport)
+	protected.GET("/reports/spend-history", subHandler.SpendHistory)
 
 	protected.GET("/auth/me", authHandler.Me)
 	humanProtected.PUT("/auth/password", authHandler.ChangePassword)
+	humanProtected.GET("/auth/sessions", authHandler.ListSessions)
+	humanProtected.POST("/auth/sessions/revoke-others", authHandler.RevokeOtherSessions)
+	humanProtected.DELETE("/auth/sessions/:id", authHandler.RevokeSession)
 	humanProtected.POST("/auth/email/change/send-code", authHandler.SendEmailChangeVerificationCode)
 	humanProtected.POST("/auth/email/change/confirm", authHandler.ConfirmEmailChange)
 	humanProtected.GET("/auth/totp/setup", authHandler.SetupTOTP)
@@ -288,22 +345,41 @@ func SetupRoutes(
 	humanProtected.GET("/auth/oidc/connections", authHandler.ListOIDCConnections)
 	humanProtected.POST("/auth/oidc/connect/start", authHandler.BeginOIDCConnect)
 	humanProtected.DELETE("/auth/oidc/connections/:id", authHandler.DeleteOIDCConnection)
+	humanProtected.GET("/subscriptions/:id/custom-fields/:key/secret", subHandler.RevealCustomFieldSecret)
 	admin := api.Group("/admin")
 
 	admin.Use(echojwt.WithConfig(jwtConfig))
 	admin.Use(AdminMiddleware)
+	admin.Use(auditREST)
 
 	admin.GET("/users", adminHandler.ListUsers)
 	admin.POST("/users", adminHandler.CreateUser)
 	admin.PUT("/users/:id/role", adminHandler.ChangeUserRole)
 	admin.PUT("/users/:id/status", adminHandler.ChangeUserStatus)
+	admin.POST("/users/:id/sessions/revoke", adminHandler.RevokeUserSessions)
+	admin.POST("/users/:id/unlock", adminHandler.UnlockUser)
 	admin.DELETE("/users/:id", adminHandler.DeleteUser)
 	admin.GET("/background-tasks", adminHandler.ListBackgroundTasks)
+	admin.GET("/oidc/providers", adminHandler.ListOIDCProviders)
+	admin.POST("/oidc/providers", adminHandler.CreateOIDCProvider)
+	admin.PUT("/oidc/providers/:id", adminHandler.UpdateOIDCProvider)
+	admin.DELETE("/oidc/providers/:id", adminHandler.DeleteOIDCProvider)
+	admin.GET("/scim/token", scimHandler.GetToken)
+	admin.POST("/scim/token", scimHandler.IssueToken)
+	admin.DELETE("/scim/token", scimHandler.RevokeToken)
 	admin.GET("/audit-events", auditHandler.ListAdminEvents)
+	admin.GET("/audit-events/export", auditHandler.ExportAdminEvents)
+	admin.GET("/audit-events/verify", auditHandler.VerifyAdminChain)
+	admin.GET("/notifications/outbox", notificationHandler.AdminListOutbox)
+	admin.DELETE("/notifications/outbox", notificationHandler.AdminPurgeOutbox)
+	admin.GET("/notifications/outbox/:id", notificationHandler.AdminGetOutboxEntry)
+	admin.POST("/notifications/outbox/:id/retry", notificationHandler.AdminRetryOutboxEntry)
+	admin.POST("/notifications/outbox/:id/cancel", notificationHandler.AdminCancelOutboxEntry)
 	admin.GET("/settings", adminHandler.GetSettings)
 	admin.PUT("/settings", adminHandler.UpdateSettings)
 	admin.POST("/settings/ssrf/test", adminHandler.TestSSRF)
 	admin.POST("/settings/smtp/test", adminHandler.TestSMTP)
+	admin.POST("/settings/ldap/test", adminHandler.TestLDAP)
 	admin.POST("/backup", adminHandler.BackupDB)
 	admin.POST("/backup/run", adminHandler.RunBackupNow)
 	admin.GET("/backup/local", adminHandler.ListLocalBackups)
@@ -321,6 +397,8 @@ func SetupRoutes(
 	protected.GET("/exchange-rates/:base/:target", erHandler.GetRate)
 	protected.GET("/preferences/currency", erHandler.GetPreference)
 	protected.PUT("/preferences/currency", erHandler.UpdatePreference)
+	protected.GET("/preferences/locale", userPreferenceHandler.GetLocalePreference)
+	protected.PUT("/preferences/locale", userPreferenceHandler.UpdateLocalePreference)
 
 	protected.GET("/currencies", currencyHandler.List)
 	protected.POST("/currencies", currencyHandler.Create)
@@ -334,11 +412,18 @@ func SetupRoutes(
 	protected.PUT("/categories/:id", categoryHandler.Update)
 	protected.DELETE("/categories/:id", categoryHandler.Delete)
 
+	protected.GET("/custom-fields", customFieldHandler.List)
+	protected.POST("/custom-fields", customFieldHandler.Create)
+	protected.PUT("/custom-fields/reorder", customFieldHandler.Reorder)
+	protected.PUT("/custom-fields/:id", customFieldHandler.Update)
+	protected.DELETE("/custom-fields/:id", customFieldHandler.Delete)
+
 	protected.GET("/payment-methods", paymentMethodHandler.List)
 	protected.POST("/payment-methods", paymentMethodHandler.Create)
 	protected.PUT("/payment-methods/reorder", paymentMethodHandler.Reorder)
 	protected.PUT("/payment-methods/:id", paymentMethodHandler.Update)
 	protected.DELETE("/payment-methods/:id", paymentMethodHandler.Delete)
+	protected.POST("/payment-methods/:id/replace", paymentMethodHandler.Replace)
 	protected.POST("/payment-methods/:id/icon", paymentMethodHandler.UploadIcon)
 
 	protected.GET("/notifications/channels", notificationHandler.ListChannels)
@@ -349,6 +434,11 @@ func SetupRoutes(
 	protected.GET("/notifications/policy", notificationHandler.GetPolicy)
 	protected.PUT("/notifications/policy", notificationHandler.UpdatePolicy)
 	protected.GET("/notifications/logs", notificationHandler.ListLogs)
+	protected.GET("/notifications/outbox", notificationHandler.ListOutbox)
+	protected.DELETE("/notifications/outbox", notificationHandler.PurgeOutbox)
+	protected.GET("/notifications/outbox/:id", notificationHandler.GetOutboxEntry)
+	protected.POST("/notifications/outbox/:id/retry", notificationHandler.RetryOutboxEntry)
+	protected.POST("/notifications/outbox/:id/cancel", notificationHandler.CancelOutboxEntry)
 	protected.GET("/notifications/templates", templateHandler.ListTemplates)
 	protected.GET("/notifications/templates/:id", templateHandler.GetTemplate)
 	protected.POST("/notifications/templates", templateHandler.CreateTemplate)
@@ -360,9 +450,11 @@ func SetupRoutes(
 	humanProtected.POST("/api-keys", apiKeyHandler.Create)
 	humanProtected.DELETE("/api-keys/:id", apiKeyHandler.Delete)
 	humanProtected.GET("/audit-events", auditHandler.ListUserEvents)
+	humanProtected.GET("/audit-events/export", auditHandler.ExportUserEvents)
 
 	humanProtected.GET("/calendar/tokens", calendarHandler.ListTokens)
 	humanProtected.POST("/calendar/tokens", calendarHandler.CreateToken)
+	humanProtected.PUT("/calendar/tokens/:id", calendarHandler.UpdateTokenFilter)
 	humanProtected.DELETE("/calendar/tokens/:id", calendarHandler.DeleteToken)
 
 	humanProtected.GET("/export", exportHandler.Export)
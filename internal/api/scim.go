@@ -0,0 +1,607 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/shiroha/subdux/internal/pkg/logging"
+	"github.com/shiroha/subdux/internal/service"
+)
+
+const (
+	scimBasePath          = "/scim/v2"
+	scimContentType       = "application/scim+json"
+	scimBaseURLContextKey = "scim_base_url"
+
+	scimUserSchema                  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	scimGroupSchema                 = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	scimListResponseSchema          = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	scimErrorSchema                 = "urn:ietf:params:scim:api:messages:2.0:Error"
+	scimServiceProviderConfigSchema = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	scimResourceTypeSchema          = "urn:ietf:params:scim:schemas:core:2.0:ResourceType"
+)
+
+type SCIMHandler struct {
+	Service *service.SCIMService
+	Audit   *service.AuditService
+	Reauth  *service.ReauthService
+}
+
+func NewSCIMHandler(s *service.SCIMService, audit *service.AuditService, reauth *service.ReauthService) *SCIMHandler {
+	return &SCIMHandler{Service: s, Audit: audit, Reauth: reauth}
+}
+
+type scimMeta struct {
+	ResourceType string     `json:"resourceType"`
+	Created      *time.Time `json:"created,omitempty"`
+	LastModified *time.Time `json:"lastModified,omitempty"`
+	Location     string     `json:"location"`
+}
+
+type scimGroupRef struct {
+	Value   string `json:"value"`
+	Ref     string `json:"$ref"`
+	Display string `json:"display"`
+}
+
+type scimUserResource struct {
+	Schemas     []string            `json:"schemas"`
+	ID          string              `json:"id"`
+	ExternalID  string              `json:"externalId,omitempty"`
+	UserName    string              `json:"userName"`
+	DisplayName string              `json:"displayName"`
+	Emails      []service.SCIMEmail `json:"emails"`
+	Active      bool                `json:"active"`
+	Groups      []scimGroupRef      `json:"groups"`
+	Meta        scimMeta            `json:"meta"`
+}
+
+type scimMemberRef struct {
+	Value   string `json:"value"`
+	Ref     string `json:"$ref"`
+	Display string `json:"display"`
+}
+
+type scimGroupResource struct {
+	Schemas     []string        `json:"schemas"`
+	ID          string          `json:"id"`
+	DisplayName string          `json:"displayName"`
+	Members     []scimMemberRef `json:"members"`
+	Meta        scimMeta        `json:"meta"`
+}
+
+type scimListResponse struct {
+	Schemas      []string    `json:"schemas"`
+	TotalResults int64       `json:"totalResults"`
+	StartIndex   int         `json:"startIndex"`
+	ItemsPerPage int         `json:"itemsPerPage"`
+	Resources    interface{} `json:"Resources"`
+}
+
+type scimUserRequest struct {
+	UserName   string              `json:"userName"`
+	ExternalID string              `json:"externalId"`
+	Emails     []service.SCIMEmail `json:"emails"`
+	Active     *bool               `json:"active"`
+	Password   string              `json:"password"`
+}
+
+type scimPatchRequest struct {
+	Operations []service.SCIMPatchOperation `json:"Operations"`
+}
+
+type scimGroupRequest struct {
+	Members []service.SCIMMember `json:"members"`
+}
+
+// SCIMAuthMiddleware authenticates the identity provider by the provisioning
+// bearer token that an administrator issued.
+func SCIMAuthMiddleware(scim *service.SCIMService) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			header := c.Request().Header.Get(echo.HeaderAuthorization)
+			token, ok := strings.CutPrefix(header, "Bearer ")
+			if !ok {
+				token = ""
+			}
+			if err := scim.WithContext(c.Request().Context()).ValidateToken(token); err != nil {
+				if !errors.Is(err, service.ErrSCIMUnauthorized) {
+					return writeSCIMError(c, err)
+				}
+				c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer realm="subdux-scim"`)
+				return writeSCIMStatus(c, http.StatusUnauthorized, "", "invalid or missing bearer token")
+			}
+			return next(c)
+		}
+	}
+}
+
+func (h *SCIMHandler) ServiceProviderConfig(c echo.Context) error {
+	return writeSCIM(c, http.StatusOK, echo.Map{
+		"schemas":        []string{scimServiceProviderConfigSchema},
+		"patch":          echo.Map{"supported": true},
+		"bulk":           echo.Map{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         echo.Map{"supported": true, "maxResults": service.SCIMMaxPageSize},
+		"changePassword": echo.Map{"supported": true},
+		"sort":           echo.Map{"supported": false},
+		"etag":           echo.Map{"supported": false},
+		"authenticationSchemes": []echo.Map{{
+			"type":        "oauthbearertoken",
+			"name":        "Bearer token",
+			"description": "Provisioning token issued by a Subdux administrator",
+			"primary":     true,
+		}},
+		"meta": scimMeta{ResourceType: "ServiceProviderConfig", Location: h.location(c, "/ServiceProviderConfig")},
+	})
+}
+
+func (h *SCIMHandler) ResourceTypes(c echo.Context) error {
+	resources := []echo.Map{
+		{
+			"schemas":  []string{scimResourceTypeSchema},
+			"id":       "User",
+			"name":     "User",
+			"endpoint": "/Users",
+			"schema":   scimUserSchema,
+			"meta":     scimMeta{ResourceType: "ResourceType", Location: h.location(c, "/ResourceTypes/User")},
+		},
+		{
+			"schemas":  []string{scimResourceTypeSchema},
+			"id":       "Group",
+			"name":     "Group",
+			"endpoint": "/Groups",
+			"schema":   scimGroupSchema,
+			"meta":     scimMeta{ResourceType: "ResourceType", Location: h.location(c, "/ResourceTypes/Group")},
+		},
+	}
+	return writeSCIM(c, http.StatusOK, scimListResponse{
+		Schemas:      []string{scimListResponseSchema},
+		TotalResults: int64(len(resources)),
+		StartIndex:   1,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	})
+}
+
+func (h *SCIMHandler) ListUsers(c echo.Context) error {
+	options, err := parseSCIMListOptions(c)
+	if err != nil {
+		return writeSCIMError(c, err)
+	}
+	users, total, err := h.Service.WithContext(c.Request().Context()).ListUsers(options)
+	if err != nil {
+		return writeSCIMError(c, err)
+	}
+	resources := make([]scimUserResource, len(users))
+	for i := range users {
+		resources[i] = h.userResource(c, &users[i])
+	}
+	return writeSCIM(c, http.StatusOK, scimListResponse{
+		Schemas:      []string{scimListResponseSchema},
+		TotalResults: total,
+		StartIndex:   options.StartIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	})
+}
+
+func (h *SCIMHandler) GetUser(c echo.Context) error {
+	userID, ok := parseSCIMID(c)
+	if !ok {
+		return writeSCIMError(c, service.ErrSCIMNotFound)
+	}
+	user, err := h.Service.WithContext(c.Request().Context()).GetUser(userID)
+	if err != nil {
+		return writeSCIMError(c, err)
+	}
+	return writeSCIM(c, http.StatusOK, h.userResource(c, user))
+}
+
+func (h *SCIMHandler) CreateUser(c echo.Context) error {
+	var req scimUserRequest
+	if err := decodeSCIMBody(c, &req); err != nil {
+		return writeSCIMError(c, err)
+	}
+	user, err := h.Service.WithContext(c.Request().Context()).CreateUser(req.input())
+	var userID uint
+	if user != nil {
+		userID = user.ID
+	}
+	h.recordUserAudit(c, "create", userID, nil, err)
+	if err != nil {
+		return writeSCIMError(c, err)
+	}
+	c.Response().Header().Set(echo.HeaderLocation, h.location(c, "/Users/"+strconv.FormatUint(uint64(user.ID), 10)))
+	return writeSCIM(c, http.StatusCreated, h.userResource(c, user))
+}
+
+func (h *SCIMHandler) ReplaceUser(c echo.Context) error {
+	userID, ok := parseSCIMID(c)
+	if !ok {
+		return writeSCIMError(c, service.ErrSCIMNotFound)
+	}
+	var req scimUserRequest
+	if err := decodeSCIMBody(c, &req); err != nil {
+		return writeSCIMError(c, err)
+	}
+	svc := h.Service.WithContext(c.Request().Context())
+	before := h.userSnapshot(c, userID)
+	user, err := svc.ReplaceUser(userID, req.input())
+	h.recordUserAudit(c, "update", userID, before, err)
+	if err != nil {
+		return writeSCIMError(c, err)
+	}
+	return writeSCIM(c, http.StatusOK, h.userResource(c, user))
+}
+
+func (h *SCIMHandler) PatchUser(c echo.Context) error {
+	userID, ok := parseSCIMID(c)
+	if !ok {
+		return writeSCIMError(c, service.ErrSCIMNotFound)
+	}
+	var req scimPatchRequest
+	if err := decodeSCIMBody(c, &req); err != nil {
+		return writeSCIMError(c, err)
+	}
+	svc := h.Service.WithContext(c.Request().Context())
+	before := h.userSnapshot(c, userID)
+	user, err := svc.PatchUser(userID, req.Operations)
+	h.recordUserAudit(c, "update", userID, before, err)
+	if err != nil {
+		return writeSCIMError(c, err)
+	}
+	return writeSCIM(c, http.StatusOK, h.userResource(c, user))
+}
+
+func (h *SCIMHandler) DeleteUser(c echo.Context) error {
+	userID, ok := parseSCIMID(c)
+	if !ok {
+		return writeSCIMError(c, service.ErrSCIMNotFound)
+	}
+	before := h.userSnapshot(c, userID)
+	err := h.Service.WithContext(c.Request().Context()).DeleteUser(userID)
+	h.recordUserAudit(c, "delete", userID, before, err)
+	if err != nil {
+		return writeSCIMError(c, err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *SCIMHandler) ListGroups(c echo.Context) error {
+	options, err := parseSCIMListOptions(c)
+	if err != nil {
+		return writeSCIMError(c, err)
+	}
+	groups, err := h.Service.WithContext(c.Request().Context()).ListGroups(options.Filter)
+	if err != nil {
+		return writeSCIMError(c, err)
+	}
+	resources := []scimGroupResource{}
+	if options.StartIndex == 1 && options.Count > 0 {
+		for _, group := range groups {
+			resources = append(resources, h.groupResource(c, &group))
+		}
+	}
+	return writeSCIM(c, http.StatusOK, scimListResponse{
+		Schemas:      []string{scimListResponseSchema},
+		TotalResults: int64(len(groups)),
+		StartIndex:   options.StartIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	})
+}
+
+func (h *SCIMHandler) GetGroup(c echo.Context) error {
+	group, err := h.Service.WithContext(c.Request().Context()).GetGroup(c.Param("id"))
+	if err != nil {
+		return writeSCIMError(c, err)
+	}
+	return writeSCIM(c, http.StatusOK, h.groupResource(c, group))
+}
+
+func (h *SCIMHandler) ReplaceGroup(c echo.Context) error {
+	var req scimGroupRequest
+	if err := decodeSCIMBody(c, &req); err != nil {
+		return writeSCIMError(c, err)
+	}
+	memberIDs, err := service.ParseSCIMMemberIDs(req.Members)
+	if err != nil {
+		return writeSCIMError(c, err)
+	}
+	svc := h.Service.WithContext(c.Request().Context())
+	before := h.groupSnapshot(c)
+	group, err := svc.ReplaceGroupMembers(c.Param("id"), memberIDs)
+	h.recordGroupAudit(c, before, err)
+	if err != nil {
+		return writeSCIMError(c, err)
+	}
+	return writeSCIM(c, http.StatusOK, h.groupResource(c, group))
+}
+
+func (h *SCIMHandler) PatchGroup(c echo.Context) error {
+	var req scimPatchRequest
+	if err := decodeSCIMBody(c, &req); err != nil {
+		return writeSCIMError(c, err)
+	}
+	svc := h.Service.WithContext(c.Request().Context())
+	before := h.groupSnapshot(c)
+	group, err := svc.PatchGroup(c.Param("id"), req.Operations)
+	h.recordGroupAudit(c, before, err)
+	if err != nil {
+		return writeSCIMError(c, err)
+	}
+	return writeSCIM(c, http.StatusOK, h.groupResource(c, group))
+}
+
+// GroupNotSupported answers group creation and deletion: the admin group is
+// the only group and it is fixed.
+func (h *SCIMHandler) GroupNotSupported(c echo.Context) error {
+	return writeSCIMStatus(c, http.StatusNotImplemented, "", "groups cannot be created or deleted; use the "+service.SCIMAdminGroupID+" group")
+}
+
+func (r scimUserRequest) input() service.SCIMUserInput {
+	return service.SCIMUserInput{
+		UserName:   r.UserName,
+		Email:      service.PrimarySCIMEmail(r.Emails),
+		Active:     r.Active,
+		ExternalID: r.ExternalID,
+		Password:   r.Password,
+	}
+}
+
+// location builds an absolute resource URL from the configured site URL,
+// falling back to the request host. The base is resolved once per request.
+func (h *SCIMHandler) location(c echo.Context, path string) string {
+	base, ok := c.Get(scimBaseURLContextKey).(string)
+	if !ok {
+		base = h.Service.WithContext(c.Request().Context()).BaseURL()
+		if base == "" {
+			base = c.Scheme() + "://" + c.Request().Host
+		}
+		c.Set(scimBaseURLContextKey, base)
+	}
+	return base + scimBasePath + path
+}
+
+func (h *SCIMHandler) userResource(c echo.Context, user *service.SCIMUser) scimUserResource {
+	id := strconv.FormatUint(uint64(user.ID), 10)
+	created, updated := user.CreatedAt.UTC(), user.UpdatedAt.UTC()
+	resource := scimUserResource{
+		Schemas:     []string{scimUserSchema},
+		ID:          id,
+		ExternalID:  user.ExternalID,
+		UserName:    user.Username,
+		DisplayName: user.Username,
+		Emails:      []service.SCIMEmail{{Value: user.Email, Type: "work", Primary: true}},
+		Active:      user.Status == "active",
+		Groups:      []scimGroupRef{},
+		Meta: scimMeta{
+			ResourceType: "User",
+			Created:      &created,
+			LastModified: &updated,
+			Location:     h.location(c, "/Users/"+id),
+		},
+	}
+	if user.Role == "admin" {
+		resource.Groups = append(resource.Groups, scimGroupRef{
+			Value:   service.SCIMAdminGroupID,
+			Ref:     h.location(c, "/Groups/"+service.SCIMAdminGroupID),
+			Display: service.SCIMAdminGroupDisplayName,
+		})
+	}
+	return resource
+}
+
+func (h *SCIMHandler) groupResource(c echo.Context, group *service.SCIMGroup) scimGroupResource {
+	members := make([]scimMemberRef, len(group.Members))
+	for i, user := range group.Members {
+		id := strconv.FormatUint(uint64(user.ID), 10)
+		members[i] = scimMemberRef{Value: id, Ref: h.location(c, "/Users/"+id), Display: user.Username}
+	}
+	return scimGroupResource{
+		Schemas:     []string{scimGroupSchema},
+		ID:          group.ID,
+		DisplayName: group.DisplayName,
+		Members:     members,
+		Meta:        scimMeta{ResourceType: "Group", Location: h.location(c, "/Groups/"+group.ID)},
+	}
+}
+
+func (h *SCIMHandler) userSnapshot(c echo.Context, userID uint) interface{} {
+	return h.Audit.WithContext(c.Request().Context()).ResourceSnapshot(service.AuditResourceUser, nil, userID)
+}
+
+func (h *SCIMHandler) groupSnapshot(c echo.Context) interface{} {
+	group, err := h.Service.WithContext(c.Request().Context()).GetGroup(service.SCIMAdminGroupID)
+	if err != nil {
+		return nil
+	}
+	members := make([]uint, len(group.Members))
+	for i, user := range group.Members {
+		members[i] = user.ID
+	}
+	return echo.Map{"members": members}
+}
+
+func (h *SCIMHandler) recordUserAudit(c echo.Context, action string, userID uint, before interface{}, opErr error) {
+	var after interface{}
+	if opErr == nil && action != "delete" {
+		after = h.userSnapshot(c, userID)
+	}
+	h.recordAudit(c, service.AuditResourceUser, action, strconv.FormatUint(uint64(userID), 10), before, after, opErr)
+}
+
+func (h *SCIMHandler) recordGroupAudit(c echo.Context, before interface{}, opErr error) {
+	var after interface{}
+	if opErr == nil {
+		after = h.groupSnapshot(c)
+	}
+	h.recordAudit(c, service.AuditResourceGroup, "update", c.Param("id"), before, after, opErr)
+}
+
+// recordAudit stores a provisioning change. The acting principal is the
+// identity provider, so events are not attributed to a user.
+func (h *SCIMHandler) recordAudit(c echo.Context, resource, action, resourceID string, before, after interface{}, opErr error) {
+	req := c.Request()
+	audit := h.Audit.WithContext(req.Context())
+	if enabled, err := audit.IsEnabled(); err != nil || !enabled {
+		return
+	}
+	if resourceID == "0" {
+		resourceID = ""
+	}
+	input := service.CreateAuditEventInput{
+		KeyKind:        service.AuditKeyKindSCIMToken,
+		Transport:      service.AuditTransportSCIM,
+		ToolName:       req.Method + " " + c.Path(),
+		ResourceType:   resource,
+		ResourceID:     resourceID,
+		Action:         action,
+		Status:         service.AuditStatusSuccess,
+		ClientName:     req.UserAgent(),
+		RequestID:      logging.RequestIDFromContext(req.Context()),
+		BeforeSnapshot: before,
+		AfterSnapshot:  after,
+	}
+	if opErr != nil {
+		input.Status = service.AuditStatusError
+		input.Error = opErr.Error()
+	}
+	if _, err := audit.Create(input); err != nil {
+		logging.FromContext(req.Context()).Error("failed to record audit event",
+			slog.String("route", input.ToolName), slog.Any("error", err))
+	}
+}
+
+func parseSCIMID(c echo.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func parseSCIMListOptions(c echo.Context) (service.SCIMListOptions, error) {
+	options := service.SCIMListOptions{
+		Filter:     c.QueryParam("filter"),
+		StartIndex: 1,
+		Count:      service.SCIMDefaultPageSize,
+	}
+	if raw := strings.TrimSpace(c.QueryParam("startIndex")); raw != "" {
+		value, err := strconv.Atoi(raw)
+		if err != nil {
+			return options, fmt.Errorf("%w: startIndex must be an integer", service.ErrSCIMInvalidValue)
+		}
+		options.StartIndex = max(value, 1)
+	}
+	if raw := strings.TrimSpace(c.QueryParam("count")); raw != "" {
+		value, err := strconv.Atoi(raw)
+		if err != nil {
+			return options, fmt.Errorf("%w: count must be an integer", service.ErrSCIMInvalidValue)
+		}
+		options.Count = min(max(value, 0), service.SCIMMaxPageSize)
+	}
+	return options, nil
+}
+
+// decodeSCIMBody reads a JSON request body. SCIM clients send
+// application/scim+json, which Echo's binder does not accept.
+func decodeSCIMBody(c echo.Context, target interface{}) error {
+	if err := json.NewDecoder(c.Request().Body).Decode(target); err != nil {
+		return fmt.Errorf("%w: request body must be a JSON object", service.ErrSCIMInvalidValue)
+	}
+	return nil
+}
+
+func writeSCIM(c echo.Context, status int, body interface{}) error {
+	out, err := json.Marshal(body)
+	if err != nil {
+		return writeSCIMStatus(c, http.StatusInternalServerError, "", "failed to encode response")
+	}
+	return c.Blob(status, scimContentType, out)
+}
+
+func writeSCIMStatus(c echo.Context, status int, scimType, detail string) error {
+	body := echo.Map{
+		"schemas": []string{scimErrorSchema},
+		"status":  strconv.Itoa(status),
+		"detail":  detail,
+	}
+	if scimType != "" {
+		body["scimType"] = scimType
+	}
+	out, _ := json.Marshal(body)
+	return c.Blob(status, scimContentType, out)
+}
+
+func writeSCIMError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, service.ErrSCIMNotFound):
+		return writeSCIMStatus(c, http.StatusNotFound, "", "resource not found")
+	case errors.Is(err, service.ErrSCIMUniqueness):
+		return writeSCIMStatus(c, http.StatusConflict, "uniqueness", err.Error())
+	case errors.Is(err, service.ErrSCIMInvalidFilter):
+		return writeSCIMStatus(c, http.StatusBadRequest, "invalidFilter", err.Error())
+	case errors.Is(err, service.ErrSCIMInvalidPath):
+		return writeSCIMStatus(c, http.StatusBadRequest, "invalidPath", err.Error())
+	case errors.Is(err, service.ErrSCIMMutability):
+		return writeSCIMStatus(c, http.StatusBadRequest, "mutability", err.Error())
+	case errors.Is(err, service.ErrSCIMInvalidValue):
+		return writeSCIMStatus(c, http.StatusBadRequest, "invalidValue", err.Error())
+	}
+	logging.FromContext(c.Request().Context()).Error("scim request failed", slog.Any("error", err))
+	if isTransientSQLiteBusyError(err) {
+		c.Response().Header().Set("Retry-After", "1")
+		return writeSCIMStatus(c, http.StatusServiceUnavailable, "", "database is busy, retry later")
+	}
+	return writeSCIMStatus(c, http.StatusInternalServerError, "", "internal server error")
+}
+
+// SCIM token administration
+
+func (h *SCIMHandler) GetToken(c echo.Context) error {
+	status, err := h.Service.WithContext(c.Request().Context()).TokenStatus()
+	if err != nil {
+		return writeInternalServerError(c, err)
+	}
+	return c.JSON(http.StatusOK, status)
+}
+
+// IssueToken replaces the provisioning token. Because the token can create
+// administrators, issuing one requires a fresh re-authentication.
+func (h *SCIMHandler) IssueToken(c echo.Context) error {
+	var input struct {
+		ReauthTicket string `json:"reauth_ticket"`
+	}
+	if err := c.Bind(&input); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid request body"})
+	}
+	if err := h.Reauth.WithContext(c.Request().Context()).Consume(getUserID(c), service.ReauthOperationSCIMToken, input.ReauthTicket); err != nil {
+		return writeReauthError(c, err)
+	}
+
+	svc := h.Service.WithContext(c.Request().Context())
+	token, err := svc.IssueToken()
+	if err != nil {
+		return writeInternalServerError(c, err)
+	}
+	status, err := svc.TokenStatus()
+	if err != nil {
+		return writeInternalServerError(c, err)
+	}
+	return c.JSON(http.StatusCreated, echo.Map{"token": token, "status": status})
+}
+
+func (h *SCIMHandler) RevokeToken(c echo.Context) error {
+	if err := h.Service.WithContext(c.Request().Context()).RevokeToken(); err != nil {
+		return writeInternalServerError(c, err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/shiroha/subdux/internal/model"
+	"github.com/shiroha/subdux/internal/service"
+	"gorm.io/gorm"
+)
+
+func newSCIMTestServer(t *testing.T) (*gorm.DB, string) {
+	t.Helper()
+
+	db := newHumanOnlyRouteTestDB(t)
+	if err := db.AutoMigrate(&model.SCIMIdentity{}); err != nil {
+		t.Fatalf("failed to migrate scim identities: %v", err)
+	}
+	createHumanOnlyRouteTestUser(t, db)
+	token, err := service.NewSCIMService(db).IssueToken()
+	if err != nil {
+		t.Fatalf("IssueToken() error = %v", err)
+	}
+	return db, token
+}
+
+func serveSCIMRequest(t *testing.T, db *gorm.DB, token, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	e := newHumanOnlyRouteTestServer(t, db)
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", scimContentType)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestSCIMRejectsMissingOrWrongToken(t *testing.T) {
+	db, _ := newSCIMTestServer(t)
+
+	for _, token := range []string{"", "scim_wrong"} {
+		rec := serveSCIMRequest(t, db, token, http.MethodGet, "/scim/v2/Users", "")
+		if rec.Code != http.StatusUnauthorized {
+			t.Fatalf("token %q status = %d, want 401", token, rec.Code)
+		}
+		if !strings.Contains(rec.Body.String(), scimErrorSchema) {
+			t.Fatalf("body = %s, want SCIM error", rec.Body.String())
+		}
+	}
+}
+
+func TestSCIMProvisionsAndDeprovisionsUser(t *testing.T) {
+	db, token := newSCIMTestServer(t)
+
+	rec := serveSCIMRequest(t, db, token, http.MethodPost, "/scim/v2/Users", `{
+		"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+		"userName": "erin",
+		"externalId": "00u-erin",
+		"name": {"givenName": "Erin"},
+		"emails": [{"value": "erin@example.com", "type": "work", "primary": true}],
+		"active": true
+	}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("Content-Type"); got != scimContentType {
+		t.Fatalf("Content-Type = %q, want %q", got, scimContentType)
+	}
+	var created scimUserResource
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatalf("failed to decode user: %v", err)
+	}
+	if created.UserName != "erin" || created.ExternalID != "00u-erin" || !created.Active || !strings.HasSuffix(created.Meta.Location, "/scim/v2/Users/"+created.ID) {
+		t.Fatalf("created user = %+v", created)
+	}
+
+	rec = serveSCIMRequest(t, db, token, http.MethodPost, "/scim/v2/Users", `{"userName":"erin","emails":[{"value":"other@example.com"}]}`)
+	if rec.Code != http.StatusConflict || !strings.Contains(rec.Body.String(), `"scimType":"uniqueness"`) {
+		t.Fatalf("duplicate status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+
+	rec = serveSCIMRequest(t, db, token, http.MethodGet, `/scim/v2/Users?filter=userName+eq+%22ERIN%22`, "")
+	var list struct {
+		TotalResults int                `json:"totalResults"`
+		Resources    []scimUserResource `json:"Resources"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("filter status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	if list.TotalResults != 1 || list.Resources[0].ID != created.ID {
+		t.Fatalf("filter result = %+v, want erin", list)
+	}
+
+	rec = serveSCIMRequest(t, db, token, http.MethodPatch, "/scim/v2/Users/"+created.ID, `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+		"Operations": [{"op": "replace", "path": "active", "value": false}]
+	}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("patch status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	var user model.User
+	if err := db.Where("username = ?", "erin").First(&user).Error; err != nil {
+		t.Fatalf("failed to load user: %v", err)
+	}
+	if user.Status != "disabled" {
+		t.Fatalf("status = %q, want disabled", user.Status)
+	}
+
+	var events []model.AuditEvent
+	if err := db.Where("transport = ?", service.AuditTransportSCIM).Order("sequence ASC").Find(&events).Error; err != nil {
+		t.Fatalf("failed to list audit events: %v", err)
+	}
+	if len(events) != 3 || events[2].Action != "update" || events[2].KeyKind != service.AuditKeyKindSCIMToken || events[2].ResourceID != created.ID {
+		t.Fatalf("audit events = %+v, want create, failed create and update", events)
+	}
+
+	rec = serveSCIMRequest(t, db, token, http.MethodDelete, "/scim/v2/Users/"+created.ID, "")
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("delete status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	if rec := serveSCIMRequest(t, db, token, http.MethodGet, "/scim/v2/Users/"+created.ID, ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("get after delete status = %d, want 404", rec.Code)
+	}
+}
+
+func TestSCIMAdminTokenRequiresReauth(t *testing.T) {
+	db := newHumanOnlyRouteTestDB(t)
+	admin := model.User{Username: "scim-admin", Email: "scim-admin@example.com", Password: "hashed-password", Role: "admin", Status: "active"}
+	if err := db.Create(&admin).Error; err != nil {
+		t.Fatalf("failed to create admin: %v", err)
+	}
+
+	rec := serveRESTAuditRequest(t, db, &admin, http.MethodPost, "/api/admin/scim/token", `{}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("issue without reauth status = %d, want 400", rec.Code)
+	}
+	rec = serveRESTAuditRequest(t, db, &admin, http.MethodGet, "/api/admin/scim/token", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"enabled":false`) {
+		t.Fatalf("token status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+}
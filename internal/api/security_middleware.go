@@ -302,6 +302,9 @@ func APIKeyScopeMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
 		if hasAPIKeyScope(c, requiredScope) {
 			return next(c)
 		}
+		if requiredScope == service.APIKeyScopeUsage && hasAPIKeyScope(c, service.APIKeyScopeWrite) {
+			return next(c)
+		}
 
 		return c.JSON(http.StatusForbidden, echo.Map{"error": "api key does not have required scope"})
 	}
@@ -332,6 +335,11 @@ var writeScopeRoutes = map[string]struct{}{
 	"/api/auth/totp/setup": {},
 }
 
+// usageScopeRoutes accept a key with either the usage or the write scope.
+var usageScopeRoutes = map[string]struct{}{
+	"/api/subscriptions/:id/check-in": {},
+}
+
 func requiredAPIKeyScope(c echo.Context) string {
 	path := c.Path()
 	if path == "" {
@@ -341,6 +349,9 @@ func requiredAPIKeyScope(c echo.Context) string {
 	if _, ok := writeScopeRoutes[path]; ok {
 		return service.APIKeyScopeWrite
 	}
+	if _, ok := usageScopeRoutes[path]; ok && c.Request().Method == http.MethodPost {
+		return service.APIKeyScopeUsage
+	}
 
 	if isReadOnlyMethod(c.Request().Method) {
 		return service.APIKeyScopeRead
@@ -189,6 +189,16 @@ func TestRequiredAPIKeyScopeUsesReadForRegularGetRoute(t *testing.T) {
 	}
 }
 
+func TestRequiredAPIKeyScopeUsesUsageForCheckIn(t *testing.T) {
+	c := newSecurityMiddlewareTestContext(http.MethodPost, "/api/subscriptions/1/check-in", "", "")
+	c.SetPath("/api/subscriptions/:id/check-in")
+
+	got := requiredAPIKeyScope(c)
+	if got != service.APIKeyScopeUsage {
+		t.Fatalf("requiredAPIKeyScope() = %q, want %q", got, service.APIKeyScopeUsage)
+	}
+}
+
 func TestIsAPIKeyRouteAllowed(t *testing.T) {
 	tests := []struct {
 		path string
@@ -381,6 +391,11 @@ func TestHumanOnlyRoutesBlockAPIKeyPrincipal(t *testing.T) {
 			method: http.MethodPost,
 			target: "/api/calendar/tokens",
 		},
+		{
+			name:   "update calendar token filter",
+			method: http.MethodPut,
+			target: "/api/calendar/tokens/1",
+		},
 		{
 			name:   "delete calendar token",
 			method: http.MethodDelete,
@@ -571,6 +586,11 @@ func newHumanOnlyRouteTestDB(t *testing.T) *gorm.DB {
 		&model.PaymentMethod{},
 		&model.Subscription{},
 		&model.SubscriptionEvent{},
+		&model.SubscriptionPriceChange{},
+		&model.SubscriptionUsage{},
+		&model.CustomFieldDefinition{},
+		&model.SubscriptionCustomFieldValue{},
+		&model.SubscriptionAttachment{},
 		&model.SubscriptionActionSnooze{},
 		&model.NotificationChannel{},
 		&model.NotificationPolicy{},
@@ -582,6 +602,7 @@ func newHumanOnlyRouteTestDB(t *testing.T) *gorm.DB {
 		&model.EmailVerificationCode{},
 		&model.UserBackupCode{},
 		&model.AuditEvent{},
+		&model.AuditChainCheckpoint{},
 	); err != nil {
 		t.Fatalf("failed to migrate test database: %v", err)
 	}
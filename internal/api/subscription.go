@@ -9,6 +9,7 @@ import (
 
 	"github.com/labstack/echo/v4"
 	"github.com/shiroha/subdux/internal/model"
+	"github.com/shiroha/subdux/internal/pkg"
 	"github.com/shiroha/subdux/internal/service"
 	"gorm.io/gorm"
 )
@@ -23,31 +24,38 @@ func NewSubscriptionHandler(s *service.SubscriptionService, er *service.Exchange
 }
 
 type subscriptionResponse struct {
-	ID               uint      `json:"id"`
-	Name             string    `json:"name"`
-	Amount           float64   `json:"amount"`
-	Currency         string    `json:"currency"`
-	Status           string    `json:"status"`
-	RenewalMode      string    `json:"renewal_mode"`
-	EndsAt           *string   `json:"ends_at"`
-	BillingType      string    `json:"billing_type"`
-	RecurrenceType   string    `json:"recurrence_type"`
-	IntervalCount    *int      `json:"interval_count"`
-	IntervalUnit     string    `json:"interval_unit"`
-	MonthlyDay       *int      `json:"monthly_day"`
-	YearlyMonth      *int      `json:"yearly_month"`
-	YearlyDay        *int      `json:"yearly_day"`
-	NextBillingDate  *string   `json:"next_billing_date"`
-	Category         string    `json:"category"`
-	CategoryID       *uint     `json:"category_id"`
-	PaymentMethodID  *uint     `json:"payment_method_id"`
-	NotifyEnabled    *bool     `json:"notify_enabled"`
-	NotifyDaysBefore *int      `json:"notify_days_before"`
-	Icon             string    `json:"icon"`
-	URL              string    `json:"url"`
-	Notes            string    `json:"notes"`
-	CreatedAt        time.Time `json:"created_at"`
-	UpdatedAt        time.Time `json:"updated_at"`
+	ID               uint              `json:"id"`
+	Name             string            `json:"name"`
+	Amount           float64           `json:"amount"`
+	Currency         string            `json:"currency"`
+	TaxRate          *float64          `json:"tax_rate"`
+	TaxInclusive     bool              `json:"tax_inclusive"`
+	FXFee            float64           `json:"fx_fee"`
+	ProcessorFee     float64           `json:"processor_fee"`
+	AnnualPlanAmount *float64          `json:"annual_plan_amount"`
+	LastUsedAt       *string           `json:"last_used_at"`
+	Status           string            `json:"status"`
+	RenewalMode      string            `json:"renewal_mode"`
+	EndsAt           *string           `json:"ends_at"`
+	BillingType      string            `json:"billing_type"`
+	RecurrenceType   string            `json:"recurrence_type"`
+	IntervalCount    *int              `json:"interval_count"`
+	IntervalUnit     string            `json:"interval_unit"`
+	MonthlyDay       *int              `json:"monthly_day"`
+	YearlyMonth      *int              `json:"yearly_month"`
+	YearlyDay        *int              `json:"yearly_day"`
+	NextBillingDate  *string           `json:"next_billing_date"`
+	Category         string            `json:"category"`
+	CategoryID       *uint             `json:"category_id"`
+	PaymentMethodID  *uint             `json:"payment_method_id"`
+	NotifyEnabled    *bool             `json:"notify_enabled"`
+	NotifyDaysBefore *int              `json:"notify_days_before"`
+	Icon             string            `json:"icon"`
+	URL              string            `json:"url"`
+	Notes            string            `json:"notes"`
+	CustomFields     map[string]string `json:"custom_fields"`
+	CreatedAt        time.Time         `json:"created_at"`
+	UpdatedAt        time.Time         `json:"updated_at"`
 }
 
 type subscriptionDetailResponse struct {
@@ -57,14 +65,25 @@ type subscriptionDetailResponse struct {
 	NotificationLogs []service.SubscriptionDetailNotificationLog  `json:"notification_logs"`
 	UpcomingCharges  []service.SubscriptionDetailUpcomingCharge   `json:"upcoming_charges"`
 	Calendar         service.SubscriptionDetailCalendar           `json:"calendar"`
+	Usage            service.SubscriptionUsageStats               `json:"usage"`
 }
 
 func mapSubscriptionResponse(sub model.Subscription) subscriptionResponse {
+	customFields := sub.CustomFields
+	if customFields == nil {
+		customFields = map[string]string{}
+	}
 	return subscriptionResponse{
 		ID:               sub.ID,
 		Name:             sub.Name,
 		Amount:           sub.Amount,
 		Currency:         sub.Currency,
+		TaxRate:          sub.TaxRate,
+		TaxInclusive:     sub.TaxInclusive,
+		FXFee:            sub.FXFee,
+		ProcessorFee:     sub.ProcessorFee,
+		AnnualPlanAmount: sub.AnnualPlanAmount,
+		LastUsedAt:       formatDateOnly(sub.LastUsedAt),
 		Status:           sub.Status,
 		RenewalMode:      sub.RenewalMode,
 		EndsAt:           formatDateOnly(sub.EndsAt),
@@ -84,6 +103,7 @@ func mapSubscriptionResponse(sub model.Subscription) subscriptionResponse {
 		Icon:             sub.Icon,
 		URL:              sub.URL,
 		Notes:            sub.Notes,
+		CustomFields:     customFields,
 		CreatedAt:        sub.CreatedAt,
 		UpdatedAt:        sub.UpdatedAt,
 	}
@@ -97,6 +117,7 @@ func mapSubscriptionDetailResponse(detail service.SubscriptionDetail) subscripti
 		NotificationLogs: detail.NotificationLogs,
 		UpcomingCharges:  detail.UpcomingCharges,
 		Calendar:         detail.Calendar,
+		Usage:            detail.Usage,
 	}
 }
 
@@ -117,9 +138,11 @@ func mapSubscriptionResponses(subs []model.Subscription) []subscriptionResponse
 	return responses
 }
 
+// List returns all subscriptions, or with q only those whose name, category,
+// URL, notes or custom field values contain it.
 func (h *SubscriptionHandler) List(c echo.Context) error {
 	userID := getUserID(c)
-	subs, err := h.Service.WithContext(c.Request().Context()).List(userID)
+	subs, err := h.Service.WithContext(c.Request().Context()).Search(userID, c.QueryParam("q"))
 	if err != nil {
 		return writeInternalServerError(c, err)
 	}
@@ -251,6 +274,67 @@ func (h *SubscriptionHandler) MarkRenewed(c echo.Context) error {
 	return c.JSON(http.StatusOK, mapSubscriptionResponse(*sub))
 }
 
+// CheckIn logs a use of the subscription on used_at or today. Scripts can call
+// it with an API key holding the narrow "usage" scope.
+func (h *SubscriptionHandler) CheckIn(c echo.Context) error {
+	userID := getUserID(c)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid ID"})
+	}
+	var input service.CheckInSubscriptionInput
+	if err := c.Bind(&input); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid request body"})
+	}
+	input.Source = "manual"
+	if getAuthType(c) == pkg.AuthTypeAPIKey {
+		input.Source = "api"
+	}
+
+	sub, err := h.Service.WithContext(c.Request().Context()).CheckIn(userID, uint(id), input)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(http.StatusNotFound, echo.Map{"error": "Subscription not found"})
+		}
+		if isSubscriptionBadRequestError(err.Error()) {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+		}
+		return writeInternalServerError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, mapSubscriptionResponse(*sub))
+}
+
+// UsageHistory returns check-in counts per week or month (period) for the
+// last periods periods, with cost per use and the idle signal.
+func (h *SubscriptionHandler) UsageHistory(c echo.Context) error {
+	userID := getUserID(c)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid ID"})
+	}
+	periods := 0
+	if raw := strings.TrimSpace(c.QueryParam("periods")); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": service.ErrInvalidUsagePeriod.Error()})
+		}
+		periods = parsed
+	}
+
+	history, err := h.Service.WithContext(c.Request().Context()).GetUsageHistory(userID, uint(id), c.QueryParam("period"), periods)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidUsagePeriod) {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(http.StatusNotFound, echo.Map{"error": "Subscription not found"})
+		}
+		return writeInternalServerError(c, err)
+	}
+	return c.JSON(http.StatusOK, history)
+}
+
 // Reconcile persists any due lifecycle transitions for the caller's
 // subscriptions on demand, then returns the refreshed list. Reads advance
 // lifecycle in memory only; this endpoint is the explicit repair entry that
@@ -272,7 +356,11 @@ func (h *SubscriptionHandler) Reconcile(c echo.Context) error {
 
 func (h *SubscriptionHandler) ActionCenter(c echo.Context) error {
 	userID := getUserID(c)
-	center, err := h.Service.WithContext(c.Request().Context()).GetActionCenter(userID)
+	ctx := c.Request().Context()
+	erService := h.ERService.WithContext(ctx)
+
+	pref, _ := erService.GetUserPreference(userID)
+	center, err := h.Service.WithContext(ctx).GetActionCenter(userID, pref.PreferredCurrency, erService)
 	if err != nil {
 		return writeInternalServerError(c, err)
 	}
@@ -366,6 +454,79 @@ func (h *SubscriptionHandler) AnalyticsReport(c echo.Context) error {
 	return c.JSON(http.StatusOK, report)
 }
 
+// SavingsReport lists ways to spend less, valued in the preferred currency.
+// unused_days overrides how long a subscription may go without a check-in
+// before it counts as unused.
+func (h *SubscriptionHandler) SavingsReport(c echo.Context) error {
+	unusedDays := 0
+	if raw := strings.TrimSpace(c.QueryParam("unused_days")); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": service.ErrInvalidSavingsUnusedDays.Error()})
+		}
+		unusedDays = parsed
+	}
+
+	userID := getUserID(c)
+	ctx := c.Request().Context()
+	erService := h.ERService.WithContext(ctx)
+
+	pref, _ := erService.GetUserPreference(userID)
+	report, err := h.Service.WithContext(ctx).GetSavingsOpportunities(userID, pref.PreferredCurrency, erService, unusedDays)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidSavingsUnusedDays) {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+		}
+		return writeInternalServerError(c, err)
+	}
+	return c.JSON(http.StatusOK, report)
+}
+
+// RevealCustomFieldSecret returns the plaintext of a secret custom field, which
+// subscription responses only show masked.
+func (h *SubscriptionHandler) RevealCustomFieldSecret(c echo.Context) error {
+	userID := getUserID(c)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid ID"})
+	}
+
+	svc := h.Service.WithContext(c.Request().Context())
+	if _, err := svc.GetByID(userID, uint(id)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(http.StatusNotFound, echo.Map{"error": "Subscription not found"})
+		}
+		return writeInternalServerError(c, err)
+	}
+	value, err := svc.RevealCustomFieldSecret(userID, uint(id), c.Param("key"))
+	if err != nil {
+		if errors.Is(err, service.ErrCustomFieldNotFound) {
+			return c.JSON(http.StatusNotFound, echo.Map{"error": err.Error()})
+		}
+		return writeInternalServerError(c, err)
+	}
+	return c.JSON(http.StatusOK, echo.Map{"key": c.Param("key"), "value": value})
+}
+
+// SpendHistory reports what was billed per month between the from and to
+// months (YYYY-MM), in the preferred currency, with the previous year's
+// months alongside for comparison.
+func (h *SubscriptionHandler) SpendHistory(c echo.Context) error {
+	userID := getUserID(c)
+	ctx := c.Request().Context()
+	erService := h.ERService.WithContext(ctx)
+
+	pref, _ := erService.GetUserPreference(userID)
+	history, err := h.Service.WithContext(ctx).GetSpendHistory(userID, pref.PreferredCurrency, erService, c.QueryParam("from"), c.QueryParam("to"))
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidSpendHistoryRange) {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+		}
+		return writeInternalServerError(c, err)
+	}
+	return c.JSON(http.StatusOK, history)
+}
+
 func isSubscriptionBadRequestError(message string) bool {
 	if message == "payment method not found" || message == "category not found" {
 		return true
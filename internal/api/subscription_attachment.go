@@ -0,0 +1,156 @@
+package api
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/shiroha/subdux/internal/service"
+	"gorm.io/gorm"
+)
+
+// maxAttachmentRequestBodyBytes leaves room for multipart framing and the
+// period fields around the largest file an admin may allow.
+const maxAttachmentRequestBodyBytes = service.MaxAttachmentUploadBytes + 1<<20
+
+func (h *SubscriptionHandler) ListAttachments(c echo.Context) error {
+	userID := getUserID(c)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid ID"})
+	}
+
+	attachments, err := h.Service.WithContext(c.Request().Context()).ListAttachments(userID, uint(id))
+	if err != nil {
+		return writeAttachmentError(c, err)
+	}
+	return c.JSON(http.StatusOK, attachments)
+}
+
+func (h *SubscriptionHandler) UploadAttachment(c echo.Context) error {
+	userID := getUserID(c)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid ID"})
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "no file provided"})
+	}
+	src, err := fileHeader.Open()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{"error": "failed to read file"})
+	}
+	defer src.Close()
+
+	input := service.UploadAttachmentInput{
+		PeriodStart: c.FormValue("period_start"),
+		PeriodEnd:   c.FormValue("period_end"),
+	}
+	attachment, err := h.Service.WithContext(c.Request().Context()).UploadAttachment(userID, uint(id), src, fileHeader.Filename, input)
+	if err != nil {
+		return writeAttachmentError(c, err)
+	}
+	return c.JSON(http.StatusCreated, attachment)
+}
+
+func (h *SubscriptionHandler) UpdateAttachment(c echo.Context) error {
+	userID := getUserID(c)
+	id, attachmentID, ok := parseAttachmentParams(c)
+	if !ok {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid ID"})
+	}
+
+	var input service.UpdateAttachmentInput
+	if err := c.Bind(&input); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid request body"})
+	}
+	attachment, err := h.Service.WithContext(c.Request().Context()).UpdateAttachment(userID, id, attachmentID, input)
+	if err != nil {
+		return writeAttachmentError(c, err)
+	}
+	return c.JSON(http.StatusOK, attachment)
+}
+
+// DownloadAttachment streams an attachment to its owner. Files are never
+// cached by shared caches and are sandboxed like uploaded icons.
+func (h *SubscriptionHandler) DownloadAttachment(c echo.Context) error {
+	userID := getUserID(c)
+	id, attachmentID, ok := parseAttachmentParams(c)
+	if !ok {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid ID"})
+	}
+
+	attachment, file, err := h.Service.WithContext(c.Request().Context()).OpenAttachment(userID, id, attachmentID)
+	if err != nil {
+		return writeAttachmentError(c, err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return writeInternalServerError(c, err)
+	}
+	if !info.Mode().IsRegular() {
+		return c.JSON(http.StatusNotFound, echo.Map{"error": service.ErrAttachmentNotFound.Error()})
+	}
+
+	disposition := "inline"
+	if c.QueryParam("download") == "true" {
+		disposition = "attachment"
+	}
+	header := c.Response().Header()
+	header.Set(echo.HeaderContentType, attachment.ContentType)
+	header.Set(echo.HeaderXContentTypeOptions, "nosniff")
+	header.Set(echo.HeaderContentSecurityPolicy, "default-src 'none'; base-uri 'none'; form-action 'none'; sandbox")
+	header.Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": attachment.Filename}))
+	header.Set(echo.HeaderCacheControl, "private, no-store")
+
+	http.ServeContent(c.Response().Writer, c.Request(), attachment.Filename, info.ModTime(), file)
+	return nil
+}
+
+func (h *SubscriptionHandler) DeleteAttachment(c echo.Context) error {
+	userID := getUserID(c)
+	id, attachmentID, ok := parseAttachmentParams(c)
+	if !ok {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid ID"})
+	}
+
+	if err := h.Service.WithContext(c.Request().Context()).DeleteAttachment(userID, id, attachmentID); err != nil {
+		return writeAttachmentError(c, err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func parseAttachmentParams(c echo.Context) (uint, uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return 0, 0, false
+	}
+	attachmentID, err := strconv.ParseUint(c.Param("attachmentId"), 10, 32)
+	if err != nil {
+		return 0, 0, false
+	}
+	return uint(id), uint(attachmentID), true
+}
+
+func writeAttachmentError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.JSON(http.StatusNotFound, echo.Map{"error": "Subscription not found"})
+	case errors.Is(err, service.ErrAttachmentNotFound):
+		return c.JSON(http.StatusNotFound, echo.Map{"error": err.Error()})
+	case errors.Is(err, service.ErrAttachmentQuotaExceeded):
+		return c.JSON(http.StatusRequestEntityTooLarge, echo.Map{"error": err.Error()})
+	case errors.Is(err, service.ErrAttachmentUnsupportedType),
+		errors.Is(err, service.ErrAttachmentSizeLimit),
+		errors.Is(err, service.ErrAttachmentContentMismatch),
+		isSubscriptionBadRequestError(err.Error()):
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+	return writeInternalServerError(c, err)
+}
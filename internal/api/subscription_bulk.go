@@ -0,0 +1,71 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/shiroha/subdux/internal/service"
+)
+
+type bulkSubscriptionItemResponse struct {
+	ID           uint                  `json:"id"`
+	Status       string                `json:"status"`
+	Error        string                `json:"error,omitempty"`
+	Subscription *subscriptionResponse `json:"subscription,omitempty"`
+}
+
+type bulkSubscriptionResponse struct {
+	Error     string                         `json:"error,omitempty"`
+	Action    string                         `json:"action"`
+	Committed bool                           `json:"committed"`
+	Succeeded int                            `json:"succeeded"`
+	Failed    int                            `json:"failed"`
+	Items     []bulkSubscriptionItemResponse `json:"items"`
+}
+
+func mapBulkSubscriptionResponse(result *service.BulkSubscriptionResult) bulkSubscriptionResponse {
+	resp := bulkSubscriptionResponse{
+		Action:    result.Action,
+		Committed: result.Committed,
+		Succeeded: result.Succeeded,
+		Failed:    result.Failed,
+		Items:     make([]bulkSubscriptionItemResponse, 0, len(result.Items)),
+	}
+	for _, item := range result.Items {
+		mapped := bulkSubscriptionItemResponse{ID: item.ID, Status: item.Status, Error: item.Error}
+		if item.Subscription != nil {
+			sub := mapSubscriptionResponse(*item.Subscription)
+			mapped.Subscription = &sub
+		}
+		resp.Items = append(resp.Items, mapped)
+	}
+	return resp
+}
+
+// Bulk applies one action to a list of subscription IDs or to every
+// subscription matching a filter. The batch is all-or-nothing: if any item
+// fails, the response is 400 and carries the per-item results.
+func (h *SubscriptionHandler) Bulk(c echo.Context) error {
+	userID := getUserID(c)
+
+	var input service.BulkSubscriptionInput
+	if err := c.Bind(&input); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid request body"})
+	}
+
+	result, err := h.Service.WithContext(c.Request().Context()).ApplyBulk(userID, input)
+	if err != nil {
+		if errors.Is(err, service.ErrBulkSubscriptionRolledBack) && result != nil {
+			resp := mapBulkSubscriptionResponse(result)
+			resp.Error = err.Error()
+			return c.JSON(http.StatusBadRequest, resp)
+		}
+		if isSubscriptionBadRequestError(err.Error()) {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+		}
+		return writeInternalServerError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, mapBulkSubscriptionResponse(result))
+}
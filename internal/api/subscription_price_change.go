@@ -0,0 +1,78 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/shiroha/subdux/internal/service"
+	"gorm.io/gorm"
+)
+
+func (h *SubscriptionHandler) ListPriceChanges(c echo.Context) error {
+	userID := getUserID(c)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid ID"})
+	}
+
+	changes, err := h.Service.WithContext(c.Request().Context()).ListPriceChanges(userID, uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(http.StatusNotFound, echo.Map{"error": "Subscription not found"})
+		}
+		return writeInternalServerError(c, err)
+	}
+	return c.JSON(http.StatusOK, changes)
+}
+
+func (h *SubscriptionHandler) SchedulePriceChange(c echo.Context) error {
+	userID := getUserID(c)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid ID"})
+	}
+
+	var input service.SchedulePriceChangeInput
+	if err := c.Bind(&input); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid request body"})
+	}
+
+	change, err := h.Service.WithContext(c.Request().Context()).SchedulePriceChange(userID, uint(id), input)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return c.JSON(http.StatusNotFound, echo.Map{"error": "Subscription not found"})
+		case errors.Is(err, service.ErrPriceChangeDateTaken):
+			return c.JSON(http.StatusConflict, echo.Map{"error": err.Error()})
+		case isSubscriptionBadRequestError(err.Error()):
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+		}
+		return writeInternalServerError(c, err)
+	}
+	return c.JSON(http.StatusCreated, change)
+}
+
+func (h *SubscriptionHandler) CancelPriceChange(c echo.Context) error {
+	userID := getUserID(c)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid ID"})
+	}
+	changeID, err := strconv.ParseUint(c.Param("changeId"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid price change ID"})
+	}
+
+	if err := h.Service.WithContext(c.Request().Context()).CancelPriceChange(userID, uint(id), uint(changeID)); err != nil {
+		switch {
+		case errors.Is(err, service.ErrPriceChangeNotFound):
+			return c.JSON(http.StatusNotFound, echo.Map{"error": err.Error()})
+		case errors.Is(err, service.ErrPriceChangeAlreadyApplied):
+			return c.JSON(http.StatusConflict, echo.Map{"error": err.Error()})
+		}
+		return writeInternalServerError(c, err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
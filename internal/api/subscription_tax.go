@@ -0,0 +1,86 @@
+package api
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/shiroha/subdux/internal/pkg/money"
+	"github.com/shiroha/subdux/internal/service"
+)
+
+const (
+	taxSummaryFormatJSON = "json"
+	taxSummaryFormatCSV  = "csv"
+)
+
+var taxSummaryCSVHeader = []string{
+	"section", "key", "label", "charge_count", "currency", "net", "tax", "fees", "gross",
+}
+
+// TaxSummary returns the per-month and per-category net/tax/fee split of past
+// and scheduled charges. format=csv downloads the same figures for accounting,
+// with amounts written at the currency's exact precision.
+func (h *SubscriptionHandler) TaxSummary(c echo.Context) error {
+	format := strings.ToLower(strings.TrimSpace(c.QueryParam("format")))
+	if format == "" {
+		format = taxSummaryFormatJSON
+	}
+	if format != taxSummaryFormatJSON && format != taxSummaryFormatCSV {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "format must be json or csv"})
+	}
+
+	userID := getUserID(c)
+	ctx := c.Request().Context()
+	erService := h.ERService.WithContext(ctx)
+
+	pref, _ := erService.GetUserPreference(userID)
+	targetCurrency := pref.PreferredCurrency
+
+	summary, err := h.Service.WithContext(ctx).GetTaxSummary(userID, targetCurrency, erService, c.QueryParam("from"), c.QueryParam("to"))
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidTaxSummaryRange) {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+		}
+		return writeInternalServerError(c, err)
+	}
+	if format == taxSummaryFormatJSON {
+		return c.JSON(http.StatusOK, summary)
+	}
+
+	res := c.Response()
+	filename := fmt.Sprintf("subdux-tax-summary-%s-%s.csv", summary.From, summary.To)
+	res.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	res.Header().Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+	res.WriteHeader(http.StatusOK)
+
+	writer := csv.NewWriter(res)
+	_ = writer.Write(taxSummaryCSVHeader)
+	for _, period := range summary.Periods {
+		_ = writer.Write(taxSummaryCSVRecord("period", period.Period, period.Period, period.ChargeCount, summary.Currency, period.SpendComponents))
+	}
+	for _, category := range summary.Categories {
+		_ = writer.Write(taxSummaryCSVRecord("category", category.Key, category.Label, category.ChargeCount, summary.Currency, category.SpendComponents))
+	}
+	_ = writer.Write(taxSummaryCSVRecord("total", "", "", summary.Totals.ChargeCount, summary.Currency, summary.Totals.SpendComponents))
+	writer.Flush()
+	return nil
+}
+
+func taxSummaryCSVRecord(section, key, label string, chargeCount int, currency string, components service.SpendComponents) []string {
+	return []string{
+		section,
+		escapeCSVFormula(key),
+		escapeCSVFormula(label),
+		strconv.Itoa(chargeCount),
+		currency,
+		money.Format(components.Net, currency),
+		money.Format(components.Tax, currency),
+		money.Format(components.Fees, currency),
+		money.Format(components.Gross, currency),
+	}
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/shiroha/subdux/internal/service"
+)
+
+type UserPreferenceHandler struct {
+	Service *service.UserPreferenceService
+}
+
+type localePreferenceResponse struct {
+	Locale   string `json:"locale"`
+	Timezone string `json:"timezone"`
+}
+
+func mapLocalePreferenceResponse(pref service.LocalePreference) localePreferenceResponse {
+	return localePreferenceResponse{
+		Locale:   pref.Locale,
+		Timezone: pref.Timezone,
+	}
+}
+
+func NewUserPreferenceHandler(s *service.UserPreferenceService) *UserPreferenceHandler {
+	return &UserPreferenceHandler{Service: s}
+}
+
+func (h *UserPreferenceHandler) GetLocalePreference(c echo.Context) error {
+	userID := getUserID(c)
+	pref, err := h.Service.WithContext(c.Request().Context()).GetLocalePreference(userID)
+	if err != nil {
+		return writeInternalServerError(c, err)
+	}
+	return c.JSON(http.StatusOK, mapLocalePreferenceResponse(*pref))
+}
+
+func (h *UserPreferenceHandler) UpdateLocalePreference(c echo.Context) error {
+	userID := getUserID(c)
+	var input service.UpdateLocalePreferenceInput
+	if err := c.Bind(&input); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid request body"})
+	}
+
+	pref, err := h.Service.WithContext(c.Request().Context()).UpdateLocalePreference(userID, input)
+	if err != nil {
+		if errors.Is(err, service.ErrUnsupportedLocale) || errors.Is(err, service.ErrInvalidTimezone) {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+		}
+		return writeInternalServerError(c, err)
+	}
+	return c.JSON(http.StatusOK, mapLocalePreferenceResponse(*pref))
+}
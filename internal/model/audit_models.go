@@ -5,7 +5,8 @@ import "time"
 type AuditEvent struct {
 	EventID             string    `gorm:"primaryKey;size:36" json:"event_id"`
 	OccurredAt          time.Time `gorm:"not null;index;index:idx_audit_user_occurred,priority:2" json:"occurred_at"`
-	UserID              uint      `gorm:"not null;index:idx_audit_user_occurred,priority:1" json:"user_id"`
+	UserID              uint      `gorm:"not null;index:idx_audit_user_occurred,priority:1;uniqueIndex:idx_audit_user_sequence,priority:1" json:"user_id"`
+	Sequence            int64     `gorm:"not null;default:0;uniqueIndex:idx_audit_user_sequence,priority:2" json:"sequence"`
 	KeyID               uint      `gorm:"not null;index" json:"key_id"`
 	KeyKind             string    `gorm:"not null;size:30" json:"key_kind"`
 	ScopeUsed           string    `gorm:"not null;size:20" json:"scope_used"`
@@ -23,5 +24,16 @@ type AuditEvent struct {
 	RequestArgsRedacted string    `gorm:"type:text" json:"request_args_redacted"`
 	BeforeSnapshot      string    `gorm:"type:text" json:"before_snapshot"`
 	AfterSnapshot       string    `gorm:"type:text" json:"after_snapshot"`
+	PrevHash            string    `gorm:"size:64;not null;default:''" json:"prev_hash"`
+	Hash                string    `gorm:"size:64;not null;default:''" json:"hash"`
 	CreatedAt           time.Time `json:"created_at"`
 }
+
+// AuditChainCheckpoint remembers the last event removed from a user's audit
+// chain by retention, so verification can start from a purged prefix.
+type AuditChainCheckpoint struct {
+	UserID    uint      `gorm:"primaryKey;autoIncrement:false" json:"user_id"`
+	Sequence  int64     `gorm:"not null" json:"sequence"`
+	Hash      string    `gorm:"size:64;not null" json:"hash"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -60,6 +60,65 @@ type OIDCConnection struct {
 	User      *User     `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
 }
 
+// LDAPIdentity links a user to the directory entry they sign in with. The
+// username is the value of the configured username attribute, lowercased.
+type LDAPIdentity struct {
+	UserID    uint      `gorm:"primaryKey;autoIncrement:false" json:"user_id"`
+	Username  string    `gorm:"size:255;not null;uniqueIndex:idx_ldap_identity_username" json:"username"`
+	DN        string    `gorm:"size:1024;not null" json:"dn"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	User      *User     `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
+
+// AccountLockout tracks consecutive failed sign-in attempts for a user so the
+// throttle survives restarts and is shared between replicas. The row is
+// cleared after a successful sign-in or an administrator unlock.
+type AccountLockout struct {
+	UserID         uint       `gorm:"primaryKey;autoIncrement:false" json:"user_id"`
+	FailedAttempts int        `gorm:"not null;default:0" json:"failed_attempts"`
+	LastFailedAt   *time.Time `json:"last_failed_at"`
+	LockedUntil    *time.Time `gorm:"index" json:"locked_until"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	User           *User      `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
+
+// OIDCProvider is an additional login provider configured by an
+// administrator. The provider configured in system settings keeps the
+// built-in key "oidc"; each row here has its own key, which OIDCConnection
+// records in its Provider column.
+type OIDCProvider struct {
+	ID                    uint      `gorm:"primaryKey" json:"id"`
+	Key                   string    `gorm:"size:64;not null;uniqueIndex:idx_oidc_provider_key" json:"key"`
+	Name                  string    `gorm:"size:100;not null" json:"name"`
+	Kind                  string    `gorm:"size:16;not null;default:'oidc'" json:"kind"`
+	Enabled               bool      `gorm:"not null;default:false" json:"enabled"`
+	SortOrder             int       `gorm:"not null;default:0" json:"sort_order"`
+	IssuerURL             string    `gorm:"size:512" json:"issuer_url"`
+	ClientID              string    `gorm:"size:255" json:"client_id"`
+	ClientSecret          string    `gorm:"type:text" json:"-"`
+	RedirectURL           string    `gorm:"size:512" json:"redirect_url"`
+	Scopes                string    `gorm:"size:512" json:"scopes"`
+	AuthorizationEndpoint string    `gorm:"size:512" json:"authorization_endpoint"`
+	TokenEndpoint         string    `gorm:"size:512" json:"token_endpoint"`
+	UserinfoEndpoint      string    `gorm:"size:512" json:"userinfo_endpoint"`
+	Audience              string    `gorm:"size:255" json:"audience"`
+	Resource              string    `gorm:"size:255" json:"resource"`
+	ExtraAuthParams       string    `gorm:"size:1024" json:"extra_auth_params"`
+	AutoCreateUser        bool      `gorm:"not null;default:false" json:"auto_create_user"`
+	AllowedDomains        string    `gorm:"size:512" json:"allowed_domains"`
+	SubjectClaim          string    `gorm:"size:255" json:"subject_claim"`
+	EmailClaim            string    `gorm:"size:255" json:"email_claim"`
+	UsernameClaim         string    `gorm:"size:255" json:"username_claim"`
+	RoleClaim             string    `gorm:"size:255" json:"role_claim"`
+	AdminValues           string    `gorm:"size:1024" json:"admin_values"`
+	StatusClaim           string    `gorm:"size:255" json:"status_claim"`
+	ActiveValues          string    `gorm:"size:1024" json:"active_values"`
+	CreatedAt             time.Time `json:"created_at"`
+	UpdatedAt             time.Time `json:"updated_at"`
+}
+
 type APIKey struct {
 	ID         uint       `gorm:"primaryKey" json:"id"`
 	UserID     uint       `gorm:"index;not null" json:"user_id"`
@@ -75,10 +134,18 @@ type APIKey struct {
 	User       *User      `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
 }
 
+// RefreshToken is the current link of a sign-in session. Tokens rotate on
+// every use; SessionID, CreatedVia and SignedInAt carry over to the
+// replacement so one sign-in stays one session.
 type RefreshToken struct {
 	ID         uint       `gorm:"primaryKey" json:"id"`
 	UserID     uint       `gorm:"index;not null" json:"user_id"`
 	TokenHash  string     `gorm:"not null;uniqueIndex:idx_refresh_token_hash;size:64" json:"-"`
+	SessionID  string     `gorm:"size:64;index" json:"session_id"`
+	CreatedVia string     `gorm:"size:20" json:"created_via"`
+	UserAgent  string     `gorm:"size:512" json:"user_agent"`
+	IPAddress  string     `gorm:"size:64" json:"ip_address"`
+	SignedInAt *time.Time `json:"signed_in_at"`
 	ExpiresAt  time.Time  `gorm:"not null;index" json:"expires_at"`
 	LastUsedAt *time.Time `json:"last_used_at"`
 	RevokedAt  *time.Time `gorm:"index" json:"revoked_at"`
@@ -86,3 +153,13 @@ type RefreshToken struct {
 	UpdatedAt  time.Time  `json:"updated_at"`
 	User       *User      `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
 }
+
+// SCIMIdentity records the identity provider's externalId for a user
+// provisioned over SCIM.
+type SCIMIdentity struct {
+	UserID     uint      `gorm:"primaryKey;autoIncrement:false" json:"user_id"`
+	ExternalID string    `gorm:"size:255;not null;uniqueIndex:idx_scim_identity_external_id" json:"external_id"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	User       *User     `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
@@ -19,16 +19,21 @@ type NotificationPolicy struct {
 	DaysBefore             int       `gorm:"default:3;check:chk_notification_policies_days_before,days_before >= 0 AND days_before <= 10" json:"days_before"`
 	NotifyOnDueDay         bool      `gorm:"default:true" json:"notify_on_due_day"`
 	NotifyManualRenewDaily bool      `gorm:"default:false" json:"notify_manual_renew_daily"`
+	DigestFrequency        string    `gorm:"size:20;not null;default:'off'" json:"digest_frequency"`
+	DigestOnly             bool      `gorm:"default:false" json:"digest_only"`
 	CreatedAt              time.Time `json:"created_at"`
 	UpdatedAt              time.Time `json:"updated_at"`
 	User                   *User     `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
 }
 
+// NotificationLog records one delivery attempt. SubscriptionID is nil for
+// notifications about the account rather than one subscription, such as
+// digests.
 type NotificationLog struct {
 	ID             uint          `gorm:"primaryKey" json:"id"`
 	OutboxID       *uint         `gorm:"index" json:"outbox_id"`
 	UserID         uint          `gorm:"index;not null;index:idx_notification_logs_user_status_sent,priority:1;index:idx_notification_logs_user_sub_channel_sent,priority:1" json:"user_id"`
-	SubscriptionID uint          `gorm:"index;not null;index:idx_notification_logs_user_sub_channel_sent,priority:2" json:"subscription_id"`
+	SubscriptionID *uint         `gorm:"index;index:idx_notification_logs_user_sub_channel_sent,priority:2" json:"subscription_id"`
 	ChannelType    string        `gorm:"not null;size:20;index:idx_notification_logs_user_sub_channel_sent,priority:3" json:"channel_type"`
 	TriggerType    string        `gorm:"size:30;index;default:''" json:"trigger_type"`
 	NotifyDate     time.Time     `gorm:"not null;index" json:"notify_date"`
@@ -43,7 +48,7 @@ type NotificationOutbox struct {
 	ID              uint          `gorm:"primaryKey" json:"id"`
 	DedupeKey       string        `gorm:"not null;size:255;uniqueIndex" json:"dedupe_key"`
 	UserID          uint          `gorm:"not null;index" json:"user_id"`
-	SubscriptionID  uint          `gorm:"not null;index" json:"subscription_id"`
+	SubscriptionID  *uint         `gorm:"index" json:"subscription_id"`
 	ChannelID       *uint         `gorm:"index" json:"channel_id"`
 	ChannelType     string        `gorm:"not null;size:20" json:"channel_type"`
 	TriggerType     string        `gorm:"not null;size:30;index" json:"trigger_type"`
@@ -73,6 +78,7 @@ type NotificationTemplate struct {
 	ID          uint      `gorm:"primaryKey" json:"id"`
 	UserID      uint      `gorm:"index;not null" json:"user_id"`
 	ChannelType *string   `gorm:"size:20;index" json:"channel_type"`
+	Kind        string    `gorm:"size:20;not null;default:'reminder';index" json:"kind"`
 	Format      string    `gorm:"size:20;not null;default:'plaintext'" json:"format"`
 	Template    string    `gorm:"type:text;not null" json:"template"`
 	CreatedAt   time.Time `json:"created_at"`
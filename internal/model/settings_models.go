@@ -1,6 +1,10 @@
 package model
 
-import "time"
+import (
+	"time"
+
+	"github.com/shiroha/subdux/internal/pkg/money"
+)
 
 type SystemSetting struct {
 	Key   string `gorm:"primaryKey;size:100" json:"key"`
@@ -17,19 +21,21 @@ type BackgroundTaskLease struct {
 }
 
 type ExchangeRate struct {
-	ID             uint      `gorm:"primaryKey" json:"id"`
-	BaseCurrency   string    `gorm:"not null;size:10;uniqueIndex:idx_base_target" json:"base_currency"`
-	TargetCurrency string    `gorm:"not null;size:10;uniqueIndex:idx_base_target" json:"target_currency"`
-	Rate           float64   `gorm:"not null" json:"rate"`
-	Source         string    `gorm:"not null;size:50" json:"source"`
-	FetchedAt      time.Time `gorm:"not null" json:"fetched_at"`
-	CreatedAt      time.Time `json:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at"`
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	BaseCurrency   string     `gorm:"not null;size:10;uniqueIndex:idx_base_target" json:"base_currency"`
+	TargetCurrency string     `gorm:"not null;size:10;uniqueIndex:idx_base_target" json:"target_currency"`
+	Rate           money.Rate `gorm:"type:text;not null" json:"rate"`
+	Source         string     `gorm:"not null;size:50" json:"source"`
+	FetchedAt      time.Time  `gorm:"not null" json:"fetched_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
 }
 
 type UserPreference struct {
 	UserID            uint      `gorm:"primaryKey" json:"user_id"`
 	PreferredCurrency string    `gorm:"size:10;default:'USD'" json:"preferred_currency"`
+	Locale            string    `gorm:"size:16;not null;default:'en'" json:"locale"`
+	Timezone          string    `gorm:"size:64;not null;default:''" json:"timezone"`
 	UpdatedAt         time.Time `json:"updated_at"`
 	User              *User     `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
 }
@@ -1,38 +1,58 @@
 package model
 
-import "time"
+import (
+	"time"
+
+	"github.com/shiroha/subdux/internal/pkg/money"
+	"gorm.io/gorm"
+)
 
 type Subscription struct {
-	ID               uint           `gorm:"primaryKey" json:"id"`
-	UserID           uint           `gorm:"not null;index;index:idx_subscriptions_user_status_billing,priority:1;index:idx_subscriptions_user_next_billing,priority:1" json:"user_id"`
-	Name             string         `gorm:"not null;size:255" json:"name"`
-	Amount           float64        `gorm:"not null;check:chk_subscriptions_amount_non_negative,amount >= 0" json:"amount"`
-	Currency         string         `gorm:"not null;size:10;default:'USD'" json:"currency"`
-	Enabled          bool           `gorm:"default:true" json:"enabled"`
-	Status           string         `gorm:"not null;size:30;default:'active';check:chk_subscriptions_status,status IN ('active','ended');index:idx_subscriptions_user_status_billing,priority:2" json:"status"`
-	RenewalMode      string         `gorm:"not null;size:30;default:'auto_renew';check:chk_subscriptions_renewal_mode,renewal_mode IN ('auto_renew','manual_renew','cancel_at_period_end')" json:"renewal_mode"`
-	EndsAt           *time.Time     `json:"ends_at"`
-	BillingType      string         `gorm:"not null;size:30;default:'recurring';index:idx_subscriptions_user_status_billing,priority:3" json:"billing_type"`
-	RecurrenceType   string         `gorm:"size:30" json:"recurrence_type"`
-	IntervalCount    *int           `json:"interval_count"`
-	IntervalUnit     string         `gorm:"size:10" json:"interval_unit"`
-	MonthlyDay       *int           `json:"monthly_day"`
-	YearlyMonth      *int           `json:"yearly_month"`
-	YearlyDay        *int           `json:"yearly_day"`
-	NextBillingDate  *time.Time     `gorm:"index:idx_subscriptions_user_next_billing,priority:2" json:"next_billing_date"`
-	Category         string         `gorm:"size:100" json:"category"`
-	CategoryID       *uint          `gorm:"index" json:"category_id"`
-	PaymentMethodID  *uint          `gorm:"index" json:"payment_method_id"`
-	NotifyEnabled    *bool          `json:"notify_enabled"`
-	NotifyDaysBefore *int           `gorm:"check:chk_subscriptions_notify_days_before,notify_days_before IS NULL OR (notify_days_before >= 0 AND notify_days_before <= 10)" json:"notify_days_before"`
-	Icon             string         `gorm:"size:500" json:"icon"`
-	URL              string         `json:"url"`
-	Notes            string         `json:"notes"`
-	CreatedAt        time.Time      `json:"created_at"`
-	UpdatedAt        time.Time      `json:"updated_at"`
-	User             *User          `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
-	CategoryRef      *Category      `gorm:"foreignKey:CategoryID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"-"`
-	PaymentMethodRef *PaymentMethod `gorm:"foreignKey:PaymentMethodID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"-"`
+	ID                    uint           `gorm:"primaryKey" json:"id"`
+	UserID                uint           `gorm:"not null;index;index:idx_subscriptions_user_status_billing,priority:1;index:idx_subscriptions_user_next_billing,priority:1" json:"user_id"`
+	Name                  string         `gorm:"not null;size:255" json:"name"`
+	Amount                float64        `gorm:"-" json:"amount"`
+	AmountMinor           int64          `gorm:"not null;default:0;check:chk_subscriptions_amount_non_negative,amount_minor >= 0" json:"amount_minor"`
+	Currency              string         `gorm:"not null;size:10;default:'USD'" json:"currency"`
+	TaxRate               *float64       `json:"tax_rate"`
+	TaxInclusive          bool           `gorm:"not null;default:false" json:"tax_inclusive"`
+	FXFee                 float64        `gorm:"-" json:"fx_fee"`
+	FXFeeMinor            int64          `gorm:"column:fx_fee_minor;not null;default:0" json:"fx_fee_minor"`
+	ProcessorFee          float64        `gorm:"-" json:"processor_fee"`
+	ProcessorFeeMinor     int64          `gorm:"not null;default:0" json:"processor_fee_minor"`
+	AnnualPlanAmount      *float64       `gorm:"-" json:"annual_plan_amount"`
+	AnnualPlanAmountMinor *int64         `json:"annual_plan_amount_minor"`
+	LastUsedAt            *time.Time     `json:"last_used_at"`
+	Enabled               bool           `gorm:"default:true" json:"enabled"`
+	Status                string         `gorm:"not null;size:30;default:'active';check:chk_subscriptions_status,status IN ('active','ended');index:idx_subscriptions_user_status_billing,priority:2" json:"status"`
+	RenewalMode           string         `gorm:"not null;size:30;default:'auto_renew';check:chk_subscriptions_renewal_mode,renewal_mode IN ('auto_renew','manual_renew','cancel_at_period_end')" json:"renewal_mode"`
+	EndsAt                *time.Time     `json:"ends_at"`
+	BillingType           string         `gorm:"not null;size:30;default:'recurring';index:idx_subscriptions_user_status_billing,priority:3" json:"billing_type"`
+	RecurrenceType        string         `gorm:"size:30" json:"recurrence_type"`
+	IntervalCount         *int           `json:"interval_count"`
+	IntervalUnit          string         `gorm:"size:10" json:"interval_unit"`
+	MonthlyDay            *int           `json:"monthly_day"`
+	YearlyMonth           *int           `json:"yearly_month"`
+	YearlyDay             *int           `json:"yearly_day"`
+	NextBillingDate       *time.Time     `gorm:"index:idx_subscriptions_user_next_billing,priority:2" json:"next_billing_date"`
+	Category              string         `gorm:"size:100" json:"category"`
+	CategoryID            *uint          `gorm:"index" json:"category_id"`
+	PaymentMethodID       *uint          `gorm:"index" json:"payment_method_id"`
+	NotifyEnabled         *bool          `json:"notify_enabled"`
+	NotifyDaysBefore      *int           `gorm:"check:chk_subscriptions_notify_days_before,notify_days_before IS NULL OR (notify_days_before >= 0 AND notify_days_before <= 10)" json:"notify_days_before"`
+	Icon                  string         `gorm:"size:500" json:"icon"`
+	URL                   string         `json:"url"`
+	Notes                 string         `json:"notes"`
+	CreatedAt             time.Time      `json:"created_at"`
+	UpdatedAt             time.Time      `json:"updated_at"`
+	User                  *User          `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	CategoryRef           *Category      `gorm:"foreignKey:CategoryID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"-"`
+	PaymentMethodRef      *PaymentMethod `gorm:"foreignKey:PaymentMethodID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"-"`
+
+	// CustomFields holds the subscription's custom field values keyed by
+	// field key. It is filled by the service layer and never persisted on
+	// this row.
+	CustomFields map[string]string `gorm:"-" json:"custom_fields,omitempty"`
 }
 
 type SubscriptionEvent struct {
@@ -43,8 +63,10 @@ type SubscriptionEvent struct {
 	SubscriptionName          string        `gorm:"not null;size:255" json:"subscription_name"`
 	Type                      string        `gorm:"not null;size:30;index" json:"type"`
 	ChangedFields             string        `gorm:"type:text;not null;default:'[]'" json:"changed_fields"`
-	PreviousAmount            *float64      `json:"previous_amount"`
-	NewAmount                 *float64      `json:"new_amount"`
+	PreviousAmount            *float64      `gorm:"-" json:"previous_amount"`
+	NewAmount                 *float64      `gorm:"-" json:"new_amount"`
+	PreviousAmountMinor       *int64        `json:"previous_amount_minor"`
+	NewAmountMinor            *int64        `json:"new_amount_minor"`
 	PreviousMonthlyAmount     *float64      `json:"previous_monthly_amount"`
 	NewMonthlyAmount          *float64      `json:"new_monthly_amount"`
 	PreviousCurrency          string        `gorm:"size:10" json:"previous_currency"`
@@ -68,6 +90,64 @@ type SubscriptionEvent struct {
 	Subscription              *Subscription `gorm:"foreignKey:SubscriptionID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"-"`
 }
 
+// BeforeSave stores Amount, the fixed fees and the annual plan price as
+// integer minor units of Currency, the only persisted copies, and rounds them
+// to match.
+func (s *Subscription) BeforeSave(*gorm.DB) error {
+	s.AmountMinor = money.ToMinorUnits(s.Amount, s.Currency)
+	s.Amount = money.FromMinorUnits(s.AmountMinor, s.Currency)
+	s.FXFeeMinor = money.ToMinorUnits(s.FXFee, s.Currency)
+	s.FXFee = money.FromMinorUnits(s.FXFeeMinor, s.Currency)
+	s.ProcessorFeeMinor = money.ToMinorUnits(s.ProcessorFee, s.Currency)
+	s.ProcessorFee = money.FromMinorUnits(s.ProcessorFeeMinor, s.Currency)
+	s.AnnualPlanAmount, s.AnnualPlanAmountMinor = roundOptionalAmount(s.AnnualPlanAmount, s.Currency)
+	return nil
+}
+
+// AfterFind derives Amount, the fixed fees and the annual plan price from the
+// stored minor units.
+func (s *Subscription) AfterFind(*gorm.DB) error {
+	s.Amount = money.FromMinorUnits(s.AmountMinor, s.Currency)
+	s.FXFee = money.FromMinorUnits(s.FXFeeMinor, s.Currency)
+	s.ProcessorFee = money.FromMinorUnits(s.ProcessorFeeMinor, s.Currency)
+	s.AnnualPlanAmount = optionalAmountFromMinor(s.AnnualPlanAmountMinor, s.Currency)
+	return nil
+}
+
+// BeforeSave stores the event amounts as minor units, the only persisted
+// copy, and rounds all amounts to their currency's minor units.
+func (e *SubscriptionEvent) BeforeSave(*gorm.DB) error {
+	e.PreviousAmount, e.PreviousAmountMinor = roundOptionalAmount(e.PreviousAmount, e.PreviousCurrency)
+	e.NewAmount, e.NewAmountMinor = roundOptionalAmount(e.NewAmount, e.NewCurrency)
+	e.PreviousMonthlyAmount, _ = roundOptionalAmount(e.PreviousMonthlyAmount, e.PreviousCurrency)
+	e.NewMonthlyAmount, _ = roundOptionalAmount(e.NewMonthlyAmount, e.NewCurrency)
+	return nil
+}
+
+// AfterFind derives PreviousAmount and NewAmount from the stored minor units.
+func (e *SubscriptionEvent) AfterFind(*gorm.DB) error {
+	e.PreviousAmount = optionalAmountFromMinor(e.PreviousAmountMinor, e.PreviousCurrency)
+	e.NewAmount = optionalAmountFromMinor(e.NewAmountMinor, e.NewCurrency)
+	return nil
+}
+
+func roundOptionalAmount(amount *float64, currency string) (*float64, *int64) {
+	if amount == nil {
+		return nil, nil
+	}
+	units := money.ToMinorUnits(*amount, currency)
+	rounded := money.FromMinorUnits(units, currency)
+	return &rounded, &units
+}
+
+func optionalAmountFromMinor(units *int64, currency string) *float64 {
+	if units == nil {
+		return nil
+	}
+	amount := money.FromMinorUnits(*units, currency)
+	return &amount
+}
+
 type SubscriptionActionSnooze struct {
 	ID             uint          `gorm:"primaryKey" json:"id"`
 	UserID         uint          `gorm:"not null;index;uniqueIndex:idx_action_snooze_user_sub_key,priority:1" json:"user_id"`
@@ -80,6 +160,103 @@ type SubscriptionActionSnooze struct {
 	Subscription   *Subscription `gorm:"foreignKey:SubscriptionID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
 }
 
+// SubscriptionPriceChange is a future amount and currency announced by a
+// vendor. The lifecycle sweep applies it to the subscription on EffectiveDate
+// and stamps AppliedAt; until then it only feeds forecasts and reminders.
+type SubscriptionPriceChange struct {
+	ID             uint          `gorm:"primaryKey" json:"id"`
+	UserID         uint          `gorm:"not null;index:idx_price_changes_user_pending,priority:1" json:"user_id"`
+	SubscriptionID uint          `gorm:"not null;uniqueIndex:idx_price_changes_sub_effective,priority:1" json:"subscription_id"`
+	EffectiveDate  time.Time     `gorm:"not null;uniqueIndex:idx_price_changes_sub_effective,priority:2;index:idx_price_changes_user_pending,priority:3" json:"effective_date"`
+	Amount         float64       `gorm:"-" json:"amount"`
+	AmountMinor    int64         `gorm:"not null;default:0;check:chk_price_changes_amount_non_negative,amount_minor >= 0" json:"amount_minor"`
+	Currency       string        `gorm:"not null;size:10" json:"currency"`
+	Note           string        `gorm:"size:500" json:"note"`
+	AppliedAt      *time.Time    `gorm:"index:idx_price_changes_user_pending,priority:2" json:"applied_at"`
+	CreatedAt      time.Time     `json:"created_at"`
+	UpdatedAt      time.Time     `json:"updated_at"`
+	User           *User         `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	Subscription   *Subscription `gorm:"foreignKey:SubscriptionID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
+
+// BeforeSave stores Amount as minor units, as on Subscription.
+func (c *SubscriptionPriceChange) BeforeSave(*gorm.DB) error {
+	c.AmountMinor = money.ToMinorUnits(c.Amount, c.Currency)
+	c.Amount = money.FromMinorUnits(c.AmountMinor, c.Currency)
+	return nil
+}
+
+// AfterFind derives Amount from the stored minor units.
+func (c *SubscriptionPriceChange) AfterFind(*gorm.DB) error {
+	c.Amount = money.FromMinorUnits(c.AmountMinor, c.Currency)
+	return nil
+}
+
+// SubscriptionUsage is one check-in recording that a subscription was used
+// on UsedOn, either from the app or from a script holding an API key.
+type SubscriptionUsage struct {
+	ID             uint          `gorm:"primaryKey" json:"id"`
+	UserID         uint          `gorm:"not null;index" json:"user_id"`
+	SubscriptionID uint          `gorm:"not null;index:idx_subscription_usages_sub_used,priority:1" json:"subscription_id"`
+	UsedOn         time.Time     `gorm:"not null;index:idx_subscription_usages_sub_used,priority:2" json:"used_on"`
+	Source         string        `gorm:"not null;size:20;default:'manual';check:chk_subscription_usages_source,source IN ('manual','api')" json:"source"`
+	CreatedAt      time.Time     `json:"created_at"`
+	User           *User         `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	Subscription   *Subscription `gorm:"foreignKey:SubscriptionID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
+
+// CustomFieldDefinition is a user-defined field that each of the user's
+// subscriptions may carry a value for. Key names the field in API payloads,
+// exports and notification templates and never changes. Options is a JSON
+// array of the values a select field accepts.
+type CustomFieldDefinition struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	UserID       uint      `gorm:"not null;index;uniqueIndex:idx_user_custom_field_key" json:"user_id"`
+	Key          string    `gorm:"column:field_key;not null;size:40;uniqueIndex:idx_user_custom_field_key" json:"key"`
+	Name         string    `gorm:"not null;size:50" json:"name"`
+	Type         string    `gorm:"not null;size:20;check:chk_custom_field_definitions_type,type IN ('text','number','date','select','url','secret')" json:"type"`
+	Options      string    `gorm:"type:text;not null;default:'[]'" json:"options"`
+	DisplayOrder int       `gorm:"default:0" json:"display_order"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	User         *User     `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
+
+// SubscriptionCustomFieldValue is one subscription's value for a custom
+// field. Values of secret fields are stored encrypted.
+type SubscriptionCustomFieldValue struct {
+	ID             uint                   `gorm:"primaryKey" json:"id"`
+	UserID         uint                   `gorm:"not null;index" json:"user_id"`
+	SubscriptionID uint                   `gorm:"not null;uniqueIndex:idx_subscription_custom_field,priority:1" json:"subscription_id"`
+	FieldID        uint                   `gorm:"not null;index;uniqueIndex:idx_subscription_custom_field,priority:2" json:"field_id"`
+	Value          string                 `gorm:"type:text;not null" json:"value"`
+	CreatedAt      time.Time              `json:"created_at"`
+	UpdatedAt      time.Time              `json:"updated_at"`
+	User           *User                  `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	Subscription   *Subscription          `gorm:"foreignKey:SubscriptionID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	Field          *CustomFieldDefinition `gorm:"foreignKey:FieldID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
+
+// SubscriptionAttachment is an invoice, receipt or contract kept for a
+// subscription. StoredName is the file under the attachments asset directory
+// and is never exposed; PeriodStart and PeriodEnd optionally record the
+// billing period the document covers.
+type SubscriptionAttachment struct {
+	ID             uint          `gorm:"primaryKey" json:"id"`
+	UserID         uint          `gorm:"not null;index" json:"user_id"`
+	SubscriptionID uint          `gorm:"not null;index" json:"subscription_id"`
+	Filename       string        `gorm:"not null;size:255" json:"filename"`
+	StoredName     string        `gorm:"not null;size:100;uniqueIndex" json:"-"`
+	ContentType    string        `gorm:"not null;size:50" json:"content_type"`
+	Size           int64         `gorm:"not null;check:chk_subscription_attachments_size_positive,size > 0" json:"size"`
+	PeriodStart    *time.Time    `json:"period_start"`
+	PeriodEnd      *time.Time    `json:"period_end"`
+	CreatedAt      time.Time     `json:"created_at"`
+	UpdatedAt      time.Time     `json:"updated_at"`
+	User           *User         `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	Subscription   *Subscription `gorm:"foreignKey:SubscriptionID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
+
 type Category struct {
 	ID             uint      `gorm:"primaryKey" json:"id"`
 	UserID         uint      `gorm:"not null;index;uniqueIndex:idx_user_category_name;uniqueIndex:idx_user_category_system_key" json:"user_id"`
@@ -92,6 +269,9 @@ type Category struct {
 	User           *User     `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
 }
 
+// PaymentMethod is a user's way of paying. Card details are optional:
+// ExpiryMonth and ExpiryYear are both set or both nil, and LastFour holds only
+// the last four digits, never a full card number.
 type PaymentMethod struct {
 	ID             uint      `gorm:"primaryKey" json:"id"`
 	UserID         uint      `gorm:"not null;index;uniqueIndex:idx_user_payment_method_name;uniqueIndex:idx_user_payment_method_system_key" json:"user_id"`
@@ -100,18 +280,33 @@ type PaymentMethod struct {
 	NameCustomized bool      `gorm:"default:false" json:"name_customized"`
 	Icon           string    `gorm:"size:500" json:"icon"`
 	SortOrder      int       `gorm:"default:0" json:"sort_order"`
+	ExpiryMonth    *int      `json:"expiry_month"`
+	ExpiryYear     *int      `json:"expiry_year"`
+	LastFour       string    `gorm:"size:4" json:"last_four"`
+	Issuer         string    `gorm:"size:100" json:"issuer"`
 	CreatedAt      time.Time `json:"created_at"`
 	UpdatedAt      time.Time `json:"updated_at"`
 	User           *User     `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
 }
 
+// CalendarToken grants read access to a user's calendar feed. CategoryIDs and
+// PaymentMethodIDs are JSON arrays narrowing the feed (empty matches all) and
+// MinAmount is compared in the user's preferred currency. FeedETag and
+// FeedModifiedAt remember the last served feed so clients can poll cheaply.
+// SplitByCategory makes CalDAV expose one calendar per category.
 type CalendarToken struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`
-	UserID    uint      `gorm:"index;not null" json:"-"`
-	Token     string    `gorm:"uniqueIndex;not null;size:64" json:"token,omitempty"`
-	Name      string    `gorm:"not null;size:100" json:"name"`
-	CreatedAt time.Time `json:"created_at"`
-	User      *User     `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	ID               uint       `gorm:"primaryKey" json:"id"`
+	UserID           uint       `gorm:"index;not null" json:"-"`
+	Token            string     `gorm:"uniqueIndex;not null;size:64" json:"token,omitempty"`
+	Name             string     `gorm:"not null;size:100" json:"name"`
+	CategoryIDs      string     `gorm:"type:text;not null;default:'[]'" json:"-"`
+	PaymentMethodIDs string     `gorm:"type:text;not null;default:'[]'" json:"-"`
+	MinAmount        *float64   `json:"min_amount"`
+	SplitByCategory  bool       `gorm:"not null;default:false" json:"split_by_category"`
+	FeedETag         string     `gorm:"column:feed_etag;size:64" json:"-"`
+	FeedModifiedAt   *time.Time `json:"-"`
+	CreatedAt        time.Time  `json:"created_at"`
+	User             *User      `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
 }
 
 func (t *CalendarToken) MaskToken() {
@@ -0,0 +1,92 @@
+package pkg
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/shiroha/subdux/internal/model"
+)
+
+// auditEventHashInput fixes the field order hashed for an audit event. Adding
+// a field here changes every hash, so new AuditEvent columns must not be added
+// without a migration that re-chains existing events.
+type auditEventHashInput struct {
+	PrevHash            string `json:"prev_hash"`
+	EventID             string `json:"event_id"`
+	OccurredAt          string `json:"occurred_at"`
+	UserID              uint   `json:"user_id"`
+	Sequence            int64  `json:"sequence"`
+	KeyID               uint   `json:"key_id"`
+	KeyKind             string `json:"key_kind"`
+	ScopeUsed           string `json:"scope_used"`
+	Transport           string `json:"transport"`
+	ToolName            string `json:"tool_name"`
+	ResourceType        string `json:"resource_type"`
+	ResourceID          string `json:"resource_id"`
+	Action              string `json:"action"`
+	Status              string `json:"status"`
+	Error               string `json:"error"`
+	LatencyMS           int64  `json:"latency_ms"`
+	ClientName          string `json:"client_name"`
+	ClientVersion       string `json:"client_version"`
+	RequestID           string `json:"request_id"`
+	RequestArgsRedacted string `json:"request_args_redacted"`
+	BeforeSnapshot      string `json:"before_snapshot"`
+	AfterSnapshot       string `json:"after_snapshot"`
+}
+
+// auditChainKeyLabel separates the chain key derived from the settings key
+// from that key's other uses.
+const auditChainKeyLabel = "subdux audit chain v1"
+
+// AuditEventHash returns the chain hash of an audit event: HMAC-SHA256 over its
+// content and PrevHash, hex encoded. The key is derived from the settings
+// encryption key, which lives outside the database, so write access to the
+// database alone is not enough to rewrite events and re-chain them. Replacing
+// that key breaks verification of events hashed before the change.
+func AuditEventHash(event model.AuditEvent) (string, error) {
+	key, err := auditChainKey()
+	if err != nil {
+		return "", err
+	}
+	data, _ := json.Marshal(auditEventHashInput{
+		PrevHash:            event.PrevHash,
+		EventID:             event.EventID,
+		OccurredAt:          event.OccurredAt.UTC().Format(time.RFC3339Nano),
+		UserID:              event.UserID,
+		Sequence:            event.Sequence,
+		KeyID:               event.KeyID,
+		KeyKind:             event.KeyKind,
+		ScopeUsed:           event.ScopeUsed,
+		Transport:           event.Transport,
+		ToolName:            event.ToolName,
+		ResourceType:        event.ResourceType,
+		ResourceID:          event.ResourceID,
+		Action:              event.Action,
+		Status:              event.Status,
+		Error:               event.Error,
+		LatencyMS:           event.LatencyMS,
+		ClientName:          event.ClientName,
+		ClientVersion:       event.ClientVersion,
+		RequestID:           event.RequestID,
+		RequestArgsRedacted: event.RequestArgsRedacted,
+		BeforeSnapshot:      event.BeforeSnapshot,
+		AfterSnapshot:       event.AfterSnapshot,
+	})
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+func auditChainKey() ([]byte, error) {
+	settingsKey, err := getSystemSettingsKey()
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, settingsKey)
+	mac.Write([]byte(auditChainKeyLabel))
+	return mac.Sum(nil), nil
+}
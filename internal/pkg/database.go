@@ -71,6 +71,30 @@ func openSQLiteDatabase(dbPath string) (*gorm.DB, error) {
 	return db, nil
 }
 
+// WithSQLiteDatabase opens the database file at dbPath without running
+// migrations, calls fn with it and closes it again. It is meant for short
+// writes into a file that is not the live database, such as a just-restored
+// backup awaiting restart.
+func WithSQLiteDatabase(dbPath string, fn func(db *gorm.DB) error) error {
+	dsn, err := sqliteDatabaseDSN(dbPath)
+	if err != nil {
+		return err
+	}
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		return fmt.Errorf("connect to database: %w", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return fmt.Errorf("access sqlite connection pool: %w", err)
+	}
+	defer sqlDB.Close()
+
+	return fn(db)
+}
+
 func sqliteDatabaseDSN(dbPath string) (string, error) {
 	absolutePath, err := filepath.Abs(dbPath)
 	if err != nil {
@@ -89,23 +89,29 @@ func TestRunSchemaMigrationsRebuildsLegacyTablesWithConstraints(t *testing.T) {
 	}
 
 	nextBilling := now.Add(24 * time.Hour)
-	subscription := model.Subscription{
-		UserID:          primaryUser.ID,
-		Name:            "Legacy Subscription",
-		Amount:          9.99,
-		Currency:        "USD",
-		Enabled:         true,
-		Status:          "ACTIVE",
-		RenewalMode:     "AUTO_RENEW",
-		BillingType:     "recurring",
-		NextBillingDate: &nextBilling,
-		CategoryID:      &foreignCategory.ID,
-		CreatedAt:       now,
-		UpdatedAt:       now,
-	}
-	if err := db.Create(&subscription).Error; err != nil {
+	// The legacy table stores a float amount and predates the minor-unit,
+	// tax and fee columns.
+	legacySub := map[string]interface{}{
+		"user_id":           primaryUser.ID,
+		"name":              "Legacy Subscription",
+		"amount":            9.99,
+		"currency":          "USD",
+		"enabled":           true,
+		"status":            "ACTIVE",
+		"renewal_mode":      "AUTO_RENEW",
+		"billing_type":      "recurring",
+		"next_billing_date": nextBilling,
+		"category_id":       foreignCategory.ID,
+		"created_at":        now,
+		"updated_at":        now,
+	}
+	if err := db.Table("subscriptions").Create(legacySub).Error; err != nil {
 		t.Fatalf("create legacy subscription error = %v", err)
 	}
+	var subscription model.Subscription
+	if err := db.Table("subscriptions").Select("id").Where("name = ?", "Legacy Subscription").Take(&subscription).Error; err != nil {
+		t.Fatalf("load legacy subscription id error = %v", err)
+	}
 
 	policy := map[string]interface{}{
 		"user_id":           primaryUser.ID,
@@ -160,6 +166,9 @@ func TestRunSchemaMigrationsRebuildsLegacyTablesWithConstraints(t *testing.T) {
 	if migratedSub.Status != subscriptionStatusActive || migratedSub.RenewalMode != subscriptionRenewalModeAutoRenew {
 		t.Fatalf("migrated subscription lifecycle = (%q, %q), want (%q, %q)", migratedSub.Status, migratedSub.RenewalMode, subscriptionStatusActive, subscriptionRenewalModeAutoRenew)
 	}
+	if migratedSub.AmountMinor != 999 || migratedSub.Amount != 9.99 {
+		t.Fatalf("migrated subscription amount = (%v, %d minor), want (9.99, 999)", migratedSub.Amount, migratedSub.AmountMinor)
+	}
 
 	actorUserID := primaryUser.ID
 	event := model.SubscriptionEvent{
@@ -193,10 +202,11 @@ func TestRunSchemaMigrationsRebuildsLegacyTablesWithConstraints(t *testing.T) {
 		t.Fatalf("create background task lease after migrations error = %v", err)
 	}
 
+	outboxSubscriptionID := migratedSub.ID
 	outbox := model.NotificationOutbox{
 		DedupeKey:      "migration-test-dedupe",
 		UserID:         primaryUser.ID,
-		SubscriptionID: migratedSub.ID,
+		SubscriptionID: &outboxSubscriptionID,
 		ChannelType:    "webhook",
 		TriggerType:    "due_day",
 		NotifyDate:     now,
@@ -250,3 +260,365 @@ func TestRunSchemaMigrationsRebuildsLegacyTablesWithConstraints(t *testing.T) {
 		t.Fatalf("deleted user lookup error = %v, want %v", err, gorm.ErrRecordNotFound)
 	}
 }
+
+func TestRunSchemaMigrationsDropsFloatMoneyColumns(t *testing.T) {
+	db := openRawSQLiteTestDB(t)
+	if err := configureSQLiteDatabase(db); err != nil {
+		t.Fatalf("configureSQLiteDatabase() error = %v", err)
+	}
+	if err := runSchemaMigrations(db); err != nil {
+		t.Fatalf("runSchemaMigrations() error = %v", err)
+	}
+
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	user := model.User{Username: "money-user", Email: "money@example.com", Password: "hash", Role: "user", Status: "active", CreatedAt: now, UpdatedAt: now}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("create user error = %v", err)
+	}
+	sub := model.Subscription{UserID: user.ID, Name: "Float Era", Amount: 1, Currency: "USD", Status: "active", RenewalMode: "auto_renew", BillingType: "recurring"}
+	if err := db.Create(&sub).Error; err != nil {
+		t.Fatalf("create subscription error = %v", err)
+	}
+	event := model.SubscriptionEvent{UserID: user.ID, SubscriptionID: &sub.ID, SubscriptionName: sub.Name, Type: "created", NewCurrency: "USD"}
+	if err := db.Create(&event).Error; err != nil {
+		t.Fatalf("create subscription event error = %v", err)
+	}
+
+	// Recreate the float columns, as GORM added them, of a database migrated
+	// before amounts were stored only as minor units, holding values written
+	// after the backfill.
+	for _, stmt := range []string{
+		"ALTER TABLE `subscriptions` ADD `amount` real NOT NULL DEFAULT 0",
+		"ALTER TABLE `subscription_events` ADD `previous_amount` real",
+		"ALTER TABLE `subscription_events` ADD `new_amount` real",
+		"ALTER TABLE `subscription_price_changes` ADD `amount` real NOT NULL DEFAULT 0",
+	} {
+		if err := db.Exec(stmt).Error; err != nil {
+			t.Fatalf("add legacy column error = %v", err)
+		}
+	}
+	if err := db.Table("subscriptions").Where("id = ?", sub.ID).Update("amount", 12.34).Error; err != nil {
+		t.Fatalf("seed legacy amount error = %v", err)
+	}
+	if err := db.Table("subscription_events").Where("id = ?", event.ID).Update("new_amount", 5.5).Error; err != nil {
+		t.Fatalf("seed legacy event amount error = %v", err)
+	}
+	if err := db.Where("name = ?", "20261018_19_drop_float_money_columns").Delete(&schemaMigrationRecord{}).Error; err != nil {
+		t.Fatalf("reset migration record error = %v", err)
+	}
+
+	if err := runSchemaMigrations(db); err != nil {
+		t.Fatalf("runSchemaMigrations() error = %v", err)
+	}
+
+	migrator := db.Migrator()
+	for _, column := range legacyMoneyColumns {
+		if migrator.HasColumn(column.table, column.column) {
+			t.Fatalf("%s.%s still exists after migration", column.table, column.column)
+		}
+	}
+	if !migrator.HasIndex(&model.Subscription{}, "idx_subscriptions_user_next_billing") {
+		t.Fatal("idx_subscriptions_user_next_billing missing after migration")
+	}
+
+	var migratedSub model.Subscription
+	if err := db.First(&migratedSub, sub.ID).Error; err != nil {
+		t.Fatalf("reload subscription error = %v", err)
+	}
+	if migratedSub.AmountMinor != 1234 || migratedSub.Amount != 12.34 {
+		t.Fatalf("subscription amount = (%v, %d minor), want (12.34, 1234)", migratedSub.Amount, migratedSub.AmountMinor)
+	}
+	var migratedEvent model.SubscriptionEvent
+	if err := db.First(&migratedEvent, event.ID).Error; err != nil {
+		t.Fatalf("reload subscription event error = %v", err)
+	}
+	if migratedEvent.NewAmount == nil || *migratedEvent.NewAmount != 5.5 || migratedEvent.PreviousAmount != nil {
+		t.Fatalf("event amounts = (%v, %v), want (nil, 5.5)", migratedEvent.PreviousAmount, migratedEvent.NewAmount)
+	}
+
+	if err := db.Table("subscriptions").Where("id = ?", sub.ID).Update("amount_minor", -1).Error; err == nil {
+		t.Fatal("expected amount_minor check constraint error, got nil")
+	}
+	if err := validateSQLiteForeignKeys(db); err != nil {
+		t.Fatalf("validate foreign keys error = %v", err)
+	}
+	if err := db.Delete(&model.User{}, user.ID).Error; err != nil {
+		t.Fatalf("delete user error = %v", err)
+	}
+	var remaining int64
+	if err := db.Model(&model.SubscriptionEvent{}).Where("user_id = ?", user.ID).Count(&remaining).Error; err != nil {
+		t.Fatalf("count subscription events error = %v", err)
+	}
+	if remaining != 0 {
+		t.Fatalf("subscription events = %d, want 0 after FK cascade", remaining)
+	}
+}
+
+func TestRunSchemaMigrationsStoresExchangeRatesAsDecimals(t *testing.T) {
+	db := openRawSQLiteTestDB(t)
+	if err := configureSQLiteDatabase(db); err != nil {
+		t.Fatalf("configureSQLiteDatabase() error = %v", err)
+	}
+	if err := runSchemaMigrations(db); err != nil {
+		t.Fatalf("runSchemaMigrations() error = %v", err)
+	}
+
+	// Recreate the table as it was when rates were stored as floats.
+	for _, stmt := range []string{
+		"DROP TABLE `exchange_rates`",
+		"CREATE TABLE `exchange_rates` (`id` integer PRIMARY KEY AUTOINCREMENT,`base_currency` text NOT NULL,`target_currency` text NOT NULL,`rate` real NOT NULL,`source` text NOT NULL,`fetched_at` datetime NOT NULL,`created_at` datetime,`updated_at` datetime)",
+		"CREATE UNIQUE INDEX `idx_base_target` ON `exchange_rates`(`base_currency`,`target_currency`)",
+		"INSERT INTO `exchange_rates` (`base_currency`,`target_currency`,`rate`,`source`,`fetched_at`) VALUES ('usd','eur',0.9213,'free','2026-10-18 00:00:00')",
+	} {
+		if err := db.Exec(stmt).Error; err != nil {
+			t.Fatalf("seed legacy exchange rates error = %v", err)
+		}
+	}
+	if err := db.Where("name = ?", "20261018_20_exchange_rate_decimals").Delete(&schemaMigrationRecord{}).Error; err != nil {
+		t.Fatalf("reset migration record error = %v", err)
+	}
+
+	if err := runSchemaMigrations(db); err != nil {
+		t.Fatalf("runSchemaMigrations() error = %v", err)
+	}
+
+	var storedType string
+	if err := db.Raw("SELECT typeof(rate) FROM exchange_rates").Scan(&storedType).Error; err != nil {
+		t.Fatalf("read stored rate type error = %v", err)
+	}
+	if storedType != "text" {
+		t.Fatalf("stored rate type = %q, want text", storedType)
+	}
+	var rate model.ExchangeRate
+	if err := db.First(&rate).Error; err != nil {
+		t.Fatalf("reload exchange rate error = %v", err)
+	}
+	if rate.Rate != "0.9213" {
+		t.Fatalf("rate = %q, want %q", rate.Rate, "0.9213")
+	}
+	if !db.Migrator().HasIndex(&model.ExchangeRate{}, "idx_base_target") {
+		t.Fatal("idx_base_target missing after migration")
+	}
+}
+
+func TestRunSchemaMigrationsAllowsNotificationLogsWithoutSubscription(t *testing.T) {
+	db := openRawSQLiteTestDB(t)
+	if err := configureSQLiteDatabase(db); err != nil {
+		t.Fatalf("configureSQLiteDatabase() error = %v", err)
+	}
+	if err := runSchemaMigrations(db); err != nil {
+		t.Fatalf("runSchemaMigrations() error = %v", err)
+	}
+
+	// Recreate the table as it was when every log belonged to a subscription.
+	for _, stmt := range []string{
+		"DROP TABLE `notification_logs`",
+		"CREATE TABLE `notification_logs` (`id` integer PRIMARY KEY AUTOINCREMENT,`outbox_id` integer,`user_id` integer NOT NULL,`subscription_id` integer NOT NULL,`channel_type` text NOT NULL,`trigger_type` text DEFAULT '',`notify_date` datetime NOT NULL,`status` text NOT NULL,`error` text,`sent_at` datetime,CONSTRAINT `fk_notification_logs_user` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`) ON DELETE CASCADE ON UPDATE CASCADE,CONSTRAINT `fk_notification_logs_subscription` FOREIGN KEY (`subscription_id`) REFERENCES `subscriptions`(`id`) ON DELETE CASCADE ON UPDATE CASCADE)",
+	} {
+		if err := db.Exec(stmt).Error; err != nil {
+			t.Fatalf("seed legacy notification logs error = %v", err)
+		}
+	}
+	if err := db.Where("name = ?", "20261018_21_notification_log_optional_subscription").Delete(&schemaMigrationRecord{}).Error; err != nil {
+		t.Fatalf("reset migration record error = %v", err)
+	}
+
+	if err := runSchemaMigrations(db); err != nil {
+		t.Fatalf("runSchemaMigrations() error = %v", err)
+	}
+
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	user := model.User{Username: "digest-user", Email: "digest@example.com", Password: "hash", Role: "user", Status: "active", CreatedAt: now, UpdatedAt: now}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("create user error = %v", err)
+	}
+	digestLog := model.NotificationLog{UserID: user.ID, ChannelType: "email", TriggerType: "digest", NotifyDate: now, Status: "sent", SentAt: now}
+	if err := db.Create(&digestLog).Error; err != nil {
+		t.Fatalf("create notification log without subscription error = %v", err)
+	}
+	if !db.Migrator().HasIndex(&model.NotificationLog{}, "idx_notification_logs_user_sub_channel_sent") {
+		t.Fatal("idx_notification_logs_user_sub_channel_sent missing after migration")
+	}
+	if err := db.Delete(&model.User{}, user.ID).Error; err != nil {
+		t.Fatalf("delete user error = %v", err)
+	}
+	var remaining int64
+	if err := db.Model(&model.NotificationLog{}).Count(&remaining).Error; err != nil {
+		t.Fatalf("count notification logs error = %v", err)
+	}
+	if remaining != 0 {
+		t.Fatalf("notification logs = %d, want 0 after FK cascade", remaining)
+	}
+}
+
+func TestRunSchemaMigrationsRechainsAuditEventsWithKeyedHash(t *testing.T) {
+	t.Setenv("SETTINGS_ENCRYPTION_KEY", "audit-chain-migration-test-key")
+	db := openRawSQLiteTestDB(t)
+	if err := configureSQLiteDatabase(db); err != nil {
+		t.Fatalf("configureSQLiteDatabase() error = %v", err)
+	}
+	if err := runSchemaMigrations(db); err != nil {
+		t.Fatalf("runSchemaMigrations() error = %v", err)
+	}
+
+	// Seed a chain hashed before the hash was keyed.
+	occurredAt := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	prevHash := "checkpoint-hash"
+	for i, eventID := range []string{"evt-1", "evt-2", "evt-3"} {
+		hash := "unkeyed-" + eventID
+		event := model.AuditEvent{
+			EventID:      eventID,
+			OccurredAt:   occurredAt.Add(time.Duration(i) * time.Minute),
+			UserID:       1,
+			Sequence:     int64(i + 6),
+			PrevHash:     prevHash,
+			Hash:         hash,
+			Transport:    "rest",
+			ResourceType: "category",
+			Action:       "update",
+			Status:       "success",
+		}
+		if err := db.Create(&event).Error; err != nil {
+			t.Fatalf("create audit event error = %v", err)
+		}
+		prevHash = hash
+	}
+	if err := db.Where("name = ?", "20261018_22_audit_hash_hmac").Delete(&schemaMigrationRecord{}).Error; err != nil {
+		t.Fatalf("reset migration record error = %v", err)
+	}
+
+	if err := runSchemaMigrations(db); err != nil {
+		t.Fatalf("runSchemaMigrations() error = %v", err)
+	}
+
+	var events []model.AuditEvent
+	if err := db.Order("sequence ASC").Find(&events).Error; err != nil {
+		t.Fatalf("load audit events error = %v", err)
+	}
+	wantPrevHash := "checkpoint-hash"
+	for _, event := range events {
+		hash, err := AuditEventHash(event)
+		if err != nil {
+			t.Fatalf("AuditEventHash() error = %v", err)
+		}
+		if event.PrevHash != wantPrevHash || event.Hash != hash {
+			t.Fatalf("event %s = prev %q hash %q, want prev %q hash %q", event.EventID, event.PrevHash, event.Hash, wantPrevHash, hash)
+		}
+		wantPrevHash = event.Hash
+	}
+}
+
+func TestRunSchemaMigrationsMovesSubscriptionFeesToMinorUnits(t *testing.T) {
+	db := openRawSQLiteTestDB(t)
+	if err := configureSQLiteDatabase(db); err != nil {
+		t.Fatalf("configureSQLiteDatabase() error = %v", err)
+	}
+	if err := runSchemaMigrations(db); err != nil {
+		t.Fatalf("runSchemaMigrations() error = %v", err)
+	}
+
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	user := model.User{Username: "fee-user", Email: "fee@example.com", Password: "hash", Role: "user", Status: "active", CreatedAt: now, UpdatedAt: now}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("create user error = %v", err)
+	}
+	sub := model.Subscription{UserID: user.ID, Name: "Float Fees", Amount: 10, Currency: "EUR", Status: "active", RenewalMode: "auto_renew", BillingType: "recurring"}
+	if err := db.Create(&sub).Error; err != nil {
+		t.Fatalf("create subscription error = %v", err)
+	}
+
+	// Recreate the float fee columns as GORM added them.
+	for _, stmt := range []string{
+		"ALTER TABLE `subscriptions` ADD `fx_fee` real NOT NULL DEFAULT 0",
+		"ALTER TABLE `subscriptions` ADD `processor_fee` real NOT NULL DEFAULT 0",
+	} {
+		if err := db.Exec(stmt).Error; err != nil {
+			t.Fatalf("add legacy column error = %v", err)
+		}
+	}
+	if err := db.Table("subscriptions").Where("id = ?", sub.ID).Updates(map[string]interface{}{"fx_fee": 0.3, "processor_fee": 1.25}).Error; err != nil {
+		t.Fatalf("seed legacy fees error = %v", err)
+	}
+	if err := db.Where("name = ?", "20261018_23_subscription_fee_minor_units").Delete(&schemaMigrationRecord{}).Error; err != nil {
+		t.Fatalf("reset migration record error = %v", err)
+	}
+
+	if err := runSchemaMigrations(db); err != nil {
+		t.Fatalf("runSchemaMigrations() error = %v", err)
+	}
+
+	migrator := db.Migrator()
+	for _, column := range legacyFeeMoneyColumns {
+		if migrator.HasColumn(column.table, column.column) {
+			t.Fatalf("%s.%s still exists after migration", column.table, column.column)
+		}
+	}
+	if !migrator.HasIndex(&model.Subscription{}, "idx_subscriptions_user_next_billing") {
+		t.Fatal("idx_subscriptions_user_next_billing missing after migration")
+	}
+
+	var migrated model.Subscription
+	if err := db.First(&migrated, sub.ID).Error; err != nil {
+		t.Fatalf("reload subscription error = %v", err)
+	}
+	if migrated.FXFeeMinor != 30 || migrated.ProcessorFeeMinor != 125 || migrated.FXFee != 0.3 || migrated.ProcessorFee != 1.25 {
+		t.Fatalf("fees = (%v, %d minor), (%v, %d minor); want (0.3, 30), (1.25, 125)",
+			migrated.FXFee, migrated.FXFeeMinor, migrated.ProcessorFee, migrated.ProcessorFeeMinor)
+	}
+	if err := validateSQLiteForeignKeys(db); err != nil {
+		t.Fatalf("validate foreign keys error = %v", err)
+	}
+}
+
+func TestRunSchemaMigrationsMovesAnnualPlanAmountToMinorUnits(t *testing.T) {
+	db := openRawSQLiteTestDB(t)
+	if err := configureSQLiteDatabase(db); err != nil {
+		t.Fatalf("configureSQLiteDatabase() error = %v", err)
+	}
+	if err := runSchemaMigrations(db); err != nil {
+		t.Fatalf("runSchemaMigrations() error = %v", err)
+	}
+
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	user := model.User{Username: "annual-user", Email: "annual@example.com", Password: "hash", Role: "user", Status: "active", CreatedAt: now, UpdatedAt: now}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("create user error = %v", err)
+	}
+	var subs []model.Subscription
+	for _, name := range []string{"Annual Plan", "No Annual Plan"} {
+		sub := model.Subscription{UserID: user.ID, Name: name, Amount: 10, Currency: "JPY", Status: "active", RenewalMode: "auto_renew", BillingType: "recurring"}
+		if err := db.Create(&sub).Error; err != nil {
+			t.Fatalf("create subscription error = %v", err)
+		}
+		subs = append(subs, sub)
+	}
+
+	// Recreate the float column as GORM added it.
+	if err := db.Exec("ALTER TABLE `subscriptions` ADD `annual_plan_amount` real").Error; err != nil {
+		t.Fatalf("add legacy column error = %v", err)
+	}
+	if err := db.Table("subscriptions").Where("id = ?", subs[0].ID).Update("annual_plan_amount", 9800.4).Error; err != nil {
+		t.Fatalf("seed legacy annual plan error = %v", err)
+	}
+	if err := db.Where("name = ?", "20261018_24_annual_plan_minor_units").Delete(&schemaMigrationRecord{}).Error; err != nil {
+		t.Fatalf("reset migration record error = %v", err)
+	}
+
+	if err := runSchemaMigrations(db); err != nil {
+		t.Fatalf("runSchemaMigrations() error = %v", err)
+	}
+
+	if db.Migrator().HasColumn("subscriptions", "annual_plan_amount") {
+		t.Fatal("subscriptions.annual_plan_amount still exists after migration")
+	}
+	var migrated []model.Subscription
+	if err := db.Order("id ASC").Find(&migrated).Error; err != nil {
+		t.Fatalf("reload subscriptions error = %v", err)
+	}
+	if migrated[0].AnnualPlanAmountMinor == nil || *migrated[0].AnnualPlanAmountMinor != 9800 || *migrated[0].AnnualPlanAmount != 9800 {
+		t.Fatalf("annual plan = (%v, %v minor), want (9800, 9800)", migrated[0].AnnualPlanAmount, migrated[0].AnnualPlanAmountMinor)
+	}
+	if migrated[1].AnnualPlanAmountMinor != nil || migrated[1].AnnualPlanAmount != nil {
+		t.Fatalf("annual plan without a price = (%v, %v minor), want nil", migrated[1].AnnualPlanAmount, migrated[1].AnnualPlanAmountMinor)
+	}
+}
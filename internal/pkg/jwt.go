@@ -27,6 +27,9 @@ type JWTClaims struct {
 	KeyID    uint     `json:"key_id,omitempty"`
 	KeyKind  string   `json:"key_kind,omitempty"`
 	Scopes   []string `json:"scopes,omitempty"`
+	// SessionID ties a user access token to the refresh-token session it
+	// was issued for, so the session list can mark the caller's own.
+	SessionID string `json:"sid,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -125,13 +128,19 @@ func GenerateToken(userID uint, username string, email string, role string) (str
 }
 
 func GenerateAccessToken(userID uint, username string, email string, role string) (string, error) {
+	return GenerateSessionAccessToken(userID, username, email, role, "")
+}
+
+// GenerateSessionAccessToken issues a user access token bound to sessionID.
+func GenerateSessionAccessToken(userID uint, username string, email string, role string, sessionID string) (string, error) {
 	now := NowUTC()
 	claims := &JWTClaims{
-		UserID:   userID,
-		Username: username,
-		Email:    email,
-		Role:     role,
-		AuthType: AuthTypeUser,
+		UserID:    userID,
+		Username:  username,
+		Email:     email,
+		Role:      role,
+		AuthType:  AuthTypeUser,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(now.Add(getAccessTokenTTL())),
 			IssuedAt:  jwt.NewNumericDate(now),
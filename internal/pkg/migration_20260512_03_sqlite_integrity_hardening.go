@@ -53,6 +53,11 @@ func normalizeDataForSQLiteConstraints(db *gorm.DB) error {
 	if err := cleanupUserScopedOrphans(db); err != nil {
 		return err
 	}
+	// The rebuilt subscriptions table keeps amounts only as minor units, so
+	// convert the legacy float column before its rows are copied.
+	if err := backfillLegacyMoneyColumn(db, legacyMoneyColumns[0]); err != nil {
+		return err
+	}
 	if err := normalizeSubscriptionsForSQLiteConstraints(db); err != nil {
 		return err
 	}
@@ -146,8 +151,8 @@ func normalizeSubscriptionsForSQLiteConstraints(db *gorm.DB) error {
 		sub := subscriptions[i]
 		updates := map[string]interface{}{}
 
-		if sub.Amount < 0 {
-			updates["amount"] = 0
+		if sub.AmountMinor < 0 {
+			updates["amount_minor"] = 0
 		}
 
 		status := strings.ToLower(strings.TrimSpace(sub.Status))
@@ -272,7 +277,10 @@ func cleanupNotificationLogOrphans(db *gorm.DB) error {
 		}
 
 		var sub model.Subscription
-		if err := db.Select("id", "user_id").First(&sub, entry.SubscriptionID).Error; err != nil {
+		if entry.SubscriptionID == nil {
+			continue
+		}
+		if err := db.Select("id", "user_id").First(&sub, *entry.SubscriptionID).Error; err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
 				if err := db.Delete(&model.NotificationLog{}, entry.ID).Error; err != nil {
 					return err
@@ -0,0 +1,76 @@
+package pkg
+
+import (
+	"fmt"
+
+	"github.com/shiroha/subdux/internal/model"
+	"gorm.io/gorm"
+)
+
+const auditUserSequenceIndex = "idx_audit_user_sequence"
+
+// migrateAuditHashChain adds the per-user sequence and hash columns to audit
+// events and chains existing events in the order they occurred. The unique
+// (user_id, sequence) index is created only after the backfill, since every
+// pre-existing row starts at sequence 0.
+func migrateAuditHashChain(db *gorm.DB) error {
+	migrator := db.Migrator()
+	for _, field := range []string{"Sequence", "PrevHash", "Hash"} {
+		if migrator.HasColumn(&model.AuditEvent{}, field) {
+			continue
+		}
+		if err := migrator.AddColumn(&model.AuditEvent{}, field); err != nil {
+			return fmt.Errorf("add audit_events.%s: %w", field, err)
+		}
+	}
+	if err := db.AutoMigrate(&model.AuditChainCheckpoint{}); err != nil {
+		return err
+	}
+
+	var userIDs []uint
+	if err := db.Model(&model.AuditEvent{}).Where("sequence = 0").Distinct().Pluck("user_id", &userIDs).Error; err != nil {
+		return err
+	}
+	for _, userID := range userIDs {
+		if err := backfillAuditHashChain(db, userID); err != nil {
+			return fmt.Errorf("chain audit events for user %d: %w", userID, err)
+		}
+	}
+
+	if !migrator.HasIndex(&model.AuditEvent{}, auditUserSequenceIndex) {
+		if err := migrator.CreateIndex(&model.AuditEvent{}, auditUserSequenceIndex); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func backfillAuditHashChain(db *gorm.DB, userID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var events []model.AuditEvent
+		if err := tx.Where("user_id = ?", userID).Order("occurred_at ASC, event_id ASC").Find(&events).Error; err != nil {
+			return err
+		}
+
+		prevHash := ""
+		for i := range events {
+			event := events[i]
+			event.Sequence = int64(i + 1)
+			event.PrevHash = prevHash
+			hash, err := AuditEventHash(event)
+			if err != nil {
+				return err
+			}
+			event.Hash = hash
+			if err := tx.Model(&model.AuditEvent{}).Where("event_id = ?", event.EventID).Updates(map[string]interface{}{
+				"sequence":  event.Sequence,
+				"prev_hash": event.PrevHash,
+				"hash":      event.Hash,
+			}).Error; err != nil {
+				return err
+			}
+			prevHash = event.Hash
+		}
+		return nil
+	})
+}
@@ -0,0 +1,112 @@
+package pkg
+
+import (
+	"fmt"
+
+	"github.com/shiroha/subdux/internal/model"
+	"github.com/shiroha/subdux/internal/pkg/money"
+	"gorm.io/gorm"
+)
+
+// legacyMoneyColumn is a float amount column written before amounts were kept
+// as integer minor units, together with the minor-unit field that replaces it.
+type legacyMoneyColumn struct {
+	model          interface{}
+	table          string
+	column         string
+	minorField     string
+	minorColumn    string
+	currencyColumn string
+	constraint     string
+}
+
+var legacyMoneyColumns = []legacyMoneyColumn{
+	{
+		model:          &model.Subscription{},
+		table:          "subscriptions",
+		column:         "amount",
+		minorField:     "AmountMinor",
+		minorColumn:    "amount_minor",
+		currencyColumn: "currency",
+		constraint:     "chk_subscriptions_amount_non_negative",
+	},
+	{
+		model:          &model.SubscriptionEvent{},
+		table:          "subscription_events",
+		column:         "previous_amount",
+		minorField:     "PreviousAmountMinor",
+		minorColumn:    "previous_amount_minor",
+		currencyColumn: "previous_currency",
+	},
+	{
+		model:          &model.SubscriptionEvent{},
+		table:          "subscription_events",
+		column:         "new_amount",
+		minorField:     "NewAmountMinor",
+		minorColumn:    "new_amount_minor",
+		currencyColumn: "new_currency",
+	},
+	{
+		model:          &model.SubscriptionPriceChange{},
+		table:          "subscription_price_changes",
+		column:         "amount",
+		minorField:     "AmountMinor",
+		minorColumn:    "amount_minor",
+		currencyColumn: "currency",
+		constraint:     "chk_price_changes_amount_non_negative",
+	},
+}
+
+type legacyMoneyAmount struct {
+	ID       uint
+	Amount   float64
+	Currency string
+}
+
+// migrateMoneyMinorUnits adds the integer minor-unit columns for subscription
+// and event amounts and fills them from the float columns that still exist.
+// Amounts already at their currency's precision keep their value exactly;
+// anything finer is rounded half away from zero.
+func migrateMoneyMinorUnits(db *gorm.DB) error {
+	if err := db.AutoMigrate(&model.Subscription{}, &model.SubscriptionEvent{}); err != nil {
+		return err
+	}
+	for _, column := range legacyMoneyColumns {
+		if err := backfillLegacyMoneyColumn(db, column); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backfillLegacyMoneyColumn converts the float values of column into its
+// minor-unit column, adding that column first if the table predates it. It
+// does nothing once the float column is gone. The writes go through Table()
+// so model hooks stay out of the way.
+func backfillLegacyMoneyColumn(db *gorm.DB, column legacyMoneyColumn) error {
+	migrator := db.Migrator()
+	if !migrator.HasTable(column.table) || !migrator.HasColumn(column.table, column.column) {
+		return nil
+	}
+	if !migrator.HasColumn(column.table, column.minorColumn) {
+		if err := migrator.AddColumn(column.model, column.minorField); err != nil {
+			return fmt.Errorf("add %s.%s: %w", column.table, column.minorColumn, err)
+		}
+	}
+
+	var rows []legacyMoneyAmount
+	if err := db.Table(column.table).
+		Select("id", column.column+" AS amount", column.currencyColumn+" AS currency").
+		Where(column.column + " IS NOT NULL").
+		Find(&rows).Error; err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if err := db.Table(column.table).
+			Where("id = ?", row.ID).
+			Update(column.minorColumn, money.ToMinorUnits(row.Amount, row.Currency)).Error; err != nil {
+			return fmt.Errorf("convert %s of %s %d: %w", column.column, column.table, row.ID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package pkg
+
+import (
+	"fmt"
+
+	"github.com/shiroha/subdux/internal/model"
+	"gorm.io/gorm"
+)
+
+// migrateDropFloatMoneyColumns removes the float amount columns left behind
+// by migrateMoneyMinorUnits, so the minor-unit integers are the only stored
+// amounts. Each value is converted once more first, in case a write landed
+// between the two migrations. The non-negative checks that referenced the
+// float columns are dropped with them and recreated on the minor-unit columns.
+//
+// The SQLite migrator drops a column by copying the table into a new one and
+// renaming it back, which leaves references from child tables intact; the
+// indexes lost on the way are recreated by the final AutoMigrate.
+func migrateDropFloatMoneyColumns(db *gorm.DB) error {
+	return withSQLiteForeignKeysDisabled(db, func(tx *gorm.DB) error {
+		if err := dropLegacyMoneyColumns(tx, legacyMoneyColumns); err != nil {
+			return err
+		}
+		return tx.AutoMigrate(&model.Subscription{}, &model.SubscriptionEvent{}, &model.SubscriptionPriceChange{})
+	})
+}
+
+// dropLegacyMoneyColumns converts and then drops each float column that is
+// still present. Callers run it with foreign keys disabled and AutoMigrate the
+// affected models afterwards to restore their indexes.
+func dropLegacyMoneyColumns(tx *gorm.DB, columns []legacyMoneyColumn) error {
+	migrator := tx.Migrator()
+	for _, column := range columns {
+		if !migrator.HasTable(column.table) || !migrator.HasColumn(column.table, column.column) {
+			continue
+		}
+		if err := backfillLegacyMoneyColumn(tx, column); err != nil {
+			return err
+		}
+		if column.constraint != "" && migrator.HasConstraint(column.model, column.constraint) {
+			if err := migrator.DropConstraint(column.model, column.constraint); err != nil {
+				return fmt.Errorf("drop %s.%s: %w", column.table, column.constraint, err)
+			}
+		}
+		if err := migrator.DropColumn(column.model, column.column); err != nil {
+			return fmt.Errorf("drop %s.%s: %w", column.table, column.column, err)
+		}
+		// DropColumn quietly keeps columns whose definition it cannot parse.
+		if migrator.HasColumn(column.table, column.column) {
+			return fmt.Errorf("drop %s.%s: column still present", column.table, column.column)
+		}
+	}
+	return nil
+}
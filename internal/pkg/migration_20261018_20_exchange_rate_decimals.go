@@ -0,0 +1,56 @@
+package pkg
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/shiroha/subdux/internal/model"
+	"github.com/shiroha/subdux/internal/pkg/money"
+	"gorm.io/gorm"
+)
+
+type legacyExchangeRate struct {
+	ID   uint
+	Rate float64
+}
+
+// migrateExchangeRateDecimals turns the float rate column of exchange_rates
+// into decimal text. The floats are read before the column changes type and
+// written back as their shortest round-tripping decimal, which is what the
+// providers quoted for any rate with up to 15 significant digits.
+func migrateExchangeRateDecimals(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasTable(&model.ExchangeRate{}) {
+		return db.AutoMigrate(&model.ExchangeRate{})
+	}
+
+	columnTypes, err := migrator.ColumnTypes(&model.ExchangeRate{})
+	if err != nil {
+		return err
+	}
+	isText := false
+	for _, columnType := range columnTypes {
+		if columnType.Name() == "rate" {
+			isText = strings.EqualFold(columnType.DatabaseTypeName(), "text")
+		}
+	}
+	if isText {
+		return db.AutoMigrate(&model.ExchangeRate{})
+	}
+
+	var rates []legacyExchangeRate
+	if err := db.Table("exchange_rates").Select("id", "rate").Find(&rates).Error; err != nil {
+		return err
+	}
+	if err := migrator.AlterColumn(&model.ExchangeRate{}, "Rate"); err != nil {
+		return fmt.Errorf("alter exchange_rates.rate: %w", err)
+	}
+	for _, rate := range rates {
+		if err := db.Table("exchange_rates").
+			Where("id = ?", rate.ID).
+			Update("rate", string(money.RateFromFloat(rate.Rate))).Error; err != nil {
+			return fmt.Errorf("convert exchange rate %d: %w", rate.ID, err)
+		}
+	}
+	return db.AutoMigrate(&model.ExchangeRate{})
+}
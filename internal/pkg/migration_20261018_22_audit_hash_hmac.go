@@ -0,0 +1,55 @@
+package pkg
+
+import (
+	"fmt"
+
+	"github.com/shiroha/subdux/internal/model"
+	"gorm.io/gorm"
+)
+
+// migrateAuditHashHMAC re-chains stored audit events under the keyed hash.
+// Each user's chain keeps its sequences and its first link, which is either
+// empty or the retention checkpoint's hash, and every later link is rebuilt
+// from the new hashes. Events are trusted as stored at the time of the
+// upgrade; verify the chain before upgrading to catch earlier tampering.
+func migrateAuditHashHMAC(db *gorm.DB) error {
+	var userIDs []uint
+	if err := db.Model(&model.AuditEvent{}).Distinct().Order("user_id ASC").Pluck("user_id", &userIDs).Error; err != nil {
+		return err
+	}
+	for _, userID := range userIDs {
+		if err := rehashAuditChain(db, userID); err != nil {
+			return fmt.Errorf("re-chain audit events for user %d: %w", userID, err)
+		}
+	}
+	return nil
+}
+
+func rehashAuditChain(db *gorm.DB, userID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var events []model.AuditEvent
+		if err := tx.Where("user_id = ?", userID).Order("sequence ASC").Find(&events).Error; err != nil {
+			return err
+		}
+
+		for i := range events {
+			event := events[i]
+			if i > 0 {
+				event.PrevHash = events[i-1].Hash
+			}
+			hash, err := AuditEventHash(event)
+			if err != nil {
+				return err
+			}
+			event.Hash = hash
+			if err := tx.Model(&model.AuditEvent{}).Where("event_id = ?", event.EventID).Updates(map[string]interface{}{
+				"prev_hash": event.PrevHash,
+				"hash":      event.Hash,
+			}).Error; err != nil {
+				return err
+			}
+			events[i] = event
+		}
+		return nil
+	})
+}
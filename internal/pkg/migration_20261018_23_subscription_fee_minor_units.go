@@ -0,0 +1,39 @@
+package pkg
+
+import (
+	"github.com/shiroha/subdux/internal/model"
+	"gorm.io/gorm"
+)
+
+// legacyFeeMoneyColumns are the float fee columns replaced by minor units
+// after the subscription amounts were.
+var legacyFeeMoneyColumns = []legacyMoneyColumn{
+	{
+		model:          &model.Subscription{},
+		table:          "subscriptions",
+		column:         "fx_fee",
+		minorField:     "FXFeeMinor",
+		minorColumn:    "fx_fee_minor",
+		currencyColumn: "currency",
+	},
+	{
+		model:          &model.Subscription{},
+		table:          "subscriptions",
+		column:         "processor_fee",
+		minorField:     "ProcessorFeeMinor",
+		minorColumn:    "processor_fee_minor",
+		currencyColumn: "currency",
+	},
+}
+
+// migrateSubscriptionFeeMinorUnits moves the FX and processor fees to integer
+// minor units of the subscription currency and drops the float columns, the
+// same way migrateDropFloatMoneyColumns did for the amounts.
+func migrateSubscriptionFeeMinorUnits(db *gorm.DB) error {
+	return withSQLiteForeignKeysDisabled(db, func(tx *gorm.DB) error {
+		if err := dropLegacyMoneyColumns(tx, legacyFeeMoneyColumns); err != nil {
+			return err
+		}
+		return tx.AutoMigrate(&model.Subscription{})
+	})
+}
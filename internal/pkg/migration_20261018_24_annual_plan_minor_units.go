@@ -0,0 +1,27 @@
+package pkg
+
+import (
+	"github.com/shiroha/subdux/internal/model"
+	"gorm.io/gorm"
+)
+
+var legacyAnnualPlanMoneyColumn = legacyMoneyColumn{
+	model:          &model.Subscription{},
+	table:          "subscriptions",
+	column:         "annual_plan_amount",
+	minorField:     "AnnualPlanAmountMinor",
+	minorColumn:    "annual_plan_amount_minor",
+	currencyColumn: "currency",
+}
+
+// migrateAnnualPlanMinorUnits moves the annual plan price to integer minor
+// units of the subscription currency and drops the float column. Rows without
+// an annual plan keep a NULL price.
+func migrateAnnualPlanMinorUnits(db *gorm.DB) error {
+	return withSQLiteForeignKeysDisabled(db, func(tx *gorm.DB) error {
+		if err := dropLegacyMoneyColumns(tx, []legacyMoneyColumn{legacyAnnualPlanMoneyColumn}); err != nil {
+			return err
+		}
+		return tx.AutoMigrate(&model.Subscription{})
+	})
+}
@@ -0,0 +1,267 @@
+// Package money implements fixed-point arithmetic for currency amounts.
+//
+// Amounts are exchanged as float64 at the API boundary but stored and summed
+// as integer minor units of their currency (cents for USD, yen for JPY, fils
+// for BHD). Converting a float to minor units goes through its shortest
+// decimal representation, so 2.675 is treated as the decimal the user typed
+// rather than the nearest binary fraction, and rounds half away from zero.
+package money
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// DefaultMinorUnits is the ISO 4217 exponent of most currencies.
+const DefaultMinorUnits = 2
+
+// minorUnitExceptions lists the ISO 4217 currencies whose exponent is not 2.
+var minorUnitExceptions = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0,
+	"KRW": 0, "PYG": 0, "RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0,
+	"XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+	"CLF": 4, "UYW": 4,
+}
+
+// MinorUnits returns the number of decimal places used by currency. Unknown
+// codes use DefaultMinorUnits.
+func MinorUnits(currency string) int {
+	if units, ok := minorUnitExceptions[strings.ToUpper(strings.TrimSpace(currency))]; ok {
+		return units
+	}
+	return DefaultMinorUnits
+}
+
+// displayMinorUnitExceptions lists currencies whose prices are quoted with
+// fewer decimals than their ISO 4217 exponent. Amounts are still stored and
+// summed at ISO precision; only rendering for people uses these.
+var displayMinorUnitExceptions = map[string]int{
+	"IDR": 0,
+}
+
+// DisplayMinorUnits returns the number of decimal places amounts in currency
+// are shown with. It never exceeds MinorUnits.
+func DisplayMinorUnits(currency string) int {
+	if units, ok := displayMinorUnitExceptions[strings.ToUpper(strings.TrimSpace(currency))]; ok {
+		return units
+	}
+	return MinorUnits(currency)
+}
+
+var powersOfTen = [...]int64{1, 10, 100, 1000, 10000}
+
+func scale(currency string) int64 {
+	return powersOfTen[MinorUnits(currency)]
+}
+
+// decimalRat returns the exact value of the shortest decimal that
+// round-trips to f.
+func decimalRat(f float64) *big.Rat {
+	r, ok := new(big.Rat).SetString(strconv.FormatFloat(f, 'g', -1, 64))
+	if !ok {
+		return new(big.Rat)
+	}
+	return r
+}
+
+// roundRat rounds r to an integer, half away from zero.
+func roundRat(r *big.Rat) int64 {
+	num := new(big.Int).Set(r.Num())
+	den := r.Denom()
+	negative := num.Sign() < 0
+	num.Abs(num)
+
+	quotient, remainder := new(big.Int).QuoRem(num, den, new(big.Int))
+	if remainder.Lsh(remainder, 1).Cmp(den) >= 0 {
+		quotient.Add(quotient, big.NewInt(1))
+	}
+	if negative {
+		quotient.Neg(quotient)
+	}
+	if !quotient.IsInt64() {
+		if negative {
+			return math.MinInt64
+		}
+		return math.MaxInt64
+	}
+	return quotient.Int64()
+}
+
+// ToMinorUnits converts amount to integer minor units of currency.
+func ToMinorUnits(amount float64, currency string) int64 {
+	if math.IsNaN(amount) || math.IsInf(amount, 0) {
+		return 0
+	}
+	r := decimalRat(amount)
+	return roundRat(r.Mul(r, new(big.Rat).SetInt64(scale(currency))))
+}
+
+// FromMinorUnits converts integer minor units of currency back to a float.
+// The result is the float nearest to the exact decimal value.
+func FromMinorUnits(units int64, currency string) float64 {
+	f, _ := new(big.Rat).SetFrac64(units, scale(currency)).Float64()
+	return f
+}
+
+// Round rounds amount to the minor units of currency.
+func Round(amount float64, currency string) float64 {
+	return FromMinorUnits(ToMinorUnits(amount, currency), currency)
+}
+
+// Multiply returns amount × factor in minor units of currency, rounded once.
+// It is used for exchange rates and for normalising billing periods.
+func Multiply(amount float64, factor float64, currency string) int64 {
+	if math.IsNaN(amount*factor) || math.IsInf(amount*factor, 0) {
+		return 0
+	}
+	r := decimalRat(amount)
+	r.Mul(r, decimalRat(factor))
+	return roundRat(r.Mul(r, new(big.Rat).SetInt64(scale(currency))))
+}
+
+// Rate is an exchange rate kept as the exact decimal text quoted by the rate
+// provider, e.g. "0.9213". It is stored as text so no binary rounding creeps
+// in between fetching a rate and applying it.
+type Rate string
+
+// ParseRate validates s as a positive plain or exponent decimal.
+func ParseRate(s string) (Rate, error) {
+	s = strings.TrimSpace(s)
+	r, ok := new(big.Rat).SetString(s)
+	if !ok || strings.Contains(s, "/") {
+		return "", fmt.Errorf("invalid exchange rate %q", s)
+	}
+	if r.Sign() <= 0 {
+		return "", fmt.Errorf("exchange rate %q must be positive", s)
+	}
+	return Rate(s), nil
+}
+
+// RateFromFloat returns the shortest decimal that round-trips to f.
+func RateFromFloat(f float64) Rate {
+	return Rate(strconv.FormatFloat(f, 'f', -1, 64))
+}
+
+func (r Rate) rat() *big.Rat {
+	value, ok := new(big.Rat).SetString(string(r))
+	if !ok {
+		return new(big.Rat)
+	}
+	return value
+}
+
+// Float64 returns the rate as the nearest float, for the API edge.
+func (r Rate) Float64() float64 {
+	f, _ := r.rat().Float64()
+	return f
+}
+
+// MultiplyRate returns amount × rate in minor units of currency, rounded once.
+func MultiplyRate(amount float64, rate Rate, currency string) int64 {
+	if math.IsNaN(amount) || math.IsInf(amount, 0) {
+		return 0
+	}
+	r := decimalRat(amount)
+	r.Mul(r, rate.rat())
+	return roundRat(r.Mul(r, new(big.Rat).SetInt64(scale(currency))))
+}
+
+// Format renders amount with exactly the decimal places of currency and no
+// grouping, e.g. "59.99", "1200" for JPY or "1.250" for BHD.
+func Format(amount float64, currency string) string {
+	return FormatMinorUnits(ToMinorUnits(amount, currency), currency)
+}
+
+// FormatDisplay renders amount with the display places of currency and no
+// grouping, rounding half away from zero, e.g. "15000" for IDR 14999.50.
+// Use Format where the value must keep its full precision.
+func FormatDisplay(amount float64, currency string) string {
+	units := ToMinorUnits(amount, currency)
+	places := DisplayMinorUnits(currency)
+	if dropped := MinorUnits(currency) - places; dropped > 0 {
+		units = roundRat(new(big.Rat).SetFrac64(units, powersOfTen[dropped]))
+	}
+	return formatDecimal(units, places)
+}
+
+// FormatMinorUnits renders minor units of currency as a plain decimal.
+func FormatMinorUnits(units int64, currency string) string {
+	return formatDecimal(units, MinorUnits(currency))
+}
+
+// formatDecimal renders units as a decimal with places digits after the point.
+func formatDecimal(units int64, places int) string {
+	sign := ""
+	magnitude := new(big.Int).SetInt64(units)
+	if units < 0 {
+		sign = "-"
+		magnitude.Neg(magnitude)
+	}
+	digits := magnitude.String()
+	if places == 0 {
+		return sign + digits
+	}
+	if len(digits) <= places {
+		digits = strings.Repeat("0", places-len(digits)+1) + digits
+	}
+	split := len(digits) - places
+	return sign + digits[:split] + "." + digits[split:]
+}
+
+// Total accumulates amounts of one currency exactly.
+type Total struct {
+	currency string
+	units    int64
+}
+
+// NewTotal returns an empty total in currency.
+func NewTotal(currency string) Total {
+	return Total{currency: currency}
+}
+
+// Add rounds amount to the total's minor units and adds it.
+func (t *Total) Add(amount float64) {
+	t.units += ToMinorUnits(amount, t.currency)
+}
+
+// AddMinorUnits adds an amount that is already in minor units.
+func (t *Total) AddMinorUnits(units int64) {
+	t.units += units
+}
+
+// MinorUnits returns the running total in minor units.
+func (t Total) MinorUnits() int64 {
+	return t.units
+}
+
+// Float64 returns the running total as a float.
+func (t Total) Float64() float64 {
+	return FromMinorUnits(t.units, t.currency)
+}
+
+// SplitTax splits units of currency into net and tax parts for a tax rate
+// given in percent. When inclusive is true units already contain the tax and
+// the net part is extracted; otherwise units are the net amount and tax is
+// added on top. The tax part is rounded once and net + tax always equals the
+// charged total.
+func SplitTax(units int64, ratePercent float64, inclusive bool) (net int64, tax int64) {
+	if ratePercent <= 0 || math.IsNaN(ratePercent) || math.IsInf(ratePercent, 0) {
+		return units, 0
+	}
+	rate := decimalRat(ratePercent)
+	if inclusive {
+		// net = units × 100 / (100 + rate)
+		divisor := new(big.Rat).Add(big.NewRat(100, 1), rate)
+		r := new(big.Rat).SetInt64(units * 100)
+		net = roundRat(r.Quo(r, divisor))
+		return net, units - net
+	}
+	r := new(big.Rat).SetInt64(units)
+	r.Mul(r, rate)
+	tax = roundRat(r.Quo(r, big.NewRat(100, 1)))
+	return units, tax
+}
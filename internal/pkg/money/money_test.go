@@ -0,0 +1,160 @@
+package money
+
+import "testing"
+
+func TestMinorUnits(t *testing.T) {
+	tests := []struct {
+		currency string
+		want     int
+	}{
+		{currency: "USD", want: 2},
+		{currency: "jpy", want: 0},
+		{currency: " BHD ", want: 3},
+		{currency: "", want: 2},
+		{currency: "XYZ", want: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.currency, func(t *testing.T) {
+			if got := MinorUnits(tt.currency); got != tt.want {
+				t.Fatalf("MinorUnits(%q) = %d, want %d", tt.currency, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToMinorUnitsRoundsDecimalHalfAwayFromZero(t *testing.T) {
+	tests := []struct {
+		amount   float64
+		currency string
+		want     int64
+	}{
+		{amount: 9.99, currency: "USD", want: 999},
+		// 2.675 is stored as 2.67499999...; it must still round as typed.
+		{amount: 2.675, currency: "USD", want: 268},
+		{amount: -2.675, currency: "USD", want: -268},
+		{amount: 1200.5, currency: "JPY", want: 1201},
+		{amount: 1.2345, currency: "BHD", want: 1235},
+		{amount: 0.1 + 0.2, currency: "USD", want: 30},
+	}
+
+	for _, tt := range tests {
+		if got := ToMinorUnits(tt.amount, tt.currency); got != tt.want {
+			t.Errorf("ToMinorUnits(%v, %q) = %d, want %d", tt.amount, tt.currency, got, tt.want)
+		}
+	}
+}
+
+func TestTotalSumsWithoutDrift(t *testing.T) {
+	total := NewTotal("USD")
+	for i := 0; i < 6; i++ {
+		total.Add(9.99)
+	}
+	if got := total.Float64(); got != 59.94 {
+		t.Fatalf("Float64() = %v, want 59.94", got)
+	}
+	if got := FormatMinorUnits(total.MinorUnits(), "USD"); got != "59.94" {
+		t.Fatalf("FormatMinorUnits() = %q, want %q", got, "59.94")
+	}
+}
+
+func TestMultiplyRoundsOnce(t *testing.T) {
+	// 15.99 × 0.92 = 14.7108 → 14.71
+	if got := Multiply(15.99, 0.92, "EUR"); got != 1471 {
+		t.Fatalf("Multiply() = %d, want 1471", got)
+	}
+	// 120 / 12 months expressed as a factor of 1/12.
+	if got := Multiply(120, 1.0/12, "USD"); got != 1000 {
+		t.Fatalf("Multiply() = %d, want 1000", got)
+	}
+}
+
+func TestMultiplyRateKeepsQuotedDigits(t *testing.T) {
+	rate, err := ParseRate(" 0.49999999999999999 ")
+	if err != nil {
+		t.Fatalf("ParseRate() error = %v", err)
+	}
+	// As a float the rate is exactly 0.5, which would round 0.5 cents up.
+	if got := MultiplyRate(0.01, rate, "USD"); got != 0 {
+		t.Fatalf("MultiplyRate() = %d, want 0", got)
+	}
+	if got := MultiplyRate(15.99, "0.92", "EUR"); got != 1471 {
+		t.Fatalf("MultiplyRate() = %d, want 1471", got)
+	}
+	if got := RateFromFloat(0.92); got != "0.92" {
+		t.Fatalf("RateFromFloat() = %q, want %q", got, "0.92")
+	}
+	for _, invalid := range []string{"", "abc", "1/3", "0", "-1.2"} {
+		if _, err := ParseRate(invalid); err == nil {
+			t.Fatalf("ParseRate(%q) error = nil", invalid)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		amount   float64
+		currency string
+		want     string
+	}{
+		{amount: 59.99, currency: "USD", want: "59.99"},
+		{amount: 1200, currency: "JPY", want: "1200"},
+		{amount: 1.25, currency: "BHD", want: "1.250"},
+		{amount: 0.05, currency: "USD", want: "0.05"},
+		{amount: -3.5, currency: "USD", want: "-3.50"},
+	}
+
+	for _, tt := range tests {
+		if got := Format(tt.amount, tt.currency); got != tt.want {
+			t.Errorf("Format(%v, %q) = %q, want %q", tt.amount, tt.currency, got, tt.want)
+		}
+	}
+}
+
+func TestFormatDisplay(t *testing.T) {
+	tests := []struct {
+		amount   float64
+		currency string
+		want     string
+	}{
+		{amount: 59.99, currency: "USD", want: "59.99"},
+		{amount: 1200, currency: "JPY", want: "1200"},
+		{amount: 14999.5, currency: "IDR", want: "15000"},
+		{amount: -14999.49, currency: "idr", want: "-14999"},
+		{amount: 1.25, currency: "BHD", want: "1.250"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatDisplay(tt.amount, tt.currency); got != tt.want {
+			t.Errorf("FormatDisplay(%v, %q) = %q, want %q", tt.amount, tt.currency, got, tt.want)
+		}
+	}
+	if got := DisplayMinorUnits("IDR"); got != 0 || MinorUnits("IDR") != 2 {
+		t.Errorf("IDR places = display %d, stored %d; want 0 and 2", got, MinorUnits("IDR"))
+	}
+}
+
+func TestSplitTax(t *testing.T) {
+	tests := []struct {
+		name      string
+		units     int64
+		rate      float64
+		inclusive bool
+		wantNet   int64
+		wantTax   int64
+	}{
+		{name: "inclusive", units: 1199, rate: 19, inclusive: true, wantNet: 1008, wantTax: 191},
+		{name: "exclusive", units: 1000, rate: 20, inclusive: false, wantNet: 1000, wantTax: 200},
+		{name: "exclusive rounding", units: 999, rate: 7.5, inclusive: false, wantNet: 999, wantTax: 75},
+		{name: "no rate", units: 500, rate: 0, inclusive: true, wantNet: 500, wantTax: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			net, tax := SplitTax(tt.units, tt.rate, tt.inclusive)
+			if net != tt.wantNet || tax != tt.wantTax {
+				t.Fatalf("SplitTax() = (%d, %d), want (%d, %d)", net, tax, tt.wantNet, tt.wantTax)
+			}
+		})
+	}
+}
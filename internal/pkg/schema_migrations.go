@@ -35,6 +35,10 @@ var applicationModels = []interface{}{
 	&model.UserBackupCode{},
 	&model.PasskeyCredential{},
 	&model.OIDCConnection{},
+	&model.SCIMIdentity{},
+	&model.OIDCProvider{},
+	&model.LDAPIdentity{},
+	&model.AccountLockout{},
 	&model.Category{},
 	&model.PaymentMethod{},
 	&model.NotificationChannel{},
@@ -45,12 +49,18 @@ var applicationModels = []interface{}{
 	&model.RefreshToken{},
 	&model.CalendarToken{},
 	&model.AuditEvent{},
+	&model.AuditChainCheckpoint{},
 	&model.MCPIdempotencyKey{},
 }
 
 var postIntegrityApplicationModels = []interface{}{
 	&model.SubscriptionEvent{},
 	&model.SubscriptionActionSnooze{},
+	&model.SubscriptionPriceChange{},
+	&model.SubscriptionUsage{},
+	&model.CustomFieldDefinition{},
+	&model.SubscriptionCustomFieldValue{},
+	&model.SubscriptionAttachment{},
 }
 
 var schemaMigrations = []schemaMigration{
@@ -65,6 +75,30 @@ var schemaMigrations = []schemaMigration{
 	{Name: "20260628_01_manual_renew_daily_notifications", Run: migrateManualRenewDailyNotificationPolicy},
 	{Name: "20260628_02_mcp_idempotency_keys", Run: migrateMCPIdempotencyKeys},
 	{Name: "20260628_03_performance_composite_indexes", Run: migratePerformanceCompositeIndexes},
+	{Name: "20261018_01_notification_digests", Run: migrateNotificationDigests},
+	{Name: "20261018_02_user_locale_preferences", Run: migrateUserLocalePreferences},
+	{Name: "20261018_03_calendar_token_filters", Run: migrateCalendarTokenFilters},
+	{Name: "20261018_04_calendar_token_caldav", Run: migrateCalendarTokenCalDAV},
+	{Name: "20261018_05_audit_hash_chain", Run: migrateAuditHashChain},
+	{Name: "20261018_06_scim_identities", Run: migrateSCIMIdentities},
+	{Name: "20261018_07_oidc_providers", Run: migrateOIDCProviders},
+	{Name: "20261018_08_ldap_identities", Run: migrateLDAPIdentities},
+	{Name: "20261018_09_refresh_token_sessions", Run: migrateRefreshTokenSessions},
+	{Name: "20261018_10_account_lockouts", Run: migrateAccountLockouts},
+	{Name: "20261018_11_money_minor_units", Run: migrateMoneyMinorUnits},
+	{Name: "20261018_12_subscription_tax_and_fees", Run: migrateSubscriptionTaxAndFees},
+	{Name: "20261018_13_subscription_price_changes", Run: migrateSubscriptionPriceChanges},
+	{Name: "20261018_14_subscription_savings_fields", Run: migrateSubscriptionSavingsFields},
+	{Name: "20261018_15_subscription_usages", Run: migrateSubscriptionUsages},
+	{Name: "20261018_16_custom_fields", Run: migrateCustomFields},
+	{Name: "20261018_17_subscription_attachments", Run: migrateSubscriptionAttachments},
+	{Name: "20261018_18_payment_method_card_details", Run: migratePaymentMethodCardDetails},
+	{Name: "20261018_19_drop_float_money_columns", Run: migrateDropFloatMoneyColumns},
+	{Name: "20261018_20_exchange_rate_decimals", Run: migrateExchangeRateDecimals},
+	{Name: "20261018_21_notification_log_optional_subscription", Run: migrateNotificationLogOptionalSubscription},
+	{Name: "20261018_22_audit_hash_hmac", Run: migrateAuditHashHMAC},
+	{Name: "20261018_23_subscription_fee_minor_units", Run: migrateSubscriptionFeeMinorUnits},
+	{Name: "20261018_24_annual_plan_minor_units", Run: migrateAnnualPlanMinorUnits},
 }
 
 func autoMigrateLatestSchema(db *gorm.DB) error {
@@ -144,6 +178,114 @@ func migratePerformanceCompositeIndexes(db *gorm.DB) error {
 	return nil
 }
 
+// migrateNotificationDigests prepares the schema for digest notifications. A
+// digest summarizes many subscriptions, so its outbox row carries no
+// subscription: SQLite cannot relax NOT NULL in place, so the outbox table is
+// rebuilt from the current model (foreign keys disabled for the copy) before
+// the policy and template tables gain their digest columns.
+func migrateNotificationDigests(db *gorm.DB) error {
+	if db.Migrator().HasTable(&model.NotificationOutbox{}) {
+		if err := withSQLiteForeignKeysDisabled(db, func(tx *gorm.DB) error {
+			return rebuildSQLiteTable(tx, &model.NotificationOutbox{})
+		}); err != nil {
+			return err
+		}
+	}
+	return db.AutoMigrate(
+		&model.NotificationOutbox{},
+		&model.NotificationPolicy{},
+		&model.NotificationTemplate{},
+	)
+}
+
+// migrateUserLocalePreferences adds the locale and timezone columns used to
+// localize notifications. Existing users default to English and the system
+// timezone.
+func migrateUserLocalePreferences(db *gorm.DB) error {
+	return db.AutoMigrate(&model.UserPreference{})
+}
+
+func migrateCalendarTokenFilters(db *gorm.DB) error {
+	return db.AutoMigrate(&model.CalendarToken{})
+}
+
+func migrateCalendarTokenCalDAV(db *gorm.DB) error {
+	return db.AutoMigrate(&model.CalendarToken{})
+}
+
+func migrateSCIMIdentities(db *gorm.DB) error {
+	return db.AutoMigrate(&model.SCIMIdentity{})
+}
+
+func migrateOIDCProviders(db *gorm.DB) error {
+	return db.AutoMigrate(&model.OIDCProvider{})
+}
+
+func migrateLDAPIdentities(db *gorm.DB) error {
+	return db.AutoMigrate(&model.LDAPIdentity{})
+}
+
+// migrateRefreshTokenSessions gives tokens issued before sessions were tracked
+// a session id of their own so they can still be listed and revoked.
+func migrateRefreshTokenSessions(db *gorm.DB) error {
+	if err := db.AutoMigrate(&model.RefreshToken{}); err != nil {
+		return err
+	}
+
+	var ids []uint
+	if err := db.Model(&model.RefreshToken{}).
+		Where("session_id IS NULL OR session_id = ''").
+		Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := db.Model(&model.RefreshToken{}).
+			Where("id = ?", id).
+			Update("session_id", fmt.Sprintf("legacy-%d", id)).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func migrateAccountLockouts(db *gorm.DB) error {
+	return db.AutoMigrate(&model.AccountLockout{})
+}
+
+func migrateSubscriptionTaxAndFees(db *gorm.DB) error {
+	return db.AutoMigrate(&model.Subscription{})
+}
+
+func migrateSubscriptionPriceChanges(db *gorm.DB) error {
+	return db.AutoMigrate(&model.SubscriptionPriceChange{})
+}
+
+func migrateSubscriptionSavingsFields(db *gorm.DB) error {
+	return db.AutoMigrate(&model.Subscription{})
+}
+
+func migrateSubscriptionUsages(db *gorm.DB) error {
+	return db.AutoMigrate(&model.SubscriptionUsage{})
+}
+
+func migrateCustomFields(db *gorm.DB) error {
+	return db.AutoMigrate(&model.CustomFieldDefinition{}, &model.SubscriptionCustomFieldValue{})
+}
+
+func migrateSubscriptionAttachments(db *gorm.DB) error {
+	return db.AutoMigrate(&model.SubscriptionAttachment{})
+}
+
+func migratePaymentMethodCardDetails(db *gorm.DB) error {
+	return db.AutoMigrate(&model.PaymentMethod{})
+}
+
+// migrateNotificationLogOptionalSubscription drops NOT NULL from
+// notification_logs.subscription_id so digest deliveries can be logged.
+func migrateNotificationLogOptionalSubscription(db *gorm.DB) error {
+	return db.AutoMigrate(&model.NotificationLog{})
+}
+
 func runSchemaMigrations(db *gorm.DB) error {
 	if err := db.AutoMigrate(&schemaMigrationRecord{}); err != nil {
 		return fmt.Errorf("auto-migrate schema_migrations: %w", err)
@@ -27,6 +27,14 @@ func DecryptNotificationChannelConfig(value string) (string, error) {
 	return decryptWithDerivedKey(value)
 }
 
+func EncryptCustomFieldSecret(value string) (string, error) {
+	return encryptWithDerivedKey(value)
+}
+
+func DecryptCustomFieldSecret(value string) (string, error) {
+	return decryptWithDerivedKey(value)
+}
+
 func EncryptSystemSettingValue(value string) (string, error) {
 	return encryptWithDerivedKey(value)
 }
@@ -1,6 +1,7 @@
 package pkg
 
 import (
+	"math"
 	"os"
 	"sync"
 	"time"
@@ -63,6 +64,14 @@ func NormalizeDateInTimezone(t time.Time, loc *time.Location) time.Time {
 	return time.Date(inZone.Year(), inZone.Month(), inZone.Day(), 0, 0, 0, 0, loc)
 }
 
+// CalendarDateInTimezone returns midnight in loc of the calendar day date
+// carries in its own location. Billing dates are stored as midnight UTC, so
+// converting them with NormalizeDateInTimezone would move them to the previous
+// day in zones west of UTC.
+func CalendarDateInTimezone(date time.Time, loc *time.Location) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, loc)
+}
+
 // TodayInTimezone returns the current date at 00:00:00 in the given timezone.
 func TodayInTimezone(loc *time.Location) time.Time {
 	return NormalizeDateInTimezone(Now(), loc)
@@ -84,6 +93,7 @@ func DaysUntil(target time.Time, loc *time.Location) int {
 func DaysUntilFrom(now, target time.Time, loc *time.Location) int {
 	today := NormalizeDateInTimezone(now, loc)
 	targetDate := NormalizeDateInTimezone(target, loc)
-	diff := targetDate.Sub(today)
-	return int(diff.Hours() / 24)
+	// Days around a DST change last 23 or 25 hours, so round rather than
+	// truncate the hour count.
+	return int(math.Round(targetDate.Sub(today).Hours() / 24))
 }
@@ -154,3 +154,61 @@ func TestDaysUntilCrossingTimezone(t *testing.T) {
 		t.Logf("Tokyo time: %v (days until: %d)", tokyoTime, tokyoDays)
 	}
 }
+
+func TestDaysUntilFromAcrossDST(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("America/New_York unavailable: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		now      time.Time
+		target   time.Time
+		wantDays int
+	}{
+		{
+			name:     "spring forward day has 23 hours",
+			now:      time.Date(2026, 3, 7, 22, 30, 0, 0, newYork),
+			target:   time.Date(2026, 3, 9, 0, 0, 0, 0, newYork),
+			wantDays: 2,
+		},
+		{
+			name:     "fall back day has 25 hours",
+			now:      time.Date(2026, 10, 31, 8, 0, 0, 0, newYork),
+			target:   time.Date(2026, 11, 2, 0, 0, 0, 0, newYork),
+			wantDays: 2,
+		},
+		{
+			name:     "past date across DST",
+			now:      time.Date(2026, 3, 9, 1, 0, 0, 0, newYork),
+			target:   time.Date(2026, 3, 7, 0, 0, 0, 0, newYork),
+			wantDays: -2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DaysUntilFrom(tt.now, tt.target, newYork); got != tt.wantDays {
+				t.Errorf("DaysUntilFrom() = %d, want %d", got, tt.wantDays)
+			}
+		})
+	}
+}
+
+func TestCalendarDateInTimezone(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("America/New_York unavailable: %v", err)
+	}
+
+	stored := time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)
+	got := CalendarDateInTimezone(stored, newYork)
+	want := time.Date(2026, 3, 15, 0, 0, 0, 0, newYork)
+	if !got.Equal(want) {
+		t.Fatalf("CalendarDateInTimezone() = %v, want %v", got, want)
+	}
+	if shifted := NormalizeDateInTimezone(stored, newYork); shifted.Day() != 14 {
+		t.Fatalf("NormalizeDateInTimezone() day = %d, want 14 (instant conversion)", shifted.Day())
+	}
+}
@@ -0,0 +1,327 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/shiroha/subdux/internal/model"
+	"github.com/shiroha/subdux/internal/pkg"
+	"github.com/shiroha/subdux/internal/pkg/logging"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultAccountLockoutThreshold       = 10
+	minAccountLockoutThreshold           = 3
+	maxAccountLockoutThreshold           = 100
+	defaultAccountLockoutDurationMinutes = 15
+	maxAccountLockoutDurationMinutes     = 24 * 60
+
+	// accountThrottleFreeAttempts failures are allowed back to back; after
+	// that each attempt must wait twice as long as the one before, up to
+	// maxAccountThrottleDelay.
+	accountThrottleFreeAttempts = 3
+	maxAccountThrottleDelay     = time.Minute
+	// accountFailureResetWindow forgets failures that are older than this,
+	// so occasional typos spread over days never add up to a lockout.
+	accountFailureResetWindow = 24 * time.Hour
+
+	authFailurePassword     = "password"
+	authFailureSecondFactor = "second_factor"
+)
+
+var (
+	ErrInvalidAccountLockoutSettings = errors.New("invalid account lockout settings")
+	ErrAccountLocked                 = errors.New("account is temporarily locked after too many failed sign-in attempts")
+	ErrLoginThrottled                = errors.New("too many failed sign-in attempts")
+	ErrInvalidTOTPLoginCode          = errors.New("invalid code")
+)
+
+type accountLockoutConfig struct {
+	Enabled   bool
+	Threshold int
+	Duration  time.Duration
+}
+
+func loadAccountLockoutConfig(db *gorm.DB) accountLockoutConfig {
+	cfg := accountLockoutConfig{
+		Enabled:   true,
+		Threshold: defaultAccountLockoutThreshold,
+		Duration:  defaultAccountLockoutDurationMinutes * time.Minute,
+	}
+	if enabled, err := getBoolSystemSettingValue(db, "account_lockout_enabled", true); err == nil {
+		cfg.Enabled = enabled
+	}
+	if value, err := getSystemSettingValue(db, "account_lockout_threshold", ""); err == nil {
+		if v, err := strconv.Atoi(value); err == nil && v >= minAccountLockoutThreshold && v <= maxAccountLockoutThreshold {
+			cfg.Threshold = v
+		}
+	}
+	if value, err := getSystemSettingValue(db, "account_lockout_duration_minutes", ""); err == nil {
+		if v, err := strconv.Atoi(value); err == nil && v >= 1 && v <= maxAccountLockoutDurationMinutes {
+			cfg.Duration = time.Duration(v) * time.Minute
+		}
+	}
+	return cfg
+}
+
+func applyAccountLockoutSettings(tx *gorm.DB, input UpdateSettingsInput) error {
+	if input.AccountLockoutThreshold != nil &&
+		(*input.AccountLockoutThreshold < minAccountLockoutThreshold || *input.AccountLockoutThreshold > maxAccountLockoutThreshold) {
+		return fmt.Errorf("%w: threshold must be between %d and %d failed attempts",
+			ErrInvalidAccountLockoutSettings, minAccountLockoutThreshold, maxAccountLockoutThreshold)
+	}
+	if input.AccountLockoutDurationMinutes != nil &&
+		(*input.AccountLockoutDurationMinutes < 1 || *input.AccountLockoutDurationMinutes > maxAccountLockoutDurationMinutes) {
+		return fmt.Errorf("%w: duration must be between 1 and %d minutes",
+			ErrInvalidAccountLockoutSettings, maxAccountLockoutDurationMinutes)
+	}
+
+	if input.AccountLockoutEnabled != nil {
+		if err := saveBoolSystemSetting(tx, "account_lockout_enabled", *input.AccountLockoutEnabled); err != nil {
+			return err
+		}
+	}
+	if input.AccountLockoutThreshold != nil {
+		if err := saveStringSystemSetting(tx, "account_lockout_threshold", strconv.FormatInt(*input.AccountLockoutThreshold, 10)); err != nil {
+			return err
+		}
+	}
+	if input.AccountLockoutDurationMinutes != nil {
+		if err := saveStringSystemSetting(tx, "account_lockout_duration_minutes", strconv.FormatInt(*input.AccountLockoutDurationMinutes, 10)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// accountThrottleDelay is how long to wait after the given number of
+// consecutive failures before another attempt is accepted.
+func accountThrottleDelay(failures int) time.Duration {
+	if failures < accountThrottleFreeAttempts {
+		return 0
+	}
+	shift := failures - accountThrottleFreeAttempts
+	if shift >= 6 {
+		return maxAccountThrottleDelay
+	}
+	delay := time.Second << shift
+	if delay > maxAccountThrottleDelay {
+		return maxAccountThrottleDelay
+	}
+	return delay
+}
+
+func retryAfterSeconds(wait time.Duration) int {
+	seconds := int((wait + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		return 1
+	}
+	return seconds
+}
+
+// checkAccountLockout rejects a sign-in attempt while the account is locked
+// or still inside the delay that follows its last failure.
+func checkAccountLockout(db *gorm.DB, userID uint) error {
+	cfg := loadAccountLockoutConfig(db)
+	if !cfg.Enabled {
+		return nil
+	}
+
+	var lockout model.AccountLockout
+	if err := db.Where("user_id = ?", userID).First(&lockout).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	now := pkg.NowUTC()
+	if lockout.LockedUntil != nil {
+		if now.Before(*lockout.LockedUntil) {
+			return fmt.Errorf("%w; try again in %d seconds", ErrAccountLocked, retryAfterSeconds(lockout.LockedUntil.Sub(now)))
+		}
+		return nil
+	}
+	if lockout.LastFailedAt == nil || now.Sub(*lockout.LastFailedAt) >= accountFailureResetWindow {
+		return nil
+	}
+	if next := lockout.LastFailedAt.Add(accountThrottleDelay(lockout.FailedAttempts)); now.Before(next) {
+		return fmt.Errorf("%w; try again in %d seconds", ErrLoginThrottled, retryAfterSeconds(next.Sub(now)))
+	}
+	return nil
+}
+
+// recordAuthFailure counts a failed password or second-factor attempt and
+// locks the account once the configured threshold is reached. The user is
+// emailed and an audit event is written when a lockout starts.
+func recordAuthFailure(db *gorm.DB, user model.User, kind string) {
+	cfg := loadAccountLockoutConfig(db)
+	if !cfg.Enabled {
+		return
+	}
+
+	lockedUntil, attempts, err := incrementAuthFailures(db, user.ID, cfg)
+	if err != nil {
+		logging.Warn("failed to record sign-in failure",
+			slog.Uint64("user_id", uint64(user.ID)),
+			slog.Any("error", err))
+		return
+	}
+	if lockedUntil == nil {
+		return
+	}
+
+	logging.Warn("account locked after failed sign-in attempts",
+		slog.Uint64("user_id", uint64(user.ID)),
+		slog.Int("failed_attempts", attempts),
+		slog.String("last_failure", kind))
+	recordAccountLockoutAudit(db, user, kind, attempts, *lockedUntil)
+	sendAccountLockoutEmail(db, user, *lockedUntil)
+}
+
+// incrementAuthFailures bumps the counter with a single upsert so concurrent
+// attempts on different replicas are all counted. It returns the lock expiry
+// only to the caller whose failure started the lockout.
+func incrementAuthFailures(db *gorm.DB, userID uint, cfg accountLockoutConfig) (*time.Time, int, error) {
+	now := pkg.NowUTC()
+
+	// An expired lockout or a long quiet period starts the count over.
+	if err := db.Model(&model.AccountLockout{}).
+		Where("user_id = ? AND ((locked_until IS NOT NULL AND locked_until <= ?) OR last_failed_at < ?)",
+			userID, now, now.Add(-accountFailureResetWindow)).
+		Updates(map[string]interface{}{"failed_attempts": 0, "locked_until": nil}).Error; err != nil {
+		return nil, 0, err
+	}
+
+	row := model.AccountLockout{UserID: userID, FailedAttempts: 1, LastFailedAt: &now}
+	if err := db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"failed_attempts": gorm.Expr("failed_attempts + 1"),
+			"last_failed_at":  now,
+			"updated_at":      now,
+		}),
+	}).Create(&row).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var lockout model.AccountLockout
+	if err := db.Where("user_id = ?", userID).First(&lockout).Error; err != nil {
+		return nil, 0, err
+	}
+	if lockout.FailedAttempts < cfg.Threshold || lockout.LockedUntil != nil {
+		return nil, lockout.FailedAttempts, nil
+	}
+
+	lockedUntil := now.Add(cfg.Duration)
+	result := db.Model(&model.AccountLockout{}).
+		Where("user_id = ? AND locked_until IS NULL", userID).
+		Update("locked_until", lockedUntil)
+	if result.Error != nil {
+		return nil, 0, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, lockout.FailedAttempts, nil
+	}
+	return &lockedUntil, lockout.FailedAttempts, nil
+}
+
+// clearAuthFailures resets the counter after a complete sign-in.
+func clearAuthFailures(db *gorm.DB, userID uint) {
+	if err := db.Where("user_id = ?", userID).Delete(&model.AccountLockout{}).Error; err != nil {
+		logging.Warn("failed to clear sign-in failures",
+			slog.Uint64("user_id", uint64(userID)),
+			slog.Any("error", err))
+	}
+}
+
+func recordAccountLockoutAudit(db *gorm.DB, user model.User, kind string, attempts int, lockedUntil time.Time) {
+	audit := NewAuditService(db)
+	if enabled, err := audit.IsEnabled(); err != nil || !enabled {
+		return
+	}
+	client := sessionClientFromContext(db.Statement.Context)
+	if _, err := audit.Create(CreateAuditEventInput{
+		UserID:       user.ID,
+		KeyKind:      AuditKeyKindAnonymous,
+		Transport:    AuditTransportREST,
+		ToolName:     "account_lockout",
+		ResourceType: AuditResourceUser,
+		ResourceID:   strconv.FormatUint(uint64(user.ID), 10),
+		Action:       "lock",
+		ClientName:   client.UserAgent,
+		RequestID:    logging.RequestIDFromContext(db.Statement.Context),
+		AfterSnapshot: map[string]interface{}{
+			"failed_attempts": attempts,
+			"last_failure":    kind,
+			"ip_address":      client.IPAddress,
+			"locked_until":    lockedUntil,
+		},
+	}); err != nil {
+		logging.Warn("failed to record account lockout audit event",
+			slog.Uint64("user_id", uint64(user.ID)),
+			slog.Any("error", err))
+	}
+}
+
+// sendAccountLockoutEmail tells the account owner about the lockout. It is
+// skipped when SMTP is not configured. The message is delivered in the
+// background so a slow mail server does not hold up the failing sign-in.
+func sendAccountLockoutEmail(db *gorm.DB, user model.User, lockedUntil time.Time) {
+	if user.Email == "" {
+		return
+	}
+	cfg, err := loadSMTPRuntimeConfig(db)
+	if err != nil {
+		return
+	}
+
+	subject := "Subdux account temporarily locked"
+	body := fmt.Sprintf(
+		"Your Subdux account was locked after too many failed sign-in attempts.\r\nYou can sign in again after %s.\r\nIf these attempts were not yours, change your password once the lock expires, or ask an administrator to unlock the account.",
+		lockedUntil.Format(time.RFC3339),
+	)
+	message := buildSMTPMessage(cfg.FromEmail, cfg.FromName, user.Email, subject, body)
+	go func() {
+		if err := sendSMTPMessage(*cfg, user.Email, message); err != nil {
+			logging.Warn("failed to send account lockout email",
+				slog.Uint64("user_id", uint64(user.ID)),
+				slog.Any("error", err))
+		}
+	}()
+}
+
+// VerifyLoginCode checks the TOTP or backup code of a pending sign-in.
+// Failures count toward the account lockout and success clears them.
+func (s *TOTPService) VerifyLoginCode(userID uint, code string) error {
+	if err := checkAccountLockout(s.DB, userID); err != nil {
+		return err
+	}
+	if s.VerifyLogin(userID, code) || s.VerifyBackupCode(userID, code) {
+		clearAuthFailures(s.DB, userID)
+		return nil
+	}
+
+	var user model.User
+	if err := s.DB.First(&user, userID).Error; err == nil {
+		recordAuthFailure(s.DB, user, authFailureSecondFactor)
+	}
+	return ErrInvalidTOTPLoginCode
+}
+
+// UnlockUser clears a user's failed sign-in count and any active lockout.
+func (s *AdminService) UnlockUser(userID uint) error {
+	var user model.User
+	if err := s.DB.Select("id").First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	return s.DB.Where("user_id = ?", userID).Delete(&model.AccountLockout{}).Error
+}
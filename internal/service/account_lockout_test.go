@@ -0,0 +1,176 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shiroha/subdux/internal/model"
+	"github.com/shiroha/subdux/internal/pkg"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+func newLockoutTestUser(t *testing.T, db *gorm.DB, threshold int64) model.User {
+	t.Helper()
+	t.Setenv("JWT_SECRET", "lockout-test-secret-0123456789abcdef0123456789")
+
+	if err := applyAccountLockoutSettings(db, UpdateSettingsInput{AccountLockoutThreshold: &threshold}); err != nil {
+		t.Fatalf("applyAccountLockoutSettings() error = %v", err)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte("correct-password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("failed to hash password: %v", err)
+	}
+	user := model.User{Username: "alice", Email: "alice@example.com", Password: string(hash), Role: "user", Status: "active"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	return user
+}
+
+// skipThrottleDelay moves the last failure far enough into the past that the
+// progressive delay no longer applies.
+func skipThrottleDelay(t *testing.T, db *gorm.DB, userID uint) {
+	t.Helper()
+	past := pkg.NowUTC().Add(-2 * maxAccountThrottleDelay)
+	if err := db.Model(&model.AccountLockout{}).Where("user_id = ?", userID).Update("last_failed_at", past).Error; err != nil {
+		t.Fatalf("failed to rewind last failure: %v", err)
+	}
+}
+
+func TestLoginLocksAccountAfterRepeatedFailures(t *testing.T) {
+	db := newTestDB(t)
+	user := newLockoutTestUser(t, db, 3)
+	auth := NewAuthService(db)
+
+	for i := 0; i < 3; i++ {
+		skipThrottleDelay(t, db, user.ID)
+		if _, err := auth.Login(LoginInput{Identifier: "alice", Password: "wrong"}); err == nil || err.Error() != "invalid credentials" {
+			t.Fatalf("attempt %d error = %v, want invalid credentials", i+1, err)
+		}
+	}
+
+	if _, err := auth.Login(LoginInput{Identifier: "alice", Password: "correct-password"}); !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("Login() while locked error = %v, want ErrAccountLocked", err)
+	}
+
+	if err := NewAdminService(db).UnlockUser(user.ID); err != nil {
+		t.Fatalf("UnlockUser() error = %v", err)
+	}
+	if _, err := auth.Login(LoginInput{Identifier: "alice", Password: "correct-password"}); err != nil {
+		t.Fatalf("Login() after unlock error = %v", err)
+	}
+	if err := NewAdminService(db).UnlockUser(user.ID + 100); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("UnlockUser() for unknown user error = %v, want ErrUserNotFound", err)
+	}
+}
+
+func TestLoginThrottlesBeforeLockout(t *testing.T) {
+	db := newTestDB(t)
+	user := newLockoutTestUser(t, db, 10)
+	auth := NewAuthService(db)
+
+	for i := 0; i < accountThrottleFreeAttempts; i++ {
+		if _, err := auth.Login(LoginInput{Identifier: "alice", Password: "wrong"}); errors.Is(err, ErrLoginThrottled) {
+			t.Fatalf("attempt %d was throttled inside the free attempts", i+1)
+		}
+	}
+	if _, err := auth.Login(LoginInput{Identifier: "alice", Password: "correct-password"}); !errors.Is(err, ErrLoginThrottled) {
+		t.Fatalf("Login() inside delay error = %v, want ErrLoginThrottled", err)
+	}
+
+	skipThrottleDelay(t, db, user.ID)
+	if _, err := auth.Login(LoginInput{Identifier: "alice", Password: "correct-password"}); err != nil {
+		t.Fatalf("Login() after delay error = %v", err)
+	}
+	var count int64
+	db.Model(&model.AccountLockout{}).Where("user_id = ?", user.ID).Count(&count)
+	if count != 0 {
+		t.Fatal("successful sign-in did not clear the failure count")
+	}
+}
+
+func TestTOTPLoginCodeFailuresCountTowardLockout(t *testing.T) {
+	db := newTestDB(t)
+	user := newLockoutTestUser(t, db, 3)
+	secret := "JBSWY3DPEHPK3PXP"
+	if err := db.Model(&user).Updates(map[string]interface{}{"totp_enabled": true, "totp_secret": secret}).Error; err != nil {
+		t.Fatalf("failed to enable totp: %v", err)
+	}
+	totpSvc := NewTOTPService(db)
+
+	// A correct password must not reset failures for an account with 2FA.
+	for i := 0; i < 2; i++ {
+		skipThrottleDelay(t, db, user.ID)
+		if err := totpSvc.VerifyLoginCode(user.ID, "not-a-code"); !errors.Is(err, ErrInvalidTOTPLoginCode) {
+			t.Fatalf("VerifyLoginCode() error = %v, want ErrInvalidTOTPLoginCode", err)
+		}
+		skipThrottleDelay(t, db, user.ID)
+		resp, err := NewAuthService(db).Login(LoginInput{Identifier: "alice", Password: "correct-password"})
+		if err != nil || !resp.RequiresTotp {
+			t.Fatalf("Login() = %+v, %v, want pending 2FA", resp, err)
+		}
+	}
+	skipThrottleDelay(t, db, user.ID)
+	if err := totpSvc.VerifyLoginCode(user.ID, "not-a-code"); !errors.Is(err, ErrInvalidTOTPLoginCode) {
+		t.Fatalf("VerifyLoginCode() error = %v, want ErrInvalidTOTPLoginCode", err)
+	}
+	if err := totpSvc.VerifyLoginCode(user.ID, "not-a-code"); !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("VerifyLoginCode() while locked error = %v, want ErrAccountLocked", err)
+	}
+}
+
+func TestExpiredLockoutRestartsCount(t *testing.T) {
+	db := newTestDB(t)
+	user := newLockoutTestUser(t, db, 3)
+
+	past := pkg.NowUTC().Add(-time.Minute)
+	if err := db.Create(&model.AccountLockout{UserID: user.ID, FailedAttempts: 3, LastFailedAt: &past, LockedUntil: &past}).Error; err != nil {
+		t.Fatalf("failed to seed lockout: %v", err)
+	}
+	if err := checkAccountLockout(db, user.ID); err != nil {
+		t.Fatalf("checkAccountLockout() after expiry error = %v", err)
+	}
+
+	recordAuthFailure(db, user, authFailurePassword)
+	var lockout model.AccountLockout
+	if err := db.First(&lockout, "user_id = ?", user.ID).Error; err != nil {
+		t.Fatalf("failed to load lockout: %v", err)
+	}
+	if lockout.FailedAttempts != 1 || lockout.LockedUntil != nil {
+		t.Fatalf("lockout = %+v, want a fresh count of 1", lockout)
+	}
+}
+
+func TestAccountThrottleDelay(t *testing.T) {
+	cases := map[int]time.Duration{
+		0:  0,
+		2:  0,
+		3:  time.Second,
+		4:  2 * time.Second,
+		8:  32 * time.Second,
+		9:  maxAccountThrottleDelay,
+		50: maxAccountThrottleDelay,
+	}
+	for failures, want := range cases {
+		if got := accountThrottleDelay(failures); got != want {
+			t.Fatalf("accountThrottleDelay(%d) = %v, want %v", failures, got, want)
+		}
+	}
+}
+
+func TestApplyAccountLockoutSettingsValidation(t *testing.T) {
+	db := newTestDB(t)
+	low := int64(2)
+	zero := int64(0)
+
+	for _, input := range []UpdateSettingsInput{
+		{AccountLockoutThreshold: &low},
+		{AccountLockoutDurationMinutes: &zero},
+	} {
+		if err := applyAccountLockoutSettings(db, input); !errors.Is(err, ErrInvalidAccountLockoutSettings) {
+			t.Fatalf("applyAccountLockoutSettings(%+v) error = %v, want ErrInvalidAccountLockoutSettings", input, err)
+		}
+	}
+}
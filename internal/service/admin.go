@@ -37,6 +37,8 @@ type SystemSettings struct {
 	ExchangeRateSource                   string `json:"exchange_rate_source"`
 	AllowImageUpload                     bool   `json:"allow_image_upload"`
 	MaxIconFileSize                      int64  `json:"max_icon_file_size"`
+	MaxAttachmentFileSize                int64  `json:"max_attachment_file_size"`
+	AttachmentQuotaPerUser               int64  `json:"attachment_quota_per_user"`
 	IconProxyEnabled                     bool   `json:"icon_proxy_enabled"`
 	IconProxyDomainWhitelist             string `json:"icon_proxy_domain_whitelist"`
 	MCPEnabled                           bool   `json:"mcp_enabled"`
@@ -78,6 +80,36 @@ type SystemSettings struct {
 	OIDCAudience                         string `json:"oidc_audience"`
 	OIDCResource                         string `json:"oidc_resource"`
 	OIDCExtraAuthParams                  string `json:"oidc_extra_auth_params"`
+	OIDCAllowedDomains                   string `json:"oidc_allowed_domains"`
+	OIDCRoleClaim                        string `json:"oidc_role_claim"`
+	OIDCAdminValues                      string `json:"oidc_admin_values"`
+	OIDCStatusClaim                      string `json:"oidc_status_claim"`
+	OIDCActiveValues                     string `json:"oidc_active_values"`
+	LDAPEnabled                          bool   `json:"ldap_enabled"`
+	LDAPURL                              string `json:"ldap_url"`
+	LDAPStartTLS                         bool   `json:"ldap_start_tls"`
+	LDAPSkipTLSVerify                    bool   `json:"ldap_skip_tls_verify"`
+	LDAPBindDN                           string `json:"ldap_bind_dn"`
+	LDAPBindPasswordSet                  bool   `json:"ldap_bind_password_configured"`
+	LDAPBaseDN                           string `json:"ldap_base_dn"`
+	LDAPUserFilter                       string `json:"ldap_user_filter"`
+	LDAPUsernameAttribute                string `json:"ldap_username_attribute"`
+	LDAPEmailAttribute                   string `json:"ldap_email_attribute"`
+	LDAPGroupAttribute                   string `json:"ldap_group_attribute"`
+	LDAPAdminGroups                      string `json:"ldap_admin_groups"`
+	LDAPAllowedGroups                    string `json:"ldap_allowed_groups"`
+	LDAPAutoCreateUser                   bool   `json:"ldap_auto_create_user"`
+	LDAPTimeoutSeconds                   int64  `json:"ldap_timeout_seconds"`
+	ProxyAuthEnabled                     bool   `json:"proxy_auth_enabled"`
+	ProxyAuthTrustedCIDRs                string `json:"proxy_auth_trusted_cidrs"`
+	ProxyAuthUserHeader                  string `json:"proxy_auth_user_header"`
+	ProxyAuthEmailHeader                 string `json:"proxy_auth_email_header"`
+	ProxyAuthGroupsHeader                string `json:"proxy_auth_groups_header"`
+	ProxyAuthAdminGroups                 string `json:"proxy_auth_admin_groups"`
+	ProxyAuthAutoCreateUser              bool   `json:"proxy_auth_auto_create_user"`
+	AccountLockoutEnabled                bool   `json:"account_lockout_enabled"`
+	AccountLockoutThreshold              int64  `json:"account_lockout_threshold"`
+	AccountLockoutDurationMinutes        int64  `json:"account_lockout_duration_minutes"`
 	BackupScheduleEnabled                bool   `json:"backup_schedule_enabled"`
 	BackupTimeOfDay                      string `json:"backup_time_of_day"`
 	BackupIncludeAssets                  bool   `json:"backup_include_assets"`
@@ -88,6 +120,13 @@ type SystemSettings struct {
 	BackupLastRunAt                      string `json:"backup_last_run_at"`
 	BackupLastStatus                     string `json:"backup_last_status"`
 	BackupLastError                      string `json:"backup_last_error"`
+	NotificationRetryMaxAttempts         int64  `json:"notification_retry_max_attempts"`
+	NotificationRetryBackoffMinutes      string `json:"notification_retry_backoff_minutes"`
+	AuditRetentionDays                   int64  `json:"audit_retention_days"`
+	AuditSinkType                        string `json:"audit_sink_type"`
+	AuditSyslogNetwork                   string `json:"audit_syslog_network"`
+	AuditSyslogAddress                   string `json:"audit_syslog_address"`
+	AuditFilePath                        string `json:"audit_file_path"`
 }
 
 type UpdateSettingsInput struct {
@@ -100,6 +139,8 @@ type UpdateSettingsInput struct {
 	ExchangeRateSource                   *string `json:"exchange_rate_source"`
 	AllowImageUpload                     *bool   `json:"allow_image_upload"`
 	MaxIconFileSize                      *int64  `json:"max_icon_file_size"`
+	MaxAttachmentFileSize                *int64  `json:"max_attachment_file_size"`
+	AttachmentQuotaPerUser               *int64  `json:"attachment_quota_per_user"`
 	IconProxyEnabled                     *bool   `json:"icon_proxy_enabled"`
 	IconProxyDomainWhitelist             *string `json:"icon_proxy_domain_whitelist"`
 	MCPEnabled                           *bool   `json:"mcp_enabled"`
@@ -141,6 +182,36 @@ type UpdateSettingsInput struct {
 	OIDCAudience                         *string `json:"oidc_audience"`
 	OIDCResource                         *string `json:"oidc_resource"`
 	OIDCExtraAuthParams                  *string `json:"oidc_extra_auth_params"`
+	OIDCAllowedDomains                   *string `json:"oidc_allowed_domains"`
+	OIDCRoleClaim                        *string `json:"oidc_role_claim"`
+	OIDCAdminValues                      *string `json:"oidc_admin_values"`
+	OIDCStatusClaim                      *string `json:"oidc_status_claim"`
+	OIDCActiveValues                     *string `json:"oidc_active_values"`
+	LDAPEnabled                          *bool   `json:"ldap_enabled"`
+	LDAPURL                              *string `json:"ldap_url"`
+	LDAPStartTLS                         *bool   `json:"ldap_start_tls"`
+	LDAPSkipTLSVerify                    *bool   `json:"ldap_skip_tls_verify"`
+	LDAPBindDN                           *string `json:"ldap_bind_dn"`
+	LDAPBindPassword                     *string `json:"ldap_bind_password"`
+	LDAPBaseDN                           *string `json:"ldap_base_dn"`
+	LDAPUserFilter                       *string `json:"ldap_user_filter"`
+	LDAPUsernameAttribute                *string `json:"ldap_username_attribute"`
+	LDAPEmailAttribute                   *string `json:"ldap_email_attribute"`
+	LDAPGroupAttribute                   *string `json:"ldap_group_attribute"`
+	LDAPAdminGroups                      *string `json:"ldap_admin_groups"`
+	LDAPAllowedGroups                    *string `json:"ldap_allowed_groups"`
+	LDAPAutoCreateUser                   *bool   `json:"ldap_auto_create_user"`
+	LDAPTimeoutSeconds                   *int64  `json:"ldap_timeout_seconds"`
+	ProxyAuthEnabled                     *bool   `json:"proxy_auth_enabled"`
+	ProxyAuthTrustedCIDRs                *string `json:"proxy_auth_trusted_cidrs"`
+	ProxyAuthUserHeader                  *string `json:"proxy_auth_user_header"`
+	ProxyAuthEmailHeader                 *string `json:"proxy_auth_email_header"`
+	ProxyAuthGroupsHeader                *string `json:"proxy_auth_groups_header"`
+	ProxyAuthAdminGroups                 *string `json:"proxy_auth_admin_groups"`
+	ProxyAuthAutoCreateUser              *bool   `json:"proxy_auth_auto_create_user"`
+	AccountLockoutEnabled                *bool   `json:"account_lockout_enabled"`
+	AccountLockoutThreshold              *int64  `json:"account_lockout_threshold"`
+	AccountLockoutDurationMinutes        *int64  `json:"account_lockout_duration_minutes"`
 	BackupScheduleEnabled                *bool   `json:"backup_schedule_enabled"`
 	BackupTimeOfDay                      *string `json:"backup_time_of_day"`
 	BackupIncludeAssets                  *bool   `json:"backup_include_assets"`
@@ -148,6 +219,13 @@ type UpdateSettingsInput struct {
 	BackupEncryptionPassword             *string `json:"backup_encryption_password"`
 	BackupLocalDir                       *string `json:"backup_local_dir"`
 	BackupRetentionCount                 *int64  `json:"backup_retention_count"`
+	NotificationRetryMaxAttempts         *int64  `json:"notification_retry_max_attempts"`
+	NotificationRetryBackoffMinutes      *string `json:"notification_retry_backoff_minutes"`
+	AuditRetentionDays                   *int64  `json:"audit_retention_days"`
+	AuditSinkType                        *string `json:"audit_sink_type"`
+	AuditSyslogNetwork                   *string `json:"audit_syslog_network"`
+	AuditSyslogAddress                   *string `json:"audit_syslog_address"`
+	AuditFilePath                        *string `json:"audit_file_path"`
 }
 
 var ErrInvalidSSRFTestTarget = errors.New("ssrf test target must be a valid hostname or ip address")
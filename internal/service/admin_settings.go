@@ -43,6 +43,14 @@ func (s *AdminService) GetSettings() (*SystemSettings, error) {
 			if v, err := strconv.ParseInt(settingValue, 10, 64); err == nil {
 				settings.MaxIconFileSize = v
 			}
+		case maxAttachmentFileSizeKey:
+			if v, err := strconv.ParseInt(settingValue, 10, 64); err == nil {
+				settings.MaxAttachmentFileSize = v
+			}
+		case attachmentQuotaPerUserKey:
+			if v, err := strconv.ParseInt(settingValue, 10, 64); err == nil {
+				settings.AttachmentQuotaPerUser = v
+			}
 		case "icon_proxy_enabled":
 			settings.IconProxyEnabled = settingValue == "true"
 		case "icon_proxy_domain_whitelist":
@@ -141,6 +149,72 @@ func (s *AdminService) GetSettings() (*SystemSettings, error) {
 			settings.OIDCResource = settingValue
 		case "oidc_extra_auth_params":
 			settings.OIDCExtraAuthParams = settingValue
+		case "oidc_allowed_domains":
+			settings.OIDCAllowedDomains = settingValue
+		case "oidc_role_claim":
+			settings.OIDCRoleClaim = settingValue
+		case "oidc_admin_values":
+			settings.OIDCAdminValues = settingValue
+		case "oidc_status_claim":
+			settings.OIDCStatusClaim = settingValue
+		case "oidc_active_values":
+			settings.OIDCActiveValues = settingValue
+		case "ldap_enabled":
+			settings.LDAPEnabled = settingValue == "true"
+		case "ldap_url":
+			settings.LDAPURL = settingValue
+		case "ldap_start_tls":
+			settings.LDAPStartTLS = settingValue == "true"
+		case "ldap_skip_tls_verify":
+			settings.LDAPSkipTLSVerify = settingValue == "true"
+		case "ldap_bind_dn":
+			settings.LDAPBindDN = settingValue
+		case "ldap_bind_password":
+			settings.LDAPBindPasswordSet = strings.TrimSpace(settingValue) != ""
+		case "ldap_base_dn":
+			settings.LDAPBaseDN = settingValue
+		case "ldap_user_filter":
+			settings.LDAPUserFilter = settingValue
+		case "ldap_username_attribute":
+			settings.LDAPUsernameAttribute = settingValue
+		case "ldap_email_attribute":
+			settings.LDAPEmailAttribute = settingValue
+		case "ldap_group_attribute":
+			settings.LDAPGroupAttribute = settingValue
+		case "ldap_admin_groups":
+			settings.LDAPAdminGroups = settingValue
+		case "ldap_allowed_groups":
+			settings.LDAPAllowedGroups = settingValue
+		case "ldap_auto_create_user":
+			settings.LDAPAutoCreateUser = settingValue == "true"
+		case "ldap_timeout_seconds":
+			if v, err := strconv.ParseInt(settingValue, 10, 64); err == nil {
+				settings.LDAPTimeoutSeconds = v
+			}
+		case "proxy_auth_enabled":
+			settings.ProxyAuthEnabled = settingValue == "true"
+		case "proxy_auth_trusted_cidrs":
+			settings.ProxyAuthTrustedCIDRs = settingValue
+		case "proxy_auth_user_header":
+			settings.ProxyAuthUserHeader = settingValue
+		case "proxy_auth_email_header":
+			settings.ProxyAuthEmailHeader = settingValue
+		case "proxy_auth_groups_header":
+			settings.ProxyAuthGroupsHeader = settingValue
+		case "proxy_auth_admin_groups":
+			settings.ProxyAuthAdminGroups = settingValue
+		case "proxy_auth_auto_create_user":
+			settings.ProxyAuthAutoCreateUser = settingValue == "true"
+		case "account_lockout_enabled":
+			settings.AccountLockoutEnabled = settingValue == "true"
+		case "account_lockout_threshold":
+			if v, err := strconv.ParseInt(settingValue, 10, 64); err == nil {
+				settings.AccountLockoutThreshold = v
+			}
+		case "account_lockout_duration_minutes":
+			if v, err := strconv.ParseInt(settingValue, 10, 64); err == nil {
+				settings.AccountLockoutDurationMinutes = v
+			}
 		case backupScheduleEnabledKey:
 			settings.BackupScheduleEnabled = settingValue == "true"
 		case backupTimeOfDayKey:
@@ -163,6 +237,24 @@ func (s *AdminService) GetSettings() (*SystemSettings, error) {
 			settings.BackupLastStatus = settingValue
 		case backupLastErrorKey:
 			settings.BackupLastError = settingValue
+		case notificationRetryMaxAttemptsKey:
+			if v, err := strconv.ParseInt(settingValue, 10, 64); err == nil {
+				settings.NotificationRetryMaxAttempts = v
+			}
+		case notificationRetryBackoffMinutesKey:
+			settings.NotificationRetryBackoffMinutes = settingValue
+		case auditRetentionDaysKey:
+			if v, err := strconv.ParseInt(settingValue, 10, 64); err == nil {
+				settings.AuditRetentionDays = v
+			}
+		case auditSinkTypeKey:
+			settings.AuditSinkType = settingValue
+		case auditSyslogNetworkKey:
+			settings.AuditSyslogNetwork = settingValue
+		case auditSyslogAddressKey:
+			settings.AuditSyslogAddress = settingValue
+		case auditFilePathKey:
+			settings.AuditFilePath = settingValue
 		}
 	}
 
@@ -229,6 +321,24 @@ func (s *AdminService) UpdateSettings(input UpdateSettingsInput) error {
 			}
 		}
 
+		if input.MaxAttachmentFileSize != nil {
+			if *input.MaxAttachmentFileSize <= 0 || *input.MaxAttachmentFileSize > MaxAttachmentUploadBytes {
+				return ErrInvalidAttachmentLimit
+			}
+			if err := saveStringSystemSetting(tx, maxAttachmentFileSizeKey, strconv.FormatInt(*input.MaxAttachmentFileSize, 10)); err != nil {
+				return err
+			}
+		}
+
+		if input.AttachmentQuotaPerUser != nil {
+			if *input.AttachmentQuotaPerUser <= 0 {
+				return ErrInvalidAttachmentLimit
+			}
+			if err := saveStringSystemSetting(tx, attachmentQuotaPerUserKey, strconv.FormatInt(*input.AttachmentQuotaPerUser, 10)); err != nil {
+				return err
+			}
+		}
+
 		if input.IconProxyEnabled != nil {
 			if err := saveBoolSystemSetting(tx, "icon_proxy_enabled", *input.IconProxyEnabled); err != nil {
 				return err
@@ -533,10 +643,34 @@ func (s *AdminService) UpdateSettings(input UpdateSettingsInput) error {
 			}
 		}
 
+		if err := applyOIDCMappingSettings(tx, input); err != nil {
+			return err
+		}
+
+		if err := applyLDAPSettings(tx, input); err != nil {
+			return err
+		}
+
+		if err := applyProxyAuthSettings(tx, input); err != nil {
+			return err
+		}
+
+		if err := applyAccountLockoutSettings(tx, input); err != nil {
+			return err
+		}
+
 		if err := applyBackupSettings(tx, input); err != nil {
 			return err
 		}
 
+		if err := applyNotificationRetrySettings(tx, input); err != nil {
+			return err
+		}
+
+		if err := applyAuditSettings(tx, input); err != nil {
+			return err
+		}
+
 		registrationEmailVerificationEnabled, err := isSystemSettingEnabled(
 			tx,
 			"registration_email_verification_enabled",
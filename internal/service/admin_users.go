@@ -90,6 +90,7 @@ func (s *AdminService) DeleteUser(userID uint) error {
 			_ = os.Remove(path)
 		}
 	}
+	removeUserAttachmentFiles(userID)
 
 	return nil
 }
@@ -149,6 +150,11 @@ func deleteUserOwnedRecords(tx *gorm.DB, userID uint) error {
 		&model.NotificationLog{},
 		&model.NotificationOutbox{},
 		&model.SubscriptionActionSnooze{},
+		&model.SubscriptionPriceChange{},
+		&model.SubscriptionUsage{},
+		&model.SubscriptionCustomFieldValue{},
+		&model.SubscriptionAttachment{},
+		&model.CustomFieldDefinition{},
 		&model.SubscriptionEvent{},
 		&model.Subscription{},
 		&model.NotificationChannel{},
@@ -164,6 +170,9 @@ func deleteUserOwnedRecords(tx *gorm.DB, userID uint) error {
 		&model.UserBackupCode{},
 		&model.PasskeyCredential{},
 		&model.OIDCConnection{},
+		&model.SCIMIdentity{},
+		&model.LDAPIdentity{},
+		&model.AccountLockout{},
 		&model.EmailVerificationCode{},
 	} {
 		if !tx.Migrator().HasTable(value) {
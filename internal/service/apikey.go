@@ -32,6 +32,9 @@ const maxAPIKeysPerUser = 5
 const (
 	APIKeyScopeRead  = "read"
 	APIKeyScopeWrite = "write"
+	// APIKeyScopeUsage only allows logging subscription check-ins, so a
+	// script can ping usage without being able to change anything else.
+	APIKeyScopeUsage = "usage"
 )
 
 const (
@@ -44,6 +47,7 @@ var (
 	validAPIKeyScopes   = map[string]struct{}{
 		APIKeyScopeRead:  {},
 		APIKeyScopeWrite: {},
+		APIKeyScopeUsage: {},
 	}
 	validAPIKeyKinds = map[string]struct{}{
 		APIKeyKindMCPClient:      {},
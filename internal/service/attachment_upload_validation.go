@@ -0,0 +1,115 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const maxAttachmentFilenameLength = 255
+
+var (
+	ErrAttachmentUnsupportedType = errors.New("only PDF, PNG, and JPG files are supported")
+	ErrAttachmentSizeLimit       = errors.New("file size exceeds limit")
+	ErrAttachmentContentMismatch = errors.New("attachment file content does not match file extension")
+)
+
+// ValidateAttachmentFile reads an attachment, checks that its content matches
+// its extension and returns the bytes together with the normalized extension
+// and content type. Unlike icons, attachments are stored unmodified: PDFs
+// cannot be re-encoded and receipts should stay byte-identical. They are only
+// ever served behind authorization with a sandboxing CSP.
+func ValidateAttachmentFile(file io.Reader, filename string, maxSize int64) ([]byte, string, string, error) {
+	ext := normalizeAttachmentExtension(filename)
+	if ext == "" {
+		return nil, "", "", ErrAttachmentUnsupportedType
+	}
+
+	buf, err := io.ReadAll(io.LimitReader(file, maxSize+1))
+	if err != nil {
+		return nil, "", "", errors.New("failed to read file")
+	}
+	if int64(len(buf)) > maxSize {
+		return nil, "", "", ErrAttachmentSizeLimit
+	}
+
+	detected, err := detectAttachmentExtension(buf)
+	if err != nil {
+		return nil, "", "", err
+	}
+	if detected != ext {
+		return nil, "", "", ErrAttachmentContentMismatch
+	}
+	return buf, ext, attachmentContentType(ext), nil
+}
+
+func normalizeAttachmentExtension(filename string) string {
+	switch ext := strings.ToLower(filepath.Ext(filename)); ext {
+	case ".pdf", ".png", ".jpg":
+		return ext
+	case ".jpeg":
+		return ".jpg"
+	default:
+		return ""
+	}
+}
+
+func detectAttachmentExtension(buf []byte) (string, error) {
+	switch {
+	case bytes.HasPrefix(buf, []byte("%PDF-")):
+		return ".pdf", nil
+	case hasPNGSignature(buf):
+		if _, err := png.DecodeConfig(bytes.NewReader(buf)); err != nil {
+			return "", ErrAttachmentUnsupportedType
+		}
+		return ".png", nil
+	case hasJPEGSignature(buf):
+		if _, err := jpeg.DecodeConfig(bytes.NewReader(buf)); err != nil {
+			return "", ErrAttachmentUnsupportedType
+		}
+		return ".jpg", nil
+	default:
+		return "", ErrAttachmentUnsupportedType
+	}
+}
+
+func attachmentContentType(ext string) string {
+	switch ext {
+	case ".pdf":
+		return "application/pdf"
+	case ".png":
+		return "image/png"
+	case ".jpg":
+		return "image/jpeg"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// sanitizeAttachmentFilename keeps the base name of an uploaded file for
+// display, dropping directories and control characters.
+func sanitizeAttachmentFilename(filename, ext string) string {
+	base := filepath.Base(strings.ReplaceAll(filename, `\`, "/"))
+	base = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, base)
+	base = strings.TrimSpace(base)
+	if base == "" || base == "." || base == "/" {
+		base = "attachment" + ext
+	}
+	if utf8.RuneCountInString(base) > maxAttachmentFilenameLength {
+		suffix := filepath.Ext(base)
+		stem := []rune(strings.TrimSuffix(base, suffix))
+		base = string(stem[:maxAttachmentFilenameLength-utf8.RuneCountInString(suffix)]) + suffix
+	}
+	return base
+}
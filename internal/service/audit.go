@@ -13,12 +13,48 @@ import (
 )
 
 const (
-	AuditTransportMCP = "mcp"
+	AuditTransportMCP  = "mcp"
+	AuditTransportREST = "rest"
+	AuditTransportSCIM = "scim"
+
+	// AuditKeyKindSession and AuditKeyKindAnonymous describe REST callers that
+	// did not use an API key: a signed-in browser session, or an unauthenticated
+	// request such as a login attempt.
+	AuditKeyKindSession   = "session"
+	AuditKeyKindAnonymous = "anonymous"
+	// AuditKeyKindSCIMToken marks changes made by the identity provider
+	// through the SCIM provisioning token.
+	AuditKeyKindSCIMToken = "scim_token"
 
 	AuditStatusSuccess = "success"
 	AuditStatusError   = "error"
 
-	AuditResourceSubscription = "subscription"
+	AuditResourceSubscription        = "subscription"
+	AuditResourceCategory            = "category"
+	AuditResourceCustomField         = "custom_field"
+	AuditResourcePaymentMethod       = "payment_method"
+	AuditResourceCurrency            = "currency"
+	AuditResourceNotificationChannel = "notification_channel"
+	AuditResourceNotificationPolicy  = "notification_policy"
+	AuditResourceNotificationOutbox  = "notification_outbox"
+	AuditResourceTemplate            = "notification_template"
+	AuditResourcePreference          = "preference"
+	AuditResourceAPIKey              = "api_key"
+	AuditResourceCalendarToken       = "calendar_token"
+	AuditResourceImport              = "import"
+	AuditResourceUser                = "user"
+	AuditResourceSettings            = "settings"
+	AuditResourceBackup              = "backup"
+	AuditResourceExchangeRate        = "exchange_rate"
+	AuditResourceSession             = "session"
+	AuditResourcePassword            = "password"
+	AuditResourceEmail               = "email"
+	AuditResourceTOTP                = "totp"
+	AuditResourcePasskey             = "passkey"
+	AuditResourceOIDCConnection      = "oidc_connection"
+	AuditResourceOIDCProvider        = "oidc_provider"
+	AuditResourceSCIMToken           = "scim_token"
+	AuditResourceGroup               = "group"
 
 	maxAuditJSONBytes  = 8 << 10
 	maxAuditErrorBytes = 2 << 10
@@ -94,22 +130,25 @@ func (s *AuditService) Create(input CreateAuditEventInput) (*model.AuditEvent, e
 		AfterSnapshot:       marshalCappedJSON(input.AfterSnapshot, maxAuditJSONBytes),
 	}
 
-	if event.KeyKind == "" {
-		event.KeyKind = APIKeyKindMCPClient
-	}
-	if event.ScopeUsed == "" {
-		event.ScopeUsed = APIKeyScopeWrite
-	}
 	if event.Transport == "" {
 		event.Transport = AuditTransportMCP
 	}
+	if event.Transport == AuditTransportMCP {
+		if event.KeyKind == "" {
+			event.KeyKind = APIKeyKindMCPClient
+		}
+		if event.ScopeUsed == "" {
+			event.ScopeUsed = APIKeyScopeWrite
+		}
+	}
 	if event.Status == "" {
 		event.Status = AuditStatusSuccess
 	}
 
-	if err := s.DB.Create(event).Error; err != nil {
+	if err := s.appendToChain(event); err != nil {
 		return nil, err
 	}
+	s.forwardToSink(*event)
 	return event, nil
 }
 
@@ -143,6 +182,23 @@ func (s *AuditService) List(filter AuditEventFilter) ([]model.AuditEvent, error)
 	return events, nil
 }
 
+// RedactAuditSnapshot converts value to its JSON shape and redacts secret-like
+// fields, so arbitrary structs can be stored as audit snapshots.
+func RedactAuditSnapshot(value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil
+	}
+	var generic interface{}
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return nil
+	}
+	return redactAuditValue(generic)
+}
+
 func generateAuditEventID() (string, error) {
 	b := make([]byte, 16)
 	if _, err := rand.Read(b); err != nil {
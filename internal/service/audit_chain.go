@@ -0,0 +1,191 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/shiroha/subdux/internal/model"
+	"github.com/shiroha/subdux/internal/pkg"
+	"gorm.io/gorm"
+)
+
+const (
+	AuditChainIssueGap          = "gap"
+	AuditChainIssueBrokenLink   = "broken_link"
+	AuditChainIssueHashMismatch = "hash_mismatch"
+
+	auditChainVerifyBatchSize = 500
+	maxAuditChainIssues       = 200
+)
+
+// AuditChainIssue is one inconsistency found while walking a user's chain.
+type AuditChainIssue struct {
+	UserID   uint   `json:"user_id"`
+	Sequence int64  `json:"sequence"`
+	EventID  string `json:"event_id,omitempty"`
+	Kind     string `json:"kind"`
+	Detail   string `json:"detail"`
+}
+
+// AuditChainUserReport summarizes one user's chain. LastSequence and LastHash
+// identify the head, which can be compared with a copy held outside the
+// database to detect removal of the newest events.
+type AuditChainUserReport struct {
+	UserID        uint   `json:"user_id"`
+	Events        int64  `json:"events"`
+	PurgedThrough int64  `json:"purged_through"`
+	LastSequence  int64  `json:"last_sequence"`
+	LastHash      string `json:"last_hash"`
+	Issues        int    `json:"issues"`
+	Intact        bool   `json:"intact"`
+}
+
+type AuditChainReport struct {
+	Verified        bool                   `json:"verified"`
+	EventsChecked   int64                  `json:"events_checked"`
+	Users           []AuditChainUserReport `json:"users"`
+	Issues          []AuditChainIssue      `json:"issues"`
+	IssuesTruncated bool                   `json:"issues_truncated"`
+}
+
+// appendToChain assigns the event the next sequence in its user's chain,
+// links it to the previous event's hash and inserts it. The unique
+// (user_id, sequence) index rejects a concurrent writer that raced us.
+func (s *AuditService) appendToChain(event *model.AuditEvent) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		sequence, prevHash, err := auditChainHead(tx, event.UserID)
+		if err != nil {
+			return err
+		}
+		event.Sequence = sequence + 1
+		event.PrevHash = prevHash
+		hash, err := pkg.AuditEventHash(*event)
+		if err != nil {
+			return err
+		}
+		event.Hash = hash
+		return tx.Create(event).Error
+	})
+}
+
+// auditChainHead returns the sequence and hash the next event must link to:
+// the newest stored event, else the retention checkpoint, else the empty
+// genesis link.
+func auditChainHead(db *gorm.DB, userID uint) (int64, string, error) {
+	var last model.AuditEvent
+	if err := db.Where("user_id = ?", userID).Order("sequence DESC").Limit(1).Find(&last).Error; err != nil {
+		return 0, "", err
+	}
+	if last.EventID != "" {
+		return last.Sequence, last.Hash, nil
+	}
+
+	var checkpoint model.AuditChainCheckpoint
+	if err := db.Where("user_id = ?", userID).Limit(1).Find(&checkpoint).Error; err != nil {
+		return 0, "", err
+	}
+	return checkpoint.Sequence, checkpoint.Hash, nil
+}
+
+// VerifyChain walks the audit chain of one user, or of every user when
+// userID is nil, recomputing each hash and checking each link. Events removed
+// by retention are accounted for by the user's checkpoint.
+func (s *AuditService) VerifyChain(userID *uint) (*AuditChainReport, error) {
+	var userIDs []uint
+	if userID != nil {
+		userIDs = []uint{*userID}
+	} else if err := s.DB.Model(&model.AuditEvent{}).Distinct().Order("user_id ASC").Pluck("user_id", &userIDs).Error; err != nil {
+		return nil, err
+	}
+
+	report := &AuditChainReport{Verified: true, Users: make([]AuditChainUserReport, 0, len(userIDs)), Issues: []AuditChainIssue{}}
+	for _, id := range userIDs {
+		userReport, err := s.verifyUserChain(id, report)
+		if err != nil {
+			return nil, err
+		}
+		report.Users = append(report.Users, userReport)
+	}
+	return report, nil
+}
+
+func (s *AuditService) verifyUserChain(userID uint, report *AuditChainReport) (AuditChainUserReport, error) {
+	var checkpoint model.AuditChainCheckpoint
+	if err := s.DB.Where("user_id = ?", userID).Limit(1).Find(&checkpoint).Error; err != nil {
+		return AuditChainUserReport{}, err
+	}
+
+	userReport := AuditChainUserReport{
+		UserID:        userID,
+		PurgedThrough: checkpoint.Sequence,
+		LastSequence:  checkpoint.Sequence,
+		LastHash:      checkpoint.Hash,
+	}
+	addIssue := func(issue AuditChainIssue) {
+		report.Verified = false
+		userReport.Issues++
+		if len(report.Issues) >= maxAuditChainIssues {
+			report.IssuesTruncated = true
+			return
+		}
+		report.Issues = append(report.Issues, issue)
+	}
+
+	expectedSequence := checkpoint.Sequence + 1
+	expectedPrevHash := checkpoint.Hash
+	for {
+		var batch []model.AuditEvent
+		if err := s.DB.Where("user_id = ? AND sequence >= ?", userID, expectedSequence).
+			Order("sequence ASC").
+			Limit(auditChainVerifyBatchSize).
+			Find(&batch).Error; err != nil {
+			return AuditChainUserReport{}, err
+		}
+
+		for _, event := range batch {
+			if event.Sequence != expectedSequence {
+				addIssue(AuditChainIssue{
+					UserID:   userID,
+					Sequence: expectedSequence,
+					Kind:     AuditChainIssueGap,
+					Detail:   fmt.Sprintf("events %d to %d are missing", expectedSequence, event.Sequence-1),
+				})
+			}
+			if event.PrevHash != expectedPrevHash {
+				addIssue(AuditChainIssue{
+					UserID:   userID,
+					Sequence: event.Sequence,
+					EventID:  event.EventID,
+					Kind:     AuditChainIssueBrokenLink,
+					Detail:   "prev_hash does not match the preceding event",
+				})
+			}
+			hash, err := pkg.AuditEventHash(event)
+			if err != nil {
+				return AuditChainUserReport{}, err
+			}
+			if hash != event.Hash {
+				addIssue(AuditChainIssue{
+					UserID:   userID,
+					Sequence: event.Sequence,
+					EventID:  event.EventID,
+					Kind:     AuditChainIssueHashMismatch,
+					Detail:   "event content does not match its hash",
+				})
+			}
+
+			userReport.Events++
+			userReport.LastSequence = event.Sequence
+			userReport.LastHash = event.Hash
+			expectedSequence = event.Sequence + 1
+			expectedPrevHash = event.Hash
+		}
+		report.EventsChecked += int64(len(batch))
+
+		if len(batch) < auditChainVerifyBatchSize {
+			break
+		}
+	}
+
+	userReport.Intact = userReport.Issues == 0
+	return userReport, nil
+}
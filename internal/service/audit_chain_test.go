@@ -0,0 +1,299 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shiroha/subdux/internal/model"
+	"github.com/shiroha/subdux/internal/pkg"
+	"gorm.io/gorm"
+)
+
+func newAuditChainTestService(t *testing.T) (*gorm.DB, *AuditService) {
+	t.Helper()
+
+	db := newTestDB(t)
+	if err := db.AutoMigrate(&model.AuditEvent{}, &model.AuditChainCheckpoint{}); err != nil {
+		t.Fatalf("failed to migrate audit tables: %v", err)
+	}
+	return db, NewAuditService(db)
+}
+
+func createAuditChainEvents(t *testing.T, audit *AuditService, userID uint, count int) []*model.AuditEvent {
+	t.Helper()
+
+	events := make([]*model.AuditEvent, 0, count)
+	for i := 0; i < count; i++ {
+		event, err := audit.Create(CreateAuditEventInput{UserID: userID, Transport: AuditTransportREST, ResourceType: AuditResourceCategory, Action: "update"})
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestAuditChainLinksEventsPerUser(t *testing.T) {
+	_, audit := newAuditChainTestService(t)
+
+	first := createAuditChainEvents(t, audit, 1, 3)
+	other := createAuditChainEvents(t, audit, 2, 1)
+
+	for i, event := range first {
+		if event.Sequence != int64(i+1) {
+			t.Fatalf("event %d sequence = %d, want %d", i, event.Sequence, i+1)
+		}
+		if i > 0 && event.PrevHash != first[i-1].Hash {
+			t.Fatalf("event %d prev_hash = %q, want %q", i, event.PrevHash, first[i-1].Hash)
+		}
+	}
+	if first[0].PrevHash != "" || len(first[0].Hash) != 64 {
+		t.Fatalf("genesis event prev/hash = %q/%q", first[0].PrevHash, first[0].Hash)
+	}
+	if other[0].Sequence != 1 || other[0].PrevHash != "" {
+		t.Fatalf("second user's chain starts at %d/%q, want 1/empty", other[0].Sequence, other[0].PrevHash)
+	}
+
+	report, err := audit.VerifyChain(nil)
+	if err != nil {
+		t.Fatalf("VerifyChain() error = %v", err)
+	}
+	if !report.Verified || report.EventsChecked != 4 || len(report.Users) != 2 {
+		t.Fatalf("report = %+v, want verified chain of 4 events over 2 users", report)
+	}
+	if report.Users[0].LastSequence != 3 || report.Users[0].LastHash != first[2].Hash {
+		t.Fatalf("user 1 head = %d/%q, want 3/%q", report.Users[0].LastSequence, report.Users[0].LastHash, first[2].Hash)
+	}
+}
+
+func TestAuditChainDetectsTamperingAndGaps(t *testing.T) {
+	db, audit := newAuditChainTestService(t)
+	events := createAuditChainEvents(t, audit, 1, 4)
+
+	if err := db.Model(&model.AuditEvent{}).Where("event_id = ?", events[1].EventID).Update("action", "delete").Error; err != nil {
+		t.Fatalf("failed to tamper with event: %v", err)
+	}
+	if err := db.Where("event_id = ?", events[2].EventID).Delete(&model.AuditEvent{}).Error; err != nil {
+		t.Fatalf("failed to delete event: %v", err)
+	}
+
+	userID := uint(1)
+	report, err := audit.VerifyChain(&userID)
+	if err != nil {
+		t.Fatalf("VerifyChain() error = %v", err)
+	}
+	if report.Verified || report.Users[0].Intact {
+		t.Fatalf("report = %+v, want broken chain", report)
+	}
+
+	kinds := map[string]int64{}
+	for _, issue := range report.Issues {
+		kinds[issue.Kind] = issue.Sequence
+	}
+	if kinds[AuditChainIssueHashMismatch] != 2 {
+		t.Fatalf("issues = %+v, want hash mismatch at sequence 2", report.Issues)
+	}
+	if kinds[AuditChainIssueGap] != 3 {
+		t.Fatalf("issues = %+v, want gap at sequence 3", report.Issues)
+	}
+	if _, ok := kinds[AuditChainIssueBrokenLink]; !ok {
+		t.Fatalf("issues = %+v, want broken link after the gap", report.Issues)
+	}
+}
+
+func TestAuditChainHashIsKeyedOutsideDatabase(t *testing.T) {
+	t.Setenv("SETTINGS_ENCRYPTION_KEY", "audit-chain-test-key-one")
+	db, audit := newAuditChainTestService(t)
+	events := createAuditChainEvents(t, audit, 1, 2)
+
+	// Rewriting an event and re-chaining it with a plain digest, as someone
+	// with only database access could, must not verify.
+	forged := *events[1]
+	forged.Action = "delete"
+	data, _ := json.Marshal(forged)
+	sum := sha256.Sum256(data)
+	if err := db.Model(&model.AuditEvent{}).Where("event_id = ?", forged.EventID).Updates(map[string]interface{}{
+		"action": forged.Action,
+		"hash":   hex.EncodeToString(sum[:]),
+	}).Error; err != nil {
+		t.Fatalf("failed to forge event: %v", err)
+	}
+
+	userID := uint(1)
+	report, err := audit.VerifyChain(&userID)
+	if err != nil {
+		t.Fatalf("VerifyChain() error = %v", err)
+	}
+	if report.Verified || report.Issues[0].Kind != AuditChainIssueHashMismatch || report.Issues[0].Sequence != 2 {
+		t.Fatalf("report = %+v, want hash mismatch at sequence 2", report)
+	}
+
+	t.Setenv("SETTINGS_ENCRYPTION_KEY", "audit-chain-test-key-two")
+	report, err = audit.VerifyChain(&userID)
+	if err != nil {
+		t.Fatalf("VerifyChain() error = %v", err)
+	}
+	if report.Users[0].Issues != 2 {
+		t.Fatalf("report = %+v, want both events to fail under another key", report)
+	}
+}
+
+func TestAuditChainStaysIntactAfterRetentionPurge(t *testing.T) {
+	_, audit := newAuditChainTestService(t)
+
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	restore := pkg.SetNowForTest(now.AddDate(0, 0, -400))
+	createAuditChainEvents(t, audit, 1, 2)
+	restore()
+	restore = pkg.SetNowForTest(now)
+	defer restore()
+	kept := createAuditChainEvents(t, audit, 1, 1)
+
+	purged, err := audit.PurgeExpired()
+	if err != nil {
+		t.Fatalf("PurgeExpired() error = %v", err)
+	}
+	if purged != 2 {
+		t.Fatalf("PurgeExpired() = %d, want 2", purged)
+	}
+
+	report, err := audit.VerifyChain(nil)
+	if err != nil {
+		t.Fatalf("VerifyChain() error = %v", err)
+	}
+	if !report.Verified || report.Users[0].PurgedThrough != 2 || report.Users[0].Events != 1 {
+		t.Fatalf("report = %+v, want intact chain purged through 2", report)
+	}
+
+	next := createAuditChainEvents(t, audit, 1, 1)[0]
+	if next.Sequence != 4 || next.PrevHash != kept[0].Hash {
+		t.Fatalf("next event = %d/%q, want 4 linked to %q", next.Sequence, next.PrevHash, kept[0].Hash)
+	}
+}
+
+func TestAuditFileSinkAppendsJSONLines(t *testing.T) {
+	db, audit := newAuditChainTestService(t)
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	sinkType := AuditSinkFile
+	if err := applyAuditSettings(db, UpdateSettingsInput{AuditSinkType: &sinkType}); !errors.Is(err, ErrInvalidAuditFilePath) {
+		t.Fatalf("enabling file sink without path error = %v, want ErrInvalidAuditFilePath", err)
+	}
+	relative := "audit.jsonl"
+	if err := applyAuditSettings(db, UpdateSettingsInput{AuditSinkType: &sinkType, AuditFilePath: &relative}); !errors.Is(err, ErrInvalidAuditFilePath) {
+		t.Fatalf("relative file path error = %v, want ErrInvalidAuditFilePath", err)
+	}
+	if err := applyAuditSettings(db, UpdateSettingsInput{AuditSinkType: &sinkType, AuditFilePath: &path}); err != nil {
+		t.Fatalf("applyAuditSettings() error = %v", err)
+	}
+
+	events := createAuditChainEvents(t, audit, 1, 2)
+
+	// The sink worker appends in the background.
+	var lines []string
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		raw, err := os.ReadFile(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("failed to read sink file: %v", err)
+		}
+		lines = nil
+		if trimmed := strings.TrimSpace(string(raw)); trimmed != "" {
+			lines = strings.Split(trimmed, "\n")
+		}
+		if len(lines) >= 2 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(lines) != 2 {
+		t.Fatalf("sink lines = %d, want 2", len(lines))
+	}
+	var forwarded model.AuditEvent
+	if err := json.Unmarshal([]byte(lines[1]), &forwarded); err != nil {
+		t.Fatalf("failed to decode sink line: %v", err)
+	}
+	if forwarded.EventID != events[1].EventID || forwarded.Hash != events[1].Hash {
+		t.Fatalf("forwarded event = %+v, want %s", forwarded, events[1].EventID)
+	}
+}
+
+func TestAuditSyslogWriterReusesConnection(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	accepted := make(chan net.Conn, 2)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- conn
+		}
+	}()
+
+	var writer auditSyslogWriter
+	defer writer.close()
+	for _, message := range []string{"first", "second"} {
+		if err := writer.send("tcp", listener.Addr().String(), message); err != nil {
+			t.Fatalf("send(%q) error = %v", message, err)
+		}
+	}
+
+	conn := <-accepted
+	defer conn.Close()
+	if err := conn.SetReadDeadline(time.Now().Add(2 * time.Second)); err != nil {
+		t.Fatalf("failed to set deadline: %v", err)
+	}
+	want := "5 first6 second"
+	buf := make([]byte, len(want))
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatalf("failed to read framed messages: %v", err)
+	}
+	if string(buf) != want {
+		t.Fatalf("received %q, want %q on one connection", buf, want)
+	}
+	select {
+	case extra := <-accepted:
+		extra.Close()
+		t.Fatal("writer dialed a second connection")
+	default:
+	}
+}
+
+func TestFormatAuditSyslogMessage(t *testing.T) {
+	event := model.AuditEvent{
+		EventID:      "evt-1",
+		OccurredAt:   time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC),
+		UserID:       7,
+		Sequence:     3,
+		ResourceType: AuditResourceCategory,
+		Action:       `del"ete]`,
+		Status:       AuditStatusError,
+		Hash:         "abc",
+	}
+
+	message := formatAuditSyslogMessage(event)
+	if !strings.HasPrefix(message, "<109>1 2026-10-18T12:00:00Z ") {
+		t.Fatalf("message header = %q, want notice priority and UTC timestamp", message)
+	}
+	if !strings.Contains(message, `action="del\"ete\]"`) {
+		t.Fatalf("message = %q, want escaped structured data", message)
+	}
+	if !strings.Contains(message, `[subdux@32473 event_id="evt-1" user_id="7" sequence="3"`) {
+		t.Fatalf("message = %q, want chain fields in structured data", message)
+	}
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"time"
+
+	"github.com/shiroha/subdux/internal/model"
+)
+
+const auditExportBatchSize = 500
+
+// AuditExportFilter selects events for export. From is inclusive and To is
+// exclusive; nil bounds are open.
+type AuditExportFilter struct {
+	UserID *uint
+	From   *time.Time
+	To     *time.Time
+}
+
+// Export calls fn for every matching event in occurrence order. Events are read
+// in keyset-paginated batches rather than through one open cursor, so a slow
+// client streaming a large export never pins the database connection.
+func (s *AuditService) Export(filter AuditExportFilter, fn func(model.AuditEvent) error) error {
+	var (
+		lastOccurredAt time.Time
+		lastEventID    string
+	)
+	for {
+		query := s.DB.Model(&model.AuditEvent{})
+		if filter.UserID != nil {
+			query = query.Where("user_id = ?", *filter.UserID)
+		}
+		if filter.From != nil {
+			query = query.Where("occurred_at >= ?", *filter.From)
+		}
+		if filter.To != nil {
+			query = query.Where("occurred_at < ?", *filter.To)
+		}
+		if lastEventID != "" {
+			query = query.Where("occurred_at > ? OR (occurred_at = ? AND event_id > ?)", lastOccurredAt, lastOccurredAt, lastEventID)
+		}
+
+		var batch []model.AuditEvent
+		if err := query.Order("occurred_at ASC, event_id ASC").Limit(auditExportBatchSize).Find(&batch).Error; err != nil {
+			return err
+		}
+		for _, event := range batch {
+			if err := fn(event); err != nil {
+				return err
+			}
+		}
+		if len(batch) < auditExportBatchSize {
+			return nil
+		}
+		lastOccurredAt = batch[len(batch)-1].OccurredAt
+		lastEventID = batch[len(batch)-1].EventID
+	}
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/shiroha/subdux/internal/model"
+	"github.com/shiroha/subdux/internal/pkg"
+	"gorm.io/gorm"
+)
+
+const (
+	auditRetentionDaysKey     = "audit_retention_days"
+	defaultAuditRetentionDays = 365
+	maxAuditRetentionDays     = 3650
+)
+
+var ErrInvalidAuditRetentionDays = errors.New("audit retention days must be between 0 and 3650")
+
+// RetentionDays returns how long audit events are kept. Zero keeps them
+// forever. An unreadable setting falls back to the default.
+func (s *AuditService) RetentionDays() int {
+	raw, err := getSystemSettingValue(s.DB, auditRetentionDaysKey, "")
+	if err != nil {
+		return defaultAuditRetentionDays
+	}
+	days, err := normalizeAuditRetentionDays(raw)
+	if err != nil {
+		return defaultAuditRetentionDays
+	}
+	return days
+}
+
+// PurgeExpired deletes audit events older than the configured retention
+// window and returns how many were removed. Each user's chain is cut at a
+// sequence number rather than a timestamp so the remaining events stay
+// contiguous, and the last removed event is kept as a checkpoint that chain
+// verification starts from.
+func (s *AuditService) PurgeExpired() (int64, error) {
+	days := s.RetentionDays()
+	if days <= 0 {
+		return 0, nil
+	}
+	cutoff := pkg.NowUTC().AddDate(0, 0, -days)
+
+	var heads []struct {
+		UserID   uint
+		Sequence int64
+	}
+	if err := s.DB.Model(&model.AuditEvent{}).
+		Select("user_id, MAX(sequence) AS sequence").
+		Where("occurred_at < ?", cutoff).
+		Group("user_id").
+		Scan(&heads).Error; err != nil {
+		return 0, err
+	}
+
+	var purged int64
+	for _, head := range heads {
+		err := s.DB.Transaction(func(tx *gorm.DB) error {
+			// Only the expired prefix of the chain is removed, so an event
+			// stamped out of order by clock skew never takes newer events
+			// with it.
+			var retained []int64
+			if err := tx.Model(&model.AuditEvent{}).
+				Where("user_id = ? AND sequence <= ? AND occurred_at >= ?", head.UserID, head.Sequence, cutoff).
+				Order("sequence ASC").Limit(1).
+				Pluck("sequence", &retained).Error; err != nil {
+				return err
+			}
+			if len(retained) > 0 {
+				head.Sequence = retained[0] - 1
+			}
+
+			var last model.AuditEvent
+			if err := tx.Where("user_id = ? AND sequence = ?", head.UserID, head.Sequence).Limit(1).Find(&last).Error; err != nil {
+				return err
+			}
+			if last.EventID == "" {
+				return nil
+			}
+			checkpoint := model.AuditChainCheckpoint{UserID: head.UserID, Sequence: last.Sequence, Hash: last.Hash}
+			if err := tx.Save(&checkpoint).Error; err != nil {
+				return err
+			}
+			result := tx.Where("user_id = ? AND sequence <= ?", head.UserID, head.Sequence).Delete(&model.AuditEvent{})
+			purged += result.RowsAffected
+			return result.Error
+		})
+		if err != nil {
+			return purged, err
+		}
+	}
+	return purged, nil
+}
+
+func normalizeAuditRetentionDays(raw string) (int, error) {
+	value, err := strconv.Atoi(strings.TrimSpace(raw))
+	if err != nil || value < 0 || value > maxAuditRetentionDays {
+		return 0, ErrInvalidAuditRetentionDays
+	}
+	return value, nil
+}
+
+func applyAuditSettings(tx *gorm.DB, input UpdateSettingsInput) error {
+	if input.AuditRetentionDays != nil {
+		days, err := normalizeAuditRetentionDays(strconv.FormatInt(*input.AuditRetentionDays, 10))
+		if err != nil {
+			return err
+		}
+		if err := saveStringSystemSetting(tx, auditRetentionDaysKey, strconv.Itoa(days)); err != nil {
+			return err
+		}
+	}
+	return applyAuditSinkSettings(tx, input)
+}
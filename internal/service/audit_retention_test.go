@@ -0,0 +1,84 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shiroha/subdux/internal/model"
+	"github.com/shiroha/subdux/internal/pkg"
+)
+
+func TestAuditPurgeExpiredHonoursRetentionDays(t *testing.T) {
+	db := newTestDB(t)
+	if err := db.AutoMigrate(&model.AuditEvent{}, &model.AuditChainCheckpoint{}); err != nil {
+		t.Fatalf("failed to migrate audit events: %v", err)
+	}
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	restore := pkg.SetNowForTest(now)
+	defer restore()
+
+	audit := NewAuditService(db)
+	for _, age := range []int{40, 1} {
+		event, err := audit.Create(CreateAuditEventInput{UserID: 1, Transport: AuditTransportREST, ResourceType: AuditResourceCategory, Action: "update"})
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		if err := db.Model(event).Update("occurred_at", now.AddDate(0, 0, -age)).Error; err != nil {
+			t.Fatalf("failed to age audit event: %v", err)
+		}
+	}
+
+	days := int64(30)
+	if err := applyAuditSettings(db, UpdateSettingsInput{AuditRetentionDays: &days}); err != nil {
+		t.Fatalf("applyAuditSettings() error = %v", err)
+	}
+	if got := audit.RetentionDays(); got != 30 {
+		t.Fatalf("RetentionDays() = %d, want 30", got)
+	}
+
+	purged, err := audit.PurgeExpired()
+	if err != nil {
+		t.Fatalf("PurgeExpired() error = %v", err)
+	}
+	if purged != 1 {
+		t.Fatalf("PurgeExpired() = %d, want 1", purged)
+	}
+
+	keepForever := int64(0)
+	if err := applyAuditSettings(db, UpdateSettingsInput{AuditRetentionDays: &keepForever}); err != nil {
+		t.Fatalf("applyAuditSettings(0) error = %v", err)
+	}
+	if purged, err := audit.PurgeExpired(); err != nil || purged != 0 {
+		t.Fatalf("PurgeExpired() with retention 0 = %d, %v, want 0, nil", purged, err)
+	}
+
+	invalid := int64(maxAuditRetentionDays + 1)
+	if err := applyAuditSettings(db, UpdateSettingsInput{AuditRetentionDays: &invalid}); !errors.Is(err, ErrInvalidAuditRetentionDays) {
+		t.Fatalf("applyAuditSettings(%d) error = %v, want ErrInvalidAuditRetentionDays", invalid, err)
+	}
+}
+
+func TestAuditCreateKeepsMCPDefaultsOffRESTEvents(t *testing.T) {
+	db := newTestDB(t)
+	if err := db.AutoMigrate(&model.AuditEvent{}, &model.AuditChainCheckpoint{}); err != nil {
+		t.Fatalf("failed to migrate audit events: %v", err)
+	}
+	audit := NewAuditService(db)
+
+	rest, err := audit.Create(CreateAuditEventInput{UserID: 1, Transport: AuditTransportREST, KeyKind: AuditKeyKindAnonymous, Action: "login"})
+	if err != nil {
+		t.Fatalf("Create(rest) error = %v", err)
+	}
+	if rest.KeyKind != AuditKeyKindAnonymous || rest.ScopeUsed != "" {
+		t.Fatalf("rest event key kind/scope = %q/%q, want anonymous/empty", rest.KeyKind, rest.ScopeUsed)
+	}
+
+	mcp, err := audit.Create(CreateAuditEventInput{UserID: 1, Action: "create"})
+	if err != nil {
+		t.Fatalf("Create(mcp) error = %v", err)
+	}
+	if mcp.Transport != AuditTransportMCP || mcp.KeyKind != APIKeyKindMCPClient || mcp.ScopeUsed != APIKeyScopeWrite {
+		t.Fatalf("mcp event = %q/%q/%q, want MCP defaults", mcp.Transport, mcp.KeyKind, mcp.ScopeUsed)
+	}
+}
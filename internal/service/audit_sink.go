@@ -0,0 +1,350 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shiroha/subdux/internal/model"
+	"github.com/shiroha/subdux/internal/pkg/logging"
+	"gorm.io/gorm"
+)
+
+const (
+	AuditSinkNone   = "none"
+	AuditSinkSyslog = "syslog"
+	AuditSinkFile   = "file"
+
+	auditSinkTypeKey      = "audit_sink_type"
+	auditSyslogNetworkKey = "audit_syslog_network"
+	auditSyslogAddressKey = "audit_syslog_address"
+	auditFilePathKey      = "audit_file_path"
+
+	auditSinkTimeout = 3 * time.Second
+	// auditSinkQueueSize bounds the events waiting for the sink worker. When
+	// the sink falls this far behind, new events are dropped from the sink;
+	// they are still stored in the database.
+	auditSinkQueueSize = 1024
+
+	// auditSyslogFacility is the RFC 5424 "log audit" facility.
+	auditSyslogFacility       = 13
+	auditSyslogSeverityInfo   = 6
+	auditSyslogSeverityNotice = 5
+	auditSyslogEnterpriseID   = 32473
+)
+
+var (
+	ErrInvalidAuditSinkType      = errors.New("audit sink type must be none, syslog or file")
+	ErrInvalidAuditSyslogNetwork = errors.New("audit syslog network must be udp or tcp")
+	ErrInvalidAuditSyslogAddress = errors.New("audit syslog address must be host:port")
+	ErrInvalidAuditFilePath      = errors.New("audit file path must be an absolute file path")
+)
+
+// auditFileSinkMu serializes appends so concurrent events never interleave
+// within a line of the sink file.
+var auditFileSinkMu sync.Mutex
+
+// auditSinkQueue feeds the single worker that writes to the external sink,
+// so audited requests never wait on a slow syslog receiver or disk.
+var (
+	auditSinkQueue      = make(chan auditSinkJob, auditSinkQueueSize)
+	auditSinkWorkerOnce sync.Once
+)
+
+type auditSinkJob struct {
+	cfg   auditSinkConfig
+	event model.AuditEvent
+}
+
+type auditSinkConfig struct {
+	Type     string
+	Network  string
+	Address  string
+	FilePath string
+}
+
+func loadAuditSinkConfig(db *gorm.DB) auditSinkConfig {
+	cfg := auditSinkConfig{Type: AuditSinkNone, Network: "udp"}
+	if value, err := getSystemSettingValue(db, auditSinkTypeKey, AuditSinkNone); err == nil && value != "" {
+		cfg.Type = value
+	}
+	if value, err := getSystemSettingValue(db, auditSyslogNetworkKey, "udp"); err == nil && value != "" {
+		cfg.Network = value
+	}
+	if value, err := getSystemSettingValue(db, auditSyslogAddressKey, ""); err == nil {
+		cfg.Address = value
+	}
+	if value, err := getSystemSettingValue(db, auditFilePathKey, ""); err == nil {
+		cfg.FilePath = value
+	}
+	return cfg
+}
+
+// forwardToSink queues a stored event for the configured external sink. The
+// database remains the system of record, so a full queue or a sink failure is
+// logged and never fails or delays the audited request.
+func (s *AuditService) forwardToSink(event model.AuditEvent) {
+	sinkType, err := getSystemSettingValue(s.DB, auditSinkTypeKey, AuditSinkNone)
+	if err != nil || sinkType == "" || sinkType == AuditSinkNone {
+		return
+	}
+	cfg := loadAuditSinkConfig(s.DB)
+	if cfg.Type != AuditSinkSyslog && cfg.Type != AuditSinkFile {
+		return
+	}
+
+	auditSinkWorkerOnce.Do(func() { go runAuditSinkWorker(auditSinkQueue) })
+	select {
+	case auditSinkQueue <- auditSinkJob{cfg: cfg, event: event}:
+	default:
+		var ctx context.Context
+		if s.DB.Statement != nil {
+			ctx = s.DB.Statement.Context
+		}
+		logging.FromContext(ctx).Warn("audit sink queue is full, dropping event",
+			slog.String("sink", cfg.Type), slog.String("event_id", event.EventID))
+	}
+}
+
+// runAuditSinkWorker delivers queued events in order, keeping one syslog
+// connection open across events.
+func runAuditSinkWorker(jobs <-chan auditSinkJob) {
+	var syslog auditSyslogWriter
+	for job := range jobs {
+		var err error
+		switch job.cfg.Type {
+		case AuditSinkSyslog:
+			err = syslog.send(job.cfg.Network, job.cfg.Address, formatAuditSyslogMessage(job.event))
+		case AuditSinkFile:
+			syslog.close()
+			err = appendAuditFileSink(job.cfg.FilePath, job.event)
+		}
+		if err != nil {
+			logging.Warn("failed to forward audit event",
+				slog.String("sink", job.cfg.Type), slog.String("event_id", job.event.EventID), slog.Any("error", err))
+		}
+	}
+}
+
+// formatAuditSyslogMessage renders an event as an RFC 5424 message whose
+// structured data carries the chain fields and whose body is the event JSON.
+func formatAuditSyslogMessage(event model.AuditEvent) string {
+	severity := auditSyslogSeverityInfo
+	if event.Status == AuditStatusError {
+		severity = auditSyslogSeverityNotice
+	}
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+
+	structured := fmt.Sprintf(`[subdux@%d event_id="%s" user_id="%d" sequence="%d" resource="%s" action="%s" status="%s" hash="%s"]`,
+		auditSyslogEnterpriseID,
+		escapeSyslogParam(event.EventID),
+		event.UserID,
+		event.Sequence,
+		escapeSyslogParam(event.ResourceType),
+		escapeSyslogParam(event.Action),
+		escapeSyslogParam(event.Status),
+		escapeSyslogParam(event.Hash),
+	)
+	body, _ := json.Marshal(event)
+
+	return fmt.Sprintf("<%d>1 %s %s subdux %d audit %s %s",
+		auditSyslogFacility*8+severity,
+		event.OccurredAt.UTC().Format(time.RFC3339Nano),
+		hostname,
+		os.Getpid(),
+		structured,
+		body,
+	)
+}
+
+func escapeSyslogParam(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(value)
+}
+
+// auditSyslogWriter holds the sink worker's connection to the syslog
+// receiver. It redials when the target changes or a write fails.
+type auditSyslogWriter struct {
+	conn    net.Conn
+	network string
+	address string
+}
+
+// send delivers one message. TCP uses RFC 6587 octet-counting framing so
+// receivers can split messages that contain newlines. A write on a reused
+// connection that fails is retried once on a fresh one, since the receiver
+// may have closed the idle connection.
+func (w *auditSyslogWriter) send(network, address, message string) error {
+	payload := message
+	if network == "tcp" {
+		payload = strconv.Itoa(len(message)) + " " + message
+	}
+	if w.conn != nil && (w.network != network || w.address != address) {
+		w.close()
+	}
+
+	reused := w.conn != nil
+	err := w.write(network, address, payload)
+	if err != nil && reused {
+		err = w.write(network, address, payload)
+	}
+	return err
+}
+
+func (w *auditSyslogWriter) write(network, address, payload string) error {
+	if w.conn == nil {
+		conn, err := net.DialTimeout(network, address, auditSinkTimeout)
+		if err != nil {
+			return err
+		}
+		w.conn, w.network, w.address = conn, network, address
+	}
+	if err := w.conn.SetWriteDeadline(time.Now().Add(auditSinkTimeout)); err != nil {
+		w.close()
+		return err
+	}
+	if _, err := w.conn.Write([]byte(payload)); err != nil {
+		w.close()
+		return err
+	}
+	return nil
+}
+
+func (w *auditSyslogWriter) close() {
+	if w.conn != nil {
+		w.conn.Close()
+		w.conn = nil
+	}
+}
+
+func appendAuditFileSink(path string, event model.AuditEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	auditFileSinkMu.Lock()
+	defer auditFileSinkMu.Unlock()
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func normalizeAuditSinkType(raw string) (string, error) {
+	value := strings.ToLower(strings.TrimSpace(raw))
+	if value == "" {
+		value = AuditSinkNone
+	}
+	switch value {
+	case AuditSinkNone, AuditSinkSyslog, AuditSinkFile:
+		return value, nil
+	default:
+		return "", ErrInvalidAuditSinkType
+	}
+}
+
+func normalizeAuditSyslogNetwork(raw string) (string, error) {
+	value := strings.ToLower(strings.TrimSpace(raw))
+	if value == "" {
+		value = "udp"
+	}
+	if value != "udp" && value != "tcp" {
+		return "", ErrInvalidAuditSyslogNetwork
+	}
+	return value, nil
+}
+
+func normalizeAuditSyslogAddress(raw string) (string, error) {
+	value := strings.TrimSpace(raw)
+	if value == "" {
+		return "", nil
+	}
+	host, port, err := net.SplitHostPort(value)
+	if err != nil || host == "" {
+		return "", ErrInvalidAuditSyslogAddress
+	}
+	if portNumber, err := strconv.Atoi(port); err != nil || portNumber < 1 || portNumber > 65535 {
+		return "", ErrInvalidAuditSyslogAddress
+	}
+	return value, nil
+}
+
+func normalizeAuditFilePath(raw string) (string, error) {
+	value := strings.TrimSpace(raw)
+	if value == "" {
+		return "", nil
+	}
+	if !filepath.IsAbs(value) || strings.HasSuffix(value, string(filepath.Separator)) {
+		return "", ErrInvalidAuditFilePath
+	}
+	return filepath.Clean(value), nil
+}
+
+// applyAuditSinkSettings validates the sink fields in input against the
+// stored ones, so enabling a sink without a target is rejected.
+func applyAuditSinkSettings(tx *gorm.DB, input UpdateSettingsInput) error {
+	if input.AuditSinkType == nil && input.AuditSyslogNetwork == nil &&
+		input.AuditSyslogAddress == nil && input.AuditFilePath == nil {
+		return nil
+	}
+	cfg := loadAuditSinkConfig(tx)
+
+	var err error
+	if input.AuditSinkType != nil {
+		if cfg.Type, err = normalizeAuditSinkType(*input.AuditSinkType); err != nil {
+			return err
+		}
+	}
+	if input.AuditSyslogNetwork != nil {
+		if cfg.Network, err = normalizeAuditSyslogNetwork(*input.AuditSyslogNetwork); err != nil {
+			return err
+		}
+	}
+	if input.AuditSyslogAddress != nil {
+		if cfg.Address, err = normalizeAuditSyslogAddress(*input.AuditSyslogAddress); err != nil {
+			return err
+		}
+	}
+	if input.AuditFilePath != nil {
+		if cfg.FilePath, err = normalizeAuditFilePath(*input.AuditFilePath); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case cfg.Type == AuditSinkSyslog && cfg.Address == "":
+		return ErrInvalidAuditSyslogAddress
+	case cfg.Type == AuditSinkFile && cfg.FilePath == "":
+		return ErrInvalidAuditFilePath
+	}
+
+	for key, value := range map[string]string{
+		auditSinkTypeKey:      cfg.Type,
+		auditSyslogNetworkKey: cfg.Network,
+		auditSyslogAddressKey: cfg.Address,
+		auditFilePathKey:      cfg.FilePath,
+	} {
+		if err := saveStringSystemSetting(tx, key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"github.com/shiroha/subdux/internal/model"
+)
+
+// ResourceSnapshot loads the current state of a stored resource for an audit
+// before/after snapshot. A nil userID loads the row regardless of owner, which
+// admin routes rely on. Unknown resource types and missing rows return nil.
+func (s *AuditService) ResourceSnapshot(resourceType string, userID *uint, resourceID uint) interface{} {
+	if resourceID == 0 {
+		return nil
+	}
+
+	var target interface{}
+	switch resourceType {
+	case AuditResourceSubscription:
+		target = &model.Subscription{}
+	case AuditResourceCategory:
+		target = &model.Category{}
+	case AuditResourceCustomField:
+		target = &model.CustomFieldDefinition{}
+	case AuditResourcePaymentMethod:
+		target = &model.PaymentMethod{}
+	case AuditResourceCurrency:
+		target = &model.UserCurrency{}
+	case AuditResourceNotificationChannel:
+		target = &model.NotificationChannel{}
+	case AuditResourceNotificationOutbox:
+		target = &model.NotificationOutbox{}
+	case AuditResourceTemplate:
+		target = &model.NotificationTemplate{}
+	case AuditResourceAPIKey:
+		target = &model.APIKey{}
+	case AuditResourceCalendarToken:
+		target = &model.CalendarToken{}
+	case AuditResourcePasskey:
+		target = &model.PasskeyCredential{}
+	case AuditResourceOIDCConnection:
+		target = &model.OIDCConnection{}
+	case AuditResourceOIDCProvider:
+		target = &model.OIDCProvider{}
+	case AuditResourceUser:
+		target = &model.User{}
+	default:
+		return nil
+	}
+
+	query := s.DB.Where("id = ?", resourceID)
+	if userID != nil && resourceType != AuditResourceUser {
+		query = query.Where("user_id = ?", *userID)
+	} else if userID != nil {
+		query = query.Where("id = ?", *userID)
+	}
+	if err := query.Limit(1).Find(target).Error; err != nil {
+		return nil
+	}
+
+	snapshot, ok := RedactAuditSnapshot(target).(map[string]interface{})
+	if !ok || snapshot["id"] == nil || snapshot["id"] == float64(0) {
+		return nil
+	}
+	// Channel config holds webhook URLs and credentials as an opaque JSON
+	// string that key-based redaction cannot see into.
+	if resourceType == AuditResourceNotificationChannel {
+		snapshot["config"] = "[redacted]"
+	}
+	return snapshot
+}
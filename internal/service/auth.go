@@ -164,7 +164,7 @@ func (s *AuthService) Register(input RegisterInput) (*AuthResponse, error) {
 			Update("consumed_at", &now).Error
 	}
 
-	authResp, err := s.issueAuthResponse(user)
+	authResp, err := s.issueAuthResponse(user, SessionViaRegister)
 	if err != nil {
 		return nil, err
 	}
@@ -190,6 +190,13 @@ func (s *AuthService) ChangePassword(userID uint, input ChangePasswordInput) err
 	if err := s.DB.First(&user, userID).Error; err != nil {
 		return ErrUserNotFound
 	}
+	managed, err := isDirectoryManagedUser(s.DB, user.ID)
+	if err != nil {
+		return err
+	}
+	if managed {
+		return ErrLDAPManagedPassword
+	}
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.CurrentPassword)); err != nil {
 		return ErrCurrentPasswordIncorrect
 	}
@@ -213,16 +220,44 @@ func (s *AuthService) Login(input LoginInput) (*LoginResponse, error) {
 	normalizedEmail := strings.ToLower(identifier)
 
 	var user model.User
-	if err := s.DB.Where("LOWER(email) = ? OR username = ?", normalizedEmail, identifier).First(&user).Error; err != nil {
+	err := s.DB.Where("LOWER(email) = ? OR username = ?", normalizedEmail, identifier).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// Local accounts always take precedence; only identifiers unknown
+		// here are tried against the directory.
+		ldapUser, ldapErr := s.loginLDAPUser(identifier, input.Password)
+		if ldapErr != nil {
+			return nil, ldapLoginError(ldapErr)
+		}
+		user = *ldapUser
+		if user.Status == "disabled" {
+			return nil, errors.New("account is disabled")
+		}
+	} else if err != nil {
 		return nil, errors.New("invalid credentials")
-	}
-
-	if user.Status == "disabled" {
-		return nil, errors.New("account is disabled")
-	}
+	} else {
+		if user.Status == "disabled" {
+			return nil, errors.New("account is disabled")
+		}
+		if err := checkAccountLockout(s.DB, user.ID); err != nil {
+			return nil, err
+		}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password)); err != nil {
-		return nil, errors.New("invalid credentials")
+		account, err := verifyAccountPassword(s.DB, &user, input.Password)
+		if err != nil {
+			if errors.Is(err, ErrLDAPInvalidCredentials) {
+				recordAuthFailure(s.DB, user, authFailurePassword)
+			}
+			return nil, ldapLoginError(err)
+		}
+		if account != nil {
+			cfg, err := loadLDAPRuntimeConfig(s.DB)
+			if err != nil {
+				return nil, errors.New("invalid credentials")
+			}
+			if err := s.syncLDAPUser(&user, *cfg, account); err != nil {
+				return nil, ldapLoginError(err)
+			}
+		}
 	}
 
 	if user.TotpEnabled {
@@ -232,8 +267,9 @@ func (s *AuthService) Login(input LoginInput) (*LoginResponse, error) {
 		}
 		return &LoginResponse{RequiresTotp: true, TotpToken: pendingToken}, nil
 	}
+	clearAuthFailures(s.DB, user.ID)
 
-	authResp, err := s.issueAuthResponse(user)
+	authResp, err := s.issueAuthResponse(user, SessionViaPassword)
 	if err != nil {
 		return nil, err
 	}
@@ -170,7 +170,7 @@ func (s *AuthService) SendEmailChangeVerificationCode(userID uint, newEmail stri
 		return err
 	}
 
-	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(currentPassword)) != nil {
+	if _, err := verifyAccountPassword(s.DB, &user, currentPassword); err != nil {
 		return ErrCurrentPasswordIncorrect
 	}
 
@@ -234,7 +234,7 @@ func (s *AuthService) ConfirmEmailChange(userID uint, newEmail string, verificat
 	}
 
 	user.Email = normalizedEmail
-	return s.issueAuthResponse(user)
+	return s.issueAuthResponse(user, SessionViaEmailChange)
 }
 
 func (s *AuthService) isRegistrationEnabled() bool {
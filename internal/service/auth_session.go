@@ -14,6 +14,8 @@ var (
 	ErrInvalidRefreshToken = errors.New("invalid refresh token")
 )
 
+// CreateSession issues a session once a password login has passed its TOTP
+// step, which is the only flow that defers issuing one.
 func (s *AuthService) CreateSession(userID uint) (*AuthResponse, error) {
 	user, err := s.GetUser(userID)
 	if err != nil {
@@ -22,11 +24,18 @@ func (s *AuthService) CreateSession(userID uint) (*AuthResponse, error) {
 	if user.Status != "active" {
 		return nil, errors.New("account is disabled")
 	}
-	return s.issueAuthResponse(*user)
+	return s.issueAuthResponse(*user, SessionViaPassword)
 }
 
-func (s *AuthService) issueAuthResponse(user model.User) (*AuthResponse, error) {
-	accessToken, err := pkg.GenerateAccessToken(user.ID, user.Username, user.Email, user.Role)
+// issueAuthResponse starts a new session for user. via records how the user
+// signed in; the client details come from the request context.
+func (s *AuthService) issueAuthResponse(user model.User, via string) (*AuthResponse, error) {
+	sessionID, err := generateSecureToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, err := pkg.GenerateSessionAccessToken(user.ID, user.Username, user.Email, user.Role, sessionID)
 	if err != nil {
 		return nil, err
 	}
@@ -36,15 +45,29 @@ func (s *AuthService) issueAuthResponse(user model.User) (*AuthResponse, error)
 		return nil, err
 	}
 
+	client := sessionClientFromContext(s.DB.Statement.Context)
+	now := pkg.NowUTC()
 	record := model.RefreshToken{
-		UserID:    user.ID,
-		TokenHash: refreshTokenHash,
-		ExpiresAt: refreshExpiresAt,
+		UserID:     user.ID,
+		TokenHash:  refreshTokenHash,
+		SessionID:  sessionID,
+		CreatedVia: via,
+		UserAgent:  client.UserAgent,
+		IPAddress:  client.IPAddress,
+		SignedInAt: &now,
+		ExpiresAt:  refreshExpiresAt,
 	}
 
+	newDevice, err := isNewSessionDevice(s.DB, user.ID, client)
+	if err != nil {
+		return nil, err
+	}
 	if err := s.DB.Create(&record).Error; err != nil {
 		return nil, err
 	}
+	if newDevice && via != SessionViaRegister {
+		enqueueNewDeviceNotification(s.DB, user, record)
+	}
 
 	return &AuthResponse{
 		AccessToken:  accessToken,
@@ -94,7 +117,7 @@ func (s *AuthService) RefreshSession(rawRefreshToken string) (*AuthResponse, err
 		}
 
 		var err error
-		accessToken, err = pkg.GenerateAccessToken(user.ID, user.Username, user.Email, user.Role)
+		accessToken, err = pkg.GenerateSessionAccessToken(user.ID, user.Username, user.Email, user.Role, stored.SessionID)
 		if err != nil {
 			return err
 		}
@@ -117,10 +140,22 @@ func (s *AuthService) RefreshSession(rawRefreshToken string) (*AuthResponse, err
 			return ErrInvalidRefreshToken
 		}
 
+		client := sessionClientFromContext(tx.Statement.Context)
+		if client.UserAgent == "" {
+			client.UserAgent = stored.UserAgent
+		}
+		if client.IPAddress == "" {
+			client.IPAddress = stored.IPAddress
+		}
 		return tx.Create(&model.RefreshToken{
-			UserID:    user.ID,
-			TokenHash: newRefreshHash,
-			ExpiresAt: newRefreshExpires,
+			UserID:     user.ID,
+			TokenHash:  newRefreshHash,
+			SessionID:  stored.SessionID,
+			CreatedVia: stored.CreatedVia,
+			UserAgent:  client.UserAgent,
+			IPAddress:  client.IPAddress,
+			SignedInAt: stored.SignedInAt,
+			ExpiresAt:  newRefreshExpires,
 		}).Error
 	}); err != nil {
 		if errors.Is(err, ErrInvalidRefreshToken) {
@@ -0,0 +1,294 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/shiroha/subdux/internal/model"
+	"github.com/shiroha/subdux/internal/pkg"
+	"github.com/shiroha/subdux/internal/pkg/logging"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	SessionViaPassword    = "password"
+	SessionViaPasskey     = "passkey"
+	SessionViaOIDC        = "oidc"
+	SessionViaProxy       = "proxy"
+	SessionViaRegister    = "register"
+	SessionViaEmailChange = "email_change"
+
+	notificationTriggerNewLogin = "new_login"
+
+	maxSessionUserAgentLength = 512
+	maxSessionIPAddressLength = 64
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// SessionClient describes the device a request came from. The API layer
+// attaches it to the request context so session records can be annotated
+// without threading it through every sign-in method.
+type SessionClient struct {
+	UserAgent string
+	IPAddress string
+}
+
+type sessionClientContextKey struct{}
+
+// WithSessionClient returns ctx carrying the client details.
+func WithSessionClient(ctx context.Context, client SessionClient) context.Context {
+	return context.WithValue(ctx, sessionClientContextKey{}, client)
+}
+
+func sessionClientFromContext(ctx context.Context) SessionClient {
+	if ctx == nil {
+		return SessionClient{}
+	}
+	client, _ := ctx.Value(sessionClientContextKey{}).(SessionClient)
+	client.UserAgent = truncateString(strings.TrimSpace(client.UserAgent), maxSessionUserAgentLength)
+	client.IPAddress = truncateString(strings.TrimSpace(client.IPAddress), maxSessionIPAddressLength)
+	return client
+}
+
+// SessionInfo is one signed-in session as shown to its owner.
+type SessionInfo struct {
+	ID           string    `json:"id"`
+	CreatedVia   string    `json:"created_via"`
+	UserAgent    string    `json:"user_agent"`
+	IPAddress    string    `json:"ip_address"`
+	SignedInAt   time.Time `json:"signed_in_at"`
+	LastActiveAt time.Time `json:"last_active_at"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	Current      bool      `json:"current"`
+}
+
+// ListSessions returns the user's live sessions, most recently active first.
+// currentSessionID marks the caller's own session.
+func (s *AuthService) ListSessions(userID uint, currentSessionID string) ([]SessionInfo, error) {
+	var tokens []model.RefreshToken
+	if err := s.DB.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, pkg.NowUTC()).
+		Order("COALESCE(last_used_at, created_at) DESC, id DESC").
+		Find(&tokens).Error; err != nil {
+		return nil, err
+	}
+
+	sessions := make([]SessionInfo, 0, len(tokens))
+	seen := make(map[string]struct{}, len(tokens))
+	for _, token := range tokens {
+		if _, ok := seen[token.SessionID]; ok {
+			continue
+		}
+		seen[token.SessionID] = struct{}{}
+
+		signedInAt := token.CreatedAt
+		if token.SignedInAt != nil {
+			signedInAt = *token.SignedInAt
+		}
+		lastActiveAt := token.CreatedAt
+		if token.LastUsedAt != nil && token.LastUsedAt.After(lastActiveAt) {
+			lastActiveAt = *token.LastUsedAt
+		}
+		sessions = append(sessions, SessionInfo{
+			ID:           token.SessionID,
+			CreatedVia:   token.CreatedVia,
+			UserAgent:    token.UserAgent,
+			IPAddress:    token.IPAddress,
+			SignedInAt:   signedInAt,
+			LastActiveAt: lastActiveAt,
+			ExpiresAt:    token.ExpiresAt,
+			Current:      currentSessionID != "" && token.SessionID == currentSessionID,
+		})
+	}
+	return sessions, nil
+}
+
+// RevokeSession signs one of the user's sessions out. Access tokens already
+// issued to it stay valid until they expire.
+func (s *AuthService) RevokeSession(userID uint, sessionID string) error {
+	sessionID = strings.TrimSpace(sessionID)
+	if sessionID == "" {
+		return ErrSessionNotFound
+	}
+
+	now := pkg.NowUTC()
+	result := s.DB.Model(&model.RefreshToken{}).
+		Where("user_id = ? AND session_id = ? AND revoked_at IS NULL", userID, sessionID).
+		Updates(map[string]interface{}{"revoked_at": &now})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// RevokeOtherSessions signs out every session of the user except keep and
+// reports how many were revoked.
+func (s *AuthService) RevokeOtherSessions(userID uint, keep string) (int64, error) {
+	keep = strings.TrimSpace(keep)
+	if keep == "" {
+		return 0, ErrSessionNotFound
+	}
+
+	now := pkg.NowUTC()
+	result := s.DB.Model(&model.RefreshToken{}).
+		Where("user_id = ? AND session_id <> ? AND revoked_at IS NULL", userID, keep).
+		Updates(map[string]interface{}{"revoked_at": &now})
+	return result.RowsAffected, result.Error
+}
+
+// RevokeUserSessions force-logs-out a user everywhere.
+func (s *AdminService) RevokeUserSessions(userID uint) error {
+	var user model.User
+	if err := s.DB.Select("id").First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	return revokeAllRefreshTokens(s.DB, userID)
+}
+
+// isNewSessionDevice reports whether the user has signed in before but never
+// from this browser and operating system. Versions are ignored so a browser
+// update does not look like a new device. A first-ever sign-in is not treated
+// as a new device.
+func isNewSessionDevice(db *gorm.DB, userID uint, client SessionClient) (bool, error) {
+	var total int64
+	if err := db.Model(&model.RefreshToken{}).Where("user_id = ?", userID).Count(&total).Error; err != nil {
+		return false, err
+	}
+	if total == 0 {
+		return false, nil
+	}
+
+	var userAgents []string
+	if err := db.Model(&model.RefreshToken{}).
+		Where("user_id = ?", userID).
+		Distinct().
+		Pluck("user_agent", &userAgents).Error; err != nil {
+		return false, err
+	}
+	device := sessionDeviceKey(client.UserAgent)
+	for _, userAgent := range userAgents {
+		if sessionDeviceKey(userAgent) == device {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// sessionBrowserFamilies and sessionOSFamilies map user agent tokens to a
+// family name. Order matters: Edge and Opera also claim to be Chrome and
+// Safari, and iOS and Android also claim to be macOS and Linux.
+var sessionBrowserFamilies = []struct{ token, family string }{
+	{"Edg/", "edge"}, {"Edge/", "edge"}, {"EdgiOS", "edge"}, {"EdgA/", "edge"},
+	{"OPR/", "opera"}, {"Opera", "opera"},
+	{"SamsungBrowser", "samsung"},
+	{"Firefox/", "firefox"}, {"FxiOS", "firefox"},
+	{"CriOS", "chrome"}, {"Chrome/", "chrome"}, {"Chromium/", "chrome"},
+	{"Safari/", "safari"},
+}
+
+var sessionOSFamilies = []struct{ token, family string }{
+	{"Windows", "windows"},
+	{"iPhone", "ios"}, {"iPad", "ios"}, {"iPod", "ios"},
+	{"Android", "android"},
+	{"CrOS", "chromeos"},
+	{"Macintosh", "macos"}, {"Mac OS X", "macos"},
+	{"Linux", "linux"},
+}
+
+var sessionUserAgentVersionPattern = regexp.MustCompile(`[/\s]*v?\d+(?:[._]\d+)*`)
+
+// sessionDeviceKey reduces a user agent to its browser family and operating
+// system without versions. User agents naming neither, such as API clients,
+// fall back to the lower-cased string with version numbers removed.
+func sessionDeviceKey(userAgent string) string {
+	browser, system := "", ""
+	for _, candidate := range sessionBrowserFamilies {
+		if strings.Contains(userAgent, candidate.token) {
+			browser = candidate.family
+			break
+		}
+	}
+	for _, candidate := range sessionOSFamilies {
+		if strings.Contains(userAgent, candidate.token) {
+			system = candidate.family
+			break
+		}
+	}
+	if browser == "" && system == "" {
+		return strings.TrimSpace(sessionUserAgentVersionPattern.ReplaceAllString(strings.ToLower(userAgent), ""))
+	}
+	return browser + "|" + system
+}
+
+// enqueueNewDeviceNotification queues a sign-in alert on each of the user's
+// enabled channels. Failures are logged rather than failing the sign-in.
+func enqueueNewDeviceNotification(db *gorm.DB, user model.User, token model.RefreshToken) {
+	var channels []model.NotificationChannel
+	if err := db.Where("user_id = ? AND enabled = ?", user.ID, true).Find(&channels).Error; err != nil {
+		logging.Warn("failed to load channels for new sign-in notification",
+			slog.Uint64("user_id", uint64(user.ID)),
+			slog.Any("error", err))
+		return
+	}
+	if len(channels) == 0 {
+		return
+	}
+
+	now := pkg.NowUTC()
+	locale := loadNotificationLocale(db, user.ID)
+	message, err := NewTemplateRenderer(NewTemplateValidator()).RenderNewLoginTemplate(
+		builtinNotificationTemplate(locale, notificationTemplateCategoryNewLogin),
+		NewLoginTemplateData{
+			Device:     token.UserAgent,
+			IPAddress:  token.IPAddress,
+			Method:     token.CreatedVia,
+			SignedInAt: now.Format(time.RFC3339),
+			Locale:     locale,
+		},
+	)
+	if err != nil {
+		logging.Warn("failed to render new sign-in notification",
+			slog.Uint64("user_id", uint64(user.ID)),
+			slog.Any("error", err))
+		return
+	}
+
+	expiresAt := now.Add(notificationOutboxExpiryWindow)
+	maxAttempts := loadNotificationRetryPolicy(db).maxAttempts
+	for _, channel := range channels {
+		channelID := channel.ID
+		outbox := model.NotificationOutbox{
+			DedupeKey:     fmt.Sprintf("%s:%d:%s:%s:%d", notificationOutboxVersion, user.ID, notificationTriggerNewLogin, token.SessionID, channel.ID),
+			UserID:        user.ID,
+			ChannelID:     &channelID,
+			ChannelType:   channel.Type,
+			TriggerType:   notificationTriggerNewLogin,
+			NotifyDate:    normalizeDateUTC(now),
+			ScheduledFor:  now,
+			ExpiresAt:     &expiresAt,
+			Status:        notificationOutboxStatusPending,
+			MaxAttempts:   maxAttempts,
+			NextAttemptAt: now,
+			Message:       message,
+			TargetEmail:   user.Email,
+		}
+		if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&outbox).Error; err != nil {
+			logging.Warn("failed to queue new sign-in notification",
+				slog.Uint64("user_id", uint64(user.ID)),
+				slog.String("channel", channel.Type),
+				slog.Any("error", err))
+		}
+	}
+}
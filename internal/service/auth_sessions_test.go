@@ -0,0 +1,217 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/shiroha/subdux/internal/model"
+	"github.com/shiroha/subdux/internal/pkg"
+)
+
+func newSessionTestService(t *testing.T) (*AuthService, model.User) {
+	t.Helper()
+	t.Setenv("JWT_SECRET", "0123456789abcdef0123456789abcdef")
+
+	db := newTestDB(t)
+	if err := db.AutoMigrate(&model.NotificationChannel{}, &model.NotificationOutbox{}); err != nil {
+		t.Fatalf("failed to migrate notification tables: %v", err)
+	}
+	return NewAuthService(db), createTestUser(t, db)
+}
+
+func sessionIDFromAccessToken(t *testing.T, accessToken string) string {
+	t.Helper()
+	claims := &pkg.JWTClaims{}
+	if _, err := jwt.ParseWithClaims(accessToken, claims, func(*jwt.Token) (interface{}, error) {
+		return pkg.GetJWTSecret(), nil
+	}); err != nil {
+		t.Fatalf("failed to parse access token: %v", err)
+	}
+	return claims.SessionID
+}
+
+func TestSessionsRecordClientAndSurviveRotation(t *testing.T) {
+	service, user := newSessionTestService(t)
+	ctx := WithSessionClient(context.Background(), SessionClient{UserAgent: "Firefox", IPAddress: "192.0.2.10"})
+
+	first, err := service.WithContext(ctx).CreateSession(user.ID)
+	if err != nil {
+		t.Fatalf("CreateSession() error = %v", err)
+	}
+	sessionID := sessionIDFromAccessToken(t, first.AccessToken)
+	if sessionID == "" {
+		t.Fatal("access token carries no session id")
+	}
+
+	refreshCtx := WithSessionClient(context.Background(), SessionClient{UserAgent: "Firefox", IPAddress: "192.0.2.99"})
+	rotated, err := service.WithContext(refreshCtx).RefreshSession(first.RefreshToken)
+	if err != nil {
+		t.Fatalf("RefreshSession() error = %v", err)
+	}
+	if got := sessionIDFromAccessToken(t, rotated.AccessToken); got != sessionID {
+		t.Fatalf("rotated session id = %q, want %q", got, sessionID)
+	}
+
+	sessions, err := service.ListSessions(user.ID, sessionID)
+	if err != nil {
+		t.Fatalf("ListSessions() error = %v", err)
+	}
+	if len(sessions) != 1 {
+		t.Fatalf("ListSessions() returned %d sessions, want 1", len(sessions))
+	}
+	got := sessions[0]
+	if got.ID != sessionID || !got.Current || got.CreatedVia != SessionViaPassword || got.UserAgent != "Firefox" || got.IPAddress != "192.0.2.99" {
+		t.Fatalf("session = %+v", got)
+	}
+}
+
+func TestListSessionsReportsLastUse(t *testing.T) {
+	service, user := newSessionTestService(t)
+
+	first, err := service.CreateSession(user.ID)
+	if err != nil {
+		t.Fatalf("CreateSession() error = %v", err)
+	}
+	second, err := service.CreateSession(user.ID)
+	if err != nil {
+		t.Fatalf("CreateSession() error = %v", err)
+	}
+	firstID := sessionIDFromAccessToken(t, first.AccessToken)
+	secondID := sessionIDFromAccessToken(t, second.AccessToken)
+
+	lastUsed := pkg.NowUTC().Add(2 * time.Hour).Truncate(time.Second)
+	if err := service.DB.Model(&model.RefreshToken{}).
+		Where("session_id = ?", firstID).
+		Update("last_used_at", lastUsed).Error; err != nil {
+		t.Fatalf("failed to set last_used_at: %v", err)
+	}
+
+	sessions, err := service.ListSessions(user.ID, "")
+	if err != nil {
+		t.Fatalf("ListSessions() error = %v", err)
+	}
+	if len(sessions) != 2 || sessions[0].ID != firstID || sessions[1].ID != secondID {
+		t.Fatalf("sessions = %+v, want %s first", sessions, firstID)
+	}
+	if !sessions[0].LastActiveAt.Equal(lastUsed) {
+		t.Fatalf("LastActiveAt = %v, want %v", sessions[0].LastActiveAt, lastUsed)
+	}
+	if sessions[1].LastActiveAt.IsZero() {
+		t.Fatal("LastActiveAt did not fall back to the token creation time")
+	}
+}
+
+func TestRevokeSessions(t *testing.T) {
+	service, user := newSessionTestService(t)
+
+	var ids []string
+	for i := 0; i < 3; i++ {
+		resp, err := service.CreateSession(user.ID)
+		if err != nil {
+			t.Fatalf("CreateSession() error = %v", err)
+		}
+		ids = append(ids, sessionIDFromAccessToken(t, resp.AccessToken))
+	}
+
+	if err := service.RevokeSession(user.ID, ids[0]); err != nil {
+		t.Fatalf("RevokeSession() error = %v", err)
+	}
+	if err := service.RevokeSession(user.ID, ids[0]); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("second RevokeSession() error = %v, want ErrSessionNotFound", err)
+	}
+	if err := service.RevokeSession(user.ID+1, ids[1]); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("RevokeSession() for another user error = %v, want ErrSessionNotFound", err)
+	}
+
+	revoked, err := service.RevokeOtherSessions(user.ID, ids[2])
+	if err != nil || revoked != 1 {
+		t.Fatalf("RevokeOtherSessions() = %d, %v, want 1, nil", revoked, err)
+	}
+	sessions, err := service.ListSessions(user.ID, "")
+	if err != nil {
+		t.Fatalf("ListSessions() error = %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].ID != ids[2] {
+		t.Fatalf("remaining sessions = %+v, want only %q", sessions, ids[2])
+	}
+
+	if err := NewAdminService(service.DB).RevokeUserSessions(user.ID); err != nil {
+		t.Fatalf("RevokeUserSessions() error = %v", err)
+	}
+	if sessions, _ := service.ListSessions(user.ID, ""); len(sessions) != 0 {
+		t.Fatalf("sessions after admin revoke = %d, want 0", len(sessions))
+	}
+}
+
+func TestNewDeviceSignInQueuesNotification(t *testing.T) {
+	service, user := newSessionTestService(t)
+	channel := model.NotificationChannel{UserID: user.ID, Type: "webhook", Enabled: true, Config: "{}"}
+	if err := service.DB.Create(&channel).Error; err != nil {
+		t.Fatalf("failed to create channel: %v", err)
+	}
+	if err := service.DB.AutoMigrate(&model.UserPreference{}); err != nil {
+		t.Fatalf("failed to migrate preferences: %v", err)
+	}
+	locale := "ja"
+	if _, err := NewUserPreferenceService(service.DB).UpdateLocalePreference(user.ID, UpdateLocalePreferenceInput{Locale: &locale}); err != nil {
+		t.Fatalf("UpdateLocalePreference() error = %v", err)
+	}
+
+	laptop := WithSessionClient(context.Background(), SessionClient{UserAgent: "Laptop"})
+	phone := WithSessionClient(context.Background(), SessionClient{UserAgent: "Phone"})
+	for _, ctx := range []context.Context{laptop, laptop, phone} {
+		if _, err := service.WithContext(ctx).CreateSession(user.ID); err != nil {
+			t.Fatalf("CreateSession() error = %v", err)
+		}
+	}
+
+	var outbox []model.NotificationOutbox
+	if err := service.DB.Where("trigger_type = ?", notificationTriggerNewLogin).Find(&outbox).Error; err != nil {
+		t.Fatalf("failed to load outbox: %v", err)
+	}
+	if len(outbox) != 1 {
+		t.Fatalf("queued %d new sign-in notifications, want 1", len(outbox))
+	}
+	if outbox[0].SubscriptionID != nil || outbox[0].ChannelID == nil || *outbox[0].ChannelID != channel.ID {
+		t.Fatalf("outbox = %+v", outbox[0])
+	}
+	if !strings.Contains(outbox[0].Message, "新しいサインイン") || !strings.Contains(outbox[0].Message, "Phone") {
+		t.Fatalf("message = %q, want Japanese alert for the new device", outbox[0].Message)
+	}
+}
+
+func TestNewDeviceIgnoresBrowserVersion(t *testing.T) {
+	service, user := newSessionTestService(t)
+
+	const (
+		chrome129 = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/129.0.6668.90 Safari/537.36"
+		chrome130 = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/130.0.6723.59 Safari/537.36"
+		edge130   = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/130.0.0.0 Safari/537.36 Edg/130.0.2849.46"
+		chromeMac = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/130.0.6723.59 Safari/537.36"
+	)
+	ctx := WithSessionClient(context.Background(), SessionClient{UserAgent: chrome129})
+	if _, err := service.WithContext(ctx).CreateSession(user.ID); err != nil {
+		t.Fatalf("CreateSession() error = %v", err)
+	}
+
+	for _, tc := range []struct {
+		userAgent string
+		want      bool
+	}{
+		{userAgent: chrome130, want: false},
+		{userAgent: edge130, want: true},
+		{userAgent: chromeMac, want: true},
+	} {
+		got, err := isNewSessionDevice(service.DB, user.ID, SessionClient{UserAgent: tc.userAgent})
+		if err != nil {
+			t.Fatalf("isNewSessionDevice() error = %v", err)
+		}
+		if got != tc.want {
+			t.Fatalf("isNewSessionDevice(%q) = %v, want %v", tc.userAgent, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shiroha/subdux/internal/model"
+)
+
+var ErrCalendarCollectionNotFound = errors.New("calendar not found")
+
+const (
+	calDAVCombinedCollection      = "subscriptions"
+	calDAVUncategorizedCollection = "uncategorized"
+	calDAVCategoryPrefix          = "category-"
+)
+
+// CalendarCollection is one read-only CalDAV calendar. Slug is its path
+// segment under the calendar home.
+type CalendarCollection struct {
+	Slug        string
+	DisplayName string
+
+	categoryID    *uint
+	uncategorized bool
+}
+
+// CalendarObject is a single subscription rendered as its own VCALENDAR, the
+// unit CalDAV clients fetch and cache by ETag.
+type CalendarObject struct {
+	Name           string
+	SubscriptionID uint
+	Data           string
+	ETag           string
+	LastModified   time.Time
+
+	sub model.Subscription
+}
+
+// CalendarCollectionSnapshot is a calendar together with its objects. CTag
+// changes whenever any object in the calendar changes.
+type CalendarCollectionSnapshot struct {
+	Collection CalendarCollection
+	Objects    []CalendarObject
+	CTag       string
+}
+
+// OccursBetween reports whether any occurrence of the object's all-day event
+// overlaps [start, end). A zero end means no upper bound.
+func (o CalendarObject) OccursBetween(start, end time.Time) bool {
+	eventDate, ok := icalEventDate(o.sub)
+	if !ok {
+		return false
+	}
+	first := normalizeDateUTC(eventDate)
+	startDate := normalizeDateUTC(start.UTC())
+	recurs := icalRecurrenceRule(o.sub) != ""
+
+	if end.IsZero() {
+		return !first.Before(startDate) || recurs
+	}
+	endDate := normalizeDateUTC(end.UTC())
+	if end.UTC().After(endDate) {
+		endDate = endDate.AddDate(0, 0, 1)
+	}
+	if !first.Before(startDate) && first.Before(endDate) {
+		return true
+	}
+	return recurs && len(subscriptionChargeDatesInRange(o.sub, startDate, endDate)) > 0
+}
+
+// CalDAVCollections lists the calendars a token exposes: one combined calendar,
+// or one per category plus an uncategorized calendar when the filter splits by
+// category.
+func (s *CalendarService) CalDAVCollections(userID uint, filter CalendarFeedFilter) ([]CalendarCollection, error) {
+	if !filter.SplitByCategory {
+		return []CalendarCollection{{Slug: calDAVCombinedCollection, DisplayName: "Subdux Subscriptions"}}, nil
+	}
+
+	query := s.DB.Where("user_id = ?", userID)
+	if len(filter.CategoryIDs) > 0 {
+		query = query.Where("id IN ?", filter.CategoryIDs)
+	}
+	var categories []model.Category
+	if err := query.Order("display_order ASC, id ASC").Find(&categories).Error; err != nil {
+		return nil, err
+	}
+
+	collections := make([]CalendarCollection, 0, len(categories)+1)
+	for _, category := range categories {
+		categoryID := category.ID
+		collections = append(collections, CalendarCollection{
+			Slug:        calDAVCategoryPrefix + strconv.FormatUint(uint64(category.ID), 10),
+			DisplayName: category.Name,
+			categoryID:  &categoryID,
+		})
+	}
+	if len(filter.CategoryIDs) == 0 {
+		collections = append(collections, CalendarCollection{
+			Slug:          calDAVUncategorizedCollection,
+			DisplayName:   "Uncategorized",
+			uncategorized: true,
+		})
+	}
+	return collections, nil
+}
+
+// CalDAVSnapshots renders every calendar a token exposes from the same
+// subscriptions the ICS feed shows, loading them once.
+func (s *CalendarService) CalDAVSnapshots(userID uint, filter CalendarFeedFilter) ([]CalendarCollectionSnapshot, error) {
+	collections, err := s.CalDAVCollections(userID, filter)
+	if err != nil {
+		return nil, err
+	}
+	subs, err := s.GetSubscriptionsForCalendarWithFilter(userID, filter)
+	if err != nil {
+		return nil, err
+	}
+	rc, err := s.loadICalRenderContext(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := make([]CalendarCollectionSnapshot, 0, len(collections))
+	for _, collection := range collections {
+		objects := make([]CalendarObject, 0)
+		for _, sub := range subs {
+			if !collection.includes(sub) {
+				continue
+			}
+			if _, ok := icalEventDate(sub); !ok {
+				continue
+			}
+			objects = append(objects, renderCalendarObject(sub, collection.DisplayName, rc))
+		}
+		sort.Slice(objects, func(i, j int) bool { return objects[i].SubscriptionID < objects[j].SubscriptionID })
+
+		ctag := sha256.New()
+		for _, object := range objects {
+			ctag.Write([]byte(object.Name + ":" + object.ETag + "\n"))
+		}
+		snapshots = append(snapshots, CalendarCollectionSnapshot{
+			Collection: collection,
+			Objects:    objects,
+			CTag:       hex.EncodeToString(ctag.Sum(nil)),
+		})
+	}
+	return snapshots, nil
+}
+
+// CalDAVCollection renders a single calendar by slug.
+func (s *CalendarService) CalDAVCollection(userID uint, filter CalendarFeedFilter, slug string) (*CalendarCollectionSnapshot, error) {
+	snapshots, err := s.CalDAVSnapshots(userID, filter)
+	if err != nil {
+		return nil, err
+	}
+	for i := range snapshots {
+		if snapshots[i].Collection.Slug == slug {
+			return &snapshots[i], nil
+		}
+	}
+	return nil, ErrCalendarCollectionNotFound
+}
+
+func (c CalendarCollection) includes(sub model.Subscription) bool {
+	switch {
+	case c.categoryID != nil:
+		return sub.CategoryID != nil && *sub.CategoryID == *c.categoryID
+	case c.uncategorized:
+		return sub.CategoryID == nil
+	default:
+		return true
+	}
+}
+
+func renderCalendarObject(sub model.Subscription, calendarName string, rc icalRenderContext) CalendarObject {
+	var sb strings.Builder
+	writeICalCalendarStart(&sb, calendarName, rc.location)
+	writeICalEvent(&sb, sub, rc)
+	sb.WriteString("END:VCALENDAR\r\n")
+	data := sb.String()
+
+	sum := sha256.Sum256([]byte(data))
+	return CalendarObject{
+		Name:           fmt.Sprintf("subdux-sub-%d.ics", sub.ID),
+		SubscriptionID: sub.ID,
+		Data:           data,
+		ETag:           hex.EncodeToString(sum[:]),
+		LastModified:   sub.UpdatedAt.UTC().Truncate(time.Second),
+		sub:            sub,
+	}
+}
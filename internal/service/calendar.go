@@ -4,24 +4,70 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"github.com/shiroha/subdux/internal/pkg"
+	"math"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/shiroha/subdux/internal/model"
+	"github.com/shiroha/subdux/internal/pkg"
+	"github.com/shiroha/subdux/internal/pkg/money"
 	"gorm.io/gorm"
 )
 
+var (
+	ErrCalendarTokenNotFound              = errors.New("token not found")
+	ErrInvalidCalendarFilterCategory      = errors.New("calendar filter references an unknown category")
+	ErrInvalidCalendarFilterPaymentMethod = errors.New("calendar filter references an unknown payment method")
+	ErrInvalidCalendarFilterMinAmount     = errors.New("calendar filter min_amount must be zero or greater")
+)
+
 type CalendarService struct {
-	DB *gorm.DB
+	DB        *gorm.DB
+	converter CurrencyConverter
 }
 
 func NewCalendarService(db *gorm.DB) *CalendarService {
 	return &CalendarService{DB: db}
 }
 
+// SetCurrencyConverter sets the converter used to compare subscription amounts
+// with a token's minimum amount. Without one, amounts are compared as-is.
+func (s *CalendarService) SetCurrencyConverter(converter CurrencyConverter) {
+	s.converter = converter
+}
+
+// CalendarFeedFilter narrows the subscriptions a calendar token exposes. Empty
+// ID lists match everything. SplitByCategory only affects CalDAV, where it
+// exposes one calendar per category instead of a single combined calendar.
+type CalendarFeedFilter struct {
+	CategoryIDs      []uint
+	PaymentMethodIDs []uint
+	MinAmount        *float64
+	SplitByCategory  bool
+}
+
+// CalendarFeed is a rendered feed with the validators clients use for
+// conditional requests.
+type CalendarFeed struct {
+	Body         string
+	ETag         string
+	LastModified time.Time
+}
+
 func (s *CalendarService) GenerateToken(userID uint, name string) (*model.CalendarToken, error) {
+	return s.GenerateTokenWithFilter(userID, name, CalendarFeedFilter{})
+}
+
+func (s *CalendarService) GenerateTokenWithFilter(userID uint, name string, filter CalendarFeedFilter) (*model.CalendarToken, error) {
+	normalized, err := s.normalizeCalendarFeedFilter(userID, filter)
+	if err != nil {
+		return nil, err
+	}
+
 	b := make([]byte, 32)
 	if _, err := rand.Read(b); err != nil {
 		return nil, fmt.Errorf("failed to generate token: %w", err)
@@ -30,10 +76,14 @@ func (s *CalendarService) GenerateToken(userID uint, name string) (*model.Calend
 	tokenHash := hashCalendarToken(token)
 
 	ct := model.CalendarToken{
-		UserID:    userID,
-		Token:     tokenHash,
-		Name:      name,
-		CreatedAt: pkg.NowUTC(),
+		UserID:           userID,
+		Token:            tokenHash,
+		Name:             name,
+		CategoryIDs:      encodeCalendarFilterIDs(normalized.CategoryIDs),
+		PaymentMethodIDs: encodeCalendarFilterIDs(normalized.PaymentMethodIDs),
+		MinAmount:        normalized.MinAmount,
+		SplitByCategory:  normalized.SplitByCategory,
+		CreatedAt:        pkg.NowUTC(),
 	}
 	if err := s.DB.Create(&ct).Error; err != nil {
 		return nil, err
@@ -59,39 +109,81 @@ func (s *CalendarService) DeleteToken(userID uint, tokenID uint) error {
 		return result.Error
 	}
 	if result.RowsAffected == 0 {
-		return errors.New("token not found")
+		return ErrCalendarTokenNotFound
 	}
 	return nil
 }
 
-func (s *CalendarService) ValidateToken(token string) (uint, error) {
+// UpdateTokenFilter replaces a token's feed filter. The stored feed validators
+// are reset so the next fetch reports a fresh Last-Modified.
+func (s *CalendarService) UpdateTokenFilter(userID uint, tokenID uint, filter CalendarFeedFilter) (*model.CalendarToken, error) {
+	normalized, err := s.normalizeCalendarFeedFilter(userID, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	result := s.DB.Model(&model.CalendarToken{}).
+		Where("id = ? AND user_id = ?", tokenID, userID).
+		Updates(map[string]interface{}{
+			"category_ids":       encodeCalendarFilterIDs(normalized.CategoryIDs),
+			"payment_method_ids": encodeCalendarFilterIDs(normalized.PaymentMethodIDs),
+			"min_amount":         normalized.MinAmount,
+			"split_by_category":  normalized.SplitByCategory,
+			"feed_etag":          "",
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrCalendarTokenNotFound
+	}
+
+	var ct model.CalendarToken
+	if err := s.DB.Where("id = ? AND user_id = ?", tokenID, userID).First(&ct).Error; err != nil {
+		return nil, err
+	}
+	ct.Token = ""
+	return &ct, nil
+}
+
+// ResolveToken returns the calendar token for a feed URL token. Legacy
+// plaintext tokens are migrated to their hash on first use.
+func (s *CalendarService) ResolveToken(token string) (*model.CalendarToken, error) {
 	tokenHash := hashCalendarToken(token)
 
 	var ct model.CalendarToken
 	if err := s.DB.Where("token = ?", tokenHash).First(&ct).Error; err != nil {
 		if !errors.Is(err, gorm.ErrRecordNotFound) {
-			return 0, err
+			return nil, err
 		}
 
 		if err := s.DB.Where("token = ?", token).First(&ct).Error; err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
-				return 0, errors.New("invalid token")
+				return nil, errors.New("invalid token")
 			}
-			return 0, err
+			return nil, err
 		}
 
 		if migrateErr := s.DB.Model(&ct).Update("token", tokenHash).Error; migrateErr != nil {
-			return 0, migrateErr
+			return nil, migrateErr
 		}
 	}
 
 	if err := ensureUserActive(s.DB, ct.UserID); err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, errUserNotActive) {
-			return 0, errors.New("invalid token")
+			return nil, errors.New("invalid token")
 		}
-		return 0, err
+		return nil, err
 	}
 
+	return &ct, nil
+}
+
+func (s *CalendarService) ValidateToken(token string) (uint, error) {
+	ct, err := s.ResolveToken(token)
+	if err != nil {
+		return 0, err
+	}
 	return ct.UserID, nil
 }
 
@@ -100,74 +192,399 @@ func hashCalendarToken(token string) string {
 	return hex.EncodeToString(sum[:])
 }
 
+// CalendarTokenFilter decodes the feed filter stored on a token. Unreadable
+// stored lists are treated as empty so a bad row never hides the whole feed.
+func CalendarTokenFilter(ct model.CalendarToken) CalendarFeedFilter {
+	return CalendarFeedFilter{
+		CategoryIDs:      decodeCalendarFilterIDs(ct.CategoryIDs),
+		PaymentMethodIDs: decodeCalendarFilterIDs(ct.PaymentMethodIDs),
+		MinAmount:        ct.MinAmount,
+		SplitByCategory:  ct.SplitByCategory,
+	}
+}
+
+func decodeCalendarFilterIDs(raw string) []uint {
+	ids := []uint{}
+	if strings.TrimSpace(raw) == "" {
+		return ids
+	}
+	if err := json.Unmarshal([]byte(raw), &ids); err != nil {
+		return []uint{}
+	}
+	return ids
+}
+
+func encodeCalendarFilterIDs(ids []uint) string {
+	if len(ids) == 0 {
+		return "[]"
+	}
+	encoded, _ := json.Marshal(ids)
+	return string(encoded)
+}
+
+func (s *CalendarService) normalizeCalendarFeedFilter(userID uint, filter CalendarFeedFilter) (CalendarFeedFilter, error) {
+	if filter.MinAmount != nil && (math.IsNaN(*filter.MinAmount) || math.IsInf(*filter.MinAmount, 0) || *filter.MinAmount < 0) {
+		return filter, ErrInvalidCalendarFilterMinAmount
+	}
+
+	categoryIDs, err := s.ownedCalendarFilterIDs(&model.Category{}, userID, filter.CategoryIDs)
+	if err != nil {
+		return filter, err
+	}
+	if categoryIDs == nil {
+		return filter, ErrInvalidCalendarFilterCategory
+	}
+	paymentMethodIDs, err := s.ownedCalendarFilterIDs(&model.PaymentMethod{}, userID, filter.PaymentMethodIDs)
+	if err != nil {
+		return filter, err
+	}
+	if paymentMethodIDs == nil {
+		return filter, ErrInvalidCalendarFilterPaymentMethod
+	}
+
+	return CalendarFeedFilter{
+		CategoryIDs:      categoryIDs,
+		PaymentMethodIDs: paymentMethodIDs,
+		MinAmount:        filter.MinAmount,
+		SplitByCategory:  filter.SplitByCategory,
+	}, nil
+}
+
+// ownedCalendarFilterIDs de-duplicates ids and returns nil when any of them
+// does not belong to the user.
+func (s *CalendarService) ownedCalendarFilterIDs(table interface{}, userID uint, ids []uint) ([]uint, error) {
+	unique := make([]uint, 0, len(ids))
+	seen := make(map[uint]struct{}, len(ids))
+	for _, id := range ids {
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		unique = append(unique, id)
+	}
+	if len(unique) == 0 {
+		return unique, nil
+	}
+
+	var count int64
+	if err := s.DB.Model(table).Where("user_id = ? AND id IN ?", userID, unique).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count != int64(len(unique)) {
+		return nil, nil
+	}
+	sort.Slice(unique, func(i, j int) bool { return unique[i] < unique[j] })
+	return unique, nil
+}
+
 func (s *CalendarService) GetSubscriptionsForCalendar(userID uint) ([]model.Subscription, error) {
-	now := pkg.NowInSystemTimezone()
+	return s.GetSubscriptionsForCalendarWithFilter(userID, CalendarFeedFilter{})
+}
 
-	var subs []model.Subscription
-	if err := s.DB.Where(
-		"user_id = ? AND status = ? AND renewal_mode != ? AND next_billing_date IS NOT NULL",
+// GetSubscriptionsForCalendarWithFilter loads the active subscriptions a feed
+// shows. Cancel-at-period-end subscriptions are included so the feed can mark
+// when access ends.
+func (s *CalendarService) GetSubscriptionsForCalendarWithFilter(userID uint, filter CalendarFeedFilter) ([]model.Subscription, error) {
+	now := userNow(s.DB, userID)
+
+	query := s.DB.Where(
+		"user_id = ? AND status = ? AND next_billing_date IS NOT NULL",
 		userID,
 		subscriptionStatusActive,
-		renewalModeCancelAtPeriodEnd,
-	).
-		Order("next_billing_date ASC").
-		Find(&subs).Error; err != nil {
+	)
+	if len(filter.CategoryIDs) > 0 {
+		query = query.Where("category_id IN ?", filter.CategoryIDs)
+	}
+	if len(filter.PaymentMethodIDs) > 0 {
+		query = query.Where("payment_method_id IN ?", filter.PaymentMethodIDs)
+	}
+
+	var subs []model.Subscription
+	if err := query.Order("next_billing_date ASC").Find(&subs).Error; err != nil {
 		return nil, err
 	}
 	// Present lifecycle in memory (no writes): auto-renew dates roll forward and
 	// overdue manual-renew subscriptions drop out, matching what the calendar
 	// would show once the background sweep persists those transitions.
-	return presentActiveSubscriptions(subs, now), nil
+	subs = presentActiveSubscriptions(subs, now)
+
+	if filter.MinAmount == nil {
+		return subs, nil
+	}
+	currency, err := userPreferredCurrency(s.DB, userID)
+	if err != nil {
+		return nil, err
+	}
+	filtered := make([]model.Subscription, 0, len(subs))
+	for _, sub := range subs {
+		amount := sub.Amount
+		if s.converter != nil && !strings.EqualFold(sub.Currency, currency) {
+			amount = s.converter.Convert(amount, sub.Currency, currency)
+		}
+		if amount >= *filter.MinAmount {
+			filtered = append(filtered, sub)
+		}
+	}
+	return filtered, nil
 }
 
 func (s *CalendarService) GenerateICalFeed(userID uint) (string, error) {
-	subs, err := s.GetSubscriptionsForCalendar(userID)
+	return s.generateICalFeed(userID, CalendarFeedFilter{})
+}
+
+// GenerateFeedForToken renders a token's filtered feed. The ETag is a hash of
+// the body and Last-Modified is when that token's feed last changed, so a
+// client polling an unchanged feed sees stable validators.
+func (s *CalendarService) GenerateFeedForToken(ct *model.CalendarToken) (*CalendarFeed, error) {
+	body, err := s.generateICalFeed(ct.UserID, CalendarTokenFilter(*ct))
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256([]byte(body))
+	etag := hex.EncodeToString(sum[:])
+	modifiedAt := ct.CreatedAt
+	if ct.FeedModifiedAt != nil {
+		modifiedAt = *ct.FeedModifiedAt
+	}
+	if ct.FeedETag != etag {
+		modifiedAt = pkg.NowUTC()
+		if err := s.DB.Model(&model.CalendarToken{}).Where("id = ?", ct.ID).UpdateColumns(map[string]interface{}{
+			"feed_etag":        etag,
+			"feed_modified_at": modifiedAt,
+		}).Error; err != nil {
+			return nil, err
+		}
+		ct.FeedETag = etag
+		ct.FeedModifiedAt = &modifiedAt
+	}
+
+	return &CalendarFeed{
+		Body:         body,
+		ETag:         etag,
+		LastModified: modifiedAt.UTC().Truncate(time.Second),
+	}, nil
+}
+
+func (s *CalendarService) generateICalFeed(userID uint, filter CalendarFeedFilter) (string, error) {
+	subs, err := s.GetSubscriptionsForCalendarWithFilter(userID, filter)
+	if err != nil {
+		return "", err
+	}
+	rc, err := s.loadICalRenderContext(userID)
 	if err != nil {
 		return "", err
 	}
 
 	var sb strings.Builder
-	crlf := "\r\n"
+	writeICalCalendarStart(&sb, "Subdux Subscriptions", rc.location)
+	for _, sub := range subs {
+		writeICalEvent(&sb, sub, rc)
+	}
+	sb.WriteString("END:VCALENDAR\r\n")
+	return sb.String(), nil
+}
+
+// icalRenderContext holds the per-user data every event in a feed needs.
+type icalRenderContext struct {
+	policy        model.NotificationPolicy
+	categoryNames map[uint]string
+	location      *time.Location
+}
+
+func (s *CalendarService) loadICalRenderContext(userID uint) (icalRenderContext, error) {
+	policy, err := loadCalendarReminderPolicy(s.DB, userID)
+	if err != nil {
+		return icalRenderContext{}, err
+	}
+	categoryNames, err := s.calendarCategoryNames(userID)
+	if err != nil {
+		return icalRenderContext{}, err
+	}
+	return icalRenderContext{
+		policy:        policy,
+		categoryNames: categoryNames,
+		location:      loadUserLocation(s.DB, userID),
+	}, nil
+}
 
+func writeICalCalendarStart(sb *strings.Builder, name string, loc *time.Location) {
+	crlf := "\r\n"
 	sb.WriteString("BEGIN:VCALENDAR" + crlf)
 	sb.WriteString("VERSION:2.0" + crlf)
 	sb.WriteString("PRODID:-//Subdux//Calendar//EN" + crlf)
-	sb.WriteString(icalFold("X-WR-CALNAME:Subdux Subscriptions") + crlf)
+	sb.WriteString(icalFold("X-WR-CALNAME:"+icalEscape(name)) + crlf)
 	sb.WriteString("CALSCALE:GREGORIAN" + crlf)
 	sb.WriteString("METHOD:PUBLISH" + crlf)
+	if loc != nil {
+		sb.WriteString(icalFold("X-WR-TIMEZONE:"+loc.String()) + crlf)
+	}
+}
 
-	for _, sub := range subs {
-		if sub.NextBillingDate == nil {
-			continue
+// icalEventDate is the all-day date a subscription's event falls on: the next
+// billing date, or the end of access for cancel-at-period-end subscriptions
+// with no renewal left before it.
+func icalEventDate(sub model.Subscription) (time.Time, bool) {
+	if sub.NextBillingDate == nil {
+		return time.Time{}, false
+	}
+	if normalizeRenewalMode(sub.RenewalMode) == renewalModeCancelAtPeriodEnd && !icalChargesBeforePeriodEnd(sub) {
+		return *cancelAtPeriodEndBoundary(sub), true
+	}
+	return *sub.NextBillingDate, true
+}
+
+// icalChargesBeforePeriodEnd reports whether a cancel-at-period-end
+// subscription still renews before access ends, which happens when its end
+// date lies beyond the next billing date.
+func icalChargesBeforePeriodEnd(sub model.Subscription) bool {
+	return normalizeRenewalMode(sub.RenewalMode) == renewalModeCancelAtPeriodEnd &&
+		sub.BillingType == billingTypeRecurring &&
+		sub.NextBillingDate != nil &&
+		sub.EndsAt != nil &&
+		normalizeDateUTC(*sub.EndsAt).After(normalizeDateUTC(*sub.NextBillingDate)) &&
+		isRecurringScheduleValid(sub)
+}
+
+// icalRecurrenceRule returns the RRULE value for a subscription's event, or ""
+// when the event does not repeat. A cancel-at-period-end subscription repeats
+// only while it still renews, and stops the day before access ends.
+func icalRecurrenceRule(sub model.Subscription) string {
+	var until *time.Time
+	switch {
+	case icalChargesBeforePeriodEnd(sub):
+		lastCharge := normalizeDateUTC(*sub.EndsAt).AddDate(0, 0, -1)
+		until = &lastCharge
+	case sub.BillingType == billingTypeRecurring &&
+		normalizeRenewalMode(sub.RenewalMode) == renewalModeAutoRenew &&
+		isRecurringScheduleValid(sub):
+		if sub.EndsAt != nil && sub.NextBillingDate != nil && sub.EndsAt.After(*sub.NextBillingDate) {
+			until = sub.EndsAt
 		}
+	default:
+		return ""
+	}
 
-		dateStr := sub.NextBillingDate.UTC().Format("20060102")
-		summary := fmt.Sprintf("%s - %.2f %s", sub.Name, sub.Amount, sub.Currency)
+	rrule := buildRRule(sub)
+	if rrule != "" && until != nil {
+		rrule += ";UNTIL=" + until.UTC().Format("20060102")
+	}
+	return rrule
+}
 
-		sb.WriteString("BEGIN:VEVENT" + crlf)
-		sb.WriteString(icalFold(fmt.Sprintf("UID:subdux-sub-%d@subdux", sub.ID)) + crlf)
-		sb.WriteString(icalFold("DTSTART;VALUE=DATE:"+dateStr) + crlf)
-		sb.WriteString(icalFold("DTEND;VALUE=DATE:"+dateStr) + crlf)
-		sb.WriteString(icalFold("SUMMARY:"+icalEscape(summary)) + crlf)
+func writeICalEvent(sb *strings.Builder, sub model.Subscription, rc icalRenderContext) {
+	eventDate, ok := icalEventDate(sub)
+	if !ok {
+		return
+	}
+	crlf := "\r\n"
 
-		if sub.Notes != "" {
-			sb.WriteString(icalFold("DESCRIPTION:"+icalEscape(sub.Notes)) + crlf)
-		}
+	summary := fmt.Sprintf("%s - %s %s", sub.Name, money.Format(sub.Amount, sub.Currency), sub.Currency)
+	alarmText := fmt.Sprintf("%s renews", sub.Name)
+	if normalizeRenewalMode(sub.RenewalMode) == renewalModeCancelAtPeriodEnd && !icalChargesBeforePeriodEnd(sub) {
+		summary = fmt.Sprintf("%s - ends", sub.Name)
+		alarmText = fmt.Sprintf("%s ends", sub.Name)
+	}
+	dateStr := eventDate.UTC().Format("20060102")
 
-		if sub.BillingType == billingTypeRecurring &&
-			normalizeRenewalMode(sub.RenewalMode) == renewalModeAutoRenew &&
-			isRecurringScheduleValid(sub) {
-			rrule := buildRRule(sub)
-			if rrule != "" {
-				sb.WriteString(icalFold("RRULE:"+rrule) + crlf)
-			}
+	sb.WriteString("BEGIN:VEVENT" + crlf)
+	sb.WriteString(icalFold(fmt.Sprintf("UID:subdux-sub-%d@subdux", sub.ID)) + crlf)
+	sb.WriteString("DTSTAMP:" + sub.UpdatedAt.UTC().Format("20060102T150405Z") + crlf)
+	sb.WriteString(icalFold("DTSTART;VALUE=DATE:"+dateStr) + crlf)
+	sb.WriteString(icalFold("DTEND;VALUE=DATE:"+dateStr) + crlf)
+	sb.WriteString(icalFold("SUMMARY:"+icalEscape(summary)) + crlf)
+
+	if sub.Notes != "" {
+		sb.WriteString(icalFold("DESCRIPTION:"+icalEscape(sub.Notes)) + crlf)
+	}
+	if url := strings.TrimSpace(sub.URL); url != "" {
+		sb.WriteString(icalFold("URL:"+url) + crlf)
+	}
+	if category := calendarCategoryName(sub, rc.categoryNames); category != "" {
+		sb.WriteString(icalFold("CATEGORIES:"+icalEscape(category)) + crlf)
+	}
+
+	if rrule := icalRecurrenceRule(sub); rrule != "" {
+		sb.WriteString(icalFold("RRULE:"+rrule) + crlf)
+	}
+
+	for _, trigger := range calendarAlarmTriggers(sub, rc.policy) {
+		sb.WriteString("BEGIN:VALARM" + crlf)
+		sb.WriteString("ACTION:DISPLAY" + crlf)
+		sb.WriteString("TRIGGER:" + trigger + crlf)
+		sb.WriteString(icalFold("DESCRIPTION:"+icalEscape(alarmText)) + crlf)
+		sb.WriteString("END:VALARM" + crlf)
+	}
+
+	sb.WriteString("END:VEVENT" + crlf)
+}
+
+// loadCalendarReminderPolicy reads the user's reminder policy without creating
+// one, falling back to the same defaults the notification service uses.
+func loadCalendarReminderPolicy(db *gorm.DB, userID uint) (model.NotificationPolicy, error) {
+	var policy model.NotificationPolicy
+	if err := db.Where("user_id = ?", userID).Limit(1).Find(&policy).Error; err != nil {
+		return policy, err
+	}
+	if policy.ID == 0 {
+		return model.NotificationPolicy{UserID: userID, DaysBefore: 3, NotifyOnDueDay: true}, nil
+	}
+	return policy, nil
+}
+
+func (s *CalendarService) calendarCategoryNames(userID uint) (map[uint]string, error) {
+	var categories []model.Category
+	if err := s.DB.Select("id", "name").Where("user_id = ?", userID).Find(&categories).Error; err != nil {
+		return nil, err
+	}
+	names := make(map[uint]string, len(categories))
+	for _, category := range categories {
+		names[category.ID] = category.Name
+	}
+	return names, nil
+}
+
+func calendarCategoryName(sub model.Subscription, names map[uint]string) string {
+	if sub.CategoryID != nil {
+		if name := strings.TrimSpace(names[*sub.CategoryID]); name != "" {
+			return name
 		}
+	}
+	return strings.TrimSpace(sub.Category)
+}
 
-		sb.WriteString("END:VEVENT" + crlf)
+// calendarAlarmTriggers mirrors the reminder offsets the notification
+// scheduler uses for a subscription. Alarms fire at 09:00 local time.
+func calendarAlarmTriggers(sub model.Subscription, policy model.NotificationPolicy) []string {
+	if sub.NotifyEnabled != nil && !*sub.NotifyEnabled {
+		return nil
+	}
+	daysBefore := policy.DaysBefore
+	if sub.NotifyDaysBefore != nil {
+		daysBefore = *sub.NotifyDaysBefore
 	}
 
-	sb.WriteString("END:VCALENDAR" + crlf)
-	return sb.String(), nil
+	var triggers []string
+	if daysBefore > 0 {
+		triggers = append(triggers, icalAlarmTrigger(daysBefore))
+	}
+	if policy.NotifyOnDueDay {
+		triggers = append(triggers, icalAlarmTrigger(0))
+	}
+	return triggers
+}
+
+// icalAlarmTrigger returns a trigger relative to the start of an all-day event
+// that fires at 09:00 daysBefore days earlier.
+func icalAlarmTrigger(daysBefore int) string {
+	switch {
+	case daysBefore <= 0:
+		return "PT9H"
+	case daysBefore == 1:
+		return "-PT15H"
+	default:
+		return fmt.Sprintf("-P%dDT15H", daysBefore-1)
+	}
 }
 
 func buildRRule(sub model.Subscription) string {
@@ -0,0 +1,206 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shiroha/subdux/internal/model"
+	"github.com/shiroha/subdux/internal/pkg"
+	"gorm.io/gorm"
+)
+
+func createCalendarFeedSubscription(t *testing.T, db *gorm.DB, userID uint, input CreateSubscriptionInput) model.Subscription {
+	t.Helper()
+
+	intervalCount := 1
+	if input.Status == "" {
+		input.Status = subscriptionStatusActive
+	}
+	if input.RenewalMode == "" {
+		input.RenewalMode = renewalModeAutoRenew
+	}
+	input.BillingType = billingTypeRecurring
+	input.RecurrenceType = recurrenceTypeInterval
+	input.IntervalCount = &intervalCount
+	input.IntervalUnit = intervalUnitMonth
+	sub, err := NewSubscriptionService(db).Create(userID, input)
+	if err != nil {
+		t.Fatalf("create subscription %q failed: %v", input.Name, err)
+	}
+	return *sub
+}
+
+func TestGenerateICalFeedIncludesAlarmsURLAndCategories(t *testing.T) {
+	restoreClock := pkg.SetNowForTest(mustDate(t, "2026-03-01"))
+	t.Cleanup(restoreClock)
+
+	db := newTestDB(t)
+	user := createTestUser(t, db)
+	category := model.Category{UserID: user.ID, Name: "Streaming"}
+	if err := db.Create(&category).Error; err != nil {
+		t.Fatalf("create category failed: %v", err)
+	}
+	if err := db.Create(&model.NotificationPolicy{UserID: user.ID, DaysBefore: 2, NotifyOnDueDay: true}).Error; err != nil {
+		t.Fatalf("create policy failed: %v", err)
+	}
+
+	createCalendarFeedSubscription(t, db, user.ID, CreateSubscriptionInput{
+		Name:            "Video",
+		Amount:          9.99,
+		CategoryID:      &category.ID,
+		URL:             "https://video.example.com/account",
+		NextBillingDate: "2026-03-10",
+	})
+	muted := false
+	createCalendarFeedSubscription(t, db, user.ID, CreateSubscriptionInput{
+		Name:            "Muted",
+		Amount:          1,
+		NotifyEnabled:   &muted,
+		NextBillingDate: "2026-03-12",
+	})
+
+	feed, err := NewCalendarService(db).GenerateICalFeed(user.ID)
+	if err != nil {
+		t.Fatalf("GenerateICalFeed() error = %v", err)
+	}
+
+	for _, want := range []string{
+		"URL:https://video.example.com/account\r\n",
+		"CATEGORIES:Streaming\r\n",
+		"TRIGGER:-P1DT15H\r\n",
+		"TRIGGER:PT9H\r\n",
+		"DESCRIPTION:Video renews\r\n",
+	} {
+		if !strings.Contains(feed, want) {
+			t.Fatalf("feed missing %q:\n%s", want, feed)
+		}
+	}
+	if got := strings.Count(feed, "BEGIN:VALARM"); got != 2 {
+		t.Fatalf("feed has %d alarms, want 2 (muted subscription has none):\n%s", got, feed)
+	}
+}
+
+func TestCalendarTokenFilterNarrowsFeed(t *testing.T) {
+	restoreClock := pkg.SetNowForTest(mustDate(t, "2026-03-01"))
+	t.Cleanup(restoreClock)
+
+	db := newTestDB(t)
+	user := createTestUser(t, db)
+	work := model.Category{UserID: user.ID, Name: "Work"}
+	if err := db.Create(&work).Error; err != nil {
+		t.Fatalf("create category failed: %v", err)
+	}
+
+	createCalendarFeedSubscription(t, db, user.ID, CreateSubscriptionInput{
+		Name: "Office Suite", Amount: 20, Currency: "USD", CategoryID: &work.ID, NextBillingDate: "2026-03-10",
+	})
+	createCalendarFeedSubscription(t, db, user.ID, CreateSubscriptionInput{
+		Name: "Notes App", Amount: 2, Currency: "USD", CategoryID: &work.ID, NextBillingDate: "2026-03-11",
+	})
+	createCalendarFeedSubscription(t, db, user.ID, CreateSubscriptionInput{
+		Name: "Games", Amount: 30, Currency: "USD", NextBillingDate: "2026-03-12",
+	})
+
+	calendarService := NewCalendarService(db)
+	minAmount := 10.0
+	token, err := calendarService.GenerateTokenWithFilter(user.ID, "Work", CalendarFeedFilter{
+		CategoryIDs: []uint{work.ID, work.ID},
+		MinAmount:   &minAmount,
+	})
+	if err != nil {
+		t.Fatalf("GenerateTokenWithFilter() error = %v", err)
+	}
+
+	resolved, err := calendarService.ResolveToken(token.Token)
+	if err != nil {
+		t.Fatalf("ResolveToken() error = %v", err)
+	}
+	if filter := CalendarTokenFilter(*resolved); len(filter.CategoryIDs) != 1 {
+		t.Fatalf("stored category filter = %v, want de-duplicated single id", filter.CategoryIDs)
+	}
+
+	feed, err := calendarService.GenerateFeedForToken(resolved)
+	if err != nil {
+		t.Fatalf("GenerateFeedForToken() error = %v", err)
+	}
+	if !strings.Contains(feed.Body, "Office Suite") {
+		t.Fatalf("filtered feed missing matching subscription:\n%s", feed.Body)
+	}
+	if strings.Contains(feed.Body, "Notes App") || strings.Contains(feed.Body, "Games") {
+		t.Fatalf("filtered feed includes excluded subscriptions:\n%s", feed.Body)
+	}
+
+	other := model.User{Username: "other", Email: "other@example.com", Password: "x", Role: "user", Status: "active"}
+	if err := db.Create(&other).Error; err != nil {
+		t.Fatalf("create user failed: %v", err)
+	}
+	foreign := model.Category{UserID: other.ID, Name: "Foreign"}
+	if err := db.Create(&foreign).Error; err != nil {
+		t.Fatalf("create category failed: %v", err)
+	}
+	if _, err := calendarService.UpdateTokenFilter(user.ID, token.ID, CalendarFeedFilter{CategoryIDs: []uint{foreign.ID}}); !errors.Is(err, ErrInvalidCalendarFilterCategory) {
+		t.Fatalf("UpdateTokenFilter(foreign category) error = %v, want ErrInvalidCalendarFilterCategory", err)
+	}
+	negative := -1.0
+	if _, err := calendarService.UpdateTokenFilter(user.ID, token.ID, CalendarFeedFilter{MinAmount: &negative}); !errors.Is(err, ErrInvalidCalendarFilterMinAmount) {
+		t.Fatalf("UpdateTokenFilter(negative) error = %v, want ErrInvalidCalendarFilterMinAmount", err)
+	}
+}
+
+func TestGenerateFeedForTokenKeepsValidatorsUntilFeedChanges(t *testing.T) {
+	current := time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
+	restoreClock := pkg.SetNowForTest(current)
+	t.Cleanup(restoreClock)
+
+	db := newTestDB(t)
+	user := createTestUser(t, db)
+	sub := createCalendarFeedSubscription(t, db, user.ID, CreateSubscriptionInput{
+		Name: "Music", Amount: 5, NextBillingDate: "2026-03-10",
+	})
+
+	calendarService := NewCalendarService(db)
+	created, err := calendarService.GenerateToken(user.ID, "Personal")
+	if err != nil {
+		t.Fatalf("GenerateToken() error = %v", err)
+	}
+	token, err := calendarService.ResolveToken(created.Token)
+	if err != nil {
+		t.Fatalf("ResolveToken() error = %v", err)
+	}
+
+	first, err := calendarService.GenerateFeedForToken(token)
+	if err != nil {
+		t.Fatalf("GenerateFeedForToken() error = %v", err)
+	}
+
+	restoreClock()
+	restoreClock = pkg.SetNowForTest(current.Add(time.Hour))
+	token, _ = calendarService.ResolveToken(created.Token)
+	second, err := calendarService.GenerateFeedForToken(token)
+	if err != nil {
+		t.Fatalf("GenerateFeedForToken() error = %v", err)
+	}
+	if second.ETag != first.ETag || !second.LastModified.Equal(first.LastModified) {
+		t.Fatalf("unchanged feed validators changed: first=%+v second=%+v", first, second)
+	}
+
+	if err := db.Model(&model.Subscription{}).Where("id = ?", sub.ID).Updates(map[string]interface{}{
+		"name":       "Music Plus",
+		"updated_at": current.Add(time.Hour),
+	}).Error; err != nil {
+		t.Fatalf("update subscription failed: %v", err)
+	}
+	token, _ = calendarService.ResolveToken(created.Token)
+	third, err := calendarService.GenerateFeedForToken(token)
+	if err != nil {
+		t.Fatalf("GenerateFeedForToken() error = %v", err)
+	}
+	if third.ETag == first.ETag {
+		t.Fatal("changed feed kept the previous ETag")
+	}
+	if !third.LastModified.Equal(current.Add(time.Hour)) {
+		t.Fatalf("LastModified = %v, want %v", third.LastModified, current.Add(time.Hour))
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/shiroha/subdux/internal/model"
 	"github.com/shiroha/subdux/internal/pkg"
 )
 
@@ -41,7 +42,7 @@ func TestGenerateICalFeedOmitsRRuleForNonAutoRenewRecurring(t *testing.T) {
 	}
 }
 
-func TestGenerateICalFeedOmitsCancelAtPeriodEndSubscription(t *testing.T) {
+func TestGenerateICalFeedMarksCancelAtPeriodEndWithoutBillingEvent(t *testing.T) {
 	restoreClock := pkg.SetNowForTest(mustDate(t, "2026-03-01"))
 	t.Cleanup(restoreClock)
 
@@ -70,7 +71,51 @@ func TestGenerateICalFeedOmitsCancelAtPeriodEndSubscription(t *testing.T) {
 		t.Fatalf("GenerateICalFeed() error = %v", err)
 	}
 
-	if strings.Contains(feed, "Ending recurring") {
+	if strings.Contains(feed, "Ending recurring - 7.99") || strings.Contains(feed, "RRULE:") {
 		t.Fatal("cancel_at_period_end subscription should not emit a billing event in iCal feed")
 	}
+	if !strings.Contains(feed, "SUMMARY:Ending recurring - ends") || !strings.Contains(feed, "DTSTART;VALUE=DATE:20260310") {
+		t.Fatalf("feed does not mark when the subscription ends:\n%s", feed)
+	}
+}
+
+func TestGenerateICalFeedBoundsRenewalsBeforeCancelAtPeriodEnd(t *testing.T) {
+	restoreClock := pkg.SetNowForTest(mustDate(t, "2026-03-01"))
+	t.Cleanup(restoreClock)
+
+	db := newTestDB(t)
+	user := createTestUser(t, db)
+	service := NewSubscriptionService(db)
+	calendarService := NewCalendarService(db)
+
+	intervalCount := 1
+	sub, err := service.Create(user.ID, CreateSubscriptionInput{
+		Name:            "Ending later",
+		Amount:          7.99,
+		Status:          subscriptionStatusActive,
+		RenewalMode:     renewalModeCancelAtPeriodEnd,
+		BillingType:     billingTypeRecurring,
+		RecurrenceType:  recurrenceTypeInterval,
+		IntervalCount:   &intervalCount,
+		IntervalUnit:    intervalUnitMonth,
+		NextBillingDate: "2026-03-10",
+	})
+	if err != nil {
+		t.Fatalf("create ending recurring subscription failed: %v", err)
+	}
+	if err := db.Model(&model.Subscription{}).Where("id = ?", sub.ID).Update("ends_at", mustDate(t, "2026-06-10")).Error; err != nil {
+		t.Fatalf("failed to move ends_at: %v", err)
+	}
+
+	feed, err := calendarService.GenerateICalFeed(user.ID)
+	if err != nil {
+		t.Fatalf("GenerateICalFeed() error = %v", err)
+	}
+
+	if !strings.Contains(feed, "SUMMARY:Ending later - 7.99 USD") || !strings.Contains(feed, "DTSTART;VALUE=DATE:20260310") {
+		t.Fatalf("feed does not show the renewals left before access ends:\n%s", feed)
+	}
+	if !strings.Contains(feed, "RRULE:FREQ=MONTHLY;INTERVAL=1;UNTIL=20260609") {
+		t.Fatalf("feed does not stop the recurrence before access ends:\n%s", feed)
+	}
 }
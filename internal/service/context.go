@@ -41,6 +41,12 @@ func (s *CategoryService) WithContext(ctx context.Context) *CategoryService {
 	return &clone
 }
 
+func (s *CustomFieldService) WithContext(ctx context.Context) *CustomFieldService {
+	clone := *s
+	clone.DB = withContext(s.DB, ctx)
+	return &clone
+}
+
 func (s *PaymentMethodService) WithContext(ctx context.Context) *PaymentMethodService {
 	clone := *s
 	clone.DB = withContext(s.DB, ctx)
@@ -118,3 +124,15 @@ func (s *APIKeyService) WithContext(ctx context.Context) *APIKeyService {
 	clone.db = withContext(s.db, ctx)
 	return &clone
 }
+
+func (s *UserPreferenceService) WithContext(ctx context.Context) *UserPreferenceService {
+	clone := *s
+	clone.DB = withContext(s.DB, ctx)
+	return &clone
+}
+
+func (s *SCIMService) WithContext(ctx context.Context) *SCIMService {
+	clone := *s
+	clone.DB = withContext(s.DB, ctx)
+	return &clone
+}
@@ -0,0 +1,239 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/shiroha/subdux/internal/model"
+	"gorm.io/gorm"
+)
+
+const (
+	customFieldTypeText   = "text"
+	customFieldTypeNumber = "number"
+	customFieldTypeDate   = "date"
+	customFieldTypeSelect = "select"
+	customFieldTypeURL    = "url"
+	customFieldTypeSecret = "secret"
+
+	maxCustomFieldDefinitions = 50
+	maxCustomFieldNameLength  = 50
+	maxCustomFieldOptions     = 50
+	maxCustomFieldOptionLen   = 100
+)
+
+var (
+	ErrCustomFieldNotFound      = errors.New("custom field not found")
+	ErrCustomFieldKeyExists     = errors.New("custom field key already exists")
+	ErrCustomFieldInvalidKey    = errors.New("key must start with a lowercase letter and contain only lowercase letters, digits and underscores (max 40)")
+	ErrCustomFieldInvalidName   = errors.New("name must be 1-50 characters")
+	ErrCustomFieldInvalidType   = errors.New("type must be text, number, date, select, url or secret")
+	ErrCustomFieldInvalidOption = errors.New("select options must be 1-50 distinct values of 1-100 characters")
+	ErrCustomFieldLimitReached  = errors.New("only 50 custom fields can be defined")
+)
+
+var customFieldKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,39}$`)
+
+type CustomFieldService struct {
+	DB *gorm.DB
+}
+
+func NewCustomFieldService(db *gorm.DB) *CustomFieldService {
+	return &CustomFieldService{DB: db}
+}
+
+type CreateCustomFieldInput struct {
+	Key          string   `json:"key"`
+	Name         string   `json:"name"`
+	Type         string   `json:"type"`
+	Options      []string `json:"options"`
+	DisplayOrder int      `json:"display_order"`
+}
+
+// UpdateCustomFieldInput changes how a field is presented. Key and Type are
+// fixed once created because stored values and templates depend on them.
+type UpdateCustomFieldInput struct {
+	Name         *string  `json:"name"`
+	Options      []string `json:"options"`
+	DisplayOrder *int     `json:"display_order"`
+}
+
+func (s *CustomFieldService) List(userID uint) ([]model.CustomFieldDefinition, error) {
+	var fields []model.CustomFieldDefinition
+	err := s.DB.Where("user_id = ?", userID).Order("display_order ASC, id ASC").Find(&fields).Error
+	return fields, err
+}
+
+func (s *CustomFieldService) Create(userID uint, input CreateCustomFieldInput) (*model.CustomFieldDefinition, error) {
+	key := strings.TrimSpace(input.Key)
+	if !customFieldKeyPattern.MatchString(key) {
+		return nil, ErrCustomFieldInvalidKey
+	}
+	name, err := normalizeCustomFieldName(input.Name)
+	if err != nil {
+		return nil, err
+	}
+	fieldType := strings.ToLower(strings.TrimSpace(input.Type))
+	if !isValidCustomFieldType(fieldType) {
+		return nil, ErrCustomFieldInvalidType
+	}
+	options := "[]"
+	if fieldType == customFieldTypeSelect {
+		options, err = encodeCustomFieldOptions(input.Options)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var count int64
+	if err := s.DB.Model(&model.CustomFieldDefinition{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count >= maxCustomFieldDefinitions {
+		return nil, ErrCustomFieldLimitReached
+	}
+	var existing int64
+	if err := s.DB.Model(&model.CustomFieldDefinition{}).Where("user_id = ? AND field_key = ?", userID, key).Count(&existing).Error; err != nil {
+		return nil, err
+	}
+	if existing > 0 {
+		return nil, ErrCustomFieldKeyExists
+	}
+
+	field := model.CustomFieldDefinition{
+		UserID:       userID,
+		Key:          key,
+		Name:         name,
+		Type:         fieldType,
+		Options:      options,
+		DisplayOrder: input.DisplayOrder,
+	}
+	if err := s.DB.Create(&field).Error; err != nil {
+		return nil, err
+	}
+	return &field, nil
+}
+
+// Update renames or reorders a field and replaces the options of a select
+// field. Stored values that are no longer an option are kept until the
+// subscription is next edited.
+func (s *CustomFieldService) Update(userID, id uint, input UpdateCustomFieldInput) (*model.CustomFieldDefinition, error) {
+	var field model.CustomFieldDefinition
+	if err := s.DB.Where("id = ? AND user_id = ?", id, userID).First(&field).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCustomFieldNotFound
+		}
+		return nil, err
+	}
+
+	if input.Name != nil {
+		name, err := normalizeCustomFieldName(*input.Name)
+		if err != nil {
+			return nil, err
+		}
+		field.Name = name
+	}
+	if input.Options != nil && field.Type == customFieldTypeSelect {
+		options, err := encodeCustomFieldOptions(input.Options)
+		if err != nil {
+			return nil, err
+		}
+		field.Options = options
+	}
+	if input.DisplayOrder != nil {
+		field.DisplayOrder = *input.DisplayOrder
+	}
+
+	if err := s.DB.Save(&field).Error; err != nil {
+		return nil, err
+	}
+	return &field, nil
+}
+
+// Delete removes a field together with every subscription's value for it.
+func (s *CustomFieldService) Delete(userID, id uint) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		var field model.CustomFieldDefinition
+		if err := tx.Where("id = ? AND user_id = ?", id, userID).First(&field).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrCustomFieldNotFound
+			}
+			return err
+		}
+		if err := tx.Where("user_id = ? AND field_id = ?", userID, field.ID).Delete(&model.SubscriptionCustomFieldValue{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&field).Error
+	})
+}
+
+func (s *CustomFieldService) Reorder(userID uint, items []ReorderItem) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		for _, item := range items {
+			if err := tx.Model(&model.CustomFieldDefinition{}).
+				Where("id = ? AND user_id = ?", item.ID, userID).
+				Update("display_order", item.SortOrder).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func isValidCustomFieldType(fieldType string) bool {
+	switch fieldType {
+	case customFieldTypeText, customFieldTypeNumber, customFieldTypeDate,
+		customFieldTypeSelect, customFieldTypeURL, customFieldTypeSecret:
+		return true
+	default:
+		return false
+	}
+}
+
+func normalizeCustomFieldName(raw string) (string, error) {
+	name := strings.TrimSpace(raw)
+	if name == "" || utf8.RuneCountInString(name) > maxCustomFieldNameLength {
+		return "", ErrCustomFieldInvalidName
+	}
+	return name, nil
+}
+
+func encodeCustomFieldOptions(raw []string) (string, error) {
+	if len(raw) == 0 || len(raw) > maxCustomFieldOptions {
+		return "", ErrCustomFieldInvalidOption
+	}
+	options := make([]string, 0, len(raw))
+	seen := make(map[string]struct{}, len(raw))
+	for _, option := range raw {
+		option = strings.TrimSpace(option)
+		if option == "" || utf8.RuneCountInString(option) > maxCustomFieldOptionLen {
+			return "", ErrCustomFieldInvalidOption
+		}
+		if _, ok := seen[option]; ok {
+			return "", ErrCustomFieldInvalidOption
+		}
+		seen[option] = struct{}{}
+		options = append(options, option)
+	}
+	encoded, err := json.Marshal(options)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+// DecodeCustomFieldOptions returns the options of a select field, or nil for
+// other types and malformed rows.
+func DecodeCustomFieldOptions(field model.CustomFieldDefinition) []string {
+	if field.Type != customFieldTypeSelect {
+		return nil
+	}
+	var options []string
+	if err := json.Unmarshal([]byte(field.Options), &options); err != nil {
+		return nil
+	}
+	return options
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/shiroha/subdux/internal/model"
 	"github.com/shiroha/subdux/internal/pkg"
 	"github.com/shiroha/subdux/internal/pkg/logging"
+	"github.com/shiroha/subdux/internal/pkg/money"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
@@ -22,11 +23,11 @@ import (
 // instead of copying the mutex by value.
 type rateCache struct {
 	mu    sync.RWMutex
-	rates map[string]float64
+	rates map[string]money.Rate
 }
 
 func newRateCache() *rateCache {
-	return &rateCache{rates: make(map[string]float64)}
+	return &rateCache{rates: make(map[string]money.Rate)}
 }
 
 type ExchangeRateService struct {
@@ -119,7 +120,7 @@ func (s *ExchangeRateService) Convert(amount float64, from, to string) float64 {
 	s.cache.mu.RUnlock()
 
 	if ok {
-		return amount * rate
+		return convertAtRate(amount, rate, to)
 	}
 
 	var er model.ExchangeRate
@@ -128,12 +129,19 @@ func (s *ExchangeRateService) Convert(amount float64, from, to string) float64 {
 		s.cache.mu.Lock()
 		s.cache.rates[cacheKey(from, to)] = er.Rate
 		s.cache.mu.Unlock()
-		return amount * er.Rate
+		return convertAtRate(amount, er.Rate, to)
 	}
 
 	return amount
 }
 
+// convertAtRate multiplies amount by the stored decimal rate exactly and
+// rounds the result once, half away from zero, to the minor units of the
+// target currency.
+func convertAtRate(amount float64, rate money.Rate, to string) float64 {
+	return money.FromMinorUnits(money.MultiplyRate(amount, rate, to), to)
+}
+
 func (s *ExchangeRateService) GetRate(base, target string) (float64, bool) {
 	base = strings.ToUpper(base)
 	target = strings.ToUpper(target)
@@ -145,13 +153,13 @@ func (s *ExchangeRateService) GetRate(base, target string) (float64, bool) {
 	rate, ok := s.cache.rates[cacheKey(base, target)]
 	s.cache.mu.RUnlock()
 	if ok {
-		return rate, true
+		return rate.Float64(), true
 	}
 
 	var er model.ExchangeRate
 	if err := s.DB.Where("base_currency = ? AND target_currency = ?",
 		strings.ToLower(base), strings.ToLower(target)).First(&er).Error; err == nil {
-		return er.Rate, true
+		return er.Rate.Float64(), true
 	}
 
 	return 0, false
@@ -180,7 +188,7 @@ func (s *ExchangeRateService) ListRates(baseCurrency string) ([]ExchangeRateInfo
 		result[i] = ExchangeRateInfo{
 			BaseCurrency:   strings.ToUpper(r.BaseCurrency),
 			TargetCurrency: strings.ToUpper(r.TargetCurrency),
-			Rate:           r.Rate,
+			Rate:           r.Rate.Float64(),
 			Source:         r.Source,
 			FetchedAt:      r.FetchedAt,
 		}
@@ -294,7 +302,7 @@ func (s *ExchangeRateService) fetchFromFree() error {
 	return s.saveRates(allRates)
 }
 
-func (s *ExchangeRateService) fetchFreeBase(base string) (map[string]float64, error) {
+func (s *ExchangeRateService) fetchFreeBase(base string) (map[string]money.Rate, error) {
 	url := fmt.Sprintf("https://cdn.jsdelivr.net/npm/@fawazahmed0/currency-api@latest/v1/currencies/%s.min.json", base)
 	fallbackURL := fmt.Sprintf("https://latest.currency-api.pages.dev/v1/currencies/%s.min.json", base)
 
@@ -316,17 +324,27 @@ func (s *ExchangeRateService) fetchFreeBase(base string) (map[string]float64, er
 		return nil, fmt.Errorf("no rates found for base %s", base)
 	}
 
-	var rates map[string]float64
+	// Decoding into json.Number keeps the quoted digits instead of the
+	// nearest float.
+	var rates map[string]json.Number
 	if err := json.Unmarshal(ratesData, &rates); err != nil {
 		return nil, fmt.Errorf("unmarshal rates: %w", err)
 	}
 
-	filtered := make(map[string]float64)
+	filtered := make(map[string]money.Rate)
 	targets := s.getTargetCurrencies(base)
 	for _, t := range targets {
-		if rate, ok := rates[t]; ok {
-			filtered[t] = rate
+		quoted, ok := rates[t]
+		if !ok {
+			continue
 		}
+		rate, err := money.ParseRate(quoted.String())
+		if err != nil {
+			logging.Warn("skipping invalid free exchange rate",
+				slog.String("base", base), slog.String("target", t), slog.Any("error", err))
+			continue
+		}
+		filtered[t] = rate
 	}
 
 	return filtered, nil
@@ -371,8 +389,8 @@ func (s *ExchangeRateService) fetchFromPremium(apiKey string) error {
 
 		var result struct {
 			Data map[string]struct {
-				Code  string  `json:"code"`
-				Value float64 `json:"value"`
+				Code  string      `json:"code"`
+				Value json.Number `json:"value"`
 			} `json:"data"`
 		}
 		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
@@ -384,10 +402,16 @@ func (s *ExchangeRateService) fetchFromPremium(apiKey string) error {
 			if target == base {
 				continue
 			}
+			rate, err := money.ParseRate(item.Value.String())
+			if err != nil {
+				logging.Warn("skipping invalid premium exchange rate",
+					slog.String("base", base), slog.String("target", target), slog.Any("error", err))
+				continue
+			}
 			allRates = append(allRates, model.ExchangeRate{
 				BaseCurrency:   base,
 				TargetCurrency: target,
-				Rate:           item.Value,
+				Rate:           rate,
 				Source:         "premium",
 				FetchedAt:      now,
 			})
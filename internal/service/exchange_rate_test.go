@@ -0,0 +1,50 @@
+package service
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/shiroha/subdux/internal/model"
+)
+
+func TestRefreshRatesKeepsQuotedRateDigits(t *testing.T) {
+	db := newTestDB(t)
+	if err := db.AutoMigrate(&model.SystemSetting{}, &model.ExchangeRate{}); err != nil {
+		t.Fatalf("failed to migrate exchange rate tables: %v", err)
+	}
+	seedSystemSetting(t, db, "exchange_rate_source", "free")
+
+	originalCurrencies := commonCurrencies
+	commonCurrencies = []string{"usd", "eur"}
+	defer func() {
+		commonCurrencies = originalCurrencies
+	}()
+
+	svc := NewExchangeRateService(db)
+	svc.httpClient = &http.Client{Transport: exchangeRateTestRoundTripper(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(`{"usd":{"eur":0.49999999999999999}}`)),
+			Header:     make(http.Header),
+			Request:    req,
+		}, nil
+	})}
+
+	if err := svc.RefreshRates(); err != nil {
+		t.Fatalf("RefreshRates() error = %v, want nil", err)
+	}
+
+	var stored model.ExchangeRate
+	if err := db.Where("base_currency = ? AND target_currency = ?", "usd", "eur").First(&stored).Error; err != nil {
+		t.Fatalf("load stored rate: %v", err)
+	}
+	if stored.Rate != "0.49999999999999999" {
+		t.Fatalf("stored rate = %q, want the quoted digits", stored.Rate)
+	}
+	// The nearest float is exactly 0.5, which would round half a cent up.
+	if got := NewExchangeRateService(db).Convert(0.01, "USD", "EUR"); got != 0 {
+		t.Fatalf("Convert() = %v, want 0", got)
+	}
+}
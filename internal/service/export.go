@@ -18,15 +18,16 @@ func NewExportService(db *gorm.DB) *ExportService {
 }
 
 type UserExportData struct {
-	ExportedAt      time.Time              `json:"exported_at"`
-	SecretsIncluded bool                   `json:"secrets_included"`
-	User            UserExportInfo         `json:"user"`
-	Subscriptions   []model.Subscription   `json:"subscriptions"`
-	Categories      []model.Category       `json:"categories"`
-	PaymentMethods  []model.PaymentMethod  `json:"payment_methods"`
-	Currencies      []model.UserCurrency   `json:"currencies"`
-	Preference      *model.UserPreference  `json:"preference"`
-	Notifications   UserNotificationExport `json:"notifications"`
+	ExportedAt      time.Time                     `json:"exported_at"`
+	SecretsIncluded bool                          `json:"secrets_included"`
+	User            UserExportInfo                `json:"user"`
+	Subscriptions   []model.Subscription          `json:"subscriptions"`
+	Categories      []model.Category              `json:"categories"`
+	CustomFields    []model.CustomFieldDefinition `json:"custom_fields"`
+	PaymentMethods  []model.PaymentMethod         `json:"payment_methods"`
+	Currencies      []model.UserCurrency          `json:"currencies"`
+	Preference      *model.UserPreference         `json:"preference"`
+	Notifications   UserNotificationExport        `json:"notifications"`
 }
 
 type UserExportInfo struct {
@@ -58,6 +59,23 @@ func (s *ExportService) ExportUserData(userID uint, includeSecrets bool) (*UserE
 	if subs == nil {
 		subs = []model.Subscription{}
 	}
+	// Secret custom field values are left out of exports without secrets,
+	// like notification channel credentials.
+	customFieldSecrets := customFieldSecretsOmit
+	if includeSecrets {
+		customFieldSecrets = customFieldSecretsReveal
+	}
+	if err := loadSubscriptionCustomFields(s.DB, userID, subs, customFieldSecrets); err != nil {
+		return nil, err
+	}
+
+	var customFields []model.CustomFieldDefinition
+	if err := s.DB.Where("user_id = ?", userID).Order("display_order ASC, id ASC").Find(&customFields).Error; err != nil {
+		return nil, err
+	}
+	if customFields == nil {
+		customFields = []model.CustomFieldDefinition{}
+	}
 
 	var categories []model.Category
 	if err := s.DB.Where("user_id = ?", userID).Find(&categories).Error; err != nil {
@@ -150,6 +168,7 @@ func (s *ExportService) ExportUserData(userID uint, includeSecrets bool) (*UserE
 		},
 		Subscriptions:  subs,
 		Categories:     categories,
+		CustomFields:   customFields,
 		PaymentMethods: paymentMethods,
 		Currencies:     currencies,
 		Preference:     prefPtr,
@@ -28,6 +28,9 @@ func newExportTestDB(t *testing.T) *gorm.DB {
 		&model.Category{},
 		&model.PaymentMethod{},
 		&model.Subscription{},
+		&model.CustomFieldDefinition{},
+		&model.SubscriptionCustomFieldValue{},
+		&model.SubscriptionAttachment{},
 		&model.NotificationChannel{},
 		&model.NotificationPolicy{},
 		&model.NotificationTemplate{},
@@ -8,6 +8,7 @@ import (
 
 	"github.com/shiroha/subdux/internal/model"
 	"github.com/shiroha/subdux/internal/pkg"
+	"github.com/shiroha/subdux/internal/pkg/money"
 	"gorm.io/gorm"
 )
 
@@ -22,13 +23,14 @@ type SubduxImportRequest struct {
 }
 
 type SubduxImportData struct {
-	SecretsIncluded *bool                        `json:"secrets_included"`
-	Currencies      []model.UserCurrency         `json:"currencies"`
-	Categories      []model.Category             `json:"categories"`
-	PaymentMethods  []model.PaymentMethod        `json:"payment_methods"`
-	Subscriptions   []model.Subscription         `json:"subscriptions"`
-	Preference      *model.UserPreference        `json:"preference"`
-	Notifications   SubduxNotificationImportData `json:"notifications"`
+	SecretsIncluded *bool                         `json:"secrets_included"`
+	Currencies      []model.UserCurrency          `json:"currencies"`
+	Categories      []model.Category              `json:"categories"`
+	PaymentMethods  []model.PaymentMethod         `json:"payment_methods"`
+	CustomFields    []model.CustomFieldDefinition `json:"custom_fields"`
+	Subscriptions   []model.Subscription          `json:"subscriptions"`
+	Preference      *model.UserPreference         `json:"preference"`
+	Notifications   SubduxNotificationImportData  `json:"notifications"`
 }
 
 type SubduxNotificationImportData struct {
@@ -49,6 +51,13 @@ type PreviewTemplateChange struct {
 	IsNew       bool   `json:"is_new"`
 }
 
+type PreviewCustomFieldChange struct {
+	Key   string `json:"key"`
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	IsNew bool   `json:"is_new"`
+}
+
 type PreviewPreferenceChange struct {
 	WillCreate bool   `json:"will_create"`
 	WillUpdate bool   `json:"will_update"`
@@ -71,6 +80,7 @@ type SubduxImportPreview struct {
 	Currencies     []PreviewCurrencyChange          `json:"currencies"`
 	PaymentMethods []PreviewPaymentMethodChange     `json:"payment_methods"`
 	Categories     []PreviewCategoryChange          `json:"categories"`
+	CustomFields   []PreviewCustomFieldChange       `json:"custom_fields"`
 	Subscriptions  []PreviewSubscriptionChange      `json:"subscriptions"`
 	Channels       []PreviewChannelChange           `json:"channels"`
 	Templates      []PreviewTemplateChange          `json:"templates"`
@@ -91,6 +101,7 @@ func validateSubduxImportData(data SubduxImportData) error {
 	if len(data.Currencies) > maxSubduxImportItemsPerCollection ||
 		len(data.Categories) > maxSubduxImportItemsPerCollection ||
 		len(data.PaymentMethods) > maxSubduxImportItemsPerCollection ||
+		len(data.CustomFields) > maxSubduxImportItemsPerCollection ||
 		len(data.Subscriptions) > maxSubduxImportItemsPerCollection ||
 		len(data.Notifications.Channels) > maxSubduxImportItemsPerCollection ||
 		len(data.Notifications.Templates) > maxSubduxImportItemsPerCollection {
@@ -241,10 +252,10 @@ func findPaymentMethodBySystemKeyOrName(tx *gorm.DB, userID uint, incoming model
 
 func subscriptionExists(tx *gorm.DB, userID uint, sub model.Subscription) (bool, error) {
 	query := tx.Model(&model.Subscription{}).
-		Where("user_id = ? AND name = ? AND amount = ? AND currency = ? AND billing_type = ? AND recurrence_type = ? AND interval_unit = ?",
+		Where("user_id = ? AND name = ? AND amount_minor = ? AND currency = ? AND billing_type = ? AND recurrence_type = ? AND interval_unit = ?",
 			userID,
 			strings.TrimSpace(sub.Name),
-			sub.Amount,
+			money.ToMinorUnits(sub.Amount, strings.TrimSpace(sub.Currency)),
 			strings.TrimSpace(sub.Currency),
 			strings.TrimSpace(sub.BillingType),
 			strings.TrimSpace(sub.RecurrenceType),
@@ -288,6 +299,7 @@ func templateKey(template model.NotificationTemplate) string {
 		channelType = strings.TrimSpace(*template.ChannelType)
 	}
 	return strings.Join([]string{
+		normalizeNotificationTemplateKind(template.Kind),
 		strings.ToLower(channelType),
 		strings.ToLower(strings.TrimSpace(template.Format)),
 		strings.TrimSpace(template.Template),
@@ -305,6 +317,7 @@ func (s *ImportService) ImportFromSubdux(userID uint, data SubduxImportData, con
 		Currencies:     []PreviewCurrencyChange{},
 		PaymentMethods: []PreviewPaymentMethodChange{},
 		Categories:     []PreviewCategoryChange{},
+		CustomFields:   []PreviewCustomFieldChange{},
 		Subscriptions:  []PreviewSubscriptionChange{},
 		Channels:       []PreviewChannelChange{},
 		Templates:      []PreviewTemplateChange{},
@@ -313,6 +326,7 @@ func (s *ImportService) ImportFromSubdux(userID uint, data SubduxImportData, con
 	seenCurrencies := map[string]bool{}
 	seenCategories := map[string]bool{}
 	seenPaymentMethods := map[string]bool{}
+	seenCustomFields := map[string]bool{}
 	seenSubscriptions := map[string]bool{}
 	seenChannels := map[string]bool{}
 	seenTemplates := map[string]bool{}
@@ -473,6 +487,14 @@ func (s *ImportService) ImportFromSubdux(userID uint, data SubduxImportData, con
 				Icon:           incoming.Icon,
 				SortOrder:      incoming.SortOrder,
 			}
+			// Card details are optional, so an invalid set is dropped rather
+			// than failing the payment method.
+			if card, err := normalizePaymentMethodCardDetails(incoming.ExpiryMonth, incoming.ExpiryYear, incoming.LastFour, incoming.Issuer); err == nil {
+				created.ExpiryMonth = card.ExpiryMonth
+				created.ExpiryYear = card.ExpiryYear
+				created.LastFour = card.LastFour
+				created.Issuer = card.Issuer
+			}
 			if err := tx.Create(&created).Error; err != nil {
 				result.Errors = append(result.Errors, fmt.Sprintf("failed to create payment method %q: %v", name, err))
 				continue
@@ -483,6 +505,74 @@ func (s *ImportService) ImportFromSubdux(userID uint, data SubduxImportData, con
 			result.Imported++
 		}
 
+		for _, incoming := range data.CustomFields {
+			key := strings.TrimSpace(incoming.Key)
+			fieldType := strings.ToLower(strings.TrimSpace(incoming.Type))
+			name, nameErr := normalizeCustomFieldName(incoming.Name)
+			if !customFieldKeyPattern.MatchString(key) || nameErr != nil || !isValidCustomFieldType(fieldType) {
+				if confirm {
+					result.Errors = append(result.Errors, fmt.Sprintf("skipped invalid custom field %q", key))
+					result.Skipped++
+				}
+				continue
+			}
+			if seenCustomFields[key] {
+				continue
+			}
+			seenCustomFields[key] = true
+
+			var existing int64
+			if err := tx.Model(&model.CustomFieldDefinition{}).Where("user_id = ? AND field_key = ?", userID, key).Count(&existing).Error; err != nil {
+				return err
+			}
+			isNew := existing == 0
+
+			preview.CustomFields = append(preview.CustomFields, PreviewCustomFieldChange{
+				Key:   key,
+				Name:  name,
+				Type:  fieldType,
+				IsNew: isNew,
+			})
+
+			if !confirm || !isNew {
+				if confirm && !isNew {
+					result.Skipped++
+				}
+				continue
+			}
+
+			options := "[]"
+			if fieldType == customFieldTypeSelect {
+				var incomingOptions []string
+				if err := json.Unmarshal([]byte(incoming.Options), &incomingOptions); err != nil {
+					result.Errors = append(result.Errors, fmt.Sprintf("skipped custom field %q with invalid options", key))
+					result.Skipped++
+					continue
+				}
+				encoded, err := encodeCustomFieldOptions(incomingOptions)
+				if err != nil {
+					result.Errors = append(result.Errors, fmt.Sprintf("skipped custom field %q: %v", key, err))
+					result.Skipped++
+					continue
+				}
+				options = encoded
+			}
+
+			created := model.CustomFieldDefinition{
+				UserID:       userID,
+				Key:          key,
+				Name:         name,
+				Type:         fieldType,
+				Options:      options,
+				DisplayOrder: incoming.DisplayOrder,
+			}
+			if err := tx.Create(&created).Error; err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("failed to create custom field %q: %v", key, err))
+				continue
+			}
+			result.Imported++
+		}
+
 		for _, incoming := range data.Notifications.Channels {
 			channelType := strings.ToLower(strings.TrimSpace(incoming.Type))
 			canonicalConfig := canonicalChannelConfig(incoming.Config)
@@ -600,7 +690,8 @@ func (s *ImportService) ImportFromSubdux(userID uint, data SubduxImportData, con
 				}
 				continue
 			}
-			if err := validator.ValidateTemplate(templateText); err != nil {
+			kind := normalizeNotificationTemplateKind(incoming.Kind)
+			if err := validator.ValidateTemplateForKind(kind, templateText); err != nil {
 				if confirm {
 					result.Errors = append(result.Errors, fmt.Sprintf("invalid notification template: %v", err))
 					result.Skipped++
@@ -610,6 +701,7 @@ func (s *ImportService) ImportFromSubdux(userID uint, data SubduxImportData, con
 
 			templateForKey := model.NotificationTemplate{
 				ChannelType: channelTypeVal,
+				Kind:        kind,
 				Format:      format,
 				Template:    templateText,
 			}
@@ -619,7 +711,7 @@ func (s *ImportService) ImportFromSubdux(userID uint, data SubduxImportData, con
 			}
 			seenTemplates[key] = true
 
-			query := tx.Where("user_id = ? AND format = ? AND template = ?", userID, format, templateText)
+			query := tx.Where("user_id = ? AND kind = ? AND format = ? AND template = ?", userID, kind, format, templateText)
 			if channelTypeVal == nil {
 				query = query.Where("channel_type IS NULL")
 			} else {
@@ -654,6 +746,7 @@ func (s *ImportService) ImportFromSubdux(userID uint, data SubduxImportData, con
 			created := model.NotificationTemplate{
 				UserID:      userID,
 				ChannelType: channelTypeVal,
+				Kind:        kind,
 				Format:      format,
 				Template:    templateText,
 			}
@@ -801,6 +894,12 @@ func (s *ImportService) ImportFromSubdux(userID uint, data SubduxImportData, con
 				Name:             incoming.Name,
 				Amount:           incoming.Amount,
 				Currency:         incoming.Currency,
+				TaxRate:          incoming.TaxRate,
+				TaxInclusive:     incoming.TaxInclusive,
+				FXFee:            incoming.FXFee,
+				ProcessorFee:     incoming.ProcessorFee,
+				AnnualPlanAmount: incoming.AnnualPlanAmount,
+				LastUsedAt:       incoming.LastUsedAt,
 				Status:           normalizedLifecycle.Status,
 				RenewalMode:      normalizedLifecycle.RenewalMode,
 				EndsAt:           copyTimePointer(normalizedLifecycle.EndsAt),
@@ -828,6 +927,16 @@ func (s *ImportService) ImportFromSubdux(userID uint, data SubduxImportData, con
 				continue
 			}
 			result.Imported++
+
+			// Values for fields missing from this account are reported but do
+			// not undo the subscription.
+			writes, err := prepareCustomFieldValues(tx, userID, incoming.CustomFields)
+			if err == nil {
+				err = applyCustomFieldValues(tx, userID, created.ID, writes)
+			}
+			if err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("failed to import custom fields of subscription %q: %v", incoming.Name, err))
+			}
 		}
 
 		if data.Preference != nil {
@@ -890,10 +999,13 @@ func (s *ImportService) ImportFromSubdux(userID uint, data SubduxImportData, con
 					return err
 				}
 
+				incomingDigestFrequency := normalizeNotificationDigestFrequency(incomingPolicy.DigestFrequency)
 				willUpdate := !willCreate &&
 					(existing.DaysBefore != incomingPolicy.DaysBefore ||
 						existing.NotifyOnDueDay != incomingPolicy.NotifyOnDueDay ||
-						existing.NotifyManualRenewDaily != incomingPolicy.NotifyManualRenewDaily)
+						existing.NotifyManualRenewDaily != incomingPolicy.NotifyManualRenewDaily ||
+						normalizeNotificationDigestFrequency(existing.DigestFrequency) != incomingDigestFrequency ||
+						existing.DigestOnly != incomingPolicy.DigestOnly)
 				preview.Policy = &PreviewNotificationPolicyChange{
 					WillCreate:          willCreate,
 					WillUpdate:          willUpdate,
@@ -913,6 +1025,8 @@ func (s *ImportService) ImportFromSubdux(userID uint, data SubduxImportData, con
 							"days_before":               incomingPolicy.DaysBefore,
 							"notify_on_due_day":         incomingPolicy.NotifyOnDueDay,
 							"notify_manual_renew_daily": incomingPolicy.NotifyManualRenewDaily,
+							"digest_frequency":          incomingDigestFrequency,
+							"digest_only":               incomingPolicy.DigestOnly,
 						}
 						if err := tx.Model(&model.NotificationPolicy{}).Create(created).Error; err != nil {
 							result.Errors = append(result.Errors, fmt.Sprintf("failed to create policy: %v", err))
@@ -924,6 +1038,8 @@ func (s *ImportService) ImportFromSubdux(userID uint, data SubduxImportData, con
 							"days_before":               incomingPolicy.DaysBefore,
 							"notify_on_due_day":         incomingPolicy.NotifyOnDueDay,
 							"notify_manual_renew_daily": incomingPolicy.NotifyManualRenewDaily,
+							"digest_frequency":          incomingDigestFrequency,
+							"digest_only":               incomingPolicy.DigestOnly,
 						}
 						if err := tx.Model(&existing).Updates(updates).Error; err != nil {
 							result.Errors = append(result.Errors, fmt.Sprintf("failed to update policy: %v", err))
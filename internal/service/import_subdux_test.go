@@ -29,6 +29,11 @@ func newImportTestDB(t *testing.T) *gorm.DB {
 		&model.PaymentMethod{},
 		&model.Subscription{},
 		&model.SubscriptionEvent{},
+		&model.SubscriptionPriceChange{},
+		&model.SubscriptionUsage{},
+		&model.CustomFieldDefinition{},
+		&model.SubscriptionCustomFieldValue{},
+		&model.SubscriptionAttachment{},
 		&model.NotificationChannel{},
 		&model.NotificationTemplate{},
 		&model.NotificationPolicy{},
@@ -11,6 +11,7 @@ import (
 
 	"github.com/shiroha/subdux/internal/model"
 	"github.com/shiroha/subdux/internal/pkg"
+	"github.com/shiroha/subdux/internal/pkg/money"
 	"gorm.io/gorm"
 )
 
@@ -338,8 +339,8 @@ func (s *ImportService) ImportFromWallos(userID uint, data []WallosSubscription,
 				// Check against existing DB records
 				var count int64
 				if err := tx.Model(&model.Subscription{}).
-					Where("user_id = ? AND name = ? AND amount = ? AND currency = ? AND billing_type = ?",
-						userID, name, amount, currency, billingType).
+					Where("user_id = ? AND name = ? AND amount_minor = ? AND currency = ? AND billing_type = ?",
+						userID, name, money.ToMinorUnits(amount, currency), currency, billingType).
 					Count(&count).Error; err != nil {
 					return err
 				}
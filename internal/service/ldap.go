@@ -0,0 +1,737 @@
+package service
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/shiroha/subdux/internal/model"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultLDAPUserFilter        = "(uid={username})"
+	defaultLDAPUsernameAttribute = "uid"
+	defaultLDAPEmailAttribute    = "mail"
+	defaultLDAPGroupAttribute    = "memberOf"
+	defaultLDAPTimeoutSeconds    = 10
+	maxLDAPTimeoutSeconds        = 60
+	ldapUsernamePlaceholder      = "{username}"
+)
+
+var (
+	ErrInvalidLDAPSettings    = errors.New("invalid ldap settings")
+	ErrLDAPInvalidCredentials = errors.New("invalid credentials")
+	ErrLDAPGroupNotAllowed    = errors.New("directory account is not a member of an allowed group")
+	ErrLDAPManagedPassword    = errors.New("password is managed by the directory")
+)
+
+type ldapRuntimeConfig struct {
+	Enabled           bool
+	URL               string
+	StartTLS          bool
+	SkipTLSVerify     bool
+	BindDN            string
+	BindPassword      string
+	BaseDN            string
+	UserFilter        string
+	UsernameAttribute string
+	EmailAttribute    string
+	GroupAttribute    string
+	AdminGroups       []string
+	AllowedGroups     []string
+	AutoCreateUser    bool
+	Timeout           time.Duration
+	DialContext       func(context.Context, string, string) (net.Conn, error)
+}
+
+// ldapConn is the subset of *ldap.Conn used for search-then-bind.
+type ldapConn interface {
+	Bind(username string, password string) error
+	Search(request *ldap.SearchRequest) (*ldap.SearchResult, error)
+	Close() error
+}
+
+var openLDAPConnection = func(ctx context.Context, cfg ldapRuntimeConfig) (ldapConn, error) {
+	serverURL, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, errors.New("invalid ldap url")
+	}
+
+	host := serverURL.Hostname()
+	port := serverURL.Port()
+	isTLS := serverURL.Scheme == "ldaps"
+	if port == "" {
+		port = "389"
+		if isTLS {
+			port = "636"
+		}
+	}
+
+	dialCtx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+
+	rawConn, err := cfg.DialContext(dialCtx, "tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to ldap server: %w", err)
+	}
+
+	tlsConfig := &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: cfg.SkipTLSVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+	if isTLS {
+		tlsConn := tls.Client(rawConn, tlsConfig)
+		if err := tlsConn.HandshakeContext(dialCtx); err != nil {
+			_ = rawConn.Close()
+			return nil, fmt.Errorf("ldaps handshake failed: %w", err)
+		}
+		rawConn = tlsConn
+	}
+
+	conn := ldap.NewConn(rawConn, isTLS)
+	conn.SetTimeout(cfg.Timeout)
+	conn.Start()
+
+	if cfg.StartTLS {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("ldap starttls failed: %w", err)
+		}
+	}
+	return conn, nil
+}
+
+// ldapAccount is the directory entry a password was verified against.
+type ldapAccount struct {
+	DN       string
+	Username string
+	Email    string
+	Groups   []string
+}
+
+// LDAPTestResult describes the outcome of an admin connection test.
+type LDAPTestResult struct {
+	DN       string   `json:"dn,omitempty"`
+	Username string   `json:"username,omitempty"`
+	Email    string   `json:"email,omitempty"`
+	Groups   []string `json:"groups"`
+	Allowed  bool     `json:"allowed"`
+	Admin    bool     `json:"admin"`
+}
+
+func loadLDAPRuntimeConfig(db *gorm.DB) (*ldapRuntimeConfig, error) {
+	if db == nil {
+		return nil, errors.New("failed to load ldap settings")
+	}
+
+	defaults := map[string]string{
+		"ldap_enabled":            "false",
+		"ldap_url":                "",
+		"ldap_start_tls":          "false",
+		"ldap_skip_tls_verify":    "false",
+		"ldap_bind_dn":            "",
+		"ldap_bind_password":      "",
+		"ldap_base_dn":            "",
+		"ldap_user_filter":        defaultLDAPUserFilter,
+		"ldap_username_attribute": defaultLDAPUsernameAttribute,
+		"ldap_email_attribute":    defaultLDAPEmailAttribute,
+		"ldap_group_attribute":    defaultLDAPGroupAttribute,
+		"ldap_admin_groups":       "",
+		"ldap_allowed_groups":     "",
+		"ldap_auto_create_user":   "false",
+		"ldap_timeout_seconds":    strconv.Itoa(defaultLDAPTimeoutSeconds),
+	}
+
+	keys := make([]string, 0, len(defaults))
+	values := make(map[string]string, len(defaults))
+	for key, value := range defaults {
+		keys = append(keys, key)
+		values[key] = value
+	}
+
+	var items []model.SystemSetting
+	if err := db.Where("key IN ?", keys).Find(&items).Error; err != nil {
+		return nil, errors.New("failed to load ldap settings")
+	}
+	for _, item := range items {
+		settingValue, err := decryptSystemSettingValueIfNeeded(item.Key, item.Value)
+		if err != nil {
+			return nil, errors.New("failed to decrypt ldap settings")
+		}
+		values[item.Key] = settingValue
+	}
+
+	timeoutSeconds, err := strconv.ParseInt(strings.TrimSpace(values["ldap_timeout_seconds"]), 10, 64)
+	if err != nil || timeoutSeconds < 1 || timeoutSeconds > maxLDAPTimeoutSeconds {
+		timeoutSeconds = defaultLDAPTimeoutSeconds
+	}
+	timeout := time.Duration(timeoutSeconds) * time.Second
+
+	cfg := &ldapRuntimeConfig{
+		Enabled:           values["ldap_enabled"] == "true",
+		URL:               strings.TrimSpace(values["ldap_url"]),
+		StartTLS:          values["ldap_start_tls"] == "true",
+		SkipTLSVerify:     values["ldap_skip_tls_verify"] == "true",
+		BindDN:            strings.TrimSpace(values["ldap_bind_dn"]),
+		BindPassword:      values["ldap_bind_password"],
+		BaseDN:            strings.TrimSpace(values["ldap_base_dn"]),
+		UserFilter:        strings.TrimSpace(values["ldap_user_filter"]),
+		UsernameAttribute: strings.TrimSpace(values["ldap_username_attribute"]),
+		EmailAttribute:    strings.TrimSpace(values["ldap_email_attribute"]),
+		GroupAttribute:    strings.TrimSpace(values["ldap_group_attribute"]),
+		AdminGroups:       parseLDAPGroupList(values["ldap_admin_groups"]),
+		AllowedGroups:     parseLDAPGroupList(values["ldap_allowed_groups"]),
+		AutoCreateUser:    values["ldap_auto_create_user"] == "true",
+		Timeout:           timeout,
+		DialContext:       NewOutboundDialContext(db, timeout),
+	}
+	if cfg.UserFilter == "" {
+		cfg.UserFilter = defaultLDAPUserFilter
+	}
+	if cfg.UsernameAttribute == "" {
+		cfg.UsernameAttribute = defaultLDAPUsernameAttribute
+	}
+	if cfg.EmailAttribute == "" {
+		cfg.EmailAttribute = defaultLDAPEmailAttribute
+	}
+	if cfg.GroupAttribute == "" {
+		cfg.GroupAttribute = defaultLDAPGroupAttribute
+	}
+
+	if err := validateLDAPConnectionSettings(cfg.URL, cfg.StartTLS, cfg.BaseDN, cfg.UserFilter); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func applyLDAPSettings(tx *gorm.DB, input UpdateSettingsInput) error {
+	if input.LDAPEnabled == nil && input.LDAPURL == nil && input.LDAPStartTLS == nil &&
+		input.LDAPSkipTLSVerify == nil && input.LDAPBindDN == nil && input.LDAPBindPassword == nil &&
+		input.LDAPBaseDN == nil && input.LDAPUserFilter == nil && input.LDAPUsernameAttribute == nil &&
+		input.LDAPEmailAttribute == nil && input.LDAPGroupAttribute == nil && input.LDAPAdminGroups == nil &&
+		input.LDAPAllowedGroups == nil && input.LDAPAutoCreateUser == nil && input.LDAPTimeoutSeconds == nil {
+		return nil
+	}
+
+	current := make(map[string]string)
+	for key, def := range map[string]string{
+		"ldap_enabled":     "false",
+		"ldap_url":         "",
+		"ldap_start_tls":   "false",
+		"ldap_base_dn":     "",
+		"ldap_user_filter": defaultLDAPUserFilter,
+	} {
+		value, err := getSystemSettingValue(tx, key, def)
+		if err != nil {
+			return err
+		}
+		current[key] = value
+	}
+
+	enabled := current["ldap_enabled"] == "true"
+	if input.LDAPEnabled != nil {
+		enabled = *input.LDAPEnabled
+	}
+	serverURL := strings.TrimSpace(current["ldap_url"])
+	if input.LDAPURL != nil {
+		serverURL = strings.TrimSpace(*input.LDAPURL)
+	}
+	startTLS := current["ldap_start_tls"] == "true"
+	if input.LDAPStartTLS != nil {
+		startTLS = *input.LDAPStartTLS
+	}
+	baseDN := strings.TrimSpace(current["ldap_base_dn"])
+	if input.LDAPBaseDN != nil {
+		baseDN = strings.TrimSpace(*input.LDAPBaseDN)
+	}
+	userFilter := strings.TrimSpace(current["ldap_user_filter"])
+	if input.LDAPUserFilter != nil {
+		userFilter = strings.TrimSpace(*input.LDAPUserFilter)
+	}
+	if userFilter == "" {
+		userFilter = defaultLDAPUserFilter
+	}
+
+	if enabled || serverURL != "" {
+		if err := validateLDAPConnectionSettings(serverURL, startTLS, baseDN, userFilter); err != nil {
+			return err
+		}
+	}
+	if input.LDAPTimeoutSeconds != nil && (*input.LDAPTimeoutSeconds < 1 || *input.LDAPTimeoutSeconds > maxLDAPTimeoutSeconds) {
+		return fmt.Errorf("%w: timeout must be between 1 and %d seconds", ErrInvalidLDAPSettings, maxLDAPTimeoutSeconds)
+	}
+	if input.LDAPBindDN != nil && strings.TrimSpace(*input.LDAPBindDN) != "" {
+		if _, err := ldap.ParseDN(strings.TrimSpace(*input.LDAPBindDN)); err != nil {
+			return fmt.Errorf("%w: bind dn is not a valid distinguished name", ErrInvalidLDAPSettings)
+		}
+	}
+	for _, list := range []*string{input.LDAPAdminGroups, input.LDAPAllowedGroups} {
+		if list == nil {
+			continue
+		}
+		for _, group := range parseLDAPGroupList(*list) {
+			if strings.Contains(group, "=") {
+				if _, err := ldap.ParseDN(group); err != nil {
+					return fmt.Errorf("%w: %q is not a valid group dn", ErrInvalidLDAPSettings, group)
+				}
+			}
+		}
+	}
+
+	if input.LDAPEnabled != nil {
+		if err := saveBoolSystemSetting(tx, "ldap_enabled", *input.LDAPEnabled); err != nil {
+			return err
+		}
+	}
+	if input.LDAPStartTLS != nil {
+		if err := saveBoolSystemSetting(tx, "ldap_start_tls", *input.LDAPStartTLS); err != nil {
+			return err
+		}
+	}
+	if input.LDAPSkipTLSVerify != nil {
+		if err := saveBoolSystemSetting(tx, "ldap_skip_tls_verify", *input.LDAPSkipTLSVerify); err != nil {
+			return err
+		}
+	}
+	if input.LDAPAutoCreateUser != nil {
+		if err := saveBoolSystemSetting(tx, "ldap_auto_create_user", *input.LDAPAutoCreateUser); err != nil {
+			return err
+		}
+	}
+	if input.LDAPTimeoutSeconds != nil {
+		if err := saveStringSystemSetting(tx, "ldap_timeout_seconds", strconv.FormatInt(*input.LDAPTimeoutSeconds, 10)); err != nil {
+			return err
+		}
+	}
+	if input.LDAPUserFilter != nil {
+		if err := saveStringSystemSetting(tx, "ldap_user_filter", userFilter); err != nil {
+			return err
+		}
+	}
+	if input.LDAPBindPassword != nil {
+		if err := saveEncryptedSystemSetting(tx, "ldap_bind_password", *input.LDAPBindPassword); err != nil {
+			return err
+		}
+	}
+
+	for key, value := range map[string]*string{
+		"ldap_url":                input.LDAPURL,
+		"ldap_bind_dn":            input.LDAPBindDN,
+		"ldap_base_dn":            input.LDAPBaseDN,
+		"ldap_username_attribute": input.LDAPUsernameAttribute,
+		"ldap_email_attribute":    input.LDAPEmailAttribute,
+		"ldap_group_attribute":    input.LDAPGroupAttribute,
+		"ldap_admin_groups":       input.LDAPAdminGroups,
+		"ldap_allowed_groups":     input.LDAPAllowedGroups,
+	} {
+		if value == nil {
+			continue
+		}
+		if err := saveStringSystemSetting(tx, key, strings.TrimSpace(*value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateLDAPConnectionSettings(serverURL string, startTLS bool, baseDN string, userFilter string) error {
+	parsed, err := url.Parse(serverURL)
+	if serverURL == "" || err != nil || parsed.Hostname() == "" {
+		return fmt.Errorf("%w: url must be ldap://host[:port] or ldaps://host[:port]", ErrInvalidLDAPSettings)
+	}
+	switch parsed.Scheme {
+	case "ldap":
+	case "ldaps":
+		if startTLS {
+			return fmt.Errorf("%w: starttls cannot be combined with ldaps", ErrInvalidLDAPSettings)
+		}
+	default:
+		return fmt.Errorf("%w: url must be ldap://host[:port] or ldaps://host[:port]", ErrInvalidLDAPSettings)
+	}
+
+	if baseDN == "" {
+		return fmt.Errorf("%w: base dn is required", ErrInvalidLDAPSettings)
+	}
+	if _, err := ldap.ParseDN(baseDN); err != nil {
+		return fmt.Errorf("%w: base dn is not a valid distinguished name", ErrInvalidLDAPSettings)
+	}
+
+	if !strings.Contains(userFilter, ldapUsernamePlaceholder) {
+		return fmt.Errorf("%w: user filter must contain %s", ErrInvalidLDAPSettings, ldapUsernamePlaceholder)
+	}
+	if _, err := ldap.CompileFilter(buildLDAPUserFilter(userFilter, "probe")); err != nil {
+		return fmt.Errorf("%w: user filter is not a valid ldap filter", ErrInvalidLDAPSettings)
+	}
+	return nil
+}
+
+// parseLDAPGroupList splits a group list on newlines and semicolons; commas
+// are part of DNs so they cannot separate entries.
+func parseLDAPGroupList(value string) []string {
+	fields := strings.FieldsFunc(value, func(r rune) bool {
+		return r == '\n' || r == '\r' || r == ';'
+	})
+	groups := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if group := strings.TrimSpace(field); group != "" {
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
+
+func buildLDAPUserFilter(filter string, username string) string {
+	return strings.ReplaceAll(filter, ldapUsernamePlaceholder, ldap.EscapeFilter(username))
+}
+
+// ldapGroupsMatch reports whether any of the member's groups is listed. A
+// listed DN is compared as a DN; a bare name matches the group's first RDN
+// value, so "admins" matches "cn=admins,cn=groups,dc=example,dc=com".
+func ldapGroupsMatch(memberOf []string, listed []string) bool {
+	for _, group := range memberOf {
+		groupDN, err := ldap.ParseDN(group)
+		if err != nil || len(groupDN.RDNs) == 0 {
+			groupDN = nil
+		}
+		for _, want := range listed {
+			if strings.Contains(want, "=") {
+				wantDN, err := ldap.ParseDN(want)
+				if err == nil && groupDN != nil && groupDN.EqualFold(wantDN) {
+					return true
+				}
+				if strings.EqualFold(strings.TrimSpace(group), want) {
+					return true
+				}
+				continue
+			}
+			if groupDN != nil && len(groupDN.RDNs[0].Attributes) > 0 &&
+				strings.EqualFold(groupDN.RDNs[0].Attributes[0].Value, want) {
+				return true
+			}
+			if groupDN == nil && strings.EqualFold(strings.TrimSpace(group), want) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// authenticateLDAP performs search-then-bind: it binds with the service
+// account (or anonymously), looks up exactly one entry for the username and
+// then binds as that entry with the supplied password.
+func authenticateLDAP(ctx context.Context, cfg ldapRuntimeConfig, username string, password string) (*ldapAccount, error) {
+	username = strings.TrimSpace(username)
+	// An empty password would be an unauthenticated bind, which most servers
+	// accept without checking anything.
+	if username == "" || password == "" {
+		return nil, ErrLDAPInvalidCredentials
+	}
+
+	conn, err := openLDAPConnection(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	entry, err := searchLDAPUser(conn, cfg, username)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, ErrLDAPInvalidCredentials
+		}
+		return nil, fmt.Errorf("ldap bind failed: %w", err)
+	}
+
+	return ldapAccountFromEntry(cfg, entry, username), nil
+}
+
+func searchLDAPUser(conn ldapConn, cfg ldapRuntimeConfig, username string) (*ldap.Entry, error) {
+	if cfg.BindDN != "" {
+		if err := conn.Bind(cfg.BindDN, cfg.BindPassword); err != nil {
+			return nil, fmt.Errorf("ldap service bind failed: %w", err)
+		}
+	}
+
+	request := ldap.NewSearchRequest(
+		cfg.BaseDN,
+		ldap.ScopeWholeSubtree,
+		ldap.NeverDerefAliases,
+		2,
+		int(cfg.Timeout/time.Second),
+		false,
+		buildLDAPUserFilter(cfg.UserFilter, username),
+		[]string{cfg.UsernameAttribute, cfg.EmailAttribute, cfg.GroupAttribute},
+		nil,
+	)
+	result, err := conn.Search(request)
+	if err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+			return nil, ErrLDAPInvalidCredentials
+		}
+		return nil, fmt.Errorf("ldap search failed: %w", err)
+	}
+	// Zero matches and ambiguous matches both fail the same way so the
+	// response does not reveal which usernames exist in the directory.
+	if len(result.Entries) != 1 {
+		return nil, ErrLDAPInvalidCredentials
+	}
+	return result.Entries[0], nil
+}
+
+func ldapAccountFromEntry(cfg ldapRuntimeConfig, entry *ldap.Entry, fallbackUsername string) *ldapAccount {
+	username := strings.TrimSpace(entry.GetEqualFoldAttributeValue(cfg.UsernameAttribute))
+	if username == "" {
+		username = fallbackUsername
+	}
+	return &ldapAccount{
+		DN:       entry.DN,
+		Username: strings.ToLower(username),
+		Email:    strings.TrimSpace(entry.GetEqualFoldAttributeValue(cfg.EmailAttribute)),
+		Groups:   entry.GetEqualFoldAttributeValues(cfg.GroupAttribute),
+	}
+}
+
+func (cfg ldapRuntimeConfig) isAllowed(account *ldapAccount) bool {
+	return len(cfg.AllowedGroups) == 0 || ldapGroupsMatch(account.Groups, cfg.AllowedGroups)
+}
+
+// roleFor returns the role the directory grants, or "" when no admin groups
+// are configured and roles are managed locally.
+func (cfg ldapRuntimeConfig) roleFor(account *ldapAccount) string {
+	if len(cfg.AdminGroups) == 0 {
+		return ""
+	}
+	if ldapGroupsMatch(account.Groups, cfg.AdminGroups) {
+		return "admin"
+	}
+	return "user"
+}
+
+func ldapRequestContext(db *gorm.DB) context.Context {
+	if db != nil && db.Statement != nil && db.Statement.Context != nil {
+		return db.Statement.Context
+	}
+	return context.Background()
+}
+
+// verifyAccountPassword checks a password for an existing user. Users linked
+// to a directory entry bind against LDAP while it is enabled; everyone else,
+// and linked users while LDAP is switched off, use the local password hash.
+func verifyAccountPassword(db *gorm.DB, user *model.User, password string) (*ldapAccount, error) {
+	if cfg, err := loadLDAPRuntimeConfig(db); err == nil && cfg.Enabled {
+		var identity model.LDAPIdentity
+		err := db.Where("user_id = ?", user.ID).First(&identity).Error
+		if err == nil {
+			return authenticateLDAP(ldapRequestContext(db), *cfg, identity.Username, password)
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) != nil {
+		return nil, ErrLDAPInvalidCredentials
+	}
+	return nil, nil
+}
+
+// isDirectoryManagedUser reports whether the user's password lives in LDAP.
+func isDirectoryManagedUser(db *gorm.DB, userID uint) (bool, error) {
+	if cfg, err := loadLDAPRuntimeConfig(db); err != nil || !cfg.Enabled {
+		return false, nil
+	}
+	var count int64
+	if err := db.Model(&model.LDAPIdentity{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// loginLDAPUser authenticates an identifier that matched no local account
+// against the directory and returns the linked or newly provisioned user.
+func (s *AuthService) loginLDAPUser(identifier string, password string) (*model.User, error) {
+	cfg, err := loadLDAPRuntimeConfig(s.DB)
+	if err != nil || !cfg.Enabled {
+		return nil, ErrLDAPInvalidCredentials
+	}
+
+	account, err := authenticateLDAP(ldapRequestContext(s.DB), *cfg, identifier, password)
+	if err != nil {
+		return nil, err
+	}
+	if !cfg.isAllowed(account) {
+		return nil, ErrLDAPGroupNotAllowed
+	}
+
+	var identity model.LDAPIdentity
+	err = s.DB.Where("username = ?", account.Username).First(&identity).Error
+	if err == nil {
+		var user model.User
+		if err := s.DB.First(&user, identity.UserID).Error; err != nil {
+			return nil, ErrLDAPInvalidCredentials
+		}
+		if err := s.syncLDAPUser(&user, *cfg, account); err != nil {
+			return nil, err
+		}
+		return &user, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if !cfg.AutoCreateUser {
+		return nil, errors.New("no account is linked to this directory user")
+	}
+	role := cfg.roleFor(account)
+	if role == "" {
+		role = "user"
+	}
+	return s.createLDAPUser(account, role)
+}
+
+// syncLDAPUser keeps a linked user's group gate, role and stored DN in step
+// with the directory on each sign-in. The first user is never demoted.
+func (s *AuthService) syncLDAPUser(user *model.User, cfg ldapRuntimeConfig, account *ldapAccount) error {
+	if !cfg.isAllowed(account) {
+		return ErrLDAPGroupNotAllowed
+	}
+
+	if role := cfg.roleFor(account); role != "" && role != user.Role && !(user.ID == 1 && role != "admin") {
+		if err := s.DB.Model(&model.User{}).Where("id = ?", user.ID).Update("role", role).Error; err != nil {
+			return err
+		}
+		user.Role = role
+	}
+
+	return s.DB.Model(&model.LDAPIdentity{}).
+		Where("user_id = ? AND dn <> ?", user.ID, account.DN).
+		Update("dn", account.DN).Error
+}
+
+func (s *AuthService) createLDAPUser(account *ldapAccount, role string) (*model.User, error) {
+	email := strings.TrimSpace(account.Email)
+	if email == "" {
+		return nil, errors.New("directory entry has no email address")
+	}
+	if err := s.enforceEmailDomainWhitelist(normalizeEmail(email)); err != nil {
+		return nil, err
+	}
+
+	username, err := s.allocateOIDCUsername(account.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	randomPassword, err := generateSecureToken(24)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(randomPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	user := model.User{
+		Username: username,
+		Email:    email,
+		Password: string(hash),
+		Role:     role,
+		Status:   "active",
+	}
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		var existingByEmail model.User
+		if err := tx.Where("LOWER(email) = ?", strings.ToLower(email)).First(&existingByEmail).Error; err == nil {
+			return errors.New("email already registered to a local account")
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		if err := SeedUserDefaults(tx, user.ID); err != nil {
+			return err
+		}
+		return tx.Create(&model.LDAPIdentity{
+			UserID:   user.ID,
+			Username: account.Username,
+			DN:       account.DN,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// TestLDAPConnection binds with the configured service account and, when a
+// username is given, runs the full search-then-bind for it. It works while
+// LDAP sign-in is still disabled so settings can be checked first.
+func (s *AdminService) TestLDAPConnection(username string, password string) (*LDAPTestResult, error) {
+	cfg, err := loadLDAPRuntimeConfig(s.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.TrimSpace(username) == "" {
+		conn, err := openLDAPConnection(ldapRequestContext(s.DB), *cfg)
+		if err != nil {
+			return nil, err
+		}
+		defer conn.Close()
+		if cfg.BindDN != "" {
+			if err := conn.Bind(cfg.BindDN, cfg.BindPassword); err != nil {
+				return nil, fmt.Errorf("ldap service bind failed: %w", err)
+			}
+		}
+		return &LDAPTestResult{Groups: []string{}}, nil
+	}
+
+	account, err := authenticateLDAP(ldapRequestContext(s.DB), *cfg, username, password)
+	if err != nil {
+		if errors.Is(err, ErrLDAPInvalidCredentials) {
+			return nil, errors.New("user not found or password rejected by the directory")
+		}
+		return nil, err
+	}
+
+	groups := account.Groups
+	if groups == nil {
+		groups = []string{}
+	}
+	return &LDAPTestResult{
+		DN:       account.DN,
+		Username: account.Username,
+		Email:    account.Email,
+		Groups:   groups,
+		Allowed:  cfg.isAllowed(account),
+		Admin:    cfg.roleFor(account) == "admin",
+	}, nil
+}
+
+// ldapLoginError collapses directory failures into the login responses the
+// password flow already returns, keeping policy errors that the user can act on.
+func ldapLoginError(err error) error {
+	switch {
+	case errors.Is(err, ErrLDAPGroupNotAllowed):
+		return err
+	case errors.Is(err, ErrEmailDomainNotAllowed):
+		return err
+	default:
+		return errors.New("invalid credentials")
+	}
+}
@@ -0,0 +1,276 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/shiroha/subdux/internal/model"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+type fakeLDAPEntry struct {
+	password string
+	entry    *ldap.Entry
+}
+
+type fakeLDAPDirectory struct {
+	serviceDN       string
+	servicePassword string
+	users           map[string]fakeLDAPEntry
+	filters         []string
+}
+
+func (d *fakeLDAPDirectory) Bind(username string, password string) error {
+	if username == d.serviceDN && password == d.servicePassword {
+		return nil
+	}
+	for _, user := range d.users {
+		if user.entry.DN == username && user.password == password {
+			return nil
+		}
+	}
+	return ldap.NewError(ldap.LDAPResultInvalidCredentials, errors.New("invalid credentials"))
+}
+
+func (d *fakeLDAPDirectory) Search(request *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	d.filters = append(d.filters, request.Filter)
+	result := &ldap.SearchResult{}
+	for uid, user := range d.users {
+		if request.Filter == "(&(objectClass=person)(uid="+ldap.EscapeFilter(uid)+"))" {
+			result.Entries = append(result.Entries, user.entry)
+		}
+	}
+	return result, nil
+}
+
+func (d *fakeLDAPDirectory) Close() error { return nil }
+
+func newLDAPTestDB(t *testing.T) (*gorm.DB, *fakeLDAPDirectory) {
+	t.Helper()
+	t.Setenv("JWT_SECRET", "ldap-test-secret-0123456789abcdef0123456789abcdef")
+	db := newTestDB(t)
+	if err := db.AutoMigrate(&model.LDAPIdentity{}); err != nil {
+		t.Fatalf("failed to migrate ldap identities: %v", err)
+	}
+
+	for key, value := range map[string]string{
+		"ldap_enabled":          "true",
+		"ldap_url":              "ldap://directory.example.com",
+		"ldap_bind_dn":          "cn=svc,dc=example,dc=com",
+		"ldap_bind_password":    "svc-pass",
+		"ldap_base_dn":          "dc=example,dc=com",
+		"ldap_user_filter":      "(&(objectClass=person)(uid={username}))",
+		"ldap_admin_groups":     "cn=admins,cn=groups,dc=example,dc=com",
+		"ldap_allowed_groups":   "staff;admins",
+		"ldap_auto_create_user": "true",
+	} {
+		if err := saveStringSystemSetting(db, key, value); err != nil {
+			t.Fatalf("failed to save %s: %v", key, err)
+		}
+	}
+
+	directory := &fakeLDAPDirectory{
+		serviceDN:       "cn=svc,dc=example,dc=com",
+		servicePassword: "svc-pass",
+		users: map[string]fakeLDAPEntry{
+			"alice": {password: "alice-pass", entry: ldap.NewEntry("uid=alice,ou=people,dc=example,dc=com", map[string][]string{
+				"uid":      {"Alice"},
+				"mail":     {"alice@example.com"},
+				"memberOf": {"CN=Admins,CN=Groups,DC=example,DC=com", "cn=staff,cn=groups,dc=example,dc=com"},
+			})},
+			"bob": {password: "bob-pass", entry: ldap.NewEntry("uid=bob,ou=people,dc=example,dc=com", map[string][]string{
+				"uid":      {"bob"},
+				"mail":     {"bob@example.com"},
+				"memberOf": {"cn=staff,cn=groups,dc=example,dc=com"},
+			})},
+			"eve": {password: "eve-pass", entry: ldap.NewEntry("uid=eve,ou=people,dc=example,dc=com", map[string][]string{
+				"uid":  {"eve"},
+				"mail": {"eve@example.com"},
+			})},
+		},
+	}
+	original := openLDAPConnection
+	openLDAPConnection = func(context.Context, ldapRuntimeConfig) (ldapConn, error) {
+		return directory, nil
+	}
+	t.Cleanup(func() { openLDAPConnection = original })
+
+	return db, directory
+}
+
+func TestLDAPLoginProvisionsUserWithGroupRole(t *testing.T) {
+	db, directory := newLDAPTestDB(t)
+	auth := NewAuthService(db)
+	// The first user is never demoted, so keep alice off that id.
+	if err := db.Create(&model.User{Username: "owner", Email: "owner@local.test", Password: "x", Role: "admin", Status: "active"}).Error; err != nil {
+		t.Fatalf("failed to create owner: %v", err)
+	}
+
+	resp, err := auth.Login(LoginInput{Identifier: "alice", Password: "alice-pass"})
+	if err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+	if resp.User == nil || resp.User.Role != "admin" || resp.User.Email != "alice@example.com" {
+		t.Fatalf("Login() user = %+v, want provisioned admin alice", resp.User)
+	}
+
+	var identity model.LDAPIdentity
+	if err := db.Where("user_id = ?", resp.User.ID).First(&identity).Error; err != nil {
+		t.Fatalf("expected ldap identity: %v", err)
+	}
+	if identity.Username != "alice" || identity.DN != "uid=alice,ou=people,dc=example,dc=com" {
+		t.Fatalf("identity = %+v", identity)
+	}
+
+	// Subsequent logins go through the linked identity and keep the role in
+	// step with directory groups.
+	directory.users["alice"].entry.Attributes = []*ldap.EntryAttribute{
+		ldap.NewEntryAttribute("uid", []string{"alice"}),
+		ldap.NewEntryAttribute("mail", []string{"alice@example.com"}),
+		ldap.NewEntryAttribute("memberOf", []string{"cn=staff,cn=groups,dc=example,dc=com"}),
+	}
+	resp, err = auth.Login(LoginInput{Identifier: resp.User.Username, Password: "alice-pass"})
+	if err != nil {
+		t.Fatalf("second Login() error = %v", err)
+	}
+	if resp.User.Role != "user" {
+		t.Fatalf("role after group removal = %q, want user", resp.User.Role)
+	}
+
+	if _, err := auth.Login(LoginInput{Identifier: "alice", Password: "wrong"}); err == nil || err.Error() != "invalid credentials" {
+		t.Fatalf("Login() with wrong password error = %v, want invalid credentials", err)
+	}
+}
+
+func TestLDAPLoginRejectsUsersOutsideAllowedGroups(t *testing.T) {
+	db, _ := newLDAPTestDB(t)
+	auth := NewAuthService(db)
+
+	if _, err := auth.Login(LoginInput{Identifier: "eve", Password: "eve-pass"}); !errors.Is(err, ErrLDAPGroupNotAllowed) {
+		t.Fatalf("Login() error = %v, want ErrLDAPGroupNotAllowed", err)
+	}
+	var count int64
+	db.Model(&model.User{}).Where("email = ?", "eve@example.com").Count(&count)
+	if count != 0 {
+		t.Fatalf("user outside allowed groups was provisioned")
+	}
+}
+
+func TestLDAPLoginEscapesFilterInput(t *testing.T) {
+	db, directory := newLDAPTestDB(t)
+	auth := NewAuthService(db)
+
+	if _, err := auth.Login(LoginInput{Identifier: "*)(uid=*", Password: "x"}); err == nil {
+		t.Fatal("Login() with filter metacharacters succeeded")
+	}
+	if len(directory.filters) != 1 || !strings.Contains(directory.filters[0], `\2a\29\28uid=\2a`) {
+		t.Fatalf("search filters = %v, want escaped username", directory.filters)
+	}
+}
+
+func TestLDAPLocalAccountsTakePrecedence(t *testing.T) {
+	db, _ := newLDAPTestDB(t)
+	auth := NewAuthService(db)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("local-pass"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("failed to hash password: %v", err)
+	}
+	local := model.User{Username: "bob", Email: "bob@local.test", Password: string(hash), Role: "user", Status: "active"}
+	if err := db.Create(&local).Error; err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+
+	if _, err := auth.Login(LoginInput{Identifier: "bob", Password: "bob-pass"}); err == nil {
+		t.Fatal("directory password signed in to an unlinked local account")
+	}
+	if _, err := auth.Login(LoginInput{Identifier: "bob", Password: "local-pass"}); err != nil {
+		t.Fatalf("local password login error = %v", err)
+	}
+}
+
+func TestLDAPManagedUsersCannotChangePassword(t *testing.T) {
+	db, _ := newLDAPTestDB(t)
+	auth := NewAuthService(db)
+
+	resp, err := auth.Login(LoginInput{Identifier: "bob", Password: "bob-pass"})
+	if err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+	err = auth.ChangePassword(resp.User.ID, ChangePasswordInput{CurrentPassword: "bob-pass", NewPassword: "new-password"})
+	if !errors.Is(err, ErrLDAPManagedPassword) {
+		t.Fatalf("ChangePassword() error = %v, want ErrLDAPManagedPassword", err)
+	}
+
+	user, _ := auth.GetUser(resp.User.ID)
+	if _, err := verifyAccountPassword(db, user, "bob-pass"); err != nil {
+		t.Fatalf("verifyAccountPassword() error = %v", err)
+	}
+	if _, err := verifyAccountPassword(db, user, ""); err == nil {
+		t.Fatal("verifyAccountPassword() accepted an empty password")
+	}
+}
+
+func TestLDAPGroupsMatch(t *testing.T) {
+	groups := []string{"CN=Domain Admins,CN=Users,DC=corp,DC=example"}
+	cases := []struct {
+		listed []string
+		want   bool
+	}{
+		{[]string{"cn=domain admins,cn=users,dc=corp,dc=example"}, true},
+		{[]string{"domain admins"}, true},
+		{[]string{"users"}, false},
+		{[]string{"cn=domain admins,dc=corp,dc=example"}, false},
+	}
+	for _, tc := range cases {
+		if got := ldapGroupsMatch(groups, tc.listed); got != tc.want {
+			t.Fatalf("ldapGroupsMatch(%v) = %v, want %v", tc.listed, got, tc.want)
+		}
+	}
+}
+
+func TestApplyLDAPSettingsValidation(t *testing.T) {
+	db := newTestDB(t)
+	enabled := true
+	startTLS := true
+	timeout := int64(0)
+
+	cases := []struct {
+		name  string
+		input UpdateSettingsInput
+	}{
+		{"enabled without url", UpdateSettingsInput{LDAPEnabled: &enabled}},
+		{"bad scheme", UpdateSettingsInput{LDAPURL: stringPtr("http://dir"), LDAPBaseDN: stringPtr("dc=example")}},
+		{"starttls with ldaps", UpdateSettingsInput{LDAPURL: stringPtr("ldaps://dir"), LDAPBaseDN: stringPtr("dc=example"), LDAPStartTLS: &startTLS}},
+		{"filter without placeholder", UpdateSettingsInput{LDAPURL: stringPtr("ldap://dir"), LDAPBaseDN: stringPtr("dc=example"), LDAPUserFilter: stringPtr("(uid=alice)")}},
+		{"invalid filter", UpdateSettingsInput{LDAPURL: stringPtr("ldap://dir"), LDAPBaseDN: stringPtr("dc=example"), LDAPUserFilter: stringPtr("(uid={username}")}},
+		{"timeout out of range", UpdateSettingsInput{LDAPTimeoutSeconds: &timeout}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := applyLDAPSettings(db, tc.input); !errors.Is(err, ErrInvalidLDAPSettings) {
+				t.Fatalf("applyLDAPSettings() error = %v, want ErrInvalidLDAPSettings", err)
+			}
+		})
+	}
+
+	if err := applyLDAPSettings(db, UpdateSettingsInput{
+		LDAPEnabled:      &enabled,
+		LDAPURL:          stringPtr("ldaps://dir.example.com:636"),
+		LDAPBaseDN:       stringPtr("dc=example,dc=com"),
+		LDAPBindPassword: stringPtr("secret"),
+	}); err != nil {
+		t.Fatalf("applyLDAPSettings() error = %v", err)
+	}
+	cfg, err := loadLDAPRuntimeConfig(db)
+	if err != nil {
+		t.Fatalf("loadLDAPRuntimeConfig() error = %v", err)
+	}
+	if !cfg.Enabled || cfg.BindPassword != "secret" || cfg.UserFilter != defaultLDAPUserFilter {
+		t.Fatalf("config = %+v", cfg)
+	}
+}
@@ -10,6 +10,7 @@ type NotificationService struct {
 	DB               *gorm.DB
 	templateService  *NotificationTemplateService
 	templateRenderer *TemplateRenderer
+	converter        CurrencyConverter
 	ownerID          string
 }
 
@@ -42,6 +43,12 @@ func (s *NotificationService) WithContext(ctx context.Context) *NotificationServ
 	return &clone
 }
 
+// SetCurrencyConverter sets the converter used to total digest charges in the
+// user's preferred currency. Without one, amounts are summed as-is.
+func (s *NotificationService) SetCurrencyConverter(converter CurrencyConverter) {
+	s.converter = converter
+}
+
 func (s *NotificationService) notificationOwnerID() string {
 	if s.ownerID == "" {
 		s.ownerID = newNotificationOwnerID()
@@ -67,7 +74,9 @@ type UpdateChannelInput struct {
 }
 
 type UpdatePolicyInput struct {
-	DaysBefore             *int  `json:"days_before"`
-	NotifyOnDueDay         *bool `json:"notify_on_due_day"`
-	NotifyManualRenewDaily *bool `json:"notify_manual_renew_daily"`
+	DaysBefore             *int    `json:"days_before"`
+	NotifyOnDueDay         *bool   `json:"notify_on_due_day"`
+	NotifyManualRenewDaily *bool   `json:"notify_manual_renew_daily"`
+	DigestFrequency        *string `json:"digest_frequency"`
+	DigestOnly             *bool   `json:"digest_only"`
 }
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/shiroha/subdux/internal/model"
+	"github.com/shiroha/subdux/internal/pkg"
+	"github.com/shiroha/subdux/internal/pkg/logging"
+	"gorm.io/gorm/clause"
+)
+
+const notificationTriggerCardExpiring = "payment_method_expiring"
+
+// cardExpiryReminderDays are the lead times, in days before a card's last
+// valid day, at which a reminder goes out. Each fires at most once per card
+// and expiry, so a scan that first sees a card 5 days out sends only the
+// 7-day reminder.
+var cardExpiryReminderDays = []int{7, 30}
+
+func paymentMethodExpiryDedupeKey(userID, methodID uint, expiresOn time.Time, leadDays int, channelID uint) string {
+	return fmt.Sprintf(
+		"%s:%d:%s:%d:%s:%d:%d",
+		notificationOutboxVersion,
+		userID,
+		notificationTriggerCardExpiring,
+		methodID,
+		expiresOn.Format("2006-01"),
+		leadDays,
+		channelID,
+	)
+}
+
+// enqueuePaymentMethodExpiryReminders queues a reminder on every enabled
+// channel for each card nearing expiry that active subscriptions are still
+// billed to. The message lists those subscriptions so the user knows where
+// to update the card.
+func (s *NotificationService) enqueuePaymentMethodExpiryReminders(
+	userID uint,
+	user *model.User,
+	channels []model.NotificationChannel,
+	now time.Time,
+) error {
+	var methods []model.PaymentMethod
+	if err := s.DB.Where("user_id = ? AND expiry_month IS NOT NULL AND expiry_year IS NOT NULL", userID).
+		Order("sort_order ASC, id ASC").
+		Find(&methods).Error; err != nil {
+		return err
+	}
+	if len(methods) == 0 {
+		return nil
+	}
+
+	loc := now.Location()
+	locale := loadNotificationLocale(s.DB, userID)
+	for _, method := range methods {
+		expiresOn, ok := PaymentMethodExpiryDate(method)
+		if !ok {
+			continue
+		}
+		daysUntil := pkg.DaysUntilFrom(now, pkg.CalendarDateInTimezone(expiresOn, loc), loc)
+		leadDays, ok := cardExpiryReminderLeadDays(daysUntil)
+		if !ok {
+			continue
+		}
+
+		var subs []model.Subscription
+		if err := s.DB.Where("user_id = ? AND payment_method_id = ? AND status = ?", userID, method.ID, subscriptionStatusActive).
+			Order("next_billing_date IS NULL ASC").
+			Order("next_billing_date ASC").
+			Order("id ASC").
+			Find(&subs).Error; err != nil {
+			return err
+		}
+		if len(subs) == 0 {
+			continue
+		}
+
+		message, err := s.templateRenderer.RenderPaymentMethodExpiryTemplate(
+			builtinNotificationTemplate(locale, notificationTemplateCategoryPaymentMethodExpiring),
+			paymentMethodExpiryTemplateData(locale, method, expiresOn, daysUntil, subs),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to render payment method expiry reminder: %w", err)
+		}
+		for _, channel := range channels {
+			if err := s.enqueuePaymentMethodExpiryOutbox(userID, method.ID, expiresOn, leadDays, channel, message, user.Email); err != nil {
+				logging.Warn("failed to queue payment method expiry reminder",
+					slog.Uint64("user_id", uint64(userID)),
+					slog.String("channel", channel.Type),
+					slog.Any("error", err))
+			}
+		}
+	}
+	return nil
+}
+
+// cardExpiryReminderLeadDays returns the shortest reminder lead time that
+// daysUntil falls within, or false once the card has expired or is not yet
+// due a reminder.
+func cardExpiryReminderLeadDays(daysUntil int) (int, bool) {
+	if daysUntil < 0 {
+		return 0, false
+	}
+	for _, leadDays := range cardExpiryReminderDays {
+		if daysUntil <= leadDays {
+			return leadDays, true
+		}
+	}
+	return 0, false
+}
+
+func paymentMethodExpiryTemplateData(
+	locale string,
+	method model.PaymentMethod,
+	expiresOn time.Time,
+	daysUntil int,
+	subs []model.Subscription,
+) PaymentMethodExpiryTemplateData {
+	data := PaymentMethodExpiryTemplateData{
+		PaymentMethod: method.Name,
+		Issuer:        method.Issuer,
+		LastFour:      method.LastFour,
+		ExpiresOn:     expiresOn.Format("2006-01-02"),
+		DaysUntil:     daysUntil,
+		Subscriptions: make([]PaymentMethodExpiryItem, 0, len(subs)),
+		Locale:        locale,
+	}
+	for _, sub := range subs {
+		item := PaymentMethodExpiryItem{
+			SubscriptionName: sub.Name,
+			Amount:           sub.Amount,
+			Currency:         strings.ToUpper(strings.TrimSpace(sub.Currency)),
+		}
+		if sub.NextBillingDate != nil {
+			item.NextBillingDate = sub.NextBillingDate.Format("2006-01-02")
+		}
+		data.Subscriptions = append(data.Subscriptions, item)
+	}
+	return data
+}
+
+func (s *NotificationService) enqueuePaymentMethodExpiryOutbox(
+	userID, methodID uint,
+	expiresOn time.Time,
+	leadDays int,
+	channel model.NotificationChannel,
+	message string,
+	targetEmail string,
+) error {
+	channelID := channel.ID
+	now := pkg.NowUTC()
+	expiresAt := now.Add(notificationOutboxExpiryWindow)
+	outbox := model.NotificationOutbox{
+		DedupeKey:     paymentMethodExpiryDedupeKey(userID, methodID, expiresOn, leadDays, channel.ID),
+		UserID:        userID,
+		ChannelID:     &channelID,
+		ChannelType:   channel.Type,
+		TriggerType:   notificationTriggerCardExpiring,
+		NotifyDate:    expiresOn,
+		ScheduledFor:  now,
+		ExpiresAt:     &expiresAt,
+		Status:        notificationOutboxStatusPending,
+		MaxAttempts:   loadNotificationRetryPolicy(s.DB).maxAttempts,
+		NextAttemptAt: now,
+		Message:       message,
+		TargetEmail:   targetEmail,
+	}
+	return s.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&outbox).Error
+}
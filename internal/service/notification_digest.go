@@ -0,0 +1,429 @@
+package service
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shiroha/subdux/internal/model"
+	"github.com/shiroha/subdux/internal/pkg"
+	"github.com/shiroha/subdux/internal/pkg/logging"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	notificationDigestOff     = "off"
+	notificationDigestDaily   = "daily"
+	notificationDigestWeekly  = "weekly"
+	notificationDigestMonthly = "monthly"
+
+	notificationTriggerDigest = "digest"
+)
+
+// notificationDigestPeriod is the calendar period a digest covers: a day, an
+// ISO week starting on Monday, or a calendar month. Key identifies the period
+// in the outbox dedupe key so each period produces at most one digest per
+// channel no matter how often the scan runs.
+type notificationDigestPeriod struct {
+	frequency string
+	start     time.Time
+	end       time.Time
+	key       string
+}
+
+func normalizeNotificationDigestFrequency(value string) string {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case notificationDigestDaily:
+		return notificationDigestDaily
+	case notificationDigestWeekly:
+		return notificationDigestWeekly
+	case notificationDigestMonthly:
+		return notificationDigestMonthly
+	default:
+		return notificationDigestOff
+	}
+}
+
+func isValidNotificationDigestFrequency(value string) bool {
+	switch value {
+	case notificationDigestOff, notificationDigestDaily, notificationDigestWeekly, notificationDigestMonthly:
+		return true
+	default:
+		return false
+	}
+}
+
+// notificationDigestReplacesReminders reports whether the policy asks for the
+// digest instead of per-subscription reminders. Event notifications such as a
+// manual-renew subscription ending are still delivered individually.
+func notificationDigestReplacesReminders(policy *model.NotificationPolicy) bool {
+	return policy != nil &&
+		policy.DigestOnly &&
+		normalizeNotificationDigestFrequency(policy.DigestFrequency) != notificationDigestOff
+}
+
+func notificationDigestPeriodFor(frequency string, today time.Time) (notificationDigestPeriod, bool) {
+	today = normalizeDateUTC(today)
+	switch frequency {
+	case notificationDigestDaily:
+		return notificationDigestPeriod{
+			frequency: frequency,
+			start:     today,
+			end:       today.AddDate(0, 0, 1),
+			key:       today.Format("2006-01-02"),
+		}, true
+	case notificationDigestWeekly:
+		offset := (int(today.Weekday()) + 6) % 7
+		start := today.AddDate(0, 0, -offset)
+		year, week := start.ISOWeek()
+		return notificationDigestPeriod{
+			frequency: frequency,
+			start:     start,
+			end:       start.AddDate(0, 0, 7),
+			key:       fmt.Sprintf("%04d-W%02d", year, week),
+		}, true
+	case notificationDigestMonthly:
+		start := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return notificationDigestPeriod{
+			frequency: frequency,
+			start:     start,
+			end:       start.AddDate(0, 1, 0),
+			key:       start.Format("2006-01"),
+		}, true
+	default:
+		return notificationDigestPeriod{}, false
+	}
+}
+
+// previousStart returns the start of the period preceding p, which bounds the
+// failed deliveries a digest reports.
+func (p notificationDigestPeriod) previousStart() time.Time {
+	switch p.frequency {
+	case notificationDigestDaily:
+		return p.start.AddDate(0, 0, -1)
+	case notificationDigestWeekly:
+		return p.start.AddDate(0, 0, -7)
+	default:
+		return p.start.AddDate(0, -1, 0)
+	}
+}
+
+func notificationDigestDedupeKey(userID uint, channelType string, period notificationDigestPeriod) string {
+	return fmt.Sprintf(
+		"%s:%d:%s:%s:%s:%s",
+		notificationOutboxVersion,
+		userID,
+		notificationTriggerDigest,
+		channelType,
+		period.frequency,
+		period.key,
+	)
+}
+
+// enqueueNotificationDigest queues the digest for the user's current period on
+// every enabled channel. Empty digests are not sent; a digest whose period key
+// is already in the outbox is skipped by the unique dedupe key, and one whose
+// delivery is already logged is skipped even after its outbox row was purged.
+func (s *NotificationService) enqueueNotificationDigest(
+	userID uint,
+	policy *model.NotificationPolicy,
+	user *model.User,
+	subs []model.Subscription,
+	channels []model.NotificationChannel,
+	now time.Time,
+) error {
+	period, ok := notificationDigestPeriodFor(normalizeNotificationDigestFrequency(policy.DigestFrequency), normalizeDateUTC(now))
+	if !ok {
+		return nil
+	}
+
+	data, err := s.buildDigestTemplateData(userID, user, subs, period, normalizeDateUTC(now))
+	if err != nil {
+		return err
+	}
+	if len(data.Upcoming) == 0 && len(data.Ending) == 0 && len(data.Failed) == 0 {
+		return nil
+	}
+
+	for _, channel := range channels {
+		sent, err := s.notificationDigestAlreadySent(userID, channel.Type, period)
+		if err != nil {
+			return err
+		}
+		if sent {
+			continue
+		}
+		message, renderErr := s.renderNotificationDigestMessage(userID, channel.Type, *data)
+		if renderErr != nil {
+			logging.Error("failed to render notification digest",
+				slog.Uint64("user_id", uint64(userID)),
+				slog.String("channel", channel.Type),
+				slog.Any("error", renderErr))
+			continue
+		}
+		if err := s.enqueueNotificationDigestOutbox(userID, channel, period, message, user.Email); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// notificationDigestAlreadySent reports whether the digest for period has been
+// delivered on the channel type, according to the delivery log.
+func (s *NotificationService) notificationDigestAlreadySent(userID uint, channelType string, period notificationDigestPeriod) (bool, error) {
+	var count int64
+	err := s.DB.Model(&model.NotificationLog{}).
+		Where("user_id = ? AND subscription_id IS NULL AND channel_type = ? AND trigger_type = ? AND notify_date = ? AND status = ?",
+			userID, channelType, notificationTriggerDigest, period.start, notificationLogStatusSent).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (s *NotificationService) enqueueNotificationDigestOutbox(
+	userID uint,
+	channel model.NotificationChannel,
+	period notificationDigestPeriod,
+	message string,
+	targetEmail string,
+) error {
+	channelID := channel.ID
+	now := pkg.NowUTC()
+	expiresAt := period.end.Add(notificationOutboxExpiryWindow)
+	outbox := model.NotificationOutbox{
+		DedupeKey:     notificationDigestDedupeKey(userID, channel.Type, period),
+		UserID:        userID,
+		ChannelID:     &channelID,
+		ChannelType:   channel.Type,
+		TriggerType:   notificationTriggerDigest,
+		NotifyDate:    period.start,
+		ScheduledFor:  now,
+		ExpiresAt:     &expiresAt,
+		Status:        notificationOutboxStatusPending,
+		MaxAttempts:   loadNotificationRetryPolicy(s.DB).maxAttempts,
+		NextAttemptAt: now,
+		Message:       message,
+		TargetEmail:   targetEmail,
+	}
+
+	return s.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&outbox).Error
+}
+
+func (s *NotificationService) buildDigestTemplateData(
+	userID uint,
+	user *model.User,
+	subs []model.Subscription,
+	period notificationDigestPeriod,
+	today time.Time,
+) (*DigestTemplateData, error) {
+	targetCurrency, err := s.preferredCurrency(userID)
+	if err != nil {
+		return nil, err
+	}
+	categoryNames, paymentMethodNames, err := s.digestLookupNames(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &DigestTemplateData{
+		Locale:      loadNotificationLocale(s.DB, userID),
+		Frequency:   period.frequency,
+		PeriodStart: period.start.Format("2006-01-02"),
+		PeriodEnd:   period.end.AddDate(0, 0, -1).Format("2006-01-02"),
+		Currency:    targetCurrency,
+		UserEmail:   user.Email,
+		Upcoming:    []DigestRenewalItem{},
+		Ending:      []DigestRenewalItem{},
+		Failed:      []DigestFailedDelivery{},
+	}
+
+	var totalDue float64
+	for _, sub := range subs {
+		if sub.NotifyEnabled != nil && !*sub.NotifyEnabled {
+			continue
+		}
+
+		item := DigestRenewalItem{
+			SubscriptionName: sub.Name,
+			Amount:           sub.Amount,
+			Currency:         sub.Currency,
+			RenewalMode:      normalizeRenewalMode(sub.RenewalMode),
+			Category:         digestCategoryName(sub, categoryNames),
+			URL:              sub.URL,
+		}
+		if sub.PaymentMethodID != nil {
+			item.PaymentMethod = paymentMethodNames[*sub.PaymentMethodID]
+		}
+
+		if normalizeRenewalMode(sub.RenewalMode) == renewalModeCancelAtPeriodEnd {
+			boundary := cancelAtPeriodEndBoundary(sub)
+			if boundary == nil {
+				continue
+			}
+			endDate := normalizeDateUTC(*boundary)
+			if endDate.Before(today) || !endDate.Before(period.end) {
+				continue
+			}
+			item.Date = endDate.Format("2006-01-02")
+			item.DaysUntil = pkg.DaysUntilFrom(today, endDate, time.UTC)
+			data.Ending = append(data.Ending, item)
+			continue
+		}
+
+		for _, chargeDate := range subscriptionChargeDatesInRange(sub, today, period.end) {
+			charge := item
+			charge.Date = chargeDate.Format("2006-01-02")
+			charge.DaysUntil = pkg.DaysUntilFrom(today, chargeDate, time.UTC)
+			data.Upcoming = append(data.Upcoming, charge)
+			totalDue += s.convertAmount(sub.Amount, sub.Currency, targetCurrency)
+		}
+	}
+	data.TotalDue = math.Round(totalDue*100) / 100
+	sortDigestRenewalItems(data.Upcoming)
+	sortDigestRenewalItems(data.Ending)
+
+	failed, err := s.digestFailedDeliveries(userID, period.previousStart())
+	if err != nil {
+		return nil, err
+	}
+	data.Failed = failed
+
+	return data, nil
+}
+
+func sortDigestRenewalItems(items []DigestRenewalItem) {
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].Date != items[j].Date {
+			return items[i].Date < items[j].Date
+		}
+		return items[i].SubscriptionName < items[j].SubscriptionName
+	})
+}
+
+func digestCategoryName(sub model.Subscription, categoryNames map[uint]string) string {
+	if sub.CategoryID != nil {
+		if name, ok := categoryNames[*sub.CategoryID]; ok {
+			return name
+		}
+	}
+	return sub.Category
+}
+
+func (s *NotificationService) digestLookupNames(userID uint) (map[uint]string, map[uint]string, error) {
+	var categories []model.Category
+	if err := s.DB.Select("id", "name").Where("user_id = ?", userID).Find(&categories).Error; err != nil {
+		return nil, nil, err
+	}
+	var methods []model.PaymentMethod
+	if err := s.DB.Select("id", "name").Where("user_id = ?", userID).Find(&methods).Error; err != nil {
+		return nil, nil, err
+	}
+
+	categoryNames := make(map[uint]string, len(categories))
+	for _, category := range categories {
+		categoryNames[category.ID] = category.Name
+	}
+	paymentMethodNames := make(map[uint]string, len(methods))
+	for _, method := range methods {
+		paymentMethodNames[method.ID] = method.Name
+	}
+	return categoryNames, paymentMethodNames, nil
+}
+
+// digestFailedDeliveries lists the reminder deliveries that failed since the
+// given instant and have not been recovered by a later successful delivery on
+// the same subscription and channel.
+func (s *NotificationService) digestFailedDeliveries(userID uint, since time.Time) ([]DigestFailedDelivery, error) {
+	var logs []model.NotificationLog
+	if err := s.DB.Where("user_id = ? AND status = ? AND sent_at >= ? AND subscription_id IS NOT NULL", userID, notificationLogStatusFailed, since).
+		Order("sent_at DESC, id DESC").
+		Limit(maxTemplateRangeItems).
+		Find(&logs).Error; err != nil {
+		return nil, err
+	}
+	if len(logs) == 0 {
+		return []DigestFailedDelivery{}, nil
+	}
+
+	recovered, err := (&SubscriptionService{DB: s.DB}).notificationRecoveryIndex(userID, since)
+	if err != nil {
+		return nil, err
+	}
+
+	subscriptionIDs := make([]uint, 0, len(logs))
+	for _, logEntry := range logs {
+		subscriptionIDs = append(subscriptionIDs, *logEntry.SubscriptionID)
+	}
+	var subs []model.Subscription
+	if err := s.DB.Select("id", "name").Where("user_id = ? AND id IN ?", userID, subscriptionIDs).Find(&subs).Error; err != nil {
+		return nil, err
+	}
+	names := make(map[uint]string, len(subs))
+	for _, sub := range subs {
+		names[sub.ID] = sub.Name
+	}
+
+	failed := make([]DigestFailedDelivery, 0, len(logs))
+	for _, logEntry := range logs {
+		name, ok := names[*logEntry.SubscriptionID]
+		if !ok || recovered(logEntry) {
+			continue
+		}
+		failed = append(failed, DigestFailedDelivery{
+			SubscriptionName: name,
+			ChannelType:      logEntry.ChannelType,
+			NotifyDate:       normalizeDateUTC(logEntry.NotifyDate).Format("2006-01-02"),
+			FailedAt:         logEntry.SentAt.UTC().Format(time.RFC3339),
+			Error:            logEntry.Error,
+		})
+	}
+	return failed, nil
+}
+
+func (s *NotificationService) preferredCurrency(userID uint) (string, error) {
+	return userPreferredCurrency(s.DB, userID)
+}
+
+func (s *NotificationService) convertAmount(amount float64, from, to string) float64 {
+	if s.converter == nil || strings.EqualFold(from, to) {
+		return amount
+	}
+	return s.converter.Convert(amount, from, to)
+}
+
+func (s *NotificationService) renderNotificationDigestMessage(userID uint, channelType string, data DigestTemplateData) (string, error) {
+	templateText := builtinNotificationTemplate(data.Locale, notificationTemplateCategoryDigest)
+	template, err := s.templateService.findTemplateForChannel(userID, channelType, notificationTemplateKindDigest)
+	if err != nil {
+		return "", fmt.Errorf("failed to get digest template: %w", err)
+	}
+	if template != nil {
+		templateText = template.Template
+	}
+	message, err := s.templateRenderer.RenderDigestTemplate(templateText, data)
+	if err != nil {
+		return "", fmt.Errorf("failed to render digest template: %w", err)
+	}
+	return message, nil
+}
+
+// digestOutboxStillDeliverable cancels a queued digest whose owner has since
+// turned digests off. A digest for a stale period expires on its own.
+func (s *NotificationService) digestOutboxStillDeliverable(job model.NotificationOutbox) string {
+	policy, err := s.GetPolicy(job.UserID)
+	if err != nil {
+		if updateErr := s.releaseNotificationOutboxForRetry(job, err); updateErr != nil {
+			logOutboxPersistError(job, "release_digest_policy_lookup", updateErr)
+		}
+		return notificationOutboxStatusPending
+	}
+	if normalizeNotificationDigestFrequency(policy.DigestFrequency) == notificationDigestOff {
+		if err := s.updateNotificationOutboxTerminal(job, notificationOutboxStatusCancelled, "notification digest disabled"); err != nil {
+			logOutboxPersistError(job, "cancel_digest_disabled", err)
+		}
+		return notificationOutboxStatusCancelled
+	}
+	return ""
+}
@@ -0,0 +1,391 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shiroha/subdux/internal/model"
+	"github.com/shiroha/subdux/internal/pkg"
+	"gorm.io/gorm"
+)
+
+type notificationDigestTestConverter map[string]float64
+
+func (c notificationDigestTestConverter) Convert(amount float64, from, to string) float64 {
+	if rate, ok := c[from+to]; ok {
+		return amount * rate
+	}
+	return amount
+}
+
+func newNotificationDigestTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db := newNotificationOutboxTestDB(t)
+	if err := db.AutoMigrate(&model.Category{}, &model.PaymentMethod{}, &model.UserPreference{}); err != nil {
+		t.Fatalf("failed to migrate digest test tables: %v", err)
+	}
+	return db
+}
+
+func createNotificationDigestPolicy(t *testing.T, db *gorm.DB, userID uint, frequency string, digestOnly bool) {
+	t.Helper()
+
+	policy := model.NotificationPolicy{
+		UserID:          userID,
+		DaysBefore:      0,
+		NotifyOnDueDay:  true,
+		DigestFrequency: frequency,
+		DigestOnly:      digestOnly,
+	}
+	if err := db.Create(&policy).Error; err != nil {
+		t.Fatalf("failed to create notification policy: %v", err)
+	}
+}
+
+func loadNotificationDigestOutbox(t *testing.T, db *gorm.DB, userID uint) []model.NotificationOutbox {
+	t.Helper()
+
+	var jobs []model.NotificationOutbox
+	if err := db.Where("user_id = ? AND trigger_type = ?", userID, notificationTriggerDigest).Find(&jobs).Error; err != nil {
+		t.Fatalf("load digest outbox failed: %v", err)
+	}
+	return jobs
+}
+
+func TestNotificationDigestPeriodFor(t *testing.T) {
+	today := time.Date(2026, 3, 11, 0, 0, 0, 0, time.UTC) // Wednesday
+
+	tests := []struct {
+		frequency string
+		wantStart string
+		wantEnd   string
+		wantKey   string
+	}{
+		{frequency: notificationDigestDaily, wantStart: "2026-03-11", wantEnd: "2026-03-12", wantKey: "2026-03-11"},
+		{frequency: notificationDigestWeekly, wantStart: "2026-03-09", wantEnd: "2026-03-16", wantKey: "2026-W11"},
+		{frequency: notificationDigestMonthly, wantStart: "2026-03-01", wantEnd: "2026-04-01", wantKey: "2026-03"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.frequency, func(t *testing.T) {
+			period, ok := notificationDigestPeriodFor(tt.frequency, today)
+			if !ok {
+				t.Fatalf("notificationDigestPeriodFor(%q) ok = false", tt.frequency)
+			}
+			if got := period.start.Format("2006-01-02"); got != tt.wantStart {
+				t.Fatalf("start = %s, want %s", got, tt.wantStart)
+			}
+			if got := period.end.Format("2006-01-02"); got != tt.wantEnd {
+				t.Fatalf("end = %s, want %s", got, tt.wantEnd)
+			}
+			if period.key != tt.wantKey {
+				t.Fatalf("key = %s, want %s", period.key, tt.wantKey)
+			}
+		})
+	}
+
+	if _, ok := notificationDigestPeriodFor(notificationDigestOff, today); ok {
+		t.Fatal("notificationDigestPeriodFor(off) ok = true, want false")
+	}
+}
+
+func TestEnqueuePendingNotificationsCreatesWeeklyDigestOncePerPeriod(t *testing.T) {
+	db := newNotificationDigestTestDB(t)
+	user := createNotificationOutboxUser(t, db)
+	createNotificationOutboxTemplate(t, db, user.ID)
+	now := time.Date(2026, 3, 11, 9, 0, 0, 0, time.UTC)
+	restoreClock := pkg.SetNowForTest(now)
+	t.Cleanup(restoreClock)
+
+	if err := db.Create(&model.UserPreference{UserID: user.ID, PreferredCurrency: "USD"}).Error; err != nil {
+		t.Fatalf("failed to create preference: %v", err)
+	}
+	thisWeek := createNotificationOutboxSubscription(t, db, user.ID, time.Date(2026, 3, 13, 0, 0, 0, 0, time.UTC))
+	euro := createNotificationOutboxSubscription(t, db, user.ID, time.Date(2026, 3, 14, 0, 0, 0, 0, time.UTC))
+	if err := db.Model(&euro).Updates(map[string]interface{}{"name": "Euro Plan", "amount_minor": 2000, "currency": "EUR"}).Error; err != nil {
+		t.Fatalf("failed to update subscription: %v", err)
+	}
+	createNotificationOutboxSubscription(t, db, user.ID, time.Date(2026, 3, 20, 0, 0, 0, 0, time.UTC))
+	createNotificationOutboxChannel(t, db, user.ID, "webhook", `{"url":"https://notify.example.com/hook"}`)
+	createNotificationDigestPolicy(t, db, user.ID, notificationDigestWeekly, false)
+
+	svc := NewNotificationService(db, NewNotificationTemplateService(db, NewTemplateValidator()), NewTemplateRenderer(NewTemplateValidator()))
+	svc.SetCurrencyConverter(notificationDigestTestConverter{"EURUSD": 1.5})
+	for i := 0; i < 2; i++ {
+		if err := svc.EnqueuePendingNotifications(); err != nil {
+			t.Fatalf("EnqueuePendingNotifications() error = %v", err)
+		}
+	}
+
+	jobs := loadNotificationDigestOutbox(t, db, user.ID)
+	if len(jobs) != 1 {
+		t.Fatalf("digest outbox count = %d, want 1", len(jobs))
+	}
+	job := jobs[0]
+	if job.SubscriptionID != nil {
+		t.Fatalf("digest subscription_id = %v, want nil", *job.SubscriptionID)
+	}
+	if got := job.NotifyDate.Format("2006-01-02"); got != "2026-03-09" {
+		t.Fatalf("digest notify_date = %s, want 2026-03-09", got)
+	}
+	if !strings.HasSuffix(job.DedupeKey, ":weekly:2026-W11") {
+		t.Fatalf("digest dedupe key = %q, want weekly period key", job.DedupeKey)
+	}
+	for _, want := range []string{"Upcoming charges (2), total $42.50", "Mar 13 " + thisWeek.Name + ": $12.50", "Mar 14 Euro Plan: €20.00"} {
+		if !strings.Contains(job.Message, want) {
+			t.Fatalf("digest message = %q, want to contain %q", job.Message, want)
+		}
+	}
+	if strings.Contains(job.Message, "2026-03-20") {
+		t.Fatalf("digest message = %q, should not include charges after the period", job.Message)
+	}
+}
+
+func claimNotificationDigestOutbox(t *testing.T, svc *NotificationService, job model.NotificationOutbox) model.NotificationOutbox {
+	t.Helper()
+
+	if err := svc.DB.Model(&job).Updates(map[string]interface{}{
+		"status":    notificationOutboxStatusProcessing,
+		"locked_by": svc.notificationOwnerID(),
+	}).Error; err != nil {
+		t.Fatalf("claim digest outbox failed: %v", err)
+	}
+	if err := svc.DB.First(&job, job.ID).Error; err != nil {
+		t.Fatalf("reload digest outbox failed: %v", err)
+	}
+	return job
+}
+
+func TestDigestDeliveriesAreLoggedAndNotRequeued(t *testing.T) {
+	db := newNotificationDigestTestDB(t)
+	user := createNotificationOutboxUser(t, db)
+	createNotificationOutboxTemplate(t, db, user.ID)
+	now := time.Date(2026, 3, 15, 9, 0, 0, 0, time.UTC)
+	restoreClock := pkg.SetNowForTest(now)
+	t.Cleanup(restoreClock)
+
+	createNotificationOutboxSubscription(t, db, user.ID, normalizeDateUTC(now))
+	createNotificationOutboxChannel(t, db, user.ID, "webhook", `{"url":"https://notify.example.com/hook"}`)
+	createNotificationOutboxChannel(t, db, user.ID, "telegram", `{}`)
+	createNotificationDigestPolicy(t, db, user.ID, notificationDigestDaily, true)
+
+	svc := NewNotificationService(db, NewNotificationTemplateService(db, NewTemplateValidator()), NewTemplateRenderer(NewTemplateValidator()))
+	if err := svc.EnqueuePendingNotifications(); err != nil {
+		t.Fatalf("EnqueuePendingNotifications() error = %v", err)
+	}
+	jobs := loadNotificationDigestOutbox(t, db, user.ID)
+	if len(jobs) != 2 {
+		t.Fatalf("digest outbox count = %d, want 2", len(jobs))
+	}
+	for _, job := range jobs {
+		job = claimNotificationDigestOutbox(t, svc, job)
+		if job.ChannelType == "webhook" {
+			if err := svc.markNotificationOutboxSent(job); err != nil {
+				t.Fatalf("markNotificationOutboxSent() error = %v", err)
+			}
+			continue
+		}
+		job.AttemptCount = job.MaxAttempts
+		if err := svc.markNotificationOutboxFailed(job, errors.New("bot blocked")); err != nil {
+			t.Fatalf("markNotificationOutboxFailed() error = %v", err)
+		}
+	}
+
+	logs, err := svc.ListLogs(user.ID, 10)
+	if err != nil {
+		t.Fatalf("ListLogs() error = %v", err)
+	}
+	statuses := map[string]string{}
+	for _, entry := range logs {
+		if entry.SubscriptionID != nil || entry.TriggerType != notificationTriggerDigest {
+			t.Fatalf("log = %+v, want a digest log without subscription", entry)
+		}
+		statuses[entry.ChannelType] = entry.Status
+	}
+	if len(logs) != 2 || statuses["webhook"] != notificationLogStatusSent || statuses["telegram"] != notificationLogStatusFailed {
+		t.Fatalf("digest logs = %+v, want webhook sent and telegram failed", logs)
+	}
+
+	// Without the outbox rows only the delivery log remembers the period.
+	if err := db.Where("user_id = ?", user.ID).Delete(&model.NotificationOutbox{}).Error; err != nil {
+		t.Fatalf("delete digest outbox failed: %v", err)
+	}
+	if err := svc.EnqueuePendingNotifications(); err != nil {
+		t.Fatalf("EnqueuePendingNotifications() error = %v", err)
+	}
+	jobs = loadNotificationDigestOutbox(t, db, user.ID)
+	if len(jobs) != 1 || jobs[0].ChannelType != "telegram" {
+		t.Fatalf("requeued digests = %+v, want only the failed telegram digest", jobs)
+	}
+}
+
+func TestPurgeOutboxKeepsSentDigestUntilPeriodCloses(t *testing.T) {
+	db := newNotificationDigestTestDB(t)
+	user := createNotificationOutboxUser(t, db)
+	createNotificationOutboxTemplate(t, db, user.ID)
+	now := time.Date(2026, 3, 15, 9, 0, 0, 0, time.UTC)
+	restoreClock := pkg.SetNowForTest(now)
+	t.Cleanup(restoreClock)
+
+	createNotificationOutboxSubscription(t, db, user.ID, normalizeDateUTC(now))
+	createNotificationOutboxChannel(t, db, user.ID, "webhook", `{"url":"https://notify.example.com/hook"}`)
+	createNotificationDigestPolicy(t, db, user.ID, notificationDigestDaily, true)
+
+	svc := NewNotificationService(db, NewNotificationTemplateService(db, NewTemplateValidator()), NewTemplateRenderer(NewTemplateValidator()))
+	if err := svc.EnqueuePendingNotifications(); err != nil {
+		t.Fatalf("EnqueuePendingNotifications() error = %v", err)
+	}
+	jobs := loadNotificationDigestOutbox(t, db, user.ID)
+	if len(jobs) != 1 {
+		t.Fatalf("digest outbox count = %d, want 1", len(jobs))
+	}
+	if err := svc.markNotificationOutboxSent(claimNotificationDigestOutbox(t, svc, jobs[0])); err != nil {
+		t.Fatalf("markNotificationOutboxSent() error = %v", err)
+	}
+
+	purged, err := svc.PurgeOutbox(NotificationOutboxPurgeFilter{UserID: &user.ID})
+	if err != nil {
+		t.Fatalf("PurgeOutbox() error = %v", err)
+	}
+	if purged != 0 {
+		t.Fatalf("PurgeOutbox() purged %d, want the sent digest kept while its period is open", purged)
+	}
+
+	// The kept dedupe key alone must stop the rescan from queueing it again.
+	if err := db.Where("user_id = ?", user.ID).Delete(&model.NotificationLog{}).Error; err != nil {
+		t.Fatalf("delete digest logs failed: %v", err)
+	}
+	if err := svc.EnqueuePendingNotifications(); err != nil {
+		t.Fatalf("EnqueuePendingNotifications() error = %v", err)
+	}
+	jobs = loadNotificationDigestOutbox(t, db, user.ID)
+	if len(jobs) != 1 || jobs[0].Status != notificationOutboxStatusSent {
+		t.Fatalf("digest outbox after rescan = %+v, want only the sent digest", jobs)
+	}
+
+	restoreClock = pkg.SetNowForTest(jobs[0].ExpiresAt.Add(time.Minute))
+	t.Cleanup(restoreClock)
+	purged, err = svc.PurgeOutbox(NotificationOutboxPurgeFilter{UserID: &user.ID})
+	if err != nil {
+		t.Fatalf("PurgeOutbox() error = %v", err)
+	}
+	if purged != 1 {
+		t.Fatalf("PurgeOutbox() after the period purged %d, want 1", purged)
+	}
+}
+
+func TestEnqueuePendingNotificationsDigestOnlySuppressesReminders(t *testing.T) {
+	db := newNotificationDigestTestDB(t)
+	user := createNotificationOutboxUser(t, db)
+	createNotificationOutboxTemplate(t, db, user.ID)
+	now := time.Date(2026, 3, 15, 9, 0, 0, 0, time.UTC)
+	restoreClock := pkg.SetNowForTest(now)
+	t.Cleanup(restoreClock)
+
+	createNotificationOutboxSubscription(t, db, user.ID, normalizeDateUTC(now))
+	createNotificationOutboxChannel(t, db, user.ID, "webhook", `{"url":"https://notify.example.com/hook"}`)
+	createNotificationDigestPolicy(t, db, user.ID, notificationDigestDaily, true)
+
+	svc := NewNotificationService(db, NewNotificationTemplateService(db, NewTemplateValidator()), NewTemplateRenderer(NewTemplateValidator()))
+	if err := svc.EnqueuePendingNotifications(); err != nil {
+		t.Fatalf("EnqueuePendingNotifications() error = %v", err)
+	}
+
+	var jobs []model.NotificationOutbox
+	if err := db.Where("user_id = ?", user.ID).Find(&jobs).Error; err != nil {
+		t.Fatalf("load outbox failed: %v", err)
+	}
+	if len(jobs) != 1 || jobs[0].TriggerType != notificationTriggerDigest {
+		t.Fatalf("outbox jobs = %+v, want a single digest", jobs)
+	}
+}
+
+func TestEnqueuePendingNotificationsSkipsEmptyDigest(t *testing.T) {
+	db := newNotificationDigestTestDB(t)
+	user := createNotificationOutboxUser(t, db)
+	now := time.Date(2026, 3, 15, 9, 0, 0, 0, time.UTC)
+	restoreClock := pkg.SetNowForTest(now)
+	t.Cleanup(restoreClock)
+
+	createNotificationOutboxSubscription(t, db, user.ID, time.Date(2026, 4, 10, 0, 0, 0, 0, time.UTC))
+	createNotificationOutboxChannel(t, db, user.ID, "webhook", `{"url":"https://notify.example.com/hook"}`)
+	createNotificationDigestPolicy(t, db, user.ID, notificationDigestDaily, false)
+
+	svc := NewNotificationService(db, NewNotificationTemplateService(db, NewTemplateValidator()), NewTemplateRenderer(NewTemplateValidator()))
+	if err := svc.EnqueuePendingNotifications(); err != nil {
+		t.Fatalf("EnqueuePendingNotifications() error = %v", err)
+	}
+
+	if jobs := loadNotificationDigestOutbox(t, db, user.ID); len(jobs) != 0 {
+		t.Fatalf("digest outbox count = %d, want 0", len(jobs))
+	}
+}
+
+func TestDispatchNotificationOutboxCancelsDigestWhenDisabled(t *testing.T) {
+	db := newNotificationDigestTestDB(t)
+	user := createNotificationOutboxUser(t, db)
+	now := time.Date(2026, 3, 15, 9, 0, 0, 0, time.UTC)
+	restoreClock := pkg.SetNowForTest(now)
+	t.Cleanup(restoreClock)
+
+	createNotificationOutboxSubscription(t, db, user.ID, normalizeDateUTC(now))
+	createNotificationOutboxChannel(t, db, user.ID, "webhook", `{"url":"https://notify.example.com/hook"}`)
+	createNotificationDigestPolicy(t, db, user.ID, notificationDigestDaily, false)
+
+	svc := NewNotificationService(db, NewNotificationTemplateService(db, NewTemplateValidator()), NewTemplateRenderer(NewTemplateValidator()))
+	if err := svc.EnqueuePendingNotifications(); err != nil {
+		t.Fatalf("EnqueuePendingNotifications() error = %v", err)
+	}
+	if err := db.Model(&model.NotificationPolicy{}).Where("user_id = ?", user.ID).
+		Update("digest_frequency", notificationDigestOff).Error; err != nil {
+		t.Fatalf("failed to disable digest: %v", err)
+	}
+	if err := db.Where("user_id = ? AND trigger_type <> ?", user.ID, notificationTriggerDigest).
+		Delete(&model.NotificationOutbox{}).Error; err != nil {
+		t.Fatalf("failed to drop reminder jobs: %v", err)
+	}
+
+	summary, err := svc.DispatchDueNotificationOutbox(context.Background())
+	if err != nil {
+		t.Fatalf("DispatchDueNotificationOutbox() error = %v", err)
+	}
+	if summary.Cancelled != 1 {
+		t.Fatalf("cancelled = %d, want 1", summary.Cancelled)
+	}
+
+	jobs := loadNotificationDigestOutbox(t, db, user.ID)
+	if len(jobs) != 1 || jobs[0].Status != notificationOutboxStatusCancelled {
+		t.Fatalf("digest jobs = %+v, want one cancelled", jobs)
+	}
+	var logCount int64
+	if err := db.Model(&model.NotificationLog{}).Where("user_id = ?", user.ID).Count(&logCount).Error; err != nil {
+		t.Fatalf("count logs failed: %v", err)
+	}
+	if logCount != 0 {
+		t.Fatalf("notification log count = %d, want 0", logCount)
+	}
+}
+
+func TestUpdatePolicyValidatesDigestFrequency(t *testing.T) {
+	db := newNotificationDigestTestDB(t)
+	user := createNotificationOutboxUser(t, db)
+	svc := NewNotificationService(db, nil, nil)
+
+	invalid := "hourly"
+	if _, err := svc.UpdatePolicy(user.ID, UpdatePolicyInput{DigestFrequency: &invalid}); err == nil {
+		t.Fatal("UpdatePolicy() error = nil, want invalid digest_frequency error")
+	}
+
+	weekly := " Weekly "
+	digestOnly := true
+	policy, err := svc.UpdatePolicy(user.ID, UpdatePolicyInput{DigestFrequency: &weekly, DigestOnly: &digestOnly})
+	if err != nil {
+		t.Fatalf("UpdatePolicy() error = %v", err)
+	}
+	if policy.DigestFrequency != notificationDigestWeekly || !policy.DigestOnly {
+		t.Fatalf("policy digest = %q/%v, want weekly/true", policy.DigestFrequency, policy.DigestOnly)
+	}
+}
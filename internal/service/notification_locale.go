@@ -0,0 +1,192 @@
+package service
+
+import (
+	"strings"
+
+	"github.com/shiroha/subdux/internal/model"
+	"gorm.io/gorm"
+)
+
+const (
+	localeEN   = "en"
+	localeZHCN = "zh-CN"
+	localeJA   = "ja"
+
+	defaultNotificationLocale = localeEN
+)
+
+// Built-in template categories. Reminders (days before and manual-renew daily)
+// and due-day notifications differ only in wording; ending and ended
+// subscriptions have their own messages. Card expiry reminders and new sign-in
+// alerts have no user template and always use the built-in one.
+const (
+	notificationTemplateCategoryReminder              = "reminder"
+	notificationTemplateCategoryDue                   = "due"
+	notificationTemplateCategoryManualRenewEnded      = "manual_renew_ended"
+	notificationTemplateCategoryCancelAtPeriodEnd     = "cancel_at_period_end"
+	notificationTemplateCategoryDigest                = "digest"
+	notificationTemplateCategoryPaymentMethodExpiring = "payment_method_expiring"
+	notificationTemplateCategoryNewLogin              = "new_login"
+)
+
+// NormalizeLocale maps a language tag onto one of the supported notification
+// locales (en, zh-CN, ja). It reports false for unsupported languages.
+func NormalizeLocale(value string) (string, bool) {
+	tag := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(value), "_", "-"))
+	switch {
+	case tag == "en" || strings.HasPrefix(tag, "en-"):
+		return localeEN, true
+	case tag == "zh" || tag == "zh-cn" || tag == "zh-hans" || strings.HasPrefix(tag, "zh-hans-") || tag == "zh-sg":
+		return localeZHCN, true
+	case tag == "ja" || strings.HasPrefix(tag, "ja-"):
+		return localeJA, true
+	default:
+		return "", false
+	}
+}
+
+func notificationLocaleOrDefault(value string) string {
+	if locale, ok := NormalizeLocale(value); ok {
+		return locale
+	}
+	return defaultNotificationLocale
+}
+
+// loadNotificationLocale returns the user's notification locale, falling back
+// to English when no preference is stored.
+func loadNotificationLocale(db *gorm.DB, userID uint) string {
+	var preference model.UserPreference
+	if err := db.Select("locale").Where("user_id = ?", userID).Limit(1).Find(&preference).Error; err != nil {
+		return defaultNotificationLocale
+	}
+	return notificationLocaleOrDefault(preference.Locale)
+}
+
+func notificationTemplateCategory(data TemplateData) string {
+	switch {
+	case data.EventType == "manual_renew_ended":
+		return notificationTemplateCategoryManualRenewEnded
+	case data.EventType == "ending_soon":
+		return notificationTemplateCategoryCancelAtPeriodEnd
+	case data.DaysUntil == 0:
+		return notificationTemplateCategoryDue
+	default:
+		return notificationTemplateCategoryReminder
+	}
+}
+
+// builtinNotificationTemplate returns the built-in template for a locale and
+// category. Every supported locale defines every category.
+func builtinNotificationTemplate(locale, category string) string {
+	templates, ok := builtinNotificationTemplates[locale]
+	if !ok {
+		templates = builtinNotificationTemplates[defaultNotificationLocale]
+	}
+	return templates[category]
+}
+
+// isSeededDefaultNotificationTemplate reports whether tmpl is the untouched
+// default template seeded at registration. Such a template has not been
+// customized, so the localized built-in templates are used in its place.
+func isSeededDefaultNotificationTemplate(tmpl *model.NotificationTemplate) bool {
+	return tmpl.ChannelType == nil &&
+		normalizeNotificationTemplateKind(tmpl.Kind) == notificationTemplateKindReminder &&
+		strings.TrimSpace(tmpl.Template) == defaultNotificationTemplate
+}
+
+var builtinNotificationTemplates = map[string]map[string]string{
+	localeEN: {
+		notificationTemplateCategoryReminder: `{{.SubscriptionName}} {{if eq .RenewalMode "manual_renew"}}needs to be renewed{{else}}renews{{end}} ` +
+			`in {{.DaysUntil}} {{pluralize .DaysUntil "day" "days"}} on {{formatDate .BillingDate}}. ` +
+			`Amount: {{formatMoney .Amount .Currency}}.{{if .PaymentMethod}} Payment method: {{.PaymentMethod}}.{{end}}` +
+			`{{if .URL}} {{.URL}}{{end}}{{if .Remark}} Note: {{.Remark}}{{end}}`,
+		notificationTemplateCategoryDue: `{{.SubscriptionName}} {{if eq .RenewalMode "manual_renew"}}needs to be renewed{{else}}renews{{end}} ` +
+			`today ({{formatDate .BillingDate}}). Amount: {{formatMoney .Amount .Currency}}.` +
+			`{{if .PaymentMethod}} Payment method: {{.PaymentMethod}}.{{end}}{{if .URL}} {{.URL}}{{end}}{{if .Remark}} Note: {{.Remark}}{{end}}`,
+		notificationTemplateCategoryManualRenewEnded: `{{.SubscriptionName}} ended on {{formatDate .BillingDate}} without being renewed.` +
+			`{{if .URL}} Renew at {{.URL}}{{end}}`,
+		notificationTemplateCategoryCancelAtPeriodEnd: `{{.SubscriptionName}} ends {{if eq .DaysUntil 0}}today{{else}}in {{.DaysUntil}} ` +
+			`{{pluralize .DaysUntil "day" "days"}} on {{formatDate .BillingDate}}{{end}} and will not renew.{{if .URL}} {{.URL}}{{end}}`,
+		notificationTemplateCategoryDigest: "Subdux {{.Frequency}} digest for {{formatDate .PeriodStart}} to {{formatDate .PeriodEnd}}.\n" +
+			"Upcoming charges ({{.UpcomingCount}}), total {{formatMoney .TotalDue .Currency}}:\n" +
+			"{{range .Upcoming}}- {{formatDate .Date \"short\"}} {{.SubscriptionName}}: {{formatMoney .Amount .Currency}}\n{{end}}" +
+			"{{if .EndingCount}}Ending ({{.EndingCount}}):\n" +
+			"{{range .Ending}}- {{formatDate .Date \"short\"}} {{.SubscriptionName}}\n{{end}}{{end}}" +
+			"{{if .FailedCount}}Failed deliveries ({{.FailedCount}}):\n" +
+			"{{range .Failed}}- {{.SubscriptionName}} via {{.ChannelType}}: {{.Error}}\n{{end}}{{end}}",
+		notificationTemplateCategoryPaymentMethodExpiring: "Payment method expiring soon\n" +
+			"{{.PaymentMethod}}{{if or .Issuer .LastFour}} ({{.Issuer}}{{if and .Issuer .LastFour}}, {{end}}{{if .LastFour}}ending {{.LastFour}}{{end}}){{end}} " +
+			"expires on {{formatDate .ExpiresOn}} (in {{.DaysUntil}} {{pluralize .DaysUntil \"day\" \"days\"}}).\n\n" +
+			"Subscriptions billed to it:\n" +
+			"{{range .Subscriptions}}- {{.SubscriptionName}}: {{formatMoney .Amount .Currency}}{{if .NextBillingDate}}, next charge {{formatDate .NextBillingDate}}{{end}}\n{{end}}" +
+			"\nUpdate the card with each provider, then replace the payment method in Subdux to move these subscriptions to the new card.",
+		notificationTemplateCategoryNewLogin: "New sign-in to your Subdux account\n" +
+			"Device: {{if .Device}}{{.Device}}{{else}}unknown device{{end}}\n" +
+			"IP address: {{if .IPAddress}}{{.IPAddress}}{{else}}unknown{{end}}\n" +
+			"Method: {{.Method}}\n" +
+			"Time: {{formatDate .SignedInAt}} {{.SignedInTime}}\n\n" +
+			"If this wasn't you, revoke the session from your account settings and change your password.",
+	},
+	localeZHCN: {
+		notificationTemplateCategoryReminder: `{{.SubscriptionName}} 将于 {{.DaysUntil}} 天后（{{formatDate .BillingDate}}）` +
+			`{{if eq .RenewalMode "manual_renew"}}到期，需要手动续费{{else}}自动续费{{end}}，金额 {{formatMoney .Amount .Currency}}。` +
+			`{{if .PaymentMethod}}支付方式：{{.PaymentMethod}}。{{end}}{{if .URL}}{{.URL}}{{end}}{{if .Remark}} 备注：{{.Remark}}{{end}}`,
+		notificationTemplateCategoryDue: `{{.SubscriptionName}} 今天（{{formatDate .BillingDate}}）` +
+			`{{if eq .RenewalMode "manual_renew"}}到期，需要手动续费{{else}}自动续费{{end}}，金额 {{formatMoney .Amount .Currency}}。` +
+			`{{if .PaymentMethod}}支付方式：{{.PaymentMethod}}。{{end}}{{if .URL}}{{.URL}}{{end}}{{if .Remark}} 备注：{{.Remark}}{{end}}`,
+		notificationTemplateCategoryManualRenewEnded: `{{.SubscriptionName}} 已于 {{formatDate .BillingDate}} 到期且未续费。` +
+			`{{if .URL}}续费地址：{{.URL}}{{end}}`,
+		notificationTemplateCategoryCancelAtPeriodEnd: `{{.SubscriptionName}} 将于{{if eq .DaysUntil 0}}今天{{else}} {{.DaysUntil}} 天后（{{formatDate .BillingDate}}）{{end}}结束，不会再续费。` +
+			`{{if .URL}}{{.URL}}{{end}}`,
+		notificationTemplateCategoryDigest: "Subdux 订阅摘要（{{formatDate .PeriodStart}} 至 {{formatDate .PeriodEnd}}）\n" +
+			"即将扣费 {{.UpcomingCount}} 项，共计 {{formatMoney .TotalDue .Currency}}：\n" +
+			"{{range .Upcoming}}- {{formatDate .Date \"short\"}} {{.SubscriptionName}}：{{formatMoney .Amount .Currency}}\n{{end}}" +
+			"{{if .EndingCount}}即将结束 {{.EndingCount}} 项：\n" +
+			"{{range .Ending}}- {{formatDate .Date \"short\"}} {{.SubscriptionName}}\n{{end}}{{end}}" +
+			"{{if .FailedCount}}发送失败 {{.FailedCount}} 项：\n" +
+			"{{range .Failed}}- {{.SubscriptionName}}（{{.ChannelType}}）：{{.Error}}\n{{end}}{{end}}",
+		notificationTemplateCategoryPaymentMethodExpiring: "支付方式即将过期\n" +
+			"{{.PaymentMethod}}{{if or .Issuer .LastFour}}（{{.Issuer}}{{if and .Issuer .LastFour}}，{{end}}{{if .LastFour}}尾号 {{.LastFour}}{{end}}）{{end}}" +
+			" 将于 {{formatDate .ExpiresOn}}（{{.DaysUntil}} 天后）过期。\n\n" +
+			"使用该支付方式的订阅：\n" +
+			"{{range .Subscriptions}}- {{.SubscriptionName}}：{{formatMoney .Amount .Currency}}{{if .NextBillingDate}}，下次扣费 {{formatDate .NextBillingDate}}{{end}}\n{{end}}" +
+			"\n请先在各服务商处更新卡片信息，再在 Subdux 中替换支付方式，将这些订阅转移到新卡。",
+		notificationTemplateCategoryNewLogin: "你的 Subdux 账户有新的登录\n" +
+			"设备：{{if .Device}}{{.Device}}{{else}}未知设备{{end}}\n" +
+			"IP 地址：{{if .IPAddress}}{{.IPAddress}}{{else}}未知{{end}}\n" +
+			"方式：{{.Method}}\n" +
+			"时间：{{formatDate .SignedInAt}} {{.SignedInTime}}\n\n" +
+			"如果这不是你本人的操作，请在账户设置中撤销该会话并修改密码。",
+	},
+	localeJA: {
+		notificationTemplateCategoryReminder: `{{.SubscriptionName}} は {{.DaysUntil}} 日後（{{formatDate .BillingDate}}）に` +
+			`{{if eq .RenewalMode "manual_renew"}}期限を迎えます。手動での更新が必要です{{else}}自動更新されます{{end}}。金額：{{formatMoney .Amount .Currency}}。` +
+			`{{if .PaymentMethod}}支払方法：{{.PaymentMethod}}。{{end}}{{if .URL}}{{.URL}}{{end}}{{if .Remark}} メモ：{{.Remark}}{{end}}`,
+		notificationTemplateCategoryDue: `{{.SubscriptionName}} は本日（{{formatDate .BillingDate}}）` +
+			`{{if eq .RenewalMode "manual_renew"}}期限を迎えます。手動での更新が必要です{{else}}自動更新されます{{end}}。金額：{{formatMoney .Amount .Currency}}。` +
+			`{{if .PaymentMethod}}支払方法：{{.PaymentMethod}}。{{end}}{{if .URL}}{{.URL}}{{end}}{{if .Remark}} メモ：{{.Remark}}{{end}}`,
+		notificationTemplateCategoryManualRenewEnded: `{{.SubscriptionName}} は {{formatDate .BillingDate}} に更新されないまま終了しました。` +
+			`{{if .URL}}更新はこちら：{{.URL}}{{end}}`,
+		notificationTemplateCategoryCancelAtPeriodEnd: `{{.SubscriptionName}} は{{if eq .DaysUntil 0}}本日{{else}} {{.DaysUntil}} 日後（{{formatDate .BillingDate}}）に{{end}}終了し、更新されません。` +
+			`{{if .URL}}{{.URL}}{{end}}`,
+		notificationTemplateCategoryDigest: "Subdux ダイジェスト（{{formatDate .PeriodStart}}〜{{formatDate .PeriodEnd}}）\n" +
+			"今後の請求 {{.UpcomingCount}} 件、合計 {{formatMoney .TotalDue .Currency}}：\n" +
+			"{{range .Upcoming}}- {{formatDate .Date \"short\"}} {{.SubscriptionName}}：{{formatMoney .Amount .Currency}}\n{{end}}" +
+			"{{if .EndingCount}}終了予定 {{.EndingCount}} 件：\n" +
+			"{{range .Ending}}- {{formatDate .Date \"short\"}} {{.SubscriptionName}}\n{{end}}{{end}}" +
+			"{{if .FailedCount}}送信失敗 {{.FailedCount}} 件：\n" +
+			"{{range .Failed}}- {{.SubscriptionName}}（{{.ChannelType}}）：{{.Error}}\n{{end}}{{end}}",
+		notificationTemplateCategoryPaymentMethodExpiring: "支払方法の有効期限が近づいています\n" +
+			"{{.PaymentMethod}}{{if or .Issuer .LastFour}}（{{.Issuer}}{{if and .Issuer .LastFour}}、{{end}}{{if .LastFour}}末尾 {{.LastFour}}{{end}}）{{end}}" +
+			" は {{formatDate .ExpiresOn}}（{{.DaysUntil}} 日後）に有効期限が切れます。\n\n" +
+			"この支払方法で請求されるサブスクリプション：\n" +
+			"{{range .Subscriptions}}- {{.SubscriptionName}}：{{formatMoney .Amount .Currency}}{{if .NextBillingDate}}、次回請求 {{formatDate .NextBillingDate}}{{end}}\n{{end}}" +
+			"\n各サービスでカード情報を更新してから、Subdux で支払方法を置き換えて、これらのサブスクリプションを新しいカードに移してください。",
+		notificationTemplateCategoryNewLogin: "Subdux アカウントに新しいサインインがありました\n" +
+			"デバイス：{{if .Device}}{{.Device}}{{else}}不明なデバイス{{end}}\n" +
+			"IP アドレス：{{if .IPAddress}}{{.IPAddress}}{{else}}不明{{end}}\n" +
+			"方法：{{.Method}}\n" +
+			"日時：{{formatDate .SignedInAt}} {{.SignedInTime}}\n\n" +
+			"心当たりがない場合は、アカウント設定からこのセッションを取り消し、パスワードを変更してください。",
+	},
+}
@@ -0,0 +1,228 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shiroha/subdux/internal/model"
+	"github.com/shiroha/subdux/internal/pkg"
+)
+
+func TestNormalizeLocale(t *testing.T) {
+	tests := []struct {
+		value  string
+		want   string
+		wantOK bool
+	}{
+		{value: "en", want: localeEN, wantOK: true},
+		{value: "en-GB", want: localeEN, wantOK: true},
+		{value: "zh-CN", want: localeZHCN, wantOK: true},
+		{value: "zh_Hans", want: localeZHCN, wantOK: true},
+		{value: " JA-jp ", want: localeJA, wantOK: true},
+		{value: "zh-TW", wantOK: false},
+		{value: "fr", wantOK: false},
+		{value: "", wantOK: false},
+	}
+	for _, tt := range tests {
+		got, ok := NormalizeLocale(tt.value)
+		if got != tt.want || ok != tt.wantOK {
+			t.Fatalf("NormalizeLocale(%q) = (%q, %v), want (%q, %v)", tt.value, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestBuiltinNotificationTemplatesRenderEveryCategory(t *testing.T) {
+	validator := NewTemplateValidator()
+	renderer := NewTemplateRenderer(validator)
+
+	reminderCategories := []string{
+		notificationTemplateCategoryReminder,
+		notificationTemplateCategoryDue,
+		notificationTemplateCategoryManualRenewEnded,
+		notificationTemplateCategoryCancelAtPeriodEnd,
+	}
+	for _, locale := range []string{localeEN, localeZHCN, localeJA} {
+		for _, category := range reminderCategories {
+			tmpl := builtinNotificationTemplate(locale, category)
+			if err := validator.ValidateTemplateForKind(notificationTemplateKindReminder, tmpl); err != nil {
+				t.Fatalf("built-in %s/%s template invalid: %v", locale, category, err)
+			}
+			message, err := renderer.RenderTemplate(tmpl, TemplateData{
+				SubscriptionName: "Netflix",
+				BillingDate:      "2026-03-15",
+				Amount:           15.99,
+				Currency:         "USD",
+				DaysUntil:        3,
+				RenewalMode:      renewalModeAutoRenew,
+				Locale:           locale,
+			})
+			if err != nil {
+				t.Fatalf("built-in %s/%s template render error = %v", locale, category, err)
+			}
+			if !strings.Contains(message, "Netflix") {
+				t.Fatalf("built-in %s/%s message = %q, want subscription name", locale, category, message)
+			}
+		}
+
+		digest := builtinNotificationTemplate(locale, notificationTemplateCategoryDigest)
+		if err := validator.ValidateTemplateForKind(notificationTemplateKindDigest, digest); err != nil {
+			t.Fatalf("built-in %s digest template invalid: %v", locale, err)
+		}
+		data := sampleDigestTemplateData()
+		data.Locale = locale
+		if _, err := renderer.RenderDigestTemplate(digest, data); err != nil {
+			t.Fatalf("built-in %s digest template render error = %v", locale, err)
+		}
+
+		expiry := builtinNotificationTemplate(locale, notificationTemplateCategoryPaymentMethodExpiring)
+		message, err := renderer.RenderPaymentMethodExpiryTemplate(expiry, PaymentMethodExpiryTemplateData{
+			PaymentMethod: "Visa",
+			Issuer:        "Chase",
+			LastFour:      "4242",
+			ExpiresOn:     "2026-10-31",
+			DaysUntil:     14,
+			Subscriptions: []PaymentMethodExpiryItem{
+				{SubscriptionName: "Netflix", Amount: 15.99, Currency: "USD", NextBillingDate: "2026-11-05"},
+			},
+			Locale: locale,
+		})
+		if err != nil {
+			t.Fatalf("built-in %s payment method expiry template render error = %v", locale, err)
+		}
+		if !strings.Contains(message, "4242") || !strings.Contains(message, "Netflix") {
+			t.Fatalf("built-in %s payment method expiry message = %q, want card and subscription", locale, message)
+		}
+
+		login := builtinNotificationTemplate(locale, notificationTemplateCategoryNewLogin)
+		message, err = renderer.RenderNewLoginTemplate(login, NewLoginTemplateData{
+			Device:     "Firefox",
+			IPAddress:  "192.0.2.10",
+			Method:     SessionViaPassword,
+			SignedInAt: "2026-10-18T09:30:00Z",
+			Locale:     locale,
+		})
+		if err != nil {
+			t.Fatalf("built-in %s new sign-in template render error = %v", locale, err)
+		}
+		if !strings.Contains(message, "Firefox") || !strings.Contains(message, "09:30 UTC") {
+			t.Fatalf("built-in %s new sign-in message = %q, want device and time", locale, message)
+		}
+	}
+}
+
+func TestNotificationTemplateCategory(t *testing.T) {
+	tests := []struct {
+		data TemplateData
+		want string
+	}{
+		{data: TemplateData{EventType: "auto_renew_reminder", DaysUntil: 3}, want: notificationTemplateCategoryReminder},
+		{data: TemplateData{EventType: "manual_renew_reminder", DaysUntil: 0}, want: notificationTemplateCategoryDue},
+		{data: TemplateData{EventType: "manual_renew_ended", DaysUntil: 0}, want: notificationTemplateCategoryManualRenewEnded},
+		{data: TemplateData{EventType: "ending_soon", DaysUntil: 0}, want: notificationTemplateCategoryCancelAtPeriodEnd},
+	}
+	for _, tt := range tests {
+		if got := notificationTemplateCategory(tt.data); got != tt.want {
+			t.Fatalf("notificationTemplateCategory(%s, %d) = %q, want %q", tt.data.EventType, tt.data.DaysUntil, got, tt.want)
+		}
+	}
+}
+
+func TestRenderNotificationMessageUsesLocalizedBuiltinUnlessCustomized(t *testing.T) {
+	db := newNotificationDigestTestDB(t)
+	user := createNotificationOutboxUser(t, db)
+	restoreClock := pkg.SetNowForTest(time.Date(2026, 3, 12, 9, 0, 0, 0, time.UTC))
+	t.Cleanup(restoreClock)
+	if err := seedDefaultNotificationTemplate(db, user.ID); err != nil {
+		t.Fatalf("seedDefaultNotificationTemplate() error = %v", err)
+	}
+
+	svc := NewNotificationService(db, NewNotificationTemplateService(db, NewTemplateValidator()), NewTemplateRenderer(NewTemplateValidator()))
+	prefs := NewUserPreferenceService(db)
+	locale := "ja-JP"
+	if _, err := prefs.UpdateLocalePreference(user.ID, UpdateLocalePreferenceInput{Locale: &locale}); err != nil {
+		t.Fatalf("UpdateLocalePreference() error = %v", err)
+	}
+
+	data := TemplateData{
+		SubscriptionName: "Netflix",
+		BillingDate:      "2026-03-15",
+		Amount:           1500,
+		Currency:         "JPY",
+		DaysUntil:        3,
+		EventType:        "auto_renew_reminder",
+		RenewalMode:      renewalModeAutoRenew,
+		Locale:           loadNotificationLocale(db, user.ID),
+	}
+	message, err := svc.renderNotificationMessage(user.ID, "webhook", data)
+	if err != nil {
+		t.Fatalf("renderNotificationMessage() error = %v", err)
+	}
+	if want := "Netflix は 3 日後（2026年3月15日）に自動更新されます。金額：￥1,500。"; message != want {
+		t.Fatalf("message = %q, want %q", message, want)
+	}
+
+	if err := db.Model(&model.NotificationTemplate{}).
+		Where("user_id = ?", user.ID).
+		Update("template", "custom {{.SubscriptionName}}").Error; err != nil {
+		t.Fatalf("customize template failed: %v", err)
+	}
+	message, err = svc.renderNotificationMessage(user.ID, "webhook", data)
+	if err != nil {
+		t.Fatalf("renderNotificationMessage() error = %v", err)
+	}
+	if message != "custom Netflix" {
+		t.Fatalf("message = %q, want customized template", message)
+	}
+}
+
+func TestUpdateLocalePreferenceValidatesInput(t *testing.T) {
+	db := newNotificationDigestTestDB(t)
+	user := createNotificationOutboxUser(t, db)
+	svc := NewUserPreferenceService(db)
+
+	pref, err := svc.GetLocalePreference(user.ID)
+	if err != nil {
+		t.Fatalf("GetLocalePreference() error = %v", err)
+	}
+	if pref.Locale != localeEN || pref.Timezone != "" {
+		t.Fatalf("default preference = %+v, want en and system timezone", pref)
+	}
+
+	locale, timezone := "zh-hans", "Asia/Tokyo"
+	pref, err = svc.UpdateLocalePreference(user.ID, UpdateLocalePreferenceInput{Locale: &locale, Timezone: &timezone})
+	if err != nil {
+		t.Fatalf("UpdateLocalePreference() error = %v", err)
+	}
+	if pref.Locale != localeZHCN || pref.Timezone != "Asia/Tokyo" {
+		t.Fatalf("preference = %+v, want zh-CN and Asia/Tokyo", pref)
+	}
+
+	var stored model.UserPreference
+	if err := db.Where("user_id = ?", user.ID).First(&stored).Error; err != nil {
+		t.Fatalf("load preference failed: %v", err)
+	}
+	if stored.PreferredCurrency != "USD" {
+		t.Fatalf("preferred currency = %q, want column default", stored.PreferredCurrency)
+	}
+
+	invalidLocale := "fr"
+	if _, err := svc.UpdateLocalePreference(user.ID, UpdateLocalePreferenceInput{Locale: &invalidLocale}); !errors.Is(err, ErrUnsupportedLocale) {
+		t.Fatalf("UpdateLocalePreference(fr) error = %v, want ErrUnsupportedLocale", err)
+	}
+	for _, invalidTimezone := range []string{"Mars/Olympus", "Local"} {
+		if _, err := svc.UpdateLocalePreference(user.ID, UpdateLocalePreferenceInput{Timezone: &invalidTimezone}); !errors.Is(err, ErrInvalidTimezone) {
+			t.Fatalf("UpdateLocalePreference(%q) error = %v, want ErrInvalidTimezone", invalidTimezone, err)
+		}
+	}
+
+	empty := ""
+	pref, err = svc.UpdateLocalePreference(user.ID, UpdateLocalePreferenceInput{Timezone: &empty})
+	if err != nil {
+		t.Fatalf("UpdateLocalePreference(reset) error = %v", err)
+	}
+	if pref.Locale != localeZHCN || pref.Timezone != "" {
+		t.Fatalf("preference after reset = %+v, want zh-CN and system timezone", pref)
+	}
+}
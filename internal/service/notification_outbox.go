@@ -192,10 +192,11 @@ func (s *NotificationService) enqueueNotificationOutbox(job notificationOutboxJo
 	if job.triggerType == notificationTriggerManualEnded {
 		expiresAt = now.Add(notificationOutboxExpiryWindow)
 	}
+	subscriptionID := job.subscriptionID
 	outbox := model.NotificationOutbox{
 		DedupeKey:       notificationOutboxDedupeKeyForTrigger(job.userID, job.subscriptionID, job.channel.Type, job.triggerType, notifyDate, dedupeDate),
 		UserID:          job.userID,
-		SubscriptionID:  job.subscriptionID,
+		SubscriptionID:  &subscriptionID,
 		ChannelID:       &channelID,
 		ChannelType:     job.channel.Type,
 		TriggerType:     job.triggerType,
@@ -203,7 +204,7 @@ func (s *NotificationService) enqueueNotificationOutbox(job notificationOutboxJo
 		ScheduledFor:    now,
 		ExpiresAt:       &expiresAt,
 		Status:          notificationOutboxStatusPending,
-		MaxAttempts:     notificationOutboxDefaultMaxAttempts,
+		MaxAttempts:     loadNotificationRetryPolicy(s.DB).maxAttempts,
 		NextAttemptAt:   now,
 		Message:         job.message,
 		TargetEmail:     job.targetEmail,
@@ -225,8 +226,9 @@ func (s *NotificationService) notificationAlreadySent(subscriptionID uint, chann
 		query = query.Where("trigger_type = ? OR trigger_type = ? OR trigger_type IS NULL", triggerType, "")
 	}
 	if notificationTriggerUsesDedupeDate(triggerType) {
-		systemLoc := pkg.GetSystemTimezone()
-		sentDateStart := pkg.NormalizeDateInTimezone(dedupeDate, systemLoc)
+		// The scan passes the user's local day start, so the sent-at window
+		// follows the user's calendar day rather than the server's.
+		sentDateStart := pkg.NormalizeDateInTimezone(dedupeDate, dedupeDate.Location())
 		sentDateEnd := sentDateStart.AddDate(0, 0, 1)
 		query = query.Where("sent_at >= ? AND sent_at < ?", sentDateStart.UTC(), sentDateEnd.UTC())
 	}
@@ -429,9 +431,22 @@ func (s *NotificationService) cancelOutboxIfNoLongerDeliverable(job model.Notifi
 		return notificationOutboxStatusExpired
 	}
 
+	if job.TriggerType == notificationTriggerDigest {
+		return s.digestOutboxStillDeliverable(job)
+	}
+	if job.TriggerType == notificationTriggerNewLogin || job.TriggerType == notificationTriggerCardExpiring {
+		return ""
+	}
+	if job.SubscriptionID == nil {
+		if err := s.updateNotificationOutboxTerminal(job, notificationOutboxStatusCancelled, "subscription not found"); err != nil {
+			logOutboxPersistError(job, "cancel_subscription_missing", err)
+		}
+		return notificationOutboxStatusCancelled
+	}
+
 	var sub model.Subscription
 	err := s.DB.Select("id", "user_id", "status", "billing_type", "renewal_mode", "ends_at", "next_billing_date", "notify_enabled", "notify_days_before").
-		Where("id = ? AND user_id = ?", job.SubscriptionID, job.UserID).
+		Where("id = ? AND user_id = ?", *job.SubscriptionID, job.UserID).
 		First(&sub).Error
 	if errors.Is(err, gorm.ErrRecordNotFound) {
 		if updateErr := s.updateNotificationOutboxTerminal(job, notificationOutboxStatusCancelled, "subscription not found"); updateErr != nil {
@@ -526,6 +541,9 @@ func (s *NotificationService) outboxMatchesCurrentReminder(job model.Notificatio
 	if err != nil {
 		return false, "", err
 	}
+	if notificationDigestReplacesReminders(policy) {
+		return false, "queued reminder replaced by notification digest", nil
+	}
 
 	daysBefore := policy.DaysBefore
 	notifyOnDueDay := policy.NotifyOnDueDay
@@ -533,16 +551,16 @@ func (s *NotificationService) outboxMatchesCurrentReminder(job model.Notificatio
 		daysBefore = *sub.NotifyDaysBefore
 	}
 
-	systemLoc := pkg.GetSystemTimezone()
-	billingDate := pkg.NormalizeDateInTimezone(*sub.NextBillingDate, systemLoc)
+	loc := userLocation(s.DB, job.UserID)
+	billingDate := pkg.CalendarDateInTimezone(*sub.NextBillingDate, loc)
 	if !normalizeDateUTC(job.NotifyDate).Equal(normalizeDateUTC(billingDate)) {
 		return false, "queued reminder no longer matches billing date", nil
 	}
 
-	daysUntilBilling := pkg.DaysUntil(*sub.NextBillingDate, systemLoc)
+	daysUntilBilling := pkg.DaysUntil(billingDate, loc)
 	if job.TriggerType == notificationTriggerManualDaily {
-		scheduledDate := pkg.NormalizeDateInTimezone(job.ScheduledFor, systemLoc)
-		today := pkg.TodayInTimezone(systemLoc)
+		scheduledDate := pkg.NormalizeDateInTimezone(job.ScheduledFor, loc)
+		today := pkg.TodayInTimezone(loc)
 		if !scheduledDate.Equal(today) {
 			return false, "queued daily manual-renew reminder is stale", nil
 		}
@@ -568,6 +586,9 @@ func (s *NotificationService) outboxMatchesCurrentEndingSoonReminder(job model.N
 	if err != nil {
 		return false, "", err
 	}
+	if notificationDigestReplacesReminders(policy) {
+		return false, "queued ending reminder replaced by notification digest", nil
+	}
 
 	daysBefore := policy.DaysBefore
 	notifyOnDueDay := policy.NotifyOnDueDay
@@ -580,19 +601,19 @@ func (s *NotificationService) outboxMatchesCurrentEndingSoonReminder(job model.N
 		return false, "queued ending reminder no longer has an ending date", nil
 	}
 
-	systemLoc := pkg.GetSystemTimezone()
-	endDate := pkg.NormalizeDateInTimezone(*boundary, systemLoc)
+	loc := userLocation(s.DB, job.UserID)
+	endDate := pkg.CalendarDateInTimezone(*boundary, loc)
 	if !normalizeDateUTC(job.NotifyDate).Equal(normalizeDateUTC(endDate)) {
 		return false, "queued ending reminder no longer matches ending date", nil
 	}
 
-	daysUntilEnd := pkg.DaysUntil(endDate, systemLoc)
+	daysUntilEnd := pkg.DaysUntil(endDate, loc)
 	if len(notificationTriggerTypes(daysUntilEnd, daysBefore, notifyOnDueDay)) == 0 {
 		return false, "queued ending reminder no longer matches reminder timing", nil
 	}
 
-	scheduledDate := pkg.NormalizeDateInTimezone(job.ScheduledFor, systemLoc)
-	today := pkg.TodayInTimezone(systemLoc)
+	scheduledDate := pkg.NormalizeDateInTimezone(job.ScheduledFor, loc)
+	today := pkg.TodayInTimezone(loc)
 	if !scheduledDate.Equal(today) {
 		return false, "queued ending reminder is stale", nil
 	}
@@ -666,7 +687,7 @@ func (s *NotificationService) markNotificationOutboxFailed(job model.Notificatio
 	sanitizedErr := sanitizeNotificationError(sendErr.Error())
 	maxAttempts := effectiveNotificationOutboxMaxAttempts(job)
 	status := notificationOutboxStatusPending
-	nextAttemptAt := now.Add(notificationOutboxBackoff(job.AttemptCount))
+	nextAttemptAt := now.Add(loadNotificationRetryPolicy(s.DB).delayAfter(job.AttemptCount))
 	if job.AttemptCount >= maxAttempts {
 		status = notificationOutboxStatusFailed
 	}
@@ -728,7 +749,7 @@ func (s *NotificationService) releaseNotificationOutboxForRetry(job model.Notifi
 		Updates(map[string]interface{}{
 			"status":          notificationOutboxStatusPending,
 			"last_error":      sanitizeNotificationError(err.Error()),
-			"next_attempt_at": now.Add(notificationOutboxBackoff(job.AttemptCount)),
+			"next_attempt_at": now.Add(loadNotificationRetryPolicy(s.DB).delayAfter(job.AttemptCount)),
 			"locked_by":       "",
 			"locked_until":    nil,
 			"updated_at":      now,
@@ -741,16 +762,3 @@ func effectiveNotificationOutboxMaxAttempts(job model.NotificationOutbox) int {
 	}
 	return job.MaxAttempts
 }
-
-func notificationOutboxBackoff(attemptCount int) time.Duration {
-	switch {
-	case attemptCount <= 1:
-		return 15 * time.Minute
-	case attemptCount == 2:
-		return 30 * time.Minute
-	case attemptCount == 3:
-		return time.Hour
-	default:
-		return 3 * time.Hour
-	}
-}
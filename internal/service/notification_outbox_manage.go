@@ -0,0 +1,268 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/shiroha/subdux/internal/model"
+	"github.com/shiroha/subdux/internal/pkg"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrNotificationOutboxNotFound       = errors.New("notification outbox entry not found")
+	ErrNotificationOutboxNotRetryable   = errors.New("only pending, failed, cancelled or expired entries can be retried")
+	ErrNotificationOutboxNotCancellable = errors.New("only pending entries can be cancelled")
+	ErrInvalidNotificationOutboxStatus  = errors.New("status must be one of pending, processing, sent, failed, cancelled, expired")
+)
+
+var notificationOutboxStatuses = map[string]struct{}{
+	notificationOutboxStatusPending:    {},
+	notificationOutboxStatusProcessing: {},
+	notificationOutboxStatusSent:       {},
+	notificationOutboxStatusFailed:     {},
+	notificationOutboxStatusCancelled:  {},
+	notificationOutboxStatusExpired:    {},
+}
+
+// notificationOutboxTerminalStatuses are the states the dispatcher never
+// leaves on its own. Only these can be purged.
+var notificationOutboxTerminalStatuses = []string{
+	notificationOutboxStatusSent,
+	notificationOutboxStatusFailed,
+	notificationOutboxStatusCancelled,
+	notificationOutboxStatusExpired,
+}
+
+var notificationOutboxRetryableStatuses = []string{
+	notificationOutboxStatusPending,
+	notificationOutboxStatusFailed,
+	notificationOutboxStatusCancelled,
+	notificationOutboxStatusExpired,
+}
+
+// NotificationOutboxFilter narrows an outbox listing. A nil UserID lists every
+// user's entries and is only used by admin endpoints.
+type NotificationOutboxFilter struct {
+	UserID         *uint
+	Status         string
+	ChannelType    string
+	TriggerType    string
+	SubscriptionID *uint
+	Before         *time.Time
+	Limit          int
+}
+
+// NotificationOutboxPurgeFilter selects terminal entries to delete. An empty
+// Status purges every terminal status; Before limits the purge to entries last
+// updated before that time.
+type NotificationOutboxPurgeFilter struct {
+	UserID *uint
+	Status string
+	Before *time.Time
+}
+
+// NotificationOutboxDetail is an outbox entry with its delivery attempts.
+type NotificationOutboxDetail struct {
+	Entry    model.NotificationOutbox
+	Attempts []model.NotificationLog
+}
+
+func normalizeNotificationOutboxStatusFilter(value string) (string, error) {
+	status := strings.ToLower(strings.TrimSpace(value))
+	if status == "" {
+		return "", nil
+	}
+	if _, ok := notificationOutboxStatuses[status]; !ok {
+		return "", ErrInvalidNotificationOutboxStatus
+	}
+	return status, nil
+}
+
+func (s *NotificationService) ListOutbox(filter NotificationOutboxFilter) ([]model.NotificationOutbox, error) {
+	status, err := normalizeNotificationOutboxStatusFilter(filter.Status)
+	if err != nil {
+		return nil, err
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	query := s.DB.Model(&model.NotificationOutbox{})
+	if filter.UserID != nil {
+		query = query.Where("user_id = ?", *filter.UserID)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if channelType := strings.TrimSpace(filter.ChannelType); channelType != "" {
+		query = query.Where("channel_type = ?", channelType)
+	}
+	if triggerType := strings.TrimSpace(filter.TriggerType); triggerType != "" {
+		query = query.Where("trigger_type = ?", triggerType)
+	}
+	if filter.SubscriptionID != nil {
+		query = query.Where("subscription_id = ?", *filter.SubscriptionID)
+	}
+	if filter.Before != nil {
+		query = query.Where("created_at < ?", *filter.Before)
+	}
+
+	var entries []model.NotificationOutbox
+	if err := query.Order("created_at DESC, id DESC").Limit(limit).Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (s *NotificationService) GetOutboxEntry(userID *uint, id uint) (*NotificationOutboxDetail, error) {
+	entry, err := s.loadOutboxEntry(s.DB, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var attempts []model.NotificationLog
+	if err := s.DB.Where("outbox_id = ?", entry.ID).Order("sent_at ASC, id ASC").Find(&attempts).Error; err != nil {
+		return nil, err
+	}
+	return &NotificationOutboxDetail{Entry: *entry, Attempts: attempts}, nil
+}
+
+// RetryOutboxEntry makes an entry due immediately. Failed, cancelled and
+// expired entries are revived with a fresh expiry window and, when they have
+// used up their attempts, one more attempt. The dispatcher still re-checks that
+// the reminder is current before sending it.
+func (s *NotificationService) RetryOutboxEntry(userID *uint, id uint) (*model.NotificationOutbox, error) {
+	var retried *model.NotificationOutbox
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		entry, err := s.loadOutboxEntry(tx, userID, id)
+		if err != nil {
+			return err
+		}
+
+		now := pkg.NowUTC()
+		expiresAt := now.Add(notificationOutboxExpiryWindow)
+		if entry.ExpiresAt != nil && entry.ExpiresAt.After(expiresAt) {
+			expiresAt = *entry.ExpiresAt
+		}
+		maxAttempts := effectiveNotificationOutboxMaxAttempts(*entry)
+		if entry.AttemptCount >= maxAttempts {
+			maxAttempts = entry.AttemptCount + 1
+		}
+
+		result := tx.Model(&model.NotificationOutbox{}).
+			Where("id = ? AND status IN ?", entry.ID, notificationOutboxRetryableStatuses).
+			Updates(map[string]interface{}{
+				"status":          notificationOutboxStatusPending,
+				"next_attempt_at": now,
+				"expires_at":      expiresAt,
+				"max_attempts":    maxAttempts,
+				"locked_by":       "",
+				"locked_until":    nil,
+				"updated_at":      now,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotificationOutboxNotRetryable
+		}
+
+		retried, err = s.loadOutboxEntry(tx, userID, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return retried, nil
+}
+
+// CancelOutboxEntry cancels a queued entry. Entries already claimed by a
+// dispatcher are left alone so a send in flight is not reported as cancelled.
+func (s *NotificationService) CancelOutboxEntry(userID *uint, id uint, reason string) (*model.NotificationOutbox, error) {
+	var cancelled *model.NotificationOutbox
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		entry, err := s.loadOutboxEntry(tx, userID, id)
+		if err != nil {
+			return err
+		}
+
+		now := pkg.NowUTC()
+		result := tx.Model(&model.NotificationOutbox{}).
+			Where("id = ? AND status = ?", entry.ID, notificationOutboxStatusPending).
+			Updates(map[string]interface{}{
+				"status":     notificationOutboxStatusCancelled,
+				"last_error": reason,
+				"updated_at": now,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotificationOutboxNotCancellable
+		}
+
+		cancelled, err = s.loadOutboxEntry(tx, userID, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cancelled, nil
+}
+
+// PurgeOutbox deletes terminal outbox entries and reports how many were
+// removed. Pending and processing entries are never purged, and neither are
+// sent entries whose dedupe window is still open: their dedupe key is what
+// stops the next scan from queueing the same digest or card reminder again.
+// Delivery logs are kept; they reference the outbox only by id.
+func (s *NotificationService) PurgeOutbox(filter NotificationOutboxPurgeFilter) (int64, error) {
+	status, err := normalizeNotificationOutboxStatusFilter(filter.Status)
+	if err != nil {
+		return 0, err
+	}
+	statuses := notificationOutboxTerminalStatuses
+	if status != "" {
+		if status == notificationOutboxStatusPending || status == notificationOutboxStatusProcessing {
+			return 0, ErrInvalidNotificationOutboxStatus
+		}
+		statuses = []string{status}
+	}
+
+	query := s.DB.Where("status IN ?", statuses)
+	if filter.UserID != nil {
+		query = query.Where("user_id = ?", *filter.UserID)
+	}
+	if filter.Before != nil {
+		query = query.Where("updated_at < ?", *filter.Before)
+	}
+	// A card reminder is keyed to the card's expiry month rather than the
+	// delivery window, so it stays deduplicated until the card has expired.
+	now := pkg.NowUTC()
+	query = query.Where(
+		"status <> ? OR ((expires_at IS NULL OR expires_at < ?) AND (trigger_type <> ? OR notify_date < ?))",
+		notificationOutboxStatusSent, now, notificationTriggerCardExpiring, now.AddDate(0, 0, -1),
+	)
+	result := query.Delete(&model.NotificationOutbox{})
+	return result.RowsAffected, result.Error
+}
+
+func (s *NotificationService) loadOutboxEntry(db *gorm.DB, userID *uint, id uint) (*model.NotificationOutbox, error) {
+	query := db.Where("id = ?", id)
+	if userID != nil {
+		query = query.Where("user_id = ?", *userID)
+	}
+	var entry model.NotificationOutbox
+	if err := query.First(&entry).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotificationOutboxNotFound
+		}
+		return nil, err
+	}
+	return &entry, nil
+}
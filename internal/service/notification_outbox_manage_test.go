@@ -0,0 +1,245 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shiroha/subdux/internal/model"
+	"github.com/shiroha/subdux/internal/pkg"
+	"gorm.io/gorm"
+)
+
+func createManagedOutboxEntry(t *testing.T, db *gorm.DB, userID uint, key, status string, updatedAt time.Time) model.NotificationOutbox {
+	t.Helper()
+
+	entry := model.NotificationOutbox{
+		DedupeKey:     key,
+		UserID:        userID,
+		ChannelType:   "webhook",
+		TriggerType:   notificationTriggerDaysBefore,
+		NotifyDate:    updatedAt,
+		ScheduledFor:  updatedAt,
+		Status:        status,
+		AttemptCount:  0,
+		MaxAttempts:   5,
+		NextAttemptAt: updatedAt,
+		Message:       "queued",
+		CreatedAt:     updatedAt,
+		UpdatedAt:     updatedAt,
+	}
+	if err := db.Create(&entry).Error; err != nil {
+		t.Fatalf("failed to create outbox entry: %v", err)
+	}
+	return entry
+}
+
+func TestListOutboxScopesToUserAndFiltersStatus(t *testing.T) {
+	db := newNotificationOutboxTestDB(t)
+	service := &NotificationService{DB: db}
+	owner := createNotificationOutboxUser(t, db)
+	other := model.User{Username: "other", Email: "other@example.com", Password: "x", Role: "user", Status: "active"}
+	if err := db.Create(&other).Error; err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+
+	base := time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC)
+	createManagedOutboxEntry(t, db, owner.ID, "a", notificationOutboxStatusPending, base)
+	createManagedOutboxEntry(t, db, owner.ID, "b", notificationOutboxStatusFailed, base.Add(time.Hour))
+	createManagedOutboxEntry(t, db, other.ID, "c", notificationOutboxStatusFailed, base)
+
+	entries, err := service.ListOutbox(NotificationOutboxFilter{UserID: &owner.ID})
+	if err != nil {
+		t.Fatalf("ListOutbox() error = %v", err)
+	}
+	if len(entries) != 2 || entries[0].DedupeKey != "b" {
+		t.Fatalf("ListOutbox() = %+v, want owner's two entries newest first", entries)
+	}
+
+	failed, err := service.ListOutbox(NotificationOutboxFilter{Status: "FAILED"})
+	if err != nil {
+		t.Fatalf("ListOutbox() error = %v", err)
+	}
+	if len(failed) != 2 {
+		t.Fatalf("ListOutbox(status=failed) returned %d entries, want 2", len(failed))
+	}
+
+	if _, err := service.ListOutbox(NotificationOutboxFilter{Status: "bogus"}); !errors.Is(err, ErrInvalidNotificationOutboxStatus) {
+		t.Fatalf("ListOutbox(status=bogus) error = %v, want ErrInvalidNotificationOutboxStatus", err)
+	}
+
+	if _, err := service.GetOutboxEntry(&other.ID, entries[0].ID); !errors.Is(err, ErrNotificationOutboxNotFound) {
+		t.Fatalf("GetOutboxEntry() for another user error = %v, want ErrNotificationOutboxNotFound", err)
+	}
+}
+
+func TestRetryOutboxEntryRevivesExhaustedFailure(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	restore := pkg.SetNowForTest(now)
+	defer restore()
+
+	db := newNotificationOutboxTestDB(t)
+	service := &NotificationService{DB: db}
+	user := createNotificationOutboxUser(t, db)
+	entry := createManagedOutboxEntry(t, db, user.ID, "failed", notificationOutboxStatusFailed, now.Add(-48*time.Hour))
+	if err := db.Model(&entry).Updates(map[string]interface{}{"attempt_count": 5, "expires_at": now.Add(-time.Hour)}).Error; err != nil {
+		t.Fatalf("failed to update entry: %v", err)
+	}
+
+	retried, err := service.RetryOutboxEntry(&user.ID, entry.ID)
+	if err != nil {
+		t.Fatalf("RetryOutboxEntry() error = %v", err)
+	}
+	if retried.Status != notificationOutboxStatusPending {
+		t.Fatalf("status = %q, want pending", retried.Status)
+	}
+	if retried.MaxAttempts != 6 {
+		t.Fatalf("max_attempts = %d, want 6", retried.MaxAttempts)
+	}
+	if !retried.NextAttemptAt.Equal(now) {
+		t.Fatalf("next_attempt_at = %v, want %v", retried.NextAttemptAt, now)
+	}
+	if retried.ExpiresAt == nil || !retried.ExpiresAt.After(now) {
+		t.Fatalf("expires_at = %v, want a time after %v", retried.ExpiresAt, now)
+	}
+
+	processing := createManagedOutboxEntry(t, db, user.ID, "processing", notificationOutboxStatusProcessing, now)
+	if _, err := service.RetryOutboxEntry(&user.ID, processing.ID); !errors.Is(err, ErrNotificationOutboxNotRetryable) {
+		t.Fatalf("RetryOutboxEntry(processing) error = %v, want ErrNotificationOutboxNotRetryable", err)
+	}
+}
+
+func TestCancelOutboxEntryOnlyCancelsPending(t *testing.T) {
+	db := newNotificationOutboxTestDB(t)
+	service := &NotificationService{DB: db}
+	user := createNotificationOutboxUser(t, db)
+	base := time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC)
+	pending := createManagedOutboxEntry(t, db, user.ID, "pending", notificationOutboxStatusPending, base)
+	sent := createManagedOutboxEntry(t, db, user.ID, "sent", notificationOutboxStatusSent, base)
+
+	cancelled, err := service.CancelOutboxEntry(&user.ID, pending.ID, "cancelled by user")
+	if err != nil {
+		t.Fatalf("CancelOutboxEntry() error = %v", err)
+	}
+	if cancelled.Status != notificationOutboxStatusCancelled || cancelled.LastError != "cancelled by user" {
+		t.Fatalf("cancelled entry = %+v", cancelled)
+	}
+
+	if _, err := service.CancelOutboxEntry(&user.ID, sent.ID, "cancelled by user"); !errors.Is(err, ErrNotificationOutboxNotCancellable) {
+		t.Fatalf("CancelOutboxEntry(sent) error = %v, want ErrNotificationOutboxNotCancellable", err)
+	}
+}
+
+func TestPurgeOutboxRemovesOnlyTerminalEntries(t *testing.T) {
+	db := newNotificationOutboxTestDB(t)
+	service := &NotificationService{DB: db}
+	user := createNotificationOutboxUser(t, db)
+	old := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	recent := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	createManagedOutboxEntry(t, db, user.ID, "pending", notificationOutboxStatusPending, old)
+	createManagedOutboxEntry(t, db, user.ID, "sent-old", notificationOutboxStatusSent, old)
+	createManagedOutboxEntry(t, db, user.ID, "failed-old", notificationOutboxStatusFailed, old)
+	createManagedOutboxEntry(t, db, user.ID, "sent-recent", notificationOutboxStatusSent, recent)
+
+	if _, err := service.PurgeOutbox(NotificationOutboxPurgeFilter{Status: notificationOutboxStatusPending}); !errors.Is(err, ErrInvalidNotificationOutboxStatus) {
+		t.Fatalf("PurgeOutbox(pending) error = %v, want ErrInvalidNotificationOutboxStatus", err)
+	}
+
+	cutoff := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	purged, err := service.PurgeOutbox(NotificationOutboxPurgeFilter{UserID: &user.ID, Before: &cutoff})
+	if err != nil {
+		t.Fatalf("PurgeOutbox() error = %v", err)
+	}
+	if purged != 2 {
+		t.Fatalf("PurgeOutbox() purged %d, want 2", purged)
+	}
+
+	var remaining []string
+	if err := db.Model(&model.NotificationOutbox{}).Order("dedupe_key").Pluck("dedupe_key", &remaining).Error; err != nil {
+		t.Fatalf("failed to load remaining entries: %v", err)
+	}
+	if len(remaining) != 2 || remaining[0] != "pending" || remaining[1] != "sent-recent" {
+		t.Fatalf("remaining entries = %v, want [pending sent-recent]", remaining)
+	}
+}
+
+func TestPurgeOutboxKeepsCardReminderUntilCardExpires(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	restore := pkg.SetNowForTest(now)
+	defer restore()
+
+	db := newNotificationOutboxTestDB(t)
+	service := &NotificationService{DB: db}
+	user := createNotificationOutboxUser(t, db)
+	for key, expiresOn := range map[string]time.Time{
+		"card-open":    time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC),
+		"card-expired": time.Date(2026, 2, 28, 0, 0, 0, 0, time.UTC),
+	} {
+		entry := createManagedOutboxEntry(t, db, user.ID, key, notificationOutboxStatusSent, now.Add(-72*time.Hour))
+		if err := db.Model(&entry).Updates(map[string]interface{}{
+			"trigger_type": notificationTriggerCardExpiring,
+			"notify_date":  expiresOn,
+			"expires_at":   now.Add(-36 * time.Hour),
+		}).Error; err != nil {
+			t.Fatalf("failed to update entry: %v", err)
+		}
+	}
+
+	purged, err := service.PurgeOutbox(NotificationOutboxPurgeFilter{UserID: &user.ID})
+	if err != nil {
+		t.Fatalf("PurgeOutbox() error = %v", err)
+	}
+	var remaining []string
+	if err := db.Model(&model.NotificationOutbox{}).Pluck("dedupe_key", &remaining).Error; err != nil {
+		t.Fatalf("failed to load remaining entries: %v", err)
+	}
+	if purged != 1 || len(remaining) != 1 || remaining[0] != "card-open" {
+		t.Fatalf("PurgeOutbox() purged %d, remaining %v; want only the expired card's reminder purged", purged, remaining)
+	}
+}
+
+func TestNotificationRetryPolicyUsesConfiguredBackoff(t *testing.T) {
+	db := newNotificationOutboxTestDB(t)
+
+	policy := loadNotificationRetryPolicy(db)
+	if policy.maxAttempts != notificationOutboxDefaultMaxAttempts {
+		t.Fatalf("default maxAttempts = %d, want %d", policy.maxAttempts, notificationOutboxDefaultMaxAttempts)
+	}
+	if got := policy.delayAfter(1); got != 15*time.Minute {
+		t.Fatalf("default delayAfter(1) = %v, want 15m", got)
+	}
+
+	maxAttempts := int64(3)
+	backoff := " 5, 10 "
+	if err := applyNotificationRetrySettings(db, UpdateSettingsInput{
+		NotificationRetryMaxAttempts:    &maxAttempts,
+		NotificationRetryBackoffMinutes: &backoff,
+	}); err != nil {
+		t.Fatalf("applyNotificationRetrySettings() error = %v", err)
+	}
+
+	policy = loadNotificationRetryPolicy(db)
+	if policy.maxAttempts != 3 {
+		t.Fatalf("maxAttempts = %d, want 3", policy.maxAttempts)
+	}
+	if got := policy.delayAfter(1); got != 5*time.Minute {
+		t.Fatalf("delayAfter(1) = %v, want 5m", got)
+	}
+	if got := policy.delayAfter(4); got != 10*time.Minute {
+		t.Fatalf("delayAfter(4) = %v, want last step 10m", got)
+	}
+
+	stored, err := getSystemSettingValue(db, notificationRetryBackoffMinutesKey, "")
+	if err != nil || stored != "5,10" {
+		t.Fatalf("stored backoff = %q, %v; want \"5,10\"", stored, err)
+	}
+
+	invalid := "0,30"
+	if err := applyNotificationRetrySettings(db, UpdateSettingsInput{NotificationRetryBackoffMinutes: &invalid}); !errors.Is(err, ErrInvalidNotificationRetryBackoff) {
+		t.Fatalf("applyNotificationRetrySettings(0,30) error = %v, want ErrInvalidNotificationRetryBackoff", err)
+	}
+	tooMany := int64(21)
+	if err := applyNotificationRetrySettings(db, UpdateSettingsInput{NotificationRetryMaxAttempts: &tooMany}); !errors.Is(err, ErrInvalidNotificationRetryMaxAttempts) {
+		t.Fatalf("applyNotificationRetrySettings(21) error = %v, want ErrInvalidNotificationRetryMaxAttempts", err)
+	}
+}
@@ -26,8 +26,14 @@ func newNotificationOutboxTestDB(t *testing.T) *gorm.DB {
 	if err := db.AutoMigrate(
 		&model.User{},
 		&model.SystemSetting{},
+		&model.PaymentMethod{},
 		&model.Subscription{},
 		&model.SubscriptionEvent{},
+		&model.SubscriptionPriceChange{},
+		&model.SubscriptionUsage{},
+		&model.CustomFieldDefinition{},
+		&model.SubscriptionCustomFieldValue{},
+		&model.SubscriptionAttachment{},
 		&model.NotificationChannel{},
 		&model.NotificationPolicy{},
 		&model.NotificationTemplate{},
@@ -147,7 +153,7 @@ func TestEnqueuePendingNotificationsCreatesTriggerSpecificOutboxJobs(t *testing.
 	if job.Status != notificationOutboxStatusPending {
 		t.Fatalf("status = %q, want %q", job.Status, notificationOutboxStatusPending)
 	}
-	if job.DedupeKey != notificationOutboxDedupeKey(user.ID, job.SubscriptionID, "webhook", notificationTriggerDueDay, notifyDate) {
+	if job.DedupeKey != notificationOutboxDedupeKey(user.ID, *job.SubscriptionID, "webhook", notificationTriggerDueDay, notifyDate) {
 		t.Fatalf("unexpected dedupe key %q", job.DedupeKey)
 	}
 }
@@ -895,7 +901,7 @@ func TestClaimDueNotificationOutboxIsOwnerExclusive(t *testing.T) {
 	job := model.NotificationOutbox{
 		DedupeKey:      "claim-test",
 		UserID:         user.ID,
-		SubscriptionID: sub.ID,
+		SubscriptionID: ptrUint(sub.ID),
 		ChannelType:    "webhook",
 		TriggerType:    notificationTriggerDueDay,
 		NotifyDate:     notifyDate,
@@ -953,7 +959,7 @@ func TestDispatchNotificationOutboxSuccessWritesLogAndMarksSent(t *testing.T) {
 	job := model.NotificationOutbox{
 		DedupeKey:      "dispatch-success",
 		UserID:         user.ID,
-		SubscriptionID: sub.ID,
+		SubscriptionID: ptrUint(sub.ID),
 		ChannelID:      &channel.ID,
 		ChannelType:    channel.Type,
 		TriggerType:    notificationTriggerDueDay,
@@ -1077,7 +1083,7 @@ func TestDispatchNotificationOutboxCancelsDeletedChannelWithoutFallback(t *testi
 	job := model.NotificationOutbox{
 		DedupeKey:      "deleted-channel-no-fallback",
 		UserID:         user.ID,
-		SubscriptionID: sub.ID,
+		SubscriptionID: ptrUint(sub.ID),
 		ChannelID:      &deletedChannel.ID,
 		ChannelType:    deletedChannel.Type,
 		TriggerType:    notificationTriggerDueDay,
@@ -1137,7 +1143,7 @@ func TestDispatchNotificationOutboxCancelsWhenQueuedBillingDateIsStale(t *testin
 	job := model.NotificationOutbox{
 		DedupeKey:      "stale-billing-date",
 		UserID:         user.ID,
-		SubscriptionID: sub.ID,
+		SubscriptionID: ptrUint(sub.ID),
 		ChannelID:      &channel.ID,
 		ChannelType:    channel.Type,
 		TriggerType:    notificationTriggerDueDay,
@@ -1205,7 +1211,7 @@ func TestDispatchNotificationOutboxCancelsWhenQueuedTriggerNoLongerMatches(t *te
 	job := model.NotificationOutbox{
 		DedupeKey:      "stale-trigger",
 		UserID:         user.ID,
-		SubscriptionID: sub.ID,
+		SubscriptionID: ptrUint(sub.ID),
 		ChannelID:      &channel.ID,
 		ChannelType:    channel.Type,
 		TriggerType:    notificationTriggerDaysBefore,
@@ -1276,7 +1282,7 @@ func TestDispatchNotificationOutboxCancelsEndingSoonWhenRenewalModeChanges(t *te
 	job := model.NotificationOutbox{
 		DedupeKey:      notificationOutboxDedupeKeyForTrigger(user.ID, sub.ID, channel.Type, notificationTriggerEndingSoon, endDate, now),
 		UserID:         user.ID,
-		SubscriptionID: sub.ID,
+		SubscriptionID: ptrUint(sub.ID),
 		ChannelID:      &channel.ID,
 		ChannelType:    channel.Type,
 		TriggerType:    notificationTriggerEndingSoon,
@@ -1348,7 +1354,7 @@ func TestDispatchNotificationOutboxCancelsEndingSoonWhenDateChanges(t *testing.T
 	job := model.NotificationOutbox{
 		DedupeKey:      notificationOutboxDedupeKeyForTrigger(user.ID, sub.ID, channel.Type, notificationTriggerEndingSoon, endDate, now),
 		UserID:         user.ID,
-		SubscriptionID: sub.ID,
+		SubscriptionID: ptrUint(sub.ID),
 		ChannelID:      &channel.ID,
 		ChannelType:    channel.Type,
 		TriggerType:    notificationTriggerEndingSoon,
@@ -1418,7 +1424,7 @@ func TestDispatchNotificationOutboxCancelsEndingSoonWhenSubscriptionEnded(t *tes
 	job := model.NotificationOutbox{
 		DedupeKey:      notificationOutboxDedupeKeyForTrigger(user.ID, sub.ID, channel.Type, notificationTriggerEndingSoon, endDate, now),
 		UserID:         user.ID,
-		SubscriptionID: sub.ID,
+		SubscriptionID: ptrUint(sub.ID),
 		ChannelID:      &channel.ID,
 		ChannelType:    channel.Type,
 		TriggerType:    notificationTriggerEndingSoon,
@@ -1491,7 +1497,7 @@ func TestDispatchNotificationOutboxCancelsStaleEndingSoonJob(t *testing.T) {
 	job := model.NotificationOutbox{
 		DedupeKey:      notificationOutboxDedupeKeyForTrigger(user.ID, sub.ID, channel.Type, notificationTriggerEndingSoon, endDate, scheduledAt),
 		UserID:         user.ID,
-		SubscriptionID: sub.ID,
+		SubscriptionID: ptrUint(sub.ID),
 		ChannelID:      &channel.ID,
 		ChannelType:    channel.Type,
 		TriggerType:    notificationTriggerEndingSoon,
@@ -1557,7 +1563,7 @@ func TestDispatchNotificationOutboxCancelsManualRenewDailyWhenPreferenceDisabled
 	job := model.NotificationOutbox{
 		DedupeKey:      notificationOutboxDedupeKeyForTrigger(user.ID, sub.ID, channel.Type, notificationTriggerManualDaily, notifyDate, now),
 		UserID:         user.ID,
-		SubscriptionID: sub.ID,
+		SubscriptionID: ptrUint(sub.ID),
 		ChannelID:      &channel.ID,
 		ChannelType:    channel.Type,
 		TriggerType:    notificationTriggerManualDaily,
@@ -1624,7 +1630,7 @@ func TestDispatchNotificationOutboxCancelsStaleManualRenewDailyJob(t *testing.T)
 	job := model.NotificationOutbox{
 		DedupeKey:      notificationOutboxDedupeKeyForTrigger(user.ID, sub.ID, channel.Type, notificationTriggerManualDaily, notifyDate, scheduledAt),
 		UserID:         user.ID,
-		SubscriptionID: sub.ID,
+		SubscriptionID: ptrUint(sub.ID),
 		ChannelID:      &channel.ID,
 		ChannelType:    channel.Type,
 		TriggerType:    notificationTriggerManualDaily,
@@ -1724,7 +1730,7 @@ func TestDispatchNotificationOutboxCancelsStaleManualRenewEndedJob(t *testing.T)
 			job := model.NotificationOutbox{
 				DedupeKey:      notificationOutboxDedupeKey(user.ID, sub.ID, channel.Type, notificationTriggerManualEnded, endedAt),
 				UserID:         user.ID,
-				SubscriptionID: sub.ID,
+				SubscriptionID: ptrUint(sub.ID),
 				ChannelID:      &channel.ID,
 				ChannelType:    channel.Type,
 				TriggerType:    notificationTriggerManualEnded,
@@ -1772,7 +1778,7 @@ func TestDispatchNotificationOutboxRetriesThenFails(t *testing.T) {
 	job := model.NotificationOutbox{
 		DedupeKey:      "dispatch-failure",
 		UserID:         user.ID,
-		SubscriptionID: sub.ID,
+		SubscriptionID: ptrUint(sub.ID),
 		ChannelID:      &channel.ID,
 		ChannelType:    channel.Type,
 		TriggerType:    notificationTriggerDueDay,
@@ -1829,7 +1835,7 @@ func TestDispatchNotificationOutboxReclaimsExpiredProcessingLease(t *testing.T)
 	job := model.NotificationOutbox{
 		DedupeKey:      "expired-processing-lease",
 		UserID:         user.ID,
-		SubscriptionID: sub.ID,
+		SubscriptionID: ptrUint(sub.ID),
 		ChannelType:    "webhook",
 		TriggerType:    notificationTriggerDueDay,
 		NotifyDate:     notifyDate,
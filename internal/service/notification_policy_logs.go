@@ -3,6 +3,7 @@ package service
 import (
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/shiroha/subdux/internal/model"
 	"gorm.io/gorm"
@@ -17,6 +18,7 @@ func (s *NotificationService) GetPolicy(userID uint) (*model.NotificationPolicy,
 				DaysBefore:             3,
 				NotifyOnDueDay:         true,
 				NotifyManualRenewDaily: false,
+				DigestFrequency:        notificationDigestOff,
 			}, nil
 		}
 		return nil, err
@@ -37,6 +39,7 @@ func (s *NotificationService) UpdatePolicy(userID uint, input UpdatePolicyInput)
 			DaysBefore:             3,
 			NotifyOnDueDay:         true,
 			NotifyManualRenewDaily: false,
+			DigestFrequency:        notificationDigestOff,
 		}
 	}
 
@@ -52,6 +55,16 @@ func (s *NotificationService) UpdatePolicy(userID uint, input UpdatePolicyInput)
 	if input.NotifyManualRenewDaily != nil {
 		policy.NotifyManualRenewDaily = *input.NotifyManualRenewDaily
 	}
+	if input.DigestFrequency != nil {
+		frequency := strings.ToLower(strings.TrimSpace(*input.DigestFrequency))
+		if !isValidNotificationDigestFrequency(frequency) {
+			return nil, errors.New("digest_frequency must be one of off, daily, weekly or monthly")
+		}
+		policy.DigestFrequency = frequency
+	}
+	if input.DigestOnly != nil {
+		policy.DigestOnly = *input.DigestOnly
+	}
 
 	if policy.ID == 0 {
 		if err := s.DB.Model(&model.NotificationPolicy{}).Create(map[string]interface{}{
@@ -59,6 +72,8 @@ func (s *NotificationService) UpdatePolicy(userID uint, input UpdatePolicyInput)
 			"days_before":               policy.DaysBefore,
 			"notify_on_due_day":         policy.NotifyOnDueDay,
 			"notify_manual_renew_daily": policy.NotifyManualRenewDaily,
+			"digest_frequency":          policy.DigestFrequency,
+			"digest_only":               policy.DigestOnly,
 		}).Error; err != nil {
 			return nil, err
 		}
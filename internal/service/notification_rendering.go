@@ -1,7 +1,6 @@
 package service
 
 import (
-	"errors"
 	"fmt"
 	"time"
 
@@ -51,18 +50,24 @@ func (s *NotificationService) buildTemplateData(
 		URL:              sub.URL,
 		Remark:           sub.Notes,
 		UserEmail:        user.Email,
+		Locale:           loadNotificationLocale(s.DB, sub.UserID),
+		CustomFields:     customFieldTemplateVariables(s.DB, *sub),
 	}
 }
 
+// renderNotificationMessage renders the user's template for the channel. When
+// the user has no template, or only the untouched seeded default, the built-in
+// template for their locale and the notification category is used instead.
 func (s *NotificationService) renderNotificationMessage(userID uint, channelType string, templateData TemplateData) (string, error) {
-	template, err := s.templateService.GetTemplateForChannel(userID, channelType)
+	template, err := s.templateService.findTemplateForChannel(userID, channelType, notificationTemplateKindReminder)
 	if err != nil {
 		return "", fmt.Errorf("failed to get template: %w", err)
 	}
-	if template == nil {
-		return "", errors.New("no template found for channel")
+	templateText := builtinNotificationTemplate(templateData.Locale, notificationTemplateCategory(templateData))
+	if template != nil && !isSeededDefaultNotificationTemplate(template) {
+		templateText = template.Template
 	}
-	message, err := s.templateRenderer.RenderTemplate(template.Template, templateData)
+	message, err := s.templateRenderer.RenderTemplate(templateText, templateData)
 	if err != nil {
 		return "", fmt.Errorf("failed to render template: %w", err)
 	}
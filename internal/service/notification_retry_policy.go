@@ -0,0 +1,136 @@
+package service
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	notificationRetryMaxAttemptsKey    = "notification_retry_max_attempts"
+	notificationRetryBackoffMinutesKey = "notification_retry_backoff_minutes"
+
+	defaultNotificationRetryBackoffMinutes = "15,30,60,180"
+	maxNotificationRetryAttempts           = 20
+	maxNotificationRetryBackoffSteps       = 10
+	maxNotificationRetryBackoffMinutes     = 7 * 24 * 60
+)
+
+var (
+	ErrInvalidNotificationRetryMaxAttempts = errors.New("notification retry max attempts must be between 1 and 20")
+	ErrInvalidNotificationRetryBackoff     = errors.New("notification retry backoff must be 1 to 10 comma-separated minute values between 1 and 10080")
+)
+
+// notificationRetryPolicy controls how failed outbox deliveries are retried.
+// backoff[i] is the delay after the (i+1)th failed attempt; the last step
+// repeats for any further attempts.
+type notificationRetryPolicy struct {
+	maxAttempts int
+	backoff     []time.Duration
+}
+
+func defaultNotificationRetryPolicy() notificationRetryPolicy {
+	backoff, _ := parseNotificationRetryBackoff(defaultNotificationRetryBackoffMinutes)
+	return notificationRetryPolicy{
+		maxAttempts: notificationOutboxDefaultMaxAttempts,
+		backoff:     backoff,
+	}
+}
+
+// loadNotificationRetryPolicy reads the admin-configured retry policy. Missing
+// or unreadable settings fall back to the defaults so a settings problem never
+// stops delivery.
+func loadNotificationRetryPolicy(db *gorm.DB) notificationRetryPolicy {
+	policy := defaultNotificationRetryPolicy()
+
+	if raw, err := getSystemSettingValue(db, notificationRetryMaxAttemptsKey, ""); err == nil {
+		if maxAttempts, err := normalizeNotificationRetryMaxAttempts(raw); err == nil {
+			policy.maxAttempts = maxAttempts
+		}
+	}
+	if raw, err := getSystemSettingValue(db, notificationRetryBackoffMinutesKey, ""); err == nil {
+		if backoff, err := parseNotificationRetryBackoff(raw); err == nil {
+			policy.backoff = backoff
+		}
+	}
+	return policy
+}
+
+func (p notificationRetryPolicy) delayAfter(attemptCount int) time.Duration {
+	if len(p.backoff) == 0 {
+		return 15 * time.Minute
+	}
+	index := attemptCount - 1
+	if index < 0 {
+		index = 0
+	}
+	if index >= len(p.backoff) {
+		index = len(p.backoff) - 1
+	}
+	return p.backoff[index]
+}
+
+func normalizeNotificationRetryMaxAttempts(raw string) (int, error) {
+	value, err := strconv.Atoi(strings.TrimSpace(raw))
+	if err != nil || value < 1 || value > maxNotificationRetryAttempts {
+		return 0, ErrInvalidNotificationRetryMaxAttempts
+	}
+	return value, nil
+}
+
+func parseNotificationRetryBackoff(raw string) ([]time.Duration, error) {
+	parts := strings.Split(raw, ",")
+	if len(parts) == 0 || len(parts) > maxNotificationRetryBackoffSteps {
+		return nil, ErrInvalidNotificationRetryBackoff
+	}
+	backoff := make([]time.Duration, 0, len(parts))
+	for _, part := range parts {
+		minutes, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || minutes < 1 || minutes > maxNotificationRetryBackoffMinutes {
+			return nil, ErrInvalidNotificationRetryBackoff
+		}
+		backoff = append(backoff, time.Duration(minutes)*time.Minute)
+	}
+	return backoff, nil
+}
+
+// normalizeNotificationRetryBackoff validates a backoff schedule and returns it
+// in canonical "15,30,60" form.
+func normalizeNotificationRetryBackoff(raw string) (string, error) {
+	backoff, err := parseNotificationRetryBackoff(raw)
+	if err != nil {
+		return "", err
+	}
+	parts := make([]string, len(backoff))
+	for i, delay := range backoff {
+		parts[i] = strconv.Itoa(int(delay / time.Minute))
+	}
+	return strings.Join(parts, ","), nil
+}
+
+func applyNotificationRetrySettings(tx *gorm.DB, input UpdateSettingsInput) error {
+	if input.NotificationRetryMaxAttempts != nil {
+		maxAttempts, err := normalizeNotificationRetryMaxAttempts(strconv.FormatInt(*input.NotificationRetryMaxAttempts, 10))
+		if err != nil {
+			return err
+		}
+		if err := saveStringSystemSetting(tx, notificationRetryMaxAttemptsKey, strconv.Itoa(maxAttempts)); err != nil {
+			return err
+		}
+	}
+
+	if input.NotificationRetryBackoffMinutes != nil {
+		backoff, err := normalizeNotificationRetryBackoff(*input.NotificationRetryBackoffMinutes)
+		if err != nil {
+			return err
+		}
+		if err := saveStringSystemSetting(tx, notificationRetryBackoffMinutesKey, backoff); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
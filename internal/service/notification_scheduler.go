@@ -106,7 +106,7 @@ func notificationDispatchWorkerCount(jobCount int) int {
 }
 
 func (s *NotificationService) processUserNotifications(userID uint) error {
-	now := pkg.NowInSystemTimezone()
+	now := userNow(s.DB, userID)
 	if err := reconcileSubscriptionLifecycleForUser(s.DB, userID, now); err != nil {
 		return err
 	}
@@ -136,7 +136,7 @@ func (s *NotificationService) processUserNotifications(userID uint) error {
 		return err
 	}
 
-	systemLoc := pkg.GetSystemTimezone()
+	loc := now.Location()
 	scheduledDispatches := make(map[string]struct{})
 
 	endedManualRenewSubs, err := s.manualRenewEndedNotificationCandidates(userID, now)
@@ -156,7 +156,7 @@ func (s *NotificationService) processUserNotifications(userID uint) error {
 			continue
 		}
 
-		endedAt := pkg.NormalizeDateInTimezone(*sub.EndsAt, systemLoc)
+		endedAt := pkg.CalendarDateInTimezone(*sub.EndsAt, loc)
 		for _, channel := range enabledChannels {
 			if !shouldScheduleNotificationOutbox(scheduledDispatches, sub.ID, channel.Type, notificationTriggerManualEnded, endedAt, endedAt) {
 				continue
@@ -187,6 +187,17 @@ func (s *NotificationService) processUserNotifications(userID uint) error {
 		}
 	}
 
+	if err := s.enqueuePaymentMethodExpiryReminders(userID, &user, enabledChannels, now); err != nil {
+		return err
+	}
+
+	if err := s.enqueueNotificationDigest(userID, policy, &user, subs, enabledChannels, now); err != nil {
+		return err
+	}
+	if notificationDigestReplacesReminders(policy) {
+		return nil
+	}
+
 	for _, sub := range subs {
 		if normalizeRenewalMode(sub.RenewalMode) != renewalModeCancelAtPeriodEnd {
 			continue
@@ -211,9 +222,9 @@ func (s *NotificationService) processUserNotifications(userID uint) error {
 			daysBefore = *sub.NotifyDaysBefore
 		}
 
-		endDate := pkg.NormalizeDateInTimezone(*boundary, systemLoc)
-		scanDate := pkg.NormalizeDateInTimezone(now, systemLoc)
-		daysUntilEnd := pkg.DaysUntil(endDate, systemLoc)
+		endDate := pkg.CalendarDateInTimezone(*boundary, loc)
+		scanDate := pkg.NormalizeDateInTimezone(now, loc)
+		daysUntilEnd := pkg.DaysUntilFrom(now, endDate, loc)
 		triggerTypes := notificationTriggerTypes(daysUntilEnd, daysBefore, notifyOnDueDay)
 		if len(triggerTypes) == 0 {
 			continue
@@ -268,10 +279,10 @@ func (s *NotificationService) processUserNotifications(userID uint) error {
 			daysBefore = *sub.NotifyDaysBefore
 		}
 
-		billingDate := pkg.NormalizeDateInTimezone(*sub.NextBillingDate, systemLoc)
-		scanDate := pkg.NormalizeDateInTimezone(now, systemLoc)
+		billingDate := pkg.CalendarDateInTimezone(*sub.NextBillingDate, loc)
+		scanDate := pkg.NormalizeDateInTimezone(now, loc)
 
-		daysUntilBilling := pkg.DaysUntil(*sub.NextBillingDate, systemLoc)
+		daysUntilBilling := pkg.DaysUntilFrom(now, billingDate, loc)
 		triggerTypes := notificationTriggerTypesForSubscription(
 			sub.RenewalMode,
 			daysUntilBilling,
@@ -24,6 +24,7 @@ func NewNotificationTemplateService(db *gorm.DB, validator *TemplateValidator) *
 
 type CreateTemplateInput struct {
 	ChannelType *string `json:"channel_type"`
+	Kind        string  `json:"kind"`
 	Format      string  `json:"format"`
 	Template    string  `json:"template"`
 }
@@ -36,7 +37,7 @@ type UpdateTemplateInput struct {
 // ListTemplates returns all templates for a user
 func (s *NotificationTemplateService) ListTemplates(userID uint) ([]model.NotificationTemplate, error) {
 	var templates []model.NotificationTemplate
-	err := s.DB.Where("user_id = ?", userID).Order("channel_type ASC NULLS FIRST").Find(&templates).Error
+	err := s.DB.Where("user_id = ?", userID).Order("kind DESC, channel_type ASC NULLS FIRST").Find(&templates).Error
 	return templates, err
 }
 
@@ -59,7 +60,8 @@ func (s *NotificationTemplateService) CreateTemplate(userID uint, input CreateTe
 		return nil, err
 	}
 
-	if err := s.validator.ValidateTemplate(input.Template); err != nil {
+	kind := normalizeNotificationTemplateKind(input.Kind)
+	if err := s.validator.ValidateTemplateForKind(kind, input.Template); err != nil {
 		return nil, err
 	}
 
@@ -72,7 +74,7 @@ func (s *NotificationTemplateService) CreateTemplate(userID uint, input CreateTe
 	}
 
 	var count int64
-	query := s.DB.Model(&model.NotificationTemplate{}).Where("user_id = ?", userID)
+	query := s.DB.Model(&model.NotificationTemplate{}).Where("user_id = ? AND kind = ?", userID, kind)
 	if input.ChannelType == nil {
 		query = query.Where("channel_type IS NULL")
 	} else {
@@ -89,6 +91,7 @@ func (s *NotificationTemplateService) CreateTemplate(userID uint, input CreateTe
 	tmpl := model.NotificationTemplate{
 		UserID:      userID,
 		ChannelType: input.ChannelType,
+		Kind:        kind,
 		Format:      format,
 		Template:    input.Template,
 	}
@@ -121,7 +124,7 @@ func (s *NotificationTemplateService) UpdateTemplate(userID, templateID uint, in
 	}
 
 	if input.Template != nil {
-		if err := s.validator.ValidateTemplate(*input.Template); err != nil {
+		if err := s.validator.ValidateTemplateForKind(normalizeNotificationTemplateKind(tmpl.Kind), *input.Template); err != nil {
 			return nil, err
 		}
 		updates["template"] = *input.Template
@@ -152,18 +155,35 @@ func (s *NotificationTemplateService) DeleteTemplate(userID, templateID uint) er
 	return nil
 }
 
-// GetTemplateForChannel retrieves template for specific channel (with fallback to default)
+// GetTemplateForChannel retrieves the reminder template for specific channel (with fallback to default)
 func (s *NotificationTemplateService) GetTemplateForChannel(userID uint, channelType string) (*model.NotificationTemplate, error) {
+	tmpl, err := s.findTemplateForChannel(userID, channelType, notificationTemplateKindReminder)
+	if err != nil {
+		return nil, err
+	}
+	if tmpl == nil {
+		return nil, errors.New("no template configured (default or channel-specific)")
+	}
+	return tmpl, nil
+}
+
+// findTemplateForChannel looks up the channel-specific template of the given
+// kind, falling back to the user's default of that kind. It returns nil when
+// neither exists.
+func (s *NotificationTemplateService) findTemplateForChannel(userID uint, channelType, kind string) (*model.NotificationTemplate, error) {
 	var tmpl model.NotificationTemplate
-	err := s.DB.Where("user_id = ? AND channel_type = ?", userID, channelType).First(&tmpl).Error
+	err := s.DB.Where("user_id = ? AND kind = ? AND channel_type = ?", userID, kind, channelType).First(&tmpl).Error
 	if err == nil {
 		return &tmpl, nil
 	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
 
-	err = s.DB.Where("user_id = ? AND channel_type IS NULL", userID).First(&tmpl).Error
+	err = s.DB.Where("user_id = ? AND kind = ? AND channel_type IS NULL", userID, kind).First(&tmpl).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("no template configured (default or channel-specific)")
+			return nil, nil
 		}
 		return nil, err
 	}
@@ -171,17 +191,33 @@ func (s *NotificationTemplateService) GetTemplateForChannel(userID uint, channel
 	return &tmpl, nil
 }
 
+func normalizeNotificationTemplateKind(kind string) string {
+	kind = strings.ToLower(strings.TrimSpace(kind))
+	if kind == "" {
+		return notificationTemplateKindReminder
+	}
+	return kind
+}
+
 // PreviewTemplate renders a template with user's first subscription data when available.
 func (s *NotificationTemplateService) PreviewTemplate(userID uint, input CreateTemplateInput) (string, error) {
 	format := strings.ToLower(strings.TrimSpace(input.Format))
 	if err := s.validator.ValidateFormat(format); err != nil {
 		return "", err
 	}
-	if err := s.validator.ValidateTemplate(input.Template); err != nil {
+	kind := normalizeNotificationTemplateKind(input.Kind)
+	if err := s.validator.ValidateTemplateForKind(kind, input.Template); err != nil {
 		return "", err
 	}
 
 	renderer := NewTemplateRenderer(s.validator)
+	locale := loadNotificationLocale(s.DB, userID)
+	if kind == notificationTemplateKindDigest {
+		data := sampleDigestTemplateData()
+		data.Locale = locale
+		return renderer.RenderDigestTemplate(input.Template, data)
+	}
+
 	templateData := TemplateData{
 		SubscriptionName: "Netflix Premium",
 		BillingDate:      "2026-03-15",
@@ -196,6 +232,8 @@ func (s *NotificationTemplateService) PreviewTemplate(userID uint, input CreateT
 		URL:              "https://www.netflix.com",
 		Remark:           "Family plan",
 		UserEmail:        "user@example.com",
+		Locale:           locale,
+		CustomFields:     sampleCustomFieldTemplateValues(s.DB, userID),
 	}
 
 	var sub model.Subscription
@@ -220,6 +258,7 @@ func (s *NotificationTemplateService) PreviewTemplate(userID uint, input CreateT
 	templateData.EventType = notificationEventTypeForSubscription(sub)
 	templateData.RenewalMode = normalizeRenewalMode(sub.RenewalMode)
 	templateData.Status = normalizeStatus(sub.Status)
+	templateData.CustomFields = customFieldTemplateVariables(s.DB, sub)
 
 	billingDateSource := sub.NextBillingDate
 	if normalizeRenewalMode(sub.RenewalMode) == renewalModeCancelAtPeriodEnd {
@@ -260,3 +299,77 @@ func (s *NotificationTemplateService) PreviewTemplate(userID uint, input CreateT
 
 	return renderer.RenderTemplate(input.Template, templateData)
 }
+
+// sampleCustomFieldTemplateValues gives each of the user's non-secret custom
+// fields a placeholder value for previews, so templates using them render
+// even before any subscription has a value.
+func sampleCustomFieldTemplateValues(db *gorm.DB, userID uint) map[string]string {
+	var fields []model.CustomFieldDefinition
+	if err := db.Where("user_id = ? AND type <> ?", userID, customFieldTypeSecret).Find(&fields).Error; err != nil {
+		return nil
+	}
+	values := make(map[string]string, len(fields))
+	for _, field := range fields {
+		switch field.Type {
+		case customFieldTypeNumber:
+			values[field.Key] = "1"
+		case customFieldTypeDate:
+			values[field.Key] = "2026-03-15"
+		case customFieldTypeURL:
+			values[field.Key] = "https://example.com"
+		case customFieldTypeSelect:
+			if options := DecodeCustomFieldOptions(field); len(options) > 0 {
+				values[field.Key] = options[0]
+			}
+		default:
+			values[field.Key] = field.Name
+		}
+	}
+	return values
+}
+
+func sampleDigestTemplateData() DigestTemplateData {
+	return DigestTemplateData{
+		Frequency:   notificationDigestWeekly,
+		PeriodStart: "2026-03-09",
+		PeriodEnd:   "2026-03-15",
+		TotalDue:    25.98,
+		Currency:    "USD",
+		UserEmail:   "user@example.com",
+		Upcoming: []DigestRenewalItem{
+			{
+				SubscriptionName: "Netflix Premium",
+				Date:             "2026-03-12",
+				Amount:           15.99,
+				Currency:         "USD",
+				DaysUntil:        3,
+				RenewalMode:      renewalModeAutoRenew,
+				Category:         "Entertainment",
+				PaymentMethod:    "Credit Card",
+				URL:              "https://www.netflix.com",
+			},
+			{
+				SubscriptionName: "Cloud Storage",
+				Date:             "2026-03-14",
+				Amount:           9.99,
+				Currency:         "USD",
+				DaysUntil:        5,
+				RenewalMode:      renewalModeManualRenew,
+				Category:         "Cloud",
+				PaymentMethod:    "Paypal",
+			},
+		},
+		Ending: []DigestRenewalItem{
+			{
+				SubscriptionName: "Music Plus",
+				Date:             "2026-03-15",
+				Amount:           4.99,
+				Currency:         "USD",
+				DaysUntil:        6,
+				RenewalMode:      renewalModeCancelAtPeriodEnd,
+				Category:         "Music",
+			},
+		},
+		Failed: []DigestFailedDelivery{},
+	}
+}
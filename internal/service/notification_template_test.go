@@ -23,6 +23,11 @@ func newNotificationTemplatePreviewTestDB(t *testing.T) *gorm.DB {
 		&model.User{},
 		&model.Subscription{},
 		&model.SubscriptionEvent{},
+		&model.SubscriptionPriceChange{},
+		&model.SubscriptionUsage{},
+		&model.CustomFieldDefinition{},
+		&model.SubscriptionCustomFieldValue{},
+		&model.SubscriptionAttachment{},
 		&model.Category{},
 		&model.PaymentMethod{},
 	); err != nil {
@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/shiroha/subdux/internal/pkg"
+	"io"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -33,11 +34,21 @@ const (
 	oidcResultSessionTTL = 3 * time.Minute
 	maxOIDCStateSessions = 1024
 	maxOIDCResultSession = 1024
+	maxOIDCUserInfoBytes = 1 << 20
 )
 
 type OIDCPublicConfig struct {
-	Enabled        bool   `json:"enabled"`
-	ProviderName   string `json:"provider_name"`
+	Enabled        bool                 `json:"enabled"`
+	ProviderName   string               `json:"provider_name"`
+	AutoCreateUser bool                 `json:"auto_create_user"`
+	Providers      []OIDCPublicProvider `json:"providers"`
+}
+
+// OIDCPublicProvider is a login button: the key to start the flow with and
+// the label to show.
+type OIDCPublicProvider struct {
+	Key            string `json:"key"`
+	Name           string `json:"name"`
 	AutoCreateUser bool   `json:"auto_create_user"`
 }
 
@@ -46,11 +57,12 @@ type OIDCStartResult struct {
 }
 
 type OIDCConnectionInfo struct {
-	ID        uint      `json:"id"`
-	Provider  string    `json:"provider"`
-	Email     string    `json:"email"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID           uint      `json:"id"`
+	Provider     string    `json:"provider"`
+	ProviderName string    `json:"provider_name"`
+	Email        string    `json:"email"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
 }
 
 type OIDCCallbackResult struct {
@@ -77,6 +89,7 @@ type OIDCSessionResult struct {
 
 type oidcStateSession struct {
 	Purpose      string
+	Provider     string
 	UserID       uint
 	Operation    string
 	CodeVerifier string
@@ -92,6 +105,8 @@ type oidcResultSession struct {
 }
 
 type oidcSettings struct {
+	Key            string
+	Kind           string
 	Enabled        bool
 	ProviderName   string
 	IssuerURL      string
@@ -106,10 +121,29 @@ type oidcSettings struct {
 	Audience       string
 	Resource       string
 	ExtraAuth      map[string]string
+
+	AllowedDomains string
+	SubjectClaim   string
+	EmailClaim     string
+	UsernameClaim  string
+	RoleClaim      string
+	AdminValues    []string
+	StatusClaim    string
+	ActiveValues   []string
 }
 
 func (s oidcSettings) isConfigured() bool {
-	return s.IssuerURL != "" && s.ClientID != "" && s.ClientSecret != "" && s.RedirectURL != ""
+	if s.ClientID == "" || s.ClientSecret == "" || s.RedirectURL == "" {
+		return false
+	}
+	if s.Kind == OIDCProviderKindOAuth2 {
+		return s.AuthURL != "" && s.TokenURL != "" && s.UserinfoURL != ""
+	}
+	return s.IssuerURL != ""
+}
+
+func (s oidcSettings) isAvailable() bool {
+	return s.Enabled && s.isConfigured()
 }
 
 type oidcIdentityClaims struct {
@@ -119,22 +153,40 @@ type oidcIdentityClaims struct {
 	PreferredUsername string `json:"preferred_username"`
 	Name              string `json:"name"`
 	Nonce             string `json:"nonce"`
+
+	// Raw holds every claim so providers can map role, status and identity
+	// attributes from arbitrary (possibly nested) claims.
+	Raw map[string]interface{} `json:"-"`
 }
 
 func (s *AuthService) GetOIDCPublicConfig() *OIDCPublicConfig {
 	settings := s.getOIDCSettings()
-
-	return &OIDCPublicConfig{
-		Enabled:        settings.Enabled && settings.isConfigured(),
+	config := &OIDCPublicConfig{
 		ProviderName:   settings.ProviderName,
 		AutoCreateUser: settings.AutoCreateUser,
+		Providers:      []OIDCPublicProvider{},
 	}
+
+	for _, provider := range s.listOIDCProviders() {
+		if !provider.isAvailable() {
+			continue
+		}
+		config.Providers = append(config.Providers, OIDCPublicProvider{
+			Key:            provider.Key,
+			Name:           provider.ProviderName,
+			AutoCreateUser: provider.AutoCreateUser,
+		})
+	}
+	config.Enabled = len(config.Providers) > 0
+	return config
 }
 
-func (s *AuthService) BeginOIDCLogin() (*OIDCStartResult, error) {
-	settings := s.getOIDCSettings()
-	if !settings.Enabled || !settings.isConfigured() {
-		return nil, errors.New("oidc login is not available")
+// BeginOIDCLogin starts a login with the provider identified by providerKey.
+// An empty key selects the provider configured in system settings.
+func (s *AuthService) BeginOIDCLogin(providerKey string) (*OIDCStartResult, error) {
+	settings, err := s.getAvailableOIDCProvider(providerKey)
+	if err != nil {
+		return nil, err
 	}
 
 	authorizationURL, err := s.buildOIDCAuthorizationURL(settings, oidcPurposeLogin, 0, "")
@@ -145,10 +197,10 @@ func (s *AuthService) BeginOIDCLogin() (*OIDCStartResult, error) {
 	return &OIDCStartResult{AuthorizationURL: authorizationURL}, nil
 }
 
-func (s *AuthService) BeginOIDCConnect(userID uint) (*OIDCStartResult, error) {
-	settings := s.getOIDCSettings()
-	if !settings.Enabled || !settings.isConfigured() {
-		return nil, errors.New("oidc login is not available")
+func (s *AuthService) BeginOIDCConnect(userID uint, providerKey string) (*OIDCStartResult, error) {
+	settings, err := s.getAvailableOIDCProvider(providerKey)
+	if err != nil {
+		return nil, err
 	}
 
 	authorizationURL, err := s.buildOIDCAuthorizationURL(settings, oidcPurposeConnect, userID, "")
@@ -163,14 +215,25 @@ func (s *AuthService) BeginOIDCConnect(userID uint) (*OIDCStartResult, error) {
 // user before a sensitive operation. Unlike login/connect it carries the
 // operation through the state session so the minted ticket can be scoped to it,
 // and on completion issues no tokens — success only proves the admin still
-// controls their linked OIDC identity.
-func (s *AuthService) BeginOIDCReauth(userID uint, operation string) (*OIDCStartResult, error) {
+// controls their linked OIDC identity. Without a providerKey the first
+// available provider the user is linked to is used.
+func (s *AuthService) BeginOIDCReauth(userID uint, operation string, providerKey string) (*OIDCStartResult, error) {
 	if userID == 0 {
 		return nil, errors.New("invalid oidc reauth session")
 	}
-	settings := s.getOIDCSettings()
-	if !settings.Enabled || !settings.isConfigured() {
-		return nil, errors.New("oidc login is not available")
+	if strings.TrimSpace(providerKey) == "" {
+		linked, err := s.linkedOIDCProviders(userID)
+		if err != nil {
+			return nil, err
+		}
+		if len(linked) == 0 {
+			return nil, errors.New("oidc identity is not linked to this account")
+		}
+		providerKey = linked[0].Key
+	}
+	settings, err := s.getAvailableOIDCProvider(providerKey)
+	if err != nil {
+		return nil, err
 	}
 
 	authorizationURL, err := s.buildOIDCAuthorizationURL(settings, oidcPurposeReauth, userID, operation)
@@ -206,9 +269,9 @@ func (s *AuthService) HandleOIDCCallback(state string, code string, providerErro
 		return s.createOIDCCallbackErrorResult(purpose, "missing OIDC authorization code")
 	}
 
-	settings := s.getOIDCSettings()
-	if !settings.Enabled || !settings.isConfigured() {
-		return s.createOIDCCallbackErrorResult(purpose, "oidc login is not available")
+	settings, err := s.getAvailableOIDCProvider(session.Provider)
+	if err != nil {
+		return s.createOIDCCallbackErrorResult(purpose, err.Error())
 	}
 
 	claims, err := s.resolveOIDCIdentity(settings, code, session.CodeVerifier, session.Nonce)
@@ -218,9 +281,9 @@ func (s *AuthService) HandleOIDCCallback(state string, code string, providerErro
 
 	var result OIDCSessionResult
 	if purpose == oidcPurposeConnect {
-		result, err = s.finishOIDCConnect(session.UserID, claims)
+		result, err = s.finishOIDCConnect(settings, session.UserID, claims)
 	} else if purpose == oidcPurposeReauth {
-		result, err = s.finishOIDCReauth(session.UserID, session.Operation, claims)
+		result, err = s.finishOIDCReauth(settings.Key, session.UserID, session.Operation, claims)
 	} else {
 		result, err = s.finishOIDCLogin(settings, claims)
 	}
@@ -265,29 +328,62 @@ func (s *AuthService) ListOIDCConnections(userID uint) ([]OIDCConnectionInfo, er
 		return nil, err
 	}
 
+	names := make(map[string]string)
+	for _, provider := range s.listOIDCProviders() {
+		names[provider.Key] = provider.ProviderName
+	}
+
 	result := make([]OIDCConnectionInfo, 0, len(records))
 	for _, record := range records {
-		result = append(result, mapOIDCConnectionInfo(record))
+		info := mapOIDCConnectionInfo(record)
+		info.ProviderName = names[record.Provider]
+		result = append(result, info)
 	}
 	return result, nil
 }
 
 // CanReauthWithOIDC reports whether the user can use OIDC as a step-up factor:
-// the provider must be enabled/configured and the user must have a linked OIDC
-// connection to authenticate against.
+// some provider must be enabled/configured and the user must have a linked
+// OIDC connection with it to authenticate against.
 func (s *AuthService) CanReauthWithOIDC(userID uint) (bool, error) {
-	settings := s.getOIDCSettings()
-	if !settings.Enabled || !settings.isConfigured() {
-		return false, nil
+	linked, err := s.linkedOIDCProviders(userID)
+	if err != nil {
+		return false, err
+	}
+	return len(linked) > 0, nil
+}
+
+// linkedOIDCProviders returns the available providers the user has a
+// connection with, in provider order.
+func (s *AuthService) linkedOIDCProviders(userID uint) ([]oidcSettings, error) {
+	var available []oidcSettings
+	for _, provider := range s.listOIDCProviders() {
+		if provider.isAvailable() {
+			available = append(available, provider)
+		}
+	}
+	if len(available) == 0 {
+		return nil, nil
 	}
 
-	var count int64
+	var keys []string
 	if err := s.DB.Model(&model.OIDCConnection{}).
-		Where("provider = ? AND user_id = ?", oidcProviderKey, userID).
-		Count(&count).Error; err != nil {
-		return false, err
+		Where("user_id = ?", userID).
+		Pluck("provider", &keys).Error; err != nil {
+		return nil, err
 	}
-	return count > 0, nil
+	connected := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		connected[key] = struct{}{}
+	}
+
+	var linked []oidcSettings
+	for _, provider := range available {
+		if _, ok := connected[provider.Key]; ok {
+			linked = append(linked, provider)
+		}
+	}
+	return linked, nil
 }
 
 func (s *AuthService) DeleteOIDCConnection(userID uint, connectionID uint) error {
@@ -301,17 +397,20 @@ func (s *AuthService) DeleteOIDCConnection(userID uint, connectionID uint) error
 	return nil
 }
 
-func (s *AuthService) buildOIDCAuthorizationURL(settings oidcSettings, purpose string, userID uint, operation string) (string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-	ctx = oidc.ClientContext(ctx, NewOutboundHTTPClient(s.DB, 10*time.Second))
-
-	provider, err := oidc.NewProvider(ctx, settings.IssuerURL)
-	if err != nil {
-		return "", errors.New("failed to initialize oidc provider")
+// oidcOAuthConfig builds the OAuth client for a provider. OIDC providers use
+// discovery, with the configured endpoints taking precedence; plain OAuth 2.0
+// providers have no discovery document and return a nil *oidc.Provider.
+func oidcOAuthConfig(ctx context.Context, settings oidcSettings) (oauth2.Config, *oidc.Provider, error) {
+	var provider *oidc.Provider
+	var endpoint oauth2.Endpoint
+	if settings.Kind != OIDCProviderKindOAuth2 {
+		discovered, err := oidc.NewProvider(ctx, settings.IssuerURL)
+		if err != nil {
+			return oauth2.Config{}, nil, errors.New("failed to initialize oidc provider")
+		}
+		provider = discovered
+		endpoint = provider.Endpoint()
 	}
-
-	endpoint := provider.Endpoint()
 	if strings.TrimSpace(settings.AuthURL) != "" {
 		endpoint.AuthURL = strings.TrimSpace(settings.AuthURL)
 	}
@@ -319,12 +418,23 @@ func (s *AuthService) buildOIDCAuthorizationURL(settings oidcSettings, purpose s
 		endpoint.TokenURL = strings.TrimSpace(settings.TokenURL)
 	}
 
-	oauthConfig := oauth2.Config{
+	return oauth2.Config{
 		ClientID:     settings.ClientID,
 		ClientSecret: settings.ClientSecret,
 		Endpoint:     endpoint,
 		RedirectURL:  settings.RedirectURL,
 		Scopes:       settings.Scopes,
+	}, provider, nil
+}
+
+func (s *AuthService) buildOIDCAuthorizationURL(settings oidcSettings, purpose string, userID uint, operation string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	ctx = oidc.ClientContext(ctx, NewOutboundHTTPClient(s.DB, 10*time.Second))
+
+	oauthConfig, _, err := oidcOAuthConfig(ctx, settings)
+	if err != nil {
+		return "", err
 	}
 
 	state := uuid.NewString()
@@ -336,6 +446,7 @@ func (s *AuthService) buildOIDCAuthorizationURL(settings oidcSettings, purpose s
 	codeVerifier := oauth2.GenerateVerifier()
 	s.storeOIDCStateSession(state, oidcStateSession{
 		Purpose:      purpose,
+		Provider:     settings.Key,
 		UserID:       userID,
 		Operation:    operation,
 		CodeVerifier: codeVerifier,
@@ -371,25 +482,9 @@ func (s *AuthService) resolveOIDCIdentity(settings oidcSettings, code string, co
 	defer cancel()
 	ctx = oidc.ClientContext(ctx, NewOutboundHTTPClient(s.DB, 10*time.Second))
 
-	provider, err := oidc.NewProvider(ctx, settings.IssuerURL)
+	oauthConfig, provider, err := oidcOAuthConfig(ctx, settings)
 	if err != nil {
-		return nil, errors.New("failed to initialize oidc provider")
-	}
-
-	endpoint := provider.Endpoint()
-	if strings.TrimSpace(settings.AuthURL) != "" {
-		endpoint.AuthURL = strings.TrimSpace(settings.AuthURL)
-	}
-	if strings.TrimSpace(settings.TokenURL) != "" {
-		endpoint.TokenURL = strings.TrimSpace(settings.TokenURL)
-	}
-
-	oauthConfig := oauth2.Config{
-		ClientID:     settings.ClientID,
-		ClientSecret: settings.ClientSecret,
-		Endpoint:     endpoint,
-		RedirectURL:  settings.RedirectURL,
-		Scopes:       settings.Scopes,
+		return nil, err
 	}
 
 	tokenOptions := []oauth2.AuthCodeOption{oauth2.VerifierOption(codeVerifier)}
@@ -405,6 +500,16 @@ func (s *AuthService) resolveOIDCIdentity(settings oidcSettings, code string, co
 		return nil, errors.New("failed to exchange oidc authorization code")
 	}
 
+	if settings.Kind == OIDCProviderKindOAuth2 {
+		// Plain OAuth 2.0 providers issue no ID token; the identity comes
+		// from the userinfo endpoint authenticated with the access token.
+		claims, err := fetchOIDCUserInfoClaims(ctx, nil, oauthToken, settings.UserinfoURL, NewOutboundHTTPClient(s.DB, 10*time.Second))
+		if err != nil {
+			return nil, errors.New("failed to load oauth user profile")
+		}
+		return mapOIDCIdentityClaims(settings, claims)
+	}
+
 	rawIDToken, ok := oauthToken.Extra("id_token").(string)
 	if !ok || strings.TrimSpace(rawIDToken) == "" {
 		return nil, errors.New("oidc provider did not return id_token")
@@ -420,6 +525,9 @@ func (s *AuthService) resolveOIDCIdentity(settings oidcSettings, code string, co
 	if err := idToken.Claims(&claims); err != nil {
 		return nil, errors.New("failed to parse oidc identity")
 	}
+	if err := idToken.Claims(&claims.Raw); err != nil {
+		return nil, errors.New("failed to parse oidc identity")
+	}
 
 	if expectedNonce != "" {
 		if claims.Nonce == "" {
@@ -437,7 +545,9 @@ func (s *AuthService) resolveOIDCIdentity(settings oidcSettings, code string, co
 
 	needsUserInfo := strings.TrimSpace(claims.Email) == "" ||
 		strings.TrimSpace(claims.PreferredUsername) == "" ||
-		strings.TrimSpace(claims.Name) == ""
+		strings.TrimSpace(claims.Name) == "" ||
+		!hasOIDCClaim(claims.Raw, settings.RoleClaim) ||
+		!hasOIDCClaim(claims.Raw, settings.StatusClaim)
 	if needsUserInfo {
 		userInfoClaims, userInfoErr := fetchOIDCUserInfoClaims(ctx, provider, oauthToken, settings.UserinfoURL, NewOutboundHTTPClient(s.DB, 10*time.Second))
 		if userInfoErr == nil && userInfoClaims != nil {
@@ -454,15 +564,25 @@ func (s *AuthService) resolveOIDCIdentity(settings oidcSettings, code string, co
 			if strings.TrimSpace(claims.Name) == "" {
 				claims.Name = strings.TrimSpace(userInfoClaims.Name)
 			}
+			for key, value := range userInfoClaims.Raw {
+				if _, exists := claims.Raw[key]; !exists {
+					claims.Raw[key] = value
+				}
+			}
 		}
 	}
 
-	return &claims, nil
+	return mapOIDCIdentityClaims(settings, &claims)
 }
 
 func (s *AuthService) finishOIDCLogin(settings oidcSettings, claims *oidcIdentityClaims) (OIDCSessionResult, error) {
+	if err := checkOIDCEmailDomain(settings, claims); err != nil {
+		return OIDCSessionResult{}, err
+	}
+	mapping := mapOIDCAccount(settings, claims)
+
 	var connection model.OIDCConnection
-	err := s.DB.Where("provider = ? AND subject = ?", oidcProviderKey, claims.Subject).First(&connection).Error
+	err := s.DB.Where("provider = ? AND subject = ?", settings.Key, claims.Subject).First(&connection).Error
 	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
 		return OIDCSessionResult{}, errors.New("failed to load oidc connection")
 	}
@@ -474,6 +594,9 @@ func (s *AuthService) finishOIDCLogin(settings oidcSettings, claims *oidcIdentit
 			return OIDCSessionResult{}, errors.New("linked user not found")
 		}
 
+		if err := s.syncOIDCAccount(user, mapping); err != nil {
+			return OIDCSessionResult{}, err
+		}
 		if user.Status == "disabled" {
 			return OIDCSessionResult{}, errors.New("account is disabled")
 		}
@@ -486,14 +609,17 @@ func (s *AuthService) finishOIDCLogin(settings oidcSettings, claims *oidcIdentit
 		if !settings.AutoCreateUser {
 			return OIDCSessionResult{}, errors.New("oidc account is not linked")
 		}
+		if mapping.Status == "disabled" {
+			return OIDCSessionResult{}, errors.New("account is disabled")
+		}
 
-		user, err = s.createOIDCUser(claims)
+		user, err = s.createOIDCUser(settings.Key, claims, mapping.Role)
 		if err != nil {
 			return OIDCSessionResult{}, err
 		}
 	}
 
-	authResp, err := s.issueAuthResponse(*user)
+	authResp, err := s.issueAuthResponse(*user, SessionViaOIDC)
 	if err != nil {
 		return OIDCSessionResult{}, err
 	}
@@ -506,10 +632,13 @@ func (s *AuthService) finishOIDCLogin(settings oidcSettings, claims *oidcIdentit
 	}, nil
 }
 
-func (s *AuthService) finishOIDCConnect(userID uint, claims *oidcIdentityClaims) (OIDCSessionResult, error) {
+func (s *AuthService) finishOIDCConnect(settings oidcSettings, userID uint, claims *oidcIdentityClaims) (OIDCSessionResult, error) {
 	if userID == 0 {
 		return OIDCSessionResult{}, errors.New("invalid oidc connect session")
 	}
+	if err := checkOIDCEmailDomain(settings, claims); err != nil {
+		return OIDCSessionResult{}, err
+	}
 
 	user, err := s.GetUser(userID)
 	if err != nil {
@@ -523,7 +652,7 @@ func (s *AuthService) finishOIDCConnect(userID uint, claims *oidcIdentityClaims)
 	var connection model.OIDCConnection
 	err = s.DB.Transaction(func(tx *gorm.DB) error {
 		var existingBySubject model.OIDCConnection
-		if err := tx.Where("provider = ? AND subject = ?", oidcProviderKey, claims.Subject).First(&existingBySubject).Error; err == nil {
+		if err := tx.Where("provider = ? AND subject = ?", settings.Key, claims.Subject).First(&existingBySubject).Error; err == nil {
 			if existingBySubject.UserID != userID {
 				return errors.New("this oidc account is already linked to another user")
 			}
@@ -541,7 +670,7 @@ func (s *AuthService) finishOIDCConnect(userID uint, claims *oidcIdentityClaims)
 		}
 
 		var existingForUser model.OIDCConnection
-		if err := tx.Where("provider = ? AND user_id = ?", oidcProviderKey, userID).First(&existingForUser).Error; err == nil {
+		if err := tx.Where("provider = ? AND user_id = ?", settings.Key, userID).First(&existingForUser).Error; err == nil {
 			return errors.New("you have already connected another oidc account")
 		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
 			return err
@@ -549,7 +678,7 @@ func (s *AuthService) finishOIDCConnect(userID uint, claims *oidcIdentityClaims)
 
 		connection = model.OIDCConnection{
 			UserID:   userID,
-			Provider: oidcProviderKey,
+			Provider: settings.Key,
 			Subject:  claims.Subject,
 			Email:    email,
 		}
@@ -563,6 +692,7 @@ func (s *AuthService) finishOIDCConnect(userID uint, claims *oidcIdentityClaims)
 	}
 
 	mapped := mapOIDCConnectionInfo(connection)
+	mapped.ProviderName = settings.ProviderName
 	return OIDCSessionResult{
 		Purpose:    oidcPurposeConnect,
 		Connected:  true,
@@ -576,7 +706,7 @@ func (s *AuthService) finishOIDCConnect(userID uint, claims *oidcIdentityClaims)
 // VerifyOIDC later spends to mint a reauth ticket. Requiring an existing
 // connection owned by userID means an admin can only step up with their own
 // linked identity, never by authenticating as a different OIDC account.
-func (s *AuthService) finishOIDCReauth(userID uint, operation string, claims *oidcIdentityClaims) (OIDCSessionResult, error) {
+func (s *AuthService) finishOIDCReauth(providerKey string, userID uint, operation string, claims *oidcIdentityClaims) (OIDCSessionResult, error) {
 	if userID == 0 {
 		return OIDCSessionResult{}, errors.New("invalid oidc reauth session")
 	}
@@ -590,7 +720,7 @@ func (s *AuthService) finishOIDCReauth(userID uint, operation string, claims *oi
 	}
 
 	var connection model.OIDCConnection
-	err = s.DB.Where("provider = ? AND subject = ?", oidcProviderKey, claims.Subject).First(&connection).Error
+	err = s.DB.Where("provider = ? AND subject = ?", providerKey, claims.Subject).First(&connection).Error
 	if errors.Is(err, gorm.ErrRecordNotFound) {
 		return OIDCSessionResult{}, errors.New("oidc identity is not linked to this account")
 	} else if err != nil {
@@ -607,7 +737,7 @@ func (s *AuthService) finishOIDCReauth(userID uint, operation string, claims *oi
 	}, nil
 }
 
-func (s *AuthService) createOIDCUser(claims *oidcIdentityClaims) (*model.User, error) {
+func (s *AuthService) createOIDCUser(providerKey string, claims *oidcIdentityClaims, role string) (*model.User, error) {
 	email := strings.TrimSpace(claims.Email)
 	if email == "" {
 		return nil, errors.New("oidc provider did not return an email")
@@ -654,7 +784,7 @@ func (s *AuthService) createOIDCUser(claims *oidcIdentityClaims) (*model.User, e
 		Username: username,
 		Email:    email,
 		Password: string(hash),
-		Role:     "user",
+		Role:     role,
 		Status:   "active",
 	}
 
@@ -676,7 +806,7 @@ func (s *AuthService) createOIDCUser(claims *oidcIdentityClaims) (*model.User, e
 
 		connection := model.OIDCConnection{
 			UserID:   user.ID,
-			Provider: oidcProviderKey,
+			Provider: providerKey,
 			Subject:  claims.Subject,
 			Email:    email,
 		}
@@ -747,6 +877,8 @@ func (s *AuthService) getOIDCSettings() oidcSettings {
 	}
 
 	return oidcSettings{
+		Key:            oidcProviderKey,
+		Kind:           OIDCProviderKindOIDC,
 		Enabled:        parseBoolSetting(s.getSetting("oidc_enabled")),
 		ProviderName:   providerName,
 		IssuerURL:      strings.TrimSpace(s.getSetting("oidc_issuer_url")),
@@ -761,6 +893,11 @@ func (s *AuthService) getOIDCSettings() oidcSettings {
 		Audience:       strings.TrimSpace(s.getSetting("oidc_audience")),
 		Resource:       strings.TrimSpace(s.getSetting("oidc_resource")),
 		ExtraAuth:      parseOIDCExtraAuthParams(s.getSetting("oidc_extra_auth_params")),
+		AllowedDomains: s.getSetting("oidc_allowed_domains"),
+		RoleClaim:      s.getSetting("oidc_role_claim"),
+		AdminValues:    parseOIDCClaimValues(s.getSetting("oidc_admin_values")),
+		StatusClaim:    s.getSetting("oidc_status_claim"),
+		ActiveValues:   parseOIDCClaimValues(s.getSetting("oidc_active_values")),
 	}
 }
 
@@ -906,6 +1043,28 @@ func parseBoolSetting(value string) bool {
 }
 
 func parseOIDCScopes(raw string) []string {
+	scopes := splitOIDCScopes(raw)
+	if len(scopes) == 0 {
+		return nil
+	}
+
+	hasOpenID := false
+	for _, scope := range scopes {
+		if scope == "openid" {
+			hasOpenID = true
+			break
+		}
+	}
+	if !hasOpenID {
+		scopes = append([]string{"openid"}, scopes...)
+	}
+
+	return scopes
+}
+
+// splitOIDCScopes splits and deduplicates a scope list without adding
+// "openid", which plain OAuth 2.0 providers do not understand.
+func splitOIDCScopes(raw string) []string {
 	value := strings.ReplaceAll(strings.TrimSpace(raw), ",", " ")
 	if value == "" {
 		return nil
@@ -933,18 +1092,6 @@ func parseOIDCScopes(raw string) []string {
 	if len(scopes) == 0 {
 		return nil
 	}
-
-	hasOpenID := false
-	for _, scope := range scopes {
-		if scope == "openid" {
-			hasOpenID = true
-			break
-		}
-	}
-	if !hasOpenID {
-		scopes = append([]string{"openid"}, scopes...)
-	}
-
 	return scopes
 }
 
@@ -1005,6 +1152,9 @@ func fetchOIDCUserInfoClaims(ctx context.Context, provider *oidc.Provider, oauth
 		if err := userInfo.Claims(&claims); err != nil {
 			return nil, err
 		}
+		if err := userInfo.Claims(&claims.Raw); err != nil {
+			return nil, err
+		}
 		return &claims, nil
 	}
 
@@ -1036,8 +1186,15 @@ func fetchOIDCUserInfoClaims(ctx context.Context, provider *oidc.Provider, oauth
 		return nil, fmt.Errorf("oidc userinfo endpoint returned %d", resp.StatusCode)
 	}
 
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxOIDCUserInfoBytes))
+	if err != nil {
+		return nil, err
+	}
 	var claims oidcIdentityClaims
-	if err := json.NewDecoder(resp.Body).Decode(&claims); err != nil {
+	if err := json.Unmarshal(body, &claims); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(body, &claims.Raw); err != nil {
 		return nil, err
 	}
 	return &claims, nil
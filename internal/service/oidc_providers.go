@@ -0,0 +1,633 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/shiroha/subdux/internal/model"
+	"github.com/shiroha/subdux/internal/pkg"
+	"gorm.io/gorm"
+)
+
+const (
+	OIDCProviderKindOIDC   = "oidc"
+	OIDCProviderKindOAuth2 = "oauth2"
+
+	maxOIDCClaimLength = 255
+)
+
+var (
+	ErrOIDCProviderNotFound = errors.New("oidc provider not found")
+	ErrOIDCProviderKeyTaken = errors.New("oidc provider key is already in use")
+	ErrInvalidOIDCProvider  = errors.New("invalid oidc provider")
+	ErrInvalidOIDCMapping   = errors.New("invalid oidc claim mapping")
+)
+
+var oidcProviderKeyPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// OIDCProviderInput creates or updates an additional provider. Nil fields
+// are left unchanged on update; an empty client secret keeps the stored one,
+// as for the secrets in system settings.
+type OIDCProviderInput struct {
+	Key                   *string `json:"key"`
+	Name                  *string `json:"name"`
+	Kind                  *string `json:"kind"`
+	Enabled               *bool   `json:"enabled"`
+	SortOrder             *int    `json:"sort_order"`
+	IssuerURL             *string `json:"issuer_url"`
+	ClientID              *string `json:"client_id"`
+	ClientSecret          *string `json:"client_secret"`
+	RedirectURL           *string `json:"redirect_url"`
+	Scopes                *string `json:"scopes"`
+	AuthorizationEndpoint *string `json:"authorization_endpoint"`
+	TokenEndpoint         *string `json:"token_endpoint"`
+	UserinfoEndpoint      *string `json:"userinfo_endpoint"`
+	Audience              *string `json:"audience"`
+	Resource              *string `json:"resource"`
+	ExtraAuthParams       *string `json:"extra_auth_params"`
+	AutoCreateUser        *bool   `json:"auto_create_user"`
+	AllowedDomains        *string `json:"allowed_domains"`
+	SubjectClaim          *string `json:"subject_claim"`
+	EmailClaim            *string `json:"email_claim"`
+	UsernameClaim         *string `json:"username_claim"`
+	RoleClaim             *string `json:"role_claim"`
+	AdminValues           *string `json:"admin_values"`
+	StatusClaim           *string `json:"status_claim"`
+	ActiveValues          *string `json:"active_values"`
+}
+
+// OIDCProviderInfo is a provider as shown to administrators. The client
+// secret is write-only.
+type OIDCProviderInfo struct {
+	model.OIDCProvider
+	ClientSecretSet bool `json:"client_secret_configured"`
+}
+
+func (s *AdminService) ListOIDCProviders() ([]OIDCProviderInfo, error) {
+	var records []model.OIDCProvider
+	if err := s.DB.Order("sort_order ASC, id ASC").Find(&records).Error; err != nil {
+		return nil, err
+	}
+	result := make([]OIDCProviderInfo, 0, len(records))
+	for _, record := range records {
+		result = append(result, mapOIDCProviderInfo(record))
+	}
+	return result, nil
+}
+
+func (s *AdminService) CreateOIDCProvider(input OIDCProviderInput) (*OIDCProviderInfo, error) {
+	if input.Key == nil {
+		return nil, fmt.Errorf("%w: key is required", ErrInvalidOIDCProvider)
+	}
+	key := strings.ToLower(strings.TrimSpace(*input.Key))
+	if !oidcProviderKeyPattern.MatchString(key) || key == oidcProviderKey {
+		return nil, fmt.Errorf("%w: key must be 1-64 lowercase letters, digits, - or _ and not %q", ErrInvalidOIDCProvider, oidcProviderKey)
+	}
+
+	provider := model.OIDCProvider{Key: key, Kind: OIDCProviderKindOIDC, Scopes: defaultOIDCScopes}
+	if err := applyOIDCProviderInput(&provider, input); err != nil {
+		return nil, err
+	}
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&model.OIDCProvider{}).Where("key = ?", key).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrOIDCProviderKeyTaken
+		}
+		return tx.Create(&provider).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	info := mapOIDCProviderInfo(provider)
+	return &info, nil
+}
+
+// UpdateOIDCProvider changes a provider. Its key is fixed once created
+// because existing connections refer to it.
+func (s *AdminService) UpdateOIDCProvider(id uint, input OIDCProviderInput) (*OIDCProviderInfo, error) {
+	var provider model.OIDCProvider
+	if err := s.DB.First(&provider, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOIDCProviderNotFound
+		}
+		return nil, err
+	}
+	if input.Key != nil && strings.ToLower(strings.TrimSpace(*input.Key)) != provider.Key {
+		return nil, fmt.Errorf("%w: key cannot be changed", ErrInvalidOIDCProvider)
+	}
+
+	if err := applyOIDCProviderInput(&provider, input); err != nil {
+		return nil, err
+	}
+	if err := s.DB.Save(&provider).Error; err != nil {
+		return nil, err
+	}
+
+	info := mapOIDCProviderInfo(provider)
+	return &info, nil
+}
+
+// DeleteOIDCProvider removes a provider together with the connections made
+// through it, so a later provider reusing the key cannot sign into them.
+func (s *AdminService) DeleteOIDCProvider(id uint) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		var provider model.OIDCProvider
+		if err := tx.First(&provider, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrOIDCProviderNotFound
+			}
+			return err
+		}
+		if err := tx.Where("provider = ?", provider.Key).Delete(&model.OIDCConnection{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&provider).Error
+	})
+}
+
+func mapOIDCProviderInfo(provider model.OIDCProvider) OIDCProviderInfo {
+	return OIDCProviderInfo{
+		OIDCProvider:    provider,
+		ClientSecretSet: strings.TrimSpace(provider.ClientSecret) != "",
+	}
+}
+
+func applyOIDCProviderInput(provider *model.OIDCProvider, input OIDCProviderInput) error {
+	if input.Name != nil {
+		provider.Name = strings.TrimSpace(*input.Name)
+	}
+	if input.Kind != nil {
+		provider.Kind = strings.ToLower(strings.TrimSpace(*input.Kind))
+	}
+	if input.Enabled != nil {
+		provider.Enabled = *input.Enabled
+	}
+	if input.SortOrder != nil {
+		provider.SortOrder = *input.SortOrder
+	}
+	if input.AutoCreateUser != nil {
+		provider.AutoCreateUser = *input.AutoCreateUser
+	}
+
+	for target, value := range map[*string]*string{
+		&provider.IssuerURL:             input.IssuerURL,
+		&provider.ClientID:              input.ClientID,
+		&provider.RedirectURL:           input.RedirectURL,
+		&provider.Scopes:                input.Scopes,
+		&provider.AuthorizationEndpoint: input.AuthorizationEndpoint,
+		&provider.TokenEndpoint:         input.TokenEndpoint,
+		&provider.UserinfoEndpoint:      input.UserinfoEndpoint,
+		&provider.Audience:              input.Audience,
+		&provider.Resource:              input.Resource,
+		&provider.ExtraAuthParams:       input.ExtraAuthParams,
+		&provider.SubjectClaim:          input.SubjectClaim,
+		&provider.EmailClaim:            input.EmailClaim,
+		&provider.UsernameClaim:         input.UsernameClaim,
+	} {
+		if value != nil {
+			*target = strings.TrimSpace(*value)
+		}
+	}
+
+	if input.ClientSecret != nil && strings.TrimSpace(*input.ClientSecret) != "" {
+		encrypted, err := pkg.EncryptSystemSettingValue(strings.TrimSpace(*input.ClientSecret))
+		if err != nil {
+			return err
+		}
+		provider.ClientSecret = encrypted
+	}
+
+	mapping := oidcMappingSettings{
+		AllowedDomains: provider.AllowedDomains,
+		RoleClaim:      provider.RoleClaim,
+		AdminValues:    provider.AdminValues,
+		StatusClaim:    provider.StatusClaim,
+		ActiveValues:   provider.ActiveValues,
+	}
+	if err := mapping.apply(input.AllowedDomains, input.RoleClaim, input.AdminValues, input.StatusClaim, input.ActiveValues); err != nil {
+		return err
+	}
+	provider.AllowedDomains = mapping.AllowedDomains
+	provider.RoleClaim = mapping.RoleClaim
+	provider.AdminValues = mapping.AdminValues
+	provider.StatusClaim = mapping.StatusClaim
+	provider.ActiveValues = mapping.ActiveValues
+
+	return validateOIDCProvider(provider)
+}
+
+func validateOIDCProvider(provider *model.OIDCProvider) error {
+	if provider.Name == "" || len(provider.Name) > 100 {
+		return fmt.Errorf("%w: name must be 1-100 characters", ErrInvalidOIDCProvider)
+	}
+	if provider.Kind != OIDCProviderKindOIDC && provider.Kind != OIDCProviderKindOAuth2 {
+		return fmt.Errorf("%w: kind must be oidc or oauth2", ErrInvalidOIDCProvider)
+	}
+
+	for _, field := range []struct {
+		label string
+		value string
+	}{
+		{"issuer_url", provider.IssuerURL},
+		{"redirect_url", provider.RedirectURL},
+		{"authorization_endpoint", provider.AuthorizationEndpoint},
+		{"token_endpoint", provider.TokenEndpoint},
+		{"userinfo_endpoint", provider.UserinfoEndpoint},
+	} {
+		if field.value == "" {
+			continue
+		}
+		if _, err := validateHTTPURL(field.value, field.label, false); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidOIDCProvider, err)
+		}
+	}
+	for _, claim := range []string{provider.SubjectClaim, provider.EmailClaim, provider.UsernameClaim} {
+		if len(claim) > maxOIDCClaimLength {
+			return fmt.Errorf("%w: claim names must be at most %d characters", ErrInvalidOIDCProvider, maxOIDCClaimLength)
+		}
+	}
+
+	if provider.Enabled && !oidcSettingsFromProvider(*provider).isConfigured() {
+		if provider.Kind == OIDCProviderKindOAuth2 {
+			return fmt.Errorf("%w: an enabled oauth2 provider needs client credentials, redirect URL and authorization, token and userinfo endpoints", ErrInvalidOIDCProvider)
+		}
+		return fmt.Errorf("%w: an enabled oidc provider needs an issuer URL, client credentials and redirect URL", ErrInvalidOIDCProvider)
+	}
+	return nil
+}
+
+// oidcMappingSettings are the domain and claim mapping options shared by the
+// provider in system settings and the additional providers.
+type oidcMappingSettings struct {
+	AllowedDomains string
+	RoleClaim      string
+	AdminValues    string
+	StatusClaim    string
+	ActiveValues   string
+}
+
+// apply normalizes the given fields over the current ones and rejects a
+// role or status claim without the values that map it.
+func (m *oidcMappingSettings) apply(allowedDomains, roleClaim, adminValues, statusClaim, activeValues *string) error {
+	if allowedDomains != nil {
+		normalized, err := normalizeEmailDomainWhitelist(*allowedDomains)
+		if err != nil {
+			return fmt.Errorf("%w: allowed domains: %v", ErrInvalidOIDCMapping, err)
+		}
+		m.AllowedDomains = normalized
+	}
+	if roleClaim != nil {
+		m.RoleClaim = strings.TrimSpace(*roleClaim)
+	}
+	if adminValues != nil {
+		m.AdminValues = strings.Join(parseOIDCClaimValues(*adminValues), ",")
+	}
+	if statusClaim != nil {
+		m.StatusClaim = strings.TrimSpace(*statusClaim)
+	}
+	if activeValues != nil {
+		m.ActiveValues = strings.Join(parseOIDCClaimValues(*activeValues), ",")
+	}
+
+	if len(m.RoleClaim) > maxOIDCClaimLength || len(m.StatusClaim) > maxOIDCClaimLength {
+		return fmt.Errorf("%w: claim names must be at most %d characters", ErrInvalidOIDCMapping, maxOIDCClaimLength)
+	}
+	if len(m.AdminValues) > 1024 || len(m.ActiveValues) > 1024 {
+		return fmt.Errorf("%w: claim values are too long", ErrInvalidOIDCMapping)
+	}
+	if m.RoleClaim != "" && m.AdminValues == "" {
+		return fmt.Errorf("%w: a role claim needs the values that grant the admin role", ErrInvalidOIDCMapping)
+	}
+	if m.StatusClaim != "" && m.ActiveValues == "" {
+		return fmt.Errorf("%w: a status claim needs the values that mean active", ErrInvalidOIDCMapping)
+	}
+	return nil
+}
+
+// applyOIDCMappingSettings validates and stores the mapping options of the
+// provider configured in system settings.
+func applyOIDCMappingSettings(tx *gorm.DB, input UpdateSettingsInput) error {
+	if input.OIDCAllowedDomains == nil && input.OIDCRoleClaim == nil && input.OIDCAdminValues == nil &&
+		input.OIDCStatusClaim == nil && input.OIDCActiveValues == nil {
+		return nil
+	}
+
+	var mapping oidcMappingSettings
+	for key, target := range map[string]*string{
+		"oidc_allowed_domains": &mapping.AllowedDomains,
+		"oidc_role_claim":      &mapping.RoleClaim,
+		"oidc_admin_values":    &mapping.AdminValues,
+		"oidc_status_claim":    &mapping.StatusClaim,
+		"oidc_active_values":   &mapping.ActiveValues,
+	} {
+		value, err := getSystemSettingValue(tx, key, "")
+		if err != nil {
+			return err
+		}
+		*target = value
+	}
+	if err := mapping.apply(input.OIDCAllowedDomains, input.OIDCRoleClaim, input.OIDCAdminValues, input.OIDCStatusClaim, input.OIDCActiveValues); err != nil {
+		return err
+	}
+
+	for key, value := range map[string]string{
+		"oidc_allowed_domains": mapping.AllowedDomains,
+		"oidc_role_claim":      mapping.RoleClaim,
+		"oidc_admin_values":    mapping.AdminValues,
+		"oidc_status_claim":    mapping.StatusClaim,
+		"oidc_active_values":   mapping.ActiveValues,
+	} {
+		if err := saveStringSystemSetting(tx, key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func oidcSettingsFromProvider(provider model.OIDCProvider) oidcSettings {
+	clientSecret, err := pkg.DecryptSystemSettingValue(provider.ClientSecret)
+	if err != nil {
+		clientSecret = ""
+	}
+
+	scopes := parseOIDCScopes(provider.Scopes)
+	if provider.Kind == OIDCProviderKindOAuth2 {
+		scopes = splitOIDCScopes(provider.Scopes)
+	} else if len(scopes) == 0 {
+		scopes = parseOIDCScopes(defaultOIDCScopes)
+	}
+
+	return oidcSettings{
+		Key:            provider.Key,
+		Kind:           provider.Kind,
+		Enabled:        provider.Enabled,
+		ProviderName:   provider.Name,
+		IssuerURL:      provider.IssuerURL,
+		ClientID:       provider.ClientID,
+		ClientSecret:   strings.TrimSpace(clientSecret),
+		RedirectURL:    provider.RedirectURL,
+		Scopes:         scopes,
+		AutoCreateUser: provider.AutoCreateUser,
+		AuthURL:        provider.AuthorizationEndpoint,
+		TokenURL:       provider.TokenEndpoint,
+		UserinfoURL:    provider.UserinfoEndpoint,
+		Audience:       provider.Audience,
+		Resource:       provider.Resource,
+		ExtraAuth:      parseOIDCExtraAuthParams(provider.ExtraAuthParams),
+		AllowedDomains: provider.AllowedDomains,
+		SubjectClaim:   provider.SubjectClaim,
+		EmailClaim:     provider.EmailClaim,
+		UsernameClaim:  provider.UsernameClaim,
+		RoleClaim:      provider.RoleClaim,
+		AdminValues:    parseOIDCClaimValues(provider.AdminValues),
+		StatusClaim:    provider.StatusClaim,
+		ActiveValues:   parseOIDCClaimValues(provider.ActiveValues),
+	}
+}
+
+// listOIDCProviders returns the provider from system settings followed by
+// the additional providers in display order, whether or not they are
+// available.
+func (s *AuthService) listOIDCProviders() []oidcSettings {
+	providers := []oidcSettings{s.getOIDCSettings()}
+
+	var records []model.OIDCProvider
+	if err := s.DB.Order("sort_order ASC, id ASC").Find(&records).Error; err != nil {
+		return providers
+	}
+	for _, record := range records {
+		providers = append(providers, oidcSettingsFromProvider(record))
+	}
+	return providers
+}
+
+// getAvailableOIDCProvider returns an enabled, configured provider. An empty
+// key selects the provider configured in system settings.
+func (s *AuthService) getAvailableOIDCProvider(key string) (oidcSettings, error) {
+	key = strings.TrimSpace(key)
+	if key == "" {
+		key = oidcProviderKey
+	}
+	for _, provider := range s.listOIDCProviders() {
+		if provider.Key == key && provider.isAvailable() {
+			return provider, nil
+		}
+	}
+	return oidcSettings{}, errors.New("oidc login is not available")
+}
+
+// mapOIDCIdentityClaims applies the provider's identity claim names, which
+// plain OAuth 2.0 providers such as GitHub need (their user id is "id" and
+// their username "login").
+func mapOIDCIdentityClaims(settings oidcSettings, claims *oidcIdentityClaims) (*oidcIdentityClaims, error) {
+	if claims.Raw == nil {
+		claims.Raw = map[string]interface{}{}
+	}
+	if settings.SubjectClaim != "" {
+		claims.Subject = firstOIDCClaimValue(claims.Raw, settings.SubjectClaim)
+	}
+	if settings.EmailClaim != "" {
+		claims.Email = firstOIDCClaimValue(claims.Raw, settings.EmailClaim)
+	}
+	if settings.UsernameClaim != "" {
+		claims.PreferredUsername = firstOIDCClaimValue(claims.Raw, settings.UsernameClaim)
+	}
+
+	claims.Subject = strings.TrimSpace(claims.Subject)
+	claims.Email = strings.TrimSpace(claims.Email)
+	if claims.Subject == "" {
+		return nil, errors.New("oidc subject is missing")
+	}
+	return claims, nil
+}
+
+// checkOIDCEmailDomain enforces the provider's allowed domains. The email
+// must be present and, when the provider says so, verified.
+func checkOIDCEmailDomain(settings oidcSettings, claims *oidcIdentityClaims) error {
+	if strings.TrimSpace(settings.AllowedDomains) == "" {
+		return nil
+	}
+	if claims.Email == "" {
+		return errors.New("oidc provider did not return an email")
+	}
+	if verified, ok := claims.Raw["email_verified"].(bool); ok && !verified {
+		return errors.New("oidc email is not verified")
+	}
+	if !isEmailDomainAllowed(claims.Email, settings.AllowedDomains) {
+		return ErrEmailDomainNotAllowed
+	}
+	return nil
+}
+
+// oidcAccountMapping is the role and status a login's claims map to. An
+// empty field means the provider does not map it.
+type oidcAccountMapping struct {
+	Role   string
+	Status string
+}
+
+func mapOIDCAccount(settings oidcSettings, claims *oidcIdentityClaims) oidcAccountMapping {
+	var mapping oidcAccountMapping
+	if settings.RoleClaim != "" {
+		mapping.Role = "user"
+		if matchesOIDCClaimValues(claims.Raw, settings.RoleClaim, settings.AdminValues) {
+			mapping.Role = "admin"
+		}
+	}
+	if settings.StatusClaim != "" {
+		mapping.Status = "disabled"
+		if matchesOIDCClaimValues(claims.Raw, settings.StatusClaim, settings.ActiveValues) {
+			mapping.Status = "active"
+		}
+	}
+	return mapping
+}
+
+// syncOIDCAccount applies a login's mapped role and status to the user, so
+// group changes at the identity provider take effect on the next sign-in.
+// As in the admin API, the first user always stays an active administrator.
+func (s *AuthService) syncOIDCAccount(user *model.User, mapping oidcAccountMapping) error {
+	updates := map[string]interface{}{}
+	if mapping.Role != "" && mapping.Role != user.Role && !(user.ID == 1 && mapping.Role == "user") {
+		updates["role"] = mapping.Role
+	}
+	if mapping.Status != "" && mapping.Status != user.Status && !(user.ID == 1 && mapping.Status == "disabled") {
+		updates["status"] = mapping.Status
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.User{}).Where("id = ?", user.ID).Updates(updates).Error; err != nil {
+			return err
+		}
+		if updates["status"] == "disabled" {
+			return revokeAllRefreshTokens(tx, user.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.New("failed to update account from oidc claims")
+	}
+
+	if role, ok := updates["role"].(string); ok {
+		user.Role = role
+	}
+	if status, ok := updates["status"].(string); ok {
+		user.Status = status
+	}
+	return nil
+}
+
+func matchesOIDCClaimValues(raw map[string]interface{}, path string, accepted []string) bool {
+	for _, value := range oidcClaimValues(raw, path) {
+		for _, candidate := range accepted {
+			if strings.EqualFold(value, candidate) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func hasOIDCClaim(raw map[string]interface{}, path string) bool {
+	if path == "" {
+		return true
+	}
+	_, ok := lookupOIDCClaim(raw, path)
+	return ok
+}
+
+func firstOIDCClaimValue(raw map[string]interface{}, path string) string {
+	values := oidcClaimValues(raw, path)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// oidcClaimValues returns a claim as strings. A list claim such as groups
+// yields one value per element.
+func oidcClaimValues(raw map[string]interface{}, path string) []string {
+	value, ok := lookupOIDCClaim(raw, path)
+	if !ok {
+		return nil
+	}
+	if list, ok := value.([]interface{}); ok {
+		values := make([]string, 0, len(list))
+		for _, item := range list {
+			if text, ok := formatOIDCClaimScalar(item); ok {
+				values = append(values, text)
+			}
+		}
+		return values
+	}
+	if text, ok := formatOIDCClaimScalar(value); ok {
+		return []string{text}
+	}
+	return nil
+}
+
+// lookupOIDCClaim resolves a claim name. A name is first tried as is, since
+// namespaced claims like "https://example.com/groups" contain dots, and then
+// as a dotted path into nested objects such as "realm_access.roles".
+func lookupOIDCClaim(raw map[string]interface{}, path string) (interface{}, bool) {
+	if value, ok := raw[path]; ok && value != nil {
+		return value, true
+	}
+
+	var current interface{} = raw
+	for _, part := range strings.Split(path, ".") {
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if current, ok = object[part]; !ok || current == nil {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+func formatOIDCClaimScalar(value interface{}) (string, bool) {
+	switch typed := value.(type) {
+	case string:
+		text := strings.TrimSpace(typed)
+		return text, text != ""
+	case float64:
+		return strconv.FormatFloat(typed, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(typed), true
+	default:
+		return "", false
+	}
+}
+
+func parseOIDCClaimValues(raw string) []string {
+	parts := strings.FieldsFunc(raw, func(r rune) bool {
+		return r == ',' || r == '\n'
+	})
+	seen := make(map[string]struct{}, len(parts))
+	values := make([]string, 0, len(parts))
+	for _, part := range parts {
+		value := strings.TrimSpace(part)
+		if value == "" {
+			continue
+		}
+		if _, exists := seen[value]; exists {
+			continue
+		}
+		seen[value] = struct{}{}
+		values = append(values, value)
+	}
+	return values
+}
@@ -0,0 +1,330 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/shiroha/subdux/internal/model"
+	"gorm.io/gorm"
+)
+
+func newOIDCProviderTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	t.Setenv("JWT_SECRET", "oidc-provider-test-secret-0123456789abcdef0123456789")
+	db := newTestDB(t)
+	if err := db.AutoMigrate(&model.OIDCConnection{}, &model.OIDCProvider{}); err != nil {
+		t.Fatalf("failed to migrate oidc tables: %v", err)
+	}
+	return db
+}
+
+func TestCreateOIDCProviderValidation(t *testing.T) {
+	db := newOIDCProviderTestDB(t)
+	admin := NewAdminService(db)
+	enabled := true
+
+	cases := []struct {
+		name  string
+		input OIDCProviderInput
+	}{
+		{"reserved key", OIDCProviderInput{Key: stringPtr("oidc"), Name: stringPtr("Corp")}},
+		{"invalid key", OIDCProviderInput{Key: stringPtr("Not Valid"), Name: stringPtr("Corp")}},
+		{"missing name", OIDCProviderInput{Key: stringPtr("corp")}},
+		{"unknown kind", OIDCProviderInput{Key: stringPtr("corp"), Name: stringPtr("Corp"), Kind: stringPtr("saml")}},
+		{"enabled without configuration", OIDCProviderInput{Key: stringPtr("corp"), Name: stringPtr("Corp"), Enabled: &enabled}},
+		{"role claim without values", OIDCProviderInput{Key: stringPtr("corp"), Name: stringPtr("Corp"), RoleClaim: stringPtr("groups")}},
+		{"invalid domain", OIDCProviderInput{Key: stringPtr("corp"), Name: stringPtr("Corp"), AllowedDomains: stringPtr("not a domain")}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := admin.CreateOIDCProvider(tc.input)
+			if !errors.Is(err, ErrInvalidOIDCProvider) && !errors.Is(err, ErrInvalidOIDCMapping) {
+				t.Fatalf("CreateOIDCProvider() error = %v, want a validation error", err)
+			}
+		})
+	}
+}
+
+func TestOIDCProviderLifecycle(t *testing.T) {
+	db := newOIDCProviderTestDB(t)
+	admin := NewAdminService(db)
+	enabled := true
+
+	created, err := admin.CreateOIDCProvider(OIDCProviderInput{
+		Key:                   stringPtr("github"),
+		Name:                  stringPtr("GitHub"),
+		Kind:                  stringPtr(OIDCProviderKindOAuth2),
+		Enabled:               &enabled,
+		ClientID:              stringPtr("client"),
+		ClientSecret:          stringPtr("secret"),
+		RedirectURL:           stringPtr("https://subdux.example.com/api/auth/oidc/callback"),
+		AuthorizationEndpoint: stringPtr("https://github.com/login/oauth/authorize"),
+		TokenEndpoint:         stringPtr("https://github.com/login/oauth/access_token"),
+		UserinfoEndpoint:      stringPtr("https://api.github.com/user"),
+		Scopes:                stringPtr("read:user user:email"),
+		SubjectClaim:          stringPtr("id"),
+		UsernameClaim:         stringPtr("login"),
+	})
+	if err != nil {
+		t.Fatalf("CreateOIDCProvider() error = %v", err)
+	}
+	if !created.ClientSecretSet || created.ClientSecret == "secret" {
+		t.Fatalf("client secret was not stored encrypted: %+v", created)
+	}
+	encoded, _ := json.Marshal(created)
+	if strings.Contains(string(encoded), created.ClientSecret) {
+		t.Fatalf("provider JSON exposes the client secret: %s", encoded)
+	}
+
+	if _, err := admin.CreateOIDCProvider(OIDCProviderInput{Key: stringPtr("github"), Name: stringPtr("Again")}); !errors.Is(err, ErrOIDCProviderKeyTaken) {
+		t.Fatalf("duplicate CreateOIDCProvider() error = %v, want ErrOIDCProviderKeyTaken", err)
+	}
+
+	auth := NewAuthService(db)
+	config := auth.GetOIDCPublicConfig()
+	if !config.Enabled || len(config.Providers) != 1 || config.Providers[0].Key != "github" || config.Providers[0].Name != "GitHub" {
+		t.Fatalf("GetOIDCPublicConfig() = %+v, want only the GitHub provider", config)
+	}
+	settings, err := auth.getAvailableOIDCProvider("github")
+	if err != nil {
+		t.Fatalf("getAvailableOIDCProvider() error = %v", err)
+	}
+	if settings.ClientSecret != "secret" || strings.Join(settings.Scopes, " ") != "read:user user:email" {
+		t.Fatalf("provider settings = %+v, want decrypted secret and scopes without openid", settings)
+	}
+
+	// An empty secret on update keeps the stored one; the key is fixed.
+	updated, err := admin.UpdateOIDCProvider(created.ID, OIDCProviderInput{Name: stringPtr("GitHub.com"), ClientSecret: stringPtr("")})
+	if err != nil {
+		t.Fatalf("UpdateOIDCProvider() error = %v", err)
+	}
+	if updated.Name != "GitHub.com" || updated.ClientSecret != created.ClientSecret {
+		t.Fatalf("UpdateOIDCProvider() = %+v, want new name and unchanged secret", updated)
+	}
+	if _, err := admin.UpdateOIDCProvider(created.ID, OIDCProviderInput{Key: stringPtr("gh")}); !errors.Is(err, ErrInvalidOIDCProvider) {
+		t.Fatalf("UpdateOIDCProvider() with new key error = %v, want ErrInvalidOIDCProvider", err)
+	}
+
+	user := createTestUser(t, db)
+	if err := db.Create(&model.OIDCConnection{UserID: user.ID, Provider: "github", Subject: "42"}).Error; err != nil {
+		t.Fatalf("failed to create connection: %v", err)
+	}
+	if err := admin.DeleteOIDCProvider(created.ID); err != nil {
+		t.Fatalf("DeleteOIDCProvider() error = %v", err)
+	}
+	var connections int64
+	db.Model(&model.OIDCConnection{}).Where("provider = ?", "github").Count(&connections)
+	if connections != 0 {
+		t.Fatalf("connections after delete = %d, want 0", connections)
+	}
+	if err := admin.DeleteOIDCProvider(created.ID); !errors.Is(err, ErrOIDCProviderNotFound) {
+		t.Fatalf("second DeleteOIDCProvider() error = %v, want ErrOIDCProviderNotFound", err)
+	}
+}
+
+func TestOIDCClaimLookup(t *testing.T) {
+	var raw map[string]interface{}
+	if err := json.Unmarshal([]byte(`{
+		"id": 1234567,
+		"login": "octocat",
+		"groups": ["staff", "subdux-admins"],
+		"realm_access": {"roles": ["offline_access", "admin"]},
+		"https://example.com/claims/status": "Active",
+		"enabled": true
+	}`), &raw); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := firstOIDCClaimValue(raw, "id"); got != "1234567" {
+		t.Fatalf("id = %q, want 1234567", got)
+	}
+	if !matchesOIDCClaimValues(raw, "groups", []string{"SUBDUX-ADMINS"}) {
+		t.Fatal("groups did not match subdux-admins case-insensitively")
+	}
+	if !matchesOIDCClaimValues(raw, "realm_access.roles", []string{"admin"}) {
+		t.Fatal("nested realm_access.roles did not match admin")
+	}
+	if !matchesOIDCClaimValues(raw, "https://example.com/claims/status", []string{"active"}) {
+		t.Fatal("namespaced claim with dots was not resolved")
+	}
+	if !matchesOIDCClaimValues(raw, "enabled", []string{"true"}) {
+		t.Fatal("boolean claim did not match true")
+	}
+	if hasOIDCClaim(raw, "missing.claim") {
+		t.Fatal("hasOIDCClaim() = true for a missing claim")
+	}
+
+	claims, err := mapOIDCIdentityClaims(oidcSettings{SubjectClaim: "id", UsernameClaim: "login"}, &oidcIdentityClaims{Raw: raw})
+	if err != nil {
+		t.Fatalf("mapOIDCIdentityClaims() error = %v", err)
+	}
+	if claims.Subject != "1234567" || claims.PreferredUsername != "octocat" {
+		t.Fatalf("mapped claims = %+v, want subject 1234567 and username octocat", claims)
+	}
+	if _, err := mapOIDCIdentityClaims(oidcSettings{SubjectClaim: "sub"}, &oidcIdentityClaims{Raw: raw}); err == nil {
+		t.Fatal("mapOIDCIdentityClaims() without a subject error = nil")
+	}
+}
+
+func TestFinishOIDCLoginSyncsRoleAndStatus(t *testing.T) {
+	db := newOIDCProviderTestDB(t)
+	auth := NewAuthService(db)
+
+	first := model.User{Username: "owner", Email: "owner@corp.example", Password: "x", Role: "admin", Status: "active"}
+	member := model.User{Username: "member", Email: "member@corp.example", Password: "x", Role: "user", Status: "active"}
+	for _, user := range []*model.User{&first, &member} {
+		if err := db.Create(user).Error; err != nil {
+			t.Fatalf("failed to create user: %v", err)
+		}
+	}
+	for _, connection := range []model.OIDCConnection{
+		{UserID: first.ID, Provider: "corp", Subject: "owner-sub"},
+		{UserID: member.ID, Provider: "corp", Subject: "member-sub"},
+	} {
+		if err := db.Create(&connection).Error; err != nil {
+			t.Fatalf("failed to create connection: %v", err)
+		}
+	}
+
+	settings := oidcSettings{
+		Key:          "corp",
+		RoleClaim:    "groups",
+		AdminValues:  []string{"subdux-admins"},
+		StatusClaim:  "groups",
+		ActiveValues: []string{"subdux-users", "subdux-admins"},
+	}
+	login := func(subject string, groups ...string) (OIDCSessionResult, error) {
+		list := make([]interface{}, 0, len(groups))
+		for _, group := range groups {
+			list = append(list, group)
+		}
+		return auth.finishOIDCLogin(settings, &oidcIdentityClaims{
+			Subject: subject,
+			Raw:     map[string]interface{}{"groups": list},
+		})
+	}
+	reload := func(id uint) model.User {
+		var user model.User
+		if err := db.First(&user, id).Error; err != nil {
+			t.Fatalf("failed to reload user: %v", err)
+		}
+		return user
+	}
+
+	if _, err := login("member-sub", "subdux-admins"); err != nil {
+		t.Fatalf("login as admin group member error = %v", err)
+	}
+	if got := reload(member.ID).Role; got != "admin" {
+		t.Fatalf("role after admin group login = %q, want admin", got)
+	}
+
+	if _, err := login("member-sub", "subdux-users"); err != nil {
+		t.Fatalf("login as user group member error = %v", err)
+	}
+	if got := reload(member.ID).Role; got != "user" {
+		t.Fatalf("role after leaving admin group = %q, want user", got)
+	}
+
+	if err := db.Create(&model.RefreshToken{UserID: member.ID, TokenHash: "member-token", ExpiresAt: first.CreatedAt.AddDate(1, 0, 0)}).Error; err != nil {
+		t.Fatalf("failed to create refresh token: %v", err)
+	}
+	if _, err := login("member-sub"); err == nil || err.Error() != "account is disabled" {
+		t.Fatalf("login without groups error = %v, want account is disabled", err)
+	}
+	if got := reload(member.ID).Status; got != "disabled" {
+		t.Fatalf("status after losing access = %q, want disabled", got)
+	}
+	var active int64
+	db.Model(&model.RefreshToken{}).Where("user_id = ? AND revoked_at IS NULL", member.ID).Count(&active)
+	if active != 0 {
+		t.Fatalf("active refresh tokens after disable = %d, want 0", active)
+	}
+
+	if _, err := login("member-sub", "subdux-users"); err != nil {
+		t.Fatalf("login after regaining access error = %v", err)
+	}
+	if got := reload(member.ID).Status; got != "active" {
+		t.Fatalf("status after regaining access = %q, want active", got)
+	}
+
+	// The first user stays an active administrator whatever the claims say.
+	if _, err := login("owner-sub", "subdux-users"); err != nil {
+		t.Fatalf("first user login error = %v", err)
+	}
+	if owner := reload(first.ID); owner.Role != "admin" || owner.Status != "active" {
+		t.Fatalf("first user = %s/%s, want admin/active", owner.Role, owner.Status)
+	}
+}
+
+func TestFinishOIDCLoginDomainRestrictionAndAutoCreate(t *testing.T) {
+	db := newOIDCProviderTestDB(t)
+	auth := NewAuthService(db)
+
+	settings := oidcSettings{
+		Key:            "corp",
+		AutoCreateUser: true,
+		AllowedDomains: "corp.example",
+		RoleClaim:      "groups",
+		AdminValues:    []string{"subdux-admins"},
+	}
+
+	cases := []struct {
+		name   string
+		claims oidcIdentityClaims
+		want   error
+	}{
+		{"other domain", oidcIdentityClaims{Subject: "a", Email: "someone@other.example"}, ErrEmailDomainNotAllowed},
+		{"missing email", oidcIdentityClaims{Subject: "b"}, nil},
+		{"unverified email", oidcIdentityClaims{Subject: "c", Email: "someone@corp.example", Raw: map[string]interface{}{"email_verified": false}}, nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			claims := tc.claims
+			_, err := auth.finishOIDCLogin(settings, &claims)
+			if err == nil || (tc.want != nil && !errors.Is(err, tc.want)) {
+				t.Fatalf("finishOIDCLogin() error = %v, want rejection", err)
+			}
+		})
+	}
+
+	claims := &oidcIdentityClaims{
+		Subject: "new-sub",
+		Email:   "new.admin@eu.corp.example",
+		Raw:     map[string]interface{}{"groups": []interface{}{"subdux-admins"}},
+	}
+	result, err := auth.finishOIDCLogin(settings, claims)
+	if err != nil {
+		t.Fatalf("finishOIDCLogin() auto-create error = %v", err)
+	}
+	if result.User == nil || result.User.Role != "admin" {
+		t.Fatalf("auto-created user = %+v, want admin role from claims", result.User)
+	}
+	var connection model.OIDCConnection
+	if err := db.Where("provider = ? AND subject = ?", "corp", "new-sub").First(&connection).Error; err != nil {
+		t.Fatalf("auto-created connection not stored under the provider key: %v", err)
+	}
+}
+
+func TestOIDCMappingSettingsValidation(t *testing.T) {
+	db := newTestDB(t)
+	admin := NewAdminService(db)
+
+	err := admin.UpdateSettings(UpdateSettingsInput{OIDCRoleClaim: stringPtr("groups")})
+	if !errors.Is(err, ErrInvalidOIDCMapping) {
+		t.Fatalf("UpdateSettings() role claim without values error = %v, want ErrInvalidOIDCMapping", err)
+	}
+
+	if err := admin.UpdateSettings(UpdateSettingsInput{
+		OIDCRoleClaim:      stringPtr("groups"),
+		OIDCAdminValues:    stringPtr("subdux-admins, ops ,subdux-admins"),
+		OIDCAllowedDomains: stringPtr("Corp.Example"),
+	}); err != nil {
+		t.Fatalf("UpdateSettings() error = %v", err)
+	}
+	settings := NewAuthService(db).getOIDCSettings()
+	if settings.RoleClaim != "groups" || strings.Join(settings.AdminValues, ",") != "subdux-admins,ops" || settings.AllowedDomains != "corp.example" {
+		t.Fatalf("stored mapping = %+v", settings)
+	}
+}
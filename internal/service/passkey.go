@@ -257,7 +257,7 @@ func (s *AuthService) FinishPasskeyLogin(sessionID string, parsedResponse *proto
 	}
 	user := resolved.account
 
-	authResp, err := s.issueAuthResponse(user)
+	authResp, err := s.issueAuthResponse(user, SessionViaPasskey)
 	if err != nil {
 		return nil, err
 	}
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -23,15 +24,46 @@ func NewPaymentMethodService(db *gorm.DB) *PaymentMethodService {
 }
 
 type CreatePaymentMethodInput struct {
-	Name      string `json:"name"`
-	Icon      string `json:"icon"`
-	SortOrder int    `json:"sort_order"`
+	Name        string `json:"name"`
+	Icon        string `json:"icon"`
+	SortOrder   int    `json:"sort_order"`
+	ExpiryMonth *int   `json:"expiry_month"`
+	ExpiryYear  *int   `json:"expiry_year"`
+	LastFour    string `json:"last_four"`
+	Issuer      string `json:"issuer"`
 }
 
+// UpdatePaymentMethodInput changes only the fields it carries. The expiry is
+// replaced as a pair: sending expiry_month and expiry_year as null clears it.
 type UpdatePaymentMethodInput struct {
-	Name      *string `json:"name"`
-	Icon      *string `json:"icon"`
-	SortOrder *int    `json:"sort_order"`
+	Name        *string `json:"name"`
+	Icon        *string `json:"icon"`
+	SortOrder   *int    `json:"sort_order"`
+	ExpiryMonth *int    `json:"expiry_month"`
+	ExpiryYear  *int    `json:"expiry_year"`
+	LastFour    *string `json:"last_four"`
+	Issuer      *string `json:"issuer"`
+
+	ExpirySet bool `json:"-"`
+}
+
+func (input *UpdatePaymentMethodInput) UnmarshalJSON(data []byte) error {
+	type alias UpdatePaymentMethodInput
+	var decoded alias
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*input = UpdatePaymentMethodInput(decoded)
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	_, monthSet := raw["expiry_month"]
+	_, yearSet := raw["expiry_year"]
+	input.ExpirySet = monthSet || yearSet
+	return nil
 }
 
 func (s *PaymentMethodService) List(userID uint) ([]model.PaymentMethod, error) {
@@ -45,9 +77,13 @@ func (s *PaymentMethodService) Create(userID uint, input CreatePaymentMethodInpu
 	if name == "" || len(name) > 50 {
 		return nil, errors.New("name must be 1-50 characters")
 	}
+	card, err := normalizePaymentMethodCardDetails(input.ExpiryMonth, input.ExpiryYear, input.LastFour, input.Issuer)
+	if err != nil {
+		return nil, err
+	}
 
 	var existing model.PaymentMethod
-	err := s.DB.Where("user_id = ? AND name = ?", userID, name).First(&existing).Error
+	err = s.DB.Where("user_id = ? AND name = ?", userID, name).First(&existing).Error
 	if err == nil {
 		return nil, errors.New("payment method name already exists")
 	}
@@ -59,6 +95,10 @@ func (s *PaymentMethodService) Create(userID uint, input CreatePaymentMethodInpu
 		NameCustomized: true,
 		Icon:           strings.TrimSpace(input.Icon),
 		SortOrder:      input.SortOrder,
+		ExpiryMonth:    card.ExpiryMonth,
+		ExpiryYear:     card.ExpiryYear,
+		LastFour:       card.LastFour,
+		Issuer:         card.Issuer,
 	}
 
 	if err := s.DB.Create(&method).Error; err != nil {
@@ -101,6 +141,26 @@ func (s *PaymentMethodService) Update(userID, id uint, input UpdatePaymentMethod
 		method.SortOrder = *input.SortOrder
 	}
 
+	expiryMonth, expiryYear := method.ExpiryMonth, method.ExpiryYear
+	if input.ExpirySet || input.ExpiryMonth != nil || input.ExpiryYear != nil {
+		expiryMonth, expiryYear = input.ExpiryMonth, input.ExpiryYear
+	}
+	lastFour, issuer := method.LastFour, method.Issuer
+	if input.LastFour != nil {
+		lastFour = *input.LastFour
+	}
+	if input.Issuer != nil {
+		issuer = *input.Issuer
+	}
+	card, err := normalizePaymentMethodCardDetails(expiryMonth, expiryYear, lastFour, issuer)
+	if err != nil {
+		return nil, err
+	}
+	method.ExpiryMonth = card.ExpiryMonth
+	method.ExpiryYear = card.ExpiryYear
+	method.LastFour = card.LastFour
+	method.Issuer = card.Issuer
+
 	if err := s.DB.Save(method).Error; err != nil {
 		return nil, err
 	}
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/shiroha/subdux/internal/model"
+	"gorm.io/gorm"
+)
+
+const (
+	subscriptionEventPaymentMethodReplaced = "payment_method_replaced"
+
+	minPaymentMethodExpiryYear = 2000
+	maxPaymentMethodExpiryYear = 2100
+	maxPaymentMethodIssuerLen  = 100
+)
+
+// paymentMethodCardDetails holds the optional card fields shared by the create
+// and update inputs once they have been validated.
+type paymentMethodCardDetails struct {
+	ExpiryMonth *int
+	ExpiryYear  *int
+	LastFour    string
+	Issuer      string
+}
+
+func normalizePaymentMethodCardDetails(expiryMonth, expiryYear *int, lastFour, issuer string) (paymentMethodCardDetails, error) {
+	details := paymentMethodCardDetails{
+		LastFour: strings.TrimSpace(lastFour),
+		Issuer:   strings.TrimSpace(issuer),
+	}
+	if (expiryMonth == nil) != (expiryYear == nil) {
+		return details, errors.New("expiry_month and expiry_year must be set together")
+	}
+	if expiryMonth != nil {
+		if *expiryMonth < 1 || *expiryMonth > 12 {
+			return details, errors.New("expiry_month must be between 1 and 12")
+		}
+		if *expiryYear < minPaymentMethodExpiryYear || *expiryYear > maxPaymentMethodExpiryYear {
+			return details, fmt.Errorf("expiry_year must be between %d and %d", minPaymentMethodExpiryYear, maxPaymentMethodExpiryYear)
+		}
+		month, year := *expiryMonth, *expiryYear
+		details.ExpiryMonth = &month
+		details.ExpiryYear = &year
+	}
+	if details.LastFour != "" && !isFourDigits(details.LastFour) {
+		return details, errors.New("last_four must be exactly 4 digits")
+	}
+	if utf8.RuneCountInString(details.Issuer) > maxPaymentMethodIssuerLen {
+		return details, fmt.Errorf("issuer must be at most %d characters", maxPaymentMethodIssuerLen)
+	}
+	return details, nil
+}
+
+func isFourDigits(value string) bool {
+	if len(value) != 4 {
+		return false
+	}
+	for _, r := range value {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// PaymentMethodExpiryDate returns the last day a card is valid, the final day
+// of its expiry month, as midnight UTC.
+func PaymentMethodExpiryDate(method model.PaymentMethod) (time.Time, bool) {
+	if method.ExpiryMonth == nil || method.ExpiryYear == nil {
+		return time.Time{}, false
+	}
+	return time.Date(*method.ExpiryYear, time.Month(*method.ExpiryMonth)+1, 0, 0, 0, 0, 0, time.UTC), true
+}
+
+// paymentMethodLabel names a payment method with its card details, for
+// example "Visa (Chase, ending 4242)".
+func paymentMethodLabel(method model.PaymentMethod) string {
+	var details []string
+	if method.Issuer != "" {
+		details = append(details, method.Issuer)
+	}
+	if method.LastFour != "" {
+		details = append(details, "ending "+method.LastFour)
+	}
+	if len(details) == 0 {
+		return method.Name
+	}
+	return fmt.Sprintf("%s (%s)", method.Name, strings.Join(details, ", "))
+}
+
+type ReplacePaymentMethodInput struct {
+	ReplacementID uint `json:"replacement_id"`
+	// DeleteOld removes the replaced payment method once its subscriptions
+	// have moved.
+	DeleteOld bool `json:"delete_old"`
+}
+
+type PaymentMethodReplacement struct {
+	From                 model.PaymentMethod `json:"from"`
+	To                   model.PaymentMethod `json:"to"`
+	MovedSubscriptionIDs []uint              `json:"moved_subscription_ids"`
+	DeletedOld           bool                `json:"deleted_old"`
+}
+
+// Replace moves every subscription billed to payment method id onto the
+// replacement, recording a payment_method_replaced event for each, for
+// example after a card has been reissued.
+func (s *PaymentMethodService) Replace(userID, id uint, input ReplacePaymentMethodInput) (*PaymentMethodReplacement, error) {
+	if input.ReplacementID == 0 {
+		return nil, errors.New("replacement_id is required")
+	}
+	if input.ReplacementID == id {
+		return nil, errors.New("replacement must be a different payment method")
+	}
+	from, err := s.GetByID(userID, id)
+	if err != nil {
+		return nil, errors.New("payment method not found")
+	}
+	to, err := s.GetByID(userID, input.ReplacementID)
+	if err != nil {
+		return nil, errors.New("replacement payment method not found")
+	}
+
+	result := &PaymentMethodReplacement{From: *from, To: *to, MovedSubscriptionIDs: []uint{}}
+	if err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var subs []model.Subscription
+		if err := tx.Where("user_id = ? AND payment_method_id = ?", userID, id).Order("id ASC").Find(&subs).Error; err != nil {
+			return err
+		}
+
+		subscriptions := &SubscriptionService{DB: tx}
+		for _, sub := range subs {
+			if err := tx.Model(&model.Subscription{}).
+				Where("id = ? AND user_id = ?", sub.ID, userID).
+				Update("payment_method_id", to.ID).Error; err != nil {
+				return err
+			}
+			after := sub
+			after.PaymentMethodID = &to.ID
+			if err := subscriptions.recordSubscriptionChanged(userID, sub, after, subscriptionEventPaymentMethodReplaced); err != nil {
+				return err
+			}
+			result.MovedSubscriptionIDs = append(result.MovedSubscriptionIDs, sub.ID)
+		}
+
+		if input.DeleteOld {
+			if err := tx.Delete(&model.PaymentMethod{}, "id = ? AND user_id = ?", id, userID).Error; err != nil {
+				return err
+			}
+			result.DeletedOld = true
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	if result.DeletedOld {
+		s.removeManagedIconFile(from.Icon)
+	}
+	return result, nil
+}
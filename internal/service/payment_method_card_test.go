@@ -0,0 +1,207 @@
+package service
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shiroha/subdux/internal/model"
+	"github.com/shiroha/subdux/internal/pkg"
+	"gorm.io/gorm"
+)
+
+func createCardTestPaymentMethod(t *testing.T, db *gorm.DB, userID uint, name string, month, year int) model.PaymentMethod {
+	t.Helper()
+
+	method, err := NewPaymentMethodService(db).Create(userID, CreatePaymentMethodInput{
+		Name:        name,
+		ExpiryMonth: &month,
+		ExpiryYear:  &year,
+		LastFour:    "4242",
+		Issuer:      "Chase",
+	})
+	if err != nil {
+		t.Fatalf("create payment method failed: %v", err)
+	}
+	return *method
+}
+
+func TestPaymentMethodCardDetailsValidation(t *testing.T) {
+	db := newTestDB(t)
+	user := createTestUser(t, db)
+	methods := NewPaymentMethodService(db)
+
+	month, year := 13, 2027
+	if _, err := methods.Create(user.ID, CreatePaymentMethodInput{Name: "Bad Month", ExpiryMonth: &month, ExpiryYear: &year}); err == nil {
+		t.Fatal("Create() with month 13 error = nil")
+	}
+	month = 5
+	if _, err := methods.Create(user.ID, CreatePaymentMethodInput{Name: "Month Only", ExpiryMonth: &month}); err == nil {
+		t.Fatal("Create() with month but no year error = nil")
+	}
+	if _, err := methods.Create(user.ID, CreatePaymentMethodInput{Name: "Bad Digits", LastFour: "42a2"}); err == nil {
+		t.Fatal("Create() with non-digit last_four error = nil")
+	}
+
+	method := createCardTestPaymentMethod(t, db, user.ID, "Visa", 5, 2027)
+	if method.ExpiryMonth == nil || *method.ExpiryMonth != 5 || method.LastFour != "4242" || method.Issuer != "Chase" {
+		t.Fatalf("created method = %+v, want card details", method)
+	}
+
+	var input UpdatePaymentMethodInput
+	if err := json.Unmarshal([]byte(`{"expiry_month":null,"expiry_year":null}`), &input); err != nil {
+		t.Fatalf("unmarshal update input failed: %v", err)
+	}
+	updated, err := methods.Update(user.ID, method.ID, input)
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if updated.ExpiryMonth != nil || updated.ExpiryYear != nil {
+		t.Fatalf("expiry = %v/%v, want cleared", updated.ExpiryMonth, updated.ExpiryYear)
+	}
+	if updated.LastFour != "4242" {
+		t.Fatalf("last_four = %q, want it kept", updated.LastFour)
+	}
+}
+
+func TestReplacePaymentMethodMovesSubscriptionsAndRecordsEvents(t *testing.T) {
+	t.Setenv("DATA_PATH", t.TempDir())
+	db := newTestDB(t)
+	user := createTestUser(t, db)
+	subscriptions := NewSubscriptionService(db)
+	methods := NewPaymentMethodService(db)
+
+	oldCard := createCardTestPaymentMethod(t, db, user.ID, "Old Visa", 10, 2026)
+	newCard := createCardTestPaymentMethod(t, db, user.ID, "New Visa", 10, 2030)
+	first := createCustomFieldTestSubscription(t, subscriptions, user.ID, nil)
+	second := createCustomFieldTestSubscription(t, subscriptions, user.ID, nil)
+	unlinked := createCustomFieldTestSubscription(t, subscriptions, user.ID, nil)
+	if err := db.Model(&model.Subscription{}).Where("id IN ?", []uint{first, second}).Update("payment_method_id", oldCard.ID).Error; err != nil {
+		t.Fatalf("failed to link subscriptions: %v", err)
+	}
+
+	if _, err := methods.Replace(user.ID, oldCard.ID, ReplacePaymentMethodInput{ReplacementID: oldCard.ID}); err == nil {
+		t.Fatal("Replace() onto itself error = nil")
+	}
+
+	result, err := methods.Replace(user.ID, oldCard.ID, ReplacePaymentMethodInput{ReplacementID: newCard.ID, DeleteOld: true})
+	if err != nil {
+		t.Fatalf("Replace() error = %v", err)
+	}
+	if len(result.MovedSubscriptionIDs) != 2 || result.MovedSubscriptionIDs[0] != first || result.MovedSubscriptionIDs[1] != second {
+		t.Fatalf("moved = %v, want [%d %d]", result.MovedSubscriptionIDs, first, second)
+	}
+	if !result.DeletedOld {
+		t.Fatal("deleted_old = false, want true")
+	}
+
+	var linked int64
+	if err := db.Model(&model.Subscription{}).Where("payment_method_id = ?", newCard.ID).Count(&linked).Error; err != nil {
+		t.Fatalf("count linked subscriptions failed: %v", err)
+	}
+	if linked != 2 {
+		t.Fatalf("subscriptions on replacement = %d, want 2", linked)
+	}
+	var events []model.SubscriptionEvent
+	if err := db.Where("type = ?", subscriptionEventPaymentMethodReplaced).Order("subscription_id ASC").Find(&events).Error; err != nil {
+		t.Fatalf("query events failed: %v", err)
+	}
+	if len(events) != 2 || *events[0].SubscriptionID != first || events[0].NewPaymentMethodName != "New Visa" {
+		t.Fatalf("events = %+v, want one payment_method_replaced event per moved subscription", events)
+	}
+	var unlinkedEvents int64
+	db.Model(&model.SubscriptionEvent{}).Where("type = ? AND subscription_id = ?", subscriptionEventPaymentMethodReplaced, unlinked).Count(&unlinkedEvents)
+	if unlinkedEvents != 0 {
+		t.Fatalf("unlinked subscription events = %d, want 0", unlinkedEvents)
+	}
+	if _, err := methods.GetByID(user.ID, oldCard.ID); err == nil {
+		t.Fatal("old payment method still exists after delete_old")
+	}
+}
+
+func TestActionCenterFlagsExpiringPaymentMethod(t *testing.T) {
+	restoreClock := pkg.SetNowForTest(mustDate(t, "2026-10-18"))
+	t.Cleanup(restoreClock)
+
+	db := newTestDB(t)
+	user := createTestUser(t, db)
+	subscriptions := NewSubscriptionService(db)
+
+	expiring := createCardTestPaymentMethod(t, db, user.ID, "Visa", 10, 2026)
+	createCardTestPaymentMethod(t, db, user.ID, "Unused Visa", 10, 2026)
+	valid := createCardTestPaymentMethod(t, db, user.ID, "Amex", 6, 2028)
+	first := createCustomFieldTestSubscription(t, subscriptions, user.ID, nil)
+	second := createCustomFieldTestSubscription(t, subscriptions, user.ID, nil)
+	other := createCustomFieldTestSubscription(t, subscriptions, user.ID, nil)
+	db.Model(&model.Subscription{}).Where("id IN ?", []uint{first, second}).Update("payment_method_id", expiring.ID)
+	db.Model(&model.Subscription{}).Where("id = ?", other).Update("payment_method_id", valid.ID)
+
+	center, err := subscriptions.GetActionCenter(user.ID, "USD", nil)
+	if err != nil {
+		t.Fatalf("GetActionCenter() error = %v", err)
+	}
+	var items []SubscriptionAction
+	for _, item := range center.Items {
+		if item.Type == actionTypeCardExpiring {
+			items = append(items, item)
+		}
+	}
+	if len(items) != 1 {
+		t.Fatalf("payment method expiry items = %d, want 1", len(items))
+	}
+	item := items[0]
+	if item.PaymentMethodID == nil || *item.PaymentMethodID != expiring.ID {
+		t.Fatalf("payment_method_id = %v, want %d", item.PaymentMethodID, expiring.ID)
+	}
+	if item.Severity != actionSeverityMedium || item.DueDate == nil || *item.DueDate != "2026-10-31" {
+		t.Fatalf("item = %+v, want medium severity due 2026-10-31", item)
+	}
+	if len(item.RelatedSubscriptionIDs) != 2 {
+		t.Fatalf("related subscriptions = %v, want both linked subscriptions", item.RelatedSubscriptionIDs)
+	}
+}
+
+func TestEnqueuePendingNotificationsQueuesPaymentMethodExpiryOnce(t *testing.T) {
+	db := newNotificationDigestTestDB(t)
+	user := createNotificationOutboxUser(t, db)
+	createNotificationOutboxTemplate(t, db, user.ID)
+	now := time.Date(2026, 10, 25, 9, 0, 0, 0, time.UTC)
+	restoreClock := pkg.SetNowForTest(now)
+	t.Cleanup(restoreClock)
+
+	month, year := 10, 2026
+	method := model.PaymentMethod{UserID: user.ID, Name: "Visa", ExpiryMonth: &month, ExpiryYear: &year, LastFour: "4242"}
+	if err := db.Create(&method).Error; err != nil {
+		t.Fatalf("failed to create payment method: %v", err)
+	}
+	sub := createNotificationOutboxSubscription(t, db, user.ID, time.Date(2026, 12, 1, 0, 0, 0, 0, time.UTC))
+	if err := db.Model(&sub).Update("payment_method_id", method.ID).Error; err != nil {
+		t.Fatalf("failed to link subscription: %v", err)
+	}
+	createNotificationOutboxChannel(t, db, user.ID, "webhook", `{"url":"https://notify.example.com/hook"}`)
+
+	svc := NewNotificationService(db, NewNotificationTemplateService(db, NewTemplateValidator()), NewTemplateRenderer(NewTemplateValidator()))
+	for i := 0; i < 2; i++ {
+		if err := svc.EnqueuePendingNotifications(); err != nil {
+			t.Fatalf("EnqueuePendingNotifications() error = %v", err)
+		}
+	}
+
+	var jobs []model.NotificationOutbox
+	if err := db.Where("user_id = ? AND trigger_type = ?", user.ID, notificationTriggerCardExpiring).Find(&jobs).Error; err != nil {
+		t.Fatalf("query outbox failed: %v", err)
+	}
+	if len(jobs) != 1 {
+		t.Fatalf("payment method expiry outbox count = %d, want 1", len(jobs))
+	}
+	job := jobs[0]
+	if !strings.Contains(job.DedupeKey, ":2026-10:7:") {
+		t.Fatalf("dedupe key = %q, want 7-day lead for 2026-10", job.DedupeKey)
+	}
+	for _, want := range []string{"Visa (ending 4242) expires on Oct 31, 2026", "- " + sub.Name + ": "} {
+		if !strings.Contains(job.Message, want) {
+			t.Fatalf("message = %q, want to contain %q", job.Message, want)
+		}
+	}
+}
@@ -0,0 +1,400 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/shiroha/subdux/internal/model"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultProxyAuthUserHeader   = "Remote-User"
+	defaultProxyAuthEmailHeader  = "Remote-Email"
+	defaultProxyAuthGroupsHeader = "Remote-Groups"
+	maxProxyAuthHeaderValueBytes = 1024
+)
+
+var (
+	ErrInvalidProxyAuthSettings = errors.New("invalid proxy authentication settings")
+	ErrProxyAuthDisabled        = errors.New("proxy authentication is disabled")
+	ErrProxyAuthUntrustedSource = errors.New("request did not come from a trusted proxy")
+	ErrProxyAuthMissingIdentity = errors.New("trusted proxy did not send a user identity")
+	ErrProxyAuthUnknownUser     = errors.New("no account matches the proxy identity")
+)
+
+type proxyAuthConfig struct {
+	Enabled        bool
+	TrustedNets    []*net.IPNet
+	UserHeader     string
+	EmailHeader    string
+	GroupsHeader   string
+	AdminGroups    []string
+	AutoCreateUser bool
+}
+
+// proxyIdentity is what a trusted forward-auth proxy asserted about the user.
+type proxyIdentity struct {
+	Username string
+	Email    string
+	Groups   []string
+}
+
+func loadProxyAuthConfig(db *gorm.DB) (*proxyAuthConfig, error) {
+	values := make(map[string]string)
+	for key, def := range map[string]string{
+		"proxy_auth_enabled":          "false",
+		"proxy_auth_trusted_cidrs":    "",
+		"proxy_auth_user_header":      defaultProxyAuthUserHeader,
+		"proxy_auth_email_header":     defaultProxyAuthEmailHeader,
+		"proxy_auth_groups_header":    defaultProxyAuthGroupsHeader,
+		"proxy_auth_admin_groups":     "",
+		"proxy_auth_auto_create_user": "false",
+	} {
+		value, err := getSystemSettingValue(db, key, def)
+		if err != nil {
+			return nil, err
+		}
+		values[key] = value
+	}
+
+	trustedNets, err := parseProxyAuthCIDRs(values["proxy_auth_trusted_cidrs"])
+	if err != nil {
+		return nil, err
+	}
+
+	return &proxyAuthConfig{
+		Enabled:        values["proxy_auth_enabled"] == "true",
+		TrustedNets:    trustedNets,
+		UserHeader:     strings.TrimSpace(values["proxy_auth_user_header"]),
+		EmailHeader:    strings.TrimSpace(values["proxy_auth_email_header"]),
+		GroupsHeader:   strings.TrimSpace(values["proxy_auth_groups_header"]),
+		AdminGroups:    splitProxyAuthList(values["proxy_auth_admin_groups"]),
+		AutoCreateUser: values["proxy_auth_auto_create_user"] == "true",
+	}, nil
+}
+
+func applyProxyAuthSettings(tx *gorm.DB, input UpdateSettingsInput) error {
+	if input.ProxyAuthEnabled == nil && input.ProxyAuthTrustedCIDRs == nil && input.ProxyAuthUserHeader == nil &&
+		input.ProxyAuthEmailHeader == nil && input.ProxyAuthGroupsHeader == nil && input.ProxyAuthAdminGroups == nil &&
+		input.ProxyAuthAutoCreateUser == nil {
+		return nil
+	}
+
+	cfg, err := loadProxyAuthConfig(tx)
+	if err != nil {
+		cfg = &proxyAuthConfig{}
+	}
+	enabled := cfg.Enabled
+	if input.ProxyAuthEnabled != nil {
+		enabled = *input.ProxyAuthEnabled
+	}
+	trustedNets := cfg.TrustedNets
+	if input.ProxyAuthTrustedCIDRs != nil {
+		trustedNets, err = parseProxyAuthCIDRs(*input.ProxyAuthTrustedCIDRs)
+		if err != nil {
+			return err
+		}
+	}
+	userHeader := cfg.UserHeader
+	if input.ProxyAuthUserHeader != nil {
+		userHeader = strings.TrimSpace(*input.ProxyAuthUserHeader)
+	}
+	emailHeader := cfg.EmailHeader
+	if input.ProxyAuthEmailHeader != nil {
+		emailHeader = strings.TrimSpace(*input.ProxyAuthEmailHeader)
+	}
+
+	for _, header := range []*string{input.ProxyAuthUserHeader, input.ProxyAuthEmailHeader, input.ProxyAuthGroupsHeader} {
+		if header != nil && !isValidProxyAuthHeaderName(strings.TrimSpace(*header)) {
+			return fmt.Errorf("%w: %q is not a valid header name", ErrInvalidProxyAuthSettings, strings.TrimSpace(*header))
+		}
+	}
+	if enabled {
+		if len(trustedNets) == 0 {
+			return fmt.Errorf("%w: at least one trusted proxy cidr is required", ErrInvalidProxyAuthSettings)
+		}
+		if userHeader == "" && emailHeader == "" {
+			return fmt.Errorf("%w: a user or email header is required", ErrInvalidProxyAuthSettings)
+		}
+	}
+
+	if input.ProxyAuthEnabled != nil {
+		if err := saveBoolSystemSetting(tx, "proxy_auth_enabled", *input.ProxyAuthEnabled); err != nil {
+			return err
+		}
+	}
+	if input.ProxyAuthAutoCreateUser != nil {
+		if err := saveBoolSystemSetting(tx, "proxy_auth_auto_create_user", *input.ProxyAuthAutoCreateUser); err != nil {
+			return err
+		}
+	}
+	if input.ProxyAuthTrustedCIDRs != nil {
+		cidrs := make([]string, 0, len(trustedNets))
+		for _, network := range trustedNets {
+			cidrs = append(cidrs, network.String())
+		}
+		if err := saveStringSystemSetting(tx, "proxy_auth_trusted_cidrs", strings.Join(cidrs, ",")); err != nil {
+			return err
+		}
+	}
+	if input.ProxyAuthAdminGroups != nil {
+		if err := saveStringSystemSetting(tx, "proxy_auth_admin_groups", strings.Join(splitProxyAuthList(*input.ProxyAuthAdminGroups), ",")); err != nil {
+			return err
+		}
+	}
+	for key, value := range map[string]*string{
+		"proxy_auth_user_header":   input.ProxyAuthUserHeader,
+		"proxy_auth_email_header":  input.ProxyAuthEmailHeader,
+		"proxy_auth_groups_header": input.ProxyAuthGroupsHeader,
+	} {
+		if value == nil {
+			continue
+		}
+		if err := saveStringSystemSetting(tx, key, http.CanonicalHeaderKey(strings.TrimSpace(*value))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseProxyAuthCIDRs accepts CIDRs or bare addresses separated by commas or
+// whitespace. A bare address trusts that single host.
+func parseProxyAuthCIDRs(value string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, item := range splitProxyAuthList(strings.ReplaceAll(value, "\n", ",")) {
+		for _, field := range strings.Fields(item) {
+			if !strings.Contains(field, "/") {
+				ip := net.ParseIP(field)
+				if ip == nil {
+					return nil, fmt.Errorf("%w: %q is not an ip address or cidr", ErrInvalidProxyAuthSettings, field)
+				}
+				bits := 128
+				if ip.To4() != nil {
+					ip = ip.To4()
+					bits = 32
+				}
+				nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+				continue
+			}
+			_, network, err := net.ParseCIDR(field)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %q is not an ip address or cidr", ErrInvalidProxyAuthSettings, field)
+			}
+			nets = append(nets, network)
+		}
+	}
+	return nets, nil
+}
+
+func splitProxyAuthList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func isValidProxyAuthHeaderName(name string) bool {
+	if name == "" {
+		return true
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+func (cfg *proxyAuthConfig) trusts(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(strings.TrimSpace(host))
+	if ip == nil {
+		return false
+	}
+	for _, network := range cfg.TrustedNets {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (cfg *proxyAuthConfig) identity(header http.Header) (*proxyIdentity, error) {
+	read := func(name string) string {
+		if name == "" {
+			return ""
+		}
+		value := strings.TrimSpace(header.Get(name))
+		if len(value) > maxProxyAuthHeaderValueBytes {
+			return ""
+		}
+		return value
+	}
+
+	identity := &proxyIdentity{
+		Username: read(cfg.UserHeader),
+		Email:    read(cfg.EmailHeader),
+	}
+	if groups := read(cfg.GroupsHeader); groups != "" {
+		identity.Groups = splitProxyAuthList(groups)
+	}
+	if identity.Username == "" && identity.Email == "" {
+		return nil, ErrProxyAuthMissingIdentity
+	}
+	return identity, nil
+}
+
+// roleFor returns the role the proxy's group header grants, or "" when role
+// mapping is not configured.
+func (cfg *proxyAuthConfig) roleFor(identity *proxyIdentity) string {
+	if cfg.GroupsHeader == "" || len(cfg.AdminGroups) == 0 {
+		return ""
+	}
+	for _, group := range identity.Groups {
+		for _, admin := range cfg.AdminGroups {
+			if strings.EqualFold(group, admin) {
+				return "admin"
+			}
+		}
+	}
+	return "user"
+}
+
+// LoginWithProxyHeaders establishes a session for the user a trusted
+// forward-auth proxy (Authelia, Authentik, ...) has already authenticated.
+// Only the direct peer address is checked against the trusted CIDRs, never
+// X-Forwarded-For, and the proxy must strip the identity headers from client
+// requests. TOTP is not asked for again since the proxy owns the login.
+func (s *AuthService) LoginWithProxyHeaders(remoteAddr string, header http.Header) (*LoginResponse, error) {
+	cfg, err := loadProxyAuthConfig(s.DB)
+	if err != nil || !cfg.Enabled {
+		return nil, ErrProxyAuthDisabled
+	}
+	if !cfg.trusts(remoteAddr) {
+		return nil, ErrProxyAuthUntrustedSource
+	}
+
+	identity, err := cfg.identity(header)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.findProxyUser(identity)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if !cfg.AutoCreateUser {
+			return nil, ErrProxyAuthUnknownUser
+		}
+		role := cfg.roleFor(identity)
+		if role == "" {
+			role = "user"
+		}
+		user, err = s.createProxyUser(identity, role)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if user.Status == "disabled" {
+		return nil, errors.New("account is disabled")
+	}
+	if role := cfg.roleFor(identity); role != "" && role != user.Role && !(user.ID == 1 && role != "admin") {
+		if err := s.DB.Model(&model.User{}).Where("id = ?", user.ID).Update("role", role).Error; err != nil {
+			return nil, err
+		}
+		user.Role = role
+	}
+
+	authResp, err := s.issueAuthResponse(*user, SessionViaProxy)
+	if err != nil {
+		return nil, err
+	}
+	return &LoginResponse{
+		AccessToken:  authResp.AccessToken,
+		RefreshToken: authResp.RefreshToken,
+		User:         &authResp.User,
+	}, nil
+}
+
+// findProxyUser matches the asserted email first, then the username header,
+// which may itself carry an email (X-Forwarded-Email style setups).
+func (s *AuthService) findProxyUser(identity *proxyIdentity) (*model.User, error) {
+	var user model.User
+	if identity.Email != "" {
+		err := s.DB.Where("LOWER(email) = ?", normalizeEmail(identity.Email)).First(&user).Error
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return &user, err
+		}
+	}
+	if identity.Username != "" {
+		err := s.DB.Where("username = ? OR LOWER(email) = ?", identity.Username, strings.ToLower(identity.Username)).First(&user).Error
+		if err != nil {
+			return nil, err
+		}
+		return &user, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (s *AuthService) createProxyUser(identity *proxyIdentity, role string) (*model.User, error) {
+	email := identity.Email
+	if email == "" && strings.Contains(identity.Username, "@") {
+		email = identity.Username
+	}
+	email = normalizeEmail(email)
+	if email == "" {
+		return nil, errors.New("trusted proxy did not send an email to create the account with")
+	}
+	if err := s.enforceEmailDomainWhitelist(email); err != nil {
+		return nil, err
+	}
+
+	usernameSeed := identity.Username
+	if usernameSeed == "" || strings.Contains(usernameSeed, "@") {
+		usernameSeed = email
+		if at := strings.Index(usernameSeed, "@"); at > 0 {
+			usernameSeed = usernameSeed[:at]
+		}
+	}
+	username, err := s.allocateOIDCUsername(usernameSeed)
+	if err != nil {
+		return nil, err
+	}
+
+	randomPassword, err := generateSecureToken(24)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(randomPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	user := model.User{
+		Username: username,
+		Email:    email,
+		Password: string(hash),
+		Role:     role,
+		Status:   "active",
+	}
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		return SeedUserDefaults(tx, user.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/shiroha/subdux/internal/model"
+	"gorm.io/gorm"
+)
+
+func newProxyAuthTestDB(t *testing.T, autoCreate bool) *gorm.DB {
+	t.Helper()
+	t.Setenv("JWT_SECRET", "proxy-auth-test-secret-0123456789abcdef0123456789")
+	db := newTestDB(t)
+
+	enabled := true
+	if err := applyProxyAuthSettings(db, UpdateSettingsInput{
+		ProxyAuthEnabled:        &enabled,
+		ProxyAuthTrustedCIDRs:   stringPtr("10.0.0.0/8, 192.168.1.5"),
+		ProxyAuthAdminGroups:    stringPtr("subdux-admins"),
+		ProxyAuthAutoCreateUser: &autoCreate,
+	}); err != nil {
+		t.Fatalf("applyProxyAuthSettings() error = %v", err)
+	}
+	return db
+}
+
+func proxyHeaders(values map[string]string) http.Header {
+	header := http.Header{}
+	for key, value := range values {
+		header.Set(key, value)
+	}
+	return header
+}
+
+func TestProxyAuthIsDisabledByDefault(t *testing.T) {
+	db := newTestDB(t)
+	auth := NewAuthService(db)
+
+	_, err := auth.LoginWithProxyHeaders("10.0.0.1:4000", proxyHeaders(map[string]string{"Remote-User": "alice"}))
+	if !errors.Is(err, ErrProxyAuthDisabled) {
+		t.Fatalf("LoginWithProxyHeaders() error = %v, want ErrProxyAuthDisabled", err)
+	}
+}
+
+func TestProxyAuthRejectsUntrustedSources(t *testing.T) {
+	db := newProxyAuthTestDB(t, true)
+	auth := NewAuthService(db)
+	header := proxyHeaders(map[string]string{"Remote-User": "alice", "Remote-Email": "alice@example.com"})
+
+	for _, remoteAddr := range []string{"203.0.113.7:5000", "192.168.1.6:5000", "garbage"} {
+		if _, err := auth.LoginWithProxyHeaders(remoteAddr, header); !errors.Is(err, ErrProxyAuthUntrustedSource) {
+			t.Fatalf("LoginWithProxyHeaders(%q) error = %v, want ErrProxyAuthUntrustedSource", remoteAddr, err)
+		}
+	}
+	if _, err := auth.LoginWithProxyHeaders("192.168.1.5:5000", header); err != nil {
+		t.Fatalf("LoginWithProxyHeaders() from single trusted host error = %v", err)
+	}
+}
+
+func TestProxyAuthProvisionsAndMapsRoles(t *testing.T) {
+	db := newProxyAuthTestDB(t, true)
+	auth := NewAuthService(db)
+	if err := db.Create(&model.User{Username: "owner", Email: "owner@example.com", Password: "x", Role: "admin", Status: "active"}).Error; err != nil {
+		t.Fatalf("failed to create owner: %v", err)
+	}
+
+	resp, err := auth.LoginWithProxyHeaders("10.1.2.3:4000", proxyHeaders(map[string]string{
+		"Remote-User":   "alice",
+		"Remote-Email":  "Alice@Example.com",
+		"Remote-Groups": "staff,Subdux-Admins",
+	}))
+	if err != nil {
+		t.Fatalf("LoginWithProxyHeaders() error = %v", err)
+	}
+	if resp.AccessToken == "" || resp.RefreshToken == "" {
+		t.Fatalf("expected a session, got %+v", resp)
+	}
+	if resp.User.Username != "alice" || resp.User.Email != "alice@example.com" || resp.User.Role != "admin" {
+		t.Fatalf("user = %+v, want provisioned admin alice", resp.User)
+	}
+
+	resp, err = auth.LoginWithProxyHeaders("10.1.2.3:4000", proxyHeaders(map[string]string{
+		"Remote-User":   "alice",
+		"Remote-Email":  "alice@example.com",
+		"Remote-Groups": "staff",
+	}))
+	if err != nil {
+		t.Fatalf("second LoginWithProxyHeaders() error = %v", err)
+	}
+	if resp.User.Role != "user" {
+		t.Fatalf("role after leaving admin group = %q, want user", resp.User.Role)
+	}
+
+	var count int64
+	db.Model(&model.User{}).Where("email = ?", "alice@example.com").Count(&count)
+	if count != 1 {
+		t.Fatalf("user count = %d, want 1", count)
+	}
+}
+
+func TestProxyAuthWithoutAutoCreate(t *testing.T) {
+	db := newProxyAuthTestDB(t, false)
+	auth := NewAuthService(db)
+
+	_, err := auth.LoginWithProxyHeaders("10.0.0.2:1", proxyHeaders(map[string]string{"Remote-User": "bob"}))
+	if !errors.Is(err, ErrProxyAuthUnknownUser) {
+		t.Fatalf("LoginWithProxyHeaders() error = %v, want ErrProxyAuthUnknownUser", err)
+	}
+	if _, err := auth.LoginWithProxyHeaders("10.0.0.2:1", http.Header{}); !errors.Is(err, ErrProxyAuthMissingIdentity) {
+		t.Fatalf("LoginWithProxyHeaders() without headers error = %v, want ErrProxyAuthMissingIdentity", err)
+	}
+
+	disabled := model.User{Username: "bob", Email: "bob@example.com", Password: "x", Role: "user", Status: "disabled"}
+	if err := db.Create(&disabled).Error; err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	if _, err := auth.LoginWithProxyHeaders("10.0.0.2:1", proxyHeaders(map[string]string{"Remote-User": "bob"})); err == nil {
+		t.Fatal("disabled user signed in through the proxy")
+	}
+}
+
+func TestApplyProxyAuthSettingsValidation(t *testing.T) {
+	db := newTestDB(t)
+	enabled := true
+
+	cases := []struct {
+		name  string
+		input UpdateSettingsInput
+	}{
+		{"enabled without cidrs", UpdateSettingsInput{ProxyAuthEnabled: &enabled}},
+		{"invalid cidr", UpdateSettingsInput{ProxyAuthTrustedCIDRs: stringPtr("10.0.0.0/33")}},
+		{"invalid header", UpdateSettingsInput{ProxyAuthUserHeader: stringPtr("Remote User")}},
+		{"no identity header", UpdateSettingsInput{ProxyAuthEnabled: &enabled, ProxyAuthTrustedCIDRs: stringPtr("127.0.0.1"), ProxyAuthUserHeader: stringPtr(""), ProxyAuthEmailHeader: stringPtr("")}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := applyProxyAuthSettings(db, tc.input); !errors.Is(err, ErrInvalidProxyAuthSettings) {
+				t.Fatalf("applyProxyAuthSettings() error = %v, want ErrInvalidProxyAuthSettings", err)
+			}
+		})
+	}
+}
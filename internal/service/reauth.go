@@ -10,7 +10,6 @@ import (
 	"github.com/go-webauthn/webauthn/protocol"
 	"github.com/shiroha/subdux/internal/model"
 	"github.com/shiroha/subdux/internal/pkg"
-	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
@@ -30,8 +29,9 @@ import (
 // Operation identifiers scope a ticket to a single sensitive action so a ticket
 // minted for one operation cannot authorize another.
 const (
-	ReauthOperationBackup  = "backup"
-	ReauthOperationRestore = "restore"
+	ReauthOperationBackup    = "backup"
+	ReauthOperationRestore   = "restore"
+	ReauthOperationSCIMToken = "scim_token"
 )
 
 const (
@@ -103,7 +103,7 @@ func (s *ReauthService) WithContext(ctx context.Context) *ReauthService {
 
 func isValidReauthOperation(operation string) bool {
 	switch operation {
-	case ReauthOperationBackup, ReauthOperationRestore:
+	case ReauthOperationBackup, ReauthOperationRestore, ReauthOperationSCIMToken:
 		return true
 	default:
 		return false
@@ -137,7 +137,7 @@ func (s *ReauthService) VerifyPassword(userID uint, operation string, password s
 	if err := s.db.First(&user, userID).Error; err != nil {
 		return "", ErrReauthRequired
 	}
-	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) != nil {
+	if _, err := verifyAccountPassword(s.db, &user, password); err != nil {
 		return "", ErrReauthRequired
 	}
 
@@ -167,12 +167,13 @@ func (s *ReauthService) FinishPasskey(userID uint, operation string, sessionID s
 
 // BeginOIDC starts an OIDC step-up for the operation, returning the provider
 // authorization URL the client opens (in a popup) to authenticate. The operation
-// is validated here and carried through the OIDC state session.
-func (s *ReauthService) BeginOIDC(userID uint, operation string) (*OIDCStartResult, error) {
+// is validated here and carried through the OIDC state session. An empty
+// provider uses the first linked one.
+func (s *ReauthService) BeginOIDC(userID uint, operation string, provider string) (*OIDCStartResult, error) {
 	if !isValidReauthOperation(operation) {
 		return nil, ErrInvalidReauthOperation
 	}
-	return s.auth.BeginOIDCReauth(userID, operation)
+	return s.auth.BeginOIDCReauth(userID, operation, provider)
 }
 
 // VerifyOIDC completes an OIDC step-up: it spends the single-use reauth result
@@ -335,7 +335,7 @@ func TestFinishOIDCReauthOwnership(t *testing.T) {
 	}
 
 	claims := &oidcIdentityClaims{Subject: "shared-subject", Email: other.Email}
-	if _, err := auth.finishOIDCReauth(user.ID, ReauthOperationBackup, claims); err == nil {
+	if _, err := auth.finishOIDCReauth(oidcProviderKey, user.ID, ReauthOperationBackup, claims); err == nil {
 		t.Fatal("finishOIDCReauth() error = nil for another user's identity, want non-nil")
 	}
 
@@ -346,7 +346,7 @@ func TestFinishOIDCReauthOwnership(t *testing.T) {
 		t.Fatalf("failed to create own connection: %v", err)
 	}
 	ownClaims := &oidcIdentityClaims{Subject: "own-subject", Email: user.Email}
-	result, err := auth.finishOIDCReauth(user.ID, ReauthOperationBackup, ownClaims)
+	result, err := auth.finishOIDCReauth(oidcProviderKey, user.ID, ReauthOperationBackup, ownClaims)
 	if err != nil {
 		t.Fatalf("finishOIDCReauth() error = %v, want nil", err)
 	}
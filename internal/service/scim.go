@@ -0,0 +1,609 @@
+package service
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shiroha/subdux/internal/model"
+	"github.com/shiroha/subdux/internal/pkg"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrSCIMUnauthorized  = errors.New("invalid scim token")
+	ErrSCIMNotFound      = errors.New("resource not found")
+	ErrSCIMUniqueness    = errors.New("userName, email or externalId is already in use")
+	ErrSCIMInvalidValue  = errors.New("invalid attribute value")
+	ErrSCIMInvalidFilter = errors.New("invalid filter")
+	ErrSCIMInvalidPath   = errors.New("invalid patch path")
+	ErrSCIMMutability    = errors.New("attribute cannot be changed")
+)
+
+const (
+	scimTokenHashKey      = "scim_token_hash"
+	scimTokenPrefixKey    = "scim_token_prefix"
+	scimTokenCreatedAtKey = "scim_token_created_at"
+
+	scimTokenPrefixLength = 12
+
+	// SCIMDefaultPageSize and SCIMMaxPageSize bound list responses; the
+	// maximum is advertised in the service provider config.
+	SCIMDefaultPageSize = 100
+	SCIMMaxPageSize     = 200
+
+	scimUserSchemaPrefix = "urn:ietf:params:scim:schemas:core:2.0:user:"
+)
+
+type SCIMService struct {
+	DB *gorm.DB
+}
+
+func NewSCIMService(db *gorm.DB) *SCIMService {
+	return &SCIMService{DB: db}
+}
+
+// SCIMTokenStatus describes the provisioning token without revealing it.
+type SCIMTokenStatus struct {
+	Enabled   bool       `json:"enabled"`
+	Prefix    string     `json:"prefix"`
+	CreatedAt *time.Time `json:"created_at"`
+}
+
+// SCIMUser is a user together with the identity provider's externalId.
+type SCIMUser struct {
+	model.User
+	ExternalID string `gorm:"column:external_id"`
+}
+
+// SCIMUserInput is the writable part of a SCIM user. A nil Active leaves the
+// status unchanged on update and means active on create.
+type SCIMUserInput struct {
+	UserName   string
+	Email      string
+	Active     *bool
+	ExternalID string
+	Password   string
+}
+
+// SCIMPatchOperation is one entry of a PATCH request's Operations array.
+type SCIMPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+
+// SCIMListOptions selects a page of a list query. StartIndex is 1-based and a
+// Count of zero only reports the total, as RFC 7644 specifies.
+type SCIMListOptions struct {
+	Filter     string
+	StartIndex int
+	Count      int
+}
+
+func normalizeSCIMListOptions(options SCIMListOptions) SCIMListOptions {
+	if options.StartIndex < 1 {
+		options.StartIndex = 1
+	}
+	if options.Count < 0 {
+		options.Count = 0
+	}
+	if options.Count > SCIMMaxPageSize {
+		options.Count = SCIMMaxPageSize
+	}
+	return options
+}
+
+// IssueToken replaces the provisioning token and returns the new raw value,
+// which is only ever shown once.
+func (s *SCIMService) IssueToken() (string, error) {
+	secret, err := generateSecureToken(32)
+	if err != nil {
+		return "", err
+	}
+	token := "scim_" + secret
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		for key, value := range map[string]string{
+			scimTokenHashKey:      hashSCIMToken(token),
+			scimTokenPrefixKey:    token[:scimTokenPrefixLength],
+			scimTokenCreatedAtKey: pkg.NowUTC().Format(time.RFC3339),
+		} {
+			if err := saveStringSystemSetting(tx, key, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// RevokeToken disables SCIM provisioning until a new token is issued.
+func (s *SCIMService) RevokeToken() error {
+	return s.DB.Where("key IN ?", []string{scimTokenHashKey, scimTokenPrefixKey, scimTokenCreatedAtKey}).
+		Delete(&model.SystemSetting{}).Error
+}
+
+func (s *SCIMService) TokenStatus() (*SCIMTokenStatus, error) {
+	hash, err := getSystemSettingValue(s.DB, scimTokenHashKey, "")
+	if err != nil {
+		return nil, err
+	}
+	if hash == "" {
+		return &SCIMTokenStatus{}, nil
+	}
+	prefix, err := getSystemSettingValue(s.DB, scimTokenPrefixKey, "")
+	if err != nil {
+		return nil, err
+	}
+	status := &SCIMTokenStatus{Enabled: true, Prefix: prefix}
+	if raw, err := getSystemSettingValue(s.DB, scimTokenCreatedAtKey, ""); err == nil {
+		if createdAt, err := time.Parse(time.RFC3339, raw); err == nil {
+			status.CreatedAt = &createdAt
+		}
+	}
+	return status, nil
+}
+
+// ValidateToken checks a bearer token against the stored hash in constant
+// time. With no token issued every request is rejected.
+func (s *SCIMService) ValidateToken(raw string) error {
+	stored, err := getSystemSettingValue(s.DB, scimTokenHashKey, "")
+	if err != nil {
+		return err
+	}
+	raw = strings.TrimSpace(raw)
+	if stored == "" || raw == "" {
+		return ErrSCIMUnauthorized
+	}
+	if subtle.ConstantTimeCompare([]byte(hashSCIMToken(raw)), []byte(stored)) != 1 {
+		return ErrSCIMUnauthorized
+	}
+	return nil
+}
+
+func hashSCIMToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
+
+// BaseURL returns the configured site URL used for absolute resource
+// locations, or an empty string when none is set.
+func (s *SCIMService) BaseURL() string {
+	value, err := getSystemSettingValue(s.DB, "site_url", "")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimRight(normalizeSiteURL(value), "/")
+}
+
+func scimUserQuery(db *gorm.DB) *gorm.DB {
+	return db.Model(&model.User{}).
+		Select("users.*, COALESCE(scim_identities.external_id, '') AS external_id").
+		Joins("LEFT JOIN scim_identities ON scim_identities.user_id = users.id")
+}
+
+// ListUsers returns one page of users matching a SCIM filter and the total
+// number of matches.
+func (s *SCIMService) ListUsers(options SCIMListOptions) ([]SCIMUser, int64, error) {
+	options = normalizeSCIMListOptions(options)
+	where, args, err := parseSCIMUserFilter(options.Filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	query := scimUserQuery(s.DB)
+	if where != "" {
+		query = query.Where(where, args...)
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	users := []SCIMUser{}
+	if options.Count == 0 {
+		return users, total, nil
+	}
+	if err := query.Order("users.id ASC").Offset(options.StartIndex - 1).Limit(options.Count).Find(&users).Error; err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+func (s *SCIMService) GetUser(userID uint) (*SCIMUser, error) {
+	return loadSCIMUser(s.DB, userID)
+}
+
+func loadSCIMUser(db *gorm.DB, userID uint) (*SCIMUser, error) {
+	var users []SCIMUser
+	if err := scimUserQuery(db).Where("users.id = ?", userID).Limit(1).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	if len(users) == 0 {
+		return nil, ErrSCIMNotFound
+	}
+	return &users[0], nil
+}
+
+// CreateUser provisions a user. Without a password the account gets a random
+// one, so the user signs in through the identity provider.
+func (s *SCIMService) CreateUser(input SCIMUserInput) (*SCIMUser, error) {
+	input, err := normalizeSCIMUserInput(input)
+	if err != nil {
+		return nil, err
+	}
+	password := input.Password
+	if password == "" {
+		if password, err = generateSecureToken(24); err != nil {
+			return nil, err
+		}
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	status := "active"
+	if input.Active != nil && !*input.Active {
+		status = "disabled"
+	}
+	user := model.User{
+		Username: input.UserName,
+		Email:    input.Email,
+		Password: string(hash),
+		Role:     "user",
+		Status:   status,
+	}
+
+	var created *SCIMUser
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := ensureSCIMUserUnique(tx, 0, input); err != nil {
+			return err
+		}
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		if err := SeedUserDefaults(tx, user.ID); err != nil {
+			return err
+		}
+		if err := saveSCIMExternalID(tx, user.ID, input.ExternalID); err != nil {
+			return err
+		}
+		created, err = loadSCIMUser(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// ReplaceUser applies a PUT: every writable attribute takes the given value,
+// so an omitted externalId is cleared.
+func (s *SCIMService) ReplaceUser(userID uint, input SCIMUserInput) (*SCIMUser, error) {
+	input, err := normalizeSCIMUserInput(input)
+	if err != nil {
+		return nil, err
+	}
+	return s.updateUser(userID, func(*SCIMUser) (SCIMUserInput, error) {
+		return input, nil
+	})
+}
+
+// PatchUser applies PATCH operations. Attributes Subdux does not store, such
+// as name or phoneNumbers, are accepted and ignored so identity providers
+// that always send them keep working.
+func (s *SCIMService) PatchUser(userID uint, operations []SCIMPatchOperation) (*SCIMUser, error) {
+	if len(operations) == 0 {
+		return nil, fmt.Errorf("%w: Operations is required", ErrSCIMInvalidValue)
+	}
+	return s.updateUser(userID, func(current *SCIMUser) (SCIMUserInput, error) {
+		active := current.Status == "active"
+		state := SCIMUserInput{
+			UserName:   current.Username,
+			Email:      current.Email,
+			Active:     &active,
+			ExternalID: current.ExternalID,
+		}
+		for _, operation := range operations {
+			if err := applySCIMUserOperation(&state, operation); err != nil {
+				return SCIMUserInput{}, err
+			}
+		}
+		return normalizeSCIMUserInput(state)
+	})
+}
+
+func (s *SCIMService) updateUser(userID uint, build func(*SCIMUser) (SCIMUserInput, error)) (*SCIMUser, error) {
+	var updated *SCIMUser
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		current, err := loadSCIMUser(tx, userID)
+		if err != nil {
+			return err
+		}
+		input, err := build(current)
+		if err != nil {
+			return err
+		}
+		if err := ensureSCIMUserUnique(tx, userID, input); err != nil {
+			return err
+		}
+
+		status := current.Status
+		if input.Active != nil {
+			status = "disabled"
+			if *input.Active {
+				status = "active"
+			}
+		}
+		if userID == 1 && status == "disabled" {
+			return fmt.Errorf("%w: the first user cannot be deactivated", ErrSCIMMutability)
+		}
+
+		updates := map[string]interface{}{
+			"username": input.UserName,
+			"email":    input.Email,
+			"status":   status,
+		}
+		if input.Password != "" {
+			hash, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
+			if err != nil {
+				return err
+			}
+			updates["password"] = string(hash)
+		}
+		if err := tx.Model(&model.User{}).Where("id = ?", userID).Updates(updates).Error; err != nil {
+			return err
+		}
+		if status == "disabled" && current.Status != "disabled" {
+			if err := revokeUserCredentials(tx, userID); err != nil {
+				return err
+			}
+		}
+		if err := saveSCIMExternalID(tx, userID, input.ExternalID); err != nil {
+			return err
+		}
+		updated, err = loadSCIMUser(tx, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// DeleteUser removes a user and everything they own, as the admin API does.
+func (s *SCIMService) DeleteUser(userID uint) error {
+	if _, err := s.GetUser(userID); err != nil {
+		return err
+	}
+	if userID == 1 {
+		return fmt.Errorf("%w: the first user cannot be deleted", ErrSCIMMutability)
+	}
+	return NewAdminService(s.DB).DeleteUser(userID)
+}
+
+// revokeUserCredentials ends every way a deprovisioned user could keep
+// acting: refresh tokens are revoked and API keys are deleted, so
+// reactivating the account does not bring old credentials back.
+func revokeUserCredentials(tx *gorm.DB, userID uint) error {
+	if err := revokeAllRefreshTokens(tx, userID); err != nil {
+		return err
+	}
+	return tx.Where("user_id = ?", userID).Delete(&model.APIKey{}).Error
+}
+
+func normalizeSCIMUserInput(input SCIMUserInput) (SCIMUserInput, error) {
+	input.UserName = strings.TrimSpace(input.UserName)
+	if input.UserName == "" || len(input.UserName) > 255 {
+		return input, fmt.Errorf("%w: userName is required and must be at most 255 characters", ErrSCIMInvalidValue)
+	}
+	email, err := sanitizeAndValidateEmail(input.Email)
+	if err != nil || len(email) > 255 {
+		return input, fmt.Errorf("%w: a valid email is required", ErrSCIMInvalidValue)
+	}
+	input.Email = email
+	input.ExternalID = strings.TrimSpace(input.ExternalID)
+	if len(input.ExternalID) > 255 {
+		return input, fmt.Errorf("%w: externalId must be at most 255 characters", ErrSCIMInvalidValue)
+	}
+	if input.Password != "" {
+		if len(input.Password) < 8 {
+			return input, fmt.Errorf("%w: password must be at least 8 characters", ErrSCIMInvalidValue)
+		}
+		if err := validateBcryptPasswordLength(input.Password); err != nil {
+			return input, fmt.Errorf("%w: %v", ErrSCIMInvalidValue, err)
+		}
+	}
+	return input, nil
+}
+
+func ensureSCIMUserUnique(tx *gorm.DB, userID uint, input SCIMUserInput) error {
+	var count int64
+	if err := tx.Model(&model.User{}).
+		Where("id <> ? AND (username = ? OR LOWER(email) = ?)", userID, input.UserName, input.Email).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 && input.ExternalID != "" {
+		if err := tx.Model(&model.SCIMIdentity{}).
+			Where("user_id <> ? AND external_id = ?", userID, input.ExternalID).
+			Count(&count).Error; err != nil {
+			return err
+		}
+	}
+	if count > 0 {
+		return ErrSCIMUniqueness
+	}
+	return nil
+}
+
+func saveSCIMExternalID(tx *gorm.DB, userID uint, externalID string) error {
+	if externalID == "" {
+		return tx.Where("user_id = ?", userID).Delete(&model.SCIMIdentity{}).Error
+	}
+	var identity model.SCIMIdentity
+	if err := tx.Where("user_id = ?", userID).Limit(1).Find(&identity).Error; err != nil {
+		return err
+	}
+	identity.UserID = userID
+	identity.ExternalID = externalID
+	return tx.Save(&identity).Error
+}
+
+// applySCIMUserOperation folds one PATCH operation into state. Paths are
+// matched case-insensitively, with or without the core schema URN.
+func applySCIMUserOperation(state *SCIMUserInput, operation SCIMPatchOperation) error {
+	op := strings.ToLower(strings.TrimSpace(operation.Op))
+	if op != "add" && op != "replace" && op != "remove" {
+		return fmt.Errorf("%w: unsupported op %q", ErrSCIMInvalidValue, operation.Op)
+	}
+	path := normalizeSCIMUserPath(operation.Path)
+
+	if path == "" {
+		if op == "remove" {
+			return fmt.Errorf("%w: remove requires a path", ErrSCIMInvalidPath)
+		}
+		var values map[string]json.RawMessage
+		if err := json.Unmarshal(operation.Value, &values); err != nil {
+			return fmt.Errorf("%w: value must be an object when path is omitted", ErrSCIMInvalidValue)
+		}
+		for key, value := range values {
+			if err := applySCIMUserOperation(state, SCIMPatchOperation{Op: op, Path: key, Value: value}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	switch path {
+	case "username":
+		if op == "remove" {
+			return fmt.Errorf("%w: userName is required", ErrSCIMMutability)
+		}
+		return decodeSCIMString(operation.Value, &state.UserName)
+	case "emails", "emails.value":
+		if op == "remove" {
+			return fmt.Errorf("%w: email is required", ErrSCIMMutability)
+		}
+		email, err := decodeSCIMEmail(operation.Value)
+		if err != nil {
+			return err
+		}
+		state.Email = email
+		return nil
+	case "active":
+		if op == "remove" {
+			return nil
+		}
+		active, err := decodeSCIMBool(operation.Value)
+		if err != nil {
+			return err
+		}
+		state.Active = &active
+		return nil
+	case "externalid":
+		if op == "remove" {
+			state.ExternalID = ""
+			return nil
+		}
+		return decodeSCIMString(operation.Value, &state.ExternalID)
+	case "password":
+		if op == "remove" {
+			return fmt.Errorf("%w: password cannot be removed", ErrSCIMMutability)
+		}
+		return decodeSCIMString(operation.Value, &state.Password)
+	case "id", "meta", "groups", "schemas":
+		return fmt.Errorf("%w: %s is read-only", ErrSCIMMutability, operation.Path)
+	default:
+		return nil
+	}
+}
+
+// normalizeSCIMUserPath lowercases a path, strips the core schema URN and
+// reduces value filters such as emails[type eq "work"].value to
+// emails.value, since a Subdux user has exactly one email.
+func normalizeSCIMUserPath(raw string) string {
+	path := strings.ToLower(strings.TrimSpace(raw))
+	path = strings.TrimPrefix(path, scimUserSchemaPrefix)
+	if open := strings.Index(path, "["); open >= 0 {
+		if close := strings.Index(path[open:], "]"); close >= 0 {
+			path = path[:open] + path[open+close+1:]
+		}
+	}
+	return path
+}
+
+func decodeSCIMString(raw json.RawMessage, target *string) error {
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return fmt.Errorf("%w: expected a string", ErrSCIMInvalidValue)
+	}
+	*target = value
+	return nil
+}
+
+// decodeSCIMBool accepts JSON booleans and the "True"/"False" strings some
+// identity providers send.
+func decodeSCIMBool(raw json.RawMessage) (bool, error) {
+	var value bool
+	if err := json.Unmarshal(raw, &value); err == nil {
+		return value, nil
+	}
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		switch strings.ToLower(strings.TrimSpace(text)) {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+	}
+	return false, fmt.Errorf("%w: expected a boolean", ErrSCIMInvalidValue)
+}
+
+// SCIMEmail is one entry of a user's emails attribute.
+type SCIMEmail struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+// PrimarySCIMEmail picks the primary address, else the first one.
+func PrimarySCIMEmail(emails []SCIMEmail) string {
+	for _, email := range emails {
+		if email.Primary {
+			return email.Value
+		}
+	}
+	if len(emails) > 0 {
+		return emails[0].Value
+	}
+	return ""
+}
+
+func decodeSCIMEmail(raw json.RawMessage) (string, error) {
+	var value string
+	if err := json.Unmarshal(raw, &value); err == nil {
+		return value, nil
+	}
+	var emails []SCIMEmail
+	if err := json.Unmarshal(raw, &emails); err == nil && len(emails) > 0 {
+		return PrimarySCIMEmail(emails), nil
+	}
+	var email SCIMEmail
+	if err := json.Unmarshal(raw, &email); err == nil && email.Value != "" {
+		return email.Value, nil
+	}
+	return "", fmt.Errorf("%w: expected an email address", ErrSCIMInvalidValue)
+}
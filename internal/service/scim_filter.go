@@ -0,0 +1,311 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type scimAttributeKind int
+
+const (
+	scimAttributeText scimAttributeKind = iota
+	scimAttributeExactText
+	scimAttributeBool
+	scimAttributeTime
+	scimAttributeID
+)
+
+type scimAttribute struct {
+	column string
+	kind   scimAttributeKind
+}
+
+// scimUserAttributes maps the filterable user attributes, normalized by
+// normalizeSCIMUserPath, to SQL columns of scimUserQuery.
+var scimUserAttributes = map[string]scimAttribute{
+	"id":                {column: "users.id", kind: scimAttributeID},
+	"username":          {column: "users.username", kind: scimAttributeText},
+	"emails":            {column: "users.email", kind: scimAttributeText},
+	"emails.value":      {column: "users.email", kind: scimAttributeText},
+	"externalid":        {column: "COALESCE(scim_identities.external_id, '')", kind: scimAttributeExactText},
+	"active":            {column: "users.status", kind: scimAttributeBool},
+	"meta.created":      {column: "users.created_at", kind: scimAttributeTime},
+	"meta.lastmodified": {column: "users.updated_at", kind: scimAttributeTime},
+}
+
+type scimFilterToken struct {
+	text   string
+	quoted bool
+}
+
+type scimFilterParser struct {
+	tokens []scimFilterToken
+	pos    int
+}
+
+// parseSCIMUserFilter translates an RFC 7644 filter into a SQL condition. It
+// supports the comparison operators, pr, and/or/not and grouping on the
+// attributes in scimUserAttributes.
+func parseSCIMUserFilter(raw string) (string, []interface{}, error) {
+	if strings.TrimSpace(raw) == "" {
+		return "", nil, nil
+	}
+	tokens, err := tokenizeSCIMFilter(raw)
+	if err != nil {
+		return "", nil, err
+	}
+	parser := &scimFilterParser{tokens: tokens}
+	where, args, err := parser.parseOr()
+	if err != nil {
+		return "", nil, err
+	}
+	if parser.pos != len(parser.tokens) {
+		return "", nil, fmt.Errorf("%w: unexpected %q", ErrSCIMInvalidFilter, parser.tokens[parser.pos].text)
+	}
+	return where, args, nil
+}
+
+// tokenizeSCIMFilter splits a filter into words, quoted strings and
+// parentheses. An attribute path keeps its bracketed value filter, as in
+// emails[type eq "work"].value, as part of one token.
+func tokenizeSCIMFilter(raw string) ([]scimFilterToken, error) {
+	var tokens []scimFilterToken
+	for i := 0; i < len(raw); {
+		switch ch := raw[i]; {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+			i++
+		case ch == '(' || ch == ')':
+			tokens = append(tokens, scimFilterToken{text: string(ch)})
+			i++
+		case ch == '"':
+			var builder strings.Builder
+			j := i + 1
+			for ; j < len(raw) && raw[j] != '"'; j++ {
+				if raw[j] == '\\' && j+1 < len(raw) {
+					j++
+				}
+				builder.WriteByte(raw[j])
+			}
+			if j >= len(raw) {
+				return nil, fmt.Errorf("%w: unterminated string", ErrSCIMInvalidFilter)
+			}
+			tokens = append(tokens, scimFilterToken{text: builder.String(), quoted: true})
+			i = j + 1
+		default:
+			j := i
+			depth := 0
+			for ; j < len(raw); j++ {
+				c := raw[j]
+				if c == '[' {
+					depth++
+				} else if c == ']' {
+					depth--
+				} else if depth == 0 && (c == ' ' || c == '(' || c == ')') {
+					break
+				} else if c == '"' && depth == 0 {
+					break
+				}
+			}
+			if depth != 0 {
+				return nil, fmt.Errorf("%w: unbalanced brackets", ErrSCIMInvalidFilter)
+			}
+			tokens = append(tokens, scimFilterToken{text: raw[i:j]})
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+func (p *scimFilterParser) peekKeyword(keyword string) bool {
+	if p.pos >= len(p.tokens) || p.tokens[p.pos].quoted {
+		return false
+	}
+	return strings.EqualFold(p.tokens[p.pos].text, keyword)
+}
+
+func (p *scimFilterParser) next() (scimFilterToken, error) {
+	if p.pos >= len(p.tokens) {
+		return scimFilterToken{}, fmt.Errorf("%w: unexpected end of filter", ErrSCIMInvalidFilter)
+	}
+	token := p.tokens[p.pos]
+	p.pos++
+	return token, nil
+}
+
+func (p *scimFilterParser) expect(text string) error {
+	token, err := p.next()
+	if err != nil {
+		return err
+	}
+	if token.quoted || token.text != text {
+		return fmt.Errorf("%w: expected %q", ErrSCIMInvalidFilter, text)
+	}
+	return nil
+}
+
+func (p *scimFilterParser) parseOr() (string, []interface{}, error) {
+	return p.parseJoined("or", p.parseAnd)
+}
+
+func (p *scimFilterParser) parseAnd() (string, []interface{}, error) {
+	return p.parseJoined("and", p.parseUnary)
+}
+
+func (p *scimFilterParser) parseJoined(keyword string, operand func() (string, []interface{}, error)) (string, []interface{}, error) {
+	where, args, err := operand()
+	if err != nil {
+		return "", nil, err
+	}
+	for p.peekKeyword(keyword) {
+		p.pos++
+		right, rightArgs, err := operand()
+		if err != nil {
+			return "", nil, err
+		}
+		where = "(" + where + " " + strings.ToUpper(keyword) + " " + right + ")"
+		args = append(args, rightArgs...)
+	}
+	return where, args, nil
+}
+
+func (p *scimFilterParser) parseUnary() (string, []interface{}, error) {
+	if p.peekKeyword("not") {
+		p.pos++
+		if err := p.expect("("); err != nil {
+			return "", nil, err
+		}
+		where, args, err := p.parseOr()
+		if err != nil {
+			return "", nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return "", nil, err
+		}
+		return "NOT (" + where + ")", args, nil
+	}
+	if p.pos < len(p.tokens) && !p.tokens[p.pos].quoted && p.tokens[p.pos].text == "(" {
+		p.pos++
+		where, args, err := p.parseOr()
+		if err != nil {
+			return "", nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return "", nil, err
+		}
+		return "(" + where + ")", args, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *scimFilterParser) parseComparison() (string, []interface{}, error) {
+	attrToken, err := p.next()
+	if err != nil {
+		return "", nil, err
+	}
+	attribute, ok := scimUserAttributes[normalizeSCIMUserPath(attrToken.text)]
+	if attrToken.quoted || !ok {
+		return "", nil, fmt.Errorf("%w: unsupported attribute %q", ErrSCIMInvalidFilter, attrToken.text)
+	}
+	opToken, err := p.next()
+	if err != nil {
+		return "", nil, err
+	}
+	op := strings.ToLower(opToken.text)
+	if op == "pr" {
+		return scimPresentCondition(attribute), nil, nil
+	}
+	value, err := p.next()
+	if err != nil {
+		return "", nil, err
+	}
+	return scimComparisonCondition(attrToken.text, attribute, op, value)
+}
+
+func scimPresentCondition(attribute scimAttribute) string {
+	switch attribute.kind {
+	case scimAttributeText, scimAttributeExactText:
+		return attribute.column + " <> ''"
+	default:
+		return "1 = 1"
+	}
+}
+
+var scimOrderingOperators = map[string]string{
+	"eq": "=",
+	"ne": "<>",
+	"gt": ">",
+	"ge": ">=",
+	"lt": "<",
+	"le": "<=",
+}
+
+func scimComparisonCondition(name string, attribute scimAttribute, op string, value scimFilterToken) (string, []interface{}, error) {
+	invalid := fmt.Errorf("%w: unsupported comparison %s on %s", ErrSCIMInvalidFilter, op, name)
+
+	switch attribute.kind {
+	case scimAttributeText, scimAttributeExactText:
+		if !value.quoted {
+			return "", nil, fmt.Errorf("%w: expected a quoted string", ErrSCIMInvalidFilter)
+		}
+		column, text := attribute.column, value.text
+		if attribute.kind == scimAttributeText {
+			column, text = "LOWER("+column+")", strings.ToLower(text)
+		}
+		if sqlOp, ok := scimOrderingOperators[op]; ok {
+			return column + " " + sqlOp + " ?", []interface{}{text}, nil
+		}
+		escaped := escapeSCIMLike(text)
+		switch op {
+		case "co":
+			return column + ` LIKE ? ESCAPE '\'`, []interface{}{"%" + escaped + "%"}, nil
+		case "sw":
+			return column + ` LIKE ? ESCAPE '\'`, []interface{}{escaped + "%"}, nil
+		case "ew":
+			return column + ` LIKE ? ESCAPE '\'`, []interface{}{"%" + escaped}, nil
+		}
+		return "", nil, invalid
+	case scimAttributeBool:
+		active, err := strconv.ParseBool(value.text)
+		if value.quoted || err != nil {
+			return "", nil, fmt.Errorf("%w: expected true or false", ErrSCIMInvalidFilter)
+		}
+		if op == "ne" {
+			active = !active
+		} else if op != "eq" {
+			return "", nil, invalid
+		}
+		if active {
+			return attribute.column + " = 'active'", nil, nil
+		}
+		return attribute.column + " <> 'active'", nil, nil
+	case scimAttributeTime:
+		parsed, err := time.Parse(time.RFC3339, value.text)
+		if !value.quoted || err != nil {
+			return "", nil, fmt.Errorf("%w: expected an RFC 3339 timestamp", ErrSCIMInvalidFilter)
+		}
+		if sqlOp, ok := scimOrderingOperators[op]; ok {
+			return attribute.column + " " + sqlOp + " ?", []interface{}{parsed.UTC()}, nil
+		}
+		return "", nil, invalid
+	case scimAttributeID:
+		id, err := strconv.ParseUint(value.text, 10, 64)
+		if err != nil {
+			// An id that is not a number cannot match any user.
+			if op == "eq" {
+				return "1 = 0", nil, nil
+			}
+			return "", nil, fmt.Errorf("%w: id must be numeric", ErrSCIMInvalidFilter)
+		}
+		if sqlOp, ok := scimOrderingOperators[op]; ok {
+			return attribute.column + " " + sqlOp + " ?", []interface{}{id}, nil
+		}
+		return "", nil, invalid
+	}
+	return "", nil, invalid
+}
+
+func escapeSCIMLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
@@ -0,0 +1,266 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/shiroha/subdux/internal/model"
+	"gorm.io/gorm"
+)
+
+// Subdux has two roles, so SCIM exposes a single fixed group whose members
+// are the administrators. Adding a user to it grants the admin role and
+// removing them revokes it; the group itself cannot be created, renamed or
+// deleted.
+const (
+	SCIMAdminGroupID          = "admins"
+	SCIMAdminGroupDisplayName = "Subdux Administrators"
+)
+
+// SCIMGroup is the admin group with its current members.
+type SCIMGroup struct {
+	ID          string
+	DisplayName string
+	Members     []model.User
+}
+
+var scimGroupFilterPattern = regexp.MustCompile(`(?i)^\s*(id|displayName)\s+eq\s+"((?:[^"\\]|\\.)*)"\s*$`)
+
+// ListGroups returns the admin group when it matches the filter. Only
+// equality on id or displayName is supported, which is what identity
+// providers use to look a group up before linking it.
+func (s *SCIMService) ListGroups(filter string) ([]SCIMGroup, error) {
+	if strings.TrimSpace(filter) != "" {
+		match := scimGroupFilterPattern.FindStringSubmatch(filter)
+		if match == nil {
+			return nil, fmt.Errorf("%w: groups support only id or displayName eq", ErrSCIMInvalidFilter)
+		}
+		value := strings.ReplaceAll(match[2], `\"`, `"`)
+		if strings.EqualFold(match[1], "id") && value != SCIMAdminGroupID {
+			return []SCIMGroup{}, nil
+		}
+		if strings.EqualFold(match[1], "displayName") && !strings.EqualFold(value, SCIMAdminGroupDisplayName) {
+			return []SCIMGroup{}, nil
+		}
+	}
+	group, err := s.GetGroup(SCIMAdminGroupID)
+	if err != nil {
+		return nil, err
+	}
+	return []SCIMGroup{*group}, nil
+}
+
+func (s *SCIMService) GetGroup(groupID string) (*SCIMGroup, error) {
+	if groupID != SCIMAdminGroupID {
+		return nil, ErrSCIMNotFound
+	}
+	var members []model.User
+	if err := s.DB.Where("role = ?", "admin").Order("id ASC").Find(&members).Error; err != nil {
+		return nil, err
+	}
+	return &SCIMGroup{ID: SCIMAdminGroupID, DisplayName: SCIMAdminGroupDisplayName, Members: members}, nil
+}
+
+// ReplaceGroupMembers makes exactly the given users administrators.
+func (s *SCIMService) ReplaceGroupMembers(groupID string, memberIDs []uint) (*SCIMGroup, error) {
+	if groupID != SCIMAdminGroupID {
+		return nil, ErrSCIMNotFound
+	}
+	if err := s.DB.Transaction(func(tx *gorm.DB) error {
+		return replaceSCIMGroupMembers(tx, memberIDs)
+	}); err != nil {
+		return nil, err
+	}
+	return s.GetGroup(groupID)
+}
+
+// PatchGroup applies member add, remove and replace operations. A remove may
+// name its member in the value list or in a members[value eq "id"] path.
+func (s *SCIMService) PatchGroup(groupID string, operations []SCIMPatchOperation) (*SCIMGroup, error) {
+	if groupID != SCIMAdminGroupID {
+		return nil, ErrSCIMNotFound
+	}
+	if len(operations) == 0 {
+		return nil, fmt.Errorf("%w: Operations is required", ErrSCIMInvalidValue)
+	}
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		for _, operation := range operations {
+			op := strings.ToLower(strings.TrimSpace(operation.Op))
+			path := strings.ToLower(strings.TrimSpace(operation.Path))
+
+			if path == "displayname" || (path == "" && op != "remove" && !scimValueHasMembers(operation.Value)) {
+				// The group name is fixed; identity providers that push
+				// their own name are tolerated rather than rejected.
+				continue
+			}
+			if path == "" {
+				var value struct {
+					Members json.RawMessage `json:"members"`
+				}
+				if err := json.Unmarshal(operation.Value, &value); err != nil {
+					return fmt.Errorf("%w: invalid members value", ErrSCIMInvalidValue)
+				}
+				operation.Value = value.Members
+				path = "members"
+			}
+
+			if strings.HasPrefix(path, "members[") && op == "remove" {
+				id, err := parseSCIMMemberPathID(operation.Path)
+				if err != nil {
+					return err
+				}
+				if err := setSCIMGroupRole(tx, []uint{id}, "user"); err != nil {
+					return err
+				}
+				continue
+			}
+			if path != "members" {
+				return fmt.Errorf("%w: %s", ErrSCIMInvalidPath, operation.Path)
+			}
+
+			var ids []uint
+			if len(operation.Value) > 0 {
+				var err error
+				if ids, err = decodeSCIMMemberIDs(operation.Value); err != nil {
+					return err
+				}
+			}
+			switch op {
+			case "add":
+				if err := setSCIMGroupRole(tx, ids, "admin"); err != nil {
+					return err
+				}
+			case "remove":
+				if len(operation.Value) == 0 {
+					if err := tx.Model(&model.User{}).Where("role = ?", "admin").Pluck("id", &ids).Error; err != nil {
+						return err
+					}
+				}
+				if err := setSCIMGroupRole(tx, ids, "user"); err != nil {
+					return err
+				}
+			case "replace":
+				if err := replaceSCIMGroupMembers(tx, ids); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("%w: unsupported op %q", ErrSCIMInvalidValue, operation.Op)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetGroup(groupID)
+}
+
+func replaceSCIMGroupMembers(tx *gorm.DB, memberIDs []uint) error {
+	var current []uint
+	if err := tx.Model(&model.User{}).Where("role = ?", "admin").Pluck("id", &current).Error; err != nil {
+		return err
+	}
+	keep := uniqueSCIMIDs(memberIDs)
+	var removed []uint
+	for _, id := range current {
+		if _, ok := keep[id]; !ok {
+			removed = append(removed, id)
+		}
+	}
+	if err := setSCIMGroupRole(tx, memberIDs, "admin"); err != nil {
+		return err
+	}
+	return setSCIMGroupRole(tx, removed, "user")
+}
+
+// setSCIMGroupRole gives the users the role. The first user always stays an
+// administrator, matching the admin API.
+func setSCIMGroupRole(tx *gorm.DB, userIDs []uint, role string) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	if role == "user" {
+		for _, id := range userIDs {
+			if id == 1 {
+				return fmt.Errorf("%w: the first user must remain an administrator", ErrSCIMMutability)
+			}
+		}
+	}
+	var count int64
+	if err := tx.Model(&model.User{}).Where("id IN ?", userIDs).Count(&count).Error; err != nil {
+		return err
+	}
+	if count != int64(len(uniqueSCIMIDs(userIDs))) {
+		return fmt.Errorf("%w: unknown group member", ErrSCIMInvalidValue)
+	}
+	return tx.Model(&model.User{}).Where("id IN ?", userIDs).Update("role", role).Error
+}
+
+func uniqueSCIMIDs(ids []uint) map[uint]struct{} {
+	unique := make(map[uint]struct{}, len(ids))
+	for _, id := range ids {
+		unique[id] = struct{}{}
+	}
+	return unique
+}
+
+func scimValueHasMembers(raw json.RawMessage) bool {
+	var value map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return false
+	}
+	for key := range value {
+		if strings.EqualFold(key, "members") {
+			return true
+		}
+	}
+	return false
+}
+
+// SCIMMember references a user in a group's members attribute.
+type SCIMMember struct {
+	Value string `json:"value"`
+}
+
+// ParseSCIMMemberIDs converts member references to user IDs.
+func ParseSCIMMemberIDs(members []SCIMMember) ([]uint, error) {
+	ids := make([]uint, 0, len(members))
+	for _, member := range members {
+		id, err := strconv.ParseUint(strings.TrimSpace(member.Value), 10, 64)
+		if err != nil || id == 0 {
+			return nil, fmt.Errorf("%w: unknown group member %q", ErrSCIMInvalidValue, member.Value)
+		}
+		ids = append(ids, uint(id))
+	}
+	return ids, nil
+}
+
+func decodeSCIMMemberIDs(raw json.RawMessage) ([]uint, error) {
+	var members []SCIMMember
+	if err := json.Unmarshal(raw, &members); err != nil {
+		var member SCIMMember
+		if err := json.Unmarshal(raw, &member); err != nil {
+			return nil, fmt.Errorf("%w: invalid members value", ErrSCIMInvalidValue)
+		}
+		members = []SCIMMember{member}
+	}
+	return ParseSCIMMemberIDs(members)
+}
+
+var scimMemberPathPattern = regexp.MustCompile(`(?i)^members\[\s*value\s+eq\s+"([^"]*)"\s*\]$`)
+
+func parseSCIMMemberPathID(path string) (uint, error) {
+	match := scimMemberPathPattern.FindStringSubmatch(strings.TrimSpace(path))
+	if match == nil {
+		return 0, fmt.Errorf("%w: %s", ErrSCIMInvalidPath, path)
+	}
+	ids, err := ParseSCIMMemberIDs([]SCIMMember{{Value: match[1]}})
+	if err != nil {
+		return 0, err
+	}
+	return ids[0], nil
+}
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/shiroha/subdux/internal/model"
+	"gorm.io/gorm"
+)
+
+func newSCIMTestService(t *testing.T) (*gorm.DB, *SCIMService) {
+	t.Helper()
+
+	db := newTestDB(t)
+	if err := db.AutoMigrate(&model.SCIMIdentity{}, &model.APIKey{}, &model.CalendarToken{}); err != nil {
+		t.Fatalf("failed to migrate scim tables: %v", err)
+	}
+	owner := model.User{Username: "owner", Email: "owner@example.com", Password: "hash", Role: "admin", Status: "active"}
+	if err := db.Create(&owner).Error; err != nil {
+		t.Fatalf("failed to create owner: %v", err)
+	}
+	return db, NewSCIMService(db)
+}
+
+func TestSCIMTokenValidation(t *testing.T) {
+	_, scim := newSCIMTestService(t)
+
+	if err := scim.ValidateToken("anything"); !errors.Is(err, ErrSCIMUnauthorized) {
+		t.Fatalf("ValidateToken() without issued token error = %v, want ErrSCIMUnauthorized", err)
+	}
+	token, err := scim.IssueToken()
+	if err != nil {
+		t.Fatalf("IssueToken() error = %v", err)
+	}
+	if err := scim.ValidateToken(token); err != nil {
+		t.Fatalf("ValidateToken(issued) error = %v", err)
+	}
+	status, err := scim.TokenStatus()
+	if err != nil || !status.Enabled || status.Prefix != token[:scimTokenPrefixLength] {
+		t.Fatalf("TokenStatus() = %+v, %v", status, err)
+	}
+
+	if err := scim.RevokeToken(); err != nil {
+		t.Fatalf("RevokeToken() error = %v", err)
+	}
+	if err := scim.ValidateToken(token); !errors.Is(err, ErrSCIMUnauthorized) {
+		t.Fatalf("ValidateToken() after revoke error = %v, want ErrSCIMUnauthorized", err)
+	}
+}
+
+func TestSCIMUserFilterQueries(t *testing.T) {
+	_, scim := newSCIMTestService(t)
+	for _, input := range []SCIMUserInput{
+		{UserName: "Alice", Email: "alice@example.com", ExternalID: "ext-alice"},
+		{UserName: "bob", Email: "bob@corp.example"},
+	} {
+		if _, err := scim.CreateUser(input); err != nil {
+			t.Fatalf("CreateUser(%s) error = %v", input.UserName, err)
+		}
+	}
+
+	tests := []struct {
+		filter string
+		want   []string
+	}{
+		{`userName eq "alice"`, []string{"Alice"}},
+		{`externalId eq "ext-alice"`, []string{"Alice"}},
+		{`emails[type eq "work"].value ew "corp.example"`, []string{"bob"}},
+		{`userName sw "a" or userName eq "bob"`, []string{"Alice", "bob"}},
+		{`active eq true and not (userName eq "owner")`, []string{"Alice", "bob"}},
+		{`externalId pr`, []string{"Alice"}},
+		{`userName co "%"`, nil},
+	}
+	for _, tt := range tests {
+		users, total, err := scim.ListUsers(SCIMListOptions{Filter: tt.filter, Count: SCIMDefaultPageSize})
+		if err != nil {
+			t.Fatalf("ListUsers(%s) error = %v", tt.filter, err)
+		}
+		var got []string
+		for _, user := range users {
+			got = append(got, user.Username)
+		}
+		if len(got) != len(tt.want) || int(total) != len(tt.want) {
+			t.Fatalf("ListUsers(%s) = %v (total %d), want %v", tt.filter, got, total, tt.want)
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Fatalf("ListUsers(%s) = %v, want %v", tt.filter, got, tt.want)
+			}
+		}
+	}
+
+	for _, filter := range []string{`password eq "x"`, `userName eq`, `(userName eq "a"`, `active gt true`} {
+		if _, _, err := scim.ListUsers(SCIMListOptions{Filter: filter, Count: 1}); !errors.Is(err, ErrSCIMInvalidFilter) {
+			t.Fatalf("ListUsers(%s) error = %v, want ErrSCIMInvalidFilter", filter, err)
+		}
+	}
+
+	page, total, err := scim.ListUsers(SCIMListOptions{StartIndex: 2, Count: 1})
+	if err != nil || total != 3 || len(page) != 1 || page[0].Username != "Alice" {
+		t.Fatalf("ListUsers(page 2) = %v, %d, %v", page, total, err)
+	}
+}
+
+func TestSCIMDeactivationRevokesCredentials(t *testing.T) {
+	db, scim := newSCIMTestService(t)
+	user, err := scim.CreateUser(SCIMUserInput{UserName: "carol", Email: "carol@example.com"})
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	if err := db.Create(&model.RefreshToken{UserID: user.ID, TokenHash: "refresh-hash"}).Error; err != nil {
+		t.Fatalf("failed to create refresh token: %v", err)
+	}
+	if err := db.Create(&model.APIKey{UserID: user.ID, Name: "cli", KeyHash: "key-hash", Prefix: "sdx_1234"}).Error; err != nil {
+		t.Fatalf("failed to create api key: %v", err)
+	}
+
+	patched, err := scim.PatchUser(user.ID, []SCIMPatchOperation{
+		{Op: "Replace", Value: json.RawMessage(`{"active":"False","externalId":"ext-carol"}`)},
+	})
+	if err != nil {
+		t.Fatalf("PatchUser() error = %v", err)
+	}
+	if patched.Status != "disabled" || patched.ExternalID != "ext-carol" {
+		t.Fatalf("patched user = %+v, want disabled with externalId", patched)
+	}
+
+	var token model.RefreshToken
+	if err := db.Where("user_id = ?", user.ID).First(&token).Error; err != nil {
+		t.Fatalf("failed to load refresh token: %v", err)
+	}
+	if token.RevokedAt == nil {
+		t.Fatal("refresh token was not revoked")
+	}
+	var keys int64
+	if err := db.Model(&model.APIKey{}).Where("user_id = ?", user.ID).Count(&keys).Error; err != nil {
+		t.Fatalf("failed to count api keys: %v", err)
+	}
+	if keys != 0 {
+		t.Fatalf("api keys after deactivation = %d, want 0", keys)
+	}
+
+	if _, err := scim.PatchUser(1, []SCIMPatchOperation{{Op: "replace", Path: "active", Value: json.RawMessage(`false`)}}); !errors.Is(err, ErrSCIMMutability) {
+		t.Fatalf("deactivating first user error = %v, want ErrSCIMMutability", err)
+	}
+	if _, err := scim.CreateUser(SCIMUserInput{UserName: "other", Email: "CAROL@example.com"}); !errors.Is(err, ErrSCIMUniqueness) {
+		t.Fatalf("duplicate email error = %v, want ErrSCIMUniqueness", err)
+	}
+}
+
+func TestSCIMAdminGroupMapsToRole(t *testing.T) {
+	db, scim := newSCIMTestService(t)
+	user, err := scim.CreateUser(SCIMUserInput{UserName: "dave", Email: "dave@example.com"})
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	memberID := json.RawMessage(`[{"value":"` + strconv.FormatUint(uint64(user.ID), 10) + `"}]`)
+
+	group, err := scim.PatchGroup(SCIMAdminGroupID, []SCIMPatchOperation{{Op: "add", Path: "members", Value: memberID}})
+	if err != nil {
+		t.Fatalf("PatchGroup(add) error = %v", err)
+	}
+	if len(group.Members) != 2 {
+		t.Fatalf("group members = %d, want 2", len(group.Members))
+	}
+
+	if _, err := scim.PatchGroup(SCIMAdminGroupID, []SCIMPatchOperation{{Op: "remove", Path: `members[value eq "` + strconv.FormatUint(uint64(user.ID), 10) + `"]`}}); err != nil {
+		t.Fatalf("PatchGroup(remove) error = %v", err)
+	}
+	var role string
+	if err := db.Model(&model.User{}).Where("id = ?", user.ID).Pluck("role", &role).Error; err != nil {
+		t.Fatalf("failed to load role: %v", err)
+	}
+	if role != "user" {
+		t.Fatalf("role after removal = %q, want user", role)
+	}
+
+	if _, err := scim.ReplaceGroupMembers(SCIMAdminGroupID, []uint{user.ID}); !errors.Is(err, ErrSCIMMutability) {
+		t.Fatalf("replacing members without first user error = %v, want ErrSCIMMutability", err)
+	}
+	if _, err := scim.GetGroup("everyone"); !errors.Is(err, ErrSCIMNotFound) {
+		t.Fatalf("GetGroup(unknown) error = %v, want ErrSCIMNotFound", err)
+	}
+}
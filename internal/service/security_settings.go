@@ -11,6 +11,7 @@ const bcryptMaxPasswordBytes = 72
 var encryptedSystemSettingKeys = map[string]struct{}{
 	"smtp_password":              {},
 	"oidc_client_secret":         {},
+	"ldap_bind_password":         {},
 	"currencyapi_key":            {},
 	"system_proxy_url":           {},
 	"backup_encryption_password": {},
@@ -41,34 +41,46 @@ func NewSubscriptionService(db *gorm.DB) *SubscriptionService {
 }
 
 type CreateSubscriptionInput struct {
-	Name             string  `json:"name"`
-	Amount           float64 `json:"amount"`
-	Currency         string  `json:"currency"`
-	Status           string  `json:"status"`
-	RenewalMode      string  `json:"renewal_mode"`
-	EndsAt           string  `json:"ends_at"`
-	BillingType      string  `json:"billing_type"`
-	RecurrenceType   string  `json:"recurrence_type"`
-	IntervalCount    *int    `json:"interval_count"`
-	IntervalUnit     string  `json:"interval_unit"`
-	NextBillingDate  string  `json:"next_billing_date"`
-	MonthlyDay       *int    `json:"monthly_day"`
-	YearlyMonth      *int    `json:"yearly_month"`
-	YearlyDay        *int    `json:"yearly_day"`
-	Category         string  `json:"category"`
-	CategoryID       *uint   `json:"category_id"`
-	PaymentMethodID  *uint   `json:"payment_method_id"`
-	NotifyEnabled    *bool   `json:"notify_enabled"`
-	NotifyDaysBefore *int    `json:"notify_days_before"`
-	Icon             string  `json:"icon"`
-	URL              string  `json:"url"`
-	Notes            string  `json:"notes"`
+	Name             string   `json:"name"`
+	Amount           float64  `json:"amount"`
+	Currency         string   `json:"currency"`
+	TaxRate          *float64 `json:"tax_rate"`
+	TaxInclusive     bool     `json:"tax_inclusive"`
+	FXFee            float64  `json:"fx_fee"`
+	ProcessorFee     float64  `json:"processor_fee"`
+	AnnualPlanAmount *float64 `json:"annual_plan_amount"`
+	Status           string   `json:"status"`
+	RenewalMode      string   `json:"renewal_mode"`
+	EndsAt           string   `json:"ends_at"`
+	BillingType      string   `json:"billing_type"`
+	RecurrenceType   string   `json:"recurrence_type"`
+	IntervalCount    *int     `json:"interval_count"`
+	IntervalUnit     string   `json:"interval_unit"`
+	NextBillingDate  string   `json:"next_billing_date"`
+	MonthlyDay       *int     `json:"monthly_day"`
+	YearlyMonth      *int     `json:"yearly_month"`
+	YearlyDay        *int     `json:"yearly_day"`
+	Category         string   `json:"category"`
+	CategoryID       *uint    `json:"category_id"`
+	PaymentMethodID  *uint    `json:"payment_method_id"`
+	NotifyEnabled    *bool    `json:"notify_enabled"`
+	NotifyDaysBefore *int     `json:"notify_days_before"`
+	Icon             string   `json:"icon"`
+	URL              string   `json:"url"`
+	Notes            string   `json:"notes"`
+	// CustomFields sets custom field values by field key.
+	CustomFields map[string]string `json:"custom_fields"`
 }
 
 type UpdateSubscriptionInput struct {
 	Name             *string  `json:"name"`
 	Amount           *float64 `json:"amount"`
 	Currency         *string  `json:"currency"`
+	TaxRate          *float64 `json:"tax_rate"`
+	TaxInclusive     *bool    `json:"tax_inclusive"`
+	FXFee            *float64 `json:"fx_fee"`
+	ProcessorFee     *float64 `json:"processor_fee"`
+	AnnualPlanAmount *float64 `json:"annual_plan_amount"`
 	Status           *string  `json:"status"`
 	RenewalMode      *string  `json:"renewal_mode"`
 	EndsAt           *string  `json:"ends_at"`
@@ -88,7 +100,12 @@ type UpdateSubscriptionInput struct {
 	Icon             *string  `json:"icon"`
 	URL              *string  `json:"url"`
 	Notes            *string  `json:"notes"`
+	// CustomFields changes only the keys it contains: an empty value clears
+	// the field and CustomFieldSecretMask keeps a stored secret.
+	CustomFields map[string]string `json:"custom_fields"`
 
+	TaxRateSet          bool `json:"-"`
+	AnnualPlanAmountSet bool `json:"-"`
 	CategoryIDSet       bool `json:"-"`
 	PaymentMethodIDSet  bool `json:"-"`
 	NotifyEnabledSet    bool `json:"-"`
@@ -114,6 +131,12 @@ func (input *UpdateSubscriptionInput) UnmarshalJSON(data []byte) error {
 	if _, ok := raw["notify_days_before"]; ok {
 		input.NotifyDaysBeforeSet = true
 	}
+	if _, ok := raw["tax_rate"]; ok {
+		input.TaxRateSet = true
+	}
+	if _, ok := raw["annual_plan_amount"]; ok {
+		input.AnnualPlanAmountSet = true
+	}
 	if _, ok := raw["category_id"]; ok {
 		input.CategoryIDSet = true
 	}
@@ -133,6 +156,13 @@ type DashboardSummary struct {
 	ActiveCount          int64   `json:"active_count"`
 	UpcomingRenewalCount int64   `json:"upcoming_renewal_count"`
 	Currency             string  `json:"currency"`
+	// MinorUnits is the number of decimal places amounts in Currency use.
+	MinorUnits int `json:"minor_units"`
+	// MonthlyComponents splits ongoing monthly spend into net, tax and fees.
+	// Its Gross adds tax charged on top of tax-exclusive amounts and fixed
+	// fees, so it can exceed TotalMonthly.
+	MonthlyComponents SpendComponents `json:"monthly_components"`
+	YearlyComponents  SpendComponents `json:"yearly_components"`
 }
 
 type billingDraft struct {
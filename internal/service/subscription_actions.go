@@ -4,7 +4,6 @@ import (
 	"errors"
 
 	"github.com/shiroha/subdux/internal/model"
-	"github.com/shiroha/subdux/internal/pkg"
 	"gorm.io/gorm"
 )
 
@@ -12,7 +11,7 @@ func (s *SubscriptionService) MarkManualRenewed(userID, id uint) (*model.Subscri
 	// Persist any due lifecycle transition first so the active/renewal checks
 	// below run against the subscription's true current state rather than a row
 	// the background sweep has not yet caught up on.
-	if err := reconcileSubscriptionForWrite(s.DB, userID, id, pkg.NowInSystemTimezone()); err != nil {
+	if err := reconcileSubscriptionForWrite(s.DB, userID, id, userNow(s.DB, userID)); err != nil {
 		return nil, err
 	}
 
@@ -8,7 +8,7 @@ import (
 	"time"
 
 	"github.com/shiroha/subdux/internal/model"
-	"github.com/shiroha/subdux/internal/pkg"
+	"github.com/shiroha/subdux/internal/pkg/money"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
@@ -20,6 +20,9 @@ const (
 	actionTypeNotificationFailed = "notification_failed"
 	actionTypeMissingNextBilling = "missing_next_billing"
 	actionTypePriceIncrease      = "price_increase"
+	actionTypeScheduledPrice     = "scheduled_price_increase"
+	actionTypeSavings            = "savings_opportunity"
+	actionTypeCardExpiring       = "payment_method_expiring"
 	actionSeverityCritical       = "critical"
 	actionSeverityHigh           = "high"
 	actionSeverityMedium         = "medium"
@@ -29,18 +32,22 @@ const (
 	actionCenterUrgentDays       = 7
 	actionCenterRecentChangeDays = 30
 	actionCenterFailedLogDays    = 30
+	actionCenterScheduledDays    = 60
+	actionCenterCardExpiryDays   = 60
 	actionCenterMaxItems         = 100
 	notificationLogStatusFailed  = "failed"
 	notificationLogStatusSent    = "sent"
 )
 
 type ActionCenter struct {
-	GeneratedAt    time.Time            `json:"generated_at"`
-	WindowDays     int                  `json:"window_days"`
-	UrgentDays     int                  `json:"urgent_days"`
-	Items          []SubscriptionAction `json:"items"`
-	Counts         ActionCenterCounts   `json:"counts"`
-	AvailableTypes []string             `json:"available_types"`
+	GeneratedAt            time.Time            `json:"generated_at"`
+	WindowDays             int                  `json:"window_days"`
+	UrgentDays             int                  `json:"urgent_days"`
+	Items                  []SubscriptionAction `json:"items"`
+	Counts                 ActionCenterCounts   `json:"counts"`
+	AvailableTypes         []string             `json:"available_types"`
+	SavingsCurrency        string               `json:"savings_currency"`
+	PotentialYearlySavings float64              `json:"potential_yearly_savings"`
 }
 
 type ActionCenterCounts struct {
@@ -56,32 +63,37 @@ type ActionCenterCounts struct {
 }
 
 type SubscriptionAction struct {
-	Key                   string     `json:"key"`
-	Type                  string     `json:"type"`
-	Severity              string     `json:"severity"`
-	NeedsDecision         bool       `json:"needs_decision"`
-	NeedsRepair           bool       `json:"needs_repair"`
-	UpcomingCharge        bool       `json:"upcoming_charge"`
-	SubscriptionID        uint       `json:"subscription_id"`
-	SubscriptionName      string     `json:"subscription_name"`
-	SubscriptionIcon      string     `json:"subscription_icon"`
-	Amount                float64    `json:"amount"`
-	Currency              string     `json:"currency"`
-	RenewalMode           string     `json:"renewal_mode"`
-	Status                string     `json:"status"`
-	DueDate               *string    `json:"due_date"`
-	DaysUntil             *int       `json:"days_until"`
-	EventDate             *string    `json:"event_date"`
-	Message               string     `json:"message"`
-	Detail                string     `json:"detail"`
-	PreviousMonthlyAmount *float64   `json:"previous_monthly_amount"`
-	NewMonthlyAmount      *float64   `json:"new_monthly_amount"`
-	DeltaMonthlyAmount    *float64   `json:"delta_monthly_amount"`
-	DeltaPercentage       *float64   `json:"delta_percentage"`
-	NotificationChannel   string     `json:"notification_channel"`
-	NotificationError     string     `json:"notification_error"`
-	AllowedActions        []string   `json:"allowed_actions"`
-	SnoozedUntil          *time.Time `json:"snoozed_until"`
+	Key                    string     `json:"key"`
+	Type                   string     `json:"type"`
+	Severity               string     `json:"severity"`
+	NeedsDecision          bool       `json:"needs_decision"`
+	NeedsRepair            bool       `json:"needs_repair"`
+	UpcomingCharge         bool       `json:"upcoming_charge"`
+	SubscriptionID         uint       `json:"subscription_id"`
+	SubscriptionName       string     `json:"subscription_name"`
+	SubscriptionIcon       string     `json:"subscription_icon"`
+	Amount                 float64    `json:"amount"`
+	Currency               string     `json:"currency"`
+	RenewalMode            string     `json:"renewal_mode"`
+	Status                 string     `json:"status"`
+	DueDate                *string    `json:"due_date"`
+	DaysUntil              *int       `json:"days_until"`
+	EventDate              *string    `json:"event_date"`
+	Message                string     `json:"message"`
+	Detail                 string     `json:"detail"`
+	PreviousMonthlyAmount  *float64   `json:"previous_monthly_amount"`
+	NewMonthlyAmount       *float64   `json:"new_monthly_amount"`
+	DeltaMonthlyAmount     *float64   `json:"delta_monthly_amount"`
+	DeltaPercentage        *float64   `json:"delta_percentage"`
+	NotificationChannel    string     `json:"notification_channel"`
+	NotificationError      string     `json:"notification_error"`
+	SavingsReason          string     `json:"savings_reason"`
+	YearlySavings          *float64   `json:"yearly_savings"`
+	RelatedSubscriptionIDs []uint     `json:"related_subscription_ids"`
+	PaymentMethodID        *uint      `json:"payment_method_id"`
+	PaymentMethodName      string     `json:"payment_method_name"`
+	AllowedActions         []string   `json:"allowed_actions"`
+	SnoozedUntil           *time.Time `json:"snoozed_until"`
 }
 
 type SnoozeSubscriptionActionInput struct {
@@ -90,8 +102,14 @@ type SnoozeSubscriptionActionInput struct {
 	UntilDate string `json:"until_date"`
 }
 
-func (s *SubscriptionService) GetActionCenter(userID uint) (*ActionCenter, error) {
-	now := pkg.NowInSystemTimezone()
+// GetActionCenter collects the subscriptions that need attention. Savings
+// opportunities are valued in targetCurrency, the user's preferred currency.
+func (s *SubscriptionService) GetActionCenter(userID uint, targetCurrency string, converter CurrencyConverter) (*ActionCenter, error) {
+	now := userNow(s.DB, userID)
+	if strings.TrimSpace(targetCurrency) == "" {
+		targetCurrency = "USD"
+	}
+	targetCurrency = strings.ToUpper(strings.TrimSpace(targetCurrency))
 
 	today := normalizeDateUTC(now)
 	windowEnd := today.AddDate(0, 0, actionCenterUpcomingDays)
@@ -126,6 +144,21 @@ func (s *SubscriptionService) GetActionCenter(userID uint) (*ActionCenter, error
 	}
 	items = append(items, priceItems...)
 
+	scheduledItems, err := s.scheduledPriceIncreaseActions(userID, subs, today)
+	if err != nil {
+		return nil, err
+	}
+	items = append(items, scheduledItems...)
+
+	cardItems, err := s.paymentMethodExpiryActions(userID, subs, today)
+	if err != nil {
+		return nil, err
+	}
+	items = append(items, cardItems...)
+
+	opportunities, potentialSavings := savingsOpportunities(subs, today, targetCurrency, converter, defaultSavingsUnusedDays)
+	items = append(items, savingsActions(subs, opportunities)...)
+
 	visible := make([]SubscriptionAction, 0, len(items))
 	snoozedCount := 0
 	for _, item := range items {
@@ -158,12 +191,14 @@ func (s *SubscriptionService) GetActionCenter(userID uint) (*ActionCenter, error
 	}
 
 	return &ActionCenter{
-		GeneratedAt:    now,
-		WindowDays:     actionCenterUpcomingDays,
-		UrgentDays:     actionCenterUrgentDays,
-		Items:          visible,
-		Counts:         buildActionCenterCounts(visible, snoozedCount),
-		AvailableTypes: []string{actionTypeManualRenewalDue, actionTypeNotificationFailed, actionTypeMissingNextBilling, actionTypePriceIncrease, actionTypeEndingSoon, actionTypeUpcomingRenewal},
+		GeneratedAt:            now,
+		WindowDays:             actionCenterUpcomingDays,
+		UrgentDays:             actionCenterUrgentDays,
+		Items:                  visible,
+		Counts:                 buildActionCenterCounts(visible, snoozedCount),
+		AvailableTypes:         []string{actionTypeManualRenewalDue, actionTypeNotificationFailed, actionTypeMissingNextBilling, actionTypeCardExpiring, actionTypePriceIncrease, actionTypeScheduledPrice, actionTypeSavings, actionTypeEndingSoon, actionTypeUpcomingRenewal},
+		SavingsCurrency:        targetCurrency,
+		PotentialYearlySavings: money.FromMinorUnits(potentialSavings, targetCurrency),
 	}, nil
 }
 
@@ -182,7 +217,7 @@ func (s *SubscriptionService) SnoozeAction(userID uint, input SnoozeSubscription
 		return nil, err
 	}
 
-	snoozedUntil, err := resolveActionSnoozeUntil(input)
+	snoozedUntil, err := resolveActionSnoozeUntil(input, userNow(s.DB, userID))
 	if err != nil {
 		return nil, err
 	}
@@ -372,7 +407,7 @@ func newScheduleAction(
 func (s *SubscriptionService) notificationFailureActions(userID uint, today time.Time) ([]SubscriptionAction, error) {
 	since := today.AddDate(0, 0, -actionCenterFailedLogDays)
 	var logs []model.NotificationLog
-	if err := s.DB.Where("user_id = ? AND status = ? AND sent_at >= ?", userID, notificationLogStatusFailed, since).
+	if err := s.DB.Where("user_id = ? AND status = ? AND sent_at >= ? AND subscription_id IS NOT NULL", userID, notificationLogStatusFailed, since).
 		Order("sent_at DESC, id DESC").
 		Limit(100).
 		Find(&logs).Error; err != nil {
@@ -396,7 +431,7 @@ func (s *SubscriptionService) notificationFailureActions(userID uint, today time
 	candidates := make([]failedCandidate, 0, len(logs))
 	ids := make([]uint, 0, len(logs))
 	for _, logEntry := range logs {
-		key := subscriptionActionKey(logEntry.SubscriptionID, actionTypeNotificationFailed, logEntry.ChannelType)
+		key := subscriptionActionKey(*logEntry.SubscriptionID, actionTypeNotificationFailed, logEntry.ChannelType)
 		if _, ok := seen[key]; ok {
 			continue
 		}
@@ -405,7 +440,7 @@ func (s *SubscriptionService) notificationFailureActions(userID uint, today time
 			continue
 		}
 		candidates = append(candidates, failedCandidate{log: logEntry, key: key})
-		ids = append(ids, logEntry.SubscriptionID)
+		ids = append(ids, *logEntry.SubscriptionID)
 	}
 
 	subsByID, err := s.loadSubscriptionsByIDs(userID, ids, today)
@@ -416,7 +451,7 @@ func (s *SubscriptionService) notificationFailureActions(userID uint, today time
 	items := make([]SubscriptionAction, 0, len(candidates))
 	for _, candidate := range candidates {
 		logEntry := candidate.log
-		sub, ok := subsByID[logEntry.SubscriptionID]
+		sub, ok := subsByID[*logEntry.SubscriptionID]
 		if !ok {
 			continue
 		}
@@ -461,21 +496,24 @@ func (s *SubscriptionService) notificationRecoveryIndex(userID uint, since time.
 	var sentLogs []model.NotificationLog
 	if err := s.DB.
 		Select("subscription_id", "channel_type", "sent_at").
-		Where("user_id = ? AND status = ? AND sent_at >= ?", userID, notificationLogStatusSent, since).
+		Where("user_id = ? AND status = ? AND sent_at >= ? AND subscription_id IS NOT NULL", userID, notificationLogStatusSent, since).
 		Find(&sentLogs).Error; err != nil {
 		return nil, err
 	}
 
 	latestSent := make(map[string]time.Time, len(sentLogs))
 	for _, sent := range sentLogs {
-		key := notificationRecoveryKey(sent.SubscriptionID, sent.ChannelType)
+		key := notificationRecoveryKey(*sent.SubscriptionID, sent.ChannelType)
 		if current, ok := latestSent[key]; !ok || sent.SentAt.After(current) {
 			latestSent[key] = sent.SentAt
 		}
 	}
 
 	return func(failed model.NotificationLog) bool {
-		latest, ok := latestSent[notificationRecoveryKey(failed.SubscriptionID, failed.ChannelType)]
+		if failed.SubscriptionID == nil {
+			return false
+		}
+		latest, ok := latestSent[notificationRecoveryKey(*failed.SubscriptionID, failed.ChannelType)]
 		return ok && latest.After(failed.SentAt)
 	}, nil
 }
@@ -571,6 +609,223 @@ func (s *SubscriptionService) priceIncreaseActions(userID uint, today time.Time)
 	return items, nil
 }
 
+// scheduledPriceIncreaseActions surfaces announced price increases that take
+// effect within actionCenterScheduledDays, so a user can decide to cancel
+// before the first charge at the new price. A change to another currency is
+// always shown because it cannot be compared without a rate.
+func (s *SubscriptionService) scheduledPriceIncreaseActions(userID uint, subs []model.Subscription, today time.Time) ([]SubscriptionAction, error) {
+	pending, err := s.pendingPriceChangesBySubscription(userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(pending) == 0 {
+		return nil, nil
+	}
+
+	windowEnd := today.AddDate(0, 0, actionCenterScheduledDays)
+	items := make([]SubscriptionAction, 0, len(pending))
+	for _, sub := range subs {
+		if normalizeStatus(sub.Status) != subscriptionStatusActive {
+			continue
+		}
+		previousAmount := sub.Amount
+		previousCurrency := strings.ToUpper(strings.TrimSpace(sub.Currency))
+		for _, change := range pending[sub.ID] {
+			effectiveDate := normalizeDateUTC(change.EffectiveDate)
+			if effectiveDate.After(windowEnd) {
+				break
+			}
+			newCurrency := strings.ToUpper(strings.TrimSpace(change.Currency))
+			sameCurrency := newCurrency == previousCurrency
+			increase := !sameCurrency || change.Amount > previousAmount
+			if increase && !effectiveDate.Before(today) {
+				date := effectiveDate.Format("2006-01-02")
+				daysUntil := int(effectiveDate.Sub(today).Hours() / 24)
+				severity := actionSeverityLow
+				if daysUntil <= actionCenterUpcomingDays {
+					severity = actionSeverityMedium
+				}
+				if daysUntil <= actionCenterUrgentDays {
+					severity = actionSeverityHigh
+				}
+				item := SubscriptionAction{
+					Key:              subscriptionActionKey(sub.ID, actionTypeScheduledPrice, date),
+					Type:             actionTypeScheduledPrice,
+					Severity:         severity,
+					NeedsDecision:    true,
+					SubscriptionID:   sub.ID,
+					SubscriptionName: sub.Name,
+					SubscriptionIcon: sub.Icon,
+					Amount:           change.Amount,
+					Currency:         newCurrency,
+					RenewalMode:      normalizeRenewalMode(sub.RenewalMode),
+					Status:           normalizeStatus(sub.Status),
+					DueDate:          &date,
+					DaysUntil:        &daysUntil,
+					Message:          "price increase scheduled",
+					Detail:           "decide whether to keep the subscription before the new price applies",
+					AllowedActions:   []string{"cancel_at_period_end", "edit", "open_detail", "snooze"},
+				}
+				if sameCurrency {
+					factor := subscriptionMonthlyFactor(sub)
+					previous := money.FromMinorUnits(money.Multiply(previousAmount, factor, newCurrency), newCurrency)
+					current := money.FromMinorUnits(money.Multiply(change.Amount, factor, newCurrency), newCurrency)
+					delta := moneyDifference(current, previous, newCurrency)
+					percentage := percentageDelta(previous, current)
+					item.PreviousMonthlyAmount = &previous
+					item.NewMonthlyAmount = &current
+					item.DeltaMonthlyAmount = &delta
+					item.DeltaPercentage = &percentage
+				}
+				items = append(items, item)
+			}
+			previousAmount, previousCurrency = change.Amount, newCurrency
+		}
+	}
+	return items, nil
+}
+
+// paymentMethodExpiryActions raises one item per card that has expired or
+// expires within actionCenterCardExpiryDays while active subscriptions are
+// still billed to it. The item hangs off the subscription charged soonest, so
+// it can be snoozed like any other, and lists every affected subscription in
+// RelatedSubscriptionIDs.
+func (s *SubscriptionService) paymentMethodExpiryActions(userID uint, subs []model.Subscription, today time.Time) ([]SubscriptionAction, error) {
+	var methods []model.PaymentMethod
+	if err := s.DB.Where("user_id = ? AND expiry_month IS NOT NULL AND expiry_year IS NOT NULL", userID).
+		Order("sort_order ASC, id ASC").
+		Find(&methods).Error; err != nil {
+		return nil, err
+	}
+	if len(methods) == 0 {
+		return nil, nil
+	}
+
+	linked := make(map[uint][]model.Subscription, len(methods))
+	for _, sub := range subs {
+		if sub.PaymentMethodID == nil || normalizeStatus(sub.Status) != subscriptionStatusActive {
+			continue
+		}
+		linked[*sub.PaymentMethodID] = append(linked[*sub.PaymentMethodID], sub)
+	}
+
+	windowEnd := today.AddDate(0, 0, actionCenterCardExpiryDays)
+	items := make([]SubscriptionAction, 0, len(methods))
+	for _, method := range methods {
+		expiresOn, ok := PaymentMethodExpiryDate(method)
+		if !ok || expiresOn.After(windowEnd) || len(linked[method.ID]) == 0 {
+			continue
+		}
+		affected := linked[method.ID]
+		sort.SliceStable(affected, func(i, j int) bool {
+			return actionSubscriptionChargeDate(affected[i]).Before(actionSubscriptionChargeDate(affected[j]))
+		})
+		anchor := affected[0]
+		ids := make([]uint, 0, len(affected))
+		for _, sub := range affected {
+			ids = append(ids, sub.ID)
+		}
+
+		daysUntil := int(expiresOn.Sub(today).Hours() / 24)
+		severity := actionSeverityLow
+		message := "payment method expiring soon"
+		if daysUntil <= actionCenterUpcomingDays {
+			severity = actionSeverityMedium
+		}
+		if daysUntil <= actionCenterUrgentDays {
+			severity = actionSeverityHigh
+		}
+		if daysUntil < 0 {
+			severity = actionSeverityCritical
+			message = "payment method has expired"
+		}
+		date := expiresOn.Format("2006-01-02")
+		methodID := method.ID
+		items = append(items, SubscriptionAction{
+			Key:                    subscriptionActionKey(anchor.ID, actionTypeCardExpiring, fmt.Sprintf("%d:%s", method.ID, expiresOn.Format("2006-01"))),
+			Type:                   actionTypeCardExpiring,
+			Severity:               severity,
+			NeedsRepair:            true,
+			SubscriptionID:         anchor.ID,
+			SubscriptionName:       anchor.Name,
+			SubscriptionIcon:       anchor.Icon,
+			Amount:                 anchor.Amount,
+			Currency:               strings.ToUpper(strings.TrimSpace(anchor.Currency)),
+			RenewalMode:            normalizeRenewalMode(anchor.RenewalMode),
+			Status:                 normalizeStatus(anchor.Status),
+			DueDate:                &date,
+			DaysUntil:              &daysUntil,
+			Message:                message,
+			Detail:                 fmt.Sprintf("%s is used by %d subscription(s); replace it or update its expiry", paymentMethodLabel(method), len(affected)),
+			RelatedSubscriptionIDs: ids,
+			PaymentMethodID:        &methodID,
+			PaymentMethodName:      method.Name,
+			AllowedActions:         []string{"replace_payment_method", "edit_payment_method", "open_detail", "snooze"},
+		})
+	}
+	return items, nil
+}
+
+// actionSubscriptionChargeDate orders subscriptions by their next charge,
+// putting those without one last.
+func actionSubscriptionChargeDate(sub model.Subscription) time.Time {
+	if sub.NextBillingDate == nil {
+		return time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+	}
+	return normalizeDateUTC(*sub.NextBillingDate)
+}
+
+// savingsActions turns savings opportunities into Action Center items, one
+// per subscription and reason so each can be snoozed on its own. Unused
+// subscriptions rank above plan and duplicate suggestions.
+func savingsActions(subs []model.Subscription, opportunities []SavingsOpportunity) []SubscriptionAction {
+	if len(opportunities) == 0 {
+		return nil
+	}
+	subsByID := make(map[uint]model.Subscription, len(subs))
+	for _, sub := range subs {
+		subsByID[sub.ID] = sub
+	}
+
+	items := make([]SubscriptionAction, 0, len(opportunities))
+	for _, opportunity := range opportunities {
+		sub, ok := subsByID[opportunity.SubscriptionID]
+		if !ok {
+			continue
+		}
+		severity := actionSeverityLow
+		message := "cheaper annual plan available"
+		switch opportunity.Reason {
+		case savingsReasonUnused:
+			severity = actionSeverityMedium
+			message = "subscription appears unused"
+		case savingsReasonDuplicate:
+			message = "possible duplicate subscription"
+		}
+		savings := opportunity.YearlySavings
+		items = append(items, SubscriptionAction{
+			Key:                    subscriptionActionKey(sub.ID, actionTypeSavings, opportunity.Reason),
+			Type:                   actionTypeSavings,
+			Severity:               severity,
+			NeedsDecision:          true,
+			SubscriptionID:         sub.ID,
+			SubscriptionName:       sub.Name,
+			SubscriptionIcon:       sub.Icon,
+			Amount:                 sub.Amount,
+			Currency:               strings.ToUpper(strings.TrimSpace(sub.Currency)),
+			RenewalMode:            normalizeRenewalMode(sub.RenewalMode),
+			Status:                 normalizeStatus(sub.Status),
+			Message:                message,
+			Detail:                 opportunity.Detail,
+			SavingsReason:          opportunity.Reason,
+			YearlySavings:          &savings,
+			RelatedSubscriptionIDs: opportunity.RelatedSubscriptionIDs,
+			AllowedActions:         []string{"cancel_at_period_end", "edit", "open_detail", "snooze"},
+		})
+	}
+	return items
+}
+
 func subscriptionActionKey(subscriptionID uint, actionType, qualifier string) string {
 	parts := []string{fmt.Sprintf("subscription:%d", subscriptionID), actionType}
 	if strings.TrimSpace(qualifier) != "" {
@@ -591,7 +846,7 @@ func parseActionSubscriptionID(key string) (uint, error) {
 	return id, nil
 }
 
-func resolveActionSnoozeUntil(input SnoozeSubscriptionActionInput) (time.Time, error) {
+func resolveActionSnoozeUntil(input SnoozeSubscriptionActionInput, now time.Time) (time.Time, error) {
 	if strings.TrimSpace(input.UntilDate) != "" {
 		parsed, err := parseOptionalDateString(input.UntilDate)
 		if err != nil {
@@ -601,7 +856,7 @@ func resolveActionSnoozeUntil(input SnoozeSubscriptionActionInput) (time.Time, e
 			return time.Time{}, errors.New("snooze date is required")
 		}
 		snoozedUntil := normalizeDateUTC(*parsed)
-		if !snoozedUntil.After(normalizeDateUTC(pkg.NowInSystemTimezone())) {
+		if !snoozedUntil.After(normalizeDateUTC(now)) {
 			return time.Time{}, errors.New("snooze date must be in the future")
 		}
 		return snoozedUntil, nil
@@ -614,7 +869,7 @@ func resolveActionSnoozeUntil(input SnoozeSubscriptionActionInput) (time.Time, e
 	if days > actionCenterUpcomingDays {
 		days = actionCenterUpcomingDays
 	}
-	return normalizeDateUTC(pkg.NowInSystemTimezone()).AddDate(0, 0, days), nil
+	return normalizeDateUTC(now).AddDate(0, 0, days), nil
 }
 
 func actionSeverityRank(severity string) int {
@@ -87,7 +87,7 @@ func TestGetActionCenterAggregatesRenewalRepairAndHistoryItems(t *testing.T) {
 
 	if err := db.Create(&model.NotificationLog{
 		UserID:         user.ID,
-		SubscriptionID: autoRenew.ID,
+		SubscriptionID: &autoRenew.ID,
 		ChannelType:    "webhook",
 		NotifyDate:     mustDate(t, "2026-03-04"),
 		Status:         "failed",
@@ -102,7 +102,7 @@ func TestGetActionCenterAggregatesRenewalRepairAndHistoryItems(t *testing.T) {
 		t.Fatalf("update subscription amount failed: %v", err)
 	}
 
-	center, err := service.GetActionCenter(user.ID)
+	center, err := service.GetActionCenter(user.ID, "USD", nil)
 	if err != nil {
 		t.Fatalf("GetActionCenter() error = %v", err)
 	}
@@ -175,7 +175,7 @@ func TestSnoozeActionHidesMatchingItem(t *testing.T) {
 		t.Fatalf("SnoozeAction() error = %v", err)
 	}
 
-	center, err := service.GetActionCenter(user.ID)
+	center, err := service.GetActionCenter(user.ID, "USD", nil)
 	if err != nil {
 		t.Fatalf("GetActionCenter() error = %v", err)
 	}
@@ -214,7 +214,7 @@ func TestActionCenterSuppressesRecoveredNotificationFailures(t *testing.T) {
 
 	if err := db.Create(&model.NotificationLog{
 		UserID:         user.ID,
-		SubscriptionID: sub.ID,
+		SubscriptionID: &sub.ID,
 		ChannelType:    "webhook",
 		NotifyDate:     mustDate(t, "2026-03-04"),
 		Status:         notificationLogStatusFailed,
@@ -225,7 +225,7 @@ func TestActionCenterSuppressesRecoveredNotificationFailures(t *testing.T) {
 	}
 	if err := db.Create(&model.NotificationLog{
 		UserID:         user.ID,
-		SubscriptionID: sub.ID,
+		SubscriptionID: &sub.ID,
 		ChannelType:    "webhook",
 		NotifyDate:     mustDate(t, "2026-03-04"),
 		Status:         notificationLogStatusSent,
@@ -234,7 +234,7 @@ func TestActionCenterSuppressesRecoveredNotificationFailures(t *testing.T) {
 		t.Fatalf("create recovered notification log failed: %v", err)
 	}
 
-	center, err := service.GetActionCenter(user.ID)
+	center, err := service.GetActionCenter(user.ID, "USD", nil)
 	if err != nil {
 		t.Fatalf("GetActionCenter() error = %v", err)
 	}
@@ -280,7 +280,7 @@ func TestActionCenterSuppressesOlderPriceIncreasesAfterReduction(t *testing.T) {
 		t.Fatalf("reduce subscription amount failed: %v", err)
 	}
 
-	center, err := service.GetActionCenter(user.ID)
+	center, err := service.GetActionCenter(user.ID, "USD", nil)
 	if err != nil {
 		t.Fatalf("GetActionCenter() error = %v", err)
 	}
@@ -319,7 +319,7 @@ func TestActionCenterSuppressesEndedSubscriptionNotificationFailures(t *testing.
 
 	if err := db.Create(&model.NotificationLog{
 		UserID:         user.ID,
-		SubscriptionID: sub.ID,
+		SubscriptionID: &sub.ID,
 		ChannelType:    "webhook",
 		NotifyDate:     mustDate(t, "2026-02-18"),
 		Status:         notificationLogStatusFailed,
@@ -329,7 +329,7 @@ func TestActionCenterSuppressesEndedSubscriptionNotificationFailures(t *testing.
 		t.Fatalf("create failed notification log failed: %v", err)
 	}
 
-	center, err := service.GetActionCenter(user.ID)
+	center, err := service.GetActionCenter(user.ID, "USD", nil)
 	if err != nil {
 		t.Fatalf("GetActionCenter() error = %v", err)
 	}
@@ -375,7 +375,7 @@ func TestActionCenterSuppressesEndedSubscriptionPriceIncreases(t *testing.T) {
 		t.Fatalf("end subscription failed: %v", err)
 	}
 
-	center, err := service.GetActionCenter(user.ID)
+	center, err := service.GetActionCenter(user.ID, "USD", nil)
 	if err != nil {
 		t.Fatalf("GetActionCenter() error = %v", err)
 	}
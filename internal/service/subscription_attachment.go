@@ -0,0 +1,324 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shiroha/subdux/internal/model"
+	"github.com/shiroha/subdux/internal/pkg"
+	"gorm.io/gorm"
+)
+
+const (
+	maxAttachmentFileSizeKey  = "max_attachment_file_size"
+	attachmentQuotaPerUserKey = "attachment_quota_per_user"
+
+	defaultMaxAttachmentFileSize  int64 = 10 << 20
+	defaultAttachmentQuotaPerUser int64 = 100 << 20
+
+	// MaxAttachmentUploadBytes caps the configurable per-file limit so the
+	// upload route can bound request bodies.
+	MaxAttachmentUploadBytes int64 = 50 << 20
+)
+
+var (
+	ErrAttachmentNotFound      = errors.New("attachment not found")
+	ErrAttachmentQuotaExceeded = errors.New("attachment storage quota exceeded")
+	ErrInvalidAttachmentLimit  = errors.New("attachment size limits must be positive and files at most 50 MiB")
+)
+
+// UploadAttachmentInput carries the optional billing period an attachment
+// covers, as YYYY-MM-DD dates.
+type UploadAttachmentInput struct {
+	PeriodStart string `json:"period_start"`
+	PeriodEnd   string `json:"period_end"`
+}
+
+// UpdateAttachmentInput changes the billing period of an attachment. An empty
+// string clears a date; nil leaves it unchanged.
+type UpdateAttachmentInput struct {
+	PeriodStart *string `json:"period_start"`
+	PeriodEnd   *string `json:"period_end"`
+}
+
+type SubscriptionAttachmentList struct {
+	Attachments []model.SubscriptionAttachment `json:"attachments"`
+	UsedBytes   int64                          `json:"used_bytes"`
+	QuotaBytes  int64                          `json:"quota_bytes"`
+}
+
+func (s *SubscriptionService) GetMaxAttachmentFileSize() int64 {
+	return getPositiveInt64SystemSetting(s.DB, maxAttachmentFileSizeKey, defaultMaxAttachmentFileSize)
+}
+
+func (s *SubscriptionService) GetAttachmentQuota() int64 {
+	return getPositiveInt64SystemSetting(s.DB, attachmentQuotaPerUserKey, defaultAttachmentQuotaPerUser)
+}
+
+func getPositiveInt64SystemSetting(db *gorm.DB, key string, fallback int64) int64 {
+	value, err := getSystemSettingValue(db, key, "")
+	if err != nil {
+		return fallback
+	}
+	if v, err := strconv.ParseInt(value, 10, 64); err == nil && v > 0 {
+		return v
+	}
+	return fallback
+}
+
+// ListAttachments returns a subscription's attachments, newest first, with
+// the user's storage usage against the quota.
+func (s *SubscriptionService) ListAttachments(userID, subscriptionID uint) (*SubscriptionAttachmentList, error) {
+	if _, err := s.GetByID(userID, subscriptionID); err != nil {
+		return nil, err
+	}
+
+	attachments := []model.SubscriptionAttachment{}
+	if err := s.DB.Where("user_id = ? AND subscription_id = ?", userID, subscriptionID).
+		Order("created_at DESC").
+		Order("id DESC").
+		Find(&attachments).Error; err != nil {
+		return nil, err
+	}
+	used, err := s.attachmentBytesUsed(userID)
+	if err != nil {
+		return nil, err
+	}
+	return &SubscriptionAttachmentList{
+		Attachments: attachments,
+		UsedBytes:   used,
+		QuotaBytes:  s.GetAttachmentQuota(),
+	}, nil
+}
+
+// UploadAttachment stores a PDF, PNG or JPG file for a subscription. The file
+// must fit both the per-file limit and what is left of the user's quota.
+func (s *SubscriptionService) UploadAttachment(userID, subscriptionID uint, file io.Reader, filename string, input UploadAttachmentInput) (*model.SubscriptionAttachment, error) {
+	if _, err := s.GetByID(userID, subscriptionID); err != nil {
+		return nil, err
+	}
+	periodStart, periodEnd, err := parseAttachmentPeriod(input.PeriodStart, input.PeriodEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	content, ext, contentType, err := ValidateAttachmentFile(file, filename, s.GetMaxAttachmentFileSize())
+	if err != nil {
+		return nil, err
+	}
+	used, err := s.attachmentBytesUsed(userID)
+	if err != nil {
+		return nil, err
+	}
+	if used+int64(len(content)) > s.GetAttachmentQuota() {
+		return nil, ErrAttachmentQuotaExceeded
+	}
+
+	token, err := newAttachmentToken()
+	if err != nil {
+		return nil, err
+	}
+	storedName := fmt.Sprintf("%d_%d_%s%s", userID, subscriptionID, token, ext)
+	destPath, ok := managedAttachmentFilePath(storedName)
+	if !ok {
+		return nil, errors.New("failed to save attachment file")
+	}
+	if err := os.MkdirAll(filepath.Dir(destPath), 0o750); err != nil {
+		return nil, errors.New("failed to create attachment directory")
+	}
+	if err := os.WriteFile(destPath, content, 0o600); err != nil {
+		return nil, errors.New("failed to save attachment file")
+	}
+
+	attachment := model.SubscriptionAttachment{
+		UserID:         userID,
+		SubscriptionID: subscriptionID,
+		Filename:       sanitizeAttachmentFilename(filename, ext),
+		StoredName:     storedName,
+		ContentType:    contentType,
+		Size:           int64(len(content)),
+		PeriodStart:    periodStart,
+		PeriodEnd:      periodEnd,
+	}
+	if err := s.DB.Create(&attachment).Error; err != nil {
+		_ = os.Remove(destPath)
+		return nil, err
+	}
+	return &attachment, nil
+}
+
+// UpdateAttachment changes the billing period recorded for an attachment.
+func (s *SubscriptionService) UpdateAttachment(userID, subscriptionID, attachmentID uint, input UpdateAttachmentInput) (*model.SubscriptionAttachment, error) {
+	attachment, err := s.getAttachment(userID, subscriptionID, attachmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	start := formatOptionalDate(attachment.PeriodStart)
+	if input.PeriodStart != nil {
+		start = *input.PeriodStart
+	}
+	end := formatOptionalDate(attachment.PeriodEnd)
+	if input.PeriodEnd != nil {
+		end = *input.PeriodEnd
+	}
+	periodStart, periodEnd, err := parseAttachmentPeriod(start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.DB.Model(attachment).Updates(map[string]interface{}{
+		"period_start": periodStart,
+		"period_end":   periodEnd,
+		"updated_at":   pkg.NowUTC(),
+	}).Error; err != nil {
+		return nil, err
+	}
+	return s.getAttachment(userID, subscriptionID, attachmentID)
+}
+
+// OpenAttachment returns an attachment's record and an open handle to its
+// file. The caller closes the file.
+func (s *SubscriptionService) OpenAttachment(userID, subscriptionID, attachmentID uint) (*model.SubscriptionAttachment, *os.File, error) {
+	attachment, err := s.getAttachment(userID, subscriptionID, attachmentID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, ok := managedAttachmentFilePath(attachment.StoredName); !ok {
+		return nil, nil, ErrAttachmentNotFound
+	}
+
+	root, err := os.OpenRoot(attachmentsDir())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil, ErrAttachmentNotFound
+		}
+		return nil, nil, err
+	}
+	defer func() {
+		_ = root.Close()
+	}()
+
+	file, err := root.Open(attachment.StoredName)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil, ErrAttachmentNotFound
+		}
+		return nil, nil, err
+	}
+	return attachment, file, nil
+}
+
+func (s *SubscriptionService) DeleteAttachment(userID, subscriptionID, attachmentID uint) error {
+	attachment, err := s.getAttachment(userID, subscriptionID, attachmentID)
+	if err != nil {
+		return err
+	}
+	if err := s.DB.Delete(attachment).Error; err != nil {
+		return err
+	}
+	removeManagedAttachmentFile(attachment.StoredName)
+	return nil
+}
+
+func (s *SubscriptionService) getAttachment(userID, subscriptionID, attachmentID uint) (*model.SubscriptionAttachment, error) {
+	var attachment model.SubscriptionAttachment
+	if err := s.DB.Where("id = ? AND user_id = ? AND subscription_id = ?", attachmentID, userID, subscriptionID).
+		First(&attachment).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAttachmentNotFound
+		}
+		return nil, err
+	}
+	return &attachment, nil
+}
+
+func (s *SubscriptionService) attachmentBytesUsed(userID uint) (int64, error) {
+	var used int64
+	err := s.DB.Model(&model.SubscriptionAttachment{}).
+		Where("user_id = ?", userID).
+		Select("COALESCE(SUM(size), 0)").
+		Scan(&used).Error
+	return used, err
+}
+
+func parseAttachmentPeriod(start, end string) (*time.Time, *time.Time, error) {
+	periodStart, err := parseOptionalDateString(start)
+	if err != nil {
+		return nil, nil, errors.New("period_start must be a date in YYYY-MM-DD format")
+	}
+	periodEnd, err := parseOptionalDateString(end)
+	if err != nil {
+		return nil, nil, errors.New("period_end must be a date in YYYY-MM-DD format")
+	}
+	if periodStart != nil && periodEnd != nil && periodEnd.Before(*periodStart) {
+		return nil, nil, errors.New("period_end must be on or after period_start")
+	}
+	return periodStart, periodEnd, nil
+}
+
+func formatOptionalDate(value *time.Time) string {
+	if value == nil {
+		return ""
+	}
+	return value.Format("2006-01-02")
+}
+
+func newAttachmentToken() (string, error) {
+	var buf [16]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf[:]), nil
+}
+
+func attachmentsDir() string {
+	return filepath.Join(pkg.GetDataPath(), "assets", "attachments")
+}
+
+// managedAttachmentFilePath resolves a stored attachment name to its path,
+// rejecting anything that is not a plain file name with an allowed extension.
+func managedAttachmentFilePath(storedName string) (string, bool) {
+	if storedName == "" || strings.ContainsAny(storedName, `/\`) || filepath.Base(storedName) != storedName {
+		return "", false
+	}
+	if normalizeAttachmentExtension(storedName) != strings.ToLower(filepath.Ext(storedName)) {
+		return "", false
+	}
+	return filepath.Join(attachmentsDir(), storedName), true
+}
+
+func removeManagedAttachmentFile(storedName string) {
+	if path, ok := managedAttachmentFilePath(storedName); ok {
+		_ = os.Remove(path)
+	}
+}
+
+// removeAttachmentFilesWithPrefix deletes the attachment files whose stored
+// names start with prefix. Stored names begin with "<userID>_<subscriptionID>_",
+// so files can be cleaned up after the rows are gone.
+func removeAttachmentFilesWithPrefix(prefix string) {
+	matches, err := filepath.Glob(filepath.Join(attachmentsDir(), prefix+"*"))
+	if err != nil {
+		return
+	}
+	for _, match := range matches {
+		removeManagedAttachmentFile(filepath.Base(match))
+	}
+}
+
+func removeSubscriptionAttachmentFiles(userID, subscriptionID uint) {
+	removeAttachmentFilesWithPrefix(fmt.Sprintf("%d_%d_", userID, subscriptionID))
+}
+
+func removeUserAttachmentFiles(userID uint) {
+	removeAttachmentFilesWithPrefix(fmt.Sprintf("%d_", userID))
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/shiroha/subdux/internal/model"
+)
+
+var testAttachmentPDF = []byte("%PDF-1.7\n1 0 obj\n<<>>\nendobj\n%%EOF\n")
+
+func TestUploadAttachmentStoresFileWithBillingPeriod(t *testing.T) {
+	t.Setenv("DATA_PATH", t.TempDir())
+	db := newTestDB(t)
+	user := createTestUser(t, db)
+	service := NewSubscriptionService(db)
+	subID := createCustomFieldTestSubscription(t, service, user.ID, nil)
+
+	attachment, err := service.UploadAttachment(user.ID, subID, bytes.NewReader(testAttachmentPDF), "../invoice 2026-10.PDF", UploadAttachmentInput{
+		PeriodStart: "2026-10-01",
+		PeriodEnd:   "2026-10-31",
+	})
+	if err != nil {
+		t.Fatalf("UploadAttachment() error = %v", err)
+	}
+	if attachment.Filename != "invoice 2026-10.PDF" || attachment.ContentType != "application/pdf" {
+		t.Fatalf("attachment = %+v, want sanitized PDF metadata", attachment)
+	}
+	if attachment.PeriodStart == nil || attachment.PeriodStart.Format("2006-01-02") != "2026-10-01" {
+		t.Fatalf("period start = %v, want 2026-10-01", attachment.PeriodStart)
+	}
+	if !strings.HasPrefix(attachment.StoredName, "1_") || !strings.HasSuffix(attachment.StoredName, ".pdf") {
+		t.Fatalf("stored name = %q", attachment.StoredName)
+	}
+
+	_, file, err := service.OpenAttachment(user.ID, subID, attachment.ID)
+	if err != nil {
+		t.Fatalf("OpenAttachment() error = %v", err)
+	}
+	contents, err := io.ReadAll(file)
+	_ = file.Close()
+	if err != nil || !bytes.Equal(contents, testAttachmentPDF) {
+		t.Fatalf("stored contents = %q, err = %v", contents, err)
+	}
+
+	other := model.User{Username: "other", Email: "other@example.com", Password: "hashed-password", Role: "user", Status: "active"}
+	if err := db.Create(&other).Error; err != nil {
+		t.Fatalf("failed to create other user: %v", err)
+	}
+	if _, _, err := service.OpenAttachment(other.ID, subID, attachment.ID); !errors.Is(err, ErrAttachmentNotFound) {
+		t.Fatalf("OpenAttachment() by another user error = %v, want %v", err, ErrAttachmentNotFound)
+	}
+
+	list, err := service.ListAttachments(user.ID, subID)
+	if err != nil {
+		t.Fatalf("ListAttachments() error = %v", err)
+	}
+	if len(list.Attachments) != 1 || list.UsedBytes != int64(len(testAttachmentPDF)) || list.QuotaBytes != defaultAttachmentQuotaPerUser {
+		t.Fatalf("list = %+v", list)
+	}
+
+	if _, err := service.UpdateAttachment(user.ID, subID, attachment.ID, UpdateAttachmentInput{PeriodEnd: strPtr("2026-09-01")}); err == nil {
+		t.Fatal("UpdateAttachment() with end before start error = nil")
+	}
+	cleared := ""
+	updated, err := service.UpdateAttachment(user.ID, subID, attachment.ID, UpdateAttachmentInput{PeriodStart: &cleared})
+	if err != nil {
+		t.Fatalf("UpdateAttachment() error = %v", err)
+	}
+	if updated.PeriodStart != nil || updated.PeriodEnd == nil {
+		t.Fatalf("updated period = %v..%v, want cleared start and kept end", updated.PeriodStart, updated.PeriodEnd)
+	}
+}
+
+func TestUploadAttachmentRejectsInvalidFilesAndQuota(t *testing.T) {
+	t.Setenv("DATA_PATH", t.TempDir())
+	db := newTestDB(t)
+	user := createTestUser(t, db)
+	service := NewSubscriptionService(db)
+	subID := createCustomFieldTestSubscription(t, service, user.ID, nil)
+
+	if _, err := service.UploadAttachment(user.ID, subID, strings.NewReader("<html></html>"), "invoice.html", UploadAttachmentInput{}); !errors.Is(err, ErrAttachmentUnsupportedType) {
+		t.Fatalf("html upload error = %v, want %v", err, ErrAttachmentUnsupportedType)
+	}
+	if _, err := service.UploadAttachment(user.ID, subID, bytes.NewReader(testAttachmentPDF), "receipt.png", UploadAttachmentInput{}); !errors.Is(err, ErrAttachmentContentMismatch) {
+		t.Fatalf("mismatched upload error = %v, want %v", err, ErrAttachmentContentMismatch)
+	}
+	if _, err := service.UploadAttachment(user.ID, subID, bytes.NewReader(testAttachmentPDF), "invoice.pdf", UploadAttachmentInput{PeriodStart: "10/01/2026"}); err == nil {
+		t.Fatal("invalid period upload error = nil")
+	}
+
+	if err := db.Create(&model.SystemSetting{Key: attachmentQuotaPerUserKey, Value: "60"}).Error; err != nil {
+		t.Fatalf("failed to set quota: %v", err)
+	}
+	if _, err := service.UploadAttachment(user.ID, subID, bytes.NewReader(testAttachmentPDF), "first.pdf", UploadAttachmentInput{}); err != nil {
+		t.Fatalf("first upload error = %v", err)
+	}
+	if _, err := service.UploadAttachment(user.ID, subID, bytes.NewReader(testAttachmentPDF), "second.pdf", UploadAttachmentInput{}); !errors.Is(err, ErrAttachmentQuotaExceeded) {
+		t.Fatalf("second upload error = %v, want %v", err, ErrAttachmentQuotaExceeded)
+	}
+}
+
+func TestDeletingSubscriptionRemovesAttachmentFiles(t *testing.T) {
+	t.Setenv("DATA_PATH", t.TempDir())
+	db := newTestDB(t)
+	user := createTestUser(t, db)
+	service := NewSubscriptionService(db)
+	subID := createCustomFieldTestSubscription(t, service, user.ID, nil)
+
+	attachment, err := service.UploadAttachment(user.ID, subID, bytes.NewReader(testAttachmentPDF), "invoice.pdf", UploadAttachmentInput{})
+	if err != nil {
+		t.Fatalf("UploadAttachment() error = %v", err)
+	}
+	path, _ := managedAttachmentFilePath(attachment.StoredName)
+
+	if err := service.Delete(user.ID, subID); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("attachment file stat error = %v, want not exist", err)
+	}
+}
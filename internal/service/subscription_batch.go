@@ -1,11 +1,311 @@
 package service
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/shiroha/subdux/internal/model"
+	"gorm.io/gorm"
 )
 
+const (
+	BulkActionUpdate      = "update"
+	BulkActionDelete      = "delete"
+	BulkActionMarkRenewed = "mark_renewed"
+
+	maxBulkSubscriptionItems = 500
+
+	bulkItemStatusOK         = "ok"
+	bulkItemStatusError      = "error"
+	bulkItemStatusRolledBack = "rolled_back"
+)
+
+var (
+	ErrBulkSubscriptionRolledBack = errors.New("bulk operation rolled back because some items failed")
+	ErrBulkSubscriptionTooMany    = fmt.Errorf("bulk selection must be at most %d subscriptions", maxBulkSubscriptionItems)
+)
+
+// BulkSubscriptionInput selects subscriptions either by ID or by filter and
+// applies one action to all of them.
+type BulkSubscriptionInput struct {
+	Action string                  `json:"action"`
+	IDs    []uint                  `json:"ids"`
+	Filter *BulkSubscriptionFilter `json:"filter"`
+	Update BulkSubscriptionUpdate  `json:"update"`
+}
+
+// BulkSubscriptionFilter matches subscriptions the same way the list and
+// search views do. At least one field must be set so a filter cannot select
+// every subscription by accident.
+type BulkSubscriptionFilter struct {
+	Query           string `json:"query"`
+	Status          string `json:"status"`
+	RenewalMode     string `json:"renewal_mode"`
+	Currency        string `json:"currency"`
+	CategoryID      *uint  `json:"category_id"`
+	PaymentMethodID *uint  `json:"payment_method_id"`
+}
+
+// BulkSubscriptionUpdate holds the fields a bulk update may change. As with
+// UpdateSubscriptionInput, an explicit null clears the category, payment
+// method or notification overrides.
+type BulkSubscriptionUpdate struct {
+	CategoryID       *uint   `json:"category_id"`
+	PaymentMethodID  *uint   `json:"payment_method_id"`
+	NotifyEnabled    *bool   `json:"notify_enabled"`
+	NotifyDaysBefore *int    `json:"notify_days_before"`
+	RenewalMode      *string `json:"renewal_mode"`
+
+	CategoryIDSet       bool `json:"-"`
+	PaymentMethodIDSet  bool `json:"-"`
+	NotifyEnabledSet    bool `json:"-"`
+	NotifyDaysBeforeSet bool `json:"-"`
+}
+
+func (input *BulkSubscriptionUpdate) UnmarshalJSON(data []byte) error {
+	type alias BulkSubscriptionUpdate
+	var decoded alias
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*input = BulkSubscriptionUpdate(decoded)
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	_, input.CategoryIDSet = raw["category_id"]
+	_, input.PaymentMethodIDSet = raw["payment_method_id"]
+	_, input.NotifyEnabledSet = raw["notify_enabled"]
+	_, input.NotifyDaysBeforeSet = raw["notify_days_before"]
+	return nil
+}
+
+func (input BulkSubscriptionUpdate) isEmpty() bool {
+	return !input.CategoryIDSet && input.CategoryID == nil &&
+		!input.PaymentMethodIDSet && input.PaymentMethodID == nil &&
+		!input.NotifyEnabledSet && input.NotifyEnabled == nil &&
+		!input.NotifyDaysBeforeSet && input.NotifyDaysBefore == nil &&
+		input.RenewalMode == nil
+}
+
+func (input BulkSubscriptionUpdate) toUpdateInput() UpdateSubscriptionInput {
+	return UpdateSubscriptionInput{
+		CategoryID:          input.CategoryID,
+		PaymentMethodID:     input.PaymentMethodID,
+		NotifyEnabled:       input.NotifyEnabled,
+		NotifyDaysBefore:    input.NotifyDaysBefore,
+		RenewalMode:         input.RenewalMode,
+		CategoryIDSet:       input.CategoryIDSet,
+		PaymentMethodIDSet:  input.PaymentMethodIDSet,
+		NotifyEnabledSet:    input.NotifyEnabledSet,
+		NotifyDaysBeforeSet: input.NotifyDaysBeforeSet,
+	}
+}
+
+type BulkSubscriptionItemResult struct {
+	ID           uint                `json:"id"`
+	Status       string              `json:"status"`
+	Error        string              `json:"error,omitempty"`
+	Subscription *model.Subscription `json:"subscription,omitempty"`
+}
+
+// BulkSubscriptionResult reports the outcome of every selected subscription.
+// When any item fails nothing is committed, and the items that would have
+// succeeded are reported as rolled_back.
+type BulkSubscriptionResult struct {
+	Action    string                       `json:"action"`
+	Committed bool                         `json:"committed"`
+	Succeeded int                          `json:"succeeded"`
+	Failed    int                          `json:"failed"`
+	Items     []BulkSubscriptionItemResult `json:"items"`
+
+	// Deleted keeps the snapshots of deleted subscriptions so their files can
+	// be cleaned up once the transaction has committed.
+	Deleted []model.Subscription `json:"-"`
+}
+
+// ApplyBulk runs a bulk action and, once it has committed, removes the files
+// of any deleted subscriptions.
+func (s *SubscriptionService) ApplyBulk(userID uint, input BulkSubscriptionInput) (*BulkSubscriptionResult, error) {
+	result, err := s.ApplyBulkRecords(userID, input)
+	if err != nil {
+		return result, err
+	}
+	for _, deleted := range result.Deleted {
+		s.CleanupDeletedSubscriptionResources(deleted)
+	}
+	return result, nil
+}
+
+// ApplyBulkRecords applies a bulk action in a single transaction without
+// touching filesystem resources. Each item goes through the same service
+// method as its single-subscription counterpart, so every change records its
+// own subscription event. If any item fails the whole batch is rolled back
+// and ErrBulkSubscriptionRolledBack is returned together with the per-item
+// results.
+func (s *SubscriptionService) ApplyBulkRecords(userID uint, input BulkSubscriptionInput) (*BulkSubscriptionResult, error) {
+	action := strings.TrimSpace(input.Action)
+	switch action {
+	case BulkActionUpdate:
+		if input.Update.isEmpty() {
+			return nil, errors.New("at least one update field is required")
+		}
+	case BulkActionDelete, BulkActionMarkRenewed:
+	default:
+		return nil, errors.New("action must be update, delete, or mark_renewed")
+	}
+
+	ids, err := s.resolveBulkSubscriptionIDs(userID, input)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &BulkSubscriptionResult{
+		Action: action,
+		Items:  make([]BulkSubscriptionItemResult, 0, len(ids)),
+	}
+	txErr := s.DB.Transaction(func(tx *gorm.DB) error {
+		txService := &SubscriptionService{DB: tx}
+		for _, id := range ids {
+			item := BulkSubscriptionItemResult{ID: id, Status: bulkItemStatusOK}
+			sub, err := txService.applyBulkItem(userID, id, action, input.Update)
+			if err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					err = errors.New("subscription not found")
+				}
+				item.Status = bulkItemStatusError
+				item.Error = err.Error()
+				result.Failed++
+			} else if action == BulkActionDelete {
+				result.Deleted = append(result.Deleted, *sub)
+			} else {
+				item.Subscription = sub
+			}
+			result.Items = append(result.Items, item)
+		}
+		if result.Failed > 0 {
+			return ErrBulkSubscriptionRolledBack
+		}
+		return nil
+	})
+
+	if txErr != nil {
+		result.Deleted = nil
+		for i := range result.Items {
+			if result.Items[i].Status == bulkItemStatusOK {
+				result.Items[i].Status = bulkItemStatusRolledBack
+				result.Items[i].Subscription = nil
+			}
+		}
+		if errors.Is(txErr, ErrBulkSubscriptionRolledBack) {
+			return result, ErrBulkSubscriptionRolledBack
+		}
+		return nil, txErr
+	}
+
+	result.Committed = true
+	result.Succeeded = len(result.Items)
+	return result, nil
+}
+
+func (s *SubscriptionService) applyBulkItem(userID, id uint, action string, update BulkSubscriptionUpdate) (*model.Subscription, error) {
+	switch action {
+	case BulkActionUpdate:
+		return s.Update(userID, id, update.toUpdateInput())
+	case BulkActionDelete:
+		return s.DeleteRecord(userID, id)
+	default:
+		return s.MarkManualRenewed(userID, id)
+	}
+}
+
+// resolveBulkSubscriptionIDs returns the de-duplicated IDs a bulk action
+// targets. Explicit IDs are kept as given so unknown ones surface as item
+// errors; a filter is matched against the user's current subscriptions.
+func (s *SubscriptionService) resolveBulkSubscriptionIDs(userID uint, input BulkSubscriptionInput) ([]uint, error) {
+	if (len(input.IDs) > 0) == (input.Filter != nil) {
+		return nil, errors.New("exactly one of ids or filter is required")
+	}
+
+	var ids []uint
+	if input.Filter != nil {
+		matched, err := s.filterBulkSubscriptions(userID, *input.Filter)
+		if err != nil {
+			return nil, err
+		}
+		for _, sub := range matched {
+			ids = append(ids, sub.ID)
+		}
+	} else {
+		seen := make(map[uint]bool, len(input.IDs))
+		for _, id := range input.IDs {
+			if id == 0 {
+				return nil, errors.New("ids must be positive integers")
+			}
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+
+	if len(ids) > maxBulkSubscriptionItems {
+		return nil, ErrBulkSubscriptionTooMany
+	}
+	return ids, nil
+}
+
+func (s *SubscriptionService) filterBulkSubscriptions(userID uint, filter BulkSubscriptionFilter) ([]model.Subscription, error) {
+	filter.Query = strings.TrimSpace(filter.Query)
+	filter.Status = strings.TrimSpace(filter.Status)
+	filter.RenewalMode = strings.TrimSpace(filter.RenewalMode)
+	filter.Currency = strings.ToUpper(strings.TrimSpace(filter.Currency))
+	if filter.Query == "" && filter.Status == "" && filter.RenewalMode == "" &&
+		filter.Currency == "" && filter.CategoryID == nil && filter.PaymentMethodID == nil {
+		return nil, errors.New("at least one filter field is required")
+	}
+	switch filter.Status {
+	case "", subscriptionStatusActive, subscriptionStatusEnded:
+	default:
+		return nil, errors.New("filter status must be active or ended")
+	}
+	switch filter.RenewalMode {
+	case "", renewalModeAutoRenew, renewalModeManualRenew, renewalModeCancelAtPeriodEnd:
+	default:
+		return nil, errors.New("filter renewal_mode must be auto_renew, manual_renew, or cancel_at_period_end")
+	}
+
+	subs, err := s.Search(userID, filter.Query)
+	if err != nil {
+		return nil, err
+	}
+	matched := make([]model.Subscription, 0, len(subs))
+	for _, sub := range subs {
+		if filter.Status != "" && normalizeStatus(sub.Status) != filter.Status {
+			continue
+		}
+		if filter.RenewalMode != "" && normalizeRenewalMode(sub.RenewalMode) != filter.RenewalMode {
+			continue
+		}
+		if filter.Currency != "" && !strings.EqualFold(sub.Currency, filter.Currency) {
+			continue
+		}
+		if filter.CategoryID != nil && (sub.CategoryID == nil || *sub.CategoryID != *filter.CategoryID) {
+			continue
+		}
+		if filter.PaymentMethodID != nil && (sub.PaymentMethodID == nil || *sub.PaymentMethodID != *filter.PaymentMethodID) {
+			continue
+		}
+		matched = append(matched, sub)
+	}
+	return matched, nil
+}
+
 // loadSubscriptionsByIDs loads the given user's subscriptions keyed by ID,
 // advancing each one's lifecycle in memory as of referenceDate (a pure-read
 // presentation, no writes) and normalizing legacy fields. It collapses what
@@ -1,6 +1,8 @@
 package service
 
 import (
+	"encoding/json"
+	"errors"
 	"testing"
 	"time"
 
@@ -47,7 +49,7 @@ func seedPriceIncreaseFixtures(t *testing.T, service *SubscriptionService, db *g
 		failedAt := time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
 		if err := db.Create(&model.NotificationLog{
 			UserID:         userID,
-			SubscriptionID: sub.ID,
+			SubscriptionID: &sub.ID,
 			ChannelType:    "webhook",
 			NotifyDate:     mustDate(t, "2026-03-04"),
 			Status:         notificationLogStatusFailed,
@@ -130,3 +132,113 @@ func TestActionCenterReadPathsHaveConstantQueryCount(t *testing.T) {
 		})
 	}
 }
+
+func TestApplyBulkUpdatesSubscriptionsAndRecordsEvents(t *testing.T) {
+	db := newTestDB(t)
+	user := createTestUser(t, db)
+	service := NewSubscriptionService(db)
+	firstID := createCustomFieldTestSubscription(t, service, user.ID, nil)
+	secondID := createCustomFieldTestSubscription(t, service, user.ID, nil)
+
+	var input BulkSubscriptionInput
+	body := `{"action":"update","ids":[1,2,1],"update":{"notify_enabled":false,"notify_days_before":null,"renewal_mode":"manual_renew"}}`
+	if err := json.Unmarshal([]byte(body), &input); err != nil {
+		t.Fatalf("unmarshal bulk input failed: %v", err)
+	}
+	if !input.Update.NotifyDaysBeforeSet || input.Update.CategoryIDSet {
+		t.Fatalf("update set flags = %+v", input.Update)
+	}
+
+	result, err := service.ApplyBulk(user.ID, input)
+	if err != nil {
+		t.Fatalf("ApplyBulk() error = %v", err)
+	}
+	if !result.Committed || result.Succeeded != 2 || len(result.Items) != 2 {
+		t.Fatalf("result = %+v, want two committed items", result)
+	}
+	for _, id := range []uint{firstID, secondID} {
+		sub, err := service.GetByID(user.ID, id)
+		if err != nil {
+			t.Fatalf("GetByID() error = %v", err)
+		}
+		if sub.RenewalMode != renewalModeManualRenew || sub.NotifyEnabled == nil || *sub.NotifyEnabled {
+			t.Fatalf("subscription %d = %+v, want manual_renew without notifications", id, sub)
+		}
+	}
+
+	var events int64
+	if err := db.Model(&model.SubscriptionEvent{}).Where("user_id = ? AND type = ?", user.ID, subscriptionEventUpdated).Count(&events).Error; err != nil {
+		t.Fatalf("count events failed: %v", err)
+	}
+	if events != 2 {
+		t.Fatalf("updated events = %d, want 2", events)
+	}
+}
+
+func TestApplyBulkRollsBackWhenAnyItemFails(t *testing.T) {
+	db := newTestDB(t)
+	user := createTestUser(t, db)
+	service := NewSubscriptionService(db)
+	autoID := createCustomFieldTestSubscription(t, service, user.ID, nil)
+	manualID := createCustomFieldTestSubscription(t, service, user.ID, nil)
+	manual := renewalModeManualRenew
+	if _, err := service.Update(user.ID, manualID, UpdateSubscriptionInput{RenewalMode: &manual}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	result, err := service.ApplyBulk(user.ID, BulkSubscriptionInput{Action: BulkActionMarkRenewed, IDs: []uint{manualID, autoID}})
+	if !errors.Is(err, ErrBulkSubscriptionRolledBack) {
+		t.Fatalf("ApplyBulk() error = %v, want %v", err, ErrBulkSubscriptionRolledBack)
+	}
+	if result.Committed || result.Failed != 1 || result.Items[0].Status != bulkItemStatusRolledBack || result.Items[1].Status != bulkItemStatusError {
+		t.Fatalf("result = %+v, want rolled back batch with one failed item", result)
+	}
+
+	sub, err := service.GetByID(user.ID, manualID)
+	if err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+	if sub.NextBillingDate.Format("2006-01-02") != "2026-11-01" {
+		t.Fatalf("next billing date = %s, want unchanged 2026-11-01", sub.NextBillingDate.Format("2006-01-02"))
+	}
+	var renewed int64
+	if err := db.Model(&model.SubscriptionEvent{}).Where("type = ?", subscriptionEventManualRenewed).Count(&renewed).Error; err != nil {
+		t.Fatalf("count events failed: %v", err)
+	}
+	if renewed != 0 {
+		t.Fatalf("manual_renewed events = %d, want 0 after rollback", renewed)
+	}
+}
+
+func TestApplyBulkSelectsByFilter(t *testing.T) {
+	db := newTestDB(t)
+	user := createTestUser(t, db)
+	service := NewSubscriptionService(db)
+	keptID := createCustomFieldTestSubscription(t, service, user.ID, nil)
+	deletedID := createCustomFieldTestSubscription(t, service, user.ID, nil)
+	notes := "cancel me"
+	if _, err := service.Update(user.ID, deletedID, UpdateSubscriptionInput{Notes: &notes}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	if _, err := service.ApplyBulk(user.ID, BulkSubscriptionInput{Action: BulkActionDelete, Filter: &BulkSubscriptionFilter{}}); err == nil {
+		t.Fatal("ApplyBulk() with empty filter error = nil")
+	}
+	if _, err := service.ApplyBulk(user.ID, BulkSubscriptionInput{Action: BulkActionDelete, IDs: []uint{keptID}, Filter: &BulkSubscriptionFilter{Query: "cancel"}}); err == nil {
+		t.Fatal("ApplyBulk() with ids and filter error = nil")
+	}
+
+	result, err := service.ApplyBulk(user.ID, BulkSubscriptionInput{Action: BulkActionDelete, Filter: &BulkSubscriptionFilter{Query: "CANCEL", Currency: "usd"}})
+	if err != nil {
+		t.Fatalf("ApplyBulk() error = %v", err)
+	}
+	if len(result.Items) != 1 || result.Items[0].ID != deletedID {
+		t.Fatalf("items = %+v, want only subscription %d", result.Items, deletedID)
+	}
+	if _, err := service.GetByID(user.ID, keptID); err != nil {
+		t.Fatalf("kept subscription GetByID() error = %v", err)
+	}
+	if _, err := service.GetByID(user.ID, deletedID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("deleted subscription GetByID() error = %v, want not found", err)
+	}
+}
@@ -6,12 +6,12 @@ import (
 	"time"
 
 	"github.com/shiroha/subdux/internal/model"
-	"github.com/shiroha/subdux/internal/pkg"
+	"github.com/shiroha/subdux/internal/pkg/money"
 	"gorm.io/gorm"
 )
 
 func (s *SubscriptionService) List(userID uint) ([]model.Subscription, error) {
-	now := pkg.NowInSystemTimezone()
+	now := userNow(s.DB, userID)
 
 	var subs []model.Subscription
 	err := s.DB.Where("user_id = ?", userID).
@@ -26,6 +26,9 @@ func (s *SubscriptionService) List(userID uint) ([]model.Subscription, error) {
 	for i := range subs {
 		presentSubscriptionForResponse(&subs[i], now)
 	}
+	if err := loadSubscriptionCustomFields(s.DB, userID, subs, customFieldSecretsMask); err != nil {
+		return nil, err
+	}
 	return subs, err
 }
 
@@ -33,7 +36,8 @@ func (s *SubscriptionService) GetByID(userID, id uint) (*model.Subscription, err
 	var sub model.Subscription
 	err := s.DB.Where("id = ? AND user_id = ?", id, userID).First(&sub).Error
 	if err == nil {
-		presentSubscriptionForResponse(&sub, pkg.NowInSystemTimezone())
+		presentSubscriptionForResponse(&sub, userNow(s.DB, userID))
+		err = loadCustomFieldsForSubscription(s.DB, &sub, customFieldSecretsMask)
 	}
 	return &sub, err
 }
@@ -76,7 +80,7 @@ func (s *SubscriptionService) Create(userID uint, input CreateSubscriptionInput)
 		Status:      input.Status,
 		RenewalMode: input.RenewalMode,
 		EndsAt:      endsAt,
-	}, nextBillingDate, pkg.NowInSystemTimezone())
+	}, nextBillingDate, userNow(s.DB, userID))
 	if err != nil {
 		return nil, err
 	}
@@ -101,12 +105,33 @@ func (s *SubscriptionService) Create(userID uint, input CreateSubscriptionInput)
 			return nil, err
 		}
 	}
+	if err := validateSubscriptionTaxRate(input.TaxRate); err != nil {
+		return nil, err
+	}
+	if err := validateSubscriptionFee("fx_fee", input.FXFee); err != nil {
+		return nil, err
+	}
+	if err := validateSubscriptionFee("processor_fee", input.ProcessorFee); err != nil {
+		return nil, err
+	}
+	if err := validateAnnualPlanAmount(input.AnnualPlanAmount); err != nil {
+		return nil, err
+	}
+	customFieldWrites, err := prepareCustomFieldValues(s.DB, userID, input.CustomFields)
+	if err != nil {
+		return nil, err
+	}
 
 	sub := model.Subscription{
 		UserID:           userID,
 		Name:             input.Name,
 		Amount:           input.Amount,
 		Currency:         currency,
+		TaxRate:          input.TaxRate,
+		TaxInclusive:     input.TaxInclusive,
+		FXFee:            input.FXFee,
+		ProcessorFee:     input.ProcessorFee,
+		AnnualPlanAmount: input.AnnualPlanAmount,
 		Status:           lifecycle.Status,
 		RenewalMode:      lifecycle.RenewalMode,
 		EndsAt:           copyTimePointer(lifecycle.EndsAt),
@@ -133,10 +158,16 @@ func (s *SubscriptionService) Create(userID uint, input CreateSubscriptionInput)
 		if err := tx.Create(&sub).Error; err != nil {
 			return err
 		}
+		if err := applyCustomFieldValues(tx, userID, sub.ID, customFieldWrites); err != nil {
+			return err
+		}
 		return (&SubscriptionService{DB: tx}).recordSubscriptionCreated(userID, sub)
 	}); err != nil {
 		return nil, err
 	}
+	if err := loadCustomFieldsForSubscription(s.DB, &sub, customFieldSecretsMask); err != nil {
+		return nil, err
+	}
 
 	return &sub, nil
 }
@@ -145,7 +176,7 @@ func (s *SubscriptionService) Update(userID, id uint, input UpdateSubscriptionIn
 	// A mutation must act on a row whose lifecycle is current: read paths only
 	// advance state in memory, so persist any due transition for this
 	// subscription before computing the update.
-	if err := reconcileSubscriptionForWrite(s.DB, userID, id, pkg.NowInSystemTimezone()); err != nil {
+	if err := reconcileSubscriptionForWrite(s.DB, userID, id, userNow(s.DB, userID)); err != nil {
 		return nil, err
 	}
 
@@ -159,12 +190,75 @@ func (s *SubscriptionService) Update(userID, id uint, input UpdateSubscriptionIn
 	if input.Name != nil {
 		updates["name"] = *input.Name
 	}
-	if input.Amount != nil {
-		updates["amount"] = *input.Amount
-	}
 	if input.Currency != nil {
 		updates["currency"] = strings.TrimSpace(*input.Currency)
 	}
+	if input.Amount != nil || input.Currency != nil {
+		// Map updates skip the model's BeforeSave hook, so convert to the
+		// stored minor units here.
+		amount, currency := sub.Amount, sub.Currency
+		if input.Amount != nil {
+			amount = *input.Amount
+		}
+		if input.Currency != nil {
+			currency = strings.TrimSpace(*input.Currency)
+		}
+		updates["amount_minor"] = money.ToMinorUnits(amount, currency)
+	}
+	if input.TaxRateSet || input.TaxRate != nil {
+		if err := validateSubscriptionTaxRate(input.TaxRate); err != nil {
+			return nil, err
+		}
+		if input.TaxRate == nil {
+			updates["tax_rate"] = nil
+		} else {
+			updates["tax_rate"] = *input.TaxRate
+		}
+	}
+	if input.TaxInclusive != nil {
+		updates["tax_inclusive"] = *input.TaxInclusive
+	}
+	if input.FXFee != nil || input.ProcessorFee != nil || input.Currency != nil {
+		// Fees are stored in minor units of the subscription currency, so
+		// convert them again when either the fee or the currency changes.
+		currency := sub.Currency
+		if input.Currency != nil {
+			currency = strings.TrimSpace(*input.Currency)
+		}
+		fxFee, processorFee := sub.FXFee, sub.ProcessorFee
+		if input.FXFee != nil {
+			if err := validateSubscriptionFee("fx_fee", *input.FXFee); err != nil {
+				return nil, err
+			}
+			fxFee = *input.FXFee
+		}
+		if input.ProcessorFee != nil {
+			if err := validateSubscriptionFee("processor_fee", *input.ProcessorFee); err != nil {
+				return nil, err
+			}
+			processorFee = *input.ProcessorFee
+		}
+		updates["fx_fee_minor"] = money.ToMinorUnits(fxFee, currency)
+		updates["processor_fee_minor"] = money.ToMinorUnits(processorFee, currency)
+	}
+	if input.AnnualPlanAmountSet || input.AnnualPlanAmount != nil || input.Currency != nil {
+		annualPlanAmount := sub.AnnualPlanAmount
+		if input.AnnualPlanAmountSet || input.AnnualPlanAmount != nil {
+			if err := validateAnnualPlanAmount(input.AnnualPlanAmount); err != nil {
+				return nil, err
+			}
+			annualPlanAmount = input.AnnualPlanAmount
+		}
+		if annualPlanAmount == nil {
+			updates["annual_plan_amount_minor"] = nil
+		} else {
+			currency := sub.Currency
+			if input.Currency != nil {
+				currency = strings.TrimSpace(*input.Currency)
+			}
+			updates["annual_plan_amount_minor"] = money.ToMinorUnits(*annualPlanAmount, currency)
+		}
+	}
 	if input.Category != nil {
 		updates["category"] = *input.Category
 	}
@@ -311,7 +405,7 @@ func (s *SubscriptionService) Update(userID, id uint, input UpdateSubscriptionIn
 		normalizedLifecycle, err := normalizeLifecycleDraft(
 			lifecycle,
 			nextBillingDate,
-			pkg.NowInSystemTimezone(),
+			userNow(s.DB, userID),
 		)
 		if err != nil {
 			return nil, err
@@ -323,9 +417,19 @@ func (s *SubscriptionService) Update(userID, id uint, input UpdateSubscriptionIn
 		updates["enabled"] = normalizedLifecycle.Status == subscriptionStatusActive
 	}
 
+	customFieldWrites, err := prepareCustomFieldValues(s.DB, userID, input.CustomFields)
+	if err != nil {
+		return nil, err
+	}
+
 	var updated model.Subscription
 	if err := s.DB.Transaction(func(tx *gorm.DB) error {
-		if err := tx.Model(&model.Subscription{}).Where("id = ? AND user_id = ?", id, userID).Updates(updates).Error; err != nil {
+		if len(updates) > 0 {
+			if err := tx.Model(&model.Subscription{}).Where("id = ? AND user_id = ?", id, userID).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		if err := applyCustomFieldValues(tx, userID, id, customFieldWrites); err != nil {
 			return err
 		}
 		if err := tx.Where("id = ? AND user_id = ?", id, userID).First(&updated).Error; err != nil {
@@ -336,6 +440,9 @@ func (s *SubscriptionService) Update(userID, id uint, input UpdateSubscriptionIn
 	}); err != nil {
 		return nil, err
 	}
+	if err := loadCustomFieldsForSubscription(s.DB, &updated, customFieldSecretsMask); err != nil {
+		return nil, err
+	}
 
 	return &updated, nil
 }
@@ -376,7 +483,7 @@ func (s *SubscriptionService) Delete(userID, id uint) error {
 // DeleteRecord removes the subscription database record and returns the deleted
 // snapshot without touching filesystem resources.
 func (s *SubscriptionService) DeleteRecord(userID, id uint) (*model.Subscription, error) {
-	if err := reconcileSubscriptionForWrite(s.DB, userID, id, pkg.NowInSystemTimezone()); err != nil {
+	if err := reconcileSubscriptionForWrite(s.DB, userID, id, userNow(s.DB, userID)); err != nil {
 		return nil, err
 	}
 
@@ -401,6 +508,7 @@ func (s *SubscriptionService) DeleteRecord(userID, id uint) (*model.Subscription
 // only be cleaned after the deleting database transaction has committed.
 func (s *SubscriptionService) CleanupDeletedSubscriptionResources(sub model.Subscription) {
 	s.removeManagedIconFile(sub.Icon)
+	removeSubscriptionAttachmentFiles(sub.UserID, sub.ID)
 }
 
 func copyIntPointer(value *int) *int {
@@ -0,0 +1,298 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/shiroha/subdux/internal/model"
+	"github.com/shiroha/subdux/internal/pkg"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	maxCustomFieldValueLength = 1000
+
+	// CustomFieldSecretMask stands in for a stored secret in responses.
+	// Sending it back unchanged keeps the stored value.
+	CustomFieldSecretMask = "********"
+
+	// customFieldTemplatePrefix prefixes a field key to form its notification
+	// template placeholder, e.g. {{.Custom_contract_id}}.
+	customFieldTemplatePrefix = "Custom_"
+)
+
+// customFieldSecrets selects how secret values are returned when loading
+// custom fields.
+type customFieldSecrets int
+
+const (
+	customFieldSecretsMask customFieldSecrets = iota
+	customFieldSecretsReveal
+	customFieldSecretsOmit
+)
+
+// customFieldWrite is one validated change to a subscription's custom field.
+// An empty value removes the stored value; keep leaves it untouched.
+type customFieldWrite struct {
+	fieldID uint
+	value   string
+	keep    bool
+}
+
+type subscriptionCustomFieldRow struct {
+	SubscriptionID uint
+	FieldKey       string
+	Type           string
+	Value          string
+}
+
+// prepareCustomFieldValues validates values keyed by field key against the
+// user's definitions and converts them to their stored form.
+func prepareCustomFieldValues(db *gorm.DB, userID uint, values map[string]string) ([]customFieldWrite, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	var fields []model.CustomFieldDefinition
+	if err := db.Where("user_id = ?", userID).Find(&fields).Error; err != nil {
+		return nil, err
+	}
+	byKey := make(map[string]model.CustomFieldDefinition, len(fields))
+	for _, field := range fields {
+		byKey[field.Key] = field
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	writes := make([]customFieldWrite, 0, len(values))
+	for _, key := range keys {
+		field, ok := byKey[key]
+		if !ok {
+			return nil, fmt.Errorf("custom_fields.%s must be a defined custom field key", key)
+		}
+		raw := values[key]
+		if field.Type == customFieldTypeSecret && raw == CustomFieldSecretMask {
+			writes = append(writes, customFieldWrite{fieldID: field.ID, keep: true})
+			continue
+		}
+		value, err := normalizeCustomFieldValue(field, raw)
+		if err != nil {
+			return nil, err
+		}
+		writes = append(writes, customFieldWrite{fieldID: field.ID, value: value})
+	}
+	return writes, nil
+}
+
+// normalizeCustomFieldValue checks raw against the field type and returns the
+// value to store. Secret values come back encrypted.
+func normalizeCustomFieldValue(field model.CustomFieldDefinition, raw string) (string, error) {
+	value := strings.TrimSpace(raw)
+	if value == "" {
+		return "", nil
+	}
+	if utf8.RuneCountInString(value) > maxCustomFieldValueLength {
+		return "", fmt.Errorf("custom_fields.%s must be at most %d characters", field.Key, maxCustomFieldValueLength)
+	}
+
+	switch field.Type {
+	case customFieldTypeNumber:
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(number) || math.IsInf(number, 0) {
+			return "", fmt.Errorf("custom_fields.%s must be a number", field.Key)
+		}
+		return strconv.FormatFloat(number, 'f', -1, 64), nil
+	case customFieldTypeDate:
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			return "", fmt.Errorf("custom_fields.%s must be a date in YYYY-MM-DD format", field.Key)
+		}
+		return parsed.Format("2006-01-02"), nil
+	case customFieldTypeSelect:
+		if !slices.Contains(DecodeCustomFieldOptions(field), value) {
+			return "", fmt.Errorf("custom_fields.%s must be one of the field's options", field.Key)
+		}
+		return value, nil
+	case customFieldTypeURL:
+		normalized, err := normalizeSubscriptionURL(value)
+		if err != nil {
+			return "", fmt.Errorf("custom_fields.%s must be a valid http or https URL", field.Key)
+		}
+		return normalized, nil
+	case customFieldTypeSecret:
+		return pkg.EncryptCustomFieldSecret(value)
+	default:
+		return value, nil
+	}
+}
+
+func applyCustomFieldValues(tx *gorm.DB, userID, subscriptionID uint, writes []customFieldWrite) error {
+	for _, write := range writes {
+		if write.keep {
+			continue
+		}
+		if write.value == "" {
+			if err := tx.Where("user_id = ? AND subscription_id = ? AND field_id = ?", userID, subscriptionID, write.fieldID).
+				Delete(&model.SubscriptionCustomFieldValue{}).Error; err != nil {
+				return err
+			}
+			continue
+		}
+		value := model.SubscriptionCustomFieldValue{
+			UserID:         userID,
+			SubscriptionID: subscriptionID,
+			FieldID:        write.fieldID,
+			Value:          write.value,
+		}
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "subscription_id"}, {Name: "field_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"value", "updated_at"}),
+		}).Create(&value).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadSubscriptionCustomFields fills CustomFields on each of subs, which must
+// all belong to userID.
+func loadSubscriptionCustomFields(db *gorm.DB, userID uint, subs []model.Subscription, secrets customFieldSecrets) error {
+	if len(subs) == 0 {
+		return nil
+	}
+	query := db.Table("subscription_custom_field_values AS v").
+		Select("v.subscription_id, d.field_key, d.type, v.value").
+		Joins("JOIN custom_field_definitions AS d ON d.id = v.field_id").
+		Where("v.user_id = ?", userID)
+	if len(subs) == 1 {
+		query = query.Where("v.subscription_id = ?", subs[0].ID)
+	}
+	var rows []subscriptionCustomFieldRow
+	if err := query.Scan(&rows).Error; err != nil {
+		return err
+	}
+
+	bySubscription := make(map[uint]map[string]string)
+	for _, row := range rows {
+		value := row.Value
+		if row.Type == customFieldTypeSecret {
+			switch secrets {
+			case customFieldSecretsOmit:
+				continue
+			case customFieldSecretsMask:
+				value = CustomFieldSecretMask
+			default:
+				decrypted, err := pkg.DecryptCustomFieldSecret(value)
+				if err != nil {
+					return err
+				}
+				value = decrypted
+			}
+		}
+		if bySubscription[row.SubscriptionID] == nil {
+			bySubscription[row.SubscriptionID] = make(map[string]string)
+		}
+		bySubscription[row.SubscriptionID][row.FieldKey] = value
+	}
+	for i := range subs {
+		subs[i].CustomFields = bySubscription[subs[i].ID]
+	}
+	return nil
+}
+
+func loadCustomFieldsForSubscription(db *gorm.DB, sub *model.Subscription, secrets customFieldSecrets) error {
+	subs := []model.Subscription{*sub}
+	if err := loadSubscriptionCustomFields(db, sub.UserID, subs, secrets); err != nil {
+		return err
+	}
+	sub.CustomFields = subs[0].CustomFields
+	return nil
+}
+
+// RevealCustomFieldSecret returns the plaintext of one secret custom field of
+// a subscription.
+func (s *SubscriptionService) RevealCustomFieldSecret(userID, subscriptionID uint, key string) (string, error) {
+	var field model.CustomFieldDefinition
+	if err := s.DB.Where("user_id = ? AND field_key = ? AND type = ?", userID, key, customFieldTypeSecret).
+		First(&field).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", ErrCustomFieldNotFound
+		}
+		return "", err
+	}
+	var value model.SubscriptionCustomFieldValue
+	if err := s.DB.Where("user_id = ? AND subscription_id = ? AND field_id = ?", userID, subscriptionID, field.ID).
+		First(&value).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", nil
+		}
+		return "", err
+	}
+	return pkg.DecryptCustomFieldSecret(value.Value)
+}
+
+// Search lists the subscriptions whose name, category, URL, notes or custom
+// field values contain query, ignoring case. Secret values are not searched.
+func (s *SubscriptionService) Search(userID uint, query string) ([]model.Subscription, error) {
+	subs, err := s.List(userID)
+	if err != nil {
+		return nil, err
+	}
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" {
+		return subs, nil
+	}
+	categoryLabels, err := s.reportCategoryLabels(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := make([]model.Subscription, 0, len(subs))
+	for _, sub := range subs {
+		_, categoryLabel := reportCategoryKeyAndLabel(sub, categoryLabels)
+		text := strings.ToLower(strings.Join([]string{
+			sub.Name,
+			sub.Category,
+			categoryLabel,
+			sub.URL,
+			sub.Notes,
+			SubscriptionCustomFieldSearchText(sub),
+		}, " "))
+		if strings.Contains(text, query) {
+			matches = append(matches, sub)
+		}
+	}
+	return matches, nil
+}
+
+// SubscriptionCustomFieldSearchText joins the searchable custom field values
+// of a loaded subscription, leaving out masked secrets.
+func SubscriptionCustomFieldSearchText(sub model.Subscription) string {
+	values := make([]string, 0, len(sub.CustomFields))
+	for _, value := range sub.CustomFields {
+		if value != CustomFieldSecretMask {
+			values = append(values, value)
+		}
+	}
+	slices.Sort(values)
+	return strings.Join(values, " ")
+}
+
+// customFieldTemplateVariables returns the custom field values of sub that
+// notification templates may use. Secrets are never exposed to templates.
+func customFieldTemplateVariables(db *gorm.DB, sub model.Subscription) map[string]string {
+	if err := loadCustomFieldsForSubscription(db, &sub, customFieldSecretsOmit); err != nil {
+		return nil
+	}
+	return sub.CustomFields
+}
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func createCustomFieldTestSubscription(t *testing.T, service *SubscriptionService, userID uint, fields map[string]string) uint {
+	t.Helper()
+
+	monthly := 1
+	sub, err := service.Create(userID, CreateSubscriptionInput{
+		Name:            "Hosting",
+		Amount:          20,
+		Currency:        "USD",
+		Status:          subscriptionStatusActive,
+		RenewalMode:     renewalModeAutoRenew,
+		BillingType:     billingTypeRecurring,
+		RecurrenceType:  recurrenceTypeInterval,
+		IntervalCount:   &monthly,
+		IntervalUnit:    intervalUnitMonth,
+		NextBillingDate: "2026-11-01",
+		CustomFields:    fields,
+	})
+	if err != nil {
+		t.Fatalf("create subscription failed: %v", err)
+	}
+	return sub.ID
+}
+
+func TestCustomFieldDefinitionValidation(t *testing.T) {
+	db := newTestDB(t)
+	user := createTestUser(t, db)
+	fields := NewCustomFieldService(db)
+
+	if _, err := fields.Create(user.ID, CreateCustomFieldInput{Key: "Contract ID", Name: "Contract", Type: "text"}); !errors.Is(err, ErrCustomFieldInvalidKey) {
+		t.Fatalf("Create() invalid key error = %v, want %v", err, ErrCustomFieldInvalidKey)
+	}
+	if _, err := fields.Create(user.ID, CreateCustomFieldInput{Key: "plan", Name: "Plan", Type: "select"}); !errors.Is(err, ErrCustomFieldInvalidOption) {
+		t.Fatalf("Create() select without options error = %v, want %v", err, ErrCustomFieldInvalidOption)
+	}
+	if _, err := fields.Create(user.ID, CreateCustomFieldInput{Key: "plan", Name: "Plan", Type: "color"}); !errors.Is(err, ErrCustomFieldInvalidType) {
+		t.Fatalf("Create() invalid type error = %v, want %v", err, ErrCustomFieldInvalidType)
+	}
+
+	created, err := fields.Create(user.ID, CreateCustomFieldInput{Key: "plan", Name: "Plan", Type: "select", Options: []string{"Basic", "Pro"}})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if got := DecodeCustomFieldOptions(*created); len(got) != 2 || got[1] != "Pro" {
+		t.Fatalf("options = %v, want [Basic Pro]", got)
+	}
+	if _, err := fields.Create(user.ID, CreateCustomFieldInput{Key: "plan", Name: "Plan again", Type: "text"}); !errors.Is(err, ErrCustomFieldKeyExists) {
+		t.Fatalf("Create() duplicate key error = %v, want %v", err, ErrCustomFieldKeyExists)
+	}
+}
+
+func TestSubscriptionCustomFieldValuesAreValidatedAndSearchable(t *testing.T) {
+	db := newTestDB(t)
+	user := createTestUser(t, db)
+	fields := NewCustomFieldService(db)
+	service := NewSubscriptionService(db)
+
+	for _, input := range []CreateCustomFieldInput{
+		{Key: "contract_id", Name: "Contract ID", Type: "text"},
+		{Key: "seats", Name: "Seats", Type: "number"},
+		{Key: "signed_on", Name: "Signed on", Type: "date"},
+	} {
+		if _, err := fields.Create(user.ID, input); err != nil {
+			t.Fatalf("Create(%s) error = %v", input.Key, err)
+		}
+	}
+
+	invalid := []map[string]string{
+		{"seats": "many"},
+		{"signed_on": "11/01/2026"},
+		{"unknown": "value"},
+	}
+	monthly := 1
+	for _, values := range invalid {
+		_, err := service.Create(user.ID, CreateSubscriptionInput{
+			Name:            "Invalid",
+			Amount:          1,
+			Currency:        "USD",
+			BillingType:     billingTypeRecurring,
+			RecurrenceType:  recurrenceTypeInterval,
+			IntervalCount:   &monthly,
+			IntervalUnit:    intervalUnitMonth,
+			NextBillingDate: "2026-11-01",
+			CustomFields:    values,
+		})
+		if err == nil || !strings.Contains(err.Error(), "custom_fields.") {
+			t.Fatalf("Create(%v) error = %v, want custom field validation error", values, err)
+		}
+	}
+
+	subID := createCustomFieldTestSubscription(t, service, user.ID, map[string]string{
+		"contract_id": "ACME-4711",
+		"seats":       "05",
+	})
+	sub, err := service.GetByID(user.ID, subID)
+	if err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+	if sub.CustomFields["contract_id"] != "ACME-4711" || sub.CustomFields["seats"] != "5" {
+		t.Fatalf("custom fields = %v, want contract_id and normalized seats", sub.CustomFields)
+	}
+
+	matches, err := service.Search(user.ID, "acme-47")
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	if len(matches) != 1 || matches[0].ID != subID {
+		t.Fatalf("Search() = %+v, want subscription %d", matches, subID)
+	}
+
+	cleared, err := service.Update(user.ID, subID, UpdateSubscriptionInput{CustomFields: map[string]string{"seats": ""}})
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if _, ok := cleared.CustomFields["seats"]; ok || cleared.CustomFields["contract_id"] != "ACME-4711" {
+		t.Fatalf("custom fields after clearing seats = %v", cleared.CustomFields)
+	}
+}
+
+func TestSubscriptionCustomFieldSecretsAreMasked(t *testing.T) {
+	t.Setenv("SETTINGS_ENCRYPTION_KEY", "custom-field-test-key-0123456789abcdef")
+
+	db := newTestDB(t)
+	user := createTestUser(t, db)
+	if _, err := NewCustomFieldService(db).Create(user.ID, CreateCustomFieldInput{Key: "license", Name: "License", Type: "secret"}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	service := NewSubscriptionService(db)
+	subID := createCustomFieldTestSubscription(t, service, user.ID, map[string]string{"license": "XXXX-SECRET"})
+
+	sub, err := service.GetByID(user.ID, subID)
+	if err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+	if sub.CustomFields["license"] != CustomFieldSecretMask {
+		t.Fatalf("license = %q, want mask", sub.CustomFields["license"])
+	}
+
+	if _, err := service.Update(user.ID, subID, UpdateSubscriptionInput{CustomFields: map[string]string{"license": CustomFieldSecretMask}}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	value, err := service.RevealCustomFieldSecret(user.ID, subID, "license")
+	if err != nil {
+		t.Fatalf("RevealCustomFieldSecret() error = %v", err)
+	}
+	if value != "XXXX-SECRET" {
+		t.Fatalf("revealed value = %q, want XXXX-SECRET", value)
+	}
+
+	matches, err := service.Search(user.ID, "secret")
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	if len(matches) != 0 {
+		t.Fatalf("Search() matched secret value: %+v", matches)
+	}
+	if vars := customFieldTemplateVariables(db, *sub); vars["license"] != "" {
+		t.Fatalf("template variables expose secret: %v", vars)
+	}
+}
+
+func TestCustomFieldTemplateVariables(t *testing.T) {
+	validator := NewTemplateValidator()
+	tmpl := "{{.SubscriptionName}} contract {{.Custom_contract_id}}"
+	if err := validator.ValidateTemplate(tmpl); err != nil {
+		t.Fatalf("ValidateTemplate() error = %v", err)
+	}
+
+	renderer := NewTemplateRenderer(validator)
+	rendered, err := renderer.RenderTemplate(tmpl, TemplateData{
+		SubscriptionName: "Hosting",
+		CustomFields:     map[string]string{"contract_id": "ACME-4711"},
+	})
+	if err != nil {
+		t.Fatalf("RenderTemplate() error = %v", err)
+	}
+	if rendered != "Hosting contract ACME-4711" {
+		t.Fatalf("rendered = %q", rendered)
+	}
+}
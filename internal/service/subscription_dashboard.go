@@ -4,11 +4,11 @@ import (
 	"time"
 
 	"github.com/shiroha/subdux/internal/model"
-	"github.com/shiroha/subdux/internal/pkg"
+	"github.com/shiroha/subdux/internal/pkg/money"
 )
 
 func (s *SubscriptionService) GetDashboardSummary(userID uint, targetCurrency string, converter CurrencyConverter) (*DashboardSummary, error) {
-	now := pkg.NowInSystemTimezone()
+	now := userNow(s.DB, userID)
 
 	var subs []model.Subscription
 	if err := s.DB.Where("user_id = ? AND status = ?", userID, subscriptionStatusActive).Find(&subs).Error; err != nil {
@@ -30,7 +30,7 @@ func (s *SubscriptionService) SubscriptionsWithSummary(
 	targetCurrency string,
 	converter CurrencyConverter,
 ) ([]model.Subscription, *DashboardSummary, error) {
-	now := pkg.NowInSystemTimezone()
+	now := userNow(s.DB, userID)
 
 	var subs []model.Subscription
 	if err := s.DB.Where("user_id = ?", userID).
@@ -82,9 +82,13 @@ func computeDashboardSummary(subs []model.Subscription, targetCurrency string, c
 	startOfThisMonth := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC)
 	startOfNextMonth := startOfThisMonth.AddDate(0, 1, 0)
 
-	var totalMonthly float64
-	var committedMonthly float64
-	var dueThisMonth float64
+	// Each subscription is converted and normalised to a monthly amount once,
+	// rounded to the target currency's minor units, and the totals are summed
+	// in those units so they never pick up floating-point drift.
+	totalMonthly := money.NewTotal(targetCurrency)
+	committedMonthly := money.NewTotal(targetCurrency)
+	dueThisMonth := money.NewTotal(targetCurrency)
+	var monthlyComponents chargeComponents
 	for _, sub := range subs {
 		amount := sub.Amount
 		if converter != nil && sub.Currency != targetCurrency {
@@ -93,15 +97,17 @@ func computeDashboardSummary(subs []model.Subscription, targetCurrency string, c
 
 		factor := subscriptionMonthlyFactor(sub)
 		if factor > 0 && subscriptionContributesToOngoingSpend(sub) {
-			totalMonthly += amount * factor
+			monthly := money.Multiply(amount, factor, targetCurrency)
+			totalMonthly.AddMinorUnits(monthly)
 			if normalizeRenewalMode(sub.RenewalMode) == renewalModeAutoRenew {
-				committedMonthly += amount * factor
+				committedMonthly.AddMinorUnits(monthly)
 			}
+			monthlyComponents.add(subscriptionChargeComponents(sub, targetCurrency, converter).scaled(factor, targetCurrency))
 		}
 
 		occurrences := len(subscriptionChargeDatesInRange(sub, today, startOfNextMonth))
 		if occurrences > 0 {
-			dueThisMonth += amount * float64(occurrences)
+			dueThisMonth.AddMinorUnits(money.ToMinorUnits(amount, targetCurrency) * int64(occurrences))
 		}
 	}
 
@@ -120,14 +126,17 @@ func computeDashboardSummary(subs []model.Subscription, targetCurrency string, c
 	}
 
 	return &DashboardSummary{
-		TotalMonthly:         totalMonthly,
-		TotalYearly:          totalMonthly * 12,
-		CommittedMonthly:     committedMonthly,
-		CommittedYearly:      committedMonthly * 12,
-		DueThisMonth:         dueThisMonth,
+		TotalMonthly:         totalMonthly.Float64(),
+		TotalYearly:          money.FromMinorUnits(totalMonthly.MinorUnits()*12, targetCurrency),
+		CommittedMonthly:     committedMonthly.Float64(),
+		CommittedYearly:      money.FromMinorUnits(committedMonthly.MinorUnits()*12, targetCurrency),
+		DueThisMonth:         dueThisMonth.Float64(),
 		ActiveCount:          int64(len(subs)),
 		UpcomingRenewalCount: upcomingRenewalCount,
 		Currency:             targetCurrency,
+		MinorUnits:           money.MinorUnits(targetCurrency),
+		MonthlyComponents:    monthlyComponents.spend(targetCurrency),
+		YearlyComponents:     monthlyComponents.times(12).spend(targetCurrency),
 	}
 }
 
@@ -6,7 +6,6 @@ import (
 	"time"
 
 	"github.com/shiroha/subdux/internal/model"
-	"github.com/shiroha/subdux/internal/pkg"
 	"gorm.io/gorm"
 )
 
@@ -19,6 +18,7 @@ type SubscriptionDetail struct {
 	NotificationLogs []SubscriptionDetailNotificationLog  `json:"notification_logs"`
 	UpcomingCharges  []SubscriptionDetailUpcomingCharge   `json:"upcoming_charges"`
 	Calendar         SubscriptionDetailCalendar           `json:"calendar"`
+	Usage            SubscriptionUsageStats               `json:"usage"`
 }
 
 type SubscriptionDetailEvent struct {
@@ -99,7 +99,13 @@ func (s *SubscriptionService) GetDetail(userID, id uint) (*SubscriptionDetail, e
 		return nil, err
 	}
 
-	upcomingCharges := subscriptionDetailUpcomingCharges(*sub, subscriptionDetailUpcomingChargeCount, pkg.NowInSystemTimezone())
+	now := userNow(s.DB, userID)
+	upcomingCharges := subscriptionDetailUpcomingCharges(*sub, subscriptionDetailUpcomingChargeCount, now)
+
+	usage, err := s.subscriptionUsageStats(userID, *sub, normalizeDateUTC(now))
+	if err != nil {
+		return nil, err
+	}
 
 	return &SubscriptionDetail{
 		Subscription:     *sub,
@@ -108,6 +114,7 @@ func (s *SubscriptionService) GetDetail(userID, id uint) (*SubscriptionDetail, e
 		NotificationLogs: logs,
 		UpcomingCharges:  upcomingCharges,
 		Calendar:         subscriptionDetailCalendar(upcomingCharges),
+		Usage:            usage,
 	}, nil
 }
 
@@ -44,7 +44,7 @@ func TestGetSubscriptionDetailAggregatesHistoryLogsAndUpcomingCharges(t *testing
 
 	if err := db.Create(&model.NotificationLog{
 		UserID:         user.ID,
-		SubscriptionID: sub.ID,
+		SubscriptionID: &sub.ID,
 		ChannelType:    "email",
 		NotifyDate:     mustDate(t, "2026-03-12"),
 		Status:         "sent",
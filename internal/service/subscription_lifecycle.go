@@ -6,7 +6,6 @@ import (
 	"time"
 
 	"github.com/shiroha/subdux/internal/model"
-	"github.com/shiroha/subdux/internal/pkg"
 	"gorm.io/gorm"
 )
 
@@ -185,9 +184,17 @@ func persistAdvancedSubscriptionLifecycle(db *gorm.DB, userID uint, sub *model.S
 }
 
 // reconcileSubscriptionLifecycleForUser persists any due lifecycle transitions
-// for a user's active recurring subscriptions. It is invoked by the background
-// sweep and by write paths, not by ordinary read requests.
+// for a user's active recurring subscriptions, after applying scheduled price
+// changes that have taken effect. It is invoked by the background sweep and by
+// write paths, not by ordinary read requests. referenceDate is moved into the
+// user's timezone first, so a sweep driven by one server clock ends and rolls
+// subscriptions on each user's own calendar day.
 func reconcileSubscriptionLifecycleForUser(db *gorm.DB, userID uint, referenceDate time.Time) error {
+	referenceDate = inUserTimezone(db, userID, referenceDate)
+	if err := applyDuePriceChanges(db, userID, nil, referenceDate); err != nil {
+		return err
+	}
+
 	var subs []model.Subscription
 	if err := db.Where("user_id = ? AND status = ? AND billing_type = ?", userID, subscriptionStatusActive, billingTypeRecurring).
 		Find(&subs).Error; err != nil {
@@ -218,6 +225,10 @@ func presentSubscriptionForResponse(sub *model.Subscription, referenceDate time.
 // transition that should have happened. Missing rows are ignored: the caller's
 // own load reports not-found.
 func reconcileSubscriptionForWrite(db *gorm.DB, userID, id uint, referenceDate time.Time) error {
+	if err := applyDuePriceChanges(db, userID, &id, referenceDate); err != nil {
+		return err
+	}
+
 	var sub model.Subscription
 	if err := db.Where("id = ? AND user_id = ?", id, userID).First(&sub).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -233,7 +244,7 @@ func reconcileSubscriptionForWrite(db *gorm.DB, userID, id uint, referenceDate t
 // callable from an API endpoint or admin action to repair a user's state
 // immediately rather than waiting for the next sweep.
 func (s *SubscriptionService) ReconcileUserLifecycle(userID uint) error {
-	return reconcileSubscriptionLifecycleForUser(s.DB, userID, pkg.NowInSystemTimezone())
+	return reconcileSubscriptionLifecycleForUser(s.DB, userID, userNow(s.DB, userID))
 }
 
 func deriveLegacyLifecycle(enabled bool, nextBillingDate, endsAt *time.Time, updatedAt time.Time) lifecycleDraft {
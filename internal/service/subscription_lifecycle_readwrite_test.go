@@ -74,7 +74,7 @@ func TestReadPathsDoNotWriteSubscriptions(t *testing.T) {
 	if _, err := service.GetDashboardSummary(user.ID, "USD", nil); err != nil {
 		t.Fatalf("GetDashboardSummary() error = %v", err)
 	}
-	if _, err := service.GetActionCenter(user.ID); err != nil {
+	if _, err := service.GetActionCenter(user.ID, "USD", nil); err != nil {
 		t.Fatalf("GetActionCenter() error = %v", err)
 	}
 	if _, err := service.GetAnalyticsReport(user.ID, "USD", nil); err != nil {
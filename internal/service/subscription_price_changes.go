@@ -0,0 +1,212 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/shiroha/subdux/internal/model"
+	"github.com/shiroha/subdux/internal/pkg"
+	"github.com/shiroha/subdux/internal/pkg/money"
+	"gorm.io/gorm"
+)
+
+const subscriptionEventPriceChangeApplied = "price_change_applied"
+
+var (
+	ErrPriceChangeNotFound       = errors.New("price change not found")
+	ErrPriceChangeAlreadyApplied = errors.New("price change has already been applied and is read-only")
+	ErrPriceChangeDateTaken      = errors.New("only one price change can be scheduled per effective date")
+)
+
+type SchedulePriceChangeInput struct {
+	Amount        float64 `json:"amount"`
+	Currency      string  `json:"currency"`
+	EffectiveDate string  `json:"effective_date"`
+	Note          string  `json:"note"`
+}
+
+// ListPriceChanges returns every scheduled and applied price change of a
+// subscription, oldest effective date first.
+func (s *SubscriptionService) ListPriceChanges(userID, subscriptionID uint) ([]model.SubscriptionPriceChange, error) {
+	if _, err := s.GetByID(userID, subscriptionID); err != nil {
+		return nil, err
+	}
+
+	var changes []model.SubscriptionPriceChange
+	if err := s.DB.Where("user_id = ? AND subscription_id = ?", userID, subscriptionID).
+		Order("effective_date ASC").
+		Order("id ASC").
+		Find(&changes).Error; err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// SchedulePriceChange records a future amount and currency for a subscription.
+// The effective date must be after today in the user's timezone; a change due
+// today is an ordinary edit.
+func (s *SubscriptionService) SchedulePriceChange(userID, subscriptionID uint, input SchedulePriceChangeInput) (*model.SubscriptionPriceChange, error) {
+	sub, err := s.GetByID(userID, subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	if !subscriptionIsActive(*sub) {
+		return nil, errors.New("only active subscriptions can schedule a price change")
+	}
+
+	if input.Amount < 0 {
+		return nil, errors.New("amount must not be negative")
+	}
+	effectiveDate, err := parseOptionalDateString(input.EffectiveDate)
+	if err != nil {
+		return nil, err
+	}
+	if effectiveDate == nil {
+		return nil, errors.New("effective_date is required")
+	}
+	if !effectiveDate.After(normalizeDateUTC(userNow(s.DB, userID))) {
+		return nil, errors.New("effective_date must be in the future")
+	}
+	currency := strings.ToUpper(strings.TrimSpace(input.Currency))
+	if currency == "" {
+		currency = strings.ToUpper(strings.TrimSpace(sub.Currency))
+	}
+	note := strings.TrimSpace(input.Note)
+	if len(note) > 500 {
+		return nil, errors.New("note must be at most 500 characters")
+	}
+
+	var existing int64
+	if err := s.DB.Model(&model.SubscriptionPriceChange{}).
+		Where("subscription_id = ? AND effective_date = ?", subscriptionID, *effectiveDate).
+		Count(&existing).Error; err != nil {
+		return nil, err
+	}
+	if existing > 0 {
+		return nil, ErrPriceChangeDateTaken
+	}
+
+	change := model.SubscriptionPriceChange{
+		UserID:         userID,
+		SubscriptionID: subscriptionID,
+		EffectiveDate:  *effectiveDate,
+		Amount:         input.Amount,
+		Currency:       currency,
+		Note:           note,
+	}
+	if err := s.DB.Create(&change).Error; err != nil {
+		return nil, err
+	}
+	return &change, nil
+}
+
+// CancelPriceChange deletes a price change that has not been applied yet.
+func (s *SubscriptionService) CancelPriceChange(userID, subscriptionID, changeID uint) error {
+	var change model.SubscriptionPriceChange
+	if err := s.DB.Where("id = ? AND user_id = ? AND subscription_id = ?", changeID, userID, subscriptionID).
+		First(&change).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrPriceChangeNotFound
+		}
+		return err
+	}
+	if change.AppliedAt != nil {
+		return ErrPriceChangeAlreadyApplied
+	}
+	return s.DB.Delete(&change).Error
+}
+
+// applyDuePriceChanges writes every pending price change whose effective date
+// has arrived onto its subscription and records a SubscriptionEvent for it,
+// so reports and the Action Center see the change like a manual edit. When a
+// subscription has several due changes they are applied in date order and
+// the last one wins. subscriptionID narrows the work to one subscription.
+func applyDuePriceChanges(db *gorm.DB, userID uint, subscriptionID *uint, today time.Time) error {
+	query := db.Where("user_id = ? AND applied_at IS NULL AND effective_date <= ?", userID, normalizeDateUTC(today))
+	if subscriptionID != nil {
+		query = query.Where("subscription_id = ?", *subscriptionID)
+	}
+	var due []model.SubscriptionPriceChange
+	if err := query.Order("effective_date ASC").Order("id ASC").Find(&due).Error; err != nil {
+		return err
+	}
+
+	for _, change := range due {
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			var before model.Subscription
+			if err := tx.Where("id = ? AND user_id = ?", change.SubscriptionID, userID).First(&before).Error; err != nil {
+				return err
+			}
+			appliedAt := pkg.NowUTC()
+			if err := tx.Model(&model.SubscriptionPriceChange{}).
+				Where("id = ?", change.ID).
+				Update("applied_at", appliedAt).Error; err != nil {
+				return err
+			}
+			if !subscriptionIsActive(before) {
+				// An ended subscription is not charged again; the change is
+				// retired without touching its last known price.
+				return nil
+			}
+
+			if err := tx.Model(&model.Subscription{}).
+				Where("id = ? AND user_id = ?", change.SubscriptionID, userID).
+				Updates(map[string]interface{}{
+					"amount_minor": change.AmountMinor,
+					"currency":     change.Currency,
+				}).Error; err != nil {
+				return err
+			}
+			var after model.Subscription
+			if err := tx.Where("id = ? AND user_id = ?", change.SubscriptionID, userID).First(&after).Error; err != nil {
+				return err
+			}
+			return (&SubscriptionService{DB: tx}).recordSubscriptionChanged(userID, before, after, subscriptionEventPriceChangeApplied)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pendingPriceChangesBySubscription loads a user's unapplied price changes,
+// grouped by subscription and ordered by effective date.
+func (s *SubscriptionService) pendingPriceChangesBySubscription(userID uint) (map[uint][]model.SubscriptionPriceChange, error) {
+	var changes []model.SubscriptionPriceChange
+	if err := s.DB.Where("user_id = ? AND applied_at IS NULL", userID).
+		Order("effective_date ASC").
+		Order("id ASC").
+		Find(&changes).Error; err != nil {
+		return nil, err
+	}
+
+	result := make(map[uint][]model.SubscriptionPriceChange)
+	for _, change := range changes {
+		result[change.SubscriptionID] = append(result[change.SubscriptionID], change)
+	}
+	return result, nil
+}
+
+// subscriptionPriceOn returns the amount and currency sub will be charged on
+// date, taking the latest pending change effective on or before it.
+func subscriptionPriceOn(sub model.Subscription, changes []model.SubscriptionPriceChange, date time.Time) (float64, string) {
+	amount, currency := sub.Amount, sub.Currency
+	date = normalizeDateUTC(date)
+	for _, change := range changes {
+		if normalizeDateUTC(change.EffectiveDate).After(date) {
+			break
+		}
+		amount, currency = change.Amount, change.Currency
+	}
+	return amount, currency
+}
+
+// subscriptionChargeMinorOn is subscriptionPriceOn converted to minor units of
+// targetCurrency.
+func subscriptionChargeMinorOn(sub model.Subscription, changes []model.SubscriptionPriceChange, date time.Time, targetCurrency string, converter CurrencyConverter) int64 {
+	amount, currency := subscriptionPriceOn(sub, changes, date)
+	priced := sub
+	priced.Amount, priced.Currency = amount, currency
+	return money.ToMinorUnits(convertSubscriptionAmount(priced, targetCurrency, converter), targetCurrency)
+}
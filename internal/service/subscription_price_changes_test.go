@@ -0,0 +1,119 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/shiroha/subdux/internal/model"
+	"github.com/shiroha/subdux/internal/pkg"
+)
+
+func TestScheduledPriceChangeProjectsSurfacesAndApplies(t *testing.T) {
+	restoreClock := pkg.SetNowForTest(mustDate(t, "2026-02-10"))
+	t.Cleanup(restoreClock)
+
+	db := newTestDB(t)
+	user := createTestUser(t, db)
+	service := NewSubscriptionService(db)
+
+	monthly := 1
+	sub, err := service.Create(user.ID, CreateSubscriptionInput{
+		Name:            "Streaming",
+		Amount:          12,
+		Currency:        "USD",
+		Status:          subscriptionStatusActive,
+		RenewalMode:     renewalModeAutoRenew,
+		BillingType:     billingTypeRecurring,
+		RecurrenceType:  recurrenceTypeInterval,
+		IntervalCount:   &monthly,
+		IntervalUnit:    intervalUnitMonth,
+		NextBillingDate: "2026-02-15",
+	})
+	if err != nil {
+		t.Fatalf("create subscription failed: %v", err)
+	}
+
+	if _, err := service.SchedulePriceChange(user.ID, sub.ID, SchedulePriceChangeInput{
+		Amount:        15,
+		EffectiveDate: "2026-02-10",
+	}); err == nil {
+		t.Fatal("SchedulePriceChange() accepted an effective date of today")
+	}
+	change, err := service.SchedulePriceChange(user.ID, sub.ID, SchedulePriceChangeInput{
+		Amount:        15,
+		EffectiveDate: "2026-03-01",
+		Note:          "announced by email",
+	})
+	if err != nil {
+		t.Fatalf("SchedulePriceChange() error = %v", err)
+	}
+	if change.Currency != "USD" {
+		t.Fatalf("currency = %q, want USD from the subscription", change.Currency)
+	}
+	if _, err := service.SchedulePriceChange(user.ID, sub.ID, SchedulePriceChangeInput{
+		Amount:        16,
+		EffectiveDate: "2026-03-01",
+	}); !errors.Is(err, ErrPriceChangeDateTaken) {
+		t.Fatalf("duplicate SchedulePriceChange() error = %v, want ErrPriceChangeDateTaken", err)
+	}
+
+	report, err := service.GetAnalyticsReport(user.ID, "USD", nil)
+	if err != nil {
+		t.Fatalf("GetAnalyticsReport() error = %v", err)
+	}
+	assertFloatEqual(t, report.MonthlyForecast[0].AmountDue, 12, "february amount_due")
+	assertFloatEqual(t, report.MonthlyForecast[1].AmountDue, 15, "march amount_due")
+	assertFloatEqual(t, report.KPIs.TotalMonthly, 12, "total_monthly before the change")
+
+	center, err := service.GetActionCenter(user.ID, "USD", nil)
+	if err != nil {
+		t.Fatalf("GetActionCenter() error = %v", err)
+	}
+	var scheduled *SubscriptionAction
+	for i := range center.Items {
+		if center.Items[i].Type == actionTypeScheduledPrice {
+			scheduled = &center.Items[i]
+		}
+	}
+	if scheduled == nil {
+		t.Fatalf("action center items = %#v, want a scheduled price increase", center.Items)
+	}
+	if scheduled.DueDate == nil || *scheduled.DueDate != "2026-03-01" {
+		t.Fatalf("scheduled due_date = %v, want 2026-03-01", scheduled.DueDate)
+	}
+	assertFloatEqual(t, *scheduled.DeltaMonthlyAmount, 3, "scheduled delta_monthly_amount")
+
+	restoreClock()
+	restoreClock = pkg.SetNowForTest(mustDate(t, "2026-03-01"))
+	t.Cleanup(restoreClock)
+	if err := service.reconcileDueLifecycles(pkg.NowUTC()); err != nil {
+		t.Fatalf("reconcileDueLifecycles() error = %v", err)
+	}
+
+	var updated model.Subscription
+	if err := db.First(&updated, sub.ID).Error; err != nil {
+		t.Fatalf("load subscription failed: %v", err)
+	}
+	if updated.Amount != 15 || updated.AmountMinor != 1500 {
+		t.Fatalf("amount = (%v, %d minor), want (15, 1500)", updated.Amount, updated.AmountMinor)
+	}
+
+	var applied model.SubscriptionPriceChange
+	if err := db.First(&applied, change.ID).Error; err != nil {
+		t.Fatalf("load price change failed: %v", err)
+	}
+	if applied.AppliedAt == nil {
+		t.Fatal("applied_at was not set")
+	}
+	if err := service.CancelPriceChange(user.ID, sub.ID, change.ID); !errors.Is(err, ErrPriceChangeAlreadyApplied) {
+		t.Fatalf("CancelPriceChange() error = %v, want ErrPriceChangeAlreadyApplied", err)
+	}
+
+	var event model.SubscriptionEvent
+	if err := db.Where("subscription_id = ? AND type = ?", sub.ID, subscriptionEventPriceChangeApplied).First(&event).Error; err != nil {
+		t.Fatalf("load price change event failed: %v", err)
+	}
+	if event.PreviousAmount == nil || *event.PreviousAmount != 12 || event.NewAmount == nil || *event.NewAmount != 15 {
+		t.Fatalf("event amounts = (%v, %v), want (12, 15)", event.PreviousAmount, event.NewAmount)
+	}
+}
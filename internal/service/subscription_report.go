@@ -6,7 +6,7 @@ import (
 	"time"
 
 	"github.com/shiroha/subdux/internal/model"
-	"github.com/shiroha/subdux/internal/pkg"
+	"github.com/shiroha/subdux/internal/pkg/money"
 )
 
 const (
@@ -16,6 +16,7 @@ const (
 
 type AnalyticsReport struct {
 	Currency               string                    `json:"currency"`
+	MinorUnits             int                       `json:"minor_units"`
 	GeneratedAt            time.Time                 `json:"generated_at"`
 	KPIs                   AnalyticsReportKPIs       `json:"kpis"`
 	MonthlyForecast        []MonthlyForecastItem     `json:"monthly_forecast"`
@@ -27,6 +28,10 @@ type AnalyticsReport struct {
 	PriceIncreases         []ReportPriceIncrease     `json:"price_increases"`
 	RecentChanges          []ReportSubscriptionEvent `json:"recent_changes"`
 	AnnualGrowth           []ReportAnnualGrowthItem  `json:"annual_growth"`
+	LeastUsed              []ReportSubscriptionUsage `json:"least_used"`
+	// PastSpend is what was actually billed in each of the last twelve
+	// months, as reconstructed by GetSpendHistory.
+	PastSpend []SpendHistoryMonth `json:"past_spend"`
 }
 
 type AnalyticsReportKPIs struct {
@@ -41,6 +46,10 @@ type AnalyticsReportKPIs struct {
 	DueThisMonth         float64 `json:"due_this_month"`
 	DueNext30Days        float64 `json:"due_next_30_days"`
 	UpcomingRenewalCount int64   `json:"upcoming_renewal_count"`
+	// MonthlyComponents and YearlyComponents split ongoing spend into net,
+	// tax, fees and gross, as in DashboardSummary.
+	MonthlyComponents SpendComponents `json:"monthly_components"`
+	YearlyComponents  SpendComponents `json:"yearly_components"`
 }
 
 type MonthlyForecastItem struct {
@@ -119,14 +128,14 @@ type ReportAnnualGrowthItem struct {
 }
 
 type reportBreakdownAccumulator struct {
-	key           string
-	label         string
-	count         int64
-	monthlyAmount float64
+	key          string
+	label        string
+	count        int64
+	monthlyMinor int64
 }
 
 func (s *SubscriptionService) GetAnalyticsReport(userID uint, targetCurrency string, converter CurrencyConverter) (*AnalyticsReport, error) {
-	now := pkg.NowInSystemTimezone()
+	now := userNow(s.DB, userID)
 
 	if strings.TrimSpace(targetCurrency) == "" {
 		targetCurrency = "USD"
@@ -147,6 +156,10 @@ func (s *SubscriptionService) GetAnalyticsReport(userID uint, targetCurrency str
 	if err != nil {
 		return nil, err
 	}
+	pendingPriceChanges, err := s.pendingPriceChangesBySubscription(userID)
+	if err != nil {
+		return nil, err
+	}
 
 	today := normalizeDateUTC(now)
 	startOfThisMonth := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC)
@@ -155,6 +168,7 @@ func (s *SubscriptionService) GetAnalyticsReport(userID uint, targetCurrency str
 
 	report := &AnalyticsReport{
 		Currency:    targetCurrency,
+		MinorUnits:  money.MinorUnits(targetCurrency),
 		GeneratedAt: now,
 		KPIs: AnalyticsReportKPIs{
 			ActiveCount: int64(len(subs)),
@@ -168,12 +182,15 @@ func (s *SubscriptionService) GetAnalyticsReport(userID uint, targetCurrency str
 		PriceIncreases:         []ReportPriceIncrease{},
 		RecentChanges:          []ReportSubscriptionEvent{},
 		AnnualGrowth:           []ReportAnnualGrowthItem{},
+		LeastUsed:              []ReportSubscriptionUsage{},
+		PastSpend:              []SpendHistoryMonth{},
 	}
 
 	categoryBreakdowns := map[string]*reportBreakdownAccumulator{}
 	paymentMethodBreakdowns := map[string]*reportBreakdownAccumulator{}
 	renewalModeBreakdowns := map[string]*reportBreakdownAccumulator{}
 
+	forecastTotals := make([]money.Total, 12)
 	for i := 0; i < 12; i++ {
 		periodStart := startOfThisMonth.AddDate(0, i, 0)
 		if i == 0 {
@@ -182,35 +199,65 @@ func (s *SubscriptionService) GetAnalyticsReport(userID uint, targetCurrency str
 		report.MonthlyForecast = append(report.MonthlyForecast, MonthlyForecastItem{
 			Month: periodStart.Format("2006-01"),
 		})
+		forecastTotals[i] = money.NewTotal(targetCurrency)
 	}
 
+	// Amounts are rounded to the target currency once per subscription and
+	// every total is summed in minor units, as in computeDashboardSummary.
+	totalMonthly := money.NewTotal(targetCurrency)
+	committedMonthly := money.NewTotal(targetCurrency)
+	dueThisMonth := money.NewTotal(targetCurrency)
+	dueNext30Days := money.NewTotal(targetCurrency)
+	var monthlyComponents chargeComponents
+	monthlyBySubscription := make(map[uint]int64, len(subs))
 	for _, sub := range subs {
 		amount := convertSubscriptionAmount(sub, targetCurrency, converter)
+		amountMinor := money.ToMinorUnits(amount, targetCurrency)
+		amount = money.FromMinorUnits(amountMinor, targetCurrency)
+		// Charges on or after a scheduled price change are projected at the
+		// new price; ongoing monthly spend keeps the current price.
+		priceChanges := pendingPriceChanges[sub.ID]
+		chargeMinor := func(date time.Time) int64 {
+			if len(priceChanges) == 0 {
+				return amountMinor
+			}
+			return subscriptionChargeMinorOn(sub, priceChanges, date, targetCurrency, converter)
+		}
+		sumCharges := func(dates []time.Time) int64 {
+			var total int64
+			for _, date := range dates {
+				total += chargeMinor(date)
+			}
+			return total
+		}
 		factor := subscriptionMonthlyFactor(sub)
-		monthlyAmount := 0.0
+		var monthlyMinor int64
 		if subscriptionContributesToOngoingSpend(sub) {
-			monthlyAmount = amount * factor
+			monthlyMinor = money.Multiply(amount, factor, targetCurrency)
+			monthlyComponents.add(subscriptionChargeComponents(sub, targetCurrency, converter).scaled(factor, targetCurrency))
 		}
+		monthlyAmount := money.FromMinorUnits(monthlyMinor, targetCurrency)
+		monthlyBySubscription[sub.ID] = monthlyMinor
 
 		renewalMode := normalizeRenewalMode(sub.RenewalMode)
 		switch renewalMode {
 		case renewalModeAutoRenew:
 			report.KPIs.AutoRenewCount++
-			report.KPIs.CommittedMonthly += monthlyAmount
+			committedMonthly.AddMinorUnits(monthlyMinor)
 		case renewalModeManualRenew:
 			report.KPIs.ManualRenewCount++
 		case renewalModeCancelAtPeriodEnd:
 			report.KPIs.CancelingCount++
 		}
 
-		report.KPIs.TotalMonthly += monthlyAmount
+		totalMonthly.AddMinorUnits(monthlyMinor)
 
 		thisMonthRenewalDates := subscriptionChargeDatesInRange(sub, today, startOfNextMonth)
-		report.KPIs.DueThisMonth += amount * float64(len(thisMonthRenewalDates))
+		dueThisMonth.AddMinorUnits(sumCharges(thisMonthRenewalDates))
 
 		renewalDates := subscriptionChargeDatesInRange(sub, today, next30DaysExclusive)
 		report.KPIs.UpcomingRenewalCount += int64(len(renewalDates))
-		report.KPIs.DueNext30Days += amount * float64(len(renewalDates))
+		dueNext30Days.AddMinorUnits(sumCharges(renewalDates))
 		for _, renewalDate := range renewalDates {
 			report.UpcomingRenewals = append(report.UpcomingRenewals, ReportUpcomingRenewal{
 				ID:            sub.ID,
@@ -218,7 +265,7 @@ func (s *SubscriptionService) GetAnalyticsReport(userID uint, targetCurrency str
 				Icon:          sub.Icon,
 				BillingDate:   renewalDate.Format("2006-01-02"),
 				DaysUntil:     int(renewalDate.Sub(today).Hours() / 24),
-				Amount:        amount,
+				Amount:        money.FromMinorUnits(chargeMinor(renewalDate), targetCurrency),
 				Category:      reportSubscriptionCategory(sub, categoryLabels),
 				PaymentMethod: reportSubscriptionPaymentMethod(sub, paymentMethodLabels),
 				RenewalMode:   renewalMode,
@@ -234,18 +281,18 @@ func (s *SubscriptionService) GetAnalyticsReport(userID uint, targetCurrency str
 			occurrences := subscriptionChargeDatesInRange(sub, periodStart, periodEnd)
 			if len(occurrences) > 0 {
 				report.MonthlyForecast[i].OccurrenceCount += len(occurrences)
-				report.MonthlyForecast[i].AmountDue += amount * float64(len(occurrences))
+				forecastTotals[i].AddMinorUnits(sumCharges(occurrences))
 			}
 		}
 
-		if monthlyAmount > 0 {
+		if monthlyMinor > 0 {
 			categoryKey, categoryLabel := reportCategoryKeyAndLabel(sub, categoryLabels)
-			addReportBreakdown(categoryBreakdowns, categoryKey, categoryLabel, monthlyAmount)
+			addReportBreakdown(categoryBreakdowns, categoryKey, categoryLabel, monthlyMinor)
 
 			paymentKey, paymentLabel := reportPaymentMethodKeyAndLabel(sub, paymentMethodLabels)
-			addReportBreakdown(paymentMethodBreakdowns, paymentKey, paymentLabel, monthlyAmount)
+			addReportBreakdown(paymentMethodBreakdowns, paymentKey, paymentLabel, monthlyMinor)
 
-			addReportBreakdown(renewalModeBreakdowns, renewalMode, renewalMode, monthlyAmount)
+			addReportBreakdown(renewalModeBreakdowns, renewalMode, renewalMode, monthlyMinor)
 
 			nextBillingDate := ""
 			if sub.NextBillingDate != nil {
@@ -260,18 +307,27 @@ func (s *SubscriptionService) GetAnalyticsReport(userID uint, targetCurrency str
 				RenewalMode:      renewalMode,
 				NextBillingDate:  nextBillingDate,
 				MonthlyAmount:    monthlyAmount,
-				YearlyAmount:     monthlyAmount * 12,
+				YearlyAmount:     money.FromMinorUnits(monthlyMinor*12, targetCurrency),
 				OriginalAmount:   sub.Amount,
 				OriginalCurrency: strings.ToUpper(sub.Currency),
 			})
 		}
 	}
 
-	report.KPIs.TotalYearly = report.KPIs.TotalMonthly * 12
-	report.KPIs.CommittedYearly = report.KPIs.CommittedMonthly * 12
-	report.CategoryBreakdown = buildReportBreakdown(categoryBreakdowns, report.KPIs.TotalMonthly)
-	report.PaymentMethodBreakdown = buildReportBreakdown(paymentMethodBreakdowns, report.KPIs.TotalMonthly)
-	report.RenewalModeBreakdown = buildReportBreakdown(renewalModeBreakdowns, report.KPIs.TotalMonthly)
+	report.KPIs.TotalMonthly = totalMonthly.Float64()
+	report.KPIs.TotalYearly = money.FromMinorUnits(totalMonthly.MinorUnits()*12, targetCurrency)
+	report.KPIs.CommittedMonthly = committedMonthly.Float64()
+	report.KPIs.CommittedYearly = money.FromMinorUnits(committedMonthly.MinorUnits()*12, targetCurrency)
+	report.KPIs.DueThisMonth = dueThisMonth.Float64()
+	report.KPIs.DueNext30Days = dueNext30Days.Float64()
+	report.KPIs.MonthlyComponents = monthlyComponents.spend(targetCurrency)
+	report.KPIs.YearlyComponents = monthlyComponents.times(12).spend(targetCurrency)
+	for i := range report.MonthlyForecast {
+		report.MonthlyForecast[i].AmountDue = forecastTotals[i].Float64()
+	}
+	report.CategoryBreakdown = buildReportBreakdown(categoryBreakdowns, totalMonthly.MinorUnits(), targetCurrency)
+	report.PaymentMethodBreakdown = buildReportBreakdown(paymentMethodBreakdowns, totalMonthly.MinorUnits(), targetCurrency)
+	report.RenewalModeBreakdown = buildReportBreakdown(renewalModeBreakdowns, totalMonthly.MinorUnits(), targetCurrency)
 
 	sort.Slice(report.TopSubscriptions, func(i, j int) bool {
 		if report.TopSubscriptions[i].MonthlyAmount == report.TopSubscriptions[j].MonthlyAmount {
@@ -297,6 +353,18 @@ func (s *SubscriptionService) GetAnalyticsReport(userID uint, targetCurrency str
 		return nil, err
 	}
 
+	leastUsed, err := s.reportLeastUsed(userID, subs, monthlyBySubscription, targetCurrency, today)
+	if err != nil {
+		return nil, err
+	}
+	report.LeastUsed = leastUsed
+
+	pastSpend, err := s.GetSpendHistory(userID, targetCurrency, converter, "", "")
+	if err != nil {
+		return nil, err
+	}
+	report.PastSpend = pastSpend.Months
+
 	return report, nil
 }
 
@@ -344,7 +412,7 @@ func (s *SubscriptionService) reportPriceIncreases(userID uint, targetCurrency s
 		}
 		previousAmount := convertHistoricalAmount(*event.PreviousMonthlyAmount, event.PreviousCurrency, targetCurrency, converter)
 		newAmount := convertHistoricalAmount(*event.NewMonthlyAmount, event.NewCurrency, targetCurrency, converter)
-		delta := newAmount - previousAmount
+		delta := moneyDifference(newAmount, previousAmount, targetCurrency)
 		if delta <= 0 {
 			continue
 		}
@@ -404,7 +472,7 @@ func (s *SubscriptionService) reportRecentSubscriptionChanges(userID uint, targe
 }
 
 func (s *SubscriptionService) reportAnnualGrowth(userID uint, targetCurrency string, converter CurrencyConverter) ([]ReportAnnualGrowthItem, error) {
-	now := pkg.NowInSystemTimezone()
+	now := userNow(s.DB, userID)
 	var subs []model.Subscription
 	if err := s.DB.Where("user_id = ? AND status = ?", userID, subscriptionStatusActive).Find(&subs).Error; err != nil {
 		return nil, err
@@ -421,7 +489,8 @@ func (s *SubscriptionService) reportAnnualGrowth(userID uint, targetCurrency str
 		if !subscriptionContributesToOngoingSpend(sub) {
 			continue
 		}
-		currentMonthly := convertHistoricalAmount(sub.Amount*subscriptionMonthlyFactor(sub), sub.Currency, targetCurrency, converter)
+		currentAmount := convertHistoricalAmount(sub.Amount, sub.Currency, targetCurrency, converter)
+		currentMonthly := money.FromMinorUnits(money.Multiply(currentAmount, subscriptionMonthlyFactor(sub), targetCurrency), targetCurrency)
 		if currentMonthly <= 0 {
 			continue
 		}
@@ -431,7 +500,7 @@ func (s *SubscriptionService) reportAnnualGrowth(userID uint, targetCurrency str
 			continue
 		}
 
-		delta := currentMonthly - baselineMonthly
+		delta := moneyDifference(currentMonthly, baselineMonthly, targetCurrency)
 		if delta <= 0 {
 			continue
 		}
@@ -510,9 +579,14 @@ func convertHistoricalAmount(amount float64, currency, targetCurrency string, co
 		targetCurrency = "USD"
 	}
 	if currency == "" || converter == nil || strings.EqualFold(currency, targetCurrency) {
-		return amount
+		return money.Round(amount, targetCurrency)
 	}
-	return converter.Convert(amount, currency, targetCurrency)
+	return money.Round(converter.Convert(amount, currency, targetCurrency), targetCurrency)
+}
+
+// moneyDifference returns a - b computed in minor units of currency.
+func moneyDifference(a, b float64, currency string) float64 {
+	return money.FromMinorUnits(money.ToMinorUnits(a, currency)-money.ToMinorUnits(b, currency), currency)
 }
 
 func percentageDelta(previousAmount, newAmount float64) float64 {
@@ -579,7 +653,7 @@ func reportSubscriptionPaymentMethod(sub model.Subscription, labels map[uint]str
 	return label
 }
 
-func addReportBreakdown(items map[string]*reportBreakdownAccumulator, key, label string, monthlyAmount float64) {
+func addReportBreakdown(items map[string]*reportBreakdownAccumulator, key, label string, monthlyMinor int64) {
 	item, ok := items[key]
 	if !ok {
 		item = &reportBreakdownAccumulator{
@@ -589,22 +663,22 @@ func addReportBreakdown(items map[string]*reportBreakdownAccumulator, key, label
 		items[key] = item
 	}
 	item.count++
-	item.monthlyAmount += monthlyAmount
+	item.monthlyMinor += monthlyMinor
 }
 
-func buildReportBreakdown(items map[string]*reportBreakdownAccumulator, totalMonthly float64) []ReportBreakdownItem {
+func buildReportBreakdown(items map[string]*reportBreakdownAccumulator, totalMonthlyMinor int64, currency string) []ReportBreakdownItem {
 	result := make([]ReportBreakdownItem, 0, len(items))
 	for _, item := range items {
 		percentage := 0.0
-		if totalMonthly > 0 {
-			percentage = item.monthlyAmount / totalMonthly * 100
+		if totalMonthlyMinor > 0 {
+			percentage = float64(item.monthlyMinor) / float64(totalMonthlyMinor) * 100
 		}
 		result = append(result, ReportBreakdownItem{
 			Key:           item.key,
 			Label:         item.label,
 			Count:         item.count,
-			MonthlyAmount: item.monthlyAmount,
-			YearlyAmount:  item.monthlyAmount * 12,
+			MonthlyAmount: money.FromMinorUnits(item.monthlyMinor, currency),
+			YearlyAmount:  money.FromMinorUnits(item.monthlyMinor*12, currency),
 			Percentage:    percentage,
 		})
 	}
@@ -24,6 +24,11 @@ func newSubscriptionRolloverTestDB(t *testing.T) *gorm.DB {
 		&model.User{},
 		&model.Subscription{},
 		&model.SubscriptionEvent{},
+		&model.SubscriptionPriceChange{},
+		&model.SubscriptionUsage{},
+		&model.CustomFieldDefinition{},
+		&model.SubscriptionCustomFieldValue{},
+		&model.SubscriptionAttachment{},
 		&model.NotificationPolicy{},
 		&model.NotificationChannel{},
 		&model.NotificationTemplate{},
@@ -0,0 +1,314 @@
+package service
+
+import (
+	"errors"
+	"math"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/shiroha/subdux/internal/model"
+	"github.com/shiroha/subdux/internal/pkg/money"
+	"golang.org/x/net/publicsuffix"
+)
+
+const (
+	savingsReasonAnnualPlan   = "annual_plan"
+	savingsReasonDuplicate    = "duplicate"
+	savingsReasonUnused       = "unused"
+	defaultSavingsUnusedDays  = 60
+	maxSavingsUnusedDays      = 365
+	minDuplicateNameKeyLength = 3
+)
+
+var ErrInvalidSavingsUnusedDays = errors.New("unused_days must be between 1 and 365")
+
+// duplicateNameNoise lists plan and billing words that are stripped before
+// names are compared, so "Spotify Premium" and "Spotify Family" match.
+var duplicateNameNoise = map[string]struct{}{
+	"annual": {}, "basic": {}, "business": {}, "duo": {}, "family": {},
+	"individual": {}, "monthly": {}, "personal": {}, "plan": {}, "plus": {},
+	"premium": {}, "pro": {}, "standard": {}, "student": {}, "subscription": {},
+	"team": {}, "yearly": {},
+}
+
+type SavingsReport struct {
+	Currency               string               `json:"currency"`
+	MinorUnits             int                  `json:"minor_units"`
+	GeneratedAt            time.Time            `json:"generated_at"`
+	UnusedDays             int                  `json:"unused_days"`
+	PotentialYearlySavings float64              `json:"potential_yearly_savings"`
+	Opportunities          []SavingsOpportunity `json:"opportunities"`
+}
+
+// SavingsOpportunity is one way to spend less on a subscription. Amounts are
+// yearly and expressed in the report currency.
+type SavingsOpportunity struct {
+	Reason                 string   `json:"reason"`
+	SubscriptionID         uint     `json:"subscription_id"`
+	SubscriptionName       string   `json:"subscription_name"`
+	RelatedSubscriptionIDs []uint   `json:"related_subscription_ids"`
+	CurrentYearlyCost      float64  `json:"current_yearly_cost"`
+	AlternativeYearlyCost  *float64 `json:"alternative_yearly_cost"`
+	YearlySavings          float64  `json:"yearly_savings"`
+	LastUsedAt             *string  `json:"last_used_at"`
+	DaysSinceUsed          *int     `json:"days_since_used"`
+	Detail                 string   `json:"detail"`
+
+	yearlySavingsMinor int64
+}
+
+func validateAnnualPlanAmount(amount *float64) error {
+	if amount == nil {
+		return nil
+	}
+	if math.IsNaN(*amount) || math.IsInf(*amount, 0) || *amount <= 0 {
+		return errors.New("annual_plan_amount must be greater than zero")
+	}
+	return nil
+}
+
+// GetSavingsOpportunities looks for money a user could save on their active
+// subscriptions: switching to a cheaper annual plan they have entered,
+// cancelling the cheaper of two likely duplicates, and cancelling
+// subscriptions last checked in more than unusedDays ago. Subscriptions that
+// have never been checked in are not treated as unused.
+func (s *SubscriptionService) GetSavingsOpportunities(userID uint, targetCurrency string, converter CurrencyConverter, unusedDays int) (*SavingsReport, error) {
+	if unusedDays == 0 {
+		unusedDays = defaultSavingsUnusedDays
+	}
+	if unusedDays < 1 || unusedDays > maxSavingsUnusedDays {
+		return nil, ErrInvalidSavingsUnusedDays
+	}
+	if strings.TrimSpace(targetCurrency) == "" {
+		targetCurrency = "USD"
+	}
+	targetCurrency = strings.ToUpper(strings.TrimSpace(targetCurrency))
+
+	now := userNow(s.DB, userID)
+	var subs []model.Subscription
+	if err := s.DB.Where("user_id = ? AND status = ?", userID, subscriptionStatusActive).Find(&subs).Error; err != nil {
+		return nil, err
+	}
+	subs = presentActiveSubscriptions(subs, now)
+
+	opportunities, total := savingsOpportunities(subs, normalizeDateUTC(now), targetCurrency, converter, unusedDays)
+	return &SavingsReport{
+		Currency:               targetCurrency,
+		MinorUnits:             money.MinorUnits(targetCurrency),
+		GeneratedAt:            now,
+		UnusedDays:             unusedDays,
+		PotentialYearlySavings: money.FromMinorUnits(total, targetCurrency),
+		Opportunities:          opportunities,
+	}, nil
+}
+
+// savingsOpportunities runs every savings check over subs and returns the
+// opportunities, largest saving first, together with the total yearly saving
+// in minor units of targetCurrency. A subscription can appear under several
+// reasons; only its largest saving counts towards the total so the same money
+// is never saved twice.
+func savingsOpportunities(subs []model.Subscription, today time.Time, targetCurrency string, converter CurrencyConverter, unusedDays int) ([]SavingsOpportunity, int64) {
+	candidates := make([]model.Subscription, 0, len(subs))
+	yearlyCost := make(map[uint]int64, len(subs))
+	for _, sub := range subs {
+		if !subscriptionIsActive(sub) || !subscriptionContributesToOngoingSpend(sub) {
+			continue
+		}
+		factor := subscriptionMonthlyFactor(sub)
+		if factor <= 0 {
+			continue
+		}
+		monthly := money.Multiply(convertSubscriptionAmount(sub, targetCurrency, converter), factor, targetCurrency)
+		yearlyCost[sub.ID] = monthly * 12
+		candidates = append(candidates, sub)
+	}
+
+	opportunities := make([]SavingsOpportunity, 0)
+	for _, sub := range candidates {
+		if item, ok := annualPlanOpportunity(sub, yearlyCost[sub.ID], targetCurrency, converter); ok {
+			opportunities = append(opportunities, item)
+		}
+		if item, ok := unusedOpportunity(sub, yearlyCost[sub.ID], today, targetCurrency, unusedDays); ok {
+			opportunities = append(opportunities, item)
+		}
+	}
+	opportunities = append(opportunities, duplicateOpportunities(candidates, yearlyCost, targetCurrency)...)
+
+	best := make(map[uint]int64, len(opportunities))
+	for _, item := range opportunities {
+		if item.yearlySavingsMinor > best[item.SubscriptionID] {
+			best[item.SubscriptionID] = item.yearlySavingsMinor
+		}
+	}
+	var total int64
+	for _, savings := range best {
+		total += savings
+	}
+
+	sort.Slice(opportunities, func(i, j int) bool {
+		if opportunities[i].yearlySavingsMinor != opportunities[j].yearlySavingsMinor {
+			return opportunities[i].yearlySavingsMinor > opportunities[j].yearlySavingsMinor
+		}
+		if opportunities[i].SubscriptionName != opportunities[j].SubscriptionName {
+			return opportunities[i].SubscriptionName < opportunities[j].SubscriptionName
+		}
+		return opportunities[i].Reason < opportunities[j].Reason
+	})
+	return opportunities, total
+}
+
+// annualPlanOpportunity compares a subscription billed more often than once a
+// year with the annual price the user entered for the same service.
+func annualPlanOpportunity(sub model.Subscription, yearlyCost int64, targetCurrency string, converter CurrencyConverter) (SavingsOpportunity, bool) {
+	if sub.AnnualPlanAmount == nil || subscriptionMonthlyFactor(sub)*12 <= 1 {
+		return SavingsOpportunity{}, false
+	}
+	annual := sub
+	annual.Amount = *sub.AnnualPlanAmount
+	alternative := money.ToMinorUnits(convertSubscriptionAmount(annual, targetCurrency, converter), targetCurrency)
+	if alternative >= yearlyCost {
+		return SavingsOpportunity{}, false
+	}
+
+	alternativeAmount := money.FromMinorUnits(alternative, targetCurrency)
+	item := newSavingsOpportunity(sub, savingsReasonAnnualPlan, yearlyCost, yearlyCost-alternative, targetCurrency)
+	item.AlternativeYearlyCost = &alternativeAmount
+	item.Detail = "switching to the annual plan costs less than paying per period"
+	return item, true
+}
+
+// unusedOpportunity flags a subscription whose last check-in is at least
+// unusedDays old; cancelling it saves its whole yearly cost.
+func unusedOpportunity(sub model.Subscription, yearlyCost int64, today time.Time, targetCurrency string, unusedDays int) (SavingsOpportunity, bool) {
+	if sub.LastUsedAt == nil || yearlyCost <= 0 {
+		return SavingsOpportunity{}, false
+	}
+	lastUsed := normalizeDateUTC(*sub.LastUsedAt)
+	daysSince := int(today.Sub(lastUsed).Hours() / 24)
+	if daysSince < unusedDays {
+		return SavingsOpportunity{}, false
+	}
+
+	lastUsedAt := lastUsed.Format("2006-01-02")
+	item := newSavingsOpportunity(sub, savingsReasonUnused, yearlyCost, yearlyCost, targetCurrency)
+	item.LastUsedAt = &lastUsedAt
+	item.DaysSinceUsed = &daysSince
+	item.Detail = "not used recently; consider cancelling it"
+	return item, true
+}
+
+// duplicateOpportunities groups subscriptions that share a registrable URL
+// domain or the same name once plan words are removed. Within each group the
+// most expensive subscription is assumed to be the one to keep and every
+// other member is reported with its yearly cost as the saving.
+func duplicateOpportunities(subs []model.Subscription, yearlyCost map[uint]int64, targetCurrency string) []SavingsOpportunity {
+	groups := make(map[string][]model.Subscription)
+	for _, sub := range subs {
+		if domain := subscriptionRegistrableDomain(sub.URL); domain != "" {
+			groups["domain:"+domain] = append(groups["domain:"+domain], sub)
+		}
+		if name := duplicateNameKey(sub.Name); name != "" {
+			groups["name:"+name] = append(groups["name:"+name], sub)
+		}
+	}
+
+	related := make(map[uint]map[uint]struct{})
+	for _, members := range groups {
+		if len(members) < 2 {
+			continue
+		}
+		keep := members[0]
+		for _, member := range members[1:] {
+			if yearlyCost[member.ID] > yearlyCost[keep.ID] ||
+				(yearlyCost[member.ID] == yearlyCost[keep.ID] && member.ID < keep.ID) {
+				keep = member
+			}
+		}
+		for _, member := range members {
+			if member.ID == keep.ID {
+				continue
+			}
+			if related[member.ID] == nil {
+				related[member.ID] = make(map[uint]struct{})
+			}
+			for _, other := range members {
+				if other.ID != member.ID {
+					related[member.ID][other.ID] = struct{}{}
+				}
+			}
+		}
+	}
+
+	items := make([]SavingsOpportunity, 0, len(related))
+	for _, sub := range subs {
+		others, ok := related[sub.ID]
+		if !ok || yearlyCost[sub.ID] <= 0 {
+			continue
+		}
+		item := newSavingsOpportunity(sub, savingsReasonDuplicate, yearlyCost[sub.ID], yearlyCost[sub.ID], targetCurrency)
+		for id := range others {
+			item.RelatedSubscriptionIDs = append(item.RelatedSubscriptionIDs, id)
+		}
+		sort.Slice(item.RelatedSubscriptionIDs, func(i, j int) bool {
+			return item.RelatedSubscriptionIDs[i] < item.RelatedSubscriptionIDs[j]
+		})
+		item.Detail = "looks like a duplicate of another subscription"
+		items = append(items, item)
+	}
+	return items
+}
+
+func newSavingsOpportunity(sub model.Subscription, reason string, yearlyCost, savings int64, targetCurrency string) SavingsOpportunity {
+	return SavingsOpportunity{
+		Reason:                 reason,
+		SubscriptionID:         sub.ID,
+		SubscriptionName:       sub.Name,
+		RelatedSubscriptionIDs: []uint{},
+		CurrentYearlyCost:      money.FromMinorUnits(yearlyCost, targetCurrency),
+		YearlySavings:          money.FromMinorUnits(savings, targetCurrency),
+		yearlySavingsMinor:     savings,
+	}
+}
+
+// subscriptionRegistrableDomain returns the eTLD+1 of a subscription URL, so
+// music.example.com and www.example.com compare equal.
+func subscriptionRegistrableDomain(rawURL string) string {
+	if strings.TrimSpace(rawURL) == "" {
+		return ""
+	}
+	parsed, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return ""
+	}
+	host := strings.TrimSuffix(strings.ToLower(parsed.Hostname()), ".")
+	if host == "" {
+		return ""
+	}
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return ""
+	}
+	return domain
+}
+
+// duplicateNameKey lowercases a name, drops punctuation and plan words and
+// joins what is left, or returns "" when too little remains to compare.
+func duplicateNameKey(name string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var key strings.Builder
+	for _, word := range words {
+		if _, noise := duplicateNameNoise[word]; noise {
+			continue
+		}
+		key.WriteString(word)
+	}
+	if len([]rune(key.String())) < minDuplicateNameKeyLength {
+		return ""
+	}
+	return key.String()
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/shiroha/subdux/internal/pkg"
+)
+
+func TestSavingsOpportunitiesFeedActionCenter(t *testing.T) {
+	restoreClock := pkg.SetNowForTest(mustDate(t, "2026-06-01"))
+	t.Cleanup(restoreClock)
+
+	db := newTestDB(t)
+	user := createTestUser(t, db)
+	service := NewSubscriptionService(db)
+
+	monthly := 1
+	create := func(input CreateSubscriptionInput) uint {
+		t.Helper()
+		input.Status = subscriptionStatusActive
+		input.RenewalMode = renewalModeAutoRenew
+		input.BillingType = billingTypeRecurring
+		input.RecurrenceType = recurrenceTypeInterval
+		input.IntervalCount = &monthly
+		input.IntervalUnit = intervalUnitMonth
+		input.NextBillingDate = "2026-06-20"
+		sub, err := service.Create(user.ID, input)
+		if err != nil {
+			t.Fatalf("create %q failed: %v", input.Name, err)
+		}
+		return sub.ID
+	}
+
+	annual := 100.0
+	editorID := create(CreateSubscriptionInput{Name: "Editor", Amount: 10, AnnualPlanAmount: &annual})
+	musicID := create(CreateSubscriptionInput{Name: "Music Premium", Amount: 12, URL: "https://music.example.com/account"})
+	musicFamilyID := create(CreateSubscriptionInput{Name: "Music Family", Amount: 5, Category: "Other"})
+	gymID := create(CreateSubscriptionInput{Name: "Gym", Amount: 30})
+
+	if _, err := service.CheckIn(user.ID, gymID, CheckInSubscriptionInput{UsedAt: "2026-06-02"}); err == nil {
+		t.Fatal("CheckIn() accepted a future used_at")
+	}
+	if _, err := service.CheckIn(user.ID, gymID, CheckInSubscriptionInput{UsedAt: "2026-03-01"}); err != nil {
+		t.Fatalf("CheckIn() error = %v", err)
+	}
+	checked, err := service.CheckIn(user.ID, gymID, CheckInSubscriptionInput{UsedAt: "2026-02-01"})
+	if err != nil {
+		t.Fatalf("older CheckIn() error = %v", err)
+	}
+	if checked.LastUsedAt == nil || checked.LastUsedAt.Format("2006-01-02") != "2026-03-01" {
+		t.Fatalf("last_used_at = %v, want 2026-03-01 kept", checked.LastUsedAt)
+	}
+
+	report, err := service.GetSavingsOpportunities(user.ID, "USD", nil, 0)
+	if err != nil {
+		t.Fatalf("GetSavingsOpportunities() error = %v", err)
+	}
+	bySubscription := map[uint]SavingsOpportunity{}
+	for _, item := range report.Opportunities {
+		bySubscription[item.SubscriptionID] = item
+	}
+
+	if item := bySubscription[editorID]; item.Reason != savingsReasonAnnualPlan {
+		t.Fatalf("editor opportunity = %+v, want annual_plan", item)
+	} else {
+		assertFloatEqual(t, item.YearlySavings, 20, "annual plan savings")
+	}
+	if item := bySubscription[musicFamilyID]; item.Reason != savingsReasonDuplicate {
+		t.Fatalf("music family opportunity = %+v, want duplicate", item)
+	} else if len(item.RelatedSubscriptionIDs) != 1 || item.RelatedSubscriptionIDs[0] != musicID {
+		t.Fatalf("related ids = %v, want [%d]", item.RelatedSubscriptionIDs, musicID)
+	}
+	if _, ok := bySubscription[musicID]; ok {
+		t.Fatal("the more expensive duplicate should be kept, not flagged")
+	}
+	if item := bySubscription[gymID]; item.Reason != savingsReasonUnused {
+		t.Fatalf("gym opportunity = %+v, want unused", item)
+	} else if item.DaysSinceUsed == nil || *item.DaysSinceUsed != 92 {
+		t.Fatalf("days_since_used = %v, want 92", item.DaysSinceUsed)
+	}
+	// 20 (annual plan) + 60 (duplicate) + 360 (unused)
+	assertFloatEqual(t, report.PotentialYearlySavings, 440, "potential yearly savings")
+
+	center, err := service.GetActionCenter(user.ID, "USD", nil)
+	if err != nil {
+		t.Fatalf("GetActionCenter() error = %v", err)
+	}
+	assertFloatEqual(t, center.PotentialYearlySavings, 440, "action center savings")
+	reasons := map[string]bool{}
+	for _, item := range center.Items {
+		if item.Type == actionTypeSavings {
+			reasons[item.SavingsReason] = true
+		}
+	}
+	for _, reason := range []string{savingsReasonAnnualPlan, savingsReasonDuplicate, savingsReasonUnused} {
+		if !reasons[reason] {
+			t.Fatalf("action center savings reasons = %v, missing %s", reasons, reason)
+		}
+	}
+
+	if _, err := service.GetSavingsOpportunities(user.ID, "USD", nil, 400); !errors.Is(err, ErrInvalidSavingsUnusedDays) {
+		t.Fatalf("GetSavingsOpportunities() error = %v, want ErrInvalidSavingsUnusedDays", err)
+	}
+}
+
+func TestDuplicateNameKeyIgnoresPlanWords(t *testing.T) {
+	if got, want := duplicateNameKey("Spotify Premium"), duplicateNameKey("spotify (Family)"); got != want {
+		t.Fatalf("duplicateNameKey() = %q and %q, want equal", got, want)
+	}
+	if got := duplicateNameKey("Pro Plan"); got != "" {
+		t.Fatalf("duplicateNameKey(%q) = %q, want empty", "Pro Plan", got)
+	}
+	if got, want := subscriptionRegistrableDomain("https://www.example.co.uk/a"), "example.co.uk"; got != want {
+		t.Fatalf("subscriptionRegistrableDomain() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,430 @@
+package service
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shiroha/subdux/internal/model"
+	"github.com/shiroha/subdux/internal/pkg/money"
+)
+
+const (
+	maxSpendHistoryMonths     = 36
+	defaultSpendHistoryMonths = 12
+	maxReconstructedCharges   = 10000
+)
+
+var ErrInvalidSpendHistoryRange = errors.New("spend history range must be between 1 and 36 months with from before to and to not in the future")
+
+// SpendHistory is what was billed per calendar month over a past range,
+// reconstructed from subscription events and recurrence rules, with the same
+// months one year earlier for comparison.
+type SpendHistory struct {
+	Currency               string                      `json:"currency"`
+	MinorUnits             int                         `json:"minor_units"`
+	From                   string                      `json:"from"`
+	To                     string                      `json:"to"`
+	GeneratedAt            time.Time                   `json:"generated_at"`
+	Total                  float64                     `json:"total"`
+	ChargeCount            int                         `json:"charge_count"`
+	PreviousYearTotal      float64                     `json:"previous_year_total"`
+	YearOverYearDelta      float64                     `json:"year_over_year_delta"`
+	YearOverYearPercentage *float64                    `json:"year_over_year_percentage"`
+	Months                 []SpendHistoryMonth         `json:"months"`
+	Categories             []SpendHistoryBreakdownItem `json:"categories"`
+	PaymentMethods         []SpendHistoryBreakdownItem `json:"payment_methods"`
+}
+
+type SpendHistoryMonth struct {
+	Month              string   `json:"month"`
+	Amount             float64  `json:"amount"`
+	ChargeCount        int      `json:"charge_count"`
+	PreviousYearAmount float64  `json:"previous_year_amount"`
+	DeltaAmount        float64  `json:"delta_amount"`
+	DeltaPercentage    *float64 `json:"delta_percentage"`
+}
+
+type SpendHistoryBreakdownItem struct {
+	Key         string  `json:"key"`
+	Label       string  `json:"label"`
+	ChargeCount int     `json:"charge_count"`
+	Amount      float64 `json:"amount"`
+	Percentage  float64 `json:"percentage"`
+}
+
+// historicalCharge is one reconstructed past charge in minor units of the
+// report currency, with the category and payment method in effect that day.
+// subscription is the subscription as it was priced on that day.
+type historicalCharge struct {
+	date               time.Time
+	amountMinor        int64
+	subscription       model.Subscription
+	categoryKey        string
+	categoryLabel      string
+	paymentMethodKey   string
+	paymentMethodLabel string
+}
+
+type spendHistoryAccumulator struct {
+	key         string
+	label       string
+	chargeCount int
+	amountMinor int64
+}
+
+// subscriptionHistoryState is a subscription's billing-relevant fields from a
+// given day onwards, as recorded by a SubscriptionEvent snapshot.
+type subscriptionHistoryState struct {
+	from          time.Time
+	amount        float64
+	currency      string
+	status        string
+	categoryID    *uint
+	category      string
+	paymentMethod *uint
+}
+
+// GetSpendHistory reconstructs what each subscription billed per month from
+// fromMonth through toMonth (inclusive, YYYY-MM) and compares every month with
+// the same month a year earlier. Empty bounds default to the twelve months
+// ending with the current one.
+func (s *SubscriptionService) GetSpendHistory(userID uint, targetCurrency string, converter CurrencyConverter, fromMonth, toMonth string) (*SpendHistory, error) {
+	now := userNow(s.DB, userID)
+	today := normalizeDateUTC(now)
+	if strings.TrimSpace(targetCurrency) == "" {
+		targetCurrency = "USD"
+	}
+	targetCurrency = strings.ToUpper(strings.TrimSpace(targetCurrency))
+
+	start, months, err := parseSpendHistoryRange(fromMonth, toMonth, today)
+	if err != nil {
+		return nil, err
+	}
+	end := start.AddDate(0, months, 0)
+	previousStart := start.AddDate(-1, 0, 0)
+
+	charges, err := s.historicalCharges(userID, previousStart, end, today, targetCurrency, converter)
+	if err != nil {
+		return nil, err
+	}
+
+	history := &SpendHistory{
+		Currency:       targetCurrency,
+		MinorUnits:     money.MinorUnits(targetCurrency),
+		From:           start.Format("2006-01"),
+		To:             start.AddDate(0, months-1, 0).Format("2006-01"),
+		GeneratedAt:    now,
+		Months:         make([]SpendHistoryMonth, months),
+		Categories:     []SpendHistoryBreakdownItem{},
+		PaymentMethods: []SpendHistoryBreakdownItem{},
+	}
+
+	monthTotals := make([]int64, months)
+	previousTotals := make([]int64, months)
+	categories := map[string]*spendHistoryAccumulator{}
+	paymentMethods := map[string]*spendHistoryAccumulator{}
+	var total, previousTotal int64
+	for _, charge := range charges {
+		if charge.date.Before(start) {
+			// Ranges shorter than a year leave a gap before start that has
+			// no month to be compared with.
+			index := monthsBetween(previousStart, charge.date)
+			if index >= months {
+				continue
+			}
+			previousTotals[index] += charge.amountMinor
+			previousTotal += charge.amountMinor
+			continue
+		}
+		index := monthsBetween(start, charge.date)
+		monthTotals[index] += charge.amountMinor
+		history.Months[index].ChargeCount++
+		total += charge.amountMinor
+		history.ChargeCount++
+		addSpendHistoryBreakdown(categories, charge.categoryKey, charge.categoryLabel, charge.amountMinor)
+		addSpendHistoryBreakdown(paymentMethods, charge.paymentMethodKey, charge.paymentMethodLabel, charge.amountMinor)
+	}
+
+	for i := range history.Months {
+		month := &history.Months[i]
+		month.Month = start.AddDate(0, i, 0).Format("2006-01")
+		month.Amount = money.FromMinorUnits(monthTotals[i], targetCurrency)
+		month.PreviousYearAmount = money.FromMinorUnits(previousTotals[i], targetCurrency)
+		month.DeltaAmount = money.FromMinorUnits(monthTotals[i]-previousTotals[i], targetCurrency)
+		month.DeltaPercentage = optionalPercentageDelta(previousTotals[i], monthTotals[i])
+	}
+	history.Total = money.FromMinorUnits(total, targetCurrency)
+	history.PreviousYearTotal = money.FromMinorUnits(previousTotal, targetCurrency)
+	history.YearOverYearDelta = money.FromMinorUnits(total-previousTotal, targetCurrency)
+	history.YearOverYearPercentage = optionalPercentageDelta(previousTotal, total)
+	history.Categories = buildSpendHistoryBreakdown(categories, total, targetCurrency)
+	history.PaymentMethods = buildSpendHistoryBreakdown(paymentMethods, total, targetCurrency)
+	return history, nil
+}
+
+// historicalCharges lists every charge on or after start, before end and no
+// later than today, across all of the user's subscriptions.
+//
+// A subscription's charges run forward from the first billing date it was
+// created with, following its current recurrence rules, and stop before its
+// present next billing date, which has not been paid yet. Each charge uses the
+// amount, currency, category and payment method recorded by the latest event
+// on or before that day and is skipped while the subscription was ended.
+// Deleted subscriptions cannot be reconstructed because their events are no
+// longer linked to them, and amounts are converted at today's rates.
+func (s *SubscriptionService) historicalCharges(userID uint, start, end, today time.Time, targetCurrency string, converter CurrencyConverter) ([]historicalCharge, error) {
+	now := userNow(s.DB, userID)
+	var subs []model.Subscription
+	if err := s.DB.Where("user_id = ?", userID).Find(&subs).Error; err != nil {
+		return nil, err
+	}
+	for i := range subs {
+		presentSubscriptionForResponse(&subs[i], now)
+	}
+
+	var events []model.SubscriptionEvent
+	if err := s.DB.Where("user_id = ? AND subscription_id IS NOT NULL", userID).
+		Order("subscription_id ASC, created_at ASC, id ASC").
+		Find(&events).Error; err != nil {
+		return nil, err
+	}
+	eventsBySubscription := make(map[uint][]model.SubscriptionEvent)
+	for _, event := range events {
+		eventsBySubscription[*event.SubscriptionID] = append(eventsBySubscription[*event.SubscriptionID], event)
+	}
+
+	categoryLabels, err := s.reportCategoryLabels(userID)
+	if err != nil {
+		return nil, err
+	}
+	paymentMethodLabels, err := s.reportPaymentMethodLabels(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	bound := today.AddDate(0, 0, 1)
+	if end.Before(bound) {
+		bound = end
+	}
+
+	charges := make([]historicalCharge, 0)
+	for _, sub := range subs {
+		subEvents := eventsBySubscription[sub.ID]
+		states := subscriptionHistoryStates(sub, subEvents)
+		for _, date := range reconstructedChargeDates(sub, subEvents, start, bound) {
+			state := subscriptionStateOn(states, date)
+			if state.status != subscriptionStatusActive {
+				continue
+			}
+			priced := sub
+			priced.Amount, priced.Currency = state.amount, state.currency
+			priced.CategoryID, priced.Category, priced.PaymentMethodID = state.categoryID, state.category, state.paymentMethod
+			categoryKey, categoryLabel := reportCategoryKeyAndLabel(priced, categoryLabels)
+			paymentKey, paymentLabel := reportPaymentMethodKeyAndLabel(priced, paymentMethodLabels)
+			charges = append(charges, historicalCharge{
+				date:               date,
+				amountMinor:        money.ToMinorUnits(convertHistoricalAmount(state.amount, state.currency, targetCurrency, converter), targetCurrency),
+				subscription:       priced,
+				categoryKey:        categoryKey,
+				categoryLabel:      categoryLabel,
+				paymentMethodKey:   paymentKey,
+				paymentMethodLabel: paymentLabel,
+			})
+		}
+	}
+	return charges, nil
+}
+
+// subscriptionHistoryStates turns a subscription's events, oldest first, into
+// the states it passed through. Without a created event the first recorded
+// "previous" snapshot, or else the current row, stands in for the start.
+func subscriptionHistoryStates(sub model.Subscription, events []model.SubscriptionEvent) []subscriptionHistoryState {
+	created := normalizeDateUTC(sub.CreatedAt)
+	current := subscriptionHistoryState{
+		from:          created,
+		amount:        sub.Amount,
+		currency:      sub.Currency,
+		status:        normalizeStatus(sub.Status),
+		categoryID:    sub.CategoryID,
+		category:      sub.Category,
+		paymentMethod: sub.PaymentMethodID,
+	}
+	if len(events) == 0 {
+		return []subscriptionHistoryState{current}
+	}
+
+	states := make([]subscriptionHistoryState, 0, len(events)+1)
+	if first := events[0]; first.Type != subscriptionEventCreated && first.PreviousAmount != nil {
+		states = append(states, subscriptionHistoryState{
+			from:          created,
+			amount:        *first.PreviousAmount,
+			currency:      first.PreviousCurrency,
+			status:        normalizeStatus(first.PreviousStatus),
+			categoryID:    first.PreviousCategoryID,
+			category:      first.PreviousCategoryName,
+			paymentMethod: first.PreviousPaymentMethodID,
+		})
+	}
+	for _, event := range events {
+		if event.NewAmount == nil {
+			continue
+		}
+		from := normalizeDateUTC(event.CreatedAt)
+		if event.Type == subscriptionEventCreated && from.After(created) {
+			from = created
+		}
+		states = append(states, subscriptionHistoryState{
+			from:          from,
+			amount:        *event.NewAmount,
+			currency:      event.NewCurrency,
+			status:        normalizeStatus(event.NewStatus),
+			categoryID:    event.NewCategoryID,
+			category:      event.NewCategoryName,
+			paymentMethod: event.NewPaymentMethodID,
+		})
+	}
+	if len(states) == 0 {
+		return []subscriptionHistoryState{current}
+	}
+	// Charges before the first recorded state are priced like it.
+	states[0].from = time.Time{}
+	return states
+}
+
+func subscriptionStateOn(states []subscriptionHistoryState, date time.Time) subscriptionHistoryState {
+	state := states[0]
+	for _, candidate := range states[1:] {
+		if candidate.from.After(date) {
+			break
+		}
+		state = candidate
+	}
+	return state
+}
+
+// reconstructedChargeDates returns the past billing dates of sub within
+// [start, bound), walking its recurrence rules forward from the first billing
+// date it is known to have had.
+func reconstructedChargeDates(sub model.Subscription, events []model.SubscriptionEvent, start, bound time.Time) []time.Time {
+	anchor := sub.NextBillingDate
+	for _, event := range events {
+		if event.Type == subscriptionEventCreated && event.NewNextBillingDate != nil {
+			anchor = event.NewNextBillingDate
+			break
+		}
+		if event.PreviousNextBillingDate != nil {
+			anchor = event.PreviousNextBillingDate
+			break
+		}
+	}
+	if anchor == nil {
+		return nil
+	}
+	current := normalizeDateUTC(*anchor)
+
+	if normalizeBillingType(sub.BillingType) != billingTypeRecurring {
+		if !current.Before(start) && current.Before(bound) {
+			return []time.Time{current}
+		}
+		return nil
+	}
+	if sub.NextBillingDate != nil {
+		if next := normalizeDateUTC(*sub.NextBillingDate); next.Before(bound) {
+			bound = next
+		}
+	}
+	if !isRecurringScheduleValid(sub) {
+		return nil
+	}
+
+	var dates []time.Time
+	for i := 0; i < maxReconstructedCharges && current.Before(bound); i++ {
+		if !current.Before(start) {
+			dates = append(dates, current)
+		}
+		next, ok := nextRecurringOccurrenceAfter(sub, current)
+		if !ok || !next.After(current) {
+			break
+		}
+		current = normalizeDateUTC(next)
+	}
+	return dates
+}
+
+// parseSpendHistoryRange resolves the YYYY-MM bounds of a spend history. The
+// range may not end after the current month.
+func parseSpendHistoryRange(fromMonth, toMonth string, today time.Time) (time.Time, int, error) {
+	currentMonth := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC)
+	end := currentMonth
+	if raw := strings.TrimSpace(toMonth); raw != "" {
+		parsed, err := time.Parse("2006-01", raw)
+		if err != nil {
+			return time.Time{}, 0, ErrInvalidSpendHistoryRange
+		}
+		end = parsed
+	}
+	start := end.AddDate(0, -(defaultSpendHistoryMonths - 1), 0)
+	if raw := strings.TrimSpace(fromMonth); raw != "" {
+		parsed, err := time.Parse("2006-01", raw)
+		if err != nil {
+			return time.Time{}, 0, ErrInvalidSpendHistoryRange
+		}
+		start = parsed
+	}
+
+	months := monthsBetween(start, end) + 1
+	if end.After(currentMonth) || months < 1 || months > maxSpendHistoryMonths {
+		return time.Time{}, 0, ErrInvalidSpendHistoryRange
+	}
+	return start, months, nil
+}
+
+// monthsBetween counts whole calendar months from the month of start to the
+// month of date.
+func monthsBetween(start, date time.Time) int {
+	return (date.Year()-start.Year())*12 + int(date.Month()-start.Month())
+}
+
+func optionalPercentageDelta(previousMinor, currentMinor int64) *float64 {
+	if previousMinor <= 0 {
+		return nil
+	}
+	percentage := float64(currentMinor-previousMinor) / float64(previousMinor) * 100
+	return &percentage
+}
+
+func addSpendHistoryBreakdown(items map[string]*spendHistoryAccumulator, key, label string, amountMinor int64) {
+	item, ok := items[key]
+	if !ok {
+		item = &spendHistoryAccumulator{key: key, label: label}
+		items[key] = item
+	}
+	item.chargeCount++
+	item.amountMinor += amountMinor
+}
+
+func buildSpendHistoryBreakdown(items map[string]*spendHistoryAccumulator, totalMinor int64, currency string) []SpendHistoryBreakdownItem {
+	result := make([]SpendHistoryBreakdownItem, 0, len(items))
+	for _, item := range items {
+		percentage := 0.0
+		if totalMinor > 0 {
+			percentage = float64(item.amountMinor) / float64(totalMinor) * 100
+		}
+		result = append(result, SpendHistoryBreakdownItem{
+			Key:         item.key,
+			Label:       item.label,
+			ChargeCount: item.chargeCount,
+			Amount:      money.FromMinorUnits(item.amountMinor, currency),
+			Percentage:  percentage,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Amount == result[j].Amount {
+			return result[i].Label < result[j].Label
+		}
+		return result[i].Amount > result[j].Amount
+	})
+	return result
+}
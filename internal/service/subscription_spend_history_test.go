@@ -0,0 +1,90 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/shiroha/subdux/internal/pkg"
+)
+
+func TestSpendHistoryReconstructsPastChargesFromEvents(t *testing.T) {
+	restoreClock := pkg.SetNowForTest(mustDate(t, "2025-01-10"))
+	t.Cleanup(restoreClock)
+
+	db := newTestDB(t)
+	user := createTestUser(t, db)
+	service := NewSubscriptionService(db)
+
+	monthly := 1
+	sub, err := service.Create(user.ID, CreateSubscriptionInput{
+		Name:            "Stream",
+		Amount:          10,
+		Currency:        "USD",
+		Category:        "Video",
+		Status:          subscriptionStatusActive,
+		RenewalMode:     renewalModeAutoRenew,
+		BillingType:     billingTypeRecurring,
+		RecurrenceType:  recurrenceTypeInterval,
+		IntervalCount:   &monthly,
+		IntervalUnit:    intervalUnitMonth,
+		NextBillingDate: "2025-01-15",
+	})
+	if err != nil {
+		t.Fatalf("create subscription failed: %v", err)
+	}
+
+	restoreClock()
+	restoreClock = pkg.SetNowForTest(mustDate(t, "2025-06-20"))
+	raised := 12.0
+	if _, err := service.Update(user.ID, sub.ID, UpdateSubscriptionInput{Amount: &raised}); err != nil {
+		t.Fatalf("update subscription failed: %v", err)
+	}
+
+	restoreClock()
+	restoreClock = pkg.SetNowForTest(mustDate(t, "2026-03-05"))
+
+	history, err := service.GetSpendHistory(user.ID, "USD", nil, "2026-01", "2026-02")
+	if err != nil {
+		t.Fatalf("GetSpendHistory() error = %v", err)
+	}
+	if len(history.Months) != 2 || history.Months[0].Month != "2026-01" {
+		t.Fatalf("months = %+v, want 2026-01 and 2026-02", history.Months)
+	}
+	assertFloatEqual(t, history.Months[0].Amount, 12, "january amount")
+	assertFloatEqual(t, history.Months[0].PreviousYearAmount, 10, "january previous year amount")
+	assertFloatEqual(t, history.Total, 24, "total")
+	assertFloatEqual(t, history.PreviousYearTotal, 20, "previous year total")
+	if history.YearOverYearPercentage == nil {
+		t.Fatal("year over year percentage is nil")
+	}
+	assertFloatEqual(t, *history.YearOverYearPercentage, 20, "year over year percentage")
+	if len(history.Categories) != 1 || history.Categories[0].Label != "Video" || history.Categories[0].ChargeCount != 2 {
+		t.Fatalf("categories = %+v, want one Video entry with 2 charges", history.Categories)
+	}
+
+	// April through June 2025 at 10, July 2025 through February 2026 at 12;
+	// the March charge is still due.
+	history, err = service.GetSpendHistory(user.ID, "USD", nil, "", "")
+	if err != nil {
+		t.Fatalf("default GetSpendHistory() error = %v", err)
+	}
+	if history.From != "2025-04" || history.To != "2026-03" {
+		t.Fatalf("range = %s..%s, want 2025-04..2026-03", history.From, history.To)
+	}
+	assertFloatEqual(t, history.Total, 126, "default range total")
+
+	report, err := service.GetAnalyticsReport(user.ID, "USD", nil)
+	if err != nil {
+		t.Fatalf("GetAnalyticsReport() error = %v", err)
+	}
+	if len(report.PastSpend) != 12 {
+		t.Fatalf("past spend months = %d, want 12", len(report.PastSpend))
+	}
+
+	if _, err := service.GetSpendHistory(user.ID, "USD", nil, "2026-01", "2026-04"); !errors.Is(err, ErrInvalidSpendHistoryRange) {
+		t.Fatalf("future range error = %v, want ErrInvalidSpendHistoryRange", err)
+	}
+	if _, err := service.GetSpendHistory(user.ID, "USD", nil, "2022-01", "2026-01"); !errors.Is(err, ErrInvalidSpendHistoryRange) {
+		t.Fatalf("long range error = %v, want ErrInvalidSpendHistoryRange", err)
+	}
+}
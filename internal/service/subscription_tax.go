@@ -0,0 +1,292 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shiroha/subdux/internal/model"
+	"github.com/shiroha/subdux/internal/pkg/money"
+)
+
+const (
+	maxSubscriptionTaxRate = 100
+	maxTaxSummaryMonths    = 36
+)
+
+var ErrInvalidTaxSummaryRange = errors.New("tax summary range must be between 1 and 36 months with from before to")
+
+// SpendComponents splits an amount into the part that is reimbursable net of
+// tax, the tax itself, fixed fees and the gross total actually charged.
+type SpendComponents struct {
+	Net   float64 `json:"net"`
+	Tax   float64 `json:"tax"`
+	Fees  float64 `json:"fees"`
+	Gross float64 `json:"gross"`
+}
+
+// chargeComponents holds SpendComponents in minor units of one currency while
+// they are being summed. Gross is always Net + Tax + Fees.
+type chargeComponents struct {
+	net  int64
+	tax  int64
+	fees int64
+}
+
+func (c chargeComponents) gross() int64 {
+	return c.net + c.tax + c.fees
+}
+
+func (c *chargeComponents) add(other chargeComponents) {
+	c.net += other.net
+	c.tax += other.tax
+	c.fees += other.fees
+}
+
+func (c chargeComponents) times(count int64) chargeComponents {
+	return chargeComponents{net: c.net * count, tax: c.tax * count, fees: c.fees * count}
+}
+
+// scaled multiplies every component by factor, rounding each once.
+func (c chargeComponents) scaled(factor float64, currency string) chargeComponents {
+	return chargeComponents{
+		net:  money.Multiply(money.FromMinorUnits(c.net, currency), factor, currency),
+		tax:  money.Multiply(money.FromMinorUnits(c.tax, currency), factor, currency),
+		fees: money.Multiply(money.FromMinorUnits(c.fees, currency), factor, currency),
+	}
+}
+
+func (c chargeComponents) spend(currency string) SpendComponents {
+	return SpendComponents{
+		Net:   money.FromMinorUnits(c.net, currency),
+		Tax:   money.FromMinorUnits(c.tax, currency),
+		Fees:  money.FromMinorUnits(c.fees, currency),
+		Gross: money.FromMinorUnits(c.gross(), currency),
+	}
+}
+
+func validateSubscriptionTaxRate(rate *float64) error {
+	if rate == nil {
+		return nil
+	}
+	if math.IsNaN(*rate) || *rate < 0 || *rate > maxSubscriptionTaxRate {
+		return fmt.Errorf("tax_rate must be between 0 and %d", maxSubscriptionTaxRate)
+	}
+	return nil
+}
+
+func validateSubscriptionFee(field string, fee float64) error {
+	if math.IsNaN(fee) || math.IsInf(fee, 0) || fee < 0 {
+		return fmt.Errorf("%s must not be negative", field)
+	}
+	return nil
+}
+
+// subscriptionChargeComponents splits a single charge of sub into net, tax and
+// fees in minor units of targetCurrency. The split is made in the
+// subscription's own currency and each part is converted separately, so a
+// converted gross is the sum of its converted parts.
+func subscriptionChargeComponents(sub model.Subscription, targetCurrency string, converter CurrencyConverter) chargeComponents {
+	currency := sub.Currency
+	taxRate := 0.0
+	if sub.TaxRate != nil {
+		taxRate = *sub.TaxRate
+	}
+	net, tax := money.SplitTax(money.ToMinorUnits(sub.Amount, currency), taxRate, sub.TaxInclusive)
+	fees := money.ToMinorUnits(sub.FXFee, currency) + money.ToMinorUnits(sub.ProcessorFee, currency)
+
+	convert := func(units int64) int64 {
+		amount := money.FromMinorUnits(units, currency)
+		if converter != nil && !strings.EqualFold(currency, targetCurrency) {
+			amount = converter.Convert(amount, currency, targetCurrency)
+		}
+		return money.ToMinorUnits(amount, targetCurrency)
+	}
+	return chargeComponents{net: convert(net), tax: convert(tax), fees: convert(fees)}
+}
+
+type TaxSummary struct {
+	Currency    string               `json:"currency"`
+	MinorUnits  int                  `json:"minor_units"`
+	From        string               `json:"from"`
+	To          string               `json:"to"`
+	GeneratedAt time.Time            `json:"generated_at"`
+	Totals      TaxSummaryLine       `json:"totals"`
+	Periods     []TaxSummaryLine     `json:"periods"`
+	Categories  []TaxSummaryCategory `json:"categories"`
+}
+
+type TaxSummaryLine struct {
+	Period      string `json:"period,omitempty"`
+	ChargeCount int    `json:"charge_count"`
+	SpendComponents
+}
+
+type TaxSummaryCategory struct {
+	Key         string `json:"key"`
+	Label       string `json:"label"`
+	ChargeCount int    `json:"charge_count"`
+	SpendComponents
+}
+
+type taxSummaryAccumulator struct {
+	key         string
+	label       string
+	chargeCount int
+	components  chargeComponents
+}
+
+// GetTaxSummary totals the scheduled charges of a user's active subscriptions
+// for each calendar month from fromMonth through toMonth (inclusive, YYYY-MM)
+// and for each category, split into net, tax, fees and gross. Charges before
+// today are reconstructed from subscription events the same way as the spend
+// history, using each subscription's current tax rate and fees; charges from
+// today onwards follow the schedule, matching the monthly forecast in the
+// analytics report.
+func (s *SubscriptionService) GetTaxSummary(userID uint, targetCurrency string, converter CurrencyConverter, fromMonth, toMonth string) (*TaxSummary, error) {
+	now := userNow(s.DB, userID)
+	today := normalizeDateUTC(now)
+
+	if strings.TrimSpace(targetCurrency) == "" {
+		targetCurrency = "USD"
+	}
+	targetCurrency = strings.ToUpper(strings.TrimSpace(targetCurrency))
+
+	start, months, err := parseTaxSummaryRange(fromMonth, toMonth, today)
+	if err != nil {
+		return nil, err
+	}
+
+	var subs []model.Subscription
+	if err := s.DB.Where("user_id = ? AND status = ?", userID, subscriptionStatusActive).Find(&subs).Error; err != nil {
+		return nil, err
+	}
+	subs = presentActiveSubscriptions(subs, now)
+
+	categoryLabels, err := s.reportCategoryLabels(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &TaxSummary{
+		Currency:    targetCurrency,
+		MinorUnits:  money.MinorUnits(targetCurrency),
+		From:        start.Format("2006-01"),
+		To:          start.AddDate(0, months-1, 0).Format("2006-01"),
+		GeneratedAt: now,
+		Periods:     make([]TaxSummaryLine, 0, months),
+		Categories:  []TaxSummaryCategory{},
+	}
+
+	periodTotals := make([]chargeComponents, months)
+	periodCounts := make([]int, months)
+	var totals chargeComponents
+	totalCount := 0
+	categories := map[string]*taxSummaryAccumulator{}
+
+	record := func(index int, categoryKey, categoryLabel string, charged chargeComponents, occurrences int) {
+		periodTotals[index].add(charged)
+		periodCounts[index] += occurrences
+		totals.add(charged)
+		totalCount += occurrences
+
+		item, ok := categories[categoryKey]
+		if !ok {
+			item = &taxSummaryAccumulator{key: categoryKey, label: categoryLabel}
+			categories[categoryKey] = item
+		}
+		item.chargeCount += occurrences
+		item.components.add(charged)
+	}
+
+	end := start.AddDate(0, months, 0)
+	if start.Before(today) {
+		past, err := s.historicalCharges(userID, start, end, today, targetCurrency, converter)
+		if err != nil {
+			return nil, err
+		}
+		// Reconstructed charges stop before each subscription's next
+		// billing date, where the schedule below picks up.
+		for _, charge := range past {
+			charged := subscriptionChargeComponents(charge.subscription, targetCurrency, converter)
+			record(monthsBetween(start, charge.date), charge.categoryKey, charge.categoryLabel, charged, 1)
+		}
+	}
+
+	for _, sub := range subs {
+		perCharge := subscriptionChargeComponents(sub, targetCurrency, converter)
+		categoryKey, categoryLabel := reportCategoryKeyAndLabel(sub, categoryLabels)
+
+		for i := 0; i < months; i++ {
+			periodStart := start.AddDate(0, i, 0)
+			periodEnd := periodStart.AddDate(0, 1, 0)
+			if !periodEnd.After(today) {
+				continue
+			}
+			if periodStart.Before(today) {
+				periodStart = today
+			}
+			occurrences := len(subscriptionChargeDatesInRange(sub, periodStart, periodEnd))
+			if occurrences == 0 {
+				continue
+			}
+			record(i, categoryKey, categoryLabel, perCharge.times(int64(occurrences)), occurrences)
+		}
+	}
+
+	for i := 0; i < months; i++ {
+		summary.Periods = append(summary.Periods, TaxSummaryLine{
+			Period:          start.AddDate(0, i, 0).Format("2006-01"),
+			ChargeCount:     periodCounts[i],
+			SpendComponents: periodTotals[i].spend(targetCurrency),
+		})
+	}
+	summary.Totals = TaxSummaryLine{ChargeCount: totalCount, SpendComponents: totals.spend(targetCurrency)}
+
+	for _, item := range categories {
+		summary.Categories = append(summary.Categories, TaxSummaryCategory{
+			Key:             item.key,
+			Label:           item.label,
+			ChargeCount:     item.chargeCount,
+			SpendComponents: item.components.spend(targetCurrency),
+		})
+	}
+	sort.Slice(summary.Categories, func(i, j int) bool {
+		if summary.Categories[i].Gross == summary.Categories[j].Gross {
+			return summary.Categories[i].Label < summary.Categories[j].Label
+		}
+		return summary.Categories[i].Gross > summary.Categories[j].Gross
+	})
+
+	return summary, nil
+}
+
+// parseTaxSummaryRange resolves the YYYY-MM bounds of a tax summary. An empty
+// from defaults to the current month and an empty to to twelve months later.
+func parseTaxSummaryRange(fromMonth, toMonth string, today time.Time) (time.Time, int, error) {
+	start := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if raw := strings.TrimSpace(fromMonth); raw != "" {
+		parsed, err := time.Parse("2006-01", raw)
+		if err != nil {
+			return time.Time{}, 0, ErrInvalidTaxSummaryRange
+		}
+		start = parsed
+	}
+	end := start.AddDate(0, 11, 0)
+	if raw := strings.TrimSpace(toMonth); raw != "" {
+		parsed, err := time.Parse("2006-01", raw)
+		if err != nil {
+			return time.Time{}, 0, ErrInvalidTaxSummaryRange
+		}
+		end = parsed
+	}
+
+	months := (end.Year()-start.Year())*12 + int(end.Month()-start.Month()) + 1
+	if months < 1 || months > maxTaxSummaryMonths {
+		return time.Time{}, 0, ErrInvalidTaxSummaryRange
+	}
+	return start, months, nil
+}
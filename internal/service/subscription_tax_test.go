@@ -0,0 +1,169 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/shiroha/subdux/internal/pkg"
+)
+
+func TestSubscriptionTaxAndFeesFlowIntoSummaries(t *testing.T) {
+	restoreClock := pkg.SetNowForTest(mustDate(t, "2026-03-01"))
+	t.Cleanup(restoreClock)
+
+	db := newTestDB(t)
+	user := createTestUser(t, db)
+	service := NewSubscriptionService(db)
+
+	monthly := 1
+	vat := 19.0
+	salesTax := 10.0
+	if _, err := service.Create(user.ID, CreateSubscriptionInput{
+		Name:            "Design Suite",
+		Amount:          11.9,
+		TaxRate:         &vat,
+		TaxInclusive:    true,
+		ProcessorFee:    0.3,
+		Status:          subscriptionStatusActive,
+		RenewalMode:     renewalModeAutoRenew,
+		BillingType:     billingTypeRecurring,
+		RecurrenceType:  recurrenceTypeInterval,
+		IntervalCount:   &monthly,
+		IntervalUnit:    intervalUnitMonth,
+		NextBillingDate: "2026-03-15",
+		Category:        "Design",
+	}); err != nil {
+		t.Fatalf("create inclusive subscription failed: %v", err)
+	}
+	if _, err := service.Create(user.ID, CreateSubscriptionInput{
+		Name:            "Hosting",
+		Amount:          20,
+		TaxRate:         &salesTax,
+		FXFee:           0.5,
+		Status:          subscriptionStatusActive,
+		RenewalMode:     renewalModeAutoRenew,
+		BillingType:     billingTypeRecurring,
+		RecurrenceType:  recurrenceTypeInterval,
+		IntervalCount:   &monthly,
+		IntervalUnit:    intervalUnitMonth,
+		NextBillingDate: "2026-03-20",
+		Category:        "Infrastructure",
+	}); err != nil {
+		t.Fatalf("create exclusive subscription failed: %v", err)
+	}
+
+	summary, err := service.GetDashboardSummary(user.ID, "USD", nil)
+	if err != nil {
+		t.Fatalf("GetDashboardSummary() error = %v", err)
+	}
+	// 11.90 incl. 19% VAT = 10.00 net + 1.90 tax; 20.00 + 10% = 2.00 tax.
+	assertFloatEqual(t, summary.TotalMonthly, 31.9, "total_monthly")
+	assertFloatEqual(t, summary.MonthlyComponents.Net, 30, "monthly net")
+	assertFloatEqual(t, summary.MonthlyComponents.Tax, 3.9, "monthly tax")
+	assertFloatEqual(t, summary.MonthlyComponents.Fees, 0.8, "monthly fees")
+	assertFloatEqual(t, summary.MonthlyComponents.Gross, 34.7, "monthly gross")
+	assertFloatEqual(t, summary.YearlyComponents.Gross, 416.4, "yearly gross")
+
+	report, err := service.GetAnalyticsReport(user.ID, "USD", nil)
+	if err != nil {
+		t.Fatalf("GetAnalyticsReport() error = %v", err)
+	}
+	if report.KPIs.MonthlyComponents != summary.MonthlyComponents {
+		t.Fatalf("report monthly components = %+v, want %+v", report.KPIs.MonthlyComponents, summary.MonthlyComponents)
+	}
+
+	taxSummary, err := service.GetTaxSummary(user.ID, "USD", nil, "2026-03", "2026-05")
+	if err != nil {
+		t.Fatalf("GetTaxSummary() error = %v", err)
+	}
+	if got, want := len(taxSummary.Periods), 3; got != want {
+		t.Fatalf("periods = %d, want %d", got, want)
+	}
+	if got, want := taxSummary.Periods[1].Period, "2026-04"; got != want {
+		t.Fatalf("second period = %q, want %q", got, want)
+	}
+	assertFloatEqual(t, taxSummary.Periods[1].Tax, 3.9, "april tax")
+	assertFloatEqual(t, taxSummary.Totals.Net, 90, "total net")
+	assertFloatEqual(t, taxSummary.Totals.Gross, 104.1, "total gross")
+	if got, want := taxSummary.Totals.ChargeCount, 6; got != want {
+		t.Fatalf("total charge_count = %d, want %d", got, want)
+	}
+	if got, want := taxSummary.Categories[0].Label, "Infrastructure"; got != want {
+		t.Fatalf("top category = %q, want %q", got, want)
+	}
+	assertFloatEqual(t, taxSummary.Categories[0].Tax, 6, "infrastructure tax")
+}
+
+func TestSubscriptionTaxValidation(t *testing.T) {
+	db := newTestDB(t)
+	user := createTestUser(t, db)
+	service := NewSubscriptionService(db)
+
+	rate := 120.0
+	if _, err := service.Create(user.ID, CreateSubscriptionInput{
+		Name:            "Invalid",
+		Amount:          10,
+		TaxRate:         &rate,
+		NextBillingDate: "2026-03-15",
+	}); err == nil {
+		t.Fatal("Create() accepted a tax rate above 100")
+	}
+	if _, err := service.Create(user.ID, CreateSubscriptionInput{
+		Name:            "Invalid",
+		Amount:          10,
+		FXFee:           -1,
+		NextBillingDate: "2026-03-15",
+	}); err == nil {
+		t.Fatal("Create() accepted a negative fx_fee")
+	}
+
+	if _, err := service.GetTaxSummary(user.ID, "USD", nil, "2026-05", "2026-03"); !errors.Is(err, ErrInvalidTaxSummaryRange) {
+		t.Fatalf("GetTaxSummary() error = %v, want ErrInvalidTaxSummaryRange", err)
+	}
+}
+
+func TestTaxSummaryIncludesPastMonths(t *testing.T) {
+	restoreClock := pkg.SetNowForTest(mustDate(t, "2026-01-05"))
+	t.Cleanup(restoreClock)
+
+	db := newTestDB(t)
+	user := createTestUser(t, db)
+	service := NewSubscriptionService(db)
+
+	monthly := 1
+	salesTax := 10.0
+	if _, err := service.Create(user.ID, CreateSubscriptionInput{
+		Name:            "Hosting",
+		Amount:          20,
+		TaxRate:         &salesTax,
+		Status:          subscriptionStatusActive,
+		RenewalMode:     renewalModeAutoRenew,
+		BillingType:     billingTypeRecurring,
+		RecurrenceType:  recurrenceTypeInterval,
+		IntervalCount:   &monthly,
+		IntervalUnit:    intervalUnitMonth,
+		NextBillingDate: "2026-01-15",
+	}); err != nil {
+		t.Fatalf("create subscription failed: %v", err)
+	}
+
+	restoreClock()
+	restoreClock = pkg.SetNowForTest(mustDate(t, "2026-03-10"))
+
+	// January and February have been charged; the March charge is still due.
+	summary, err := service.GetTaxSummary(user.ID, "USD", nil, "2026-01", "2026-03")
+	if err != nil {
+		t.Fatalf("GetTaxSummary() error = %v", err)
+	}
+	for i, period := range summary.Periods {
+		if period.ChargeCount != 1 {
+			t.Fatalf("period %s charge_count = %d, want 1", period.Period, period.ChargeCount)
+		}
+		assertFloatEqual(t, period.Net, 20, summary.Periods[i].Period+" net")
+		assertFloatEqual(t, period.Tax, 2, summary.Periods[i].Period+" tax")
+	}
+	assertFloatEqual(t, summary.Totals.Gross, 66, "total gross")
+	if got, want := summary.Totals.ChargeCount, 3; got != want {
+		t.Fatalf("total charge_count = %d, want %d", got, want)
+	}
+}
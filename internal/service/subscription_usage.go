@@ -0,0 +1,324 @@
+package service
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shiroha/subdux/internal/model"
+	"github.com/shiroha/subdux/internal/pkg/money"
+	"gorm.io/gorm"
+)
+
+const (
+	usageSourceManual       = "manual"
+	usageSourceAPI          = "api"
+	usagePeriodWeek         = "week"
+	usagePeriodMonth        = "month"
+	usageWindowMonths       = 3
+	usageIdleDays           = defaultSavingsUnusedDays
+	defaultUsagePeriodCount = 6
+	maxUsagePeriodCount     = 24
+	reportLeastUsedLimit    = 8
+)
+
+var ErrInvalidUsagePeriod = errors.New("period must be week or month and periods between 1 and 24")
+
+type CheckInSubscriptionInput struct {
+	UsedAt string `json:"used_at"`
+	// Source is set by the caller from how the request was authenticated,
+	// never from the request body.
+	Source string `json:"-"`
+}
+
+// SubscriptionUsageStats summarises check-ins for one subscription. Uses are
+// counted over the last usageWindowMonths months and CostPerUse divides what
+// the subscription cost over that window by them.
+type SubscriptionUsageStats struct {
+	LastUsedAt        *string  `json:"last_used_at"`
+	IdleDays          *int     `json:"idle_days"`
+	Idle              bool     `json:"idle"`
+	IdleThresholdDays int      `json:"idle_threshold_days"`
+	WindowDays        int      `json:"window_days"`
+	UsesInWindow      int64    `json:"uses_in_window"`
+	TotalUses         int64    `json:"total_uses"`
+	CostPerUse        *float64 `json:"cost_per_use"`
+	Currency          string   `json:"currency"`
+}
+
+type SubscriptionUsageHistory struct {
+	SubscriptionID uint                     `json:"subscription_id"`
+	Period         string                   `json:"period"`
+	Stats          SubscriptionUsageStats   `json:"stats"`
+	Periods        []SubscriptionUsageCount `json:"periods"`
+}
+
+type SubscriptionUsageCount struct {
+	Start string `json:"start"`
+	Count int64  `json:"count"`
+}
+
+type ReportSubscriptionUsage struct {
+	ID            uint     `json:"id"`
+	Name          string   `json:"name"`
+	Icon          string   `json:"icon"`
+	MonthlyAmount float64  `json:"monthly_amount"`
+	UsesInWindow  int64    `json:"uses_in_window"`
+	CostPerUse    *float64 `json:"cost_per_use"`
+	LastUsedAt    *string  `json:"last_used_at"`
+	IdleDays      *int     `json:"idle_days"`
+	Idle          bool     `json:"idle"`
+}
+
+type usageCountRow struct {
+	SubscriptionID uint
+	Count          int64
+}
+
+// CheckIn logs one use of a subscription on input.UsedAt (today by default)
+// and advances its last-used date. The last-used date only moves forward, so
+// a late check-in for an older day does not hide more recent use.
+func (s *SubscriptionService) CheckIn(userID, id uint, input CheckInSubscriptionInput) (*model.Subscription, error) {
+	sub, err := s.GetByID(userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	today := normalizeDateUTC(userNow(s.DB, userID))
+	usedOn := today
+	parsed, err := parseOptionalDateString(input.UsedAt)
+	if err != nil {
+		return nil, err
+	}
+	if parsed != nil {
+		if parsed.After(today) {
+			return nil, errors.New("used_at must not be in the future")
+		}
+		usedOn = *parsed
+	}
+	source := input.Source
+	if source != usageSourceAPI {
+		source = usageSourceManual
+	}
+
+	if err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&model.SubscriptionUsage{
+			UserID:         userID,
+			SubscriptionID: id,
+			UsedOn:         usedOn,
+			Source:         source,
+		}).Error; err != nil {
+			return err
+		}
+		if sub.LastUsedAt != nil && !usedOn.After(normalizeDateUTC(*sub.LastUsedAt)) {
+			return nil
+		}
+		return tx.Model(&model.Subscription{}).
+			Where("id = ? AND user_id = ?", id, userID).
+			Update("last_used_at", usedOn).Error
+	}); err != nil {
+		return nil, err
+	}
+	return s.GetByID(userID, id)
+}
+
+// GetUsageHistory counts check-ins per week (starting Monday) or calendar
+// month for the last periodCount periods, oldest first, alongside the
+// subscription's usage stats in its own currency.
+func (s *SubscriptionService) GetUsageHistory(userID, id uint, period string, periodCount int) (*SubscriptionUsageHistory, error) {
+	period = strings.ToLower(strings.TrimSpace(period))
+	if period == "" {
+		period = usagePeriodMonth
+	}
+	if periodCount == 0 {
+		periodCount = defaultUsagePeriodCount
+	}
+	if (period != usagePeriodWeek && period != usagePeriodMonth) || periodCount < 1 || periodCount > maxUsagePeriodCount {
+		return nil, ErrInvalidUsagePeriod
+	}
+
+	sub, err := s.GetByID(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	today := normalizeDateUTC(userNow(s.DB, userID))
+	stats, err := s.subscriptionUsageStats(userID, *sub, today)
+	if err != nil {
+		return nil, err
+	}
+
+	starts := make([]time.Time, periodCount)
+	current := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if period == usagePeriodWeek {
+		current = today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+	}
+	for i := periodCount - 1; i >= 0; i-- {
+		starts[i] = current
+		if period == usagePeriodWeek {
+			current = current.AddDate(0, 0, -7)
+		} else {
+			current = current.AddDate(0, -1, 0)
+		}
+	}
+
+	var usedOn []time.Time
+	if err := s.DB.Model(&model.SubscriptionUsage{}).
+		Where("user_id = ? AND subscription_id = ? AND used_on >= ?", userID, id, starts[0]).
+		Pluck("used_on", &usedOn).Error; err != nil {
+		return nil, err
+	}
+
+	history := &SubscriptionUsageHistory{
+		SubscriptionID: id,
+		Period:         period,
+		Stats:          stats,
+		Periods:        make([]SubscriptionUsageCount, periodCount),
+	}
+	layout := "2006-01"
+	if period == usagePeriodWeek {
+		layout = "2006-01-02"
+	}
+	for i, start := range starts {
+		history.Periods[i].Start = start.Format(layout)
+	}
+	for _, date := range usedOn {
+		date = normalizeDateUTC(date)
+		index := sort.Search(periodCount, func(i int) bool { return starts[i].After(date) }) - 1
+		if index >= 0 {
+			history.Periods[index].Count++
+		}
+	}
+	return history, nil
+}
+
+// subscriptionUsageStats loads the check-in counts of one subscription and
+// prices them in the subscription's own currency.
+func (s *SubscriptionService) subscriptionUsageStats(userID uint, sub model.Subscription, today time.Time) (SubscriptionUsageStats, error) {
+	windowUses, totalUses, err := s.usageCounts(userID, []uint{sub.ID}, today)
+	if err != nil {
+		return SubscriptionUsageStats{}, err
+	}
+	currency := strings.ToUpper(strings.TrimSpace(sub.Currency))
+	var monthlyMinor int64
+	if subscriptionContributesToOngoingSpend(sub) {
+		monthlyMinor = money.Multiply(sub.Amount, subscriptionMonthlyFactor(sub), currency)
+	}
+	return buildUsageStats(sub, windowUses[sub.ID], totalUses[sub.ID], monthlyMinor, currency, today), nil
+}
+
+// usageCounts returns, per subscription, the check-ins inside the usage
+// window ending today and the all-time total.
+func (s *SubscriptionService) usageCounts(userID uint, subscriptionIDs []uint, today time.Time) (map[uint]int64, map[uint]int64, error) {
+	windowUses := make(map[uint]int64, len(subscriptionIDs))
+	totalUses := make(map[uint]int64, len(subscriptionIDs))
+	if len(subscriptionIDs) == 0 {
+		return windowUses, totalUses, nil
+	}
+
+	var rows []usageCountRow
+	if err := s.DB.Model(&model.SubscriptionUsage{}).
+		Select("subscription_id, COUNT(*) AS count").
+		Where("user_id = ? AND subscription_id IN ?", userID, subscriptionIDs).
+		Group("subscription_id").
+		Scan(&rows).Error; err != nil {
+		return nil, nil, err
+	}
+	for _, row := range rows {
+		totalUses[row.SubscriptionID] = row.Count
+	}
+
+	rows = nil
+	if err := s.DB.Model(&model.SubscriptionUsage{}).
+		Select("subscription_id, COUNT(*) AS count").
+		Where("user_id = ? AND subscription_id IN ? AND used_on > ? AND used_on <= ?",
+			userID, subscriptionIDs, usageWindowStart(today), today).
+		Group("subscription_id").
+		Scan(&rows).Error; err != nil {
+		return nil, nil, err
+	}
+	for _, row := range rows {
+		windowUses[row.SubscriptionID] = row.Count
+	}
+	return windowUses, totalUses, nil
+}
+
+func usageWindowStart(today time.Time) time.Time {
+	return today.AddDate(0, -usageWindowMonths, 0)
+}
+
+// buildUsageStats derives the idle signal and cost per use. monthlyMinor is
+// the subscription's monthly cost in minor units of currency; the window cost
+// is that amount times the window length in months.
+func buildUsageStats(sub model.Subscription, windowUses, totalUses, monthlyMinor int64, currency string, today time.Time) SubscriptionUsageStats {
+	stats := SubscriptionUsageStats{
+		IdleThresholdDays: usageIdleDays,
+		WindowDays:        int(today.Sub(usageWindowStart(today)).Hours() / 24),
+		UsesInWindow:      windowUses,
+		TotalUses:         totalUses,
+		Currency:          currency,
+	}
+	if sub.LastUsedAt != nil {
+		lastUsed := normalizeDateUTC(*sub.LastUsedAt)
+		formatted := lastUsed.Format("2006-01-02")
+		idleDays := int(today.Sub(lastUsed).Hours() / 24)
+		stats.LastUsedAt = &formatted
+		stats.IdleDays = &idleDays
+		stats.Idle = idleDays >= usageIdleDays
+	}
+	if windowUses > 0 && monthlyMinor > 0 {
+		windowCost := money.FromMinorUnits(monthlyMinor*usageWindowMonths, currency)
+		costPerUse := money.FromMinorUnits(money.Multiply(windowCost, 1/float64(windowUses), currency), currency)
+		stats.CostPerUse = &costPerUse
+	}
+	return stats
+}
+
+// reportLeastUsed ranks tracked subscriptions (those with at least one
+// check-in) by fewest uses in the usage window, then by highest monthly cost,
+// so the subscriptions least worth their price come first.
+func (s *SubscriptionService) reportLeastUsed(userID uint, subs []model.Subscription, monthlyMinor map[uint]int64, targetCurrency string, today time.Time) ([]ReportSubscriptionUsage, error) {
+	ids := make([]uint, 0, len(subs))
+	for _, sub := range subs {
+		if monthlyMinor[sub.ID] > 0 && sub.LastUsedAt != nil {
+			ids = append(ids, sub.ID)
+		}
+	}
+	windowUses, totalUses, err := s.usageCounts(userID, ids, today)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]ReportSubscriptionUsage, 0, len(ids))
+	for _, sub := range subs {
+		if monthlyMinor[sub.ID] <= 0 || sub.LastUsedAt == nil {
+			continue
+		}
+		stats := buildUsageStats(sub, windowUses[sub.ID], totalUses[sub.ID], monthlyMinor[sub.ID], targetCurrency, today)
+		items = append(items, ReportSubscriptionUsage{
+			ID:            sub.ID,
+			Name:          sub.Name,
+			Icon:          sub.Icon,
+			MonthlyAmount: money.FromMinorUnits(monthlyMinor[sub.ID], targetCurrency),
+			UsesInWindow:  stats.UsesInWindow,
+			CostPerUse:    stats.CostPerUse,
+			LastUsedAt:    stats.LastUsedAt,
+			IdleDays:      stats.IdleDays,
+			Idle:          stats.Idle,
+		})
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].UsesInWindow != items[j].UsesInWindow {
+			return items[i].UsesInWindow < items[j].UsesInWindow
+		}
+		if items[i].MonthlyAmount != items[j].MonthlyAmount {
+			return items[i].MonthlyAmount > items[j].MonthlyAmount
+		}
+		return items[i].Name < items[j].Name
+	})
+	if len(items) > reportLeastUsedLimit {
+		items = items[:reportLeastUsedLimit]
+	}
+	return items, nil
+}
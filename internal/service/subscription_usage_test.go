@@ -0,0 +1,100 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/shiroha/subdux/internal/model"
+	"github.com/shiroha/subdux/internal/pkg"
+)
+
+func TestUsageCheckInsDriveCostPerUseAndLeastUsedReport(t *testing.T) {
+	restoreClock := pkg.SetNowForTest(mustDate(t, "2026-06-10"))
+	t.Cleanup(restoreClock)
+
+	db := newTestDB(t)
+	user := createTestUser(t, db)
+	service := NewSubscriptionService(db)
+
+	monthly := 1
+	create := func(name string, amount float64) uint {
+		t.Helper()
+		sub, err := service.Create(user.ID, CreateSubscriptionInput{
+			Name:            name,
+			Amount:          amount,
+			Status:          subscriptionStatusActive,
+			RenewalMode:     renewalModeAutoRenew,
+			BillingType:     billingTypeRecurring,
+			RecurrenceType:  recurrenceTypeInterval,
+			IntervalCount:   &monthly,
+			IntervalUnit:    intervalUnitMonth,
+			NextBillingDate: "2026-06-20",
+		})
+		if err != nil {
+			t.Fatalf("create %q failed: %v", name, err)
+		}
+		return sub.ID
+	}
+	videoID := create("Video", 15)
+	newsID := create("News", 8)
+	create("Untracked", 50)
+
+	for _, date := range []string{"2026-04-02", "2026-05-20", "2026-06-01", "2026-06-08", "2026-06-09"} {
+		if _, err := service.CheckIn(user.ID, videoID, CheckInSubscriptionInput{UsedAt: date}); err != nil {
+			t.Fatalf("CheckIn(%s) error = %v", date, err)
+		}
+	}
+	if _, err := service.CheckIn(user.ID, newsID, CheckInSubscriptionInput{UsedAt: "2026-01-05", Source: usageSourceAPI}); err != nil {
+		t.Fatalf("CheckIn() error = %v", err)
+	}
+
+	var apiUses int64
+	if err := db.Model(&model.SubscriptionUsage{}).Where("source = ?", usageSourceAPI).Count(&apiUses).Error; err != nil {
+		t.Fatalf("count api uses failed: %v", err)
+	}
+	if apiUses != 1 {
+		t.Fatalf("api uses = %d, want 1", apiUses)
+	}
+
+	detail, err := service.GetDetail(user.ID, videoID)
+	if err != nil {
+		t.Fatalf("GetDetail() error = %v", err)
+	}
+	if detail.Usage.UsesInWindow != 5 || detail.Usage.TotalUses != 5 {
+		t.Fatalf("usage = %+v, want 5 uses in window and total", detail.Usage)
+	}
+	// Three months at 15.00 over five uses.
+	if detail.Usage.CostPerUse == nil {
+		t.Fatal("cost_per_use is nil")
+	}
+	assertFloatEqual(t, *detail.Usage.CostPerUse, 9, "cost_per_use")
+	if detail.Usage.Idle || detail.Usage.IdleDays == nil || *detail.Usage.IdleDays != 1 {
+		t.Fatalf("idle = %v, idle_days = %v, want active with 1 day", detail.Usage.Idle, detail.Usage.IdleDays)
+	}
+
+	history, err := service.GetUsageHistory(user.ID, videoID, "month", 3)
+	if err != nil {
+		t.Fatalf("GetUsageHistory() error = %v", err)
+	}
+	counts := map[string]int64{}
+	for _, period := range history.Periods {
+		counts[period.Start] = period.Count
+	}
+	if counts["2026-04"] != 1 || counts["2026-05"] != 1 || counts["2026-06"] != 3 {
+		t.Fatalf("monthly counts = %v, want 2026-04:1 2026-05:1 2026-06:3", counts)
+	}
+	if _, err := service.GetUsageHistory(user.ID, videoID, "day", 3); !errors.Is(err, ErrInvalidUsagePeriod) {
+		t.Fatalf("GetUsageHistory() error = %v, want ErrInvalidUsagePeriod", err)
+	}
+
+	report, err := service.GetAnalyticsReport(user.ID, "USD", nil)
+	if err != nil {
+		t.Fatalf("GetAnalyticsReport() error = %v", err)
+	}
+	if got, want := len(report.LeastUsed), 2; got != want {
+		t.Fatalf("least_used = %d items, want %d (untracked subscriptions are skipped)", got, want)
+	}
+	if report.LeastUsed[0].ID != newsID || !report.LeastUsed[0].Idle || report.LeastUsed[0].CostPerUse != nil {
+		t.Fatalf("least_used[0] = %+v, want idle News with no cost per use", report.LeastUsed[0])
+	}
+}
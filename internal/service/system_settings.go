@@ -2,6 +2,7 @@ package service
 
 import (
 	"errors"
+	"strconv"
 
 	"github.com/shiroha/subdux/internal/model"
 	"gorm.io/gorm"
@@ -31,6 +32,8 @@ func defaultAdminSystemSettings() *SystemSettings {
 		ExchangeRateSource:                   "auto",
 		AllowImageUpload:                     true,
 		MaxIconFileSize:                      65536,
+		MaxAttachmentFileSize:                defaultMaxAttachmentFileSize,
+		AttachmentQuotaPerUser:               defaultAttachmentQuotaPerUser,
 		IconProxyEnabled:                     true,
 		IconProxyDomainWhitelist:             defaultIconProxyDomainWhitelist,
 		MCPEnabled:                           false,
@@ -72,6 +75,36 @@ func defaultAdminSystemSettings() *SystemSettings {
 		OIDCAudience:                         "",
 		OIDCResource:                         "",
 		OIDCExtraAuthParams:                  "",
+		OIDCAllowedDomains:                   "",
+		OIDCRoleClaim:                        "",
+		OIDCAdminValues:                      "",
+		OIDCStatusClaim:                      "",
+		OIDCActiveValues:                     "",
+		LDAPEnabled:                          false,
+		LDAPURL:                              "",
+		LDAPStartTLS:                         false,
+		LDAPSkipTLSVerify:                    false,
+		LDAPBindDN:                           "",
+		LDAPBindPasswordSet:                  false,
+		LDAPBaseDN:                           "",
+		LDAPUserFilter:                       "(uid={username})",
+		LDAPUsernameAttribute:                "uid",
+		LDAPEmailAttribute:                   "mail",
+		LDAPGroupAttribute:                   "memberOf",
+		LDAPAdminGroups:                      "",
+		LDAPAllowedGroups:                    "",
+		LDAPAutoCreateUser:                   false,
+		LDAPTimeoutSeconds:                   10,
+		ProxyAuthEnabled:                     false,
+		ProxyAuthTrustedCIDRs:                "",
+		ProxyAuthUserHeader:                  "Remote-User",
+		ProxyAuthEmailHeader:                 "Remote-Email",
+		ProxyAuthGroupsHeader:                "Remote-Groups",
+		ProxyAuthAdminGroups:                 "",
+		ProxyAuthAutoCreateUser:              false,
+		AccountLockoutEnabled:                true,
+		AccountLockoutThreshold:              defaultAccountLockoutThreshold,
+		AccountLockoutDurationMinutes:        defaultAccountLockoutDurationMinutes,
 		BackupScheduleEnabled:                false,
 		BackupTimeOfDay:                      "03:00",
 		BackupIncludeAssets:                  false,
@@ -82,6 +115,11 @@ func defaultAdminSystemSettings() *SystemSettings {
 		BackupLastRunAt:                      "",
 		BackupLastStatus:                     "",
 		BackupLastError:                      "",
+		NotificationRetryMaxAttempts:         notificationOutboxDefaultMaxAttempts,
+		NotificationRetryBackoffMinutes:      defaultNotificationRetryBackoffMinutes,
+		AuditRetentionDays:                   defaultAuditRetentionDays,
+		AuditSinkType:                        AuditSinkNone,
+		AuditSyslogNetwork:                   "udp",
 	}
 }
 
@@ -132,6 +170,8 @@ var defaultSystemSettings = []model.SystemSetting{
 	{Key: "exchange_rate_source", Value: "auto"},
 	{Key: "allow_image_upload", Value: "true"},
 	{Key: "max_icon_file_size", Value: "65536"},
+	{Key: maxAttachmentFileSizeKey, Value: strconv.FormatInt(defaultMaxAttachmentFileSize, 10)},
+	{Key: attachmentQuotaPerUserKey, Value: strconv.FormatInt(defaultAttachmentQuotaPerUser, 10)},
 	{Key: "icon_proxy_enabled", Value: "true"},
 	{Key: "icon_proxy_domain_whitelist", Value: defaultIconProxyDomainWhitelist},
 	{Key: "mcp_enabled", Value: "false"},
@@ -173,6 +213,36 @@ var defaultSystemSettings = []model.SystemSetting{
 	{Key: "oidc_audience", Value: ""},
 	{Key: "oidc_resource", Value: ""},
 	{Key: "oidc_extra_auth_params", Value: ""},
+	{Key: "oidc_allowed_domains", Value: ""},
+	{Key: "oidc_role_claim", Value: ""},
+	{Key: "oidc_admin_values", Value: ""},
+	{Key: "oidc_status_claim", Value: ""},
+	{Key: "oidc_active_values", Value: ""},
+	{Key: "ldap_enabled", Value: "false"},
+	{Key: "ldap_url", Value: ""},
+	{Key: "ldap_start_tls", Value: "false"},
+	{Key: "ldap_skip_tls_verify", Value: "false"},
+	{Key: "ldap_bind_dn", Value: ""},
+	{Key: "ldap_bind_password", Value: ""},
+	{Key: "ldap_base_dn", Value: ""},
+	{Key: "ldap_user_filter", Value: "(uid={username})"},
+	{Key: "ldap_username_attribute", Value: "uid"},
+	{Key: "ldap_email_attribute", Value: "mail"},
+	{Key: "ldap_group_attribute", Value: "memberOf"},
+	{Key: "ldap_admin_groups", Value: ""},
+	{Key: "ldap_allowed_groups", Value: ""},
+	{Key: "ldap_auto_create_user", Value: "false"},
+	{Key: "ldap_timeout_seconds", Value: "10"},
+	{Key: "proxy_auth_enabled", Value: "false"},
+	{Key: "proxy_auth_trusted_cidrs", Value: ""},
+	{Key: "proxy_auth_user_header", Value: "Remote-User"},
+	{Key: "proxy_auth_email_header", Value: "Remote-Email"},
+	{Key: "proxy_auth_groups_header", Value: "Remote-Groups"},
+	{Key: "proxy_auth_admin_groups", Value: ""},
+	{Key: "proxy_auth_auto_create_user", Value: "false"},
+	{Key: "account_lockout_enabled", Value: "true"},
+	{Key: "account_lockout_threshold", Value: "10"},
+	{Key: "account_lockout_duration_minutes", Value: "15"},
 	{Key: backupScheduleEnabledKey, Value: "false"},
 	{Key: backupTimeOfDayKey, Value: "03:00"},
 	{Key: backupIncludeAssetsKey, Value: "false"},
@@ -183,6 +253,13 @@ var defaultSystemSettings = []model.SystemSetting{
 	{Key: backupLastRunAtKey, Value: ""},
 	{Key: backupLastStatusKey, Value: ""},
 	{Key: backupLastErrorKey, Value: ""},
+	{Key: notificationRetryMaxAttemptsKey, Value: strconv.Itoa(notificationOutboxDefaultMaxAttempts)},
+	{Key: notificationRetryBackoffMinutesKey, Value: defaultNotificationRetryBackoffMinutes},
+	{Key: auditRetentionDaysKey, Value: strconv.Itoa(defaultAuditRetentionDays)},
+	{Key: auditSinkTypeKey, Value: AuditSinkNone},
+	{Key: auditSyslogNetworkKey, Value: "udp"},
+	{Key: auditSyslogAddressKey, Value: ""},
+	{Key: auditFilePathKey, Value: ""},
 }
 
 func getSystemSettingValue(db *gorm.DB, key string, defaultValue string) (string, error) {
@@ -0,0 +1,308 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shiroha/subdux/internal/pkg/money"
+)
+
+// templateFunction is one entry of the vetted function set available to
+// notification templates. Functions only see their arguments; they cannot
+// reach the database, the network or any other template state.
+type templateFunction struct {
+	minArgs int
+	maxArgs int
+	call    func(env templateEnv, args []any) (any, error)
+	// check validates literal arguments at parse time so mistakes such as an
+	// unknown date style are reported with a position. Optional.
+	check func(args []templateOperand) error
+}
+
+// templateEnv is the read-only rendering context handed to functions.
+type templateEnv struct {
+	locale string
+}
+
+func (f templateFunction) arity() string {
+	plural := func(n int) string {
+		if n == 1 {
+			return "1 argument"
+		}
+		return fmt.Sprintf("%d arguments", n)
+	}
+	if f.minArgs == f.maxArgs {
+		return plural(f.minArgs)
+	}
+	return fmt.Sprintf("%d to %s", f.minArgs, plural(f.maxArgs))
+}
+
+var templateFunctions = map[string]templateFunction{
+	"formatMoney": {minArgs: 2, maxArgs: 2, call: templateFormatMoney},
+	"formatDate":  {minArgs: 1, maxArgs: 2, call: templateFormatDate, check: checkTemplateDateStyle},
+	"upper": {minArgs: 1, maxArgs: 1, call: func(_ templateEnv, args []any) (any, error) {
+		return strings.ToUpper(templateValueString(args[0])), nil
+	}},
+	"default": {minArgs: 2, maxArgs: 2, call: func(_ templateEnv, args []any) (any, error) {
+		if templateTruthy(args[1]) {
+			return args[1], nil
+		}
+		return args[0], nil
+	}},
+	"pluralize": {minArgs: 3, maxArgs: 3, call: templatePluralize},
+	"eq":        templateComparison(func(c int) bool { return c == 0 }),
+	"ne":        templateComparison(func(c int) bool { return c != 0 }),
+	"lt":        templateComparison(func(c int) bool { return c < 0 }),
+	"le":        templateComparison(func(c int) bool { return c <= 0 }),
+	"gt":        templateComparison(func(c int) bool { return c > 0 }),
+	"ge":        templateComparison(func(c int) bool { return c >= 0 }),
+	"not": {minArgs: 1, maxArgs: 1, call: func(_ templateEnv, args []any) (any, error) {
+		return !templateTruthy(args[0]), nil
+	}},
+	"and": {minArgs: 2, maxArgs: 2, call: func(_ templateEnv, args []any) (any, error) {
+		return templateTruthy(args[0]) && templateTruthy(args[1]), nil
+	}},
+	"or": {minArgs: 2, maxArgs: 2, call: func(_ templateEnv, args []any) (any, error) {
+		return templateTruthy(args[0]) || templateTruthy(args[1]), nil
+	}},
+}
+
+// templateLocaleFormat holds the locale-specific date styles and the currency
+// symbols that differ from templateCurrencySymbols in that locale.
+type templateLocaleFormat struct {
+	dateStyles      map[string]func(time.Time) string
+	currencySymbols map[string]string
+}
+
+var templateDateStyleNames = map[string]struct{}{
+	"iso": {}, "short": {}, "medium": {}, "long": {},
+}
+
+var cjkWeekdays = map[string][7]string{
+	localeZHCN: {"星期日", "星期一", "星期二", "星期三", "星期四", "星期五", "星期六"},
+	localeJA:   {"日曜日", "月曜日", "火曜日", "水曜日", "木曜日", "金曜日", "土曜日"},
+}
+
+func cjkDateStyles(locale string) map[string]func(time.Time) string {
+	return map[string]func(time.Time) string{
+		"short":  func(t time.Time) string { return fmt.Sprintf("%d月%d日", t.Month(), t.Day()) },
+		"medium": func(t time.Time) string { return fmt.Sprintf("%d年%d月%d日", t.Year(), t.Month(), t.Day()) },
+		"long": func(t time.Time) string {
+			return fmt.Sprintf("%d年%d月%d日%s", t.Year(), t.Month(), t.Day(), cjkWeekdays[locale][t.Weekday()])
+		},
+	}
+}
+
+var templateLocaleFormats = map[string]templateLocaleFormat{
+	localeEN: {
+		dateStyles: map[string]func(time.Time) string{
+			"short":  func(t time.Time) string { return t.Format("Jan 2") },
+			"medium": func(t time.Time) string { return t.Format("Jan 2, 2006") },
+			"long":   func(t time.Time) string { return t.Format("Monday, January 2, 2006") },
+		},
+		currencySymbols: map[string]string{"CNY": "CN¥"},
+	},
+	localeZHCN: {
+		dateStyles:      cjkDateStyles(localeZHCN),
+		currencySymbols: map[string]string{"USD": "US$", "JPY": "JP¥"},
+	},
+	localeJA: {
+		dateStyles:      cjkDateStyles(localeJA),
+		currencySymbols: map[string]string{"CNY": "CN¥", "JPY": "￥"},
+	},
+}
+
+func templateLocaleFormatFor(locale string) templateLocaleFormat {
+	if format, ok := templateLocaleFormats[locale]; ok {
+		return format
+	}
+	return templateLocaleFormats[defaultNotificationLocale]
+}
+
+var templateCurrencySymbols = map[string]string{
+	"USD": "$", "EUR": "€", "GBP": "£", "CNY": "¥", "JPY": "¥",
+	"KRW": "₩", "INR": "₹", "RUB": "₽", "TRY": "₺", "UAH": "₴",
+	"VND": "₫", "PHP": "₱", "THB": "฿", "ILS": "₪", "BRL": "R$",
+	"CAD": "C$", "AUD": "A$", "NZD": "NZ$", "HKD": "HK$", "SGD": "S$",
+	"TWD": "NT$", "MXN": "MX$",
+}
+
+// templateFormatMoney renders an amount with its currency symbol in the
+// template locale, e.g. "$1,234.50" or "US$1,234.50" for zh-CN. Currencies
+// without a known symbol keep their code as a suffix.
+func templateFormatMoney(env templateEnv, args []any) (any, error) {
+	amount, ok := templateValueNumber(args[0])
+	if !ok {
+		return nil, fmt.Errorf("amount %q is not a number", templateValueString(args[0]))
+	}
+	currency := strings.ToUpper(strings.TrimSpace(templateValueString(args[1])))
+
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	number := groupTemplateThousands(money.FormatDisplay(amount, currency))
+
+	if symbol, ok := templateLocaleFormatFor(env.locale).currencySymbols[currency]; ok {
+		return sign + symbol + number, nil
+	}
+	if symbol, ok := templateCurrencySymbols[currency]; ok {
+		return sign + symbol + number, nil
+	}
+	if currency == "" {
+		return sign + number, nil
+	}
+	return sign + number + " " + currency, nil
+}
+
+func groupTemplateThousands(number string) string {
+	integer, fraction, hasFraction := strings.Cut(number, ".")
+	if len(integer) <= 3 {
+		return number
+	}
+	var builder strings.Builder
+	lead := len(integer) % 3
+	if lead > 0 {
+		builder.WriteString(integer[:lead])
+	}
+	for i := lead; i < len(integer); i += 3 {
+		if builder.Len() > 0 {
+			builder.WriteByte(',')
+		}
+		builder.WriteString(integer[i : i+3])
+	}
+	if hasFraction {
+		builder.WriteByte('.')
+		builder.WriteString(fraction)
+	}
+	return builder.String()
+}
+
+// templateFormatDate reformats a 2006-01-02 or RFC 3339 date using one of the
+// named styles (iso, short, medium, long) of the template locale. The default
+// style is medium.
+func templateFormatDate(env templateEnv, args []any) (any, error) {
+	raw := strings.TrimSpace(templateValueString(args[0]))
+	if raw == "" {
+		return "", nil
+	}
+	style := "medium"
+	if len(args) > 1 {
+		style = templateValueString(args[1])
+	}
+	if _, ok := templateDateStyleNames[style]; !ok {
+		return nil, fmt.Errorf("unknown date style %q", style)
+	}
+
+	date, err := time.Parse("2006-01-02", raw)
+	if err != nil {
+		date, err = time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a date", raw)
+		}
+	}
+	if style == "iso" {
+		return date.Format("2006-01-02"), nil
+	}
+	return templateLocaleFormatFor(env.locale).dateStyles[style](date), nil
+}
+
+func checkTemplateDateStyle(args []templateOperand) error {
+	if len(args) < 2 || args[1].operandType != templateOperandLiteral {
+		return nil
+	}
+	style, ok := args[1].value.(string)
+	if !ok {
+		return errors.New("date style must be a string")
+	}
+	if _, ok := templateDateStyleNames[style]; !ok {
+		return fmt.Errorf("unknown date style %q (use iso, short, medium or long)", style)
+	}
+	return nil
+}
+
+// templatePluralize picks the singular or plural word for a count:
+// {{pluralize .DaysUntil "day" "days"}}.
+func templatePluralize(_ templateEnv, args []any) (any, error) {
+	count, ok := templateValueNumber(args[0])
+	if !ok {
+		return nil, fmt.Errorf("count %q is not a number", templateValueString(args[0]))
+	}
+	if math.Abs(count) == 1 {
+		return templateValueString(args[1]), nil
+	}
+	return templateValueString(args[2]), nil
+}
+
+// templateComparison compares numerically when both sides are numbers and as
+// strings otherwise.
+func templateComparison(accept func(int) bool) templateFunction {
+	return templateFunction{minArgs: 2, maxArgs: 2, call: func(_ templateEnv, args []any) (any, error) {
+		left, leftOK := templateValueNumber(args[0])
+		right, rightOK := templateValueNumber(args[1])
+		if leftOK && rightOK {
+			switch {
+			case left < right:
+				return accept(-1), nil
+			case left > right:
+				return accept(1), nil
+			default:
+				return accept(0), nil
+			}
+		}
+		return accept(strings.Compare(templateValueString(args[0]), templateValueString(args[1]))), nil
+	}}
+}
+
+func templateValueString(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func templateValueNumber(value any) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case float64:
+		return v, true
+	case string:
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return parsed, err == nil
+	default:
+		return 0, false
+	}
+}
+
+func templateTruthy(value any) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case string:
+		return v != ""
+	case int:
+		return v != 0
+	case float64:
+		return v != 0
+	case bool:
+		return v
+	default:
+		return true
+	}
+}
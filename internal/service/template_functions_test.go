@@ -0,0 +1,108 @@
+package service
+
+import "testing"
+
+func TestTemplateFormatMoney(t *testing.T) {
+	tests := []struct {
+		amount   any
+		currency string
+		want     string
+	}{
+		{amount: 1234.5, currency: "USD", want: "$1,234.50"},
+		{amount: 1500.0, currency: "jpy", want: "¥1,500"},
+		{amount: -3, currency: "CHF", want: "-3.00 CHF"},
+		{amount: 150000.0, currency: "IDR", want: "150,000 IDR"},
+		{amount: 1.234, currency: "BHD", want: "1.234 BHD"},
+		{amount: "9.9", currency: "EUR", want: "€9.90"},
+		{amount: 1234567.891, currency: "", want: "1,234,567.89"},
+	}
+	for _, tt := range tests {
+		got, err := templateFormatMoney(templateEnv{}, []any{tt.amount, tt.currency})
+		if err != nil {
+			t.Fatalf("templateFormatMoney(%v, %q) error = %v", tt.amount, tt.currency, err)
+		}
+		if got != tt.want {
+			t.Fatalf("templateFormatMoney(%v, %q) = %q, want %q", tt.amount, tt.currency, got, tt.want)
+		}
+	}
+
+	if _, err := templateFormatMoney(templateEnv{}, []any{"abc", "USD"}); err == nil {
+		t.Fatal("templateFormatMoney(non-number) error = nil, want error")
+	}
+}
+
+func TestTemplateFormatDate(t *testing.T) {
+	tests := []struct {
+		args []any
+		want string
+	}{
+		{args: []any{"2026-03-15"}, want: "Mar 15, 2026"},
+		{args: []any{"2026-03-15", "short"}, want: "Mar 15"},
+		{args: []any{"2026-03-15T08:30:00Z", "iso"}, want: "2026-03-15"},
+		{args: []any{""}, want: ""},
+	}
+	for _, tt := range tests {
+		got, err := templateFormatDate(templateEnv{}, tt.args)
+		if err != nil {
+			t.Fatalf("templateFormatDate(%v) error = %v", tt.args, err)
+		}
+		if got != tt.want {
+			t.Fatalf("templateFormatDate(%v) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+
+	if _, err := templateFormatDate(templateEnv{}, []any{"soon"}); err == nil {
+		t.Fatal("templateFormatDate(non-date) error = nil, want error")
+	}
+}
+
+func TestTemplateFormattingFollowsLocale(t *testing.T) {
+	tests := []struct {
+		locale string
+		name   string
+		args   []any
+		want   string
+	}{
+		{locale: localeZHCN, name: "formatDate", args: []any{"2026-03-15"}, want: "2026年3月15日"},
+		{locale: localeZHCN, name: "formatDate", args: []any{"2026-03-15", "long"}, want: "2026年3月15日星期日"},
+		{locale: localeJA, name: "formatDate", args: []any{"2026-03-15", "short"}, want: "3月15日"},
+		{locale: localeJA, name: "formatDate", args: []any{"2026-03-15", "long"}, want: "2026年3月15日日曜日"},
+		{locale: localeJA, name: "formatDate", args: []any{"2026-03-15", "iso"}, want: "2026-03-15"},
+		{locale: localeEN, name: "formatDate", args: []any{"2026-03-15", "long"}, want: "Sunday, March 15, 2026"},
+		{locale: localeZHCN, name: "formatMoney", args: []any{15.99, "USD"}, want: "US$15.99"},
+		{locale: localeZHCN, name: "formatMoney", args: []any{88.0, "CNY"}, want: "¥88.00"},
+		{locale: localeJA, name: "formatMoney", args: []any{1500, "JPY"}, want: "￥1,500"},
+		{locale: localeEN, name: "formatMoney", args: []any{88.0, "CNY"}, want: "CN¥88.00"},
+		{locale: "fr", name: "formatMoney", args: []any{88.0, "CNY"}, want: "CN¥88.00"},
+	}
+	for _, tt := range tests {
+		got, err := templateFunctions[tt.name].call(templateEnv{locale: tt.locale}, tt.args)
+		if err != nil {
+			t.Fatalf("%s(%v) in %s error = %v", tt.name, tt.args, tt.locale, err)
+		}
+		if got != tt.want {
+			t.Fatalf("%s(%v) in %s = %q, want %q", tt.name, tt.args, tt.locale, got, tt.want)
+		}
+	}
+}
+
+func TestTemplateComparisonsAndTruthiness(t *testing.T) {
+	call := func(name string, args ...any) any {
+		t.Helper()
+		got, err := templateFunctions[name].call(templateEnv{}, args)
+		if err != nil {
+			t.Fatalf("%s(%v) error = %v", name, args, err)
+		}
+		return got
+	}
+
+	if call("eq", 3, 3.0) != true || call("lt", 2, 10.0) != true || call("gt", "b", "a") != true {
+		t.Fatal("comparisons returned unexpected results")
+	}
+	if call("not", "") != true || call("and", 1, "") != false || call("or", 0, "x") != true {
+		t.Fatal("boolean helpers returned unexpected results")
+	}
+	if call("pluralize", 1, "day", "days") != "day" || call("pluralize", 0, "day", "days") != "days" {
+		t.Fatal("pluralize returned unexpected results")
+	}
+}
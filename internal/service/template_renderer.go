@@ -2,13 +2,21 @@ package service
 
 import (
 	"fmt"
-	"strconv"
 	"strings"
+	"time"
 )
 
 const (
 	// MaxRenderedLength is the maximum allowed length for rendered template output
 	MaxRenderedLength = 4000
+
+	// maxTemplateRangeItems caps how many list items a single range section
+	// expands, independently of the rendered length guard.
+	maxTemplateRangeItems = 100
+
+	// maxTemplateExecutionSteps caps the number of nodes and function calls
+	// evaluated while rendering one template.
+	maxTemplateExecutionSteps = 10000
 )
 
 // TemplateData holds all notification variables for template rendering
@@ -26,6 +34,84 @@ type TemplateData struct {
 	URL              string
 	Remark           string
 	UserEmail        string
+	Locale           string // Selects date and amount formatting; not a placeholder
+	// CustomFields maps custom field keys to values, each exposed as a
+	// {{.Custom_<key>}} placeholder.
+	CustomFields map[string]string
+}
+
+// DigestTemplateData holds the variables for a periodic digest message. The
+// Upcoming, Ending and Failed lists are iterated with {{range .List}} sections.
+type DigestTemplateData struct {
+	Frequency   string
+	PeriodStart string // Formatted as 2006-01-02
+	PeriodEnd   string // Last day of the period, formatted as 2006-01-02
+	TotalDue    float64
+	Currency    string
+	UserEmail   string
+	Upcoming    []DigestRenewalItem
+	Ending      []DigestRenewalItem
+	Failed      []DigestFailedDelivery
+	Locale      string // Selects date and amount formatting; not a placeholder
+}
+
+// DigestRenewalItem describes one upcoming charge or ending subscription in a digest.
+type DigestRenewalItem struct {
+	SubscriptionName string
+	Date             string // Formatted as 2006-01-02
+	Amount           float64
+	Currency         string
+	DaysUntil        int
+	RenewalMode      string
+	Category         string
+	PaymentMethod    string
+	URL              string
+}
+
+// DigestFailedDelivery describes one failed reminder delivery in a digest.
+type DigestFailedDelivery struct {
+	SubscriptionName string
+	ChannelType      string
+	NotifyDate       string // Formatted as 2006-01-02
+	FailedAt         string // RFC 3339 timestamp
+	Error            string
+}
+
+// PaymentMethodExpiryTemplateData holds the variables for a reminder that a
+// card is about to expire. Subscriptions lists what is still billed to it.
+type PaymentMethodExpiryTemplateData struct {
+	PaymentMethod string
+	Issuer        string
+	LastFour      string
+	ExpiresOn     string // Last valid day, formatted as 2006-01-02
+	DaysUntil     int
+	Subscriptions []PaymentMethodExpiryItem
+	Locale        string // Selects date and amount formatting; not a placeholder
+}
+
+// PaymentMethodExpiryItem describes one subscription billed to an expiring card.
+type PaymentMethodExpiryItem struct {
+	SubscriptionName string
+	Amount           float64
+	Currency         string
+	NextBillingDate  string // Formatted as 2006-01-02, empty when unknown
+}
+
+// NewLoginTemplateData holds the variables for a new sign-in alert.
+type NewLoginTemplateData struct {
+	Device     string // User agent, empty when the client sent none
+	IPAddress  string
+	Method     string // How the session was created, e.g. password or passkey
+	SignedInAt string // RFC 3339 timestamp
+	Locale     string // Selects date formatting; not a placeholder
+}
+
+// templateValues is the flattened form of template data: scalar placeholders
+// (string, int or float64) plus named lists whose items are themselves
+// templateValues.
+type templateValues struct {
+	variables map[string]any
+	lists     map[string][]templateValues
 }
 
 // TemplateRenderer renders templates with subscription data safely
@@ -41,20 +127,140 @@ func NewTemplateRenderer(validator *TemplateValidator) *TemplateRenderer {
 }
 
 // RenderTemplate renders a template string with the provided data.
-// It allows placeholder-only actions (e.g. {{.SubscriptionName}}) and
-// rejects unsupported directives/functions.
+// It allows placeholders, the vetted function set and if sections, and
+// rejects any other directive.
 func (tr *TemplateRenderer) RenderTemplate(tmplStr string, data TemplateData) (string, error) {
-	tokens, err := parseTemplateActions(tmplStr)
+	return renderTemplateWithSchema(tmplStr, reminderTemplateSchema, data.templateValues(), templateEnv{locale: data.Locale})
+}
+
+// RenderDigestTemplate renders a digest template. Besides placeholders it
+// expands {{range .Upcoming}}, {{range .Ending}} and {{range .Failed}} sections.
+func (tr *TemplateRenderer) RenderDigestTemplate(tmplStr string, data DigestTemplateData) (string, error) {
+	return renderTemplateWithSchema(tmplStr, digestTemplateSchema, data.templateValues(), templateEnv{locale: data.Locale})
+}
+
+// RenderPaymentMethodExpiryTemplate renders a card expiry reminder, expanding
+// {{range .Subscriptions}} sections.
+func (tr *TemplateRenderer) RenderPaymentMethodExpiryTemplate(tmplStr string, data PaymentMethodExpiryTemplateData) (string, error) {
+	return renderTemplateWithSchema(tmplStr, paymentMethodExpiryTemplateSchema, data.templateValues(), templateEnv{locale: data.Locale})
+}
+
+// RenderNewLoginTemplate renders a new sign-in alert.
+func (tr *TemplateRenderer) RenderNewLoginTemplate(tmplStr string, data NewLoginTemplateData) (string, error) {
+	return renderTemplateWithSchema(tmplStr, newLoginTemplateSchema, data.templateValues(), templateEnv{locale: data.Locale})
+}
+
+func renderTemplateWithSchema(tmplStr string, schema *templateSchema, values templateValues, env templateEnv) (string, error) {
+	nodes, err := parseTemplate(tmplStr, schema)
 	if err != nil {
 		return "", err
 	}
 
-	values := map[string]string{
+	state := &templateExecState{env: env}
+	if err := state.renderNodes(nodes, values); err != nil {
+		return "", err
+	}
+	return state.builder.String(), nil
+}
+
+// templateExecState carries the output and execution budget of one render.
+type templateExecState struct {
+	env     templateEnv
+	builder strings.Builder
+	steps   int
+}
+
+func (st *templateExecState) step(pos TemplatePosition) error {
+	st.steps++
+	if st.steps > maxTemplateExecutionSteps {
+		return fmt.Errorf("template render error at %s: execution exceeded %d steps", pos, maxTemplateExecutionSteps)
+	}
+	return nil
+}
+
+func (st *templateExecState) renderNodes(nodes []templateNode, values templateValues) error {
+	for _, node := range nodes {
+		if err := st.step(node.pos); err != nil {
+			return err
+		}
+		switch node.nodeType {
+		case templateNodeText:
+			st.builder.WriteString(node.text)
+		case templateNodeAction:
+			value, err := st.evalPipeline(node.pipe, values)
+			if err != nil {
+				return err
+			}
+			st.builder.WriteString(templateValueString(value))
+		case templateNodeIf:
+			condition, err := st.evalPipeline(node.pipe, values)
+			if err != nil {
+				return err
+			}
+			branch := node.elseBody
+			if templateTruthy(condition) {
+				branch = node.body
+			}
+			if err := st.renderNodes(branch, values); err != nil {
+				return err
+			}
+		case templateNodeRange:
+			items := values.lists[node.list]
+			if len(items) > maxTemplateRangeItems {
+				items = items[:maxTemplateRangeItems]
+			}
+			for _, item := range items {
+				if err := st.renderNodes(node.body, item); err != nil {
+					return err
+				}
+			}
+		}
+
+		// Check rendered length to prevent expansion attacks
+		if st.builder.Len() > MaxRenderedLength {
+			return fmt.Errorf("rendered template exceeds maximum length of %d characters", MaxRenderedLength)
+		}
+	}
+	return nil
+}
+
+func (st *templateExecState) evalPipeline(pipe templatePipeline, values templateValues) (any, error) {
+	var result any
+	for i, command := range pipe {
+		if err := st.step(command.pos); err != nil {
+			return nil, err
+		}
+		args := make([]any, 0, len(command.args)+1)
+		for _, operand := range command.args {
+			if operand.operandType == templateOperandField {
+				args = append(args, values.variables[operand.field])
+			} else {
+				args = append(args, operand.value)
+			}
+		}
+		if command.fn == "" {
+			result = args[0]
+			continue
+		}
+		if i > 0 {
+			args = append(args, result)
+		}
+		value, err := templateFunctions[command.fn].call(st.env, args)
+		if err != nil {
+			return nil, fmt.Errorf("template render error at %s: %s: %w", command.pos, command.fn, err)
+		}
+		result = value
+	}
+	return result, nil
+}
+
+func (data TemplateData) templateValues() templateValues {
+	values := templateValues{variables: map[string]any{
 		"SubscriptionName": data.SubscriptionName,
 		"BillingDate":      data.BillingDate,
-		"Amount":           strconv.FormatFloat(data.Amount, 'f', -1, 64),
+		"Amount":           data.Amount,
 		"Currency":         data.Currency,
-		"DaysUntil":        strconv.Itoa(data.DaysUntil),
+		"DaysUntil":        data.DaysUntil,
 		"EventType":        data.EventType,
 		"RenewalMode":      data.RenewalMode,
 		"Status":           data.Status,
@@ -63,22 +269,100 @@ func (tr *TemplateRenderer) RenderTemplate(tmplStr string, data TemplateData) (s
 		"URL":              data.URL,
 		"Remark":           data.Remark,
 		"UserEmail":        data.UserEmail,
+	}}
+	for key, value := range data.CustomFields {
+		values.variables[customFieldTemplatePrefix+key] = value
 	}
+	return values
+}
 
-	var builder strings.Builder
-	last := 0
-	for _, token := range tokens {
-		builder.WriteString(tmplStr[last:token.start])
-		builder.WriteString(values[token.variable])
-		last = token.end
+func (data DigestTemplateData) templateValues() templateValues {
+	upcoming := make([]templateValues, 0, len(data.Upcoming))
+	for _, item := range data.Upcoming {
+		upcoming = append(upcoming, item.templateValues())
+	}
+	ending := make([]templateValues, 0, len(data.Ending))
+	for _, item := range data.Ending {
+		ending = append(ending, item.templateValues())
+	}
+	failed := make([]templateValues, 0, len(data.Failed))
+	for _, item := range data.Failed {
+		failed = append(failed, templateValues{variables: map[string]any{
+			"SubscriptionName": item.SubscriptionName,
+			"ChannelType":      item.ChannelType,
+			"NotifyDate":       item.NotifyDate,
+			"FailedAt":         item.FailedAt,
+			"Error":            item.Error,
+		}})
 	}
-	builder.WriteString(tmplStr[last:])
-	output := builder.String()
 
-	// Check rendered length to prevent expansion attacks
-	if len(output) > MaxRenderedLength {
-		return "", fmt.Errorf("rendered template exceeds maximum length of %d characters", MaxRenderedLength)
+	return templateValues{
+		variables: map[string]any{
+			"Frequency":     data.Frequency,
+			"PeriodStart":   data.PeriodStart,
+			"PeriodEnd":     data.PeriodEnd,
+			"TotalDue":      data.TotalDue,
+			"Currency":      data.Currency,
+			"UpcomingCount": len(data.Upcoming),
+			"EndingCount":   len(data.Ending),
+			"FailedCount":   len(data.Failed),
+			"UserEmail":     data.UserEmail,
+		},
+		lists: map[string][]templateValues{
+			"Upcoming": upcoming,
+			"Ending":   ending,
+			"Failed":   failed,
+		},
 	}
+}
 
-	return output, nil
+func (item DigestRenewalItem) templateValues() templateValues {
+	return templateValues{variables: map[string]any{
+		"SubscriptionName": item.SubscriptionName,
+		"Date":             item.Date,
+		"Amount":           item.Amount,
+		"Currency":         item.Currency,
+		"DaysUntil":        item.DaysUntil,
+		"RenewalMode":      item.RenewalMode,
+		"Category":         item.Category,
+		"PaymentMethod":    item.PaymentMethod,
+		"URL":              item.URL,
+	}}
+}
+
+func (data PaymentMethodExpiryTemplateData) templateValues() templateValues {
+	subscriptions := make([]templateValues, 0, len(data.Subscriptions))
+	for _, item := range data.Subscriptions {
+		subscriptions = append(subscriptions, templateValues{variables: map[string]any{
+			"SubscriptionName": item.SubscriptionName,
+			"Amount":           item.Amount,
+			"Currency":         item.Currency,
+			"NextBillingDate":  item.NextBillingDate,
+		}})
+	}
+	return templateValues{
+		variables: map[string]any{
+			"PaymentMethod":     data.PaymentMethod,
+			"Issuer":            data.Issuer,
+			"LastFour":          data.LastFour,
+			"ExpiresOn":         data.ExpiresOn,
+			"DaysUntil":         data.DaysUntil,
+			"SubscriptionCount": len(data.Subscriptions),
+		},
+		lists: map[string][]templateValues{"Subscriptions": subscriptions},
+	}
+}
+
+func (data NewLoginTemplateData) templateValues() templateValues {
+	signedInTime := ""
+	if at, err := time.Parse(time.RFC3339, data.SignedInAt); err == nil {
+		signedInTime = at.UTC().Format("15:04") + " UTC"
+	}
+	return templateValues{variables: map[string]any{
+		"Device":       data.Device,
+		"IPAddress":    data.IPAddress,
+		"Method":       data.Method,
+		"SignedInAt":   data.SignedInAt,
+		"SignedInTime": signedInTime,
+	}}
 }
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"errors"
 	"strings"
 	"testing"
 )
@@ -28,8 +29,8 @@ func TestTemplateValidatorRejectsUnsupportedDirectiveAndPlaceholder(t *testing.T
 			wantErr: "unsupported directive",
 		},
 		{
-			name:    "reject control directive",
-			tmpl:    `{{if .SubscriptionName}}ok{{end}}`,
+			name:    "reject unsupported control directive",
+			tmpl:    `{{with .SubscriptionName}}ok{{end}}`,
 			wantErr: "unsupported directive",
 		},
 		{
@@ -94,3 +95,162 @@ func TestTemplateRendererRejectsDirectiveAndLargeOutput(t *testing.T) {
 		t.Fatalf("RenderTemplate() error = %q, want maximum length error", err.Error())
 	}
 }
+
+func TestTemplateValidatorDigestRangeSections(t *testing.T) {
+	validator := NewTemplateValidator()
+
+	valid := "{{.UpcomingCount}} due:{{range .Upcoming}} {{.SubscriptionName}} {{.Date}};{{end}}{{range .Failed}}{{.Error}}{{end}}"
+	if err := validator.ValidateTemplateForKind(notificationTemplateKindDigest, valid); err != nil {
+		t.Fatalf("ValidateTemplateForKind(digest) error = %v, want nil", err)
+	}
+
+	tests := []struct {
+		name    string
+		kind    string
+		tmpl    string
+		wantErr string
+	}{
+		{name: "range in reminder template", kind: notificationTemplateKindReminder, tmpl: `{{range .Upcoming}}x{{end}}`, wantErr: "unsupported directive"},
+		{name: "unknown list", kind: notificationTemplateKindDigest, tmpl: `{{range .Everything}}x{{end}}`, wantErr: "unsupported list"},
+		{name: "unclosed range", kind: notificationTemplateKindDigest, tmpl: `{{range .Ending}}{{.Date}}`, wantErr: "unclosed"},
+		{name: "stray end", kind: notificationTemplateKindDigest, tmpl: `{{.TotalDue}}{{end}}`, wantErr: "unexpected \"end\""},
+		{name: "outer placeholder inside range", kind: notificationTemplateKindDigest, tmpl: `{{range .Upcoming}}{{.TotalDue}}{{end}}`, wantErr: "unsupported placeholder"},
+		{name: "item placeholder outside range", kind: notificationTemplateKindDigest, tmpl: `{{.Date}}`, wantErr: "unsupported placeholder"},
+		{name: "unknown kind", kind: "weekly", tmpl: `{{.TotalDue}}`, wantErr: "invalid template kind"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validator.ValidateTemplateForKind(tt.kind, tt.tmpl)
+			if err == nil {
+				t.Fatalf("ValidateTemplateForKind() error = nil, want %q", tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("ValidateTemplateForKind() error = %q, want to contain %q", err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTemplateRendererExpandsDigestRanges(t *testing.T) {
+	renderer := NewTemplateRenderer(NewTemplateValidator())
+	data := DigestTemplateData{
+		TotalDue: 25.5,
+		Currency: "USD",
+		Upcoming: []DigestRenewalItem{
+			{SubscriptionName: "Netflix", Date: "2026-03-12", Amount: 15.5, Currency: "USD"},
+			{SubscriptionName: "Cloud", Date: "2026-03-14", Amount: 10, Currency: "USD"},
+		},
+	}
+
+	out, err := renderer.RenderDigestTemplate("{{.UpcomingCount}}/{{.TotalDue}} {{.Currency}}:{{range .Upcoming}} {{.SubscriptionName}}@{{.Date}}={{.Amount}}{{end}}|{{range .Ending}}never{{end}}{{.EndingCount}}", data)
+	if err != nil {
+		t.Fatalf("RenderDigestTemplate() error = %v, want nil", err)
+	}
+
+	const want = "2/25.5 USD: Netflix@2026-03-12=15.5 Cloud@2026-03-14=10|0"
+	if out != want {
+		t.Fatalf("RenderDigestTemplate() = %q, want %q", out, want)
+	}
+}
+
+func TestTemplateRendererLimitsDigestRangeOutput(t *testing.T) {
+	renderer := NewTemplateRenderer(NewTemplateValidator())
+	items := make([]DigestRenewalItem, maxTemplateRangeItems)
+	for i := range items {
+		items[i] = DigestRenewalItem{SubscriptionName: strings.Repeat("n", 50)}
+	}
+
+	_, err := renderer.RenderDigestTemplate("{{range .Upcoming}}{{.SubscriptionName}}{{end}}", DigestTemplateData{Upcoming: items})
+	if err == nil || !strings.Contains(err.Error(), "maximum length") {
+		t.Fatalf("RenderDigestTemplate() error = %v, want maximum length error", err)
+	}
+}
+
+func TestTemplateRendererConditionalsAndFunctions(t *testing.T) {
+	renderer := NewTemplateRenderer(NewTemplateValidator())
+	tmpl := `{{upper .SubscriptionName}} {{formatMoney .Amount .Currency}} due ` +
+		`{{if eq .DaysUntil 0}}today{{else if eq .DaysUntil 1}}tomorrow{{else}}in {{.DaysUntil}} {{pluralize .DaysUntil "day" "days"}}{{end}}` +
+		` ({{formatDate .BillingDate "long"}}). Note: {{.Remark | default "none"}}`
+
+	tests := []struct {
+		daysUntil int
+		remark    string
+		want      string
+	}{
+		{daysUntil: 0, want: "NETFLIX $1,234.50 due today (Sunday, March 15, 2026). Note: none"},
+		{daysUntil: 1, remark: "family", want: "NETFLIX $1,234.50 due tomorrow (Sunday, March 15, 2026). Note: family"},
+		{daysUntil: 3, want: "NETFLIX $1,234.50 due in 3 days (Sunday, March 15, 2026). Note: none"},
+	}
+	for _, tt := range tests {
+		out, err := renderer.RenderTemplate(tmpl, TemplateData{
+			SubscriptionName: "Netflix",
+			BillingDate:      "2026-03-15",
+			Amount:           1234.5,
+			Currency:         "USD",
+			DaysUntil:        tt.daysUntil,
+			Remark:           tt.remark,
+		})
+		if err != nil {
+			t.Fatalf("RenderTemplate() error = %v, want nil", err)
+		}
+		if out != tt.want {
+			t.Fatalf("RenderTemplate(days=%d) = %q, want %q", tt.daysUntil, out, tt.want)
+		}
+	}
+}
+
+func TestTemplateValidatorReportsErrorPositions(t *testing.T) {
+	validator := NewTemplateValidator()
+
+	tests := []struct {
+		name    string
+		tmpl    string
+		wantPos TemplatePosition
+		wantErr string
+	}{
+		{name: "unknown placeholder", tmpl: "Hello\n  {{.Nope}}", wantPos: TemplatePosition{Line: 2, Column: 5}, wantErr: "unsupported placeholder"},
+		{name: "unknown function", tmpl: "{{ printf .URL }}", wantPos: TemplatePosition{Line: 1, Column: 4}, wantErr: "unsupported directive"},
+		{name: "wrong arity", tmpl: "x {{formatMoney .Amount}}", wantPos: TemplatePosition{Line: 1, Column: 5}, wantErr: "formatMoney expects 2 arguments, got 1"},
+		{name: "unknown date style", tmpl: `{{formatDate .BillingDate "fancy"}}`, wantPos: TemplatePosition{Line: 1, Column: 3}, wantErr: "unknown date style"},
+		{name: "unclosed if", tmpl: "a\nb {{if .URL}}c", wantPos: TemplatePosition{Line: 2, Column: 3}, wantErr: `unclosed "if"`},
+		{name: "else outside if", tmpl: "{{else}}", wantPos: TemplatePosition{Line: 1, Column: 3}, wantErr: `unexpected "else"`},
+		{name: "operand after field", tmpl: "{{.URL .Remark}}", wantPos: TemplatePosition{Line: 1, Column: 8}, wantErr: "unexpected"},
+		{name: "dangling pipe", tmpl: "{{.URL |}}", wantPos: TemplatePosition{Line: 1, Column: 8}, wantErr: "missing command"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validator.ValidateTemplate(tt.tmpl)
+			var syntaxErr *TemplateSyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Fatalf("ValidateTemplate() error = %v, want *TemplateSyntaxError", err)
+			}
+			if syntaxErr.Position != tt.wantPos {
+				t.Fatalf("position = %s, want %s", syntaxErr.Position, tt.wantPos)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("ValidateTemplate() error = %q, want to contain %q", err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTemplateValidatorLimitsNestingDepth(t *testing.T) {
+	validator := NewTemplateValidator()
+	tmpl := strings.Repeat("{{if .URL}}", maxTemplateNestingDepth+1) + strings.Repeat("{{end}}", maxTemplateNestingDepth+1)
+	if err := validator.ValidateTemplate(tmpl); err == nil || !strings.Contains(err.Error(), "nested deeper") {
+		t.Fatalf("ValidateTemplate() error = %v, want nesting depth error", err)
+	}
+}
+
+func TestTemplateRendererLimitsExecutionSteps(t *testing.T) {
+	renderer := NewTemplateRenderer(NewTemplateValidator())
+	items := make([]DigestRenewalItem, maxTemplateRangeItems)
+	tmpl := "{{range .Upcoming}}" + strings.Repeat("{{.URL}}", 150) + "{{end}}"
+
+	_, err := renderer.RenderDigestTemplate(tmpl, DigestTemplateData{Upcoming: items})
+	if err == nil || !strings.Contains(err.Error(), "execution exceeded") {
+		t.Fatalf("RenderDigestTemplate() error = %v, want execution limit error", err)
+	}
+}
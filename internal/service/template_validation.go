@@ -3,7 +3,10 @@ package service
 import (
 	"errors"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 // Constants for template validation limits
@@ -11,6 +14,11 @@ const (
 	MaxTemplateLength = 2000
 )
 
+const (
+	notificationTemplateKindReminder = "reminder"
+	notificationTemplateKindDigest   = "digest"
+)
+
 var allowedTemplateVariables = map[string]struct{}{
 	"SubscriptionName": {},
 	"BillingDate":      {},
@@ -27,10 +35,101 @@ var allowedTemplateVariables = map[string]struct{}{
 	"UserEmail":        {},
 }
 
-type templateActionToken struct {
-	start    int
-	end      int
-	variable string
+var digestRenewalItemVariables = map[string]struct{}{
+	"SubscriptionName": {},
+	"Date":             {},
+	"Amount":           {},
+	"Currency":         {},
+	"DaysUntil":        {},
+	"RenewalMode":      {},
+	"Category":         {},
+	"PaymentMethod":    {},
+	"URL":              {},
+}
+
+var digestFailedItemVariables = map[string]struct{}{
+	"SubscriptionName": {},
+	"ChannelType":      {},
+	"NotifyDate":       {},
+	"FailedAt":         {},
+	"Error":            {},
+}
+
+// templateSchema describes which placeholders a template may reference and
+// which list sections it may iterate with {{range .List}}...{{end}}. Inside a
+// range body the item schema replaces the outer one.
+type templateSchema struct {
+	variables map[string]struct{}
+	lists     map[string]*templateSchema
+	// customFields also admits {{.Custom_<key>}} placeholders for any valid
+	// custom field key. Keys without a value render empty.
+	customFields bool
+}
+
+var reminderTemplateSchema = &templateSchema{variables: allowedTemplateVariables, customFields: true}
+
+var digestTemplateSchema = &templateSchema{
+	variables: map[string]struct{}{
+		"Frequency":     {},
+		"PeriodStart":   {},
+		"PeriodEnd":     {},
+		"TotalDue":      {},
+		"Currency":      {},
+		"UpcomingCount": {},
+		"EndingCount":   {},
+		"FailedCount":   {},
+		"UserEmail":     {},
+	},
+	lists: map[string]*templateSchema{
+		"Upcoming": {variables: digestRenewalItemVariables},
+		"Ending":   {variables: digestRenewalItemVariables},
+		"Failed":   {variables: digestFailedItemVariables},
+	},
+}
+
+var paymentMethodExpiryTemplateSchema = &templateSchema{
+	variables: map[string]struct{}{
+		"PaymentMethod":     {},
+		"Issuer":            {},
+		"LastFour":          {},
+		"ExpiresOn":         {},
+		"DaysUntil":         {},
+		"SubscriptionCount": {},
+	},
+	lists: map[string]*templateSchema{
+		"Subscriptions": {variables: map[string]struct{}{
+			"SubscriptionName": {},
+			"Amount":           {},
+			"Currency":         {},
+			"NextBillingDate":  {},
+		}},
+	},
+}
+
+var newLoginTemplateSchema = &templateSchema{
+	variables: map[string]struct{}{
+		"Device":       {},
+		"IPAddress":    {},
+		"Method":       {},
+		"SignedInAt":   {},
+		"SignedInTime": {},
+	},
+}
+
+func (schema *templateSchema) allowsCustomField(name string) bool {
+	key, ok := strings.CutPrefix(name, customFieldTemplatePrefix)
+	return ok && schema.customFields && customFieldKeyPattern.MatchString(key)
+}
+
+func templateSchemaForKind(kind string) (*templateSchema, error) {
+	switch kind {
+	case "", notificationTemplateKindReminder:
+		return reminderTemplateSchema, nil
+	case notificationTemplateKindDigest:
+		return digestTemplateSchema, nil
+	default:
+		return nil, fmt.Errorf("invalid template kind %q: must be 'reminder' or 'digest'", kind)
+	}
 }
 
 // TemplateValidator provides security-focused template validation
@@ -42,10 +141,23 @@ func NewTemplateValidator() *TemplateValidator {
 	return &TemplateValidator{}
 }
 
-// ValidateTemplate validates a user-provided template string.
-// It enforces length limits and allows only documented placeholders
-// in the form {{.VariableName}}.
+// ValidateTemplate validates a user-provided reminder template string.
+// It enforces length limits and allows only documented placeholders,
+// vetted functions and if/range sections. Syntax errors are returned as
+// *TemplateSyntaxError carrying the line and column of the offending action.
 func (v *TemplateValidator) ValidateTemplate(tmplStr string) error {
+	return v.ValidateTemplateForKind(notificationTemplateKindReminder, tmplStr)
+}
+
+// ValidateTemplateForKind validates a template against the placeholder set of
+// the given template kind. Digest templates may additionally iterate their
+// list sections.
+func (v *TemplateValidator) ValidateTemplateForKind(kind, tmplStr string) error {
+	schema, err := templateSchemaForKind(kind)
+	if err != nil {
+		return err
+	}
+
 	// Check template length
 	if len(tmplStr) == 0 {
 		return errors.New("template cannot be empty")
@@ -55,7 +167,7 @@ func (v *TemplateValidator) ValidateTemplate(tmplStr string) error {
 		return fmt.Errorf("template length %d exceeds maximum %d", len(tmplStr), MaxTemplateLength)
 	}
 
-	if _, err := parseTemplateActions(tmplStr); err != nil {
+	if _, err := parseTemplate(tmplStr, schema); err != nil {
 		return err
 	}
 
@@ -73,13 +185,149 @@ func (v *TemplateValidator) ValidateFormat(format string) error {
 	}
 }
 
-func parseTemplateActions(tmplStr string) ([]templateActionToken, error) {
-	tokens := make([]templateActionToken, 0)
-	for idx := 0; idx < len(tmplStr); {
+const (
+	// maxTemplateNestingDepth bounds how deeply if/range sections may nest.
+	maxTemplateNestingDepth = 8
+)
+
+type templateNodeType int
+
+const (
+	templateNodeText templateNodeType = iota
+	templateNodeAction
+	templateNodeIf
+	templateNodeRange
+)
+
+type templateNode struct {
+	nodeType templateNodeType
+	pos      TemplatePosition
+	text     string
+	pipe     templatePipeline
+	list     string
+	body     []templateNode
+	elseBody []templateNode
+}
+
+// templatePipeline is a sequence of commands joined by "|". The result of
+// each command is passed as the final argument of the next one.
+type templatePipeline []templateCommand
+
+// templateCommand is either a single operand or a call of a vetted function.
+type templateCommand struct {
+	pos  TemplatePosition
+	fn   string
+	args []templateOperand
+}
+
+type templateOperandType int
+
+const (
+	templateOperandField templateOperandType = iota
+	templateOperandLiteral
+)
+
+type templateOperand struct {
+	operandType templateOperandType
+	pos         TemplatePosition
+	field       string
+	value       any
+}
+
+// TemplatePosition is a 1-based line and column within a template.
+type TemplatePosition struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+func (p TemplatePosition) String() string {
+	return fmt.Sprintf("line %d, column %d", p.Line, p.Column)
+}
+
+// TemplateSyntaxError reports an invalid template together with the position
+// of the offending action, so editors can point at it.
+type TemplateSyntaxError struct {
+	Position TemplatePosition
+	Message  string
+}
+
+func (e *TemplateSyntaxError) Error() string {
+	return fmt.Sprintf("template parse error at %s: %s", e.Position, e.Message)
+}
+
+func templatePositionAt(tmplStr string, offset int) TemplatePosition {
+	if offset > len(tmplStr) {
+		offset = len(tmplStr)
+	}
+	prefix := tmplStr[:offset]
+	line := strings.Count(prefix, "\n") + 1
+	lineStart := strings.LastIndex(prefix, "\n") + 1
+	return TemplatePosition{Line: line, Column: utf8.RuneCountInString(prefix[lineStart:]) + 1}
+}
+
+type templateParseFrame struct {
+	node   templateNode
+	schema *templateSchema
+	inElse bool
+	// chained marks an "else if" frame, which closes together with its parent.
+	chained bool
+}
+
+type templateParser struct {
+	src   string
+	stack []templateParseFrame
+}
+
+func (p *templateParser) errorf(offset int, format string, args ...any) error {
+	return &TemplateSyntaxError{Position: templatePositionAt(p.src, offset), Message: fmt.Sprintf(format, args...)}
+}
+
+func (p *templateParser) top() *templateParseFrame {
+	return &p.stack[len(p.stack)-1]
+}
+
+func (p *templateParser) appendNode(node templateNode) {
+	top := p.top()
+	if top.inElse {
+		top.node.elseBody = append(top.node.elseBody, node)
+		return
+	}
+	top.node.body = append(top.node.body, node)
+}
+
+func (p *templateParser) push(frame templateParseFrame, offset int) error {
+	if len(p.stack) > maxTemplateNestingDepth {
+		return p.errorf(offset, "sections nested deeper than %d levels", maxTemplateNestingDepth)
+	}
+	p.stack = append(p.stack, frame)
+	return nil
+}
+
+func (p *templateParser) pop() {
+	for {
+		finished := p.stack[len(p.stack)-1]
+		p.stack = p.stack[:len(p.stack)-1]
+		p.appendNode(finished.node)
+		if !finished.chained {
+			return
+		}
+	}
+}
+
+// parseTemplate parses a template in the sandboxed notification template
+// language: {{.Field}} placeholders, pipelines over the vetted function set,
+// {{if}}/{{else if}}/{{else}} and {{range .List}} sections closed by {{end}}.
+// Fields are checked against schema; inside a range the list item schema
+// applies.
+func parseTemplate(tmplStr string, schema *templateSchema) ([]templateNode, error) {
+	p := &templateParser{src: tmplStr, stack: []templateParseFrame{{schema: schema}}}
+
+	idx := 0
+	for idx < len(tmplStr) {
 		openOffset := strings.Index(tmplStr[idx:], "{{")
 		closeOffset := strings.Index(tmplStr[idx:], "}}")
 		if closeOffset != -1 && (openOffset == -1 || closeOffset < openOffset) {
-			return nil, errors.New("template parse error: unexpected closing delimiter \"}}\"")
+			return nil, p.errorf(idx+closeOffset, "unexpected closing delimiter \"}}\"")
 		}
 		if openOffset == -1 {
 			break
@@ -88,44 +336,298 @@ func parseTemplateActions(tmplStr string) ([]templateActionToken, error) {
 		open := idx + openOffset
 		actionCloseOffset := strings.Index(tmplStr[open+2:], "}}")
 		if actionCloseOffset == -1 {
-			return nil, errors.New("template parse error: unclosed template action")
+			return nil, p.errorf(open, "unclosed template action")
+		}
+		if open > idx {
+			p.appendNode(templateNode{nodeType: templateNodeText, text: tmplStr[idx:open]})
 		}
 
 		close := open + 2 + actionCloseOffset
-		action := strings.TrimSpace(tmplStr[open+2 : close])
-		varName, err := parseTemplateVariable(action)
-		if err != nil {
+		if err := p.parseAction(open, close); err != nil {
 			return nil, err
 		}
-
-		tokens = append(tokens, templateActionToken{
-			start:    open,
-			end:      close + 2,
-			variable: varName,
-		})
 		idx = close + 2
 	}
+	if len(p.stack) > 1 {
+		i := len(p.stack) - 1
+		for p.stack[i].chained {
+			i--
+		}
+		open := p.stack[i]
+		keyword := "if"
+		if open.node.nodeType == templateNodeRange {
+			keyword = "range ." + open.node.list
+		}
+		return nil, &TemplateSyntaxError{Position: open.node.pos, Message: fmt.Sprintf("unclosed %q", keyword)}
+	}
+	if idx < len(tmplStr) {
+		p.appendNode(templateNode{nodeType: templateNodeText, text: tmplStr[idx:]})
+	}
 
-	return tokens, nil
+	return p.stack[0].node.body, nil
+}
+
+func (p *templateParser) parseAction(open, close int) error {
+	tokens, err := p.lexAction(open+2, close)
+	if err != nil {
+		return err
+	}
+	if len(tokens) == 0 {
+		return p.errorf(open, "empty template action is not allowed")
+	}
+
+	pos := templatePositionAt(p.src, open)
+	current := p.top().schema
+	first := tokens[0]
+	if first.tokenType != templateTokenIdent {
+		pipe, err := p.parsePipeline(tokens, current)
+		if err != nil {
+			return err
+		}
+		p.appendNode(templateNode{nodeType: templateNodeAction, pos: pos, pipe: pipe})
+		return nil
+	}
+
+	switch first.text {
+	case "if":
+		pipe, err := p.parsePipeline(tokens[1:], current)
+		if err != nil {
+			return err
+		}
+		if len(pipe) == 0 {
+			return p.errorf(first.offset, "missing condition after \"if\"")
+		}
+		return p.push(templateParseFrame{node: templateNode{nodeType: templateNodeIf, pos: pos, pipe: pipe}, schema: current}, open)
+	case "else":
+		top := p.top()
+		if len(p.stack) == 1 || top.node.nodeType != templateNodeIf || top.inElse {
+			return p.errorf(first.offset, "unexpected \"else\"")
+		}
+		top.inElse = true
+		if len(tokens) == 1 {
+			return nil
+		}
+		if tokens[1].tokenType != templateTokenIdent || tokens[1].text != "if" {
+			return p.errorf(tokens[1].offset, "unexpected %q after \"else\"", tokens[1].text)
+		}
+		pipe, err := p.parsePipeline(tokens[2:], current)
+		if err != nil {
+			return err
+		}
+		if len(pipe) == 0 {
+			return p.errorf(tokens[1].offset, "missing condition after \"else if\"")
+		}
+		return p.push(templateParseFrame{node: templateNode{nodeType: templateNodeIf, pos: pos, pipe: pipe}, schema: current, chained: true}, open)
+	case "end":
+		if len(p.stack) == 1 {
+			return p.errorf(first.offset, "unexpected \"end\"")
+		}
+		if len(tokens) > 1 {
+			return p.errorf(tokens[1].offset, "unexpected %q after \"end\"", tokens[1].text)
+		}
+		p.pop()
+		return nil
+	case "range":
+		if len(current.lists) == 0 {
+			return p.errorf(first.offset, "unsupported directive %q", "range")
+		}
+		if len(tokens) != 2 || tokens[1].tokenType != templateTokenField {
+			return p.errorf(first.offset, "\"range\" expects a single list such as \"range .%s\"", firstTemplateListName(current))
+		}
+		listName := tokens[1].text
+		itemSchema, ok := current.lists[listName]
+		if !ok {
+			return p.errorf(tokens[1].offset, "unsupported list %q", "."+listName)
+		}
+		return p.push(templateParseFrame{node: templateNode{nodeType: templateNodeRange, pos: pos, list: listName}, schema: itemSchema}, open)
+	default:
+		pipe, err := p.parsePipeline(tokens, current)
+		if err != nil {
+			return err
+		}
+		p.appendNode(templateNode{nodeType: templateNodeAction, pos: pos, pipe: pipe})
+		return nil
+	}
 }
 
-func parseTemplateVariable(action string) (string, error) {
-	if action == "" {
-		return "", errors.New("template parse error: empty template action is not allowed")
+func firstTemplateListName(schema *templateSchema) string {
+	names := make([]string, 0, len(schema.lists))
+	for name := range schema.lists {
+		names = append(names, name)
 	}
-	if !strings.HasPrefix(action, ".") {
-		return "", fmt.Errorf("template parse error: unsupported directive %q", action)
+	sort.Strings(names)
+	return names[0]
+}
+
+func (p *templateParser) parsePipeline(tokens []templateToken, schema *templateSchema) (templatePipeline, error) {
+	var pipe templatePipeline
+	start := 0
+	for i := 0; i <= len(tokens); i++ {
+		if i < len(tokens) && tokens[i].tokenType != templateTokenPipe {
+			continue
+		}
+		segment := tokens[start:i]
+		if len(segment) == 0 {
+			if i < len(tokens) {
+				return nil, p.errorf(tokens[i].offset, "missing command before \"|\"")
+			}
+			if len(pipe) > 0 {
+				return nil, p.errorf(tokens[i-1].offset, "missing command after \"|\"")
+			}
+			return pipe, nil
+		}
+		command, err := p.parseCommand(segment, schema, len(pipe) > 0)
+		if err != nil {
+			return nil, err
+		}
+		pipe = append(pipe, command)
+		start = i + 1
 	}
-	if strings.ContainsAny(action, " \t\r\n|()") {
-		return "", fmt.Errorf("template parse error: unsupported directive %q", action)
+	return pipe, nil
+}
+
+func (p *templateParser) parseCommand(tokens []templateToken, schema *templateSchema, piped bool) (templateCommand, error) {
+	first := tokens[0]
+	command := templateCommand{pos: templatePositionAt(p.src, first.offset)}
+	operands := tokens
+	if first.tokenType == templateTokenIdent {
+		fn, ok := templateFunctions[first.text]
+		if !ok {
+			return command, p.errorf(first.offset, "unsupported directive %q", first.text)
+		}
+		command.fn = first.text
+		operands = tokens[1:]
+		argCount := len(operands)
+		if piped {
+			argCount++
+		}
+		if argCount < fn.minArgs || argCount > fn.maxArgs {
+			return command, p.errorf(first.offset, "%s expects %s, got %d", first.text, fn.arity(), argCount)
+		}
+	} else if piped {
+		return command, p.errorf(first.offset, "only functions may follow \"|\"")
+	} else if len(tokens) > 1 {
+		return command, p.errorf(tokens[1].offset, "unexpected %q after operand", tokens[1].text)
 	}
 
-	varName := strings.TrimPrefix(action, ".")
-	if varName == "" {
-		return "", errors.New("template parse error: empty placeholder is not allowed")
+	for _, token := range operands {
+		operand, err := p.parseOperand(token, schema)
+		if err != nil {
+			return command, err
+		}
+		command.args = append(command.args, operand)
 	}
-	if _, ok := allowedTemplateVariables[varName]; !ok {
-		return "", fmt.Errorf("template parse error: unsupported placeholder %q", action)
+	if command.fn != "" && templateFunctions[command.fn].check != nil {
+		if err := templateFunctions[command.fn].check(command.args); err != nil {
+			return command, p.errorf(first.offset, "%s: %v", command.fn, err)
+		}
 	}
-	return varName, nil
+	return command, nil
+}
+
+func (p *templateParser) parseOperand(token templateToken, schema *templateSchema) (templateOperand, error) {
+	operand := templateOperand{pos: templatePositionAt(p.src, token.offset)}
+	switch token.tokenType {
+	case templateTokenField:
+		if _, ok := schema.variables[token.text]; !ok && !schema.allowsCustomField(token.text) {
+			return operand, p.errorf(token.offset, "unsupported placeholder %q", "."+token.text)
+		}
+		operand.operandType = templateOperandField
+		operand.field = token.text
+	case templateTokenString:
+		operand.operandType = templateOperandLiteral
+		operand.value = token.text
+	case templateTokenNumber:
+		value, err := strconv.ParseFloat(token.text, 64)
+		if err != nil {
+			return operand, p.errorf(token.offset, "invalid number %q", token.text)
+		}
+		operand.operandType = templateOperandLiteral
+		operand.value = value
+	default:
+		return operand, p.errorf(token.offset, "unsupported directive %q", token.text)
+	}
+	return operand, nil
+}
+
+type templateTokenType int
+
+const (
+	templateTokenField templateTokenType = iota
+	templateTokenIdent
+	templateTokenString
+	templateTokenNumber
+	templateTokenPipe
+)
+
+type templateToken struct {
+	tokenType templateTokenType
+	text      string
+	offset    int
+}
+
+// lexAction splits the action between start and end (byte offsets into the
+// template) into tokens. Offsets are kept so errors point at the exact token.
+func (p *templateParser) lexAction(start, end int) ([]templateToken, error) {
+	var tokens []templateToken
+	i := start
+	for i < end {
+		c := p.src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			i++
+		case c == '|':
+			tokens = append(tokens, templateToken{tokenType: templateTokenPipe, text: "|", offset: i})
+			i++
+		case c == '.':
+			j := i + 1
+			for j < end && isTemplateIdentByte(p.src[j]) {
+				j++
+			}
+			if j == i+1 {
+				return nil, p.errorf(i, "empty placeholder is not allowed")
+			}
+			tokens = append(tokens, templateToken{tokenType: templateTokenField, text: p.src[i+1 : j], offset: i})
+			i = j
+		case c == '"':
+			j := i + 1
+			for j < end && p.src[j] != '"' {
+				if p.src[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= end {
+				return nil, p.errorf(i, "unterminated string")
+			}
+			value, err := strconv.Unquote(p.src[i : j+1])
+			if err != nil {
+				return nil, p.errorf(i, "invalid string %s", p.src[i:j+1])
+			}
+			tokens = append(tokens, templateToken{tokenType: templateTokenString, text: value, offset: i})
+			i = j + 1
+		case c == '-' || (c >= '0' && c <= '9'):
+			j := i + 1
+			for j < end && (p.src[j] == '.' || (p.src[j] >= '0' && p.src[j] <= '9')) {
+				j++
+			}
+			tokens = append(tokens, templateToken{tokenType: templateTokenNumber, text: p.src[i:j], offset: i})
+			i = j
+		case isTemplateIdentByte(c):
+			j := i + 1
+			for j < end && isTemplateIdentByte(p.src[j]) {
+				j++
+			}
+			tokens = append(tokens, templateToken{tokenType: templateTokenIdent, text: p.src[i:j], offset: i})
+			i = j
+		default:
+			_, size := utf8.DecodeRuneInString(p.src[i:end])
+			return nil, p.errorf(i, "unexpected character %q", p.src[i:i+size])
+		}
+	}
+	return tokens, nil
+}
+
+func isTemplateIdentByte(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
 }
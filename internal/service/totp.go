@@ -113,7 +113,7 @@ func (s *TOTPService) Disable(userID uint, password string, code string) error {
 		return errors.New("user not found")
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+	if _, err := verifyAccountPassword(s.DB, &user, password); err != nil {
 		return errors.New("invalid password")
 	}
 
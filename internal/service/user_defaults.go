@@ -133,7 +133,7 @@ func seedDefaultPreference(tx *gorm.DB, userID uint) error {
 func seedDefaultNotificationTemplate(tx *gorm.DB, userID uint) error {
 	var count int64
 	if err := tx.Model(&model.NotificationTemplate{}).
-		Where("user_id = ? AND channel_type IS NULL", userID).
+		Where("user_id = ? AND kind = ? AND channel_type IS NULL", userID, notificationTemplateKindReminder).
 		Count(&count).Error; err != nil {
 		return err
 	}
@@ -143,6 +143,7 @@ func seedDefaultNotificationTemplate(tx *gorm.DB, userID uint) error {
 
 	tmpl := model.NotificationTemplate{
 		UserID:      userID,
+		Kind:        notificationTemplateKindReminder,
 		Format:      "plaintext",
 		Template:    defaultNotificationTemplate,
 		ChannelType: nil,
@@ -31,8 +31,16 @@ func newTestDB(t *testing.T) *gorm.DB {
 		&model.Subscription{},
 		&model.SubscriptionEvent{},
 		&model.SubscriptionActionSnooze{},
+		&model.SubscriptionPriceChange{},
+		&model.SubscriptionUsage{},
+		&model.CustomFieldDefinition{},
+		&model.SubscriptionCustomFieldValue{},
+		&model.SubscriptionAttachment{},
 		&model.NotificationLog{},
 		&model.NotificationTemplate{},
+		&model.NotificationPolicy{},
+		&model.CalendarToken{},
+		&model.AccountLockout{},
 	); err != nil {
 		t.Fatalf("failed to migrate test database: %v", err)
 	}
@@ -21,6 +21,11 @@ func migrateUserLifecycleSecurityTables(t *testing.T, db *gorm.DB) {
 		&model.PaymentMethod{},
 		&model.Subscription{},
 		&model.SubscriptionEvent{},
+		&model.SubscriptionPriceChange{},
+		&model.SubscriptionUsage{},
+		&model.CustomFieldDefinition{},
+		&model.SubscriptionCustomFieldValue{},
+		&model.SubscriptionAttachment{},
 		&model.NotificationChannel{},
 		&model.NotificationPolicy{},
 		&model.NotificationLog{},
@@ -179,7 +184,7 @@ func TestDeleteUserRemovesUserScopedRecordsAndInvalidatesCredentials(t *testing.
 
 	logEntry := model.NotificationLog{
 		UserID:         target.ID,
-		SubscriptionID: subscription.ID,
+		SubscriptionID: &subscription.ID,
 		ChannelType:    "webhook",
 		NotifyDate:     time.Now().UTC(),
 		Status:         "sent",
@@ -249,6 +254,11 @@ func TestDeleteUserRemovesUserScopedRecordsAndInvalidatesCredentials(t *testing.
 		{name: "subscriptions", model: &model.Subscription{}},
 		{name: "subscription_action_snoozes", model: &model.SubscriptionActionSnooze{}},
 		{name: "subscription_events", model: &model.SubscriptionEvent{}},
+		{name: "subscription_price_changes", model: &model.SubscriptionPriceChange{}},
+		{name: "subscription_usages", model: &model.SubscriptionUsage{}},
+		{name: "subscription_custom_field_values", model: &model.SubscriptionCustomFieldValue{}},
+		{name: "subscription_attachments", model: &model.SubscriptionAttachment{}},
+		{name: "custom_field_definitions", model: &model.CustomFieldDefinition{}},
 		{name: "payment_methods", model: &model.PaymentMethod{}},
 		{name: "user_currencies", model: &model.UserCurrency{}},
 		{name: "categories", model: &model.Category{}},
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/shiroha/subdux/internal/model"
+	"github.com/shiroha/subdux/internal/pkg"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrUnsupportedLocale = errors.New("locale must be one of en, zh-CN or ja")
+	ErrInvalidTimezone   = errors.New("timezone must be a valid IANA time zone name")
+)
+
+// UserPreferenceService manages the per-user locale and timezone used to
+// localize notifications. The preferred currency lives on the same row but is
+// managed by ExchangeRateService.
+type UserPreferenceService struct {
+	DB *gorm.DB
+}
+
+func NewUserPreferenceService(db *gorm.DB) *UserPreferenceService {
+	return &UserPreferenceService{DB: db}
+}
+
+type UpdateLocalePreferenceInput struct {
+	Locale   *string `json:"locale"`
+	Timezone *string `json:"timezone"`
+}
+
+// LocalePreference is the effective locale and timezone of a user. An empty
+// Timezone means the system timezone.
+type LocalePreference struct {
+	Locale   string
+	Timezone string
+}
+
+func (s *UserPreferenceService) GetLocalePreference(userID uint) (*LocalePreference, error) {
+	var pref model.UserPreference
+	if err := s.DB.Select("locale", "timezone").Where("user_id = ?", userID).Limit(1).Find(&pref).Error; err != nil {
+		return nil, err
+	}
+	return &LocalePreference{
+		Locale:   notificationLocaleOrDefault(pref.Locale),
+		Timezone: pref.Timezone,
+	}, nil
+}
+
+// UpdateLocalePreference updates the fields present in input. Locales are
+// normalized (e.g. "zh-Hans" becomes "zh-CN"); an empty timezone resets the
+// preference to the system timezone.
+func (s *UserPreferenceService) UpdateLocalePreference(userID uint, input UpdateLocalePreferenceInput) (*LocalePreference, error) {
+	current, err := s.GetLocalePreference(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	pref := model.UserPreference{
+		UserID:   userID,
+		Locale:   current.Locale,
+		Timezone: current.Timezone,
+	}
+	if input.Locale != nil {
+		locale, ok := NormalizeLocale(*input.Locale)
+		if !ok {
+			return nil, ErrUnsupportedLocale
+		}
+		pref.Locale = locale
+	}
+	if input.Timezone != nil {
+		timezone, err := normalizeUserTimezone(*input.Timezone)
+		if err != nil {
+			return nil, err
+		}
+		pref.Timezone = timezone
+	}
+
+	if err := s.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"locale", "timezone", "updated_at"}),
+	}).Create(&pref).Error; err != nil {
+		return nil, err
+	}
+
+	return s.GetLocalePreference(userID)
+}
+
+func normalizeUserTimezone(value string) (string, error) {
+	timezone := strings.TrimSpace(value)
+	if timezone == "" {
+		return "", nil
+	}
+	if strings.EqualFold(timezone, "local") {
+		return "", ErrInvalidTimezone
+	}
+	if _, err := time.LoadLocation(timezone); err != nil {
+		return "", ErrInvalidTimezone
+	}
+	return timezone, nil
+}
+
+// loadUserLocation returns the user's preferred timezone, or nil when the user
+// follows the system timezone. A stored name that no longer loads is treated
+// as unset.
+func loadUserLocation(db *gorm.DB, userID uint) *time.Location {
+	var pref model.UserPreference
+	if err := db.Select("timezone").Where("user_id = ?", userID).Limit(1).Find(&pref).Error; err != nil {
+		return nil
+	}
+	if pref.Timezone == "" {
+		return nil
+	}
+	loc, err := time.LoadLocation(pref.Timezone)
+	if err != nil {
+		return nil
+	}
+	return loc
+}
+
+// userLocation returns the timezone that decides which calendar day it is for
+// the user: their preference, otherwise the system timezone.
+func userLocation(db *gorm.DB, userID uint) *time.Location {
+	if loc := loadUserLocation(db, userID); loc != nil {
+		return loc
+	}
+	return pkg.GetSystemTimezone()
+}
+
+// userNow returns the current time in the user's timezone. Date math that
+// normalizes it with normalizeDateUTC therefore works on the user's "today".
+func userNow(db *gorm.DB, userID uint) time.Time {
+	return pkg.NowIn(userLocation(db, userID))
+}
+
+// inUserTimezone expresses t in the user's preferred timezone. Without a
+// preference t is returned unchanged, so callers that already pass a
+// system-timezone time keep their behavior.
+func inUserTimezone(db *gorm.DB, userID uint, t time.Time) time.Time {
+	if loc := loadUserLocation(db, userID); loc != nil {
+		return t.In(loc)
+	}
+	return t
+}
+
+// userPreferredCurrency returns the user's preferred currency, defaulting to
+// USD when no preference is stored.
+func userPreferredCurrency(db *gorm.DB, userID uint) (string, error) {
+	var preference model.UserPreference
+	err := db.Select("preferred_currency").Where("user_id = ?", userID).Limit(1).Find(&preference).Error
+	if err != nil {
+		return "", err
+	}
+	currency := strings.ToUpper(strings.TrimSpace(preference.PreferredCurrency))
+	if currency == "" {
+		currency = "USD"
+	}
+	return currency, nil
+}
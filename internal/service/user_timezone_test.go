@@ -0,0 +1,201 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shiroha/subdux/internal/model"
+	"github.com/shiroha/subdux/internal/pkg"
+	"gorm.io/gorm"
+)
+
+func setUserTimezoneForTest(t *testing.T, db *gorm.DB, userID uint, timezone string) {
+	t.Helper()
+
+	if _, err := NewUserPreferenceService(db).UpdateLocalePreference(userID, UpdateLocalePreferenceInput{Timezone: &timezone}); err != nil {
+		t.Fatalf("UpdateLocalePreference(%q) error = %v", timezone, err)
+	}
+}
+
+func createUserTimezoneTestUser(t *testing.T, db *gorm.DB, username string) model.User {
+	t.Helper()
+
+	user := model.User{
+		Username: username,
+		Email:    username + "@example.com",
+		Password: "hashed-password",
+		Role:     "user",
+		Status:   "active",
+	}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	return user
+}
+
+func createManualRenewTestSubscription(t *testing.T, db *gorm.DB, userID uint, nextBilling time.Time) model.Subscription {
+	t.Helper()
+
+	sub := createNotificationOutboxSubscription(t, db, userID, nextBilling)
+	if err := db.Model(&sub).Update("renewal_mode", renewalModeManualRenew).Error; err != nil {
+		t.Fatalf("failed to switch subscription to manual renew: %v", err)
+	}
+	sub.RenewalMode = renewalModeManualRenew
+	return sub
+}
+
+func loadSubscriptionStatus(t *testing.T, db *gorm.DB, id uint) string {
+	t.Helper()
+
+	var sub model.Subscription
+	if err := db.First(&sub, id).Error; err != nil {
+		t.Fatalf("load subscription failed: %v", err)
+	}
+	return sub.Status
+}
+
+func TestReconcileDueLifecyclesUsesEachUsersCalendarDay(t *testing.T) {
+	tests := []struct {
+		name      string
+		now       time.Time
+		billing   time.Time
+		timezone  string
+		wantEnded bool
+	}{
+		{
+			// 16:00 UTC on Mar 14 is already Mar 15 in Tokyo.
+			name:      "ahead of server",
+			now:       time.Date(2026, 3, 14, 16, 0, 0, 0, time.UTC),
+			billing:   time.Date(2026, 3, 14, 0, 0, 0, 0, time.UTC),
+			timezone:  "Asia/Tokyo",
+			wantEnded: true,
+		},
+		{
+			// 04:30 UTC on Nov 2 is still Nov 1 in New York, after the
+			// fall-back transition that morning.
+			name:      "behind server after DST ends",
+			now:       time.Date(2026, 11, 2, 4, 30, 0, 0, time.UTC),
+			billing:   time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC),
+			timezone:  "America/New_York",
+			wantEnded: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newNotificationDigestTestDB(t)
+			serverUser := createUserTimezoneTestUser(t, db, "server-user")
+			zonedUser := createUserTimezoneTestUser(t, db, "zoned-user")
+			setUserTimezoneForTest(t, db, zonedUser.ID, tt.timezone)
+			serverSub := createManualRenewTestSubscription(t, db, serverUser.ID, tt.billing)
+			zonedSub := createManualRenewTestSubscription(t, db, zonedUser.ID, tt.billing)
+
+			if err := NewSubscriptionService(db).reconcileDueLifecycles(tt.now); err != nil {
+				t.Fatalf("reconcileDueLifecycles() error = %v", err)
+			}
+
+			wantZoned := subscriptionStatusActive
+			wantServer := subscriptionStatusEnded
+			if tt.wantEnded {
+				wantZoned, wantServer = subscriptionStatusEnded, subscriptionStatusActive
+			}
+			if got := loadSubscriptionStatus(t, db, zonedSub.ID); got != wantZoned {
+				t.Fatalf("%s subscription status = %q, want %q", tt.timezone, got, wantZoned)
+			}
+			if got := loadSubscriptionStatus(t, db, serverSub.ID); got != wantServer {
+				t.Fatalf("UTC subscription status = %q, want %q", got, wantServer)
+			}
+		})
+	}
+}
+
+func TestEnqueuePendingNotificationsCountsDaysInUserTimezoneAcrossDST(t *testing.T) {
+	db := newNotificationDigestTestDB(t)
+	user := createNotificationOutboxUser(t, db)
+	setUserTimezoneForTest(t, db, user.ID, "America/New_York")
+	createNotificationOutboxTemplate(t, db, user.ID)
+	createNotificationOutboxChannel(t, db, user.ID, "webhook", `{"url":"https://example.com/hook"}`)
+	if err := db.Create(&model.NotificationPolicy{UserID: user.ID, DaysBefore: 2, NotifyOnDueDay: false}).Error; err != nil {
+		t.Fatalf("failed to create notification policy: %v", err)
+	}
+
+	// 03:30 UTC on Mar 8 is 22:30 on Mar 7 in New York. Clocks spring forward
+	// on Mar 8, so Mar 7 to Mar 9 spans only 47 hours but is still two days.
+	restoreClock := pkg.SetNowForTest(time.Date(2026, 3, 8, 3, 30, 0, 0, time.UTC))
+	t.Cleanup(restoreClock)
+	billing := time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC)
+	createNotificationOutboxSubscription(t, db, user.ID, billing)
+
+	svc := NewNotificationService(db, NewNotificationTemplateService(db, NewTemplateValidator()), NewTemplateRenderer(NewTemplateValidator()))
+	if err := svc.EnqueuePendingNotifications(); err != nil {
+		t.Fatalf("EnqueuePendingNotifications() error = %v", err)
+	}
+
+	var jobs []model.NotificationOutbox
+	if err := db.Find(&jobs).Error; err != nil {
+		t.Fatalf("load outbox jobs failed: %v", err)
+	}
+	if len(jobs) != 1 {
+		t.Fatalf("outbox job count = %d, want 1", len(jobs))
+	}
+	if jobs[0].TriggerType != notificationTriggerDaysBefore {
+		t.Fatalf("trigger_type = %q, want %q", jobs[0].TriggerType, notificationTriggerDaysBefore)
+	}
+	if got := normalizeDateUTC(jobs[0].NotifyDate); !got.Equal(billing) {
+		t.Fatalf("notify_date = %s, want %s", got, billing)
+	}
+	if !strings.Contains(jobs[0].Message, "2026-03-09") {
+		t.Fatalf("message = %q, want billing date 2026-03-09", jobs[0].Message)
+	}
+}
+
+func TestDashboardDueThisMonthUsesUserTimezone(t *testing.T) {
+	db := newNotificationDigestTestDB(t)
+	user := createNotificationOutboxUser(t, db)
+	createNotificationOutboxSubscription(t, db, user.ID, time.Date(2026, 4, 5, 0, 0, 0, 0, time.UTC))
+
+	// 16:00 UTC on Mar 31 is already Apr 1 in Tokyo.
+	restoreClock := pkg.SetNowForTest(time.Date(2026, 3, 31, 16, 0, 0, 0, time.UTC))
+	t.Cleanup(restoreClock)
+
+	svc := NewSubscriptionService(db)
+	summary, err := svc.GetDashboardSummary(user.ID, "USD", nil)
+	if err != nil {
+		t.Fatalf("GetDashboardSummary() error = %v", err)
+	}
+	if summary.DueThisMonth != 0 {
+		t.Fatalf("due this month in server timezone = %v, want 0", summary.DueThisMonth)
+	}
+
+	setUserTimezoneForTest(t, db, user.ID, "Asia/Tokyo")
+	summary, err = svc.GetDashboardSummary(user.ID, "USD", nil)
+	if err != nil {
+		t.Fatalf("GetDashboardSummary() error = %v", err)
+	}
+	if summary.DueThisMonth != 12.5 {
+		t.Fatalf("due this month in Tokyo = %v, want 12.5", summary.DueThisMonth)
+	}
+}
+
+func TestICalFeedUsesUserTimezone(t *testing.T) {
+	db := newNotificationDigestTestDB(t)
+	user := createNotificationOutboxUser(t, db)
+	setUserTimezoneForTest(t, db, user.ID, "Europe/London")
+	createNotificationOutboxSubscription(t, db, user.ID, time.Date(2026, 10, 24, 0, 0, 0, 0, time.UTC))
+
+	// 23:30 UTC on Oct 24 is 00:30 on Oct 25 in London (BST), so the monthly
+	// renewal due on Oct 24 has already rolled over to Nov 24.
+	restoreClock := pkg.SetNowForTest(time.Date(2026, 10, 24, 23, 30, 0, 0, time.UTC))
+	t.Cleanup(restoreClock)
+
+	feed, err := NewCalendarService(db).GenerateICalFeed(user.ID)
+	if err != nil {
+		t.Fatalf("GenerateICalFeed() error = %v", err)
+	}
+	for _, want := range []string{"X-WR-TIMEZONE:Europe/London\r\n", "DTSTART;VALUE=DATE:20261124\r\n"} {
+		if !strings.Contains(feed, want) {
+			t.Fatalf("feed missing %q:\n%s", want, feed)
+		}
+	}
+}
@@ -162,7 +162,7 @@ export interface UpdateNotificationPolicyInput {
 
 export interface NotificationLog {
   id: number
-  subscription_id: number
+  subscription_id: number | null
   channel_type: string
   notify_date: string
   status: string